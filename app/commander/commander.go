@@ -22,13 +22,16 @@ type Commander struct {
 	ohm      outbound.Manager
 	tag      string
 	listen   string
+	gateway  *GatewayConfig
+	relay    *Gateway
 }
 
 // NewCommander creates a new Commander based on the given config.
 func NewCommander(ctx context.Context, config *Config) (*Commander, error) {
 	c := &Commander{
-		tag:    config.Tag,
-		listen: config.Listen,
+		tag:     config.Tag,
+		listen:  config.Listen,
+		gateway: config.Gateway,
 	}
 
 	common.Must(core.RequireFeatures(ctx, func(om outbound.Manager) {
@@ -66,6 +69,17 @@ func (c *Commander) Start() error {
 	for _, service := range c.services {
 		service.Register(c.server)
 	}
+	if c.gateway != nil {
+		relay, err := newGateway(c.gateway, c.server)
+		if err == nil {
+			err = relay.Start(c.server)
+		}
+		if err != nil {
+			c.Unlock()
+			return err
+		}
+		c.relay = relay
+	}
 	c.Unlock()
 
 	var listen = func(listener net.Listener) {
@@ -107,6 +121,10 @@ func (c *Commander) Close() error {
 	c.Lock()
 	defer c.Unlock()
 
+	if c.relay != nil {
+		c.relay.Close()
+		c.relay = nil
+	}
 	if c.server != nil {
 		c.server.Stop()
 		c.server = nil
@@ -115,7 +133,13 @@ func (c *Commander) Close() error {
 	return nil
 }
 
+// declareTag declares the tag of the outbound the commander adds.
+func declareTag(v *core.Validation, _ string, c interface{}) {
+	v.AddOutboundTag(c.(*Config).Tag)
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), declareTag))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
 		return NewCommander(ctx, cfg.(*Config))
 	}))
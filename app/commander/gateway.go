@@ -0,0 +1,379 @@
+package commander
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/xtls/xray-core/common/signal/done"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// maxGatewayRequestSize limits the JSON body of a request to the gateway.
+const maxGatewayRequestSize = 1 << 20
+
+// gatewayMethod is a method of a Commander service that the gateway relays.
+type gatewayMethod struct {
+	input  protoreflect.MessageType
+	output protoreflect.MessageType
+	stream bool
+}
+
+// gatewayRequest is the envelope of a call over WebSocket. Method is "<service>/<method>",
+// such as "xray.app.stats.command.StatsService/QueryStats".
+type gatewayRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// gatewayResponse is the envelope of a reply. A streaming call gets one reply per message,
+// followed by one with Done set.
+type gatewayResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *gatewayError   `json:"error,omitempty"`
+	Done   bool            `json:"done,omitempty"`
+}
+
+type gatewayError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Gateway relays JSON requests over HTTP and WebSocket to the gRPC services of Commander,
+// mapping them to and from protobuf with protojson.
+type Gateway struct {
+	config   *GatewayConfig
+	methods  map[string]*gatewayMethod
+	loopback *OutboundListener
+	conn     *grpc.ClientConn
+	server   *http.Server
+	upgrader *websocket.Upgrader
+}
+
+// newGateway creates a Gateway calling the services registered to server.
+func newGateway(config *GatewayConfig, server *grpc.Server) (*Gateway, error) {
+	g := &Gateway{
+		config:  config,
+		methods: make(map[string]*gatewayMethod),
+		loopback: &OutboundListener{
+			buffer: make(chan net.Conn, 4),
+			done:   done.New(),
+		},
+	}
+	for name, info := range server.GetServiceInfo() {
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			continue
+		}
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+		for _, m := range info.Methods {
+			if m.IsClientStream {
+				// not expressible as one JSON request
+				continue
+			}
+			md := sd.Methods().ByName(protoreflect.Name(m.Name))
+			if md == nil {
+				continue
+			}
+			input, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+			if err != nil {
+				return nil, newError("unknown input of ", name, "/", m.Name).Base(err)
+			}
+			output, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+			if err != nil {
+				return nil, newError("unknown output of ", name, "/", m.Name).Base(err)
+			}
+			g.methods[name+"/"+m.Name] = &gatewayMethod{
+				input:  input,
+				output: output,
+				stream: m.IsServerStream,
+			}
+		}
+	}
+
+	conn, err := grpc.Dial("passthrough:///commander", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			c1, c2 := net.Pipe()
+			g.loopback.add(c2)
+			return c1, nil
+		}))
+	if err != nil {
+		return nil, newError("failed to dial services").Base(err)
+	}
+	g.conn = conn
+
+	g.upgrader = &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return g.allowOrigin(r.Header.Get("Origin"))
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ws", g.serveWebSocket)
+	mux.HandleFunc("/api/", g.serveHTTP)
+	g.server = &http.Server{
+		Handler: mux,
+	}
+	return g, nil
+}
+
+// Start serves the services to the gateway, and the gateway on its listen address.
+func (g *Gateway) Start(server *grpc.Server) error {
+	go func() {
+		if err := server.Serve(g.loopback); err != nil {
+			newError("failed to serve the gateway").Base(err).AtError().WriteToLog()
+		}
+	}()
+
+	l, err := net.Listen("tcp", g.config.Listen)
+	if err != nil {
+		return newError("API gateway failed to listen on ", g.config.Listen).Base(err)
+	}
+	newError("API gateway listening on ", l.Addr()).AtInfo().WriteToLog()
+	go func() {
+		if err := g.server.Serve(l); err != nil && err != http.ErrServerClosed {
+			newError("failed to start API gateway").Base(err).AtError().WriteToLog()
+		}
+	}()
+	return nil
+}
+
+// Close implements common.Closable.
+func (g *Gateway) Close() error {
+	g.server.Close()
+	g.conn.Close()
+	return g.loopback.Close()
+}
+
+func (g *Gateway) allowOrigin(origin string) bool {
+	if origin == "" {
+		// not from a browser
+		return true
+	}
+	for _, o := range g.config.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gateway) authenticate(r *http.Request) bool {
+	if g.config.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		// browsers can't set headers on WebSocket
+		token = r.URL.Query().Get("access_token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(g.config.Token)) == 1
+}
+
+// check handles CORS and authentication, and tells if the request is to be served.
+func (g *Gateway) check(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !g.allowOrigin(origin) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return false
+	}
+	if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Add("Vary", "Origin")
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	if !g.authenticate(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// serveHTTP serves unary methods at /api/<service>/<method>, with the request as the body.
+func (g *Gateway) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !g.check(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params, err := io.ReadAll(io.LimitReader(r.Body, maxGatewayRequestSize))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var response *gatewayResponse
+	g.call(r.Context(), strings.TrimPrefix(r.URL.Path, "/api/"), params, false, func(resp *gatewayResponse) {
+		response = resp
+	})
+	if response.Error != nil {
+		w.WriteHeader(httpStatus(response.Error.Code))
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// serveWebSocket serves requests in envelopes over one WebSocket, which streaming methods push messages to.
+func (g *Gateway) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !g.check(w, r) {
+		return
+	}
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(maxGatewayRequestSize)
+	ctx, cancel := context.WithCancel(r.Context())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+		conn.Close()
+	}()
+
+	var writeLock sync.Mutex
+	reply := func(resp *gatewayResponse) {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		conn.WriteJSON(resp)
+	}
+	for {
+		var req gatewayRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				reply(&gatewayResponse{Error: &gatewayError{Code: codes.InvalidArgument.String(), Message: err.Error()}})
+				continue
+			}
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.call(ctx, req.Method, req.Params, true, func(resp *gatewayResponse) {
+				resp.ID = req.ID
+				reply(resp)
+			})
+		}()
+	}
+}
+
+// call calls method with params, and passes each reply to reply.
+func (g *Gateway) call(ctx context.Context, method string, params json.RawMessage, allowStream bool, reply func(*gatewayResponse)) {
+	fail := func(err error) {
+		s := status.Convert(err)
+		reply(&gatewayResponse{Error: &gatewayError{Code: s.Code().String(), Message: s.Message()}})
+	}
+
+	m, found := g.methods[method]
+	if !found {
+		fail(status.Error(codes.Unimplemented, "unknown method "+method))
+		return
+	}
+	if m.stream && !allowStream {
+		fail(status.Error(codes.Unimplemented, "streaming method "+method+" needs WebSocket"))
+		return
+	}
+	in := m.input.New().Interface()
+	if len(params) > 0 {
+		if err := protojson.Unmarshal(params, in); err != nil {
+			fail(status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+	}
+
+	fullMethod := "/" + method
+	if !m.stream {
+		out := m.output.New().Interface()
+		if err := g.conn.Invoke(ctx, fullMethod, in, out); err != nil {
+			fail(err)
+			return
+		}
+		reply(g.result(out))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := g.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := stream.SendMsg(in); err != nil {
+		fail(err)
+		return
+	}
+	if err := stream.CloseSend(); err != nil {
+		fail(err)
+		return
+	}
+	for {
+		out := m.output.New().Interface()
+		if err := stream.RecvMsg(out); err != nil {
+			if err == io.EOF {
+				reply(&gatewayResponse{Done: true})
+			} else {
+				fail(err)
+			}
+			return
+		}
+		reply(g.result(out))
+	}
+}
+
+func (g *Gateway) result(m proto.Message) *gatewayResponse {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return &gatewayResponse{Error: &gatewayError{Code: codes.Internal.String(), Message: err.Error()}}
+	}
+	return &gatewayResponse{Result: b}
+}
+
+// httpStatus maps the name of a gRPC code to an HTTP status.
+func httpStatus(code string) int {
+	switch code {
+	case codes.InvalidArgument.String(), codes.FailedPrecondition.String(), codes.OutOfRange.String():
+		return http.StatusBadRequest
+	case codes.NotFound.String():
+		return http.StatusNotFound
+	case codes.AlreadyExists.String(), codes.Aborted.String():
+		return http.StatusConflict
+	case codes.PermissionDenied.String():
+		return http.StatusForbidden
+	case codes.Unauthenticated.String():
+		return http.StatusUnauthorized
+	case codes.Unimplemented.String():
+		return http.StatusNotImplemented
+	case codes.Unavailable.String():
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded.String():
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
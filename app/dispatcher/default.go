@@ -14,8 +14,10 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/trace"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
+	"github.com/xtls/xray-core/features/extension"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
@@ -25,6 +27,9 @@ import (
 	"github.com/xtls/xray-core/transport/pipe"
 )
 
+// sniffingTimeout is how long content is waited for at most to be sniffed.
+const sniffingTimeout = 200 * time.Millisecond
+
 var errSniffingTimeout = newError("timeout on sniffing")
 
 type cachedReader struct {
@@ -33,8 +38,8 @@ type cachedReader struct {
 	cache  buf.MultiBuffer
 }
 
-func (r *cachedReader) Cache(b *buf.Buffer) {
-	mb, _ := r.reader.ReadMultiBufferTimeout(time.Millisecond * 100)
+func (r *cachedReader) Cache(b *buf.Buffer, timeout time.Duration) error {
+	mb, err := r.reader.ReadMultiBufferTimeout(timeout)
 	r.Lock()
 	if !mb.IsEmpty() {
 		r.cache, _ = buf.MergeMulti(r.cache, mb)
@@ -44,6 +49,7 @@ func (r *cachedReader) Cache(b *buf.Buffer) {
 	n := r.cache.Copy(rawBytes)
 	b.Resize(0, int32(n))
 	r.Unlock()
+	return err
 }
 
 func (r *cachedReader) readInternal() buf.MultiBuffer {
@@ -86,6 +92,15 @@ func (r *cachedReader) Interrupt() {
 	r.reader.Interrupt()
 }
 
+func (r *cachedReader) InterruptWithError(err error) {
+	r.Lock()
+	if r.cache != nil {
+		r.cache = buf.ReleaseMulti(r.cache)
+	}
+	r.Unlock()
+	r.reader.InterruptWithError(err)
+}
+
 // DefaultDispatcher is a default implementation of Dispatcher.
 type DefaultDispatcher struct {
 	ohm    outbound.Manager
@@ -94,6 +109,12 @@ type DefaultDispatcher struct {
 	stats  stats.Manager
 	dns    dns.Client
 	fdns   dns.FakeDNSEngine
+	tracer extension.Tracer
+
+	// routeAccess keeps route picking away from half applied changes.
+	routeAccess sync.RWMutex
+	// routeGeneration counts the changes applied under routeAccess.
+	routeGeneration uint64
 }
 
 func init() {
@@ -103,6 +124,9 @@ func init() {
 			core.RequireFeatures(ctx, func(fdns dns.FakeDNSEngine) {
 				d.fdns = fdns
 			})
+			core.RequireFeatures(ctx, func(tracer extension.Tracer) {
+				d.tracer = tracer
+			})
 			return d.Init(config.(*Config), om, router, pm, sm, dc)
 		}); err != nil {
 			return nil, err
@@ -213,17 +237,31 @@ func (d *DefaultDispatcher) shouldOverride(ctx context.Context, result SniffResu
 	return false
 }
 
+// LockRoutes implements routing.RouteLocker.
+func (d *DefaultDispatcher) LockRoutes() {
+	d.routeAccess.Lock()
+}
+
+// UnlockRoutes implements routing.RouteLocker.
+func (d *DefaultDispatcher) UnlockRoutes() {
+	d.routeGeneration++
+	d.routeAccess.Unlock()
+}
+
 // Dispatch implements routing.Dispatcher.
 func (d *DefaultDispatcher) Dispatch(ctx context.Context, destination net.Destination) (*transport.Link, error) {
 	if !destination.IsValid() {
 		panic("Dispatcher: Invalid destination.")
 	}
+	if err := policy.CheckDestination(ctx, d.stats, destination); err != nil {
+		return nil, err
+	}
 	outbounds := session.OutboundsFromContext(ctx)
 	if len(outbounds) == 0 {
 		outbounds = []*session.Outbound{{}}
 		ctx = session.ContextWithOutbounds(ctx, outbounds)
 	}
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	ob.OriginalTarget = destination
 	ob.Target = destination
 	content := session.ContentFromContext(ctx)
@@ -232,17 +270,22 @@ func (d *DefaultDispatcher) Dispatch(ctx context.Context, destination net.Destin
 		ctx = session.ContextWithContent(ctx, content)
 	}
 
+	ctx, span := d.startTrace(ctx, destination)
 	sniffingRequest := content.SniffingRequest
 	inbound, outbound := d.getLink(ctx)
-	if !sniffingRequest.Enabled {
-		go d.routedDispatch(ctx, outbound, destination)
+	if !sniffingRequest.Enabled || destination.Network == net.Network_ICMP {
+		go func() {
+			d.routedDispatch(ctx, outbound, destination)
+			span.End()
+		}()
 	} else {
 		go func() {
+			defer span.End()
 			cReader := &cachedReader{
 				reader: outbound.Reader.(*pipe.Reader),
 			}
 			outbound.Reader = cReader
-			result, err := sniffer(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
+			result, err := d.sniff(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
 			if err == nil {
 				content.Protocol = result.Protocol()
 			}
@@ -275,12 +318,15 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 	if !destination.IsValid() {
 		return newError("Dispatcher: Invalid destination.")
 	}
+	if err := policy.CheckDestination(ctx, d.stats, destination); err != nil {
+		return err
+	}
 	outbounds := session.OutboundsFromContext(ctx)
 	if len(outbounds) == 0 {
 		outbounds = []*session.Outbound{{}}
 		ctx = session.ContextWithOutbounds(ctx, outbounds)
 	}
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	ob.OriginalTarget = destination
 	ob.Target = destination
 	content := session.ContentFromContext(ctx)
@@ -288,15 +334,17 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 		content = new(session.Content)
 		ctx = session.ContextWithContent(ctx, content)
 	}
+	ctx, span := d.startTrace(ctx, destination)
+	defer span.End()
 	sniffingRequest := content.SniffingRequest
-	if !sniffingRequest.Enabled {
+	if !sniffingRequest.Enabled || destination.Network == net.Network_ICMP {
 		d.routedDispatch(ctx, outbound, destination)
 	} else {
 		cReader := &cachedReader{
 			reader: outbound.Reader.(*pipe.Reader),
 		}
 		outbound.Reader = cReader
-		result, err := sniffer(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
+		result, err := d.sniff(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
 		if err == nil {
 			content.Protocol = result.Protocol()
 		}
@@ -324,6 +372,60 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 	return nil
 }
 
+// startTrace starts the trace of a connection to destination, if the connection is to be traced.
+func (d *DefaultDispatcher) startTrace(ctx context.Context, destination net.Destination) (context.Context, *trace.Span) {
+	if d.tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := d.tracer.Trace(ctx)
+	if span == nil {
+		return ctx, nil
+	}
+	span.SetAttribute("session.id", session.IDFromContext(ctx))
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		span.SetAttribute("inbound.tag", inbound.Tag)
+		if inbound.Source.IsValid() {
+			span.SetAttribute("source", inbound.Source)
+		}
+		if inbound.User != nil && inbound.User.Email != "" {
+			span.SetAttribute("user", inbound.User.Email)
+		}
+	}
+	span.SetAttribute("destination", destination)
+	return ctx, span
+}
+
+// sniff sniffs the content from cReader, and counts the outcome.
+func (d *DefaultDispatcher) sniff(ctx context.Context, cReader *cachedReader, metadataOnly bool, network net.Network) (SniffResult, error) {
+	_, span := trace.Start(ctx, "sniffing")
+	result, err := sniffer(ctx, cReader, metadataOnly, network)
+	d.countSniffing(err)
+	if err == nil {
+		span.SetAttribute("protocol", result.Protocol())
+		span.SetAttribute("domain", result.Domain())
+	}
+	span.EndWithError(err)
+	return result, err
+}
+
+// countSniffing counts the outcome of sniffing in the stats counters
+// "sniffing>>>matched", "sniffing>>>unmatched" and "sniffing>>>timeout".
+func (d *DefaultDispatcher) countSniffing(err error) {
+	if d.stats == nil {
+		return
+	}
+	outcome := "matched"
+	switch {
+	case err == errSniffingTimeout:
+		outcome = "timeout"
+	case err != nil:
+		outcome = "unmatched"
+	}
+	if c, _ := stats.GetOrRegisterCounter(d.stats, "sniffing>>>"+outcome); c != nil {
+		c.Add(1)
+	}
+}
+
 func sniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, network net.Network) (SniffResult, error) {
 	payload := buf.New()
 	defer payload.Release()
@@ -337,18 +439,19 @@ func sniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, netw
 	}
 
 	contentResult, contentErr := func() (SniffResult, error) {
-		totalAttempt := 0
+		// Content is sniffed again on every read, so that it is dispatched as soon as it is known.
+		deadline := time.Now().Add(sniffingTimeout)
 		for {
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			default:
-				totalAttempt++
-				if totalAttempt > 2 {
+				timeout := time.Until(deadline)
+				if timeout <= 0 {
 					return nil, errSniffingTimeout
 				}
 
-				cReader.Cache(payload)
+				readErr := cReader.Cache(payload, timeout)
 				if !payload.IsEmpty() {
 					result, err := sniffer.Sniff(ctx, payload.Bytes(), network)
 					if err != common.ErrNoClue {
@@ -358,6 +461,9 @@ func sniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, netw
 				if payload.IsFull() {
 					return nil, errUnknownContent
 				}
+				if readErr != nil && readErr != buf.ErrReadTimeout {
+					return nil, errUnknownContent
+				}
 			}
 		}
 	}()
@@ -370,10 +476,12 @@ func sniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, netw
 	return contentResult, contentErr
 }
 func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.Link, destination net.Destination) {
+	_, routeSpan := trace.Start(ctx, "routing")
+	defer routeSpan.End()
 	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	if hosts, ok := d.dns.(dns.HostsLookup); ok && destination.Address.Family().IsDomain() {
-		proxied := hosts.LookupHosts(ob.Target.String())
+		proxied := hosts.LookupHosts(destination.Address.Domain())
 		if proxied != nil {
 			ro := ob.RouteTarget == destination
 			destination.Address = *proxied
@@ -392,43 +500,95 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 	isPickRoute := 0
 	if forcedOutboundTag := session.GetForcedOutboundTagFromContext(ctx); forcedOutboundTag != "" {
 		ctx = session.SetForcedOutboundTagToContext(ctx, "")
-		if h := d.ohm.GetHandler(forcedOutboundTag); h != nil {
-			isPickRoute = 1
-			newError("taking platform initialized detour [", forcedOutboundTag, "] for [", destination, "]").WriteToLog(session.ExportIDToError(ctx))
-			handler = h
-		} else {
-			newError("non existing tag for platform initialized detour: ", forcedOutboundTag).AtError().WriteToLog(session.ExportIDToError(ctx))
+		d.routeAccess.RLock()
+		h := d.ohm.GetHandler(forcedOutboundTag)
+		d.routeAccess.RUnlock()
+		if h == nil {
+			err := newError("non existing tag for platform initialized detour: ", forcedOutboundTag).AtError()
+			err.WriteToLog(session.ExportIDToError(ctx))
+			routeSpan.EndWithError(err)
 			common.Close(link.Writer)
-			common.Interrupt(link.Reader)
+			common.InterruptWithError(link.Reader, err)
 			return
 		}
+		isPickRoute = 1
+		newError("taking platform initialized detour [", forcedOutboundTag, "] for [", destination, "]").WriteToLog(session.ExportIDToError(ctx))
+		handler = h
 	} else if d.router != nil {
-		if route, err := d.router.PickRoute(routingLink); err == nil {
-			outTag := route.GetOutboundTag()
-			if h := d.ohm.GetHandler(outTag); h != nil {
-				isPickRoute = 2
-				newError("taking detour [", outTag, "] for [", destination, "]").WriteToLog(session.ExportIDToError(ctx))
-				handler = h
-			} else {
-				newError("non existing outTag: ", outTag).AtWarning().WriteToLog(session.ExportIDToError(ctx))
+		var route routing.Route
+		found := false
+		for {
+			// rules are matched without the lock, as that may take a DNS
+			// query; the route is picked again if changes landed meanwhile
+			d.routeAccess.RLock()
+			generation := d.routeGeneration
+			d.routeAccess.RUnlock()
+
+			r, err := d.router.PickRoute(routingLink)
+
+			d.routeAccess.RLock()
+			if d.routeGeneration != generation {
+				d.routeAccess.RUnlock()
+				continue
 			}
-		} else {
+			route, handler = nil, nil
+			if err == nil {
+				route = r
+				handler = d.ohm.GetHandler(r.GetOutboundTag())
+			}
+			found = handler != nil
+			if handler == nil {
+				handler = d.ohm.GetDefaultHandler()
+			}
+			d.routeAccess.RUnlock()
+			break
+		}
+
+		if route == nil {
 			newError("default route for ", destination).WriteToLog(session.ExportIDToError(ctx))
+		} else if !found {
+			newError("non existing outTag: ", route.GetOutboundTag()).AtWarning().WriteToLog(session.ExportIDToError(ctx))
+		} else {
+			isPickRoute = 2
+			newError("taking detour [", route.GetOutboundTag(), "] for [", destination, "]").WriteToLog(session.ExportIDToError(ctx))
+			if counted, ok := route.(routing.CountedRoute); ok {
+				uplink, downlink := counted.Dispatched()
+				link = &transport.Link{
+					Reader: &SizeStatReader{Counter: uplink, Reader: link.Reader},
+					Writer: &SizeStatWriter{Counter: downlink, Writer: link.Writer},
+				}
+			}
 		}
 	}
 
 	if handler == nil {
+		d.routeAccess.RLock()
 		handler = d.ohm.GetDefaultHandler()
+		d.routeAccess.RUnlock()
 	}
 
 	if handler == nil {
-		newError("default outbound handler not exist").WriteToLog(session.ExportIDToError(ctx))
+		err := newError("default outbound handler not exist")
+		err.WriteToLog(session.ExportIDToError(ctx))
+		routeSpan.EndWithError(err)
+		common.Close(link.Writer)
+		common.InterruptWithError(link.Reader, err)
+		return
+	}
+
+	if destination.Network == net.Network_ICMP && !outbound.RelaysICMP(handler) {
+		err := newError("outbound [", handler.Tag(), "] does not relay ICMP echo to ", destination).AtInfo()
+		err.WriteToLog(session.ExportIDToError(ctx))
+		routeSpan.EndWithError(err)
 		common.Close(link.Writer)
-		common.Interrupt(link.Reader)
+		common.InterruptWithError(link.Reader, err)
 		return
 	}
 
 	ob.Tag = handler.Tag()
+	routeSpan.SetAttribute("outbound.tag", handler.Tag())
+	routeSpan.End()
+	trace.FromContext(ctx).SetAttribute("outbound.tag", handler.Tag())
 	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
 		if tag := handler.Tag(); tag != "" {
 			if inTag == "" {
@@ -441,6 +601,9 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 				accessMessage.Detour = inTag + " >> " + tag
 			}
 		}
+		if annotator, ok := handler.(outbound.AccessAnnotator); ok {
+			ctx = annotator.AnnotateAccess(ctx, accessMessage)
+		}
 		log.Record(accessMessage)
 	}
 
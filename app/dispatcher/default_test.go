@@ -0,0 +1,73 @@
+package dispatcher_test
+
+import (
+	"context"
+	gonet "net"
+	"testing"
+	"time"
+
+	. "github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/app/proxyman"
+	_ "github.com/xtls/xray-core/app/proxyman/outbound"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy/vless"
+	"github.com/xtls/xray-core/proxy/vless/outbound"
+	_ "github.com/xtls/xray-core/transport/internet/tcp"
+)
+
+func TestICMPToOutboundWithoutRelay(t *testing.T) {
+	listener, err := gonet.ListenTCP("tcp", &gonet.TCPAddr{IP: gonet.IPv4(127, 0, 0, 1)})
+	common.Must(err)
+	defer listener.Close()
+
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&Config{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&outbound.Config{
+					Vnext: []*protocol.ServerEndpoint{
+						{
+							Address: net.NewIPOrDomain(net.LocalHostIP),
+							Port:    uint32(listener.Addr().(*gonet.TCPAddr).Port),
+							User: []*protocol.User{
+								{
+									Account: serial.ToTypedMessage(&vless.Account{
+										Id:         protocol.NewID(uuid.New()).String(),
+										Encryption: "none",
+									}),
+								},
+							},
+						},
+					},
+				}),
+			},
+		},
+	}
+	v, err := core.New(config)
+	common.Must(err)
+	common.Must(v.Start())
+	defer v.Close()
+
+	d := v.GetFeature(routing.DispatcherType()).(routing.Dispatcher)
+	link, err := d.Dispatch(context.Background(), net.ICMPDestination(net.ParseAddress("1.1.1.1")))
+	common.Must(err)
+	if _, err := link.Reader.ReadMultiBuffer(); err == nil {
+		t.Error("expected the link to be closed")
+	}
+
+	// VLESS would have sent the echo as a TCP request to its server
+	common.Must(listener.SetDeadline(time.Now().Add(200 * time.Millisecond)))
+	if conn, err := listener.Accept(); err == nil {
+		conn.Close()
+		t.Error("ICMP echo was dispatched to the VLESS outbound")
+	}
+}
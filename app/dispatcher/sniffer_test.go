@@ -0,0 +1,75 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+const xrayKey core.XrayKey = 1
+
+func newSniffingContext() context.Context {
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	return context.WithValue(context.Background(), xrayKey, v)
+}
+
+func TestSniffSplitHTTPRequest(t *testing.T) {
+	reader, writer := pipe.New(pipe.WithSizeLimit(buf.Size))
+	cReader := &cachedReader{reader: reader}
+
+	parts := []string{
+		"GET / HTTP/1.1\r\n",
+		"User-Agent: test\r\nHost: exam",
+		"ple.com\r\n",
+	}
+	hostArrived := make(chan time.Time, 1)
+	go func() {
+		for _, part := range parts {
+			time.Sleep(20 * time.Millisecond)
+			b := buf.New()
+			b.WriteString(part)
+			common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+		}
+		hostArrived <- time.Now()
+	}()
+
+	result, err := sniffer(newSniffingContext(), cReader, false, net.Network_TCP)
+	sniffed := time.Now()
+	common.Must(err)
+	if domain := result.Domain(); domain != "example.com" {
+		t.Error("expect domain example.com, but got ", domain)
+	}
+	if delay := sniffed.Sub(<-hostArrived); delay > sniffingTimeout/2 {
+		t.Error("expect sniffing to finish as the Host header arrives, but took ", delay)
+	}
+
+	mb := cReader.readInternal()
+	if mb.String() != parts[0]+parts[1]+parts[2] {
+		t.Error("unexpected cached content: ", mb.String())
+	}
+	buf.ReleaseMulti(mb)
+}
+
+func TestSniffHTTPRequestWithoutHost(t *testing.T) {
+	reader, writer := pipe.New(pipe.WithSizeLimit(buf.Size))
+	cReader := &cachedReader{reader: reader}
+
+	b := buf.New()
+	b.WriteString("GET / HTTP/1.1\r\nUser-Agent: test\r\n\r\n")
+	common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+
+	start := time.Now()
+	if _, err := sniffer(newSniffingContext(), cReader, false, net.Network_TCP); err == nil || err == errSniffingTimeout {
+		t.Error("expect a definitive non-match, but got ", err)
+	}
+	if elapsed := time.Since(start); elapsed > sniffingTimeout/2 {
+		t.Error("expect a request without Host not to wait for the timeout, but took ", elapsed)
+	}
+}
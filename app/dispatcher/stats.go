@@ -1,6 +1,8 @@
 package dispatcher
 
 import (
+	"time"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/features/stats"
@@ -23,3 +25,36 @@ func (w *SizeStatWriter) Close() error {
 func (w *SizeStatWriter) Interrupt() {
 	common.Interrupt(w.Writer)
 }
+
+func (w *SizeStatWriter) InterruptWithError(err error) {
+	common.InterruptWithError(w.Writer, err)
+}
+
+type SizeStatReader struct {
+	Counter stats.Counter
+	Reader  buf.Reader
+}
+
+func (r *SizeStatReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.Reader.ReadMultiBuffer()
+	r.Counter.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *SizeStatReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	tr, ok := r.Reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := tr.ReadMultiBufferTimeout(timeout)
+	r.Counter.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *SizeStatReader) Interrupt() {
+	common.Interrupt(r.Reader)
+}
+
+func (r *SizeStatReader) InterruptWithError(err error) {
+	common.InterruptWithError(r.Reader, err)
+}
@@ -2,10 +2,12 @@ package dispatcher_test
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/xtls/xray-core/app/dispatcher"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/transport/pipe"
 )
 
 type TestCounter int64
@@ -42,3 +44,26 @@ func TestStatsWriter(t *testing.T) {
 		t.Fatal("unexpected counter value. want 7, but got ", c.Value())
 	}
 }
+
+func TestStatsReader(t *testing.T) {
+	var c TestCounter
+	pReader, pWriter := pipe.New()
+	reader := &SizeStatReader{
+		Counter: &c,
+		Reader:  pReader,
+	}
+
+	common.Must(pWriter.WriteMultiBuffer(buf.MergeBytes(nil, []byte("abcd"))))
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	buf.ReleaseMulti(mb)
+
+	common.Must(pWriter.WriteMultiBuffer(buf.MergeBytes(nil, []byte("efg"))))
+	mb, err = reader.ReadMultiBufferTimeout(time.Second)
+	common.Must(err)
+	buf.ReleaseMulti(mb)
+
+	if c.Value() != 7 {
+		t.Fatal("unexpected counter value. want 7, but got ", c.Value())
+	}
+}
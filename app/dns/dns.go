@@ -12,9 +12,11 @@ import (
 	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/strmatcher"
+	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features"
 	"github.com/xtls/xray-core/features/dns"
 )
@@ -28,6 +30,7 @@ type DNS struct {
 	disableFallbackIfMatch bool
 	ipOption               *dns.IPOption
 	hosts                  *StaticHosts
+	rewriter               *QueryRewriter
 	clients                []*Client
 	ctx                    context.Context
 	domainMatcher          strmatcher.IndexMatcher
@@ -84,6 +87,11 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 		return nil, newError("failed to create hosts").Base(err)
 	}
 
+	rewriter, err := NewQueryRewriter(config.Rewrites)
+	if err != nil {
+		return nil, newError("failed to create query rewriter").Base(err)
+	}
+
 	clients := []*Client{}
 	domainRuleCount := 0
 	for _, ns := range config.NameServer {
@@ -104,6 +112,16 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 		clients = append(clients, client)
 	}
 
+	var validator *dnssecValidator
+	for _, ns := range config.NameServer {
+		if ns.Dnssec == DnssecMode_VALIDATE {
+			if validator, err = newDNSSECValidator(config.TrustAnchors); err != nil {
+				return nil, newError("failed to create DNSSEC validator").Base(err)
+			}
+			break
+		}
+	}
+
 	for _, ns := range config.NameServer {
 		clientIdx := len(clients)
 		updateDomain := func(domainRule strmatcher.Matcher, originalRuleIdx int, matcherInfos []*DomainMatcherInfo) error {
@@ -120,7 +138,7 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 		case net.IPv4len, net.IPv6len:
 			myClientIP = net.IP(ns.ClientIp)
 		}
-		client, err := NewClient(ctx, ns, myClientIP, geoipContainer, &matcherInfos, updateDomain)
+		client, err := NewClient(ctx, ns, myClientIP, validator, geoipContainer, &matcherInfos, updateDomain)
 		if err != nil {
 			return nil, newError("failed to create client").Base(err)
 		}
@@ -132,9 +150,16 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 		clients = append(clients, NewLocalDNSClient())
 	}
 
+	for _, client := range clients {
+		if client.answerOrder == AnswerOrder_DEFAULT_ORDER {
+			client.answerOrder = config.AnswerOrder
+		}
+	}
+
 	return &DNS{
 		tag:                    tag,
 		hosts:                  hosts,
+		rewriter:               rewriter,
 		ipOption:               ipOption,
 		clients:                clients,
 		ctx:                    ctx,
@@ -164,62 +189,134 @@ func (s *DNS) Close() error {
 // IsOwnLink implements proxy.dns.ownLinkVerifier
 func (s *DNS) IsOwnLink(ctx context.Context) bool {
 	inbound := session.InboundFromContext(ctx)
-	return inbound != nil && inbound.Tag == s.tag
+	if inbound == nil {
+		return false
+	}
+	if inbound.Tag == s.tag {
+		return true
+	}
+	for _, client := range s.clients {
+		if len(client.tag) > 0 && inbound.Tag == client.tag {
+			return true
+		}
+	}
+	return false
 }
 
 // LookupIP implements dns.Client.
 func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, error) {
+	domain, option, ips, err := s.lookupStatic(domain, option)
+	if ips != nil || err != nil {
+		return ips, err
+	}
+
+	// Name servers lookup
+	errs := []error{}
+	bogus := false
+	for _, client := range s.sortClients(domain) {
+		if !option.FakeEnable && strings.EqualFold(client.Name(), "FakeDNS") {
+			newError("skip DNS resolution for domain ", domain, " at server ", client.Name()).AtDebug().WriteToLog()
+			continue
+		}
+		// once an answer is bogus, only validating servers may answer instead
+		if bogus && !client.validating {
+			newError("skip DNS resolution for domain ", domain, " at server ", client.Name(), " without DNSSEC validation").AtDebug().WriteToLog()
+			continue
+		}
+		ips, err := client.QueryIP(s.clientContext(client), domain, option, s.disableCache)
+		if len(ips) > 0 {
+			return ips, nil
+		}
+		if err != nil {
+			newError("failed to lookup ip for domain ", domain, " at server ", client.Name()).Base(err).WriteToLog()
+			errs = append(errs, err)
+		}
+		if errors.Cause(err) == errDNSSECBogus {
+			bogus = true
+			continue
+		}
+		// 5 for RcodeRefused in miekg/dns, hardcode to reduce binary size
+		if err != context.Canceled && err != context.DeadlineExceeded && err != errExpectedIPNonMatch && err != dns.ErrEmptyResponse && dns.RCodeFromError(err) != 5 {
+			return nil, err
+		}
+	}
+
+	return nil, newError("returning nil for domain ", domain).Base(errors.Combine(errs...))
+}
+
+// LookupIPWithServer implements dns.ServerLookup.
+func (s *DNS) LookupIPWithServer(tag string, domain string, option dns.IPOption) ([]net.IP, error) {
+	domain, option, ips, err := s.lookupStatic(domain, option)
+	if ips != nil || err != nil {
+		return ips, err
+	}
+
+	for _, client := range s.clients {
+		if client.tag != tag {
+			continue
+		}
+		if !option.FakeEnable && strings.EqualFold(client.Name(), "FakeDNS") {
+			return nil, newError("name server ", tag, " is FakeDNS")
+		}
+		ips, err := client.QueryIP(s.clientContext(client), domain, option, s.disableCache)
+		if err != nil {
+			return nil, newError("failed to lookup ip for domain ", domain, " at server ", client.Name()).Base(err)
+		}
+		return ips, nil
+	}
+
+	return nil, newError("name server not found: ", tag)
+}
+
+// lookupStatic normalizes domain and applies query rewrites and static hosts to it. Name servers
+// need to be queried if both the returned IPs and error are nil.
+func (s *DNS) lookupStatic(domain string, option dns.IPOption) (string, dns.IPOption, []net.IP, error) {
 	if domain == "" {
-		return nil, newError("empty domain name")
+		return domain, option, nil, newError("empty domain name")
 	}
 
 	option.IPv4Enable = option.IPv4Enable && s.ipOption.IPv4Enable
 	option.IPv6Enable = option.IPv6Enable && s.ipOption.IPv6Enable
 
 	if !option.IPv4Enable && !option.IPv6Enable {
-		return nil, dns.ErrEmptyResponse
+		return domain, option, nil, dns.ErrEmptyResponse
 	}
 
 	// Normalize the FQDN form query
 	domain = strings.TrimSuffix(domain, ".")
 
+	// Query rewrite
+	rewritten, err := s.rewrite(domain)
+	if err != nil {
+		return domain, option, nil, err
+	}
+	domain = rewritten
+
 	// Static host lookup
 	switch addrs := s.hosts.Lookup(domain, option); {
 	case addrs == nil: // Domain not recorded in static host
 		break
 	case len(addrs) == 0: // Domain recorded, but no valid IP returned (e.g. IPv4 address with only IPv6 enabled)
-		return nil, dns.ErrEmptyResponse
+		return domain, option, nil, dns.ErrEmptyResponse
 	case len(addrs) == 1 && addrs[0].Family().IsDomain(): // Domain replacement
 		newError("domain replaced: ", domain, " -> ", addrs[0].Domain()).WriteToLog()
 		domain = addrs[0].Domain()
 	default: // Successfully found ip records in static host
 		newError("returning ", len(addrs), " IP(s) for domain ", domain, " -> ", addrs).WriteToLog()
-		return toNetIP(addrs)
+		ips, err := toNetIP(addrs)
+		return domain, option, ips, err
 	}
 
-	// Name servers lookup
-	errs := []error{}
-	ctx := session.ContextWithInbound(s.ctx, &session.Inbound{Tag: s.tag})
-	for _, client := range s.sortClients(domain) {
-		if !option.FakeEnable && strings.EqualFold(client.Name(), "FakeDNS") {
-			newError("skip DNS resolution for domain ", domain, " at server ", client.Name()).AtDebug().WriteToLog()
-			continue
-		}
-		ips, err := client.QueryIP(ctx, domain, option, s.disableCache)
-		if len(ips) > 0 {
-			return ips, nil
-		}
-		if err != nil {
-			newError("failed to lookup ip for domain ", domain, " at server ", client.Name()).Base(err).WriteToLog()
-			errs = append(errs, err)
-		}
-		// 5 for RcodeRefused in miekg/dns, hardcode to reduce binary size
-		if err != context.Canceled && err != context.DeadlineExceeded && err != errExpectedIPNonMatch && err != dns.ErrEmptyResponse && dns.RCodeFromError(err) != 5 {
-			return nil, err
-		}
-	}
+	return domain, option, nil, nil
+}
 
-	return nil, newError("returning nil for domain ", domain).Base(errors.Combine(errs...))
+// clientContext returns the context for queries of client, tagged with the tag of the name server if it has one.
+func (s *DNS) clientContext(client *Client) context.Context {
+	tag := s.tag
+	if len(client.tag) > 0 {
+		tag = client.tag
+	}
+	return session.ContextWithInbound(s.ctx, &session.Inbound{Tag: tag})
 }
 
 // LookupHosts implements dns.HostsLookup.
@@ -228,16 +325,35 @@ func (s *DNS) LookupHosts(domain string) *net.Address {
 	if domain == "" {
 		return nil
 	}
+	rewritten, err := s.rewrite(domain)
+	if err != nil {
+		return nil
+	}
 	// Normalize the FQDN form query
-	addrs := s.hosts.Lookup(domain, *s.ipOption)
+	addrs := s.hosts.Lookup(rewritten, *s.ipOption)
 	if len(addrs) > 0 {
 		newError("domain replaced: ", domain, " -> ", addrs[0].String()).AtInfo().WriteToLog()
 		return &addrs[0]
 	}
+	if rewritten != domain {
+		addr := net.DomainAddress(rewritten)
+		return &addr
+	}
 
 	return nil
 }
 
+func (s *DNS) rewrite(domain string) (string, error) {
+	rewritten, err := s.rewriter.Rewrite(domain)
+	if err != nil {
+		return "", newError("failed to rewrite domain ", domain).Base(err)
+	}
+	if rewritten != domain {
+		log.Record(&log.DNSLog{Server: "rewrite", Domain: domain, Rewritten: rewritten, Status: log.DNSRewritten})
+	}
+	return rewritten, nil
+}
+
 // GetIPOption implements ClientWithIPOption.
 func (s *DNS) GetIPOption() *dns.IPOption {
 	return s.ipOption
@@ -304,7 +420,17 @@ func (s *DNS) sortClients(domain string) []*Client {
 	return clients
 }
 
+// declareTags declares the inbound tags DNS queries are sent with.
+func declareTags(v *core.Validation, _ string, c interface{}) {
+	config := c.(*Config)
+	v.AddInboundTag(config.Tag)
+	for _, ns := range config.NameServer {
+		v.AddInboundTag(ns.Tag)
+	}
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), declareTags))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return New(ctx, config.(*Config))
 	}))
@@ -501,6 +501,126 @@ func TestStaticHostDomain(t *testing.T) {
 	dnsServer.Shutdown()
 }
 
+func TestQueryRewrite(t *testing.T) {
+	port := udp.PickPort()
+
+	dnsServer := dns.Server{
+		Addr:    "127.0.0.1:" + port.String(),
+		Net:     "udp",
+		Handler: &staticHandler{},
+		UDPSize: 1200,
+	}
+
+	go dnsServer.ListenAndServe()
+	time.Sleep(time.Second)
+
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&Config{
+				NameServers: []*net.Endpoint{
+					{
+						Network: net.Network_UDP,
+						Address: &net.IPOrDomain{
+							Address: &net.IPOrDomain_Ip{
+								Ip: []byte{127, 0, 0, 1},
+							},
+						},
+						Port: uint32(port),
+					},
+				},
+				StaticHosts: []*Config_HostMapping{
+					{
+						Type:   DomainMatchingType_Full,
+						Domain: "hosted.xray.com",
+						Ip:     [][]byte{{1, 2, 3, 4}},
+					},
+					{
+						Type:   DomainMatchingType_Full,
+						Domain: "example.com",
+						Ip:     [][]byte{{4, 3, 2, 1}},
+					},
+				},
+				Rewrites: []*Config_QueryRewrite{
+					{
+						Type:        DomainMatchingType_Full,
+						Domain:      "example.com",
+						Replacement: "www.example.com",
+					},
+					{
+						Type:        DomainMatchingType_Subdomain,
+						Domain:      "www.example.com",
+						Replacement: "google.com",
+					},
+					{
+						Type:        DomainMatchingType_Full,
+						Domain:      "hosted.example.com",
+						Replacement: "hosted.xray.com",
+					},
+					{
+						Type:        DomainMatchingType_Full,
+						Domain:      "loop.example.com",
+						Replacement: "loop.example.com",
+					},
+				},
+			}),
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	v, err := core.New(config)
+	common.Must(err)
+
+	client := v.GetFeature(feature_dns.ClientType()).(feature_dns.Client)
+	option := feature_dns.IPOption{
+		IPv4Enable: true,
+		IPv6Enable: true,
+		FakeEnable: false,
+	}
+
+	{ // chained rewrite, static hosts of the original domain are not used
+		ips, err := client.LookupIP("example.com", option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{8, 8, 8, 8}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
+	{ // static hosts of the rewritten domain
+		ips, err := client.LookupIP("hosted.example.com.", option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{1, 2, 3, 4}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
+	{
+		if _, err := client.LookupIP("loop.example.com", option); err == nil {
+			t.Fatal("expect error for rewrite loop")
+		}
+	}
+
+	{
+		hosts := client.(feature_dns.HostsLookup)
+		addr := hosts.LookupHosts("www.example.com")
+		if addr == nil || (*addr).String() != "google.com" {
+			t.Fatal("expect google.com but got ", addr)
+		}
+	}
+
+	dnsServer.Shutdown()
+}
+
 func TestIPMatch(t *testing.T) {
 	port := udp.PickPort()
 
@@ -1053,3 +1173,117 @@ func TestMultiMatchPrioritizedDomain(t *testing.T) {
 		t.Error("DNS query doesn't finish in 2 seconds.")
 	}
 }
+
+type resolverHandler struct{}
+
+func (*resolverHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	ans := new(dns.Msg)
+	ans.Id = r.Id
+	for _, q := range r.Question {
+		if q.Name == "google.com." && q.Qtype == dns.TypeA {
+			rr, _ := dns.NewRR("google.com. IN A 1.1.1.1")
+			ans.Answer = append(ans.Answer, rr)
+		}
+	}
+	w.WriteMsg(ans)
+}
+
+func TestLookupIPWithServer(t *testing.T) {
+	port := udp.PickPort()
+	dnsServer := dns.Server{
+		Addr:    "127.0.0.1:" + port.String(),
+		Net:     "udp",
+		Handler: &staticHandler{},
+		UDPSize: 1200,
+	}
+	go dnsServer.ListenAndServe()
+
+	resolverPort := udp.PickPort()
+	resolverServer := dns.Server{
+		Addr:    "127.0.0.1:" + resolverPort.String(),
+		Net:     "udp",
+		Handler: &resolverHandler{},
+		UDPSize: 1200,
+	}
+	go resolverServer.ListenAndServe()
+	time.Sleep(time.Second)
+
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&Config{
+				NameServer: []*NameServer{
+					{
+						Address: &net.Endpoint{
+							Network: net.Network_UDP,
+							Address: &net.IPOrDomain{
+								Address: &net.IPOrDomain_Ip{
+									Ip: []byte{127, 0, 0, 1},
+								},
+							},
+							Port: uint32(port),
+						},
+					},
+					{
+						Address: &net.Endpoint{
+							Network: net.Network_UDP,
+							Address: &net.IPOrDomain{
+								Address: &net.IPOrDomain_Ip{
+									Ip: []byte{127, 0, 0, 1},
+								},
+							},
+							Port: uint32(resolverPort),
+						},
+						Tag: "resolver",
+					},
+				},
+			}),
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	v, err := core.New(config)
+	common.Must(err)
+
+	client := v.GetFeature(feature_dns.ClientType()).(feature_dns.Client)
+	option := feature_dns.IPOption{
+		IPv4Enable: true,
+		IPv6Enable: true,
+	}
+
+	{
+		ips, err := client.(feature_dns.ServerLookup).LookupIPWithServer("resolver", "google.com", option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{1, 1, 1, 1}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
+	{
+		ips, err := client.LookupIP("google.com", option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{8, 8, 8, 8}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
+	{
+		_, err := client.(feature_dns.ServerLookup).LookupIPWithServer("unknown", "google.com", option)
+		if err == nil {
+			t.Fatal("expected error for unknown name server")
+		}
+	}
+
+	dnsServer.Shutdown()
+	resolverServer.Shutdown()
+}
@@ -3,6 +3,7 @@ package dns
 import (
 	"context"
 	"encoding/binary"
+	"io"
 	"strings"
 	"time"
 
@@ -230,6 +231,28 @@ L:
 	return ipRecord, nil
 }
 
+// writeStreamMessage writes a DNS message prefixed with its length, as on TCP and QUIC streams.
+func writeStreamMessage(w io.Writer, msg []byte) error {
+	b := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(b, uint16(len(msg)))
+	copy(b[2:], msg)
+	_, err := w.Write(b)
+	return err
+}
+
+// readStreamMessage reads a DNS message prefixed with its length.
+func readStreamMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, newError("failed to read response length").Base(err)
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, newError("failed to read response").Base(err)
+	}
+	return msg, nil
+}
+
 // toDnsContext create a new background context with parent inbound, session and dns log
 func toDnsContext(ctx context.Context, addr string) context.Context {
 	dnsCtx := core.ToBackgroundDetachedContext(ctx)
@@ -0,0 +1,587 @@
+package dns
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// defaultTrustAnchors are the DS records of the root key signing keys KSK-2017 and KSK-2024.
+var defaultTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// maxNSEC3Iterations is the number of NSEC3 hash iterations above which denials are taken as
+// insecure, as recommended by RFC 9276.
+const maxNSEC3Iterations = 150
+
+var errDNSSECBogus = errors.New("DNSSEC validation failed")
+
+func bogus(msg ...interface{}) error {
+	return newError(msg...).Base(errDNSSECBogus)
+}
+
+// zoneEntry is the cached result of looking for a zone cut at a name.
+type zoneEntry struct {
+	// cut is set if the name is the apex of a zone.
+	cut bool
+	// keys are the validated DNSKEYs of the zone, or nil if it is insecure.
+	keys   []*dns.DNSKEY
+	expire time.Time
+}
+
+// dnssecValidator validates answers up to its trust anchors. The zone cuts and keys it validates
+// are cached, and shared by the name servers validating with it.
+type dnssecValidator struct {
+	anchors []*dns.DS
+	now     func() time.Time
+
+	access sync.Mutex
+	zones  map[string]*zoneEntry
+}
+
+func newDNSSECValidator(trustAnchors []string) (*dnssecValidator, error) {
+	if len(trustAnchors) == 0 {
+		trustAnchors = defaultTrustAnchors
+	}
+	v := &dnssecValidator{
+		now:   time.Now,
+		zones: make(map[string]*zoneEntry),
+	}
+	for _, anchor := range trustAnchors {
+		rr, err := dns.NewRR(anchor)
+		if err != nil {
+			return nil, newError("invalid trust anchor: ", anchor).Base(err)
+		}
+		ds, ok := rr.(*dns.DS)
+		if !ok || ds.Hdr.Name != "." {
+			return nil, newError("trust anchor is not a DS record of the root zone: ", anchor)
+		}
+		v.anchors = append(v.anchors, ds)
+	}
+	return v, nil
+}
+
+// query sends a query of qtype at name through ex, asking for DNSSEC records without validation
+// by the upstream.
+func (v *dnssecValidator) query(ctx context.Context, ex exchanger, name string, qtype uint16, clientIP net.IP) (*dns.Msg, error) {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	req.CheckingDisabled = true
+	req.SetEdns0(4096, true)
+	if len(clientIP) > 0 {
+		subnet := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET}
+		if ip := clientIP.To4(); ip != nil {
+			subnet.Family = 1
+			subnet.SourceNetmask = 24
+			subnet.Address = ip.Mask(net.CIDRMask(24, net.IPv4len*8))
+		} else {
+			subnet.Family = 2
+			subnet.SourceNetmask = 96
+			subnet.Address = clientIP.Mask(net.CIDRMask(96, net.IPv6len*8))
+		}
+		opt := req.IsEdns0()
+		opt.Option = append(opt.Option, subnet)
+	}
+	b, err := req.Pack()
+	if err != nil {
+		return nil, newError("failed to pack query").Base(err)
+	}
+
+	resp, err := ex.exchange(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	msg := new(dns.Msg)
+	if err := msg.Unpack(resp); err != nil {
+		return nil, newError("failed to parse response").Base(err)
+	}
+	if msg.Truncated {
+		return nil, newError("response to ", name, " ", dns.TypeToString[qtype], " is truncated")
+	}
+	if len(msg.Question) != 1 || !strings.EqualFold(msg.Question[0].Name, name) || msg.Question[0].Qtype != qtype {
+		return nil, newError("response to ", name, " ", dns.TypeToString[qtype], " has another question")
+	}
+	return msg, nil
+}
+
+// validate checks the answer msg to a query of qtype at qname. It returns nil if the answer is
+// secure, or comes from an insecure zone, and an error based on errDNSSECBogus if it is bogus.
+func (v *dnssecValidator) validate(ctx context.Context, ex exchanger, qname string, qtype uint16, msg *dns.Msg) error {
+	if err := v.validateSection(ctx, ex, msg.Answer); err != nil {
+		return err
+	}
+
+	// follow the CNAME chain to the name answering the query
+	name := qname
+	found := false
+	for i := 0; i <= len(msg.Answer) && !found; i++ {
+		next := ""
+		for _, rr := range msg.Answer {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				next = rr.Target
+			default:
+				if rr.Header().Rrtype == qtype {
+					found = true
+				}
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	if found {
+		return nil
+	}
+	if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+		return nil
+	}
+
+	zone, keys, err := v.chain(ctx, ex, name)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return nil
+	}
+	if err := v.validateSection(ctx, ex, msg.Ns); err != nil {
+		return err
+	}
+	if !denies(msg.Ns, zone, name, qtype, msg.Rcode == dns.RcodeNameError) {
+		return bogus("no proof of denial of ", name, " ", dns.TypeToString[qtype])
+	}
+	return nil
+}
+
+// validateSection verifies the signatures of the RRsets in rrs.
+func (v *dnssecValidator) validateSection(ctx context.Context, ex exchanger, rrs []dns.RR) error {
+	rrsets, sigs := splitRRsets(rrs)
+	for _, rrset := range rrsets {
+		h := rrset[0].Header()
+		if h.Rrtype == dns.TypeOPT {
+			continue
+		}
+		covering := sigs[rrsetKey(h.Name, h.Rrtype)]
+		signer := h.Name
+		if len(covering) > 0 {
+			signer = covering[0].SignerName
+			if !dns.IsSubDomain(signer, h.Name) {
+				return bogus(h.Name, " ", dns.TypeToString[h.Rrtype], " is signed by ", signer, " out of its zone")
+			}
+		}
+		zone, keys, err := v.chain(ctx, ex, signer)
+		if err != nil {
+			return err
+		}
+		if keys == nil {
+			continue
+		}
+		if len(covering) == 0 {
+			return bogus(h.Name, " ", dns.TypeToString[h.Rrtype], " is not signed in secure zone ", zone)
+		}
+		if !strings.EqualFold(zone, signer) {
+			return bogus(h.Name, " ", dns.TypeToString[h.Rrtype], " is signed by ", signer, ", which is not a zone")
+		}
+		if err := v.verify(rrset, covering, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify checks that rrset is signed by one of keys with one of sigs.
+func (v *dnssecValidator) verify(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) error {
+	h := rrset[0].Header()
+	now := v.now()
+	for _, sig := range sigs {
+		if int(sig.Labels) > dns.CountLabel(h.Name) || !sig.ValidityPeriod(now) {
+			continue
+		}
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if sig.Verify(key, rrset) == nil {
+				return nil
+			}
+		}
+	}
+	return bogus("no valid signature of ", h.Name, " ", dns.TypeToString[h.Rrtype])
+}
+
+// chain returns the closest zone enclosing name, and its validated keys. Keys are nil if the zone
+// is insecure.
+func (v *dnssecValidator) chain(ctx context.Context, ex exchanger, name string) (string, []*dns.DNSKEY, error) {
+	zone := "."
+	entry, err := v.zoneAt(ctx, ex, ".", nil, ".")
+	if err != nil {
+		return "", nil, err
+	}
+	keys := entry.keys
+
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0 && keys != nil; i-- {
+		child := dns.Fqdn(strings.Join(labels[i:], "."))
+		entry, err := v.zoneAt(ctx, ex, zone, keys, child)
+		if err != nil {
+			return "", nil, err
+		}
+		if entry.cut {
+			zone, keys = child, entry.keys
+		}
+	}
+	return zone, keys, nil
+}
+
+// zoneAt looks for a zone cut at name, below zone of keys.
+func (v *dnssecValidator) zoneAt(ctx context.Context, ex exchanger, zone string, keys []*dns.DNSKEY, name string) (*zoneEntry, error) {
+	name = dns.CanonicalName(name)
+	now := v.now()
+	v.access.Lock()
+	entry, found := v.zones[name]
+	v.access.Unlock()
+	if found && entry.expire.After(now) {
+		return entry, nil
+	}
+
+	var ttl uint32
+	if name == "." {
+		entry, ttl, err := v.zoneKeys(ctx, ex, ".", v.anchors)
+		if err != nil {
+			return nil, err
+		}
+		return v.cache(name, entry, ttl), nil
+	}
+
+	msg, err := v.query(ctx, ex, name, dns.TypeDS, nil)
+	if err != nil {
+		return nil, newError("failed to query DS of ", name).Base(err)
+	}
+	rrsets, sigs := splitRRsets(msg.Answer)
+	for _, rrset := range rrsets {
+		h := rrset[0].Header()
+		if h.Rrtype != dns.TypeDS || !strings.EqualFold(h.Name, name) {
+			continue
+		}
+		if err := v.verify(rrset, sigs[rrsetKey(h.Name, h.Rrtype)], keys); err != nil {
+			return nil, err
+		}
+		var dsSet []*dns.DS
+		for _, rr := range rrset {
+			dsSet = append(dsSet, rr.(*dns.DS))
+		}
+		entry, ttl, err := v.zoneKeys(ctx, ex, name, dsSet)
+		if err != nil {
+			return nil, err
+		}
+		return v.cache(name, entry, min(ttl, h.Ttl)), nil
+	}
+
+	// no DS, the proofs in the authority section tell whether name is an insecure zone cut
+	if err := v.validateSectionIn(msg.Answer, zone, keys); err != nil {
+		return nil, err
+	}
+	if err := v.validateSectionIn(msg.Ns, zone, keys); err != nil {
+		return nil, err
+	}
+	entry = &zoneEntry{}
+	ttl = minTTL(append(msg.Answer, msg.Ns...))
+	switch insecureCut(msg.Ns, name) {
+	case cutInsecure:
+		entry = &zoneEntry{cut: true}
+	case cutNone:
+	default:
+		if len(msg.Answer) == 0 && !denies(msg.Ns, zone, name, dns.TypeDS, msg.Rcode == dns.RcodeNameError) {
+			return nil, bogus("no proof of denial of ", name, " DS")
+		}
+	}
+	return v.cache(name, entry, ttl), nil
+}
+
+// validateSectionIn verifies the signatures of the RRsets in rrs, which must be signed by zone.
+func (v *dnssecValidator) validateSectionIn(rrs []dns.RR, zone string, keys []*dns.DNSKEY) error {
+	rrsets, sigs := splitRRsets(rrs)
+	for _, rrset := range rrsets {
+		h := rrset[0].Header()
+		if h.Rrtype == dns.TypeOPT {
+			continue
+		}
+		var covering []*dns.RRSIG
+		for _, sig := range sigs[rrsetKey(h.Name, h.Rrtype)] {
+			if strings.EqualFold(sig.SignerName, zone) {
+				covering = append(covering, sig)
+			}
+		}
+		if err := v.verify(rrset, covering, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zoneKeys fetches the DNSKEYs of zone, and validates them with dsSet.
+func (v *dnssecValidator) zoneKeys(ctx context.Context, ex exchanger, zone string, dsSet []*dns.DS) (*zoneEntry, uint32, error) {
+	var supported []*dns.DS
+	for _, ds := range dsSet {
+		if supportedAlgorithm(ds.Algorithm) && supportedDigest(ds.DigestType) {
+			supported = append(supported, ds)
+		}
+	}
+	if len(supported) == 0 {
+		newError("zone ", zone, " is signed with unsupported algorithms, treated as insecure").AtInfo().WriteToLog()
+		return &zoneEntry{cut: true}, minTTL(nil), nil
+	}
+
+	msg, err := v.query(ctx, ex, zone, dns.TypeDNSKEY, nil)
+	if err != nil {
+		return nil, 0, newError("failed to query DNSKEY of ", zone).Base(err)
+	}
+	var rrset []dns.RR
+	var keys []*dns.DNSKEY
+	var sigs []*dns.RRSIG
+	for _, rr := range msg.Answer {
+		if !strings.EqualFold(rr.Header().Name, zone) {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.DNSKEY:
+			rrset = append(rrset, rr)
+			keys = append(keys, rr)
+		case *dns.RRSIG:
+			if rr.TypeCovered == dns.TypeDNSKEY {
+				sigs = append(sigs, rr)
+			}
+		}
+	}
+
+	for _, ds := range supported {
+		for _, key := range keys {
+			if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+				continue
+			}
+			if digest := key.ToDS(ds.DigestType); digest == nil || !strings.EqualFold(digest.Digest, ds.Digest) {
+				continue
+			}
+			if v.verify(rrset, sigs, []*dns.DNSKEY{key}) == nil {
+				return &zoneEntry{cut: true, keys: keys}, minTTL(rrset), nil
+			}
+		}
+	}
+	return nil, 0, bogus("no DNSKEY of ", zone, " matches its DS records")
+}
+
+func (v *dnssecValidator) cache(name string, entry *zoneEntry, ttl uint32) *zoneEntry {
+	entry.expire = v.now().Add(time.Duration(ttl) * time.Second)
+	v.access.Lock()
+	defer v.access.Unlock()
+	for n, e := range v.zones {
+		if e.expire.Before(v.now()) {
+			delete(v.zones, n)
+		}
+	}
+	v.zones[name] = entry
+	return entry
+}
+
+func supportedAlgorithm(algorithm uint8) bool {
+	switch algorithm {
+	case dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512, dns.ECDSAP256SHA256, dns.ECDSAP384SHA384, dns.ED25519:
+		return true
+	}
+	return false
+}
+
+func supportedDigest(digestType uint8) bool {
+	switch digestType {
+	case dns.SHA1, dns.SHA256, dns.SHA384:
+		return true
+	}
+	return false
+}
+
+func rrsetKey(name string, rrtype uint16) string {
+	return dns.CanonicalName(name) + "/" + dns.TypeToString[rrtype]
+}
+
+// splitRRsets groups rrs into RRsets, and the RRSIGs covering them by rrsetKey.
+func splitRRsets(rrs []dns.RR) ([][]dns.RR, map[string][]*dns.RRSIG) {
+	var rrsets [][]dns.RR
+	index := make(map[string]int)
+	sigs := make(map[string][]*dns.RRSIG)
+	for _, rr := range rrs {
+		h := rr.Header()
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			key := rrsetKey(h.Name, sig.TypeCovered)
+			sigs[key] = append(sigs[key], sig)
+			continue
+		}
+		key := rrsetKey(h.Name, h.Rrtype)
+		if i, found := index[key]; found {
+			rrsets[i] = append(rrsets[i], rr)
+		} else {
+			index[key] = len(rrsets)
+			rrsets = append(rrsets, []dns.RR{rr})
+		}
+	}
+	return rrsets, sigs
+}
+
+// minTTL returns the lowest TTL of rrs, or 600 if there is none.
+func minTTL(rrs []dns.RR) uint32 {
+	ttl := uint32(600)
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeOPT && rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return ttl
+}
+
+type cutProof int
+
+const (
+	cutUnknown cutProof = iota
+	cutNone
+	cutInsecure
+)
+
+// insecureCut tells from the NSEC or NSEC3 records in rrs whether name is a zone cut without DS.
+func insecureCut(rrs []dns.RR, name string) cutProof {
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.NSEC:
+			if !strings.EqualFold(rr.Hdr.Name, name) {
+				continue
+			}
+			if hasType(rr.TypeBitMap, dns.TypeNS) && !hasType(rr.TypeBitMap, dns.TypeDS) && !hasType(rr.TypeBitMap, dns.TypeSOA) {
+				return cutInsecure
+			}
+			return cutNone
+		case *dns.NSEC3:
+			if rr.Iterations > maxNSEC3Iterations {
+				return cutInsecure
+			}
+			if rr.Match(name) {
+				if hasType(rr.TypeBitMap, dns.TypeNS) && !hasType(rr.TypeBitMap, dns.TypeDS) && !hasType(rr.TypeBitMap, dns.TypeSOA) {
+					return cutInsecure
+				}
+				return cutNone
+			}
+		}
+	}
+	// an opt-out NSEC3 covering the next closer name of name leaves it unsigned
+	for _, rr := range rrs {
+		if nsec3, ok := rr.(*dns.NSEC3); ok && nsec3.Flags&1 == 1 && nsec3.Cover(name) {
+			return cutInsecure
+		}
+	}
+	return cutUnknown
+}
+
+// denies tells whether the NSEC or NSEC3 records in rrs of zone prove that name doesn't exist if
+// nxDomain is set, or that it has no record of qtype otherwise.
+func denies(rrs []dns.RR, zone string, name string, qtype uint16, nxDomain bool) bool {
+	var nsecs []*dns.NSEC
+	var nsec3s []*dns.NSEC3
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, rr)
+		case *dns.NSEC3:
+			if rr.Iterations > maxNSEC3Iterations {
+				return true
+			}
+			nsec3s = append(nsec3s, rr)
+		}
+	}
+
+	for _, nsec := range nsecs {
+		if nxDomain {
+			if nsecCovers(nsec, name) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(nsec.Hdr.Name, name) {
+			return !hasType(nsec.TypeBitMap, qtype) && !hasType(nsec.TypeBitMap, dns.TypeCNAME)
+		}
+		// empty non-terminals are covered by the NSEC of the name before them
+		if nsecCovers(nsec, name) && dns.IsSubDomain(name, nsec.NextDomain) {
+			return true
+		}
+	}
+
+	if !nxDomain {
+		for _, nsec3 := range nsec3s {
+			if nsec3.Match(name) {
+				return !hasType(nsec3.TypeBitMap, qtype) && !hasType(nsec3.TypeBitMap, dns.TypeCNAME)
+			}
+		}
+		if qtype != dns.TypeDS {
+			return false
+		}
+	}
+
+	// closest encloser proof
+	labels := dns.SplitDomainName(name)
+	for i := 1; i <= len(labels); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !dns.IsSubDomain(zone, encloser) {
+			break
+		}
+		for _, nsec3 := range nsec3s {
+			if !nsec3.Match(encloser) {
+				continue
+			}
+			nextCloser := dns.Fqdn(strings.Join(labels[i-1:], "."))
+			for _, cover := range nsec3s {
+				if cover.Cover(nextCloser) && (nxDomain || cover.Flags&1 == 1) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// nsecCovers tells whether name sorts between the owner and the next name of nsec.
+func nsecCovers(nsec *dns.NSEC, name string) bool {
+	if canonicalCompare(nsec.Hdr.Name, name) >= 0 {
+		return false
+	}
+	// the last NSEC of a zone points back to its apex
+	return canonicalCompare(nsec.Hdr.Name, nsec.NextDomain) >= 0 || canonicalCompare(name, nsec.NextDomain) < 0
+}
+
+// canonicalCompare compares two names in the canonical order of RFC 4034, section 6.1.
+func canonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := strings.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+func hasType(bitmap []uint16, rrtype uint16) bool {
+	for _, t := range bitmap {
+		if t == rrtype {
+			return true
+		}
+	}
+	return false
+}
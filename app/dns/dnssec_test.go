@@ -0,0 +1,246 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/strmatcher"
+	dns_feature "github.com/xtls/xray-core/features/dns"
+)
+
+type testZone struct {
+	key    *dns.DNSKEY
+	signer crypto.Signer
+}
+
+func newTestZone(name string) *testZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	common.Must(err)
+	return &testZone{key: key, signer: priv.(crypto.Signer)}
+}
+
+// sign returns rrset followed by its signature with the key of z.
+func (z *testZone) sign(rrset ...dns.RR) []dns.RR {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Algorithm:  z.key.Algorithm,
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.key.Hdr.Name,
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	common.Must(sig.Sign(z.signer, rrset))
+	return append(rrset, sig)
+}
+
+func (z *testZone) ds() *dns.DS {
+	return z.key.ToDS(dns.SHA256)
+}
+
+func newTestRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	common.Must(err)
+	return rr
+}
+
+func nsec(owner string, next string, types ...uint16) *dns.NSEC {
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600},
+		NextDomain: next,
+		TypeBitMap: types,
+	}
+}
+
+// testUpstream answers queries with canned responses.
+type testUpstream struct {
+	t         *testing.T
+	responses map[string]*dns.Msg
+}
+
+func (u *testUpstream) add(name string, qtype uint16, rcode int, answer []dns.RR, ns []dns.RR) {
+	u.responses[rrsetKey(name, qtype)] = &dns.Msg{
+		MsgHdr: dns.MsgHdr{Rcode: rcode},
+		Answer: answer,
+		Ns:     ns,
+	}
+}
+
+func (u *testUpstream) exchange(ctx context.Context, b []byte) ([]byte, error) {
+	req := new(dns.Msg)
+	common.Must(req.Unpack(b))
+	if opt := req.IsEdns0(); opt == nil || !opt.Do() || !req.CheckingDisabled {
+		u.t.Error("expect DO and CD bits in query of ", req.Question[0].Name)
+	}
+	q := req.Question[0]
+	canned, found := u.responses[rrsetKey(q.Name, q.Qtype)]
+	if !found {
+		return nil, errors.New("unexpected query: ", q.Name, " ", dns.TypeToString[q.Qtype])
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Rcode = canned.Rcode
+	resp.Answer = canned.Answer
+	resp.Ns = canned.Ns
+	return resp.Pack()
+}
+
+// newTestUpstream serves a signed root and example. zone, with an unsigned delegation to
+// insecure.example.
+func newTestUpstream(t *testing.T) (*testUpstream, *testZone) {
+	root := newTestZone(".")
+	example := newTestZone("example.")
+	attacker := newTestZone("example.")
+	u := &testUpstream{t: t, responses: make(map[string]*dns.Msg)}
+
+	u.add(".", dns.TypeDNSKEY, dns.RcodeSuccess, root.sign(root.key), nil)
+	u.add("example.", dns.TypeDS, dns.RcodeSuccess, root.sign(example.ds()), nil)
+	u.add("example.", dns.TypeDNSKEY, dns.RcodeSuccess, example.sign(example.key), nil)
+
+	// secure
+	u.add("www.example.", dns.TypeDS, dns.RcodeSuccess, nil,
+		example.sign(nsec("www.example.", "example.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("www.example.", dns.TypeA, dns.RcodeSuccess, example.sign(newTestRR("www.example. 300 IN A 192.0.2.1")), nil)
+
+	// bogus, signed by another key
+	u.add("bogus.example.", dns.TypeDS, dns.RcodeSuccess, nil,
+		example.sign(nsec("bogus.example.", "insecure.example.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("bogus.example.", dns.TypeA, dns.RcodeSuccess, attacker.sign(newTestRR("bogus.example. 300 IN A 192.0.2.66")), nil)
+
+	// bogus, signature stripped
+	u.add("stripped.example.", dns.TypeDS, dns.RcodeSuccess, nil,
+		example.sign(nsec("stripped.example.", "www.example.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("stripped.example.", dns.TypeA, dns.RcodeSuccess, []dns.RR{newTestRR("stripped.example. 300 IN A 192.0.2.66")}, nil)
+
+	// insecure delegation
+	u.add("insecure.example.", dns.TypeDS, dns.RcodeSuccess, nil,
+		example.sign(nsec("insecure.example.", "stripped.example.", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("www.insecure.example.", dns.TypeA, dns.RcodeSuccess, []dns.RR{newTestRR("www.insecure.example. 300 IN A 192.0.2.2")}, nil)
+
+	// secure denial of existence
+	missing := example.sign(nsec("insecure.example.", "stripped.example.", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC))
+	u.add("missing.example.", dns.TypeDS, dns.RcodeNameError, nil, missing)
+	u.add("missing.example.", dns.TypeA, dns.RcodeNameError, nil, missing)
+
+	// denial without proof
+	u.add("unproven.example.", dns.TypeDS, dns.RcodeNameError, nil, nil)
+	u.add("unproven.example.", dns.TypeA, dns.RcodeNameError, nil, nil)
+
+	return u, root
+}
+
+func newTestDNSSECServer(t *testing.T) (*DNSSECNameServer, *testUpstream) {
+	upstream, root := newTestUpstream(t)
+	validator, err := newDNSSECValidator([]string{root.ds().String()})
+	common.Must(err)
+	return newDNSSECNameServer("test", upstream, validator, QueryStrategy_USE_IP), upstream
+}
+
+func TestDNSSECValidation(t *testing.T) {
+	s, _ := newTestDNSSECServer(t)
+	option := dns_feature.IPOption{IPv4Enable: true}
+
+	cases := []struct {
+		domain string
+		ip     net.IP
+		bogus  bool
+	}{
+		{domain: "www.example", ip: net.IPv4(192, 0, 2, 1)},
+		{domain: "www.insecure.example", ip: net.IPv4(192, 0, 2, 2)},
+		{domain: "bogus.example", bogus: true},
+		{domain: "stripped.example", bogus: true},
+		{domain: "unproven.example", bogus: true},
+	}
+	for _, c := range cases {
+		ips, err := s.QueryIP(context.Background(), c.domain, nil, option, false)
+		if c.bogus {
+			if errors.Cause(err) != errDNSSECBogus {
+				t.Error(c.domain, ": expect bogus, but got ", ips, " ", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.domain, ": ", err)
+		} else if len(ips) != 1 || !ips[0].Equal(c.ip) {
+			t.Error(c.domain, ": expect ", c.ip, ", but got ", ips)
+		}
+	}
+
+	_, err := s.QueryIP(context.Background(), "missing.example", nil, option, false)
+	if dns_feature.RCodeFromError(err) != dns.RcodeNameError {
+		t.Error("expect NXDOMAIN for missing.example, but got ", err)
+	}
+}
+
+func TestDNSSECTrustAnchor(t *testing.T) {
+	upstream, _ := newTestUpstream(t)
+	validator, err := newDNSSECValidator(nil)
+	common.Must(err)
+	s := newDNSSECNameServer("test", upstream, validator, QueryStrategy_USE_IP)
+
+	_, err = s.QueryIP(context.Background(), "www.example", nil, dns_feature.IPOption{IPv4Enable: true}, false)
+	if errors.Cause(err) != errDNSSECBogus {
+		t.Error("expect the root key not to match the bundled trust anchors, but got ", err)
+	}
+
+	if _, err := newDNSSECValidator([]string{"example. IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"}); err == nil {
+		t.Error("expect error for a trust anchor out of the root zone")
+	}
+}
+
+// recordingServer answers all queries with the same IP, and remembers if it is queried.
+type recordingServer struct {
+	ip      net.IP
+	queried bool
+}
+
+func (*recordingServer) Name() string {
+	return "recording"
+}
+
+func (s *recordingServer) QueryIP(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption, disableCache bool) ([]net.IP, error) {
+	s.queried = true
+	return []net.IP{s.ip}, nil
+}
+
+func TestDNSSECFallback(t *testing.T) {
+	validating, _ := newTestDNSSECServer(t)
+	plain := &recordingServer{ip: net.IPv4(192, 0, 2, 66)}
+	secondValidating, _ := newTestDNSSECServer(t)
+
+	hosts, err := NewStaticHosts(nil, nil)
+	common.Must(err)
+	rewriter, err := NewQueryRewriter(nil)
+	common.Must(err)
+	s := &DNS{
+		ipOption:      &dns_feature.IPOption{IPv4Enable: true, IPv6Enable: true},
+		hosts:         hosts,
+		rewriter:      rewriter,
+		ctx:           context.Background(),
+		domainMatcher: &strmatcher.MatcherGroup{},
+		clients: []*Client{
+			{server: validating, validating: true},
+			{server: plain},
+			{server: secondValidating, validating: true},
+		},
+	}
+
+	ips, err := s.LookupIP("bogus.example", dns_feature.IPOption{IPv4Enable: true})
+	if err == nil {
+		t.Error("expect bogus answers to fail the lookup, but got ", ips)
+	}
+	if plain.queried {
+		t.Error("expect the name server without validation to be skipped after a bogus answer")
+	}
+}
@@ -0,0 +1,85 @@
+package command
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/dns"
+	"google.golang.org/grpc"
+)
+
+// fakeDNSServer is an implementation of FakeDNSService.
+type fakeDNSServer struct {
+	UnimplementedFakeDNSServiceServer
+	engine dns.FakeDNSEngineRev2
+}
+
+// NewFakeDNSServer creates a FakeDNSService for engine, which may be nil when fake DNS is not
+// enabled.
+func NewFakeDNSServer(engine dns.FakeDNSEngine) FakeDNSServiceServer {
+	s := &fakeDNSServer{}
+	s.engine, _ = engine.(dns.FakeDNSEngineRev2)
+	return s
+}
+
+func (s *fakeDNSServer) ListFakeDNS(ctx context.Context, request *ListFakeDNSRequest) (*ListFakeDNSResponse, error) {
+	if s.engine == nil {
+		return nil, newError("fake DNS is not enabled")
+	}
+	mappings := s.engine.GetFakeDNSMappings()
+	response := &ListFakeDNSResponse{Total: uint32(len(mappings))}
+	if int(request.Offset) >= len(mappings) {
+		return response, nil
+	}
+	mappings = mappings[request.Offset:]
+	if request.Limit > 0 && int(request.Limit) < len(mappings) {
+		mappings = mappings[:request.Limit]
+	}
+	for _, m := range mappings {
+		mapping := &FakeDNSMapping{
+			Domain: m.Domain,
+			Ip:     m.IP.String(),
+		}
+		if !m.LastAccess.IsZero() {
+			mapping.LastAccessTime = m.LastAccess.UnixMilli()
+		}
+		response.Mappings = append(response.Mappings, mapping)
+	}
+	return response, nil
+}
+
+func (s *fakeDNSServer) FlushFakeDNS(ctx context.Context, request *FlushFakeDNSRequest) (*FlushFakeDNSResponse, error) {
+	if s.engine == nil {
+		return nil, newError("fake DNS is not enabled")
+	}
+	var ip net.Address
+	if request.Ip != "" {
+		ip = net.ParseAddress(request.Ip)
+		if !ip.Family().IsIP() {
+			return nil, newError("invalid IP: ", request.Ip)
+		}
+	}
+	return &FlushFakeDNSResponse{
+		Flushed: uint32(s.engine.FlushFakeDNS(request.Domain, ip)),
+	}, nil
+}
+
+type service struct {
+	v *core.Instance
+}
+
+func (s *service) Register(server *grpc.Server) {
+	engine, _ := s.v.GetFeature((*dns.FakeDNSEngine)(nil)).(dns.FakeDNSEngine)
+	RegisterFakeDNSServiceServer(server, NewFakeDNSServer(engine))
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
+		s := core.MustFromContext(ctx)
+		return &service{v: s}, nil
+	}))
+}
@@ -0,0 +1,53 @@
+package command_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/app/dns/fakedns"
+	. "github.com/xtls/xray-core/app/dns/fakedns/command"
+	"github.com/xtls/xray-core/common"
+)
+
+func TestFakeDNSService(t *testing.T) {
+	if _, err := NewFakeDNSServer(nil).ListFakeDNS(context.Background(), &ListFakeDNSRequest{}); err == nil {
+		t.Error("expect an error without fake DNS")
+	}
+
+	engine, err := fakedns.NewFakeDNSHolder()
+	common.Must(err)
+	defer engine.Close()
+	ips := make(map[string]string)
+	for _, domain := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		ips[domain] = engine.GetFakeIPForDomain(domain)[0].String()
+	}
+	s := NewFakeDNSServer(engine)
+
+	resp, err := s.ListFakeDNS(context.Background(), &ListFakeDNSRequest{Offset: 1, Limit: 1})
+	common.Must(err)
+	if resp.Total != 3 || len(resp.Mappings) != 1 {
+		t.Fatal("unexpected page ", resp)
+	}
+	if m := resp.Mappings[0]; m.Domain != "b.example.com" || m.Ip != ips["b.example.com"] || m.LastAccessTime == 0 {
+		t.Error("unexpected mapping ", m)
+	}
+	resp, err = s.ListFakeDNS(context.Background(), &ListFakeDNSRequest{Offset: 3})
+	common.Must(err)
+	if len(resp.Mappings) != 0 {
+		t.Error("expect no mapping past the end, but got ", resp.Mappings)
+	}
+
+	if _, err := s.FlushFakeDNS(context.Background(), &FlushFakeDNSRequest{Ip: "example.com"}); err == nil {
+		t.Error("expect an error for a domain as IP")
+	}
+	flush, err := s.FlushFakeDNS(context.Background(), &FlushFakeDNSRequest{Ip: ips["a.example.com"]})
+	common.Must(err)
+	if flush.Flushed != 1 || engine.GetDomainFromFakeDNS(engine.GetFakeIPForDomain("c.example.com")[0]) != "c.example.com" {
+		t.Error("expect only a.example.com flushed")
+	}
+	flush, err = s.FlushFakeDNS(context.Background(), &FlushFakeDNSRequest{})
+	common.Must(err)
+	if flush.Flushed != 2 {
+		t.Error("expect the 2 mappings left flushed, but got ", flush.Flushed)
+	}
+}
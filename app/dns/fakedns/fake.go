@@ -11,13 +11,20 @@ import (
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/cache"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
+	"github.com/xtls/xray-core/features/stats"
 )
 
 type Holder struct {
 	domainToIP cache.Lru
 	ipRange    *gonet.IPNet
 	mu         *sync.Mutex
+	journal    *journal
+	metrics    *poolMetrics
+	stats      stats.Manager
+	// accessed is when the mappings were last looked up, by domain.
+	accessed sync.Map
 
 	config *FakeDnsPool
 }
@@ -37,6 +44,20 @@ func (fkdns *Holder) GetFakeIPForDomain3(domain string, ipv4, ipv6 bool) []net.A
 	return []net.Address{}
 }
 
+// defaultTTL is the TTL of fake IPs unless configured, short for the clients to ask again before
+// the IPs are reused.
+const defaultTTL = 1
+
+func (fkdns *Holder) GetFakeIPTTL(ip net.Address) (uint32, bool) {
+	if !fkdns.IsIPInIPPool(ip) {
+		return 0, false
+	}
+	if fkdns.config == nil || fkdns.config.Ttl == 0 {
+		return defaultTTL, true
+	}
+	return fkdns.config.Ttl, true
+}
+
 func (*Holder) Type() interface{} {
 	return (*dns.FakeDNSEngine)(nil)
 }
@@ -49,6 +70,11 @@ func (fkdns *Holder) Start() error {
 }
 
 func (fkdns *Holder) Close() error {
+	if err := fkdns.journal.close(); err != nil {
+		newError("failed to write fake DNS journal ", fkdns.config.PersistPath).Base(err).AtWarning().WriteToLog()
+	}
+	fkdns.journal = nil
+	fkdns.metrics = nil
 	fkdns.domainToIP = nil
 	fkdns.ipRange = nil
 	fkdns.mu = nil
@@ -70,11 +96,60 @@ func NewFakeDNSHolder() (*Holder, error) {
 }
 
 func NewFakeDNSHolderConfigOnly(conf *FakeDnsPool) (*Holder, error) {
-	return &Holder{nil, nil, nil, conf}, nil
+	return &Holder{config: conf}, nil
 }
 
 func (fkdns *Holder) initializeFromConfig() error {
-	return fkdns.initialize(fkdns.config.IpPool, int(fkdns.config.LruSize))
+	if err := fkdns.initialize(fkdns.config.IpPool, int(fkdns.config.LruSize)); err != nil {
+		return err
+	}
+	if fkdns.config.PersistPath == "" {
+		return nil
+	}
+
+	records, err := readJournal(fkdns.config.PersistPath)
+	if err != nil {
+		newError("failed to read fake DNS journal ", fkdns.config.PersistPath).Base(err).AtWarning().WriteToLog()
+	}
+	fkdns.restore(records)
+	fkdns.journal, err = openJournal(fkdns.config.PersistPath, 2*int(fkdns.config.LruSize), fkdns.snapshot)
+	if err != nil {
+		return newError("failed to open fake DNS journal ", fkdns.config.PersistPath).Base(err).AtError()
+	}
+	return nil
+}
+
+// restore puts the mappings of records, from the oldest one, in the LRU. The latest record of a
+// domain or an IP wins, and the ones out of the pool are dropped.
+func (fkdns *Holder) restore(records []journalRecord) {
+	lruSize := int(fkdns.config.LruSize)
+	domains := make(map[string]bool)
+	ips := make(map[net.Address]bool)
+	var kept []journalRecord
+	for i := len(records) - 1; i >= 0 && len(kept) < lruSize; i-- {
+		r := records[i]
+		if domains[r.domain] || ips[r.ip] || !fkdns.ipRange.Contains(r.ip.IP()) {
+			continue
+		}
+		domains[r.domain], ips[r.ip] = true, true
+		kept = append(kept, r)
+	}
+	for i := len(kept) - 1; i >= 0; i-- {
+		fkdns.domainToIP.Put(kept[i].domain, kept[i].ip)
+	}
+	fkdns.metrics.restored(len(kept))
+	if len(kept) > 0 {
+		newError("restored ", len(kept), " fake DNS mappings from ", fkdns.config.PersistPath).AtInfo().WriteToLog()
+	}
+}
+
+func (fkdns *Holder) snapshot() []journalRecord {
+	var records []journalRecord
+	fkdns.domainToIP.Range(func(key, value interface{}) bool {
+		records = append(records, journalRecord{domain: key.(string), ip: value.(net.Address)})
+		return true
+	})
+	return records
 }
 
 func (fkdns *Holder) initialize(ipPoolCidr string, lruSize int) error {
@@ -90,7 +165,12 @@ func (fkdns *Holder) initialize(ipPoolCidr string, lruSize int) error {
 	if math.Log2(float64(lruSize)) >= float64(rooms) {
 		return newError("LRU size is bigger than subnet size").AtError()
 	}
-	fkdns.domainToIP = cache.NewLru(lruSize)
+	fkdns.metrics = newPoolMetrics(ipPoolCidr, lruSize)
+	fkdns.metrics.register(fkdns.stats)
+	fkdns.domainToIP = cache.NewLruWithEviction(lruSize, func(domain, ip interface{}) {
+		fkdns.accessed.Delete(domain)
+		fkdns.metrics.evicted(ip.(net.Address))
+	})
 	fkdns.ipRange = ipRange
 	fkdns.mu = new(sync.Mutex)
 	return nil
@@ -101,6 +181,7 @@ func (fkdns *Holder) GetFakeIPForDomain(domain string) []net.Address {
 	fkdns.mu.Lock()
 	defer fkdns.mu.Unlock()
 	if v, ok := fkdns.domainToIP.Get(domain); ok {
+		fkdns.used(domain, v.(net.Address))
 		return []net.Address{v.(net.Address)}
 	}
 	currentTimeMillis := uint64(time.Now().UnixNano() / 1e6)
@@ -126,6 +207,8 @@ func (fkdns *Holder) GetFakeIPForDomain(domain string) []net.Address {
 		}
 	}
 	fkdns.domainToIP.Put(domain, ip)
+	fkdns.metrics.allocated()
+	fkdns.used(domain, ip)
 	return []net.Address{ip}
 }
 
@@ -135,12 +218,76 @@ func (fkdns *Holder) GetDomainFromFakeDNS(ip net.Address) string {
 		return ""
 	}
 	if k, ok := fkdns.domainToIP.GetKeyFromValue(ip); ok {
+		fkdns.used(k.(string), ip)
+		fkdns.metrics.seen(ip)
 		return k.(string)
 	}
+	fkdns.metrics.missed()
 	newError("A fake ip request to ", ip, ", however there is no matching domain name in fake DNS").AtInfo().WriteToLog()
 	return ""
 }
 
+// used records that the mapping of domain to ip was just looked up.
+func (fkdns *Holder) used(domain string, ip net.Address) {
+	fkdns.accessed.Store(domain, time.Now())
+	fkdns.journal.touch(domain, ip)
+}
+
+func (fkdns *Holder) GetFakeDNSMappings() []dns.FakeDNSMapping {
+	var mappings []dns.FakeDNSMapping
+	fkdns.domainToIP.Range(func(key, value interface{}) bool {
+		m := dns.FakeDNSMapping{Domain: key.(string), IP: value.(net.Address)}
+		if t, ok := fkdns.accessed.Load(key); ok {
+			m.LastAccess = t.(time.Time)
+		}
+		mappings = append(mappings, m)
+		return true
+	})
+	for i, k := 0, len(mappings)-1; i < k; i, k = i+1, k-1 {
+		mappings[i], mappings[k] = mappings[k], mappings[i]
+	}
+	return mappings
+}
+
+// FlushFakeDNS removes mappings while lookups go on. Allocations wait for it, so that no IP
+// removed is taken meanwhile for a domain removed.
+func (fkdns *Holder) FlushFakeDNS(domain string, ip net.Address) int {
+	fkdns.mu.Lock()
+	defer fkdns.mu.Unlock()
+
+	var domains []interface{}
+	if domain == "" && ip == nil {
+		fkdns.domainToIP.Range(func(key, _ interface{}) bool {
+			domains = append(domains, key)
+			return true
+		})
+	}
+	if domain != "" {
+		domains = append(domains, domain)
+	}
+	if ip != nil && ip.Family().IsIP() {
+		if k, ok := fkdns.domainToIP.PeekKeyFromValue(ip); ok && k != domain {
+			domains = append(domains, k)
+		}
+	}
+
+	var flushed []string
+	for _, d := range domains {
+		if v, ok := fkdns.domainToIP.Remove(d); ok {
+			fkdns.accessed.Delete(d)
+			fkdns.metrics.flushed(v.(net.Address))
+			flushed = append(flushed, d.(string))
+		}
+	}
+	if len(flushed) > 0 {
+		if err := fkdns.journal.forget(flushed); err != nil {
+			newError("failed to write fake DNS journal ", fkdns.config.PersistPath).Base(err).AtWarning().WriteToLog()
+		}
+		newError("flushed ", len(flushed), " fake DNS mappings").AtInfo().WriteToLog()
+	}
+	return len(flushed)
+}
+
 type HolderMulti struct {
 	holders []*Holder
 
@@ -184,6 +331,33 @@ func (h *HolderMulti) GetDomainFromFakeDNS(ip net.Address) string {
 	return ""
 }
 
+func (h *HolderMulti) GetFakeIPTTL(ip net.Address) (uint32, bool) {
+	for _, v := range h.holders {
+		if ttl, ok := v.GetFakeIPTTL(ip); ok {
+			return ttl, true
+		}
+	}
+	return 0, false
+}
+
+// GetFakeDNSMappings returns the mappings of the pools in turn, each from the most recently used
+// one.
+func (h *HolderMulti) GetFakeDNSMappings() []dns.FakeDNSMapping {
+	var mappings []dns.FakeDNSMapping
+	for _, v := range h.holders {
+		mappings = append(mappings, v.GetFakeDNSMappings()...)
+	}
+	return mappings
+}
+
+func (h *HolderMulti) FlushFakeDNS(domain string, ip net.Address) int {
+	var flushed int
+	for _, v := range h.holders {
+		flushed += v.FlushFakeDNS(domain, ip)
+	}
+	return flushed
+}
+
 func (h *HolderMulti) Type() interface{} {
 	return (*dns.FakeDNSEngine)(nil)
 }
@@ -236,6 +410,13 @@ func init() {
 		if f, err = NewFakeDNSHolderConfigOnly(config.(*FakeDnsPool)); err != nil {
 			return nil, err
 		}
+		if core.FromContext(ctx) != nil {
+			if err := core.RequireFeatures(ctx, func(sm stats.Manager) {
+				f.stats = sm
+			}); err != nil {
+				return nil, err
+			}
+		}
 		return f, nil
 	}))
 
@@ -245,6 +426,15 @@ func init() {
 		if f, err = NewFakeDNSHolderMulti(config.(*FakeDnsPoolMulti)); err != nil {
 			return nil, err
 		}
+		if core.FromContext(ctx) != nil {
+			if err := core.RequireFeatures(ctx, func(sm stats.Manager) {
+				for _, holder := range f.holders {
+					holder.stats = sm
+				}
+			}); err != nil {
+				return nil, err
+			}
+		}
 		return f, nil
 	}))
 }
@@ -1,11 +1,17 @@
 package fakedns
 
 import (
+	"context"
 	gonet "net"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/uuid"
@@ -204,3 +210,221 @@ func TestFakeDNSMulti(t *testing.T) {
 		})
 	})
 }
+
+func TestFakeDnsHolderPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fakedns.journal")
+	start := func() *Holder {
+		fkdns, err := NewFakeDNSHolderConfigOnly(&FakeDnsPool{
+			IpPool:      dns.FakeIPv4Pool,
+			LruSize:     16,
+			PersistPath: path,
+		})
+		common.Must(err)
+		common.Must(fkdns.Start())
+		return fkdns
+	}
+
+	fkdns := start()
+	domains := []string{"a.example.com", "b.example.com", "c.example.com"}
+	ips := make(map[string]net.Address)
+	for _, domain := range domains {
+		ips[domain] = fkdns.GetFakeIPForDomain(domain)[0]
+	}
+	common.Must(fkdns.Close())
+
+	// a restart keeps the IPs of the domains
+	fkdns = start()
+	for _, domain := range domains {
+		assert.Equal(t, domain, fkdns.GetDomainFromFakeDNS(ips[domain]))
+		assert.Equal(t, ips[domain], fkdns.GetFakeIPForDomain(domain)[0])
+	}
+	common.Must(fkdns.Close())
+
+	// corrupted records are skipped
+	journal, err := os.ReadFile(path)
+	common.Must(err)
+	lines := strings.SplitAfter(string(journal), "\n")
+	tampered := strings.Replace(lines[0], "a.example.com", "x.example.com", 1)
+	corrupted := "garbage\n" + tampered + string(journal) + "0000 198.18.0.1 d.example.com\n" + lines[1][:10]
+	common.Must(os.WriteFile(path, []byte(corrupted), 0o600))
+	fkdns = start()
+	for _, domain := range domains {
+		assert.Equal(t, domain, fkdns.GetDomainFromFakeDNS(ips[domain]))
+	}
+	_, found := fkdns.domainToIP.Get("x.example.com")
+	assert.False(t, found)
+
+	// the journal is capped by the pool size, keeping the mappings used last
+	var last net.Address
+	for i := 0; i < 200; i++ {
+		last = fkdns.GetFakeIPForDomain(strconv.Itoa(i) + ".example.com")[0]
+	}
+	common.Must(fkdns.Close())
+	journal, err = os.ReadFile(path)
+	common.Must(err)
+	if records := strings.Count(string(journal), "\n"); records > 32 {
+		t.Error("expect at most 32 records, but got ", records)
+	}
+	fkdns = start()
+	assert.Equal(t, "199.example.com", fkdns.GetDomainFromFakeDNS(last))
+	_, found = fkdns.domainToIP.Get("a.example.com")
+	assert.False(t, found)
+	common.Must(fkdns.Close())
+}
+
+func TestFakeDNSMultiFamilyAndTTL(t *testing.T) {
+	// the IPv6 pool comes first
+	fakeMulti, err := NewFakeDNSHolderMulti(&FakeDnsPoolMulti{
+		Pools: []*FakeDnsPool{{
+			IpPool:  "fddd:c5b4:ff5f:f4f0::/64",
+			LruSize: 256,
+			Ttl:     60,
+		}, {
+			IpPool:  "240.0.0.0/12",
+			LruSize: 256,
+		}},
+	})
+	common.Must(err)
+	common.Must(fakeMulti.Start())
+
+	ipv4 := fakeMulti.GetFakeIPForDomain3("fakednstest.example.com", true, false)
+	assert.Len(t, ipv4, 1)
+	assert.True(t, ipv4[0].Family().IsIPv4())
+	ipv6 := fakeMulti.GetFakeIPForDomain3("fakednstest.example.com", false, true)
+	assert.Len(t, ipv6, 1)
+	assert.True(t, ipv6[0].Family().IsIPv6())
+
+	ttl, ok := fakeMulti.GetFakeIPTTL(ipv4[0])
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), ttl)
+	ttl, ok = fakeMulti.GetFakeIPTTL(ipv6[0])
+	assert.True(t, ok)
+	assert.Equal(t, uint32(60), ttl)
+	_, ok = fakeMulti.GetFakeIPTTL(net.IPAddress([]byte{241, 0, 0, 5}))
+	assert.False(t, ok)
+
+	var _ dns.FakeDNSEngineRev1 = fakeMulti
+	var _ dns.FakeDNSEngineRev1 = (*Holder)(nil)
+}
+
+func TestFakeDnsHolderMetrics(t *testing.T) {
+	sm, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+	fkdns, err := NewFakeDNSHolderConfigOnly(&FakeDnsPool{
+		IpPool:  "240.0.0.0/12",
+		LruSize: 4,
+	})
+	common.Must(err)
+	fkdns.stats = sm
+	common.Must(fkdns.Start())
+	now := time.Unix(1700000000, 0)
+	fkdns.metrics.now = func() time.Time { return now }
+
+	value := func(name string) int64 {
+		if c := sm.GetCounter("fakedns>>>240.0.0.0/12>>>" + name); c != nil {
+			return c.Value()
+		}
+		return sm.GetGauge("fakedns>>>240.0.0.0/12>>>" + name).Value()
+	}
+	allocate := func(prefix string) []net.Address {
+		var ips []net.Address
+		for i := 0; i < 4; i++ {
+			ips = append(ips, fkdns.GetFakeIPForDomain(prefix + strconv.Itoa(i) + ".example.com")[0])
+		}
+		return ips
+	}
+
+	old := allocate("old")
+	assert.Equal(t, int64(100), value("utilization"))
+	assert.Equal(t, int64(4), value("allocations"))
+
+	// the IP of a mapping sent traffic to lately is evicted hot
+	assert.Equal(t, "old0.example.com", fkdns.GetDomainFromFakeDNS(old[0]))
+	now = now.Add(time.Minute)
+	recent := allocate("recent")
+	assert.Equal(t, int64(8), value("allocations"))
+	assert.Equal(t, int64(4), value("evictions"))
+	assert.Equal(t, int64(1), value("evictions>>>hot"))
+	assert.Equal(t, int64(100), value("utilization"))
+	// an IP evicted and not taken again since
+	for _, ip := range old {
+		if _, ok := fkdns.domainToIP.PeekKeyFromValue(ip); !ok {
+			assert.Equal(t, "", fkdns.GetDomainFromFakeDNS(ip))
+			break
+		}
+	}
+	assert.Equal(t, int64(1), value("lookups>>>missed"))
+
+	// but not long after
+	assert.Equal(t, "recent0.example.com", fkdns.GetDomainFromFakeDNS(recent[0]))
+	now = now.Add(hotEvictionWindow + time.Minute)
+	allocate("new")
+	assert.Equal(t, int64(8), value("evictions"))
+	assert.Equal(t, int64(1), value("evictions>>>hot"))
+
+	// IPs out of the pool are not missed lookups
+	assert.Equal(t, "", fkdns.GetDomainFromFakeDNS(net.IPAddress([]byte{241, 0, 0, 5})))
+	assert.Equal(t, int64(1), value("lookups>>>missed"))
+}
+
+func TestFakeDnsHolderFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fakedns.journal")
+	fkdns, err := NewFakeDNSHolderConfigOnly(&FakeDnsPool{
+		IpPool:      dns.FakeIPv4Pool,
+		LruSize:     16,
+		PersistPath: path,
+	})
+	common.Must(err)
+	common.Must(fkdns.Start())
+
+	domains := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"}
+	ips := make(map[string]net.Address)
+	for _, domain := range domains {
+		ips[domain] = fkdns.GetFakeIPForDomain(domain)[0]
+	}
+	assert.Equal(t, "a.example.com", fkdns.GetDomainFromFakeDNS(ips["a.example.com"]))
+
+	mappings := fkdns.GetFakeDNSMappings()
+	assert.Len(t, mappings, 4)
+	assert.Equal(t, "a.example.com", mappings[0].Domain)
+	assert.Equal(t, ips["a.example.com"], mappings[0].IP)
+	assert.False(t, mappings[0].LastAccess.IsZero())
+	assert.Equal(t, "d.example.com", mappings[1].Domain)
+
+	assert.Equal(t, 1, fkdns.FlushFakeDNS("a.example.com", nil))
+	assert.Equal(t, 1, fkdns.FlushFakeDNS("", ips["b.example.com"]))
+	assert.Equal(t, 0, fkdns.FlushFakeDNS("a.example.com", nil))
+	assert.Equal(t, "", fkdns.GetDomainFromFakeDNS(ips["a.example.com"]))
+	assert.Equal(t, "", fkdns.GetDomainFromFakeDNS(ips["b.example.com"]))
+	assert.Len(t, fkdns.GetFakeDNSMappings(), 2)
+
+	// the mappings flushed are not restored
+	common.Must(fkdns.Close())
+	common.Must(fkdns.Start())
+	assert.Equal(t, "c.example.com", fkdns.GetDomainFromFakeDNS(ips["c.example.com"]))
+	assert.Equal(t, "", fkdns.GetDomainFromFakeDNS(ips["a.example.com"]))
+
+	// flushing all of them while lookups go on
+	var errg errgroup.Group
+	for i := 0; i < 4; i++ {
+		i := i
+		errg.Go(func() error {
+			for k := 0; k < 100; k++ {
+				ip := fkdns.GetFakeIPForDomain(strconv.Itoa(i) + "." + strconv.Itoa(k) + ".example.com")[0]
+				fkdns.GetDomainFromFakeDNS(ip)
+			}
+			return nil
+		})
+	}
+	errg.Go(func() error {
+		for k := 0; k < 20; k++ {
+			fkdns.FlushFakeDNS("", nil)
+		}
+		return nil
+	})
+	common.Must(errg.Wait())
+	fkdns.FlushFakeDNS("", nil)
+	assert.Len(t, fkdns.GetFakeDNSMappings(), 0)
+	assert.Equal(t, int64(0), int64(fkdns.metrics.mappings))
+	common.Must(fkdns.Close())
+}
@@ -0,0 +1,257 @@
+package fakedns
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/task"
+)
+
+// journalFlushInterval is how often the mappings used in the meantime are appended to the journal.
+const journalFlushInterval = time.Second
+
+type journalRecord struct {
+	domain string
+	ip     net.Address
+}
+
+// journal keeps the mappings of a Holder in a file across restarts. The mappings assigned or looked
+// up are appended in batches, the most recently used last, so that lookups don't wait for the file.
+// Once the file has more records than its limit, it is rewritten with the mappings held, which caps
+// its size.
+type journal struct {
+	path  string
+	limit int
+	// snapshot returns the mappings held, from the least recently used one.
+	snapshot func() []journalRecord
+
+	access  sync.Mutex
+	pending map[string]pendingRecord
+	seq     uint64
+
+	fileAccess sync.Mutex
+	file       *os.File
+	records    int
+
+	flusher *task.Periodic
+}
+
+type pendingRecord struct {
+	ip  net.Address
+	seq uint64
+}
+
+// encode returns the line of the record, with a checksum for corrupted lines to be told apart.
+func (r journalRecord) encode() string {
+	s := r.ip.String() + " " + r.domain
+	return fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE([]byte(s)), s)
+}
+
+func decodeJournalRecord(line string) (journalRecord, bool) {
+	sum, s, ok := strings.Cut(line, " ")
+	if !ok || len(sum) != 8 {
+		return journalRecord{}, false
+	}
+	if v, err := strconv.ParseUint(sum, 16, 32); err != nil || uint32(v) != crc32.ChecksumIEEE([]byte(s)) {
+		return journalRecord{}, false
+	}
+	ip, domain, ok := strings.Cut(s, " ")
+	if !ok || domain == "" {
+		return journalRecord{}, false
+	}
+	address := net.ParseAddress(ip)
+	if !address.Family().IsIP() {
+		return journalRecord{}, false
+	}
+	return journalRecord{domain: domain, ip: address}, true
+}
+
+// readJournal returns the records of the journal at path, from the oldest one. Corrupted records
+// are skipped, and a missing journal has none.
+func readJournal(path string) ([]journalRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []journalRecord
+	var corrupted int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if r, ok := decodeJournalRecord(scanner.Text()); ok {
+			records = append(records, r)
+		} else {
+			corrupted++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// such as a line too long to be a record, past which nothing is trusted
+		corrupted++
+	}
+	if corrupted > 0 {
+		newError("ignored ", corrupted, " corrupted records of fake DNS journal ", path).AtWarning().WriteToLog()
+	}
+	return records, nil
+}
+
+// openJournal rewrites the journal at path with the mappings of snapshot, and appends to it from
+// then on.
+func openJournal(path string, limit int, snapshot func() []journalRecord) (*journal, error) {
+	j := &journal{
+		path:     path,
+		limit:    limit,
+		snapshot: snapshot,
+		pending:  make(map[string]pendingRecord),
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	j.flusher = &task.Periodic{
+		Interval: journalFlushInterval,
+		Execute: func() error {
+			if err := j.flush(); err != nil {
+				newError("failed to write fake DNS journal ", j.path).Base(err).AtWarning().WriteToLog()
+			}
+			return nil
+		},
+	}
+	if err := j.flusher.Start(); err != nil {
+		j.file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// touch records that domain is mapped to ip, and was just used.
+func (j *journal) touch(domain string, ip net.Address) {
+	if j == nil || strings.ContainsAny(domain, "\r\n") {
+		return
+	}
+	j.access.Lock()
+	defer j.access.Unlock()
+
+	j.seq++
+	j.pending[domain] = pendingRecord{ip: ip, seq: j.seq}
+}
+
+// forget drops the records of domains, whose mappings were removed, by rewriting the journal with
+// the mappings held.
+func (j *journal) forget(domains []string) error {
+	if j == nil {
+		return nil
+	}
+	j.access.Lock()
+	for _, domain := range domains {
+		delete(j.pending, domain)
+	}
+	j.access.Unlock()
+
+	j.fileAccess.Lock()
+	defer j.fileAccess.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	return j.compactLocked()
+}
+
+func (j *journal) flush() error {
+	j.access.Lock()
+	pending := j.pending
+	j.pending = make(map[string]pendingRecord)
+	j.access.Unlock()
+
+	j.fileAccess.Lock()
+	defer j.fileAccess.Unlock()
+
+	if len(pending) == 0 || j.file == nil {
+		return nil
+	}
+	if j.records+len(pending) > j.limit {
+		return j.compactLocked()
+	}
+
+	records := make([]journalRecord, 0, len(pending))
+	for domain, p := range pending {
+		records = append(records, journalRecord{domain: domain, ip: p.ip})
+	}
+	sort.Slice(records, func(i, k int) bool {
+		return pending[records[i].domain].seq < pending[records[k].domain].seq
+	})
+
+	writer := bufio.NewWriter(j.file)
+	for _, r := range records {
+		writer.WriteString(r.encode())
+	}
+	j.records += len(records)
+	return writer.Flush()
+}
+
+func (j *journal) compact() error {
+	j.fileAccess.Lock()
+	defer j.fileAccess.Unlock()
+
+	return j.compactLocked()
+}
+
+// compactLocked replaces the journal with the mappings held, through a temporary file so that a
+// crash midway leaves the former one.
+func (j *journal) compactLocked() error {
+	records := j.snapshot()
+	tmp := j.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, r := range records {
+		writer.WriteString(r.encode())
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		j.file.Close()
+	}
+	if j.file, err = os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return err
+	}
+	j.records = len(records)
+	return nil
+}
+
+// close writes the mappings pending, and closes the file.
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+	j.flusher.Close()
+	err := j.flush()
+
+	j.fileAccess.Lock()
+	defer j.fileAccess.Unlock()
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+	return err
+}
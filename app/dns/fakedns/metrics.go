@@ -0,0 +1,169 @@
+package fakedns
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+const (
+	// hotEvictionWindow is how recently the IP of an evicted mapping must have been seen in traffic
+	// for the eviction to be hot, as clients likely still connect to it.
+	hotEvictionWindow = 10 * time.Minute
+	// hotEvictionWarningInterval is the least time between two warnings about the hot evictions of
+	// a pool.
+	hotEvictionWarningInterval = time.Minute
+)
+
+// poolMetrics follows the use of the mappings of a pool. Once a stats manager is set, they are
+// published under fakedns>>>[pool]>>>: the utilization gauge, in percent of the LRU size, and the
+// allocations, evictions, evictions>>>hot and lookups>>>missed counters.
+type poolMetrics struct {
+	pool string
+	size int
+	now  func() time.Time
+
+	access   sync.Mutex
+	mappings int
+	// lastSeen is when the IPs of the mappings were last looked up for traffic.
+	lastSeen map[net.Address]time.Time
+	// hotEvictions is the number of hot evictions since the last warning.
+	hotEvictions int
+	lastWarning  time.Time
+
+	utilization   stats.Counter
+	allocations   stats.Counter
+	evictions     stats.Counter
+	hotEvicted    stats.Counter
+	missedLookups stats.Counter
+}
+
+func newPoolMetrics(pool string, size int) *poolMetrics {
+	return &poolMetrics{
+		pool:     pool,
+		size:     size,
+		now:      time.Now,
+		lastSeen: make(map[net.Address]time.Time),
+	}
+}
+
+// register publishes the metrics through sm, unless it keeps no stats.
+func (m *poolMetrics) register(sm stats.Manager) {
+	if sm == nil {
+		return
+	}
+	if _, ok := sm.(stats.NoopManager); ok {
+		return
+	}
+	prefix := "fakedns>>>" + m.pool
+	counter := func(name string) stats.Counter {
+		c, err := stats.GetOrRegisterCounter(sm, prefix+name)
+		if err != nil {
+			newError("failed to register ", prefix, name).Base(err).AtWarning().WriteToLog()
+		}
+		return c
+	}
+
+	m.access.Lock()
+	defer m.access.Unlock()
+	if gauge, err := stats.GetOrRegisterGauge(sm, prefix+">>>utilization"); err == nil {
+		m.utilization = gauge
+		m.utilization.Set(m.percentLocked())
+	} else {
+		newError("failed to register ", prefix, ">>>utilization").Base(err).AtWarning().WriteToLog()
+	}
+	m.allocations = counter(">>>allocations")
+	m.evictions = counter(">>>evictions")
+	m.hotEvicted = counter(">>>evictions>>>hot")
+	m.missedLookups = counter(">>>lookups>>>missed")
+}
+
+func (m *poolMetrics) percentLocked() int64 {
+	return int64(m.mappings * 100 / m.size)
+}
+
+func (m *poolMetrics) updateUtilizationLocked() {
+	if m.utilization != nil {
+		m.utilization.Set(m.percentLocked())
+	}
+}
+
+// restored counts the mappings put back from the journal, which are not allocations.
+func (m *poolMetrics) restored(n int) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.mappings += n
+	m.updateUtilizationLocked()
+}
+
+// allocated counts a mapping to a new IP.
+func (m *poolMetrics) allocated() {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.mappings++
+	if m.allocations != nil {
+		m.allocations.Add(1)
+	}
+	m.updateUtilizationLocked()
+}
+
+// evicted counts the mapping to ip dropped from the LRU to make room, and warns once in a while
+// if clients still connected to it lately.
+func (m *poolMetrics) evicted(ip net.Address) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.mappings--
+	m.updateUtilizationLocked()
+	if m.evictions != nil {
+		m.evictions.Add(1)
+	}
+	seen, ok := m.lastSeen[ip]
+	delete(m.lastSeen, ip)
+	now := m.now()
+	if !ok || now.Sub(seen) > hotEvictionWindow {
+		return
+	}
+	if m.hotEvicted != nil {
+		m.hotEvicted.Add(1)
+	}
+	m.hotEvictions++
+	if now.Sub(m.lastWarning) >= hotEvictionWarningInterval {
+		newError("fake DNS pool ", m.pool, " evicted ", m.hotEvictions, " mappings in use within ", hotEvictionWindow,
+			", consider a larger poolSize").AtWarning().WriteToLog()
+		m.hotEvictions = 0
+		m.lastWarning = now
+	}
+}
+
+// flushed counts the mapping to ip removed through the API, which is not an eviction.
+func (m *poolMetrics) flushed(ip net.Address) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.mappings--
+	delete(m.lastSeen, ip)
+	m.updateUtilizationLocked()
+}
+
+// seen records that traffic was sent to ip.
+func (m *poolMetrics) seen(ip net.Address) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	m.lastSeen[ip] = m.now()
+}
+
+// missed counts a lookup of an IP of the pool without a mapping.
+func (m *poolMetrics) missed() {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if m.missedLookups != nil {
+		m.missedLookups.Add(1)
+	}
+}
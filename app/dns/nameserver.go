@@ -2,7 +2,9 @@ package dns
 
 import (
 	"context"
+	"math/rand"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport/internet"
 )
 
 // Server is the interface for Name Server.
@@ -23,13 +26,23 @@ type Server interface {
 	QueryIP(ctx context.Context, domain string, clientIP net.IP, option dns.IPOption, disableCache bool) ([]net.IP, error)
 }
 
+// exchanger is implemented by name servers able to send arbitrary DNS
+// messages, which DNSSEC validation needs.
+type exchanger interface {
+	// exchange sends the packed DNS message msg, and returns the packed response.
+	exchange(ctx context.Context, msg []byte) ([]byte, error)
+}
+
 // Client is the interface for DNS client.
 type Client struct {
 	server       Server
+	tag          string
 	clientIP     net.IP
 	skipFallback bool
 	domains      []string
 	expectIPs    []*router.GeoIPMatcher
+	answerOrder  AnswerOrder
+	validating   bool
 }
 
 var errExpectedIPNonMatch = errors.New("expectIPs not match")
@@ -72,6 +85,7 @@ func NewClient(
 	ctx context.Context,
 	ns *NameServer,
 	clientIP net.IP,
+	validator *dnssecValidator,
 	container router.GeoIPMatcherContainer,
 	matcherInfos *[]*DomainMatcherInfo,
 	updateDomainRule func(strmatcher.Matcher, int, []*DomainMatcherInfo) error,
@@ -85,6 +99,15 @@ func NewClient(
 			return newError("failed to create nameserver").Base(err).AtWarning()
 		}
 
+		if ns.Dnssec == DnssecMode_VALIDATE {
+			ex, ok := server.(exchanger)
+			if !ok {
+				return newError("DNSSEC validation is not supported by name server ", server.Name()).AtWarning()
+			}
+			server = newDNSSECNameServer(server.Name(), ex, validator, ns.GetQueryStrategy())
+			client.validating = true
+		}
+
 		// Priotize local domains with specific TLDs or without any dot to local DNS
 		if _, isLocalDNS := server.(*LocalNameServer); isLocalDNS {
 			ns.PrioritizedDomain = append(ns.PrioritizedDomain, localTLDsAndDotlessDomains...)
@@ -154,10 +177,12 @@ func NewClient(
 		}
 
 		client.server = server
+		client.tag = ns.Tag
 		client.clientIP = clientIP
 		client.skipFallback = ns.SkipFallback
 		client.domains = rules
 		client.expectIPs = matchers
+		client.answerOrder = ns.AnswerOrder
 		return nil
 	})
 	return client, err
@@ -202,7 +227,53 @@ func (c *Client) QueryIP(ctx context.Context, domain string, option dns.IPOption
 	if err != nil {
 		return ips, err
 	}
-	return c.MatchExpectedIPs(domain, ips)
+	ips, err = c.MatchExpectedIPs(domain, ips)
+	if err != nil {
+		return ips, err
+	}
+	return c.orderAnswers(ips), nil
+}
+
+// orderAnswers returns the IPs in the answer order of the client. The slice
+// may be shared with the cache of the name server, so it is never modified.
+func (c *Client) orderAnswers(ips []net.IP) []net.IP {
+	if len(ips) < 2 {
+		return ips
+	}
+	if _, isFakeDNS := c.server.(*FakeDNSServer); isFakeDNS {
+		return ips
+	}
+
+	switch c.answerOrder {
+	case AnswerOrder_SHUFFLE:
+		ordered := append([]net.IP(nil), ips...)
+		rand.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+		return ordered
+	case AnswerOrder_RTT_SORTED:
+		rtts := make(map[string]time.Duration, len(ips))
+		for _, ip := range ips {
+			if rtt, found := internet.LookupRTT(ip); found {
+				rtts[string(ip)] = rtt
+			}
+		}
+		if len(rtts) == 0 {
+			return ips
+		}
+		ordered := append([]net.IP(nil), ips...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			ri, knownI := rtts[string(ordered[i])]
+			rj, knownJ := rtts[string(ordered[j])]
+			if knownI != knownJ {
+				return knownI
+			}
+			return knownI && ri < rj
+		})
+		return ordered
+	default:
+		return ips
+	}
 }
 
 // MatchExpectedIPs matches queried domain IPs with expected IPs and returns matched ones.
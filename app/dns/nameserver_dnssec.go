@@ -0,0 +1,224 @@
+package dns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/task"
+	dns_feature "github.com/xtls/xray-core/features/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSSECNameServer queries a name server for IPs, and validates its answers with DNSSEC.
+type DNSSECNameServer struct {
+	sync.RWMutex
+	name          string
+	exchanger     exchanger
+	validator     *dnssecValidator
+	ips           map[string]*record
+	cleanup       *task.Periodic
+	queryStrategy QueryStrategy
+}
+
+func newDNSSECNameServer(name string, ex exchanger, validator *dnssecValidator, queryStrategy QueryStrategy) *DNSSECNameServer {
+	s := &DNSSECNameServer{
+		name:          name,
+		exchanger:     ex,
+		validator:     validator,
+		ips:           make(map[string]*record),
+		queryStrategy: queryStrategy,
+	}
+	s.cleanup = &task.Periodic{
+		Interval: time.Minute,
+		Execute:  s.Cleanup,
+	}
+	return s
+}
+
+// Name implements Server.
+func (s *DNSSECNameServer) Name() string {
+	return s.name
+}
+
+// Cleanup clears expired items from cache
+func (s *DNSSECNameServer) Cleanup() error {
+	now := time.Now()
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.ips) == 0 {
+		return newError("nothing to do. stopping...")
+	}
+
+	for domain, record := range s.ips {
+		if record.A != nil && record.A.Expire.Before(now) {
+			record.A = nil
+		}
+		if record.AAAA != nil && record.AAAA.Expire.Before(now) {
+			record.AAAA = nil
+		}
+		if record.A == nil && record.AAAA == nil {
+			delete(s.ips, domain)
+		}
+	}
+	return nil
+}
+
+func (s *DNSSECNameServer) updateIP(domain string, qtype uint16, ipRec *IPRecord) {
+	s.Lock()
+	rec, found := s.ips[domain]
+	if !found {
+		rec = &record{}
+		s.ips[domain] = rec
+	}
+	switch qtype {
+	case dns.TypeA:
+		if isNewer(rec.A, ipRec) {
+			rec.A = ipRec
+		}
+	case dns.TypeAAAA:
+		if isNewer(rec.AAAA, ipRec) {
+			rec.AAAA = ipRec
+		}
+	}
+	s.Unlock()
+	common.Must(s.cleanup.Start())
+}
+
+// lookup queries the records of qtype at domain, and validates the answer.
+func (s *DNSSECNameServer) lookup(ctx context.Context, domain string, qtype uint16, clientIP net.IP) (*IPRecord, error) {
+	msg, err := s.validator.query(ctx, s.exchanger, domain, qtype, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validator.validate(ctx, s.exchanger, domain, qtype, msg); err != nil {
+		return nil, err
+	}
+
+	ipRecord := &IPRecord{
+		ReqID:  msg.Id,
+		RCode:  dnsmessage.RCode(msg.Rcode),
+		Expire: time.Now().Add(time.Duration(minTTL(msg.Answer)) * time.Second),
+	}
+	for _, rr := range msg.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			if qtype == dns.TypeA {
+				ipRecord.IP = append(ipRecord.IP, net.IPAddress(rr.A))
+			}
+		case *dns.AAAA:
+			if qtype == dns.TypeAAAA {
+				ipRecord.IP = append(ipRecord.IP, net.IPAddress(rr.AAAA))
+			}
+		}
+	}
+	return ipRecord, nil
+}
+
+func (s *DNSSECNameServer) findIPsForDomain(domain string, option dns_feature.IPOption) ([]net.IP, error) {
+	s.RLock()
+	record, found := s.ips[domain]
+	s.RUnlock()
+
+	if !found {
+		return nil, errRecordNotFound
+	}
+
+	var err4 error
+	var err6 error
+	var ips []net.Address
+	var ip6 []net.Address
+
+	if option.IPv4Enable {
+		ips, err4 = record.A.getIPs()
+	}
+
+	if option.IPv6Enable {
+		ip6, err6 = record.AAAA.getIPs()
+		ips = append(ips, ip6...)
+	}
+
+	if len(ips) > 0 {
+		return toNetIP(ips)
+	}
+
+	if err4 != nil {
+		return nil, err4
+	}
+
+	if err6 != nil {
+		return nil, err6
+	}
+
+	return nil, dns_feature.ErrEmptyResponse
+}
+
+// QueryIP implements Server.
+func (s *DNSSECNameServer) QueryIP(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption, disableCache bool) ([]net.IP, error) {
+	fqdn := Fqdn(domain)
+	option = ResolveIpOptionOverride(s.queryStrategy, option)
+	if !option.IPv4Enable && !option.IPv6Enable {
+		return nil, dns_feature.ErrEmptyResponse
+	}
+
+	if disableCache {
+		newError("DNS cache is disabled. Querying IP for ", domain, " at ", s.name).AtDebug().WriteToLog()
+	} else {
+		ips, err := s.findIPsForDomain(fqdn, option)
+		if err != errRecordNotFound {
+			newError(s.name, " cache HIT ", domain, " -> ", ips).Base(err).AtDebug().WriteToLog()
+			log.Record(&log.DNSLog{Server: s.name, Domain: domain, Result: ips, Status: log.DNSCacheHit, Elapsed: 0, Error: err})
+			return ips, err
+		}
+	}
+
+	var qtypes []uint16
+	if option.IPv4Enable {
+		qtypes = append(qtypes, dns.TypeA)
+	}
+	if option.IPv6Enable {
+		qtypes = append(qtypes, dns.TypeAAAA)
+	}
+
+	start := time.Now()
+	recs := make([]*IPRecord, len(qtypes))
+	errs := make([]error, len(qtypes))
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+			recs[i], errs[i] = s.lookup(ctx, fqdn, qtype, clientIP)
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	// a bogus answer fails the query, even if the other one is fine
+	for _, err := range errs {
+		if errors.Cause(err) == errDNSSECBogus {
+			log.Record(&log.DNSLog{Server: s.name, Domain: domain, Result: nil, Status: log.DNSQueried, Elapsed: time.Since(start), Error: err})
+			return nil, err
+		}
+	}
+	for i, rec := range recs {
+		if rec != nil {
+			newError(s.name, " got answer: ", fqdn, " ", dns.TypeToString[qtypes[i]], " -> ", rec.IP, " ", time.Since(start)).AtInfo().WriteToLog()
+			s.updateIP(fqdn, qtypes[i], rec)
+		}
+	}
+	ips, err := s.findIPsForDomain(fqdn, option)
+	if err == errRecordNotFound {
+		err = ctx.Err()
+		for i := 0; err == nil && i < len(errs); i++ {
+			err = errs[i]
+		}
+	}
+	log.Record(&log.DNSLog{Server: s.name, Domain: domain, Result: ips, Status: log.DNSQueried, Elapsed: time.Since(start), Error: err})
+	return ips, err
+}
@@ -284,6 +284,15 @@ func (s *DoHNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 	}
 }
 
+// exchange implements exchanger.
+func (s *DoHNameServer) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	ctx = session.ContextWithContent(ctx, &session.Content{
+		Protocol:       "https",
+		SkipDNSResolve: true,
+	})
+	return s.dohHTTPSContext(ctx, msg)
+}
+
 func (s *DoHNameServer) dohHTTPSContext(ctx context.Context, b []byte) ([]byte, error) {
 	body := bytes.NewBuffer(b)
 	req, err := http.NewRequest("POST", s.dohURL, body)
@@ -1,9 +1,7 @@
 package dns
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"net/url"
 	"sync"
 	"sync/atomic"
@@ -11,7 +9,6 @@ import (
 
 	"github.com/quic-go/quic-go"
 	"github.com/xtls/xray-core/common"
-	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol/dns"
@@ -174,20 +171,7 @@ func (s *QUICNameServer) sendQuery(ctx context.Context, domain string, clientIP
 		go func(r *dnsRequest) {
 			// generate new context for each req, using same context
 			// may cause reqs all aborted if any one encounter an error
-			dnsCtx := ctx
-
-			// reserve internal dns server requested Inbound
-			if inbound := session.InboundFromContext(ctx); inbound != nil {
-				dnsCtx = session.ContextWithInbound(dnsCtx, inbound)
-			}
-
-			dnsCtx = session.ContextWithContent(dnsCtx, &session.Content{
-				Protocol:       "quic",
-				SkipDNSResolve: true,
-			})
-
-			var cancel context.CancelFunc
-			dnsCtx, cancel = context.WithDeadline(dnsCtx, deadline)
+			dnsCtx, cancel := context.WithDeadline(ctx, deadline)
 			defer cancel()
 
 			b, err := dns.PackMessage(r.msg)
@@ -195,47 +179,14 @@ func (s *QUICNameServer) sendQuery(ctx context.Context, domain string, clientIP
 				newError("failed to pack dns query").Base(err).AtError().WriteToLog()
 				return
 			}
-
-			dnsReqBuf := buf.New()
-			binary.Write(dnsReqBuf, binary.BigEndian, uint16(b.Len()))
-			dnsReqBuf.Write(b.Bytes())
+			resp, err := s.exchange(dnsCtx, b.Bytes())
 			b.Release()
-
-			conn, err := s.openStream(dnsCtx)
-			if err != nil {
-				newError("failed to open quic connection").Base(err).AtError().WriteToLog()
-				return
-			}
-
-			_, err = conn.Write(dnsReqBuf.Bytes())
-			if err != nil {
-				newError("failed to send query").Base(err).AtError().WriteToLog()
-				return
-			}
-
-			_ = conn.Close()
-
-			respBuf := buf.New()
-			defer respBuf.Release()
-			n, err := respBuf.ReadFullFrom(conn, 2)
-			if err != nil && n == 0 {
-				newError("failed to read response length").Base(err).AtError().WriteToLog()
-				return
-			}
-			var length int16
-			err = binary.Read(bytes.NewReader(respBuf.Bytes()), binary.BigEndian, &length)
 			if err != nil {
-				newError("failed to parse response length").Base(err).AtError().WriteToLog()
-				return
-			}
-			respBuf.Clear()
-			n, err = respBuf.ReadFullFrom(conn, int32(length))
-			if err != nil && n == 0 {
-				newError("failed to read response length").Base(err).AtError().WriteToLog()
+				newError("failed to query ", s.name).Base(err).AtError().WriteToLog()
 				return
 			}
 
-			rec, err := parseResponse(respBuf.Bytes())
+			rec, err := parseResponse(resp)
 			if err != nil {
 				newError("failed to handle response").Base(err).AtError().WriteToLog()
 				return
@@ -245,6 +196,29 @@ func (s *QUICNameServer) sendQuery(ctx context.Context, domain string, clientIP
 	}
 }
 
+// exchange implements exchanger.
+func (s *QUICNameServer) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	ctx = session.ContextWithContent(ctx, &session.Content{
+		Protocol:       "quic",
+		SkipDNSResolve: true,
+	})
+
+	conn, err := s.openStream(ctx)
+	if err != nil {
+		return nil, newError("failed to open quic connection").Base(err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := writeStreamMessage(conn, msg); err != nil {
+		conn.CancelRead(0)
+		return nil, newError("failed to send query").Base(err)
+	}
+	_ = conn.Close()
+
+	return readStreamMessage(conn)
+}
+
 func (s *QUICNameServer) findIPsForDomain(domain string, option dns_feature.IPOption) ([]net.IP, error) {
 	s.RLock()
 	record, found := s.ips[domain]
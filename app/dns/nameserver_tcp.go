@@ -1,16 +1,13 @@
 package dns
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/xtls/xray-core/common"
-	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/net/cnc"
@@ -202,19 +199,7 @@ func (s *TCPNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 
 	for _, req := range reqs {
 		go func(r *dnsRequest) {
-			dnsCtx := ctx
-
-			if inbound := session.InboundFromContext(ctx); inbound != nil {
-				dnsCtx = session.ContextWithInbound(dnsCtx, inbound)
-			}
-
-			dnsCtx = session.ContextWithContent(dnsCtx, &session.Content{
-				Protocol:       "dns",
-				SkipDNSResolve: true,
-			})
-
-			var cancel context.CancelFunc
-			dnsCtx, cancel = context.WithDeadline(dnsCtx, deadline)
+			dnsCtx, cancel := context.WithDeadline(ctx, deadline)
 			defer cancel()
 
 			b, err := dns.PackMessage(r.msg)
@@ -222,46 +207,14 @@ func (s *TCPNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 				newError("failed to pack dns query").Base(err).AtError().WriteToLog()
 				return
 			}
-
-			conn, err := s.dial(dnsCtx)
-			if err != nil {
-				newError("failed to dial namesever").Base(err).AtError().WriteToLog()
-				return
-			}
-			defer conn.Close()
-			dnsReqBuf := buf.New()
-			binary.Write(dnsReqBuf, binary.BigEndian, uint16(b.Len()))
-			dnsReqBuf.Write(b.Bytes())
+			resp, err := s.exchange(dnsCtx, b.Bytes())
 			b.Release()
-
-			_, err = conn.Write(dnsReqBuf.Bytes())
-			if err != nil {
-				newError("failed to send query").Base(err).AtError().WriteToLog()
-				return
-			}
-			dnsReqBuf.Release()
-
-			respBuf := buf.New()
-			defer respBuf.Release()
-			n, err := respBuf.ReadFullFrom(conn, 2)
-			if err != nil && n == 0 {
-				newError("failed to read response length").Base(err).AtError().WriteToLog()
-				return
-			}
-			var length int16
-			err = binary.Read(bytes.NewReader(respBuf.Bytes()), binary.BigEndian, &length)
 			if err != nil {
-				newError("failed to parse response length").Base(err).AtError().WriteToLog()
-				return
-			}
-			respBuf.Clear()
-			n, err = respBuf.ReadFullFrom(conn, int32(length))
-			if err != nil && n == 0 {
-				newError("failed to read response length").Base(err).AtError().WriteToLog()
+				newError("failed to query ", s.name).Base(err).AtError().WriteToLog()
 				return
 			}
 
-			rec, err := parseResponse(respBuf.Bytes())
+			rec, err := parseResponse(resp)
 			if err != nil {
 				newError("failed to parse DNS over TCP response").Base(err).AtError().WriteToLog()
 				return
@@ -272,6 +225,27 @@ func (s *TCPNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 	}
 }
 
+// exchange implements exchanger.
+func (s *TCPNameServer) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	ctx = session.ContextWithContent(ctx, &session.Content{
+		Protocol:       "dns",
+		SkipDNSResolve: true,
+	})
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, newError("failed to dial namesever").Base(err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := writeStreamMessage(conn, msg); err != nil {
+		return nil, newError("failed to send query").Base(err)
+	}
+	return readStreamMessage(conn)
+}
+
 func (s *TCPNameServer) findIPsForDomain(domain string, option dns_feature.IPOption) ([]net.IP, error) {
 	s.RLock()
 	record, found := s.ips[domain]
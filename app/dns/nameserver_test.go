@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"context"
+	gonet "net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// staticServer answers every query with the same IPs, like a cached answer.
+type staticServer struct {
+	ips []net.IP
+}
+
+func (*staticServer) Name() string {
+	return "static"
+}
+
+func (s *staticServer) QueryIP(ctx context.Context, domain string, clientIP net.IP, option dns.IPOption, disableCache bool) ([]net.IP, error) {
+	return s.ips, nil
+}
+
+func TestAnswerOrder(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("1.1.1.1"),
+		net.ParseIP("2.2.2.2"),
+		net.ParseIP("3.3.3.3"),
+		net.ParseIP("4.4.4.4"),
+	}
+	received := append([]net.IP(nil), ips...)
+	option := dns.IPOption{IPv4Enable: true}
+
+	for _, order := range []AnswerOrder{AnswerOrder_DEFAULT_ORDER, AnswerOrder_AS_RECEIVED} {
+		client := &Client{server: &staticServer{ips: ips}, answerOrder: order}
+		for i := 0; i < 100; i++ {
+			answer, err := client.QueryIP(context.Background(), "example.com", option, false)
+			common.Must(err)
+			if r := cmp.Diff(answer, received); r != "" {
+				t.Fatal(order, ": ", r)
+			}
+		}
+	}
+
+	client := &Client{server: &staticServer{ips: ips}, answerOrder: AnswerOrder_SHUFFLE}
+	firsts := make(map[string]int)
+	const queries = 4000
+	for i := 0; i < queries; i++ {
+		answer, err := client.QueryIP(context.Background(), "example.com", option, false)
+		common.Must(err)
+		if len(answer) != len(ips) {
+			t.Fatal("unexpected answer: ", answer)
+		}
+		firsts[answer[0].String()]++
+	}
+	for _, ip := range ips {
+		// each IP is expected first 1000 times
+		if n := firsts[ip.String()]; n < 800 || n > 1200 {
+			t.Error("unexpected number of ", ip, " answered first: ", n)
+		}
+	}
+	if r := cmp.Diff(ips, received); r != "" {
+		t.Error("cached answer modified: ", r)
+	}
+}
+
+func TestAnswerOrderRTTSorted(t *testing.T) {
+	listener, err := gonet.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := net.Port(listener.Addr().(*gonet.TCPAddr).Port)
+	conn, err := internet.DialSystem(context.Background(), net.TCPDestination(net.LocalHostIP, port), nil)
+	common.Must(err)
+	conn.Close()
+
+	ips := []net.IP{
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("127.0.0.1"),
+	}
+	client := &Client{server: &staticServer{ips: ips}, answerOrder: AnswerOrder_RTT_SORTED}
+	answer, err := client.QueryIP(context.Background(), "example.com", dns.IPOption{IPv4Enable: true}, false)
+	common.Must(err)
+	expected := []net.IP{ips[2], ips[0], ips[1]}
+	if r := cmp.Diff(answer, expected); r != "" {
+		t.Error(r)
+	}
+}
@@ -2,12 +2,14 @@ package dns
 
 import (
 	"context"
+	"encoding/binary"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol/dns"
@@ -28,6 +30,7 @@ type ClassicNameServer struct {
 	address   *net.Destination
 	ips       map[string]*record
 	requests  map[uint16]*dnsRequest
+	exchanges map[uint16]chan []byte
 	pub       *pubsub.Service
 	udpServer *udp.Dispatcher
 	cleanup   *task.Periodic
@@ -42,11 +45,12 @@ func NewClassicNameServer(address net.Destination, dispatcher routing.Dispatcher
 	}
 
 	s := &ClassicNameServer{
-		address:  &address,
-		ips:      make(map[string]*record),
-		requests: make(map[uint16]*dnsRequest),
-		pub:      pubsub.NewService(),
-		name:     strings.ToUpper(address.String()),
+		address:   &address,
+		ips:       make(map[string]*record),
+		requests:  make(map[uint16]*dnsRequest),
+		exchanges: make(map[uint16]chan []byte),
+		pub:       pubsub.NewService(),
+		name:      strings.ToUpper(address.String()),
 	}
 	s.cleanup = &task.Periodic{
 		Interval: time.Minute,
@@ -107,6 +111,10 @@ func (s *ClassicNameServer) Cleanup() error {
 
 // HandleResponse handles udp response packet from remote DNS server.
 func (s *ClassicNameServer) HandleResponse(ctx context.Context, packet *udp_proto.Packet) {
+	if s.handleExchange(packet.Payload.Bytes()) {
+		return
+	}
+
 	ipRec, err := parseResponse(packet.Payload.Bytes())
 	if err != nil {
 		newError(s.name, " fail to parse responded DNS udp").AtError().WriteToLog()
@@ -198,6 +206,53 @@ func (s *ClassicNameServer) sendQuery(ctx context.Context, domain string, client
 	}
 }
 
+// exchange implements exchanger.
+func (s *ClassicNameServer) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	if len(msg) < 2 {
+		return nil, newError("invalid DNS message")
+	}
+	id := s.newReqID()
+	resp := make(chan []byte, 1)
+	s.Lock()
+	s.exchanges[id] = resp
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		delete(s.exchanges, id)
+		s.Unlock()
+	}()
+
+	b := buf.New()
+	common.Must2(b.Write(msg))
+	binary.BigEndian.PutUint16(b.BytesTo(2), id)
+	s.udpServer.Dispatch(toDnsContext(ctx, s.address.String()), *s.address, b)
+
+	select {
+	case msg := <-resp:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleExchange passes payload to the pending exchange of its ID, and reports whether there is one.
+func (s *ClassicNameServer) handleExchange(payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+	s.Lock()
+	resp, ok := s.exchanges[binary.BigEndian.Uint16(payload)]
+	s.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case resp <- append([]byte(nil), payload...):
+	default:
+	}
+	return true
+}
+
 func (s *ClassicNameServer) findIPsForDomain(domain string, option dns_feature.IPOption) ([]net.IP, error) {
 	s.RLock()
 	record, found := s.ips[domain]
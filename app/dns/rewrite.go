@@ -0,0 +1,77 @@
+package dns
+
+import (
+	"strings"
+
+	"github.com/xtls/xray-core/common/strmatcher"
+)
+
+// maxRewriteDepth is the maximum number of chained rewrites for one query.
+const maxRewriteDepth = 5
+
+// QueryRewriter replaces queried domains according to rewrite rules.
+type QueryRewriter struct {
+	replacements []string
+	matchers     *strmatcher.MatcherGroup
+}
+
+// NewQueryRewriter creates a new QueryRewriter instance.
+func NewQueryRewriter(rules []*Config_QueryRewrite) (*QueryRewriter, error) {
+	g := new(strmatcher.MatcherGroup)
+	r := &QueryRewriter{
+		replacements: make([]string, len(rules)+1),
+		matchers:     g,
+	}
+
+	for _, rule := range rules {
+		if len(rule.Replacement) == 0 {
+			return nil, newError("empty replacement for domain: ", rule.Domain).AtWarning()
+		}
+		matcher, err := toStrMatcher(rule.Type, rule.Domain)
+		if err != nil {
+			return nil, newError("failed to create domain matcher").Base(err)
+		}
+		id := g.Add(matcher)
+		r.replacements[id] = strings.ToLower(strings.TrimSuffix(rule.Replacement, "."))
+	}
+
+	return r, nil
+}
+
+func (r *QueryRewriter) rewriteOnce(domain string) (string, bool) {
+	// the earliest rule takes precedence
+	var first uint32
+	for _, id := range r.matchers.Match(domain) {
+		if first == 0 || id < first {
+			first = id
+		}
+	}
+	if first == 0 {
+		return "", false
+	}
+	return r.replacements[first], true
+}
+
+// Rewrite returns the domain to query instead of the given one, following
+// chained rewrites. It fails on loops and on chains longer than maxRewriteDepth.
+func (r *QueryRewriter) Rewrite(domain string) (string, error) {
+	var visited map[string]bool
+	current := domain
+	for depth := 0; ; depth++ {
+		next, found := r.rewriteOnce(current)
+		if !found {
+			return current, nil
+		}
+		if depth == maxRewriteDepth {
+			return "", newError("too many chained rewrites for domain ", domain).AtWarning()
+		}
+		if visited == nil {
+			visited = map[string]bool{strings.ToLower(domain): true}
+		}
+		if visited[next] {
+			return "", newError("rewrite loop for domain ", domain, " at ", next).AtWarning()
+		}
+		visited[next] = true
+		current = next
+	}
+}
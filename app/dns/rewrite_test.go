@@ -0,0 +1,103 @@
+package dns_test
+
+import (
+	"testing"
+
+	. "github.com/xtls/xray-core/app/dns"
+	"github.com/xtls/xray-core/common"
+)
+
+func TestQueryRewriter(t *testing.T) {
+	rewriter, err := NewQueryRewriter([]*Config_QueryRewrite{
+		{
+			Type:        DomainMatchingType_Full,
+			Domain:      "telemetry.vendor.com",
+			Replacement: "blackhole.local",
+		},
+		{
+			Type:        DomainMatchingType_Subdomain,
+			Domain:      "internal.example.com",
+			Replacement: "internal.example.corp.",
+		},
+		{
+			Type:        DomainMatchingType_Full,
+			Domain:      "internal.example.corp",
+			Replacement: "gateway.example.corp",
+		},
+		{
+			Type:        DomainMatchingType_Full,
+			Domain:      "a.loop",
+			Replacement: "b.loop",
+		},
+		{
+			Type:        DomainMatchingType_Full,
+			Domain:      "b.loop",
+			Replacement: "a.loop",
+		},
+		{
+			Type:        DomainMatchingType_Keyword,
+			Domain:      "chain",
+			Replacement: "1.chain",
+		},
+		{
+			Type:        DomainMatchingType_Full,
+			Domain:      "1.chain",
+			Replacement: "2.chain",
+		},
+	})
+	common.Must(err)
+
+	testCases := []struct {
+		Input  string
+		Output string
+		Error  bool
+	}{
+		{Input: "telemetry.vendor.com", Output: "blackhole.local"},
+		{Input: "vendor.com", Output: "vendor.com"},
+		{Input: "www.internal.example.com", Output: "gateway.example.corp"},
+		{Input: "a.loop", Error: true},
+		{Input: "chain.example", Error: true},
+	}
+
+	for _, testCase := range testCases {
+		domain, err := rewriter.Rewrite(testCase.Input)
+		if testCase.Error {
+			if err == nil {
+				t.Error("expect error for ", testCase.Input, " but got ", domain)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("unexpected error for ", testCase.Input, ": ", err)
+		} else if domain != testCase.Output {
+			t.Error("expect ", testCase.Output, " for ", testCase.Input, " but got ", domain)
+		}
+	}
+}
+
+func TestQueryRewriterChainLimit(t *testing.T) {
+	chain := func(n int) []*Config_QueryRewrite {
+		names := []string{"0.test", "1.test", "2.test", "3.test", "4.test", "5.test", "6.test"}
+		rules := make([]*Config_QueryRewrite, 0, n)
+		for i := 0; i < n; i++ {
+			rules = append(rules, &Config_QueryRewrite{
+				Type:        DomainMatchingType_Full,
+				Domain:      names[i],
+				Replacement: names[i+1],
+			})
+		}
+		return rules
+	}
+
+	rewriter, err := NewQueryRewriter(chain(5))
+	common.Must(err)
+	if domain, err := rewriter.Rewrite("0.test"); err != nil || domain != "5.test" {
+		t.Error("expect 5.test but got ", domain, " ", err)
+	}
+
+	rewriter, err = NewQueryRewriter(chain(6))
+	common.Must(err)
+	if domain, err := rewriter.Rewrite("0.test"); err == nil {
+		t.Error("expect error for 6 chained rewrites but got ", domain)
+	}
+}
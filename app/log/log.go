@@ -57,7 +57,7 @@ func (g *Instance) initErrorLogger() error {
 	if err != nil {
 		return err
 	}
-	g.errorLogger = handler
+	g.errorLogger = newRateLimitedHandler(handler, g.config)
 	return nil
 }
 
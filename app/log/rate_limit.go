@@ -0,0 +1,132 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/serial"
+)
+
+type rateLimitKey struct {
+	severity log.Severity
+	template string
+}
+
+type rateLimitWindow struct {
+	count      uint32
+	suppressed uint32
+}
+
+// rateLimitedHandler is a log.Handler that drops general messages of a
+// template once too many of them have been logged within a window.
+type rateLimitedHandler struct {
+	sync.Mutex
+	handler log.Handler
+	limits  map[log.Severity]*RateLimit
+	windows map[rateLimitKey]*rateLimitWindow
+	timers  map[rateLimitKey]*time.Timer
+	closed  bool
+}
+
+func newRateLimitedHandler(handler log.Handler, config *Config) log.Handler {
+	limits := make(map[log.Severity]*RateLimit)
+	for severity, limit := range map[log.Severity]*RateLimit{
+		log.Severity_Error:   config.ErrorRateLimit,
+		log.Severity_Warning: config.WarningRateLimit,
+		log.Severity_Info:    config.InfoRateLimit,
+	} {
+		if limit != nil && limit.Window > 0 {
+			limits[severity] = limit
+		}
+	}
+	if len(limits) == 0 {
+		return handler
+	}
+	return &rateLimitedHandler{
+		handler: handler,
+		limits:  limits,
+		windows: make(map[rateLimitKey]*rateLimitWindow),
+		timers:  make(map[rateLimitKey]*time.Timer),
+	}
+}
+
+func messageTemplate(content interface{}) string {
+	if t, ok := content.(log.HasTemplate); ok {
+		return t.Template()
+	}
+	return serial.ToString(content)
+}
+
+// Handle implements log.Handler.
+func (h *rateLimitedHandler) Handle(msg log.Message) {
+	if msg, ok := msg.(*log.GeneralMessage); ok {
+		if limit, found := h.limits[msg.Severity]; found && !h.allow(msg, limit) {
+			return
+		}
+	}
+	h.handler.Handle(msg)
+}
+
+func (h *rateLimitedHandler) allow(msg *log.GeneralMessage, limit *RateLimit) bool {
+	key := rateLimitKey{
+		severity: msg.Severity,
+		template: messageTemplate(msg.Content),
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.closed {
+		return true
+	}
+
+	window, found := h.windows[key]
+	if !found {
+		window = &rateLimitWindow{}
+		h.windows[key] = window
+		h.timers[key] = time.AfterFunc(time.Duration(limit.Window)*time.Second, func() {
+			h.endWindow(key)
+		})
+	}
+	if window.count < limit.Burst {
+		window.count++
+		return true
+	}
+	window.suppressed++
+	return false
+}
+
+func (h *rateLimitedHandler) endWindow(key rateLimitKey) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	window := h.windows[key]
+	delete(h.windows, key)
+	delete(h.timers, key)
+	if window != nil && window.suppressed > 0 {
+		h.handler.Handle(&log.GeneralMessage{
+			Severity: key.severity,
+			Content:  serial.Concat("suppressed ", window.suppressed, " similar messages: ", key.template),
+		})
+	}
+}
+
+// Close implements common.Closable.
+func (h *rateLimitedHandler) Close() error {
+	h.Lock()
+	h.closed = true
+	for key, timer := range h.timers {
+		timer.Stop()
+		delete(h.timers, key)
+		delete(h.windows, key)
+	}
+	h.Unlock()
+
+	return common.Close(h.handler)
+}
@@ -0,0 +1,92 @@
+package log_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/log"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	clog "github.com/xtls/xray-core/common/log"
+)
+
+type collectingHandler struct {
+	sync.Mutex
+	messages []string
+}
+
+func (h *collectingHandler) Handle(msg clog.Message) {
+	h.Lock()
+	defer h.Unlock()
+	h.messages = append(h.messages, msg.String())
+}
+
+func (h *collectingHandler) Messages() []string {
+	h.Lock()
+	defer h.Unlock()
+	return append([]string(nil), h.messages...)
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := &collectingHandler{}
+	log.RegisterHandlerCreator(log.LogType_Console, func(lt log.LogType, options log.HandlerCreatorOptions) (clog.Handler, error) {
+		return handler, nil
+	})
+
+	logger, err := log.New(context.Background(), &log.Config{
+		ErrorLogLevel: clog.Severity_Debug,
+		ErrorLogType:  log.LogType_Console,
+		AccessLogType: log.LogType_None,
+		ErrorRateLimit: &log.RateLimit{
+			Burst:  5,
+			Window: 1,
+		},
+	})
+	common.Must(err)
+	common.Must(logger.Start())
+	defer logger.Close()
+
+	for i := 0; i < 100; i++ {
+		errors.New("failed to dial ", i).AtError().WriteToLog()
+	}
+	// debug messages are never suppressed
+	for i := 0; i < 10; i++ {
+		errors.New("debug ", i).AtDebug().WriteToLog()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var summary string
+	for summary == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("no summary logged")
+		}
+		time.Sleep(100 * time.Millisecond)
+		for _, msg := range handler.Messages() {
+			if strings.Contains(msg, "suppressed") {
+				summary = msg
+			}
+		}
+	}
+
+	var dials, debugs int
+	for _, msg := range handler.Messages() {
+		switch {
+		case strings.Contains(msg, "failed to dial"):
+			dials++
+		case strings.Contains(msg, "debug"):
+			debugs++
+		}
+	}
+	if dials > 5+1 {
+		t.Error("too many messages logged: ", dials)
+	}
+	if debugs != 10 {
+		t.Error("debug messages suppressed: ", debugs)
+	}
+	if !strings.Contains(summary, "suppressed 95 similar messages") {
+		t.Error("unexpected summary: ", summary)
+	}
+}
@@ -56,6 +56,25 @@ func NewMetricsHandler(ctx context.Context, config *Config) (*MetricsHandler, er
 			}
 			return true
 		})
+		manager.VisitGauges(func(name string, gauge feature_stats.Counter) bool {
+			// inbound>>>tag>>>connections
+			nameSplit := strings.Split(name, ">>>")
+			if len(nameSplit) != 3 {
+				return true
+			}
+			typeName, tag, metric := nameSplit[0], nameSplit[1], nameSplit[2]
+			if _, found := resp[typeName]; !found {
+				return true
+			}
+			if item, found := resp[typeName][tag]; found {
+				item[metric] = gauge.Value()
+			} else {
+				resp[typeName][tag] = map[string]int64{
+					metric: gauge.Value(),
+				}
+			}
+			return true
+		})
 		return resp
 	}))
 	expvar.Publish("observatory", expvar.Func(func() interface{} {
@@ -111,7 +130,13 @@ func (p *MetricsHandler) Close() error {
 	return nil
 }
 
+// declareTag declares the tag of the outbound the metrics handler adds.
+func declareTag(v *core.Validation, _ string, c interface{}) {
+	v.AddOutboundTag(c.(*Config).Tag)
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), declareTag))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
 		return NewMetricsHandler(ctx, cfg.(*Config))
 	}))
@@ -0,0 +1,194 @@
+package otel
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/common/trace"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/extension"
+)
+
+const (
+	// flushInterval is how long spans wait at most to be posted.
+	flushInterval = time.Second
+	// maxBatchSize is the number of spans that are posted without waiting for flushInterval.
+	maxBatchSize = 512
+	// maxPendingTraces is the number of ended traces that may wait to be batched, beyond which traces are dropped.
+	maxPendingTraces = 1024
+)
+
+// Tracer traces a sample of the connections, and posts their spans to an OpenTelemetry collector
+// in batches, over OTLP/HTTP with JSON encoding.
+type Tracer struct {
+	config   *Config
+	resource otlpResource
+	client   *http.Client
+	pending  chan []*trace.Span
+	done     *done.Instance
+	flushed  sync.WaitGroup
+}
+
+// NewTracer creates a Tracer based on the given config.
+func NewTracer(ctx context.Context, config *Config) (*Tracer, error) {
+	if config.Endpoint == "" {
+		return nil, newError("OTLP endpoint can't be empty")
+	}
+	t := &Tracer{
+		config:  config,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		pending: make(chan []*trace.Span, maxPendingTraces),
+		done:    done.New(),
+	}
+	attributes := map[string]string{"service.name": "xray"}
+	for k, v := range config.ResourceAttributes {
+		attributes[k] = v
+	}
+	t.resource.Attributes = toOTLPAttributes(attributes)
+	return t, nil
+}
+
+// Type implements common.HasType.
+func (*Tracer) Type() interface{} {
+	return extension.TracerType()
+}
+
+// Trace implements extension.Tracer.
+func (t *Tracer) Trace(ctx context.Context) (context.Context, *trace.Span) {
+	if t.config.SampleRatio < 1 && rand.Float64() >= t.config.SampleRatio {
+		return ctx, nil
+	}
+	return trace.NewRoot(ctx, "connection", t)
+}
+
+// Export implements trace.Exporter.
+func (t *Tracer) Export(spans []*trace.Span) {
+	select {
+	case t.pending <- spans:
+	default:
+		newError("dropped a trace of ", len(spans), " spans as the collector falls behind").AtDebug().WriteToLog()
+	}
+}
+
+// Start implements common.Runnable.
+func (t *Tracer) Start() error {
+	t.flushed.Add(1)
+	go t.run()
+	return nil
+}
+
+// Close implements common.Closable. It posts the spans still waiting first.
+func (t *Tracer) Close() error {
+	if t.done.Done() {
+		return nil
+	}
+	t.done.Close()
+	t.flushed.Wait()
+	return nil
+}
+
+func (t *Tracer) run() {
+	defer t.flushed.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []*trace.Span
+	for {
+		select {
+		case spans := <-t.pending:
+			batch = append(batch, spans...)
+			if len(batch) < maxBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-t.done.Wait():
+			for len(t.pending) > 0 {
+				batch = append(batch, <-t.pending...)
+			}
+			t.post(batch)
+			return
+		}
+		t.post(batch)
+		batch = nil
+	}
+}
+
+func (t *Tracer) post(spans []*trace.Span) {
+	if len(spans) == 0 {
+		return
+	}
+	request := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: t.resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/xtls/xray-core", Version: core.Version()},
+				Spans: make([]otlpSpan, 0, len(spans)),
+			}},
+		}},
+	}
+	scope := &request.ResourceSpans[0].ScopeSpans[0]
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		}
+		if s.ParentID != ([8]byte{}) {
+			span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		for _, a := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttribute{Key: a.Key, Value: otlpValue{StringValue: a.Value}})
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.Error}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	body, err := json.Marshal(request)
+	common.Must(err)
+	resp, err := t.client.Post(t.config.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		newError("failed to post ", len(spans), " spans").Base(err).AtWarning().WriteToLog()
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		newError("collector refused ", len(spans), " spans: ", resp.Status).AtWarning().WriteToLog()
+	}
+}
+
+func toOTLPAttributes(m map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attributes := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		attributes = append(attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: m[k]}})
+	}
+	return attributes
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return NewTracer(ctx, config.(*Config))
+	}))
+}
@@ -0,0 +1,77 @@
+package command
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/core"
+	feature_policy "github.com/xtls/xray-core/features/policy"
+	grpc "google.golang.org/grpc"
+)
+
+// policyServer is an implementation of PolicyService.
+type policyServer struct {
+	policy feature_policy.Manager
+}
+
+func NewPolicyServer(manager feature_policy.Manager) PolicyServiceServer {
+	return &policyServer{
+		policy: manager,
+	}
+}
+
+func (s *policyServer) instance() (*policy.Instance, error) {
+	pi, ok := s.policy.(*policy.Instance)
+	if !ok {
+		return nil, newError("PolicyService only works with its own policy.Instance.")
+	}
+	return pi, nil
+}
+
+func (s *policyServer) GetPolicy(ctx context.Context, request *GetPolicyRequest) (*GetPolicyResponse, error) {
+	pi, err := s.instance()
+	if err != nil {
+		return nil, err
+	}
+	return &GetPolicyResponse{
+		Policy: pi.GetPolicy(request.Level),
+	}, nil
+}
+
+func (s *policyServer) UpdatePolicy(ctx context.Context, request *UpdatePolicyRequest) (*UpdatePolicyResponse, error) {
+	pi, err := s.instance()
+	if err != nil {
+		return nil, err
+	}
+	if request.Policy == nil {
+		return nil, newError("no policy fields to update")
+	}
+	return &UpdatePolicyResponse{
+		Policy: pi.UpdatePolicy(request.Level, request.Policy),
+	}, nil
+}
+
+func (s *policyServer) mustEmbedUnimplementedPolicyServiceServer() {}
+
+type service struct {
+	policyManager feature_policy.Manager
+}
+
+func (s *service) Register(server *grpc.Server) {
+	RegisterPolicyServiceServer(server, NewPolicyServer(s.policyManager))
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
+		s := new(service)
+
+		core.RequireFeatures(ctx, func(pm feature_policy.Manager) {
+			s.policyManager = pm
+		})
+
+		return s, nil
+	}))
+}
@@ -0,0 +1,57 @@
+package command_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/policy"
+	. "github.com/xtls/xray-core/app/policy/command"
+	"github.com/xtls/xray-core/common"
+)
+
+func TestUpdatePolicy(t *testing.T) {
+	m, err := policy.New(context.Background(), &policy.Config{
+		Level: map[uint32]*policy.Policy{
+			0: {
+				Timeout: &policy.Policy_Timeout{
+					Handshake: &policy.Second{Value: 4},
+				},
+			},
+		},
+	})
+	common.Must(err)
+
+	s := NewPolicyServer(m)
+	existing := m.ForLevel(0)
+
+	resp, err := s.UpdatePolicy(context.Background(), &UpdatePolicyRequest{
+		Level: 0,
+		Policy: &policy.Policy{
+			Timeout: &policy.Policy_Timeout{
+				Handshake: &policy.Second{Value: 8},
+			},
+		},
+	})
+	common.Must(err)
+	if v := resp.Policy.Timeout.Handshake.Value; v != 8 {
+		t.Error("expect 8 sec handshake timeout in response, but got ", v)
+	}
+
+	if p := m.ForLevel(0); p.Timeouts.Handshake != 8*time.Second {
+		t.Error("expect new sessions to use 8 sec handshake timeout, but got ", p.Timeouts.Handshake)
+	}
+	if existing.Timeouts.Handshake != 4*time.Second {
+		t.Error("expect existing session to keep 4 sec handshake timeout, but got ", existing.Timeouts.Handshake)
+	}
+
+	get, err := s.GetPolicy(context.Background(), &GetPolicyRequest{Level: 0})
+	common.Must(err)
+	if v := get.Policy.Timeout.Handshake.Value; v != 8 {
+		t.Error("expect GetPolicy to return 8, but got ", v)
+	}
+
+	if _, err := s.UpdatePolicy(context.Background(), &UpdatePolicyRequest{}); err == nil {
+		t.Error("expect error for empty update")
+	}
+}
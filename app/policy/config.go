@@ -47,17 +47,28 @@ func (p *Policy_Timeout) overrideWith(another *Policy_Timeout) {
 
 func (p *Policy) overrideWith(another *Policy) {
 	if another.Timeout != nil {
+		if p.Timeout == nil {
+			p.Timeout = &Policy_Timeout{}
+		}
 		p.Timeout.overrideWith(another.Timeout)
 	}
-	if another.Stats != nil && p.Stats == nil {
-		p.Stats = &Policy_Stats{}
-		p.Stats = another.Stats
+	if another.Stats != nil {
+		p.Stats = &Policy_Stats{
+			UserUplink:   another.Stats.UserUplink,
+			UserDownlink: another.Stats.UserDownlink,
+		}
 	}
 	if another.Buffer != nil {
 		p.Buffer = &Policy_Buffer{
 			Connection: another.Buffer.Connection,
 		}
 	}
+	if another.Latency != nil {
+		p.Latency = &Policy_Latency{
+			HighPriority: another.Latency.HighPriority,
+			MaxDelay:     another.Latency.MaxDelay,
+		}
+	}
 }
 
 // ToCorePolicy converts this Policy to policy.Session.
@@ -77,6 +88,12 @@ func (p *Policy) ToCorePolicy() policy.Session {
 	if p.Buffer != nil {
 		cp.Buffer.PerConnection = p.Buffer.Connection
 	}
+	if p.Latency != nil {
+		cp.Latency.HighPriority = p.Latency.HighPriority
+		if p.Latency.MaxDelay > 0 {
+			cp.Latency.MaxDelay = time.Millisecond * time.Duration(p.Latency.MaxDelay)
+		}
+	}
 	return cp
 }
 
@@ -88,6 +105,9 @@ func (p *SystemPolicy) ToCorePolicy() policy.System {
 			InboundDownlink:  p.Stats.InboundDownlink,
 			OutboundUplink:   p.Stats.OutboundUplink,
 			OutboundDownlink: p.Stats.OutboundDownlink,
+
+			InboundConnections:  p.Stats.InboundConnections,
+			OutboundConnections: p.Stats.OutboundConnections,
 		},
 	}
 }
@@ -2,22 +2,27 @@ package policy
 
 import (
 	"context"
+	"sync"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/features/policy"
+	"google.golang.org/protobuf/proto"
 )
 
 // Instance is an instance of Policy manager.
 type Instance struct {
-	levels map[uint32]*Policy
-	system *SystemPolicy
+	access   sync.RWMutex
+	levels   map[uint32]*Policy
+	inbounds map[string]*Policy
+	system   *SystemPolicy
 }
 
 // New creates new Policy manager instance.
 func New(ctx context.Context, config *Config) (*Instance, error) {
 	m := &Instance{
-		levels: make(map[uint32]*Policy),
-		system: config.System,
+		levels:   make(map[uint32]*Policy),
+		inbounds: make(map[string]*Policy),
+		system:   config.System,
 	}
 	if len(config.Level) > 0 {
 		for lv, p := range config.Level {
@@ -37,12 +42,73 @@ func (*Instance) Type() interface{} {
 
 // ForLevel implements policy.Manager.
 func (m *Instance) ForLevel(level uint32) policy.Session {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
 	if p, ok := m.levels[level]; ok {
 		return p.ToCorePolicy()
 	}
 	return policy.SessionDefault()
 }
 
+// ForInbound implements policy.InboundManager.
+func (m *Instance) ForInbound(tag string, level uint32) policy.Session {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	o, found := m.inbounds[tag]
+	if !found {
+		if p, ok := m.levels[level]; ok {
+			return p.ToCorePolicy()
+		}
+		return policy.SessionDefault()
+	}
+	p := m.getPolicy(level)
+	p.overrideWith(o)
+	return p.ToCorePolicy()
+}
+
+// getPolicy returns a copy of the policy for the given level. Caller must hold the lock.
+func (m *Instance) getPolicy(level uint32) *Policy {
+	if p, ok := m.levels[level]; ok {
+		return proto.Clone(p).(*Policy)
+	}
+	return defaultPolicy()
+}
+
+// GetPolicy returns a copy of the effective policy for the given level.
+func (m *Instance) GetPolicy(level uint32) *Policy {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	return m.getPolicy(level)
+}
+
+// UpdatePolicy merges the fields set in p into the policy for the given level, and returns the result.
+// Sessions that already took their policy are not affected.
+func (m *Instance) UpdatePolicy(level uint32, p *Policy) *Policy {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	pp := m.getPolicy(level)
+	pp.overrideWith(p)
+	m.levels[level] = pp
+	return proto.Clone(pp).(*Policy)
+}
+
+// SetInboundOverride sets the policy fields that override level policies for the inbound handler with the given tag.
+// A nil p removes the override.
+func (m *Instance) SetInboundOverride(tag string, p *Policy) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if p == nil {
+		delete(m.inbounds, tag)
+		return
+	}
+	m.inbounds[tag] = proto.Clone(p).(*Policy)
+}
+
 // ForSystem implements policy.Manager.
 func (m *Instance) ForSystem() policy.System {
 	if m.system == nil {
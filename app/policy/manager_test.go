@@ -7,6 +7,7 @@ import (
 
 	. "github.com/xtls/xray-core/app/policy"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/policy"
 )
 
@@ -43,3 +44,93 @@ func TestPolicy(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdatePolicy(t *testing.T) {
+	manager, err := New(context.Background(), &Config{
+		Level: map[uint32]*Policy{
+			0: {
+				Timeout: &Policy_Timeout{
+					ConnectionIdle: &Second{Value: 100},
+				},
+			},
+		},
+	})
+	common.Must(err)
+
+	existing := manager.ForLevel(0)
+
+	p := manager.UpdatePolicy(0, &Policy{
+		Timeout: &Policy_Timeout{
+			ConnectionIdle: &Second{Value: 20},
+		},
+	})
+	if p.Timeout.ConnectionIdle.Value != 20 {
+		t.Error("expect updated policy with 20 sec idle timeout, but got ", p.Timeout.ConnectionIdle.Value)
+	}
+
+	if existing.Timeouts.ConnectionIdle != 100*time.Second {
+		t.Error("existing session changed to ", existing.Timeouts.ConnectionIdle)
+	}
+
+	current := manager.ForLevel(0)
+	if current.Timeouts.ConnectionIdle != 20*time.Second {
+		t.Error("expect 20 sec idle timeout for new session, but got ", current.Timeouts.ConnectionIdle)
+	}
+	if current.Timeouts.Handshake != policy.SessionDefault().Timeouts.Handshake {
+		t.Error("unchanged field modified: ", current.Timeouts.Handshake)
+	}
+
+	if v := manager.GetPolicy(0).Timeout.ConnectionIdle.Value; v != 20 {
+		t.Error("expect GetPolicy to return 20, but got ", v)
+	}
+
+	// Updating a level without policy starts from the default one.
+	manager.UpdatePolicy(3, &Policy{
+		Timeout: &Policy_Timeout{
+			Handshake: &Second{Value: 5},
+		},
+	})
+	p3 := manager.ForLevel(3)
+	if p3.Timeouts.Handshake != 5*time.Second || p3.Timeouts.ConnectionIdle != policy.SessionDefault().Timeouts.ConnectionIdle {
+		t.Error("unexpected level 3 policy: ", p3.Timeouts)
+	}
+}
+
+func TestInboundOverride(t *testing.T) {
+	manager, err := New(context.Background(), &Config{
+		Level: map[uint32]*Policy{
+			1: {
+				Timeout: &Policy_Timeout{
+					Handshake:      &Second{Value: 4},
+					ConnectionIdle: &Second{Value: 100},
+				},
+			},
+		},
+	})
+	common.Must(err)
+
+	manager.SetInboundOverride("in", &Policy{
+		Timeout: &Policy_Timeout{
+			ConnectionIdle: &Second{Value: 10},
+		},
+	})
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{Tag: "in"})
+	p := policy.ForContext(ctx, manager, 1)
+	if p.Timeouts.ConnectionIdle != 10*time.Second {
+		t.Error("expect 10 sec idle timeout from override, but got ", p.Timeouts.ConnectionIdle)
+	}
+	if p.Timeouts.Handshake != 4*time.Second {
+		t.Error("expect 4 sec handshake timeout from level, but got ", p.Timeouts.Handshake)
+	}
+
+	other := session.ContextWithInbound(context.Background(), &session.Inbound{Tag: "other"})
+	if p := policy.ForContext(other, manager, 1); p.Timeouts.ConnectionIdle != 100*time.Second {
+		t.Error("expect level policy for other inbound, but got ", p.Timeouts.ConnectionIdle)
+	}
+
+	manager.SetInboundOverride("in", nil)
+	if p := policy.ForContext(ctx, manager, 1); p.Timeouts.ConnectionIdle != 100*time.Second {
+		t.Error("expect override removed, but got ", p.Timeouts.ConnectionIdle)
+	}
+}
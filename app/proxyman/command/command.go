@@ -2,11 +2,14 @@ package command
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/proxy"
 	grpc "google.golang.org/grpc"
 )
@@ -62,9 +65,12 @@ func (op *RemoveUserOperation) ApplyInbound(ctx context.Context, handler inbound
 }
 
 type handlerServer struct {
-	s   *core.Instance
-	ihm inbound.Manager
-	ohm outbound.Manager
+	s          *core.Instance
+	ihm        inbound.Manager
+	ohm        outbound.Manager
+	router     routing.Router
+	dispatcher routing.Dispatcher
+	probing    atomic.Int32
 }
 
 func (s *handlerServer) AddInbound(ctx context.Context, request *AddInboundRequest) (*AddInboundResponse, error) {
@@ -98,12 +104,40 @@ func (s *handlerServer) AlterInbound(ctx context.Context, request *AlterInboundR
 }
 
 func (s *handlerServer) AddOutbound(ctx context.Context, request *AddOutboundRequest) (*AddOutboundResponse, error) {
+	if err := s.checkIdleExpiry(request); err != nil {
+		return nil, err
+	}
 	if err := core.AddOutboundHandler(s.s, request.Outbound); err != nil {
 		return nil, err
 	}
+	if err := s.setIdleExpiry(request); err != nil {
+		s.ohm.RemoveHandler(ctx, request.Outbound.Tag)
+		return nil, err
+	}
 	return &AddOutboundResponse{}, nil
 }
 
+// checkIdleExpiry validates the expireAfterIdle option of a request before the outbound is added.
+func (s *handlerServer) checkIdleExpiry(request *AddOutboundRequest) error {
+	if request.ExpireAfterIdle == 0 {
+		return nil
+	}
+	if len(request.GetOutbound().GetTag()) == 0 {
+		return newError("outbound expiring after idle must have a tag")
+	}
+	if _, ok := s.ohm.(outbound.IdleExpirer); !ok {
+		return newError("outbound manager doesn't support expireAfterIdle")
+	}
+	return nil
+}
+
+func (s *handlerServer) setIdleExpiry(request *AddOutboundRequest) error {
+	if request.ExpireAfterIdle == 0 {
+		return nil
+	}
+	return s.ohm.(outbound.IdleExpirer).ExpireAfterIdle(request.Outbound.Tag, time.Duration(request.ExpireAfterIdle)*time.Second)
+}
+
 func (s *handlerServer) RemoveOutbound(ctx context.Context, request *RemoveOutboundRequest) (*RemoveOutboundResponse, error) {
 	return &RemoveOutboundResponse{}, s.ohm.RemoveHandler(ctx, request.Tag)
 }
@@ -122,6 +156,154 @@ func (s *handlerServer) AlterOutbound(ctx context.Context, request *AlterOutboun
 	return &AlterOutboundResponse{}, operation.ApplyOutbound(ctx, handler)
 }
 
+func (s *handlerServer) ListOutbounds(ctx context.Context, request *ListOutboundsRequest) (*ListOutboundsResponse, error) {
+	lister, ok := s.ohm.(outbound.HandlerLister)
+	if !ok {
+		return nil, newError("outbound manager doesn't support listing handlers")
+	}
+	expirer, _ := s.ohm.(outbound.IdleExpirer)
+
+	response := &ListOutboundsResponse{}
+	for _, handler := range lister.ListHandlers() {
+		status := &OutboundStatus{
+			Tag: handler.Tag(),
+		}
+		if reporter, ok := handler.(outbound.UsageReporter); ok {
+			if lastUsed := reporter.LastUsed(); !lastUsed.IsZero() {
+				status.LastUsed = lastUsed.Unix()
+			}
+			status.SessionCount = reporter.SessionCount()
+		}
+		if expirer != nil {
+			status.ExpireAfterIdle = uint32(expirer.IdleExpiry(handler.Tag()) / time.Second)
+		}
+		response.Outbounds = append(response.Outbounds, status)
+	}
+	return response, nil
+}
+
+func (s *handlerServer) ListConnections(ctx context.Context, request *ListConnectionsRequest) (*ListConnectionsResponse, error) {
+	lister, ok := s.ohm.(outbound.SessionLister)
+	if !ok {
+		return nil, newError("outbound manager doesn't support listing sessions")
+	}
+
+	response := &ListConnectionsResponse{}
+	for _, info := range lister.ListSessions() {
+		if request.OutboundTag != "" && info.OutboundTag != request.OutboundTag {
+			continue
+		}
+		connection := &Connection{
+			Id:          info.ID,
+			InboundTag:  info.InboundTag,
+			OutboundTag: info.OutboundTag,
+			StartTime:   info.Start.Unix(),
+		}
+		if info.Source.IsValid() {
+			connection.Source = info.Source.String()
+		}
+		if info.Target.IsValid() {
+			connection.Target = info.Target.String()
+		}
+		switch info.State {
+		case outbound.SessionEstablished:
+			connection.State = ConnectionState_ESTABLISHED
+		case outbound.SessionDraining:
+			connection.State = ConnectionState_DRAINING
+		}
+		response.Connections = append(response.Connections, connection)
+	}
+	return response, nil
+}
+
+func (s *handlerServer) getSelector(tag string) (proxy.Selector, error) {
+	handler := s.ohm.GetHandler(tag)
+	if handler == nil {
+		return nil, newError("outbound ", tag, " not found")
+	}
+	gi, ok := handler.(proxy.GetOutbound)
+	if !ok {
+		return nil, newError("can't get outbound proxy from handler.")
+	}
+	selector, ok := gi.GetOutbound().(proxy.Selector)
+	if !ok {
+		return nil, newError("outbound ", tag, " is not a selector")
+	}
+	return selector, nil
+}
+
+func (s *handlerServer) SetSelected(ctx context.Context, request *SetSelectedRequest) (*SetSelectedResponse, error) {
+	selector, err := s.getSelector(request.SelectorTag)
+	if err != nil {
+		return nil, err
+	}
+	if err := selector.Select(request.OutboundTag); err != nil {
+		return nil, err
+	}
+	return &SetSelectedResponse{}, nil
+}
+
+func (s *handlerServer) GetSelected(ctx context.Context, request *GetSelectedRequest) (*GetSelectedResponse, error) {
+	selector, err := s.getSelector(request.SelectorTag)
+	if err != nil {
+		return nil, err
+	}
+	selected, candidates := selector.Selected()
+	return &GetSelectedResponse{
+		OutboundTag: selected,
+		Candidates:  candidates,
+	}, nil
+}
+
+func (s *handlerServer) getPeerReporter(ctx context.Context, tag string) (proxy.PeerReporter, error) {
+	var p interface{}
+	if handler := s.ohm.GetHandler(tag); handler != nil {
+		gi, ok := handler.(proxy.GetOutbound)
+		if !ok {
+			return nil, newError("can't get outbound proxy from handler.")
+		}
+		p = gi.GetOutbound()
+	} else {
+		handler, err := s.ihm.GetHandler(ctx, tag)
+		if err != nil {
+			return nil, newError("no inbound or outbound ", tag).Base(err)
+		}
+		if p, err = getInbound(handler); err != nil {
+			return nil, err
+		}
+	}
+	reporter, ok := p.(proxy.PeerReporter)
+	if !ok {
+		return nil, newError(tag, " is not a wireguard inbound or outbound")
+	}
+	return reporter, nil
+}
+
+func (s *handlerServer) GetWireguardPeers(ctx context.Context, request *GetWireguardPeersRequest) (*GetWireguardPeersResponse, error) {
+	reporter, err := s.getPeerReporter(ctx, request.Tag)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := reporter.Peers()
+	if err != nil {
+		return nil, err
+	}
+	response := &GetWireguardPeersResponse{}
+	for _, peer := range peers {
+		status := &WireguardPeer{
+			PublicKey: peer.PublicKey,
+			Endpoint:  peer.Endpoint,
+			RxBytes:   peer.RxBytes,
+			TxBytes:   peer.TxBytes,
+		}
+		if !peer.LastHandshake.IsZero() {
+			status.LastHandshake = peer.LastHandshake.Unix()
+		}
+		response.Peers = append(response.Peers, status)
+	}
+	return response, nil
+}
+
 func (s *handlerServer) mustEmbedUnimplementedHandlerServiceServer() {}
 
 type service struct {
@@ -132,9 +314,11 @@ func (s *service) Register(server *grpc.Server) {
 	hs := &handlerServer{
 		s: s.v,
 	}
-	common.Must(s.v.RequireFeatures(func(im inbound.Manager, om outbound.Manager) {
+	common.Must(s.v.RequireFeatures(func(im inbound.Manager, om outbound.Manager, r routing.Router, d routing.Dispatcher) {
 		hs.ihm = im
 		hs.ohm = om
+		hs.router = r
+		hs.dispatcher = d
 	}))
 	RegisterHandlerServiceServer(server, hs)
 
@@ -19,12 +19,19 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	HandlerService_AddInbound_FullMethodName     = "/xray.app.proxyman.command.HandlerService/AddInbound"
-	HandlerService_RemoveInbound_FullMethodName  = "/xray.app.proxyman.command.HandlerService/RemoveInbound"
-	HandlerService_AlterInbound_FullMethodName   = "/xray.app.proxyman.command.HandlerService/AlterInbound"
-	HandlerService_AddOutbound_FullMethodName    = "/xray.app.proxyman.command.HandlerService/AddOutbound"
-	HandlerService_RemoveOutbound_FullMethodName = "/xray.app.proxyman.command.HandlerService/RemoveOutbound"
-	HandlerService_AlterOutbound_FullMethodName  = "/xray.app.proxyman.command.HandlerService/AlterOutbound"
+	HandlerService_AddInbound_FullMethodName        = "/xray.app.proxyman.command.HandlerService/AddInbound"
+	HandlerService_RemoveInbound_FullMethodName     = "/xray.app.proxyman.command.HandlerService/RemoveInbound"
+	HandlerService_AlterInbound_FullMethodName      = "/xray.app.proxyman.command.HandlerService/AlterInbound"
+	HandlerService_AddOutbound_FullMethodName       = "/xray.app.proxyman.command.HandlerService/AddOutbound"
+	HandlerService_RemoveOutbound_FullMethodName    = "/xray.app.proxyman.command.HandlerService/RemoveOutbound"
+	HandlerService_AlterOutbound_FullMethodName     = "/xray.app.proxyman.command.HandlerService/AlterOutbound"
+	HandlerService_ListOutbounds_FullMethodName     = "/xray.app.proxyman.command.HandlerService/ListOutbounds"
+	HandlerService_ProbeOutbound_FullMethodName     = "/xray.app.proxyman.command.HandlerService/ProbeOutbound"
+	HandlerService_SetSelected_FullMethodName       = "/xray.app.proxyman.command.HandlerService/SetSelected"
+	HandlerService_GetSelected_FullMethodName       = "/xray.app.proxyman.command.HandlerService/GetSelected"
+	HandlerService_Transaction_FullMethodName       = "/xray.app.proxyman.command.HandlerService/Transaction"
+	HandlerService_GetWireguardPeers_FullMethodName = "/xray.app.proxyman.command.HandlerService/GetWireguardPeers"
+	HandlerService_ListConnections_FullMethodName   = "/xray.app.proxyman.command.HandlerService/ListConnections"
 )
 
 // HandlerServiceClient is the client API for HandlerService service.
@@ -37,6 +44,13 @@ type HandlerServiceClient interface {
 	AddOutbound(ctx context.Context, in *AddOutboundRequest, opts ...grpc.CallOption) (*AddOutboundResponse, error)
 	RemoveOutbound(ctx context.Context, in *RemoveOutboundRequest, opts ...grpc.CallOption) (*RemoveOutboundResponse, error)
 	AlterOutbound(ctx context.Context, in *AlterOutboundRequest, opts ...grpc.CallOption) (*AlterOutboundResponse, error)
+	ListOutbounds(ctx context.Context, in *ListOutboundsRequest, opts ...grpc.CallOption) (*ListOutboundsResponse, error)
+	ProbeOutbound(ctx context.Context, in *ProbeOutboundRequest, opts ...grpc.CallOption) (*ProbeOutboundResponse, error)
+	SetSelected(ctx context.Context, in *SetSelectedRequest, opts ...grpc.CallOption) (*SetSelectedResponse, error)
+	GetSelected(ctx context.Context, in *GetSelectedRequest, opts ...grpc.CallOption) (*GetSelectedResponse, error)
+	Transaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+	GetWireguardPeers(ctx context.Context, in *GetWireguardPeersRequest, opts ...grpc.CallOption) (*GetWireguardPeersResponse, error)
+	ListConnections(ctx context.Context, in *ListConnectionsRequest, opts ...grpc.CallOption) (*ListConnectionsResponse, error)
 }
 
 type handlerServiceClient struct {
@@ -101,6 +115,69 @@ func (c *handlerServiceClient) AlterOutbound(ctx context.Context, in *AlterOutbo
 	return out, nil
 }
 
+func (c *handlerServiceClient) ListOutbounds(ctx context.Context, in *ListOutboundsRequest, opts ...grpc.CallOption) (*ListOutboundsResponse, error) {
+	out := new(ListOutboundsResponse)
+	err := c.cc.Invoke(ctx, HandlerService_ListOutbounds_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlerServiceClient) ProbeOutbound(ctx context.Context, in *ProbeOutboundRequest, opts ...grpc.CallOption) (*ProbeOutboundResponse, error) {
+	out := new(ProbeOutboundResponse)
+	err := c.cc.Invoke(ctx, HandlerService_ProbeOutbound_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlerServiceClient) SetSelected(ctx context.Context, in *SetSelectedRequest, opts ...grpc.CallOption) (*SetSelectedResponse, error) {
+	out := new(SetSelectedResponse)
+	err := c.cc.Invoke(ctx, HandlerService_SetSelected_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlerServiceClient) GetSelected(ctx context.Context, in *GetSelectedRequest, opts ...grpc.CallOption) (*GetSelectedResponse, error) {
+	out := new(GetSelectedResponse)
+	err := c.cc.Invoke(ctx, HandlerService_GetSelected_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlerServiceClient) Transaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error) {
+	out := new(TransactionResponse)
+	err := c.cc.Invoke(ctx, HandlerService_Transaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlerServiceClient) GetWireguardPeers(ctx context.Context, in *GetWireguardPeersRequest, opts ...grpc.CallOption) (*GetWireguardPeersResponse, error) {
+	out := new(GetWireguardPeersResponse)
+	err := c.cc.Invoke(ctx, HandlerService_GetWireguardPeers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *handlerServiceClient) ListConnections(ctx context.Context, in *ListConnectionsRequest, opts ...grpc.CallOption) (*ListConnectionsResponse, error) {
+	out := new(ListConnectionsResponse)
+	err := c.cc.Invoke(ctx, HandlerService_ListConnections_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HandlerServiceServer is the server API for HandlerService service.
 // All implementations must embed UnimplementedHandlerServiceServer
 // for forward compatibility
@@ -111,6 +188,13 @@ type HandlerServiceServer interface {
 	AddOutbound(context.Context, *AddOutboundRequest) (*AddOutboundResponse, error)
 	RemoveOutbound(context.Context, *RemoveOutboundRequest) (*RemoveOutboundResponse, error)
 	AlterOutbound(context.Context, *AlterOutboundRequest) (*AlterOutboundResponse, error)
+	ListOutbounds(context.Context, *ListOutboundsRequest) (*ListOutboundsResponse, error)
+	ProbeOutbound(context.Context, *ProbeOutboundRequest) (*ProbeOutboundResponse, error)
+	SetSelected(context.Context, *SetSelectedRequest) (*SetSelectedResponse, error)
+	GetSelected(context.Context, *GetSelectedRequest) (*GetSelectedResponse, error)
+	Transaction(context.Context, *TransactionRequest) (*TransactionResponse, error)
+	GetWireguardPeers(context.Context, *GetWireguardPeersRequest) (*GetWireguardPeersResponse, error)
+	ListConnections(context.Context, *ListConnectionsRequest) (*ListConnectionsResponse, error)
 	mustEmbedUnimplementedHandlerServiceServer()
 }
 
@@ -136,6 +220,28 @@ func (UnimplementedHandlerServiceServer) RemoveOutbound(context.Context, *Remove
 func (UnimplementedHandlerServiceServer) AlterOutbound(context.Context, *AlterOutboundRequest) (*AlterOutboundResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AlterOutbound not implemented")
 }
+func (UnimplementedHandlerServiceServer) ListOutbounds(context.Context, *ListOutboundsRequest) (*ListOutboundsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListOutbounds not implemented")
+}
+
+func (UnimplementedHandlerServiceServer) ProbeOutbound(context.Context, *ProbeOutboundRequest) (*ProbeOutboundResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProbeOutbound not implemented")
+}
+func (UnimplementedHandlerServiceServer) SetSelected(context.Context, *SetSelectedRequest) (*SetSelectedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSelected not implemented")
+}
+func (UnimplementedHandlerServiceServer) GetSelected(context.Context, *GetSelectedRequest) (*GetSelectedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSelected not implemented")
+}
+func (UnimplementedHandlerServiceServer) Transaction(context.Context, *TransactionRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transaction not implemented")
+}
+func (UnimplementedHandlerServiceServer) GetWireguardPeers(context.Context, *GetWireguardPeersRequest) (*GetWireguardPeersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWireguardPeers not implemented")
+}
+func (UnimplementedHandlerServiceServer) ListConnections(context.Context, *ListConnectionsRequest) (*ListConnectionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConnections not implemented")
+}
 func (UnimplementedHandlerServiceServer) mustEmbedUnimplementedHandlerServiceServer() {}
 
 // UnsafeHandlerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -257,6 +363,132 @@ func _HandlerService_AlterOutbound_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HandlerService_ListOutbounds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOutboundsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).ListOutbounds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_ListOutbounds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).ListOutbounds(ctx, req.(*ListOutboundsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HandlerService_ProbeOutbound_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProbeOutboundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).ProbeOutbound(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_ProbeOutbound_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).ProbeOutbound(ctx, req.(*ProbeOutboundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HandlerService_SetSelected_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSelectedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).SetSelected(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_SetSelected_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).SetSelected(ctx, req.(*SetSelectedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HandlerService_GetSelected_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSelectedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).GetSelected(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_GetSelected_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).GetSelected(ctx, req.(*GetSelectedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HandlerService_Transaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).Transaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_Transaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).Transaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HandlerService_GetWireguardPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWireguardPeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).GetWireguardPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_GetWireguardPeers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).GetWireguardPeers(ctx, req.(*GetWireguardPeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HandlerService_ListConnections_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConnectionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).ListConnections(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_ListConnections_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).ListConnections(ctx, req.(*ListConnectionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // HandlerService_ServiceDesc is the grpc.ServiceDesc for HandlerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -288,6 +520,34 @@ var HandlerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AlterOutbound",
 			Handler:    _HandlerService_AlterOutbound_Handler,
 		},
+		{
+			MethodName: "ListOutbounds",
+			Handler:    _HandlerService_ListOutbounds_Handler,
+		},
+		{
+			MethodName: "ProbeOutbound",
+			Handler:    _HandlerService_ProbeOutbound_Handler,
+		},
+		{
+			MethodName: "SetSelected",
+			Handler:    _HandlerService_SetSelected_Handler,
+		},
+		{
+			MethodName: "GetSelected",
+			Handler:    _HandlerService_GetSelected_Handler,
+		},
+		{
+			MethodName: "Transaction",
+			Handler:    _HandlerService_Transaction_Handler,
+		},
+		{
+			MethodName: "GetWireguardPeers",
+			Handler:    _HandlerService_GetWireguardPeers_Handler,
+		},
+		{
+			MethodName: "ListConnections",
+			Handler:    _HandlerService_ListConnections_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "app/proxyman/command/command.proto",
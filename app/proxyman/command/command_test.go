@@ -0,0 +1,133 @@
+package command
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/proxy/freedom"
+)
+
+func TestListOutbounds(t *testing.T) {
+	s, _ := newTransactionServer(t)
+	ctx := context.Background()
+
+	if _, err := s.AddOutbound(ctx, &AddOutboundRequest{
+		Outbound: &core.OutboundHandlerConfig{
+			ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+		},
+		ExpireAfterIdle: 60,
+	}); err == nil {
+		t.Error("expected error for expiring outbound without tag")
+	}
+
+	common.Must2(s.AddOutbound(ctx, &AddOutboundRequest{
+		Outbound: &core.OutboundHandlerConfig{
+			Tag:           "api",
+			ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+		},
+		ExpireAfterIdle: 60,
+	}))
+
+	resp, err := s.ListOutbounds(ctx, &ListOutboundsRequest{})
+	common.Must(err)
+	if len(resp.Outbounds) != 2 {
+		t.Fatal("unexpected outbounds: ", resp.Outbounds)
+	}
+	for _, status := range resp.Outbounds {
+		if status.LastUsed != 0 || status.SessionCount != 0 {
+			t.Error("unused outbound reported as used: ", status)
+		}
+		switch status.Tag {
+		case "api":
+			if status.ExpireAfterIdle != 60 {
+				t.Error("unexpected expireAfterIdle: ", status.ExpireAfterIdle)
+			}
+		case "direct":
+			if status.ExpireAfterIdle != 0 {
+				t.Error("config outbound set to expire: ", status.ExpireAfterIdle)
+			}
+		default:
+			t.Error("unexpected outbound: ", status.Tag)
+		}
+	}
+}
+
+func TestProbeOutbound(t *testing.T) {
+	s, _ := newTransactionServer(t)
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	resp, err := s.ProbeOutbound(ctx, &ProbeOutboundRequest{
+		Tag:     "direct",
+		TestUrl: server.URL,
+	})
+	common.Must(err)
+	if resp.Error != ProbeError_NONE || resp.StatusCode != http.StatusNoContent {
+		t.Fatal("unexpected probe result: ", resp)
+	}
+	if resp.TotalTime < resp.DialTime || resp.HandshakeTime != 0 {
+		t.Error("unexpected probe times: ", resp)
+	}
+	if reporter := s.ohm.GetHandler("direct").(outbound.UsageReporter); reporter.SessionCount() != 0 {
+		t.Error("probe counted in usage without recordStats")
+	}
+
+	common.Must2(s.ProbeOutbound(ctx, &ProbeOutboundRequest{
+		Tag:         "direct",
+		TestUrl:     server.URL,
+		RecordStats: true,
+	}))
+	if reporter := s.ohm.GetHandler("direct").(outbound.UsageReporter); reporter.SessionCount() != 1 {
+		t.Error("probe not counted in usage with recordStats")
+	}
+
+	// nothing listens on a port just released
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	resp, err = s.ProbeOutbound(ctx, &ProbeOutboundRequest{
+		Outbound: &core.OutboundHandlerConfig{
+			Tag: "broken",
+			ProxySettings: serial.ToTypedMessage(&freedom.Config{
+				DestinationOverride: &freedom.DestinationOverride{
+					Server: &protocol.ServerEndpoint{
+						Address: xnet.NewIPOrDomain(xnet.LocalHostIP),
+						Port:    uint32(port),
+					},
+				},
+			}),
+		},
+		TestUrl:   server.URL,
+		TimeoutMs: 10000,
+	})
+	common.Must(err)
+	if resp.Error != ProbeError_DIAL_FAILED || resp.StatusCode != 0 || resp.ErrorMessage == "" {
+		t.Error("unexpected probe result: ", resp)
+	}
+	if s.ohm.GetHandler("broken") != nil {
+		t.Error("probed outbound added to the outbound manager")
+	}
+
+	if _, err := s.ProbeOutbound(ctx, &ProbeOutboundRequest{Tag: "none"}); err == nil {
+		t.Error("expected error for unknown outbound")
+	}
+
+	s.probing.Store(maxConcurrentProbes)
+	if _, err := s.ProbeOutbound(ctx, &ProbeOutboundRequest{Tag: "direct", TestUrl: server.URL}); err == nil {
+		t.Error("expected error with too many probes in progress")
+	}
+}
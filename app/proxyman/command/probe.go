@@ -0,0 +1,207 @@
+package command
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/net/cnc"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultProbeURL     = "https://www.google.com/generate_204"
+	defaultProbeTimeout = 5 * time.Second
+	maxProbeTimeout     = time.Minute
+	maxConcurrentProbes = 4
+)
+
+// ProbeOutbound implements HandlerServiceServer. The probe is dispatched to the
+// outbound directly, so routing rules and the stats of users don't apply.
+func (s *handlerServer) ProbeOutbound(ctx context.Context, request *ProbeOutboundRequest) (*ProbeOutboundResponse, error) {
+	if s.probing.Add(1) > maxConcurrentProbes {
+		s.probing.Add(-1)
+		return nil, newError("too many outbound probes in progress")
+	}
+	defer s.probing.Add(-1)
+
+	handler, err := s.probedHandler(request)
+	if err != nil {
+		return nil, err
+	}
+	if request.Outbound != nil {
+		defer common.Close(handler)
+	}
+
+	timeout := defaultProbeTimeout
+	if request.TimeoutMs > 0 {
+		timeout = time.Duration(request.TimeoutMs) * time.Millisecond
+		if timeout > maxProbeTimeout {
+			timeout = maxProbeTimeout
+		}
+	}
+	testURL := request.TestUrl
+	if testURL == "" {
+		testURL = defaultProbeURL
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return probeOutbound(ctx, handler, testURL, request.RecordStats), nil
+}
+
+// probedHandler returns the outbound handler a probe goes through. Handlers
+// created from an inline config are never added to the outbound manager.
+func (s *handlerServer) probedHandler(request *ProbeOutboundRequest) (outbound.Handler, error) {
+	if request.Outbound == nil {
+		if request.Tag == "" {
+			return nil, newError("no outbound to probe")
+		}
+		handler := s.ohm.GetHandler(request.Tag)
+		if handler == nil {
+			return nil, newError("outbound not found: ", request.Tag)
+		}
+		return handler, nil
+	}
+
+	config := request.Outbound
+	if !request.RecordStats && config.Tag != "" {
+		// stats counters are named after the tag
+		config = proto.Clone(config).(*core.OutboundHandlerConfig)
+		config.Tag = ""
+	}
+	rawHandler, err := core.CreateObject(s.s, config)
+	if err != nil {
+		return nil, newError("failed to create outbound").Base(err)
+	}
+	handler, ok := rawHandler.(outbound.Handler)
+	if !ok {
+		common.Close(rawHandler)
+		return nil, newError("not an outbound handler")
+	}
+	return handler, nil
+}
+
+// probeErrors keeps the first error the outbound reports.
+type probeErrors struct {
+	sync.Mutex
+	err error
+}
+
+func (e *probeErrors) SubmitError(err error) {
+	e.Lock()
+	defer e.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *probeErrors) get() error {
+	e.Lock()
+	defer e.Unlock()
+	return e.err
+}
+
+func probeOutbound(ctx context.Context, handler outbound.Handler, testURL string, recordStats bool) *ProbeOutboundResponse {
+	start := time.Now()
+	var dialed atomic.Int64
+	outboundErrors := new(probeErrors)
+	probe := &session.Probe{
+		RecordStats: recordStats,
+		Dialed: func() {
+			dialed.CompareAndSwap(0, int64(time.Since(start)))
+		},
+	}
+
+	// the handshake may still be going on in the background when the request
+	// times out
+	var handshakeStart, handshakeDone atomic.Int64
+	var handshakeFailed atomic.Bool
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			handshakeStart.Store(int64(time.Since(start)))
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			handshakeDone.Store(int64(time.Since(start)))
+			handshakeFailed.Store(err != nil)
+		},
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: func(*http.Request) (*url.URL, error) {
+				return nil, nil
+			},
+			DisableKeepAlives: true,
+			DialContext: func(_ context.Context, network, addr string) (net.Conn, error) {
+				dest, err := net.ParseDestination(network + ":" + addr)
+				if err != nil {
+					return nil, newError("cannot understand address").Base(err)
+				}
+				ctx := session.ContextWithOutbounds(ctx, []*session.Outbound{{
+					Target: dest,
+					Tag:    handler.Tag(),
+				}})
+				ctx = session.ContextWithProbe(ctx, probe)
+				ctx = session.TrackedConnectionError(ctx, outboundErrors)
+
+				opts := pipe.OptionsFromContext(ctx)
+				uplinkReader, uplinkWriter := pipe.New(opts...)
+				downlinkReader, downlinkWriter := pipe.New(opts...)
+				go handler.Dispatch(ctx, &transport.Link{Reader: uplinkReader, Writer: downlinkWriter})
+				return cnc.NewConnection(
+					cnc.ConnectionInputMulti(uplinkWriter),
+					cnc.ConnectionOutputMulti(downlinkReader),
+					cnc.ConnectionOnClose(common.ChainedClosable{uplinkWriter, downlinkWriter}),
+				), nil
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	response := &ProbeOutboundResponse{}
+	request, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, testURL, nil)
+	if err != nil {
+		response.Error = ProbeError_REQUEST_FAILED
+		response.ErrorMessage = newError("invalid test URL ", testURL).Base(err).Error()
+		return response
+	}
+	resp, err := client.Do(request)
+	total := time.Since(start)
+	response.DialTime = time.Duration(dialed.Load()).Milliseconds()
+	if done := handshakeDone.Load(); done != 0 && !handshakeFailed.Load() {
+		response.HandshakeTime = time.Duration(done - handshakeStart.Load()).Milliseconds()
+	}
+	if err != nil {
+		outboundErr := outboundErrors.get()
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			response.Error = ProbeError_TIMEOUT
+		case outboundErr != nil && dialed.Load() == 0:
+			response.Error = ProbeError_DIAL_FAILED
+		case handshakeStart.Load() != 0 && (handshakeDone.Load() == 0 || handshakeFailed.Load()):
+			response.Error = ProbeError_HANDSHAKE_FAILED
+		default:
+			response.Error = ProbeError_REQUEST_FAILED
+		}
+		response.ErrorMessage = newError("failed to GET ", testURL, ": ", err).Base(outboundErr).Error()
+		return response
+	}
+	resp.Body.Close()
+	response.StatusCode = int32(resp.StatusCode)
+	response.TotalTime = total.Milliseconds()
+	return response
+}
@@ -0,0 +1,356 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
+)
+
+// transactionStep is a validated operation of a transaction.
+// apply returns a function reverting what it has done.
+type transactionStep struct {
+	name  string
+	apply func() (undo func(), err error)
+	// handler created during validation, closed if the step is never applied
+	handler interface{}
+	// listens is set for steps starting inbound listeners, which are applied
+	// once the changes to routing are committed
+	listens bool
+}
+
+func (s *transactionStep) discard() {
+	if s.handler != nil {
+		common.Close(s.handler)
+	}
+}
+
+type transaction struct {
+	ctx    context.Context
+	server *handlerServer
+
+	// whether a tag is in use once the validated steps are applied
+	outboundTags map[string]bool
+	inboundTags  map[string]bool
+}
+
+func (t *transaction) outboundExists(tag string) bool {
+	if exists, found := t.outboundTags[tag]; found {
+		return exists
+	}
+	return t.server.ohm.GetHandler(tag) != nil
+}
+
+func (t *transaction) inboundExists(tag string) bool {
+	if exists, found := t.inboundTags[tag]; found {
+		return exists
+	}
+	_, err := t.server.ihm.GetHandler(t.ctx, tag)
+	return err == nil
+}
+
+// validate checks an operation against the state left by the previous ones
+// and prepares everything the operation needs, without changing anything.
+func (t *transaction) validate(op *TransactionOperation) (*transactionStep, error) {
+	s := t.server
+	switch op := op.Operation.(type) {
+	case *TransactionOperation_AddInbound:
+		config := op.AddInbound.GetInbound()
+		if config == nil {
+			return nil, newError("inbound is not specified")
+		}
+		if len(config.Tag) == 0 {
+			return nil, newError("inbound added in a transaction must have a tag")
+		}
+		if t.inboundExists(config.Tag) {
+			return nil, newError("existing inbound tag: ", config.Tag)
+		}
+		rawHandler, err := core.CreateObject(s.s, config)
+		if err != nil {
+			return nil, newError("failed to create inbound").Base(err)
+		}
+		handler, ok := rawHandler.(inbound.Handler)
+		if !ok {
+			return nil, newError("not an InboundHandler")
+		}
+		t.inboundTags[config.Tag] = true
+		return &transactionStep{
+			name:    "add inbound " + config.Tag,
+			handler: handler,
+			listens: true,
+			apply: func() (func(), error) {
+				if err := s.ihm.AddHandler(t.ctx, handler); err != nil {
+					// the handler is kept by the manager if only Start failed
+					if h, _ := s.ihm.GetHandler(t.ctx, handler.Tag()); h == handler {
+						s.ihm.RemoveHandler(t.ctx, handler.Tag())
+					} else {
+						common.Close(handler)
+					}
+					return nil, err
+				}
+				return func() {
+					s.ihm.RemoveHandler(t.ctx, handler.Tag())
+				}, nil
+			},
+		}, nil
+
+	case *TransactionOperation_AddOutbound:
+		config := op.AddOutbound.GetOutbound()
+		if config == nil {
+			return nil, newError("outbound is not specified")
+		}
+		if len(config.Tag) == 0 {
+			return nil, newError("outbound added in a transaction must have a tag")
+		}
+		if t.outboundExists(config.Tag) {
+			return nil, newError("existing outbound tag: ", config.Tag)
+		}
+		if err := s.checkIdleExpiry(op.AddOutbound); err != nil {
+			return nil, err
+		}
+		rawHandler, err := core.CreateObject(s.s, config)
+		if err != nil {
+			return nil, newError("failed to create outbound").Base(err)
+		}
+		handler, ok := rawHandler.(outbound.Handler)
+		if !ok {
+			return nil, newError("not an OutboundHandler")
+		}
+		t.outboundTags[config.Tag] = true
+		return &transactionStep{
+			name:    "add outbound " + config.Tag,
+			handler: handler,
+			apply: func() (func(), error) {
+				if err := s.ohm.AddHandler(t.ctx, handler); err != nil {
+					if s.ohm.GetHandler(handler.Tag()) == handler {
+						s.ohm.RemoveHandler(t.ctx, handler.Tag())
+					}
+					common.Close(handler)
+					return nil, err
+				}
+				if err := s.setIdleExpiry(op.AddOutbound); err != nil {
+					s.ohm.RemoveHandler(t.ctx, handler.Tag())
+					common.Close(handler)
+					return nil, err
+				}
+				return func() {
+					s.ohm.RemoveHandler(t.ctx, handler.Tag())
+					common.Close(handler)
+				}, nil
+			},
+		}, nil
+
+	case *TransactionOperation_RemoveOutbound:
+		tag := op.RemoveOutbound.GetTag()
+		if !t.outboundExists(tag) {
+			return nil, newError("outbound not found: ", tag)
+		}
+		t.outboundTags[tag] = false
+		return &transactionStep{
+			name: "remove outbound " + tag,
+			apply: func() (func(), error) {
+				handler := s.ohm.GetHandler(tag)
+				if handler == nil {
+					return nil, newError("outbound not found: ", tag)
+				}
+				// the manager forgets the idle expiry of the handler with it
+				expirer, _ := s.ohm.(outbound.IdleExpirer)
+				var idle time.Duration
+				if expirer != nil {
+					idle = expirer.IdleExpiry(tag)
+				}
+				if err := s.ohm.RemoveHandler(t.ctx, tag); err != nil {
+					return nil, err
+				}
+				return func() {
+					if err := s.ohm.AddHandler(t.ctx, handler); err == nil && idle > 0 {
+						expirer.ExpireAfterIdle(tag, idle)
+					}
+				}, nil
+			},
+		}, nil
+
+	case *TransactionOperation_AddRule:
+		snapshotter, err := t.rulesSnapshotter()
+		if err != nil {
+			return nil, err
+		}
+		config := op.AddRule.GetConfig()
+		if config == nil {
+			return nil, newError("routing config is not specified")
+		}
+		if _, err := config.GetInstance(); err != nil {
+			return nil, newError("unknown routing config").Base(err)
+		}
+		shouldAppend := op.AddRule.GetShouldAppend()
+		return &transactionStep{
+			name: "add rule",
+			apply: func() (func(), error) {
+				snapshot := snapshotter.SnapshotRules()
+				if err := s.router.AddRule(config, shouldAppend); err != nil {
+					// AddRule may stop half way
+					snapshotter.RestoreRules(snapshot)
+					return nil, err
+				}
+				return func() {
+					snapshotter.RestoreRules(snapshot)
+				}, nil
+			},
+		}, nil
+
+	case *TransactionOperation_RemoveRule:
+		snapshotter, err := t.rulesSnapshotter()
+		if err != nil {
+			return nil, err
+		}
+		tag := op.RemoveRule.GetRuleTag()
+		if len(tag) == 0 {
+			return nil, newError("empty rule tag")
+		}
+		return &transactionStep{
+			name: "remove rule " + tag,
+			apply: func() (func(), error) {
+				snapshot := snapshotter.SnapshotRules()
+				if err := s.router.RemoveRule(tag); err != nil {
+					return nil, err
+				}
+				return func() {
+					snapshotter.RestoreRules(snapshot)
+				}, nil
+			},
+		}, nil
+
+	case *TransactionOperation_OverrideBalancer:
+		overrider, ok := s.router.(routing.BalancerOverrider)
+		if !ok {
+			return nil, newError("unsupported router implementation")
+		}
+		tag := op.OverrideBalancer.GetBalancerTag()
+		target := op.OverrideBalancer.GetTarget()
+		return &transactionStep{
+			name: "override balancer " + tag,
+			apply: func() (func(), error) {
+				previous, err := overrider.GetOverrideTarget(tag)
+				if err != nil {
+					return nil, err
+				}
+				if err := overrider.SetOverrideTarget(tag, target); err != nil {
+					return nil, err
+				}
+				return func() {
+					overrider.SetOverrideTarget(tag, previous)
+				}, nil
+			},
+		}, nil
+
+	default:
+		return nil, newError("unknown operation")
+	}
+}
+
+func (t *transaction) rulesSnapshotter() (routing.RulesSnapshotter, error) {
+	if t.server.router == nil {
+		return nil, newError("routing is not available")
+	}
+	snapshotter, ok := t.server.router.(routing.RulesSnapshotter)
+	if !ok {
+		return nil, newError("unsupported router implementation")
+	}
+	return snapshotter, nil
+}
+
+// Transaction applies all operations of the request in order. Nothing is
+// changed unless every operation validates; if an operation fails to apply,
+// the ones applied before it are reverted in reverse order.
+func (s *handlerServer) Transaction(ctx context.Context, request *TransactionRequest) (*TransactionResponse, error) {
+	t := &transaction{
+		ctx:          ctx,
+		server:       s,
+		outboundTags: make(map[string]bool),
+		inboundTags:  make(map[string]bool),
+	}
+
+	steps := make([]*transactionStep, 0, len(request.Operations))
+	for i, op := range request.Operations {
+		step, err := t.validate(op)
+		if err != nil {
+			for _, step := range steps {
+				step.discard()
+			}
+			return nil, newError("invalid operation #", i).Base(err)
+		}
+		steps = append(steps, step)
+	}
+
+	routeSteps := make([]*transactionStep, 0, len(steps))
+	listenSteps := make([]*transactionStep, 0, len(steps))
+	for _, step := range steps {
+		if step.listens {
+			listenSteps = append(listenSteps, step)
+		} else {
+			routeSteps = append(routeSteps, step)
+		}
+	}
+
+	// routing decisions wait until all changes are in place, or reverted
+	locker, _ := s.dispatcher.(routing.RouteLocker)
+	lockRoutes := func() {
+		if locker != nil {
+			locker.LockRoutes()
+		}
+	}
+	unlockRoutes := func() {
+		if locker != nil {
+			locker.UnlockRoutes()
+		}
+	}
+
+	lockRoutes()
+	undos, err := applySteps(routeSteps)
+	unlockRoutes()
+	if err != nil {
+		for _, step := range listenSteps {
+			step.discard()
+		}
+		return nil, err
+	}
+
+	if _, err := applySteps(listenSteps); err != nil {
+		lockRoutes()
+		revertSteps(undos)
+		unlockRoutes()
+		return nil, err
+	}
+
+	return &TransactionResponse{}, nil
+}
+
+// applySteps applies steps in order and returns the functions reverting them.
+// If a step fails, the ones applied before it are reverted, and the ones after
+// it are discarded.
+func applySteps(steps []*transactionStep) ([]func(), error) {
+	undos := make([]func(), 0, len(steps))
+	for i, step := range steps {
+		undo, err := step.apply()
+		if err != nil {
+			revertSteps(undos)
+			for _, step := range steps[i+1:] {
+				step.discard()
+			}
+			return nil, newError("failed to ", step.name, ", transaction rolled back").Base(err)
+		}
+		undos = append(undos, undo)
+	}
+	return undos, nil
+}
+
+// revertSteps calls the undo functions of applied steps in reverse order.
+func revertSteps(undos []func()) {
+	for i := len(undos) - 1; i >= 0; i-- {
+		undos[i]()
+	}
+}
@@ -0,0 +1,239 @@
+package command
+
+import (
+	"context"
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/app/proxyman"
+	_ "github.com/xtls/xray-core/app/proxyman/inbound"
+	_ "github.com/xtls/xray-core/app/proxyman/outbound"
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy/dokodemo"
+	"github.com/xtls/xray-core/proxy/freedom"
+	_ "github.com/xtls/xray-core/transport/internet/tcp"
+)
+
+func newTransactionServer(t *testing.T) (*handlerServer, *router.Router) {
+	v, err := core.New(&core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&router.Config{
+				Rule: []*router.RoutingRule{
+					{
+						RuleTag:    "r1",
+						InboundTag: []string{"in"},
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "direct",
+						},
+					},
+				},
+				BalancingRule: []*router.BalancingRule{
+					{
+						Tag:              "b",
+						OutboundSelector: []string{"direct"},
+					},
+				},
+			}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				Tag:           "direct",
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	})
+	common.Must(err)
+	common.Must(v.Start())
+	t.Cleanup(func() { v.Close() })
+
+	r := v.GetFeature(routing.RouterType()).(*router.Router)
+	return &handlerServer{
+		s:          v,
+		ihm:        v.GetFeature(inbound.ManagerType()).(inbound.Manager),
+		ohm:        v.GetFeature(outbound.ManagerType()).(outbound.Manager),
+		router:     r,
+		dispatcher: v.GetFeature(routing.DispatcherType()).(routing.Dispatcher),
+	}, r
+}
+
+func transactionOperations(last *TransactionOperation) []*TransactionOperation {
+	return []*TransactionOperation{
+		{Operation: &TransactionOperation_AddOutbound{AddOutbound: &AddOutboundRequest{
+			Outbound: &core.OutboundHandlerConfig{
+				Tag:           "new",
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		}}},
+		{Operation: &TransactionOperation_AddRule{AddRule: &AddRuleOperation{
+			Config: serial.ToTypedMessage(&router.Config{
+				Rule: []*router.RoutingRule{
+					{
+						RuleTag:    "r2",
+						InboundTag: []string{"in"},
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "new",
+						},
+					},
+				},
+			}),
+			ShouldAppend: true,
+		}}},
+		{Operation: &TransactionOperation_RemoveRule{RemoveRule: &RemoveRuleOperation{
+			RuleTag: "r1",
+		}}},
+		last,
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	hs, r := newTransactionServer(t)
+
+	// fails only when applied, after every other operation took effect
+	_, err := hs.Transaction(context.Background(), &TransactionRequest{
+		Operations: transactionOperations(&TransactionOperation{
+			Operation: &TransactionOperation_OverrideBalancer{OverrideBalancer: &OverrideBalancerOperation{
+				BalancerTag: "missing",
+				Target:      "new",
+			}},
+		}),
+	})
+	if err == nil {
+		t.Fatal("expected transaction to fail")
+	}
+
+	if hs.ohm.GetHandler("new") != nil {
+		t.Error("outbound added by a failed transaction is kept")
+	}
+	if !r.RuleExists("r1") {
+		t.Error("rule removed by a failed transaction is lost")
+	}
+	if r.RuleExists("r2") {
+		t.Error("rule added by a failed transaction is kept")
+	}
+	if target, _ := r.GetOverrideTarget("b"); target != "" {
+		t.Error("unexpected balancer target: ", target)
+	}
+}
+
+func TestTransactionRollbackRemoveOutbound(t *testing.T) {
+	hs, _ := newTransactionServer(t)
+	expirer := hs.ohm.(outbound.IdleExpirer)
+	common.Must(expirer.ExpireAfterIdle("direct", time.Minute))
+
+	_, err := hs.Transaction(context.Background(), &TransactionRequest{
+		Operations: []*TransactionOperation{
+			{Operation: &TransactionOperation_RemoveOutbound{RemoveOutbound: &RemoveOutboundRequest{
+				Tag: "direct",
+			}}},
+			{Operation: &TransactionOperation_OverrideBalancer{OverrideBalancer: &OverrideBalancerOperation{
+				BalancerTag: "missing",
+				Target:      "direct",
+			}}},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected transaction to fail")
+	}
+
+	if hs.ohm.GetHandler("direct") == nil {
+		t.Fatal("outbound removed by a failed transaction is lost")
+	}
+	if idle := expirer.IdleExpiry("direct"); idle != time.Minute {
+		t.Error("expect the outbound to expire after ", time.Minute, " idle again, but got ", idle)
+	}
+}
+
+func TestTransactionInvalid(t *testing.T) {
+	hs, r := newTransactionServer(t)
+
+	_, err := hs.Transaction(context.Background(), &TransactionRequest{
+		Operations: transactionOperations(&TransactionOperation{
+			Operation: &TransactionOperation_AddOutbound{AddOutbound: &AddOutboundRequest{
+				Outbound: &core.OutboundHandlerConfig{
+					Tag:           "new",
+					ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+				},
+			}},
+		}),
+	})
+	if err == nil {
+		t.Fatal("expected duplicate tag to be rejected")
+	}
+	if hs.ohm.GetHandler("new") != nil || r.RuleExists("r2") || !r.RuleExists("r1") {
+		t.Error("invalid transaction changed the state")
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	hs, r := newTransactionServer(t)
+
+	common.Must2(hs.Transaction(context.Background(), &TransactionRequest{
+		Operations: transactionOperations(&TransactionOperation{
+			Operation: &TransactionOperation_OverrideBalancer{OverrideBalancer: &OverrideBalancerOperation{
+				BalancerTag: "b",
+				Target:      "new",
+			}},
+		}),
+	}))
+
+	if hs.ohm.GetHandler("new") == nil {
+		t.Error("outbound not added")
+	}
+	if r.RuleExists("r1") || !r.RuleExists("r2") {
+		t.Error("rules not updated")
+	}
+	if target, _ := r.GetOverrideTarget("b"); target != "new" {
+		t.Error("unexpected balancer target: ", target)
+	}
+}
+
+func TestTransactionListenFailure(t *testing.T) {
+	hs, r := newTransactionServer(t)
+
+	// the inbound is started after routing changes are committed, and fails
+	listener, err := gonet.ListenTCP("tcp", &gonet.TCPAddr{IP: gonet.IPv4(127, 0, 0, 1)})
+	common.Must(err)
+	defer listener.Close()
+	port := net.Port(listener.Addr().(*gonet.TCPAddr).Port)
+
+	_, err = hs.Transaction(context.Background(), &TransactionRequest{
+		Operations: transactionOperations(&TransactionOperation{
+			Operation: &TransactionOperation_AddInbound{AddInbound: &AddInboundRequest{
+				Inbound: &core.InboundHandlerConfig{
+					Tag: "in",
+					ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+						PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(port)}},
+						Listen:   net.NewIPOrDomain(net.LocalHostIP),
+					}),
+					ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+						Address:  net.NewIPOrDomain(net.LocalHostIP),
+						Port:     80,
+						Networks: []net.Network{net.Network_TCP},
+					}),
+				},
+			}},
+		}),
+	})
+	if err == nil {
+		t.Fatal("expected transaction to fail")
+	}
+
+	if _, err := hs.ihm.GetHandler(context.Background(), "in"); err == nil {
+		t.Error("inbound of a failed transaction is kept")
+	}
+	if hs.ohm.GetHandler("new") != nil || r.RuleExists("r2") || !r.RuleExists("r1") {
+		t.Error("failed transaction changed the state")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/mux"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/stats"
@@ -41,6 +43,30 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	return uplinkCounter, downlinkCounter
 }
 
+func getConnectionGauge(v *core.Instance, tag string) stats.Counter {
+	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	if len(tag) > 0 && policy.ForSystem().Stats.InboundConnections {
+		statsManager := v.GetFeature(stats.ManagerType()).(stats.Manager)
+		name := "inbound>>>" + tag + ">>>connections"
+		g, err := stats.GetOrRegisterGauge(statsManager, name)
+		if err != nil {
+			newError("failed to register ", name).Base(err).AtWarning().WriteToLog()
+		}
+		return g
+	}
+	return nil
+}
+
+// contextWithTargetRestriction returns ctx with the forbidden targets of receiverConfig, which the workers pass on
+// to the connections they accept.
+func contextWithTargetRestriction(ctx context.Context, receiverConfig *proxyman.ReceiverConfig) (context.Context, error) {
+	r, err := protocol.NewTargetRestriction(receiverConfig.ForbiddenTargets)
+	if err != nil {
+		return nil, newError("failed to parse forbidden targets").Base(err).AtWarning()
+	}
+	return session.ContextWithTargetRestriction(ctx, r), nil
+}
+
 type AlwaysOnInboundHandler struct {
 	proxy   proxy.Inbound
 	workers []worker
@@ -49,6 +75,10 @@ type AlwaysOnInboundHandler struct {
 }
 
 func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *proxyman.ReceiverConfig, proxyConfig interface{}) (*AlwaysOnInboundHandler, error) {
+	ctx, err := contextWithTargetRestriction(ctx, receiverConfig)
+	if err != nil {
+		return nil, err
+	}
 	rawProxy, err := common.CreateObject(ctx, proxyConfig)
 	if err != nil {
 		return nil, err
@@ -65,6 +95,7 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 	}
 
 	uplinkCounter, downlinkCounter := getStatCounter(core.MustFromContext(ctx), tag)
+	connectionGauge := getConnectionGauge(core.MustFromContext(ctx), tag)
 
 	nl := p.Network()
 	pl := receiverConfig.PortList
@@ -87,6 +118,18 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 		}
 		mss.SocketSettings.ReceiveOriginalDestAddress = true
 	}
+	if dp, ok := p.(proxy.DeviceInbound); ok {
+		newError("creating device worker for ", tag).AtDebug().WriteToLog()
+
+		worker := &deviceWorker{
+			proxy:          dp,
+			tag:            tag,
+			dispatcher:     h.mux,
+			sniffingConfig: receiverConfig.GetEffectiveSniffingSettings(),
+			ctx:            ctx,
+		}
+		h.workers = append(h.workers, worker)
+	}
 	if pl == nil {
 		if net.HasNetwork(nl, net.Network_UNIX) {
 			newError("creating unix domain socket worker on ", address).AtDebug().WriteToLog()
@@ -100,6 +143,7 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 				sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
 				uplinkCounter:   uplinkCounter,
 				downlinkCounter: downlinkCounter,
+				connectionGauge: connectionGauge,
 				ctx:             ctx,
 			}
 			h.workers = append(h.workers, worker)
@@ -122,6 +166,7 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 						sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
 						uplinkCounter:   uplinkCounter,
 						downlinkCounter: downlinkCounter,
+						connectionGauge: connectionGauge,
 						ctx:             ctx,
 					}
 					h.workers = append(h.workers, worker)
@@ -137,6 +182,7 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 						sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
 						uplinkCounter:   uplinkCounter,
 						downlinkCounter: downlinkCounter,
+						connectionGauge: connectionGauge,
 						stream:          mss,
 						ctx:             ctx,
 					}
@@ -166,6 +212,8 @@ func (h *AlwaysOnInboundHandler) Close() error {
 		errs = append(errs, worker.Close())
 	}
 	errs = append(errs, h.mux.Close())
+	// the proxy goes last, as the workers may still be handing it connections
+	errs = append(errs, common.Close(h.proxy))
 	if err := errors.Combine(errs...); err != nil {
 		return newError("failed to close all resources").Base(err)
 	}
@@ -34,6 +34,10 @@ type DynamicInboundHandler struct {
 
 func NewDynamicInboundHandler(ctx context.Context, tag string, receiverConfig *proxyman.ReceiverConfig, proxyConfig interface{}) (*DynamicInboundHandler, error) {
 	v := core.MustFromContext(ctx)
+	ctx, err := contextWithTargetRestriction(ctx, receiverConfig)
+	if err != nil {
+		return nil, err
+	}
 	h := &DynamicInboundHandler{
 		tag:            tag,
 		proxyConfig:    proxyConfig,
@@ -118,6 +122,7 @@ func (h *DynamicInboundHandler) refresh() error {
 	}
 
 	uplinkCounter, downlinkCounter := getStatCounter(h.v, h.tag)
+	connectionGauge := getConnectionGauge(h.v, h.tag)
 
 	for i := uint32(0); i < concurrency; i++ {
 		port := h.allocatePort()
@@ -140,6 +145,7 @@ func (h *DynamicInboundHandler) refresh() error {
 				sniffingConfig:  h.receiverConfig.GetEffectiveSniffingSettings(),
 				uplinkCounter:   uplinkCounter,
 				downlinkCounter: downlinkCounter,
+				connectionGauge: connectionGauge,
 				ctx:             h.ctx,
 			}
 			if err := worker.Start(); err != nil {
@@ -159,6 +165,7 @@ func (h *DynamicInboundHandler) refresh() error {
 				sniffingConfig:  h.receiverConfig.GetEffectiveSniffingSettings(),
 				uplinkCounter:   uplinkCounter,
 				downlinkCounter: downlinkCounter,
+				connectionGauge: connectionGauge,
 				stream:          h.streamSettings,
 				ctx:             h.ctx,
 			}
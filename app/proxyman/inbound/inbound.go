@@ -6,12 +6,14 @@ import (
 	"context"
 	"sync"
 
+	app_policy "github.com/xtls/xray-core/app/policy"
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/policy"
 )
 
 // Manager is to manage all inbound handlers.
@@ -27,6 +29,20 @@ func New(ctx context.Context, config *proxyman.InboundConfig) (*Manager, error)
 	m := &Manager{
 		taggedHandlers: make(map[string]inbound.Handler),
 	}
+	if len(config.InboundPolicyOverrides) > 0 {
+		if err := core.RequireFeatures(ctx, func(pm policy.Manager) error {
+			pi, ok := pm.(*app_policy.Instance)
+			if !ok {
+				return newError("inbound policy overrides require the policy app")
+			}
+			for tag, p := range config.InboundPolicyOverrides {
+				pi.SetInboundOverride(tag, p)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
 	return m, nil
 }
 
@@ -171,7 +187,16 @@ func NewHandler(ctx context.Context, config *core.InboundHandlerConfig) (inbound
 	return nil, newError("unknown allocation strategy: ", receiverSettings.AllocationStrategy.Type).AtError()
 }
 
+// validateReceiverConfig checks the sniffing settings of an inbound.
+func validateReceiverConfig(v *core.Validation, path string, c interface{}) {
+	config := c.(*proxyman.ReceiverConfig)
+	if sniffing := config.SniffingSettings; sniffing != nil && !sniffing.Enabled && len(sniffing.DestinationOverride) > 0 {
+		v.Warning(path+".sniffing.destOverride", "ignored as sniffing is not enabled")
+	}
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*proxyman.ReceiverConfig)(nil), validateReceiverConfig))
 	common.Must(common.RegisterConfig((*proxyman.InboundConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return New(ctx, config.(*proxyman.InboundConfig))
 	}))
@@ -42,6 +42,7 @@ type tcpWorker struct {
 	sniffingConfig  *proxyman.SniffingConfig
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	connectionGauge stats.Counter
 
 	hub internet.Listener
 
@@ -80,16 +81,21 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 	}
 	ctx = session.ContextWithOutbounds(ctx, outbounds)
 
-	if w.uplinkCounter != nil || w.downlinkCounter != nil {
+	if w.uplinkCounter != nil || w.downlinkCounter != nil || w.connectionGauge != nil {
+		if w.connectionGauge != nil {
+			w.connectionGauge.Add(1)
+		}
 		conn = &stat.CounterConnection{
 			Connection:   conn,
 			ReadCounter:  w.uplinkCounter,
 			WriteCounter: w.downlinkCounter,
+			Gauge:        w.connectionGauge,
 		}
 	}
 	ctx = session.ContextWithInbound(ctx, &session.Inbound{
 		Source:  net.DestinationFromAddr(conn.RemoteAddr()),
 		Gateway: net.TCPDestination(w.address, w.port),
+		Local:   net.DestinationFromAddr(conn.LocalAddr()),
 		Tag:     w.tag,
 		Conn:    conn,
 	})
@@ -158,6 +164,8 @@ type udpConn struct {
 	done             *done.Instance
 	uplink           stats.Counter
 	downlink         stats.Counter
+	gauge            stats.Counter
+	closed           atomic.Bool
 	inactive         bool
 }
 
@@ -201,6 +209,9 @@ func (c *udpConn) Write(buf []byte) (int, error) {
 }
 
 func (c *udpConn) Close() error {
+	if c.gauge != nil && c.closed.CompareAndSwap(false, true) {
+		c.gauge.Add(-1)
+	}
 	common.Must(c.done.Close())
 	common.Must(common.Close(c.writer))
 	return nil
@@ -244,6 +255,7 @@ type udpWorker struct {
 	sniffingConfig  *proxyman.SniffingConfig
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	connectionGauge stats.Counter
 
 	checker    *task.Periodic
 	activeConn map[connID]*udpConn
@@ -278,6 +290,10 @@ func (w *udpWorker) getConnection(id connID) (*udpConn, bool) {
 		done:     done.New(),
 		uplink:   w.uplinkCounter,
 		downlink: w.downlinkCounter,
+		gauge:    w.connectionGauge,
+	}
+	if conn.gauge != nil {
+		conn.gauge.Add(1)
 	}
 	w.activeConn[id] = conn
 
@@ -285,7 +301,7 @@ func (w *udpWorker) getConnection(id connID) (*udpConn, bool) {
 	return conn, false
 }
 
-func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest net.Destination) {
+func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest net.Destination, local net.Address) {
 	id := connID{
 		src: source,
 	}
@@ -313,11 +329,17 @@ func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest
 				outbounds[0].Target = originalDest
 			}
 			ctx = session.ContextWithOutbounds(ctx, outbounds)
-			ctx = session.ContextWithInbound(ctx, &session.Inbound{
+			inbound := &session.Inbound{
 				Source:  source,
 				Gateway: net.UDPDestination(w.address, w.port),
 				Tag:     w.tag,
-			})
+			}
+			if local != nil {
+				inbound.Local = net.UDPDestination(local, w.port)
+			} else if !w.address.IP().IsUnspecified() {
+				inbound.Local = inbound.Gateway
+			}
+			ctx = session.ContextWithInbound(ctx, inbound)
 			content := new(session.Content)
 			if w.sniffingConfig != nil {
 				content.SniffingRequest.Enabled = w.sniffingConfig.Enabled
@@ -348,7 +370,7 @@ func (w *udpWorker) removeConn(id connID) {
 func (w *udpWorker) handlePackets() {
 	receive := w.hub.Receive()
 	for payload := range receive {
-		w.callback(payload.Payload, payload.Source, payload.Target)
+		w.callback(payload.Payload, payload.Source, payload.Target, payload.Local)
 	}
 }
 
@@ -443,6 +465,7 @@ type dsWorker struct {
 	sniffingConfig  *proxyman.SniffingConfig
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	connectionGauge stats.Counter
 
 	hub internet.Listener
 
@@ -454,11 +477,15 @@ func (w *dsWorker) callback(conn stat.Connection) {
 	sid := session.NewID()
 	ctx = session.ContextWithID(ctx, sid)
 
-	if w.uplinkCounter != nil || w.downlinkCounter != nil {
+	if w.uplinkCounter != nil || w.downlinkCounter != nil || w.connectionGauge != nil {
+		if w.connectionGauge != nil {
+			w.connectionGauge.Add(1)
+		}
 		conn = &stat.CounterConnection{
 			Connection:   conn,
 			ReadCounter:  w.uplinkCounter,
 			WriteCounter: w.downlinkCounter,
+			Gauge:        w.connectionGauge,
 		}
 	}
 	ctx = session.ContextWithInbound(ctx, &session.Inbound{
@@ -523,3 +550,59 @@ func (w *dsWorker) Close() error {
 
 	return nil
 }
+
+type deviceWorker struct {
+	proxy          proxy.DeviceInbound
+	tag            string
+	dispatcher     routing.Dispatcher
+	sniffingConfig *proxyman.SniffingConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   *done.Instance
+}
+
+func (w *deviceWorker) Proxy() proxy.Inbound {
+	return w.proxy
+}
+
+func (w *deviceWorker) Port() net.Port {
+	return net.Port(0)
+}
+
+func (w *deviceWorker) Start() error {
+	ctx, cancel := context.WithCancel(w.ctx)
+	ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{}})
+	ctx = session.ContextWithInbound(ctx, &session.Inbound{
+		Tag: w.tag,
+	})
+
+	content := new(session.Content)
+	if w.sniffingConfig != nil {
+		content.SniffingRequest.Enabled = w.sniffingConfig.Enabled
+		content.SniffingRequest.OverrideDestinationForProtocol = w.sniffingConfig.DestinationOverride
+		content.SniffingRequest.ExcludeForDomain = w.sniffingConfig.DomainsExcluded
+		content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
+		content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
+	}
+	ctx = session.ContextWithContent(ctx, content)
+
+	w.cancel = cancel
+	w.done = done.New()
+	go func() {
+		defer w.done.Close()
+		if err := w.proxy.Serve(ctx, w.dispatcher); err != nil && ctx.Err() == nil {
+			newError("device of inbound ", w.tag, " stopped").Base(err).AtError().WriteToLog()
+		}
+	}()
+	return nil
+}
+
+func (w *deviceWorker) Close() error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+	<-w.done.Wait()
+	return common.Close(w.proxy)
+}
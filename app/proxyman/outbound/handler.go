@@ -11,6 +11,7 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/net/cnc"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/trace"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/features/policy"
@@ -25,6 +26,8 @@ import (
 	"math/big"
 	gonet "net"
 	"os"
+	"sync/atomic"
+	"time"
 )
 
 func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter) {
@@ -52,6 +55,20 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	return uplinkCounter, downlinkCounter
 }
 
+func getConnectionGauge(v *core.Instance, tag string) stats.Counter {
+	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	if len(tag) > 0 && policy.ForSystem().Stats.OutboundConnections {
+		statsManager := v.GetFeature(stats.ManagerType()).(stats.Manager)
+		name := "outbound>>>" + tag + ">>>connections"
+		g, err := stats.GetOrRegisterGauge(statsManager, name)
+		if err != nil {
+			newError("failed to register ", name).Base(err).AtWarning().WriteToLog()
+		}
+		return g
+	}
+	return nil
+}
+
 // Handler is an implements of outbound.Handler.
 type Handler struct {
 	tag             string
@@ -64,6 +81,10 @@ type Handler struct {
 	udp443          string
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	connectionGauge stats.Counter
+	lastUsed        atomic.Int64 // unix nano
+	sessions        atomic.Uint64
+	tracker         *sessionTracker
 }
 
 // NewHandler creates a new Handler based on the given configuration.
@@ -75,6 +96,10 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 		outboundManager: v.GetFeature(outbound.ManagerType()).(outbound.Manager),
 		uplinkCounter:   uplinkCounter,
 		downlinkCounter: downlinkCounter,
+		connectionGauge: getConnectionGauge(v, config.Tag),
+	}
+	if m, ok := h.outboundManager.(*Manager); ok {
+		h.tracker = m.sessions
 	}
 
 	if config.SenderSettings != nil {
@@ -128,6 +153,8 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 							Strategy: mux.ClientStrategy{
 								MaxConcurrency: uint32(config.Concurrency),
 								MaxConnection:  128,
+								MaxReuseTime:   time.Duration(config.ConnectionReuseTimeout) * time.Second,
+								DrainTimeout:   time.Duration(config.DrainTimeout) * time.Second,
 							},
 						},
 					},
@@ -149,6 +176,8 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 							Strategy: mux.ClientStrategy{
 								MaxConcurrency: uint32(config.XudpConcurrency),
 								MaxConnection:  128,
+								MaxReuseTime:   time.Duration(config.ConnectionReuseTimeout) * time.Second,
+								DrainTimeout:   time.Duration(config.DrainTimeout) * time.Second,
 							},
 						},
 					},
@@ -169,6 +198,11 @@ func (h *Handler) Tag() string {
 
 // Dispatch implements proxy.Outbound.Dispatch.
 func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
+	if probe := session.ProbeFromContext(ctx); probe == nil || probe.RecordStats {
+		h.sessions.Add(1)
+		h.lastUsed.Store(time.Now().UnixNano())
+	}
+
 	outbounds := session.OutboundsFromContext(ctx)
 	ob := outbounds[len(outbounds) - 1]
 	if ob.Target.Network == net.Network_UDP && ob.OriginalTarget.Address != nil && ob.OriginalTarget.Address != ob.Target.Address {
@@ -181,7 +215,7 @@ func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
 				err := newError("failed to process mux outbound traffic").Base(err)
 				session.SubmitOutboundErrorToOriginator(ctx, err)
 				err.WriteToLog(session.ExportIDToError(ctx))
-				common.Interrupt(link.Writer)
+				common.InterruptWithError(link.Writer, err)
 			}
 		}
 		if ob.Target.Network == net.Network_UDP && ob.Target.Port == 443 {
@@ -206,24 +240,43 @@ func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
 		}
 	}
 out:
+	ctx, link, untrack := h.tracker.track(ctx, h.tag, link)
+	defer untrack()
+	ctx, span := trace.Start(ctx, "outbound")
+	span.SetAttribute("outbound.tag", h.tag)
 	err := h.proxy.Process(ctx, link, h)
 	if err != nil {
 		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, context.Canceled) {
 			err = nil
 		}
 	}
+	span.EndWithError(err)
 	if err != nil {
 		// Ensure outbound ray is properly closed.
 		err := newError("failed to process outbound traffic").Base(err)
 		session.SubmitOutboundErrorToOriginator(ctx, err)
 		err.WriteToLog(session.ExportIDToError(ctx))
-		common.Interrupt(link.Writer)
-	} else {
-		common.Close(link.Writer)
+		common.InterruptWithError(link.Writer, err)
+		common.InterruptWithError(link.Reader, err)
+		return
 	}
+	common.Close(link.Writer)
 	common.Interrupt(link.Reader)
 }
 
+// LastUsed implements outbound.UsageReporter.
+func (h *Handler) LastUsed() time.Time {
+	if t := h.lastUsed.Load(); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// SessionCount implements outbound.UsageReporter.
+func (h *Handler) SessionCount() uint64 {
+	return h.sessions.Load()
+}
+
 // Address implements internet.Dialer.
 func (h *Handler) Address() net.Address {
 	if h.senderSettings == nil || h.senderSettings.Via == nil {
@@ -237,7 +290,10 @@ func (h *Handler) DestIpAddress() net.IP {
 }
 
 // Dial implements internet.Dialer.
-func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connection, error) {
+func (h *Handler) Dial(ctx context.Context, dest net.Destination) (conn stat.Connection, err error) {
+	dialed := trackDial(ctx)
+	defer func() { dialed(err) }()
+
 	if h.senderSettings != nil {
 		if h.senderSettings.ProxySettings.HasTag() {
 			tag := h.senderSettings.ProxySettings.Tag
@@ -254,14 +310,18 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 				downlinkReader, downlinkWriter := pipe.New(opts...)
 
 				go handler.Dispatch(ctx, &transport.Link{Reader: uplinkReader, Writer: downlinkWriter})
-				conn := cnc.NewConnection(cnc.ConnectionInputMulti(uplinkWriter), cnc.ConnectionOutputMulti(downlinkReader))
+				output := cnc.ConnectionOutputMulti(downlinkReader)
+				if dest.Network == net.Network_UDP {
+					output = cnc.ConnectionOutputMultiUDP(downlinkReader)
+				}
+				conn := cnc.NewConnection(cnc.ConnectionInputMulti(uplinkWriter), output)
 
 				if config := tls.ConfigFromStreamSettings(h.streamSettings); config != nil {
 					tlsConfig := config.GetTLSConfig(tls.WithDestination(dest))
 					conn = tls.Client(conn, tlsConfig)
 				}
 
-				return h.getStatCouterConnection(conn), nil
+				return h.getStatCouterConnection(ctx, conn), nil
 			}
 
 			newError("failed to get outbound handler with tag: ", tag).AtWarning().WriteToLog(session.ExportIDToError(ctx))
@@ -276,27 +336,52 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 				ob.Gateway = ParseRandomIPv6(h.senderSettings.Via.AsAddress(), h.senderSettings.ViaCidr)
 			}
 		}
+
+		if len(h.senderSettings.ServerAddressResolver) > 0 {
+			outbounds := session.OutboundsFromContext(ctx)
+			ob := outbounds[len(outbounds)-1]
+			ob.Resolver = h.senderSettings.ServerAddressResolver
+		}
 	}
 
 	if conn, err := h.getUoTConnection(ctx, dest); err != os.ErrInvalid {
 		return conn, err
 	}
 
-	conn, err := internet.Dial(ctx, dest, h.streamSettings)
-	conn = h.getStatCouterConnection(conn)
+	dialCtx, span := trace.Start(ctx, "dial")
+	span.SetAttribute("destination", dest)
+	conn, err = internet.Dial(dialCtx, dest, h.streamSettings)
+	span.EndWithError(err)
+	if err == nil {
+		// it lasts till the outbound span ends
+		trace.Start(ctx, "copy")
+	}
+	if probe := session.ProbeFromContext(ctx); probe != nil && probe.Dialed != nil && err == nil {
+		probe.Dialed()
+	}
+	conn = h.getStatCouterConnection(ctx, conn)
 	outbounds := session.OutboundsFromContext(ctx)
 	ob := outbounds[len(outbounds) - 1]
 	ob.Conn = conn
 	return conn, err
 }
 
-func (h *Handler) getStatCouterConnection(conn stat.Connection) stat.Connection {
-	if h.uplinkCounter != nil || h.downlinkCounter != nil {
-		return &stat.CounterConnection{
+func (h *Handler) getStatCouterConnection(ctx context.Context, conn stat.Connection) stat.Connection {
+	if probe := session.ProbeFromContext(ctx); probe != nil && !probe.RecordStats {
+		return conn
+	}
+	if h.uplinkCounter != nil || h.downlinkCounter != nil || h.connectionGauge != nil {
+		c := &stat.CounterConnection{
 			Connection:   conn,
 			ReadCounter:  h.downlinkCounter,
 			WriteCounter: h.uplinkCounter,
 		}
+		// failed dials are not counted as open
+		if conn != nil && h.connectionGauge != nil {
+			h.connectionGauge.Add(1)
+			c.Gauge = h.connectionGauge
+		}
+		return c
 	}
 	return conn
 }
@@ -306,6 +391,12 @@ func (h *Handler) GetOutbound() proxy.Outbound {
 	return h.proxy
 }
 
+// RelaysICMP implements outbound.ICMPRelay.
+func (h *Handler) RelaysICMP() bool {
+	r, ok := h.proxy.(outbound.ICMPRelay)
+	return ok && r.RelaysICMP()
+}
+
 // Start implements common.Runnable.
 func (h *Handler) Start() error {
 	return nil
@@ -314,6 +405,8 @@ func (h *Handler) Start() error {
 // Close implements common.Closable.
 func (h *Handler) Close() error {
 	common.Close(h.mux)
+	common.Close(h.proxy)
+	internet.Release(h.streamSettings)
 	return nil
 }
 
@@ -12,18 +12,27 @@ import (
 	"github.com/xtls/xray-core/app/proxyman"
 	. "github.com/xtls/xray-core/app/proxyman/outbound"
 	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/common/session"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/outbound"
+	feature_stats "github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/proxy/freedom"
+	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet/stat"
+	_ "github.com/xtls/xray-core/transport/internet/tcp"
+	"github.com/xtls/xray-core/transport/pipe"
 )
 
 func TestInterfaces(t *testing.T) {
 	_ = (outbound.Handler)(new(Handler))
 	_ = (outbound.Manager)(new(Manager))
+	_ = (outbound.UsageReporter)(new(Handler))
+	_ = (outbound.HandlerLister)(new(Manager))
+	_ = (outbound.IdleExpirer)(new(Manager))
+	_ = (outbound.SessionLister)(new(Manager))
 }
 
 const xrayKey core.XrayKey = 1
@@ -87,6 +96,90 @@ func TestOutboundWithStatCounter(t *testing.T) {
 	}
 }
 
+func TestOutboundConnectionGauge(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	dest := net.DestinationFromAddr(listener.Addr())
+
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&policy.Config{
+				System: &policy.SystemPolicy{
+					Stats: &policy.SystemPolicy_Stats{
+						OutboundConnections: true,
+					},
+				},
+			}),
+		},
+	}
+
+	v, _ := core.New(config)
+	v.AddFeature((outbound.Manager)(new(Manager)))
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+	h, _ := NewHandler(ctx, &core.OutboundHandlerConfig{
+		Tag:           "tag",
+		ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+	})
+	gauge := v.GetFeature(feature_stats.ManagerType()).(feature_stats.GaugeManager).GetGauge("outbound>>>tag>>>connections")
+	if gauge == nil {
+		t.Fatal("gauge not registered")
+	}
+
+	const n = 16
+	conns := make(chan stat.Connection, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := session.ContextWithOutbounds(ctx, []*session.Outbound{{}})
+			conn, err := h.(*Handler).Dial(ctx, dest)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conns <- conn
+		}()
+	}
+	wg.Wait()
+	close(conns)
+
+	if value := gauge.Value(); value != n {
+		t.Error("expected ", n, " open connections, got ", value)
+	}
+	for conn := range conns {
+		wg.Add(1)
+		go func(conn stat.Connection) {
+			defer wg.Done()
+			// closing twice is counted once
+			conn.Close()
+			conn.Close()
+		}(conn)
+	}
+	wg.Wait()
+	if value := gauge.Value(); value != 0 {
+		t.Error("expected no open connections, got ", value)
+	}
+
+	if _, err := h.(*Handler).Dial(session.ContextWithOutbounds(ctx, []*session.Outbound{{}}), net.TCPDestination(net.LocalHostIP, 0)); err == nil {
+		t.Error("expected dial to fail")
+	}
+	if value := gauge.Value(); value != 0 {
+		t.Error("failed dial counted as open connection")
+	}
+}
+
 func TestTagsCache(t *testing.T) {
 
 	test_duration := 10 * time.Second
@@ -174,3 +267,66 @@ func TestTagsCache(t *testing.T) {
 	stop_get = true
 	wg_get.Wait()
 }
+
+func TestExpireAfterIdle(t *testing.T) {
+	ohm, err := New(context.Background(), &proxyman.OutboundConfig{})
+	common.Must(err)
+	v, _ := core.New(&core.Config{})
+	v.AddFeature(ohm)
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+
+	h, err := NewHandler(ctx, &core.OutboundHandlerConfig{
+		Tag:           "api",
+		ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+	})
+	common.Must(err)
+	common.Must(ohm.AddHandler(ctx, h))
+	if err := ohm.ExpireAfterIdle("unknown", time.Second); err == nil {
+		t.Error("expected error for unknown tag")
+	}
+
+	idle := 300 * time.Millisecond
+	common.Must(ohm.ExpireAfterIdle("api", idle))
+	if d := ohm.IdleExpiry("api"); d != idle {
+		t.Error("unexpected idle expiry: ", d)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	uplinkReader, uplinkWriter := pipe.New()
+	downlinkReader, downlinkWriter := pipe.New()
+	uplinkWriter.Close()
+	downlinkReader.Interrupt()
+	h.Dispatch(session.ContextWithOutbounds(ctx, []*session.Outbound{{}}), &transport.Link{Reader: uplinkReader, Writer: downlinkWriter})
+	used := time.Now()
+
+	reporter := h.(outbound.UsageReporter)
+	if count := reporter.SessionCount(); count != 1 {
+		t.Error("unexpected session count: ", count)
+	}
+	if reporter.LastUsed().IsZero() {
+		t.Error("last used time not recorded")
+	}
+
+	// past the idle period since it was added, but not since it was used
+	time.Sleep(200 * time.Millisecond)
+	if ohm.GetHandler("api") == nil {
+		t.Fatal("handler removed while in use")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ohm.GetHandler("api") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("handler not removed after being idle")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if elapsed := time.Since(used); elapsed < idle {
+		t.Error("handler removed too early: ", elapsed)
+	}
+	if len(ohm.ListHandlers()) != 0 {
+		t.Error("expired handler still listed")
+	}
+	if ohm.IdleExpiry("api") != 0 {
+		t.Error("expiry kept after removal")
+	}
+}
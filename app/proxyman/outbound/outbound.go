@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
@@ -23,6 +24,16 @@ type Manager struct {
 	untaggedHandlers []outbound.Handler
 	running          bool
 	tagsCache        *sync.Map
+	idleExpiries     map[string]*idleExpiry
+	sessions         *sessionTracker
+}
+
+// idleExpiry removes a handler once it has been left unused for a while.
+type idleExpiry struct {
+	handler outbound.Handler
+	idle    time.Duration
+	since   time.Time
+	timer   *time.Timer
 }
 
 // New creates a new Manager.
@@ -30,6 +41,13 @@ func New(ctx context.Context, config *proxyman.OutboundConfig) (*Manager, error)
 	m := &Manager{
 		taggedHandler: make(map[string]outbound.Handler),
 		tagsCache:     &sync.Map{},
+		idleExpiries:  make(map[string]*idleExpiry),
+		sessions:      newSessionTracker(),
+	}
+	if core.FromContext(ctx) != nil {
+		if err := core.RequireFeatures(ctx, m.sessions.setFeatures); err != nil {
+			return nil, err
+		}
 	}
 	return m, nil
 }
@@ -46,6 +64,10 @@ func (m *Manager) Start() error {
 
 	m.running = true
 
+	if err := m.sessions.start(); err != nil {
+		return err
+	}
+
 	for _, h := range m.taggedHandler {
 		if err := h.Start(); err != nil {
 			return err
@@ -68,6 +90,12 @@ func (m *Manager) Close() error {
 
 	m.running = false
 
+	m.sessions.close()
+	for tag, e := range m.idleExpiries {
+		e.timer.Stop()
+		delete(m.idleExpiries, tag)
+	}
+
 	var errs []error
 	for _, h := range m.taggedHandler {
 		errs = append(errs, h.Close())
@@ -137,16 +165,104 @@ func (m *Manager) RemoveHandler(ctx context.Context, tag string) error {
 	m.access.Lock()
 	defer m.access.Unlock()
 
+	m.removeHandler(tag)
+
+	return nil
+}
+
+func (m *Manager) removeHandler(tag string) {
 	m.tagsCache = &sync.Map{}
 
 	delete(m.taggedHandler, tag)
 	if m.defaultHandler != nil && m.defaultHandler.Tag() == tag {
 		m.defaultHandler = nil
 	}
+	if e, found := m.idleExpiries[tag]; found {
+		e.timer.Stop()
+		delete(m.idleExpiries, tag)
+	}
+}
+
+// ListHandlers implements outbound.HandlerLister.
+func (m *Manager) ListHandlers() []outbound.Handler {
+	m.access.RLock()
+	defer m.access.RUnlock()
 
+	handlers := make([]outbound.Handler, 0, len(m.taggedHandler))
+	for _, h := range m.taggedHandler {
+		handlers = append(handlers, h)
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].Tag() < handlers[j].Tag()
+	})
+	return handlers
+}
+
+// ListSessions implements outbound.SessionLister.
+func (m *Manager) ListSessions() []*outbound.SessionInfo {
+	return m.sessions.list()
+}
+
+// ExpireAfterIdle implements outbound.IdleExpirer.
+func (m *Manager) ExpireAfterIdle(tag string, idle time.Duration) error {
+	if idle <= 0 {
+		return newError("invalid idle duration: ", idle)
+	}
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	handler, found := m.taggedHandler[tag]
+	if !found {
+		return newError("handler not found: ", tag)
+	}
+	if _, ok := handler.(outbound.UsageReporter); !ok {
+		return newError("handler doesn't track its usage: ", tag)
+	}
+	if e, found := m.idleExpiries[tag]; found {
+		e.timer.Stop()
+	}
+	e := &idleExpiry{
+		handler: handler,
+		idle:    idle,
+		since:   time.Now(),
+	}
+	e.timer = time.AfterFunc(idle, func() {
+		m.checkIdle(tag, e)
+	})
+	m.idleExpiries[tag] = e
 	return nil
 }
 
+// IdleExpiry implements outbound.IdleExpirer.
+func (m *Manager) IdleExpiry(tag string) time.Duration {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	if e, found := m.idleExpiries[tag]; found {
+		return e.idle
+	}
+	return 0
+}
+
+func (m *Manager) checkIdle(tag string, e *idleExpiry) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if m.idleExpiries[tag] != e || m.taggedHandler[tag] != e.handler {
+		return
+	}
+	last := e.handler.(outbound.UsageReporter).LastUsed()
+	if last.Before(e.since) {
+		last = e.since
+	}
+	if remaining := time.Until(last.Add(e.idle)); remaining > 0 {
+		e.timer.Reset(remaining)
+		return
+	}
+	m.removeHandler(tag)
+	newError("outbound handler ", tag, " removed after being idle for ", e.idle).AtInfo().WriteToLog()
+}
+
 // Select implements outbound.HandlerSelector.
 func (m *Manager) Select(selectors []string) []string {
 
@@ -175,7 +291,29 @@ func (m *Manager) Select(selectors []string) []string {
 	return tags
 }
 
+// validateSenderConfig checks that the outbound an outbound dials through exists and is not itself.
+func validateSenderConfig(v *core.Validation, path string, c interface{}) {
+	config := c.(*proxyman.SenderConfig)
+	check := func(path, tag string) {
+		if tag == "" {
+			return
+		}
+		if tag == v.Outbound.Tag {
+			v.Error(path, "outbound ", tag, " can't dial through itself")
+			return
+		}
+		v.Defer(func() {
+			if !v.HasOutboundTag(tag) {
+				v.Error(path, "no outbound with tag ", tag)
+			}
+		})
+	}
+	check(path+".proxySettings.tag", config.ProxySettings.GetTag())
+	check(path+".streamSettings.sockopt.dialerProxy", config.StreamSettings.GetSocketSettings().GetDialerProxy())
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*proxyman.SenderConfig)(nil), validateSenderConfig))
 	common.Must(common.RegisterConfig((*proxyman.OutboundConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return New(ctx, config.(*proxyman.OutboundConfig))
 	}))
@@ -0,0 +1,314 @@
+package outbound
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport"
+)
+
+// reapMargin is how long a session may stay handshaking past the handshake timeout of its level,
+// so that the timeouts of the proxies and transports fire before the reaper.
+const reapMargin = 10 * time.Second
+
+type trackedSessionKey struct{}
+
+// trackedSession is a session dispatched to a handler of the Manager.
+type trackedSession struct {
+	ctx   context.Context
+	info  outbound.SessionInfo
+	level uint32
+	// dials is the number of dials of the session in progress.
+	dials atomic.Int32
+	// ready is set once a dial of the session succeeds, or the proxy relays traffic without dialing.
+	ready    atomic.Bool
+	draining atomic.Bool
+	cancel   context.CancelFunc
+	link     *transport.Link
+}
+
+func (s *trackedSession) state() outbound.SessionState {
+	switch {
+	case !s.ready.Load() || s.dials.Load() > 0:
+		return outbound.SessionHandshaking
+	case s.draining.Load():
+		return outbound.SessionDraining
+	}
+	return outbound.SessionEstablished
+}
+
+func (s *trackedSession) relaying() {
+	if !s.ready.Load() {
+		s.ready.Store(true)
+	}
+}
+
+func (s *trackedSession) dialing() func(err error) {
+	s.dials.Add(1)
+	return func(err error) {
+		if err == nil {
+			s.ready.Store(true)
+		}
+		s.dials.Add(-1)
+	}
+}
+
+// trackDial marks a dial of the session of ctx as in progress, until the returned function is called
+// with its result.
+func trackDial(ctx context.Context) func(err error) {
+	if s, ok := ctx.Value(trackedSessionKey{}).(*trackedSession); ok {
+		return s.dialing()
+	}
+	return func(error) {}
+}
+
+// sessionTracker keeps the sessions dispatched to the handlers of a Manager, and reaps the ones stuck
+// handshaking, such as those of dials that never return. Sessions multiplexed by mux are not tracked.
+type sessionTracker struct {
+	access   sync.Mutex
+	sessions map[*trackedSession]struct{}
+	policy   policy.Manager
+	// handshaking and oldest are the number of handshaking sessions and the age in seconds of the
+	// oldest one, refreshed by the reaper. They are nil unless the stats of outbound connections are on.
+	handshaking stats.Counter
+	oldest      stats.Counter
+	now         func() time.Time
+	reaper      *task.Periodic
+}
+
+func newSessionTracker() *sessionTracker {
+	t := &sessionTracker{
+		sessions: make(map[*trackedSession]struct{}),
+		now:      time.Now,
+	}
+	t.reaper = &task.Periodic{
+		Interval: time.Second,
+		Execute:  t.reap,
+	}
+	return t
+}
+
+func (t *sessionTracker) setFeatures(pm policy.Manager, sm stats.Manager) {
+	t.access.Lock()
+	defer t.access.Unlock()
+
+	t.policy = pm
+	if !pm.ForSystem().Stats.OutboundConnections {
+		return
+	}
+	for _, g := range []struct {
+		gauge *stats.Counter
+		name  string
+	}{
+		{&t.handshaking, "sessions>>>handshaking"},
+		{&t.oldest, "sessions>>>handshaking>>>oldest"},
+	} {
+		gauge, err := stats.GetOrRegisterGauge(sm, g.name)
+		if err != nil {
+			newError("failed to register ", g.name).Base(err).AtWarning().WriteToLog()
+			continue
+		}
+		*g.gauge = gauge
+	}
+}
+
+func (t *sessionTracker) start() error {
+	if t == nil {
+		return nil
+	}
+	return t.reaper.Start()
+}
+
+func (t *sessionTracker) close() error {
+	if t == nil {
+		return nil
+	}
+	return t.reaper.Close()
+}
+
+// track adds the session of ctx dispatched to the handler with the given tag. The session is to be
+// processed with the returned context and link, and removed by the returned function.
+func (t *sessionTracker) track(ctx context.Context, tag string, link *transport.Link) (context.Context, *transport.Link, func()) {
+	if t == nil {
+		return ctx, link, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &trackedSession{
+		info: outbound.SessionInfo{
+			ID:          uint32(session.IDFromContext(ctx)),
+			OutboundTag: tag,
+			Start:       t.now(),
+		},
+		cancel: cancel,
+		link:   link,
+	}
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		s.info.InboundTag = inbound.Tag
+		s.info.Source = inbound.Source
+		if inbound.User != nil {
+			s.level = inbound.User.Level
+		}
+	}
+	if outbounds := session.OutboundsFromContext(ctx); len(outbounds) > 0 {
+		s.info.Target = outbounds[len(outbounds)-1].Target
+	}
+	s.ctx = context.WithValue(ctx, trackedSessionKey{}, s)
+
+	t.access.Lock()
+	t.sessions[s] = struct{}{}
+	t.access.Unlock()
+
+	return s.ctx, &transport.Link{
+		Reader: &trackedReader{Reader: link.Reader, session: s},
+		Writer: &trackedWriter{Writer: link.Writer, session: s},
+	}, func() {
+		t.access.Lock()
+		delete(t.sessions, s)
+		t.access.Unlock()
+		cancel()
+	}
+}
+
+func (t *sessionTracker) handshakeTimeout(level uint32) time.Duration {
+	if t.policy == nil {
+		return policy.SessionDefault().Timeouts.Handshake
+	}
+	return t.policy.ForLevel(level).Timeouts.Handshake
+}
+
+// reap interrupts the sessions handshaking for longer than the handshake timeout of their level plus
+// reapMargin, and refreshes the gauges. Established and draining sessions are never touched.
+func (t *sessionTracker) reap() error {
+	now := t.now()
+	var reaped []*trackedSession
+	var handshaking int64
+	var oldest time.Time
+
+	t.access.Lock()
+	for s := range t.sessions {
+		if s.state() != outbound.SessionHandshaking {
+			continue
+		}
+		if now.Sub(s.info.Start) > t.handshakeTimeout(s.level)+reapMargin {
+			delete(t.sessions, s)
+			reaped = append(reaped, s)
+			continue
+		}
+		handshaking++
+		if oldest.IsZero() || s.info.Start.Before(oldest) {
+			oldest = s.info.Start
+		}
+	}
+	handshakingGauge, oldestGauge := t.handshaking, t.oldest
+	t.access.Unlock()
+
+	for _, s := range reaped {
+		err := newError("outbound ", s.info.OutboundTag, " still handshaking after ", now.Sub(s.info.Start).Truncate(time.Second), ", interrupted").AtWarning()
+		err.WriteToLog(session.ExportIDToError(s.ctx))
+		s.cancel()
+		common.InterruptWithError(s.link.Writer, err)
+		common.InterruptWithError(s.link.Reader, err)
+	}
+	if handshakingGauge != nil {
+		handshakingGauge.Set(handshaking)
+	}
+	if oldestGauge != nil {
+		var age int64
+		if !oldest.IsZero() {
+			age = int64(now.Sub(oldest) / time.Second)
+		}
+		oldestGauge.Set(age)
+	}
+	return nil
+}
+
+func (t *sessionTracker) list() []*outbound.SessionInfo {
+	if t == nil {
+		return nil
+	}
+	t.access.Lock()
+	sessions := make([]*outbound.SessionInfo, 0, len(t.sessions))
+	for s := range t.sessions {
+		info := s.info
+		info.State = s.state()
+		sessions = append(sessions, &info)
+	}
+	t.access.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Start.Before(sessions[j].Start)
+	})
+	return sessions
+}
+
+// trackedReader marks its session as relaying once the proxy reads the request, and as draining once
+// the request ends.
+type trackedReader struct {
+	buf.Reader
+	session *trackedSession
+}
+
+func (r *trackedReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	r.session.relaying()
+	mb, err := r.Reader.ReadMultiBuffer()
+	if err != nil {
+		r.session.draining.Store(true)
+	}
+	return mb, err
+}
+
+func (r *trackedReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	tr, ok := r.Reader.(buf.TimeoutReader)
+	if !ok {
+		return nil, buf.ErrNotTimeoutReader
+	}
+	r.session.relaying()
+	mb, err := tr.ReadMultiBufferTimeout(timeout)
+	if err != nil && err != buf.ErrReadTimeout {
+		r.session.draining.Store(true)
+	}
+	return mb, err
+}
+
+func (r *trackedReader) Interrupt() {
+	common.Interrupt(r.Reader)
+}
+
+func (r *trackedReader) InterruptWithError(err error) {
+	common.InterruptWithError(r.Reader, err)
+}
+
+// trackedWriter marks its session as relaying once the proxy writes the response.
+type trackedWriter struct {
+	buf.Writer
+	session *trackedSession
+}
+
+func (w *trackedWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	if !mb.IsEmpty() {
+		w.session.relaying()
+	}
+	return w.Writer.WriteMultiBuffer(mb)
+}
+
+func (w *trackedWriter) Close() error {
+	return common.Close(w.Writer)
+}
+
+func (w *trackedWriter) Interrupt() {
+	common.Interrupt(w.Writer)
+}
+
+func (w *trackedWriter) InterruptWithError(err error) {
+	common.InterruptWithError(w.Writer, err)
+}
@@ -0,0 +1,113 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+type statsPolicy struct {
+	policy.DefaultManager
+}
+
+func (statsPolicy) ForSystem() policy.System {
+	return policy.System{Stats: policy.SystemStats{OutboundConnections: true}}
+}
+
+func TestReapHandshakingSessions(t *testing.T) {
+	sm, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+	tracker := newSessionTracker()
+	tracker.setFeatures(statsPolicy{}, sm)
+	start := time.Unix(1700000000, 0)
+	now := start
+	tracker.now = func() time.Time { return now }
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{Tag: "in"})
+	ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{Target: net.TCPDestination(net.DomainAddress("example.com"), 443)}})
+	track := func(tag string) (context.Context, *transport.Link, *pipe.Writer, *pipe.Reader) {
+		uplinkReader, uplinkWriter := pipe.New()
+		downlinkReader, downlinkWriter := pipe.New()
+		ctx, link, _ := tracker.track(ctx, tag, &transport.Link{Reader: uplinkReader, Writer: downlinkWriter})
+		return ctx, link, uplinkWriter, downlinkReader
+	}
+	expectGauges := func(handshaking, oldest int64) {
+		t.Helper()
+		if v := sm.GetGauge("sessions>>>handshaking").Value(); v != handshaking {
+			t.Error("expect ", handshaking, " handshaking sessions, but got ", v)
+		}
+		if v := sm.GetGauge("sessions>>>handshaking>>>oldest").Value(); v != oldest {
+			t.Error("expect the oldest handshaking session to be ", oldest, "s old, but got ", v)
+		}
+	}
+
+	// a dial that hangs forever, whatever its context
+	stuckCtx, _, _, stuckResponse := track("stuck")
+	hang := make(chan struct{})
+	defer close(hang)
+	dialing := make(chan struct{})
+	go func() {
+		dialed := trackDial(stuckCtx)
+		close(dialing)
+		<-hang
+		dialed(errors.New("never"))
+	}()
+	<-dialing
+
+	// an established session, which has waited almost as long
+	now = now.Add(time.Second)
+	establishedCtx, established, request, _ := track("established")
+	trackDial(establishedCtx)(nil)
+
+	sessions := tracker.list()
+	if len(sessions) != 2 || sessions[0].State != outbound.SessionHandshaking || sessions[1].State != outbound.SessionEstablished {
+		t.Fatal("unexpected sessions ", sessions)
+	}
+	if sessions[0].OutboundTag != "stuck" || sessions[0].InboundTag != "in" || sessions[0].Target.Port != 443 {
+		t.Error("unexpected session ", *sessions[0])
+	}
+
+	// the handshake timeout is 60s by default
+	now = start.Add(60*time.Second + reapMargin)
+	common.Must(tracker.reap())
+	if stuckCtx.Err() != nil {
+		t.Fatal("reaped before the handshake timeout and the margin")
+	}
+	expectGauges(1, 70)
+
+	now = now.Add(time.Second)
+	common.Must(tracker.reap())
+	if stuckCtx.Err() == nil {
+		t.Error("expect the stuck session to be canceled")
+	}
+	if _, err := stuckResponse.ReadMultiBuffer(); err == nil {
+		t.Error("expect the response of the stuck session to be interrupted")
+	}
+	expectGauges(0, 0)
+
+	sessions = tracker.list()
+	if len(sessions) != 1 || sessions[0].OutboundTag != "established" || establishedCtx.Err() != nil {
+		t.Fatal("expect the established session to be left alone, but got ", sessions)
+	}
+
+	// the request ends, with the response still to be relayed
+	request.Close()
+	if _, err := established.Reader.ReadMultiBuffer(); err == nil {
+		t.Fatal("expect the request to end")
+	}
+	now = now.Add(time.Hour)
+	common.Must(tracker.reap())
+	if sessions := tracker.list(); len(sessions) != 1 || sessions[0].State != outbound.SessionDraining {
+		t.Error("expect the session to be draining, but got ", sessions)
+	}
+}
@@ -30,5 +30,5 @@ func (h *Handler) getUoTConnection(ctx context.Context, dest net.Destination) (s
 		return nil, newError("unable to listen socket").Base(err)
 	}
 	conn := uot.NewServerConn(packetConn, uotVersion)
-	return h.getStatCouterConnection(conn), nil
+	return h.getStatCouterConnection(ctx, conn), nil
 }
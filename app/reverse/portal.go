@@ -7,22 +7,25 @@ import (
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/mux"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/pipe"
 	"google.golang.org/protobuf/proto"
 )
 
 type Portal struct {
-	ohm    outbound.Manager
-	tag    string
-	domain string
-	picker *StaticMuxPicker
-	client *mux.ClientManager
+	ohm      outbound.Manager
+	tag      string
+	domain   string
+	picker   *StaticMuxPicker
+	stats    *portalStats
+	counters *trafficCounters
 }
 
 func NewPortal(config *PortalConfig, ohm outbound.Manager) (*Portal, error) {
@@ -44,12 +47,24 @@ func NewPortal(config *PortalConfig, ohm outbound.Manager) (*Portal, error) {
 		tag:    config.Tag,
 		domain: config.Domain,
 		picker: picker,
-		client: &mux.ClientManager{
-			Picker: picker,
-		},
 	}, nil
 }
 
+// enableStats makes the portal count the traffic and the sessions it relays,
+// in total and per bridge.
+func (p *Portal) enableStats(manager stats.Manager, traffic bool, sessions bool) {
+	if !traffic && !sessions {
+		return
+	}
+	p.stats = &portalStats{
+		manager:  manager,
+		prefix:   "portal>>>" + p.domain,
+		traffic:  traffic,
+		sessions: sessions,
+	}
+	p.counters = p.stats.portal()
+}
+
 func (p *Portal) Start() error {
 	return p.ohm.AddHandler(context.Background(), &Outbound{
 		portal: p,
@@ -63,7 +78,7 @@ func (p *Portal) Close() error {
 
 func (p *Portal) HandleConnection(ctx context.Context, link *transport.Link) error {
 	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	if ob == nil {
 		return newError("outbound metadata not found").AtError()
 	}
@@ -78,14 +93,54 @@ func (p *Portal) HandleConnection(ctx context.Context, link *transport.Link) err
 		if err != nil {
 			return newError("failed to create portal worker").Base(err)
 		}
+		worker.bridge = bridgeID(ctx)
+		if p.stats != nil {
+			worker.counters = p.stats.bridge(worker.bridge)
+		}
 
 		p.picker.AddWorker(worker)
 		return nil
 	}
 
-	return p.client.Dispatch(ctx, link)
+	worker, _ := ctx.Value(pickedWorkerKey).(*PortalWorker)
+	for i := 0; i < 16; i++ {
+		if worker == nil {
+			var err error
+			if worker, err = p.picker.pickWorker(); err != nil {
+				return err
+			}
+		}
+		counted, release := countSession(link, p.counters, worker.counters)
+		if worker.client.Dispatch(ctx, counted) {
+			return nil
+		}
+		release()
+		worker = nil
+	}
+
+	return newError("unable to find an available mux client").AtWarning()
 }
 
+// bridgeID identifies the bridge of a registration connection, by the email of
+// its user or else by its source IP.
+func bridgeID(ctx context.Context) string {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil {
+		return "unknown"
+	}
+	if inbound.User != nil && len(inbound.User.Email) > 0 {
+		return inbound.User.Email
+	}
+	if inbound.Source.IsValid() {
+		return inbound.Source.Address.String()
+	}
+	return "unknown"
+}
+
+type portalContextKey int
+
+const pickedWorkerKey portalContextKey = 0
+
 type Outbound struct {
 	portal *Portal
 	tag    string
@@ -102,6 +157,21 @@ func (o *Outbound) Dispatch(ctx context.Context, link *transport.Link) {
 	}
 }
 
+// AnnotateAccess implements outbound.AccessAnnotator. It picks the bridge of
+// the connection in advance, so that the access log can tell it.
+func (o *Outbound) AnnotateAccess(ctx context.Context, msg *log.AccessMessage) context.Context {
+	outbounds := session.OutboundsFromContext(ctx)
+	if len(outbounds) == 0 || isDomain(outbounds[len(outbounds)-1].Target, o.portal.domain) {
+		return ctx
+	}
+	worker, err := o.portal.picker.pickWorker()
+	if err != nil {
+		return ctx
+	}
+	msg.Bridge = worker.bridge
+	return context.WithValue(ctx, pickedWorkerKey, worker)
+}
+
 func (o *Outbound) Start() error {
 	return nil
 }
@@ -145,6 +215,14 @@ func (p *StaticMuxPicker) cleanup() error {
 }
 
 func (p *StaticMuxPicker) PickAvailable() (*mux.ClientWorker, error) {
+	worker, err := p.pickWorker()
+	if err != nil {
+		return nil, err
+	}
+	return worker.client, nil
+}
+
+func (p *StaticMuxPicker) pickWorker() (*PortalWorker, error) {
 	p.access.Lock()
 	defer p.access.Unlock()
 
@@ -180,7 +258,7 @@ func (p *StaticMuxPicker) PickAvailable() (*mux.ClientWorker, error) {
 	}
 
 	if minIdx != -1 {
-		return p.workers[minIdx].client, nil
+		return p.workers[minIdx], nil
 	}
 
 	return nil, newError("no mux client worker available")
@@ -199,6 +277,8 @@ type PortalWorker struct {
 	writer   buf.Writer
 	reader   buf.Reader
 	draining bool
+	bridge   string
+	counters *trafficCounters
 }
 
 func NewPortalWorker(client *mux.ClientWorker) (*PortalWorker, error) {
@@ -10,7 +10,9 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
 )
 
 const (
@@ -25,11 +27,27 @@ func isInternalDomain(dest net.Destination) bool {
 	return isDomain(dest, internalDomain)
 }
 
+// declareTags declares the tags of the bridges, which are inbound tags, and of the portals, which are outbound tags.
+func declareTags(v *core.Validation, _ string, c interface{}) {
+	config := c.(*Config)
+	for _, bridge := range config.BridgeConfig {
+		v.AddInboundTag(bridge.Tag)
+	}
+	for _, portal := range config.PortalConfig {
+		v.AddOutboundTag(portal.Tag)
+	}
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), declareTags))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		r := new(Reverse)
-		if err := core.RequireFeatures(ctx, func(d routing.Dispatcher, om outbound.Manager) error {
-			return r.Init(config.(*Config), d, om)
+		if err := core.RequireFeatures(ctx, func(d routing.Dispatcher, om outbound.Manager, pm policy.Manager, sm stats.Manager) error {
+			if err := r.Init(config.(*Config), d, om); err != nil {
+				return err
+			}
+			r.enableStats(pm, sm)
+			return nil
 		}); err != nil {
 			return nil, err
 		}
@@ -62,6 +80,15 @@ func (r *Reverse) Init(config *Config, d routing.Dispatcher, ohm outbound.Manage
 	return nil
 }
 
+// enableStats turns on the counters of portals if outbound traffic or
+// connections are counted.
+func (r *Reverse) enableStats(pm policy.Manager, sm stats.Manager) {
+	policy := pm.ForSystem().Stats
+	for _, p := range r.portals {
+		p.enableStats(sm, policy.OutboundUplink || policy.OutboundDownlink, policy.OutboundConnections)
+	}
+}
+
 func (r *Reverse) Type() interface{} {
 	return (*Reverse)(nil)
 }
@@ -0,0 +1,155 @@
+package reverse
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport"
+)
+
+// trafficCounters are the counters of a portal, or of a bridge connected to
+// it. Any of them may be nil.
+type trafficCounters struct {
+	uplink   stats.Counter
+	downlink stats.Counter
+	sessions stats.Counter
+}
+
+// portalStats registers the counters of a portal and of its bridges. Counters
+// of a bridge are never unregistered, so its totals survive reconnections.
+type portalStats struct {
+	manager  stats.Manager
+	prefix   string
+	traffic  bool
+	sessions bool
+}
+
+func (s *portalStats) counters(name string) *trafficCounters {
+	c := &trafficCounters{}
+	if s.traffic {
+		if counter, err := stats.GetOrRegisterCounter(s.manager, name+">>>traffic>>>uplink"); err == nil {
+			c.uplink = counter
+		}
+		if counter, err := stats.GetOrRegisterCounter(s.manager, name+">>>traffic>>>downlink"); err == nil {
+			c.downlink = counter
+		}
+	}
+	if s.sessions {
+		if gauge, err := stats.GetOrRegisterGauge(s.manager, name+">>>sessions"); err == nil {
+			c.sessions = gauge
+		} else {
+			newError("failed to register ", name, ">>>sessions").Base(err).AtWarning().WriteToLog()
+		}
+	}
+	return c
+}
+
+func (s *portalStats) portal() *trafficCounters {
+	return s.counters(s.prefix)
+}
+
+func (s *portalStats) bridge(id string) *trafficCounters {
+	return s.counters(s.prefix + ">>>bridge>>>" + id)
+}
+
+// countSession wraps the link of an interception session with the given
+// counters, and counts the session as active until its writer is closed.
+// release must be called if the link is never used.
+func countSession(link *transport.Link, counters ...*trafficCounters) (counted *transport.Link, release func()) {
+	var uplink, downlink, sessions []stats.Counter
+	for _, c := range counters {
+		if c == nil {
+			continue
+		}
+		if c.uplink != nil {
+			uplink = append(uplink, c.uplink)
+		}
+		if c.downlink != nil {
+			downlink = append(downlink, c.downlink)
+		}
+		if c.sessions != nil {
+			sessions = append(sessions, c.sessions)
+		}
+	}
+	if len(uplink) == 0 && len(downlink) == 0 && len(sessions) == 0 {
+		return link, func() {}
+	}
+
+	for _, c := range sessions {
+		c.Add(1)
+	}
+	w := &statWriter{
+		Writer:   link.Writer,
+		counters: downlink,
+		sessions: sessions,
+	}
+	return &transport.Link{
+		Reader: &statReader{
+			Reader:   link.Reader,
+			counters: uplink,
+		},
+		Writer: w,
+	}, w.end
+}
+
+func addAll(counters []stats.Counter, n int64) {
+	for _, c := range counters {
+		c.Add(n)
+	}
+}
+
+type statReader struct {
+	buf.Reader
+	counters []stats.Counter
+}
+
+func (r *statReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.Reader.ReadMultiBuffer()
+	addAll(r.counters, int64(mb.Len()))
+	return mb, err
+}
+
+func (r *statReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	tr, ok := r.Reader.(buf.TimeoutReader)
+	if !ok {
+		return nil, buf.ErrNotTimeoutReader
+	}
+	mb, err := tr.ReadMultiBufferTimeout(timeout)
+	addAll(r.counters, int64(mb.Len()))
+	return mb, err
+}
+
+func (r *statReader) Interrupt() {
+	common.Interrupt(r.Reader)
+}
+
+type statWriter struct {
+	buf.Writer
+	counters []stats.Counter
+	sessions []stats.Counter
+	once     sync.Once
+}
+
+func (w *statWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	addAll(w.counters, int64(mb.Len()))
+	return w.Writer.WriteMultiBuffer(mb)
+}
+
+func (w *statWriter) end() {
+	w.once.Do(func() {
+		addAll(w.sessions, -1)
+	})
+}
+
+func (w *statWriter) Close() error {
+	w.end()
+	return common.Close(w.Writer)
+}
+
+func (w *statWriter) Interrupt() {
+	w.end()
+	common.Interrupt(w.Writer)
+}
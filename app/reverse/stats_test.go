@@ -0,0 +1,190 @@
+package reverse
+
+import (
+	"context"
+	"crypto/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/mux"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// echoDispatcher plays the bridge side: it echoes every connection, and
+// swallows the control connection of the portal.
+type echoDispatcher struct {
+	received atomic.Int64
+}
+
+func (*echoDispatcher) Type() interface{} { return nil }
+func (*echoDispatcher) Start() error      { return nil }
+func (*echoDispatcher) Close() error      { return nil }
+
+func (d *echoDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	upReader, upWriter := pipe.New()
+	downReader, downWriter := pipe.New()
+	go func() {
+		if isInternalDomain(dest) {
+			buf.Copy(upReader, buf.Discard)
+			return
+		}
+		for {
+			mb, err := upReader.ReadMultiBuffer()
+			d.received.Add(int64(mb.Len()))
+			if !mb.IsEmpty() {
+				downWriter.WriteMultiBuffer(mb)
+			}
+			if err != nil {
+				downWriter.Close()
+				return
+			}
+		}
+	}()
+	return &transport.Link{Reader: downReader, Writer: upWriter}, nil
+}
+
+func (d *echoDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return newError("not implemented")
+}
+
+func connectBridge(t *testing.T, p *Portal, email string) (*echoDispatcher, func()) {
+	toBridgeReader, toBridgeWriter := pipe.New()
+	fromBridgeReader, fromBridgeWriter := pipe.New()
+
+	d := &echoDispatcher{}
+	common.Must2(mux.NewServerWorker(context.Background(), d, &transport.Link{
+		Reader: toBridgeReader,
+		Writer: fromBridgeWriter,
+	}))
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+		User: &protocol.MemoryUser{Email: email},
+	})
+	ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{
+		Target: net.TCPDestination(net.DomainAddress(p.domain), 0),
+	}})
+	common.Must(p.HandleConnection(ctx, &transport.Link{
+		Reader: fromBridgeReader,
+		Writer: toBridgeWriter,
+	}))
+	return d, func() {
+		toBridgeWriter.Close()
+		fromBridgeWriter.Close()
+	}
+}
+
+type relaySession struct {
+	writer *pipe.Writer
+	reader *pipe.Reader
+}
+
+func openSession(t *testing.T, p *Portal, payload []byte) *relaySession {
+	upReader, upWriter := pipe.New()
+	downReader, downWriter := pipe.New()
+	ctx := session.ContextWithOutbounds(context.Background(), []*session.Outbound{{
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 80),
+	}})
+	common.Must(p.HandleConnection(ctx, &transport.Link{Reader: upReader, Writer: downWriter}))
+	common.Must(upWriter.WriteMultiBuffer(buf.MergeBytes(nil, payload)))
+
+	var echoed int32
+	for echoed < int32(len(payload)) {
+		mb, err := downReader.ReadMultiBuffer()
+		if err != nil {
+			t.Fatal("failed to read echo: ", err)
+		}
+		echoed += mb.Len()
+		buf.ReleaseMulti(mb)
+	}
+	return &relaySession{writer: upWriter, reader: downReader}
+}
+
+func (s *relaySession) close() {
+	s.writer.Close()
+	buf.Copy(s.reader, buf.Discard)
+}
+
+func TestPortalStats(t *testing.T) {
+	sm, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+
+	p, err := NewPortal(&PortalConfig{Tag: "portal", Domain: "portal.example"}, nil)
+	common.Must(err)
+	p.enableStats(sm, true, true)
+
+	bridgeA, disconnectA := connectBridge(t, p, "bridge-a")
+	bridgeB, disconnectB := connectBridge(t, p, "bridge-b")
+	defer disconnectB()
+
+	payloadA := make([]byte, 1000)
+	payloadB := make([]byte, 3000)
+	common.Must2(rand.Read(payloadA))
+	common.Must2(rand.Read(payloadB))
+
+	// both bridges carry their control connection, so the first session goes
+	// to bridge-a, and the second one to the less loaded bridge-b
+	sessionA := openSession(t, p, payloadA)
+	sessionB := openSession(t, p, payloadB)
+	if bridgeA.received.Load() != 1000 || bridgeB.received.Load() != 3000 {
+		t.Fatal("unexpected relays: ", bridgeA.received.Load(), " ", bridgeB.received.Load())
+	}
+	if v := sm.GetGauge("portal>>>portal.example>>>sessions").Value(); v != 2 {
+		t.Error("active sessions: ", v)
+	}
+	sessionA.close()
+	sessionB.close()
+
+	counter := func(name string) int64 {
+		c := sm.GetCounter(name)
+		if c == nil {
+			t.Fatal("counter not registered: ", name)
+		}
+		return c.Value()
+	}
+	gauge := func(name string) int64 {
+		g := sm.GetGauge(name)
+		if g == nil {
+			t.Fatal("gauge not registered: ", name)
+		}
+		return g.Value()
+	}
+	expected := map[string]int64{
+		"portal>>>portal.example>>>traffic>>>uplink":                       4000,
+		"portal>>>portal.example>>>traffic>>>downlink":                     4000,
+		"portal>>>portal.example>>>bridge>>>bridge-a>>>traffic>>>uplink":   1000,
+		"portal>>>portal.example>>>bridge>>>bridge-a>>>traffic>>>downlink": 1000,
+		"portal>>>portal.example>>>bridge>>>bridge-b>>>traffic>>>uplink":   3000,
+		"portal>>>portal.example>>>bridge>>>bridge-b>>>traffic>>>downlink": 3000,
+	}
+	for name, value := range expected {
+		if v := counter(name); v != value {
+			t.Error(name, ": ", v, ", expected ", value)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for gauge("portal>>>portal.example>>>sessions") != 0 ||
+		gauge("portal>>>portal.example>>>bridge>>>bridge-a>>>sessions") != 0 ||
+		gauge("portal>>>portal.example>>>bridge>>>bridge-b>>>sessions") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("sessions still counted as active")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// totals of a bridge survive its disconnection
+	disconnectA()
+	time.Sleep(100 * time.Millisecond)
+	common.Must(p.picker.cleanup())
+	if v := counter("portal>>>portal.example>>>bridge>>>bridge-a>>>traffic>>>uplink"); v != 1000 {
+		t.Error("bridge-a uplink after disconnection: ", v)
+	}
+}
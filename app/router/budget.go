@@ -0,0 +1,100 @@
+package router
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	feature_stats "github.com/xtls/xray-core/features/stats"
+)
+
+// ruleBudget keeps the bytes a rule has routed in the current window.
+type ruleBudget struct {
+	bytes       int64
+	window      time.Duration
+	overflowTag string
+	now         func() time.Time
+
+	access    sync.Mutex
+	windowEnd time.Time
+	used      atomic.Int64
+}
+
+func newRuleBudget(config *RuleBudget) (*ruleBudget, error) {
+	if config.Bytes == 0 {
+		return nil, newError("budget of rule has no bytes")
+	}
+	if len(config.OverflowTag) == 0 {
+		return nil, newError("budget of rule has no overflow tag")
+	}
+	b := &ruleBudget{
+		bytes:       int64(config.Bytes),
+		window:      time.Duration(config.Window) * time.Second,
+		overflowTag: config.OverflowTag,
+		now:         time.Now,
+	}
+	return b, nil
+}
+
+// nextWindowEnd returns the end of the window including now.
+func (b *ruleBudget) nextWindowEnd(now time.Time) time.Time {
+	if b.window == 0 {
+		year, month, _ := now.Date()
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location())
+	}
+	if b.windowEnd.IsZero() {
+		return now.Add(b.window)
+	}
+	return b.windowEnd.Add((now.Sub(b.windowEnd)/b.window + 1) * b.window)
+}
+
+// state renews the budget if its window is over, and returns the bytes used in the current window.
+// Bytes are counted as they go, so the connections of a window may still be counted in the next one.
+func (b *ruleBudget) state() (used int64, windowEnd time.Time) {
+	b.access.Lock()
+	defer b.access.Unlock()
+
+	if now := b.now(); b.windowEnd.IsZero() || !now.Before(b.windowEnd) {
+		if !b.windowEnd.IsZero() {
+			b.used.Store(0)
+		}
+		b.windowEnd = b.nextWindowEnd(now)
+	}
+	return b.used.Load(), b.windowEnd
+}
+
+func (b *ruleBudget) exhausted() bool {
+	used, _ := b.state()
+	return used >= b.bytes
+}
+
+// budgetCounter counts bytes against a budget, in addition to an optional counter of rule stats.
+type budgetCounter struct {
+	counter feature_stats.Counter
+	budget  *ruleBudget
+}
+
+// Value implements stats.Counter.
+func (c *budgetCounter) Value() int64 {
+	if c.counter == nil {
+		return 0
+	}
+	return c.counter.Value()
+}
+
+// Set implements stats.Counter.
+func (c *budgetCounter) Set(v int64) int64 {
+	if c.counter == nil {
+		return 0
+	}
+	return c.counter.Set(v)
+}
+
+// Add implements stats.Counter.
+func (c *budgetCounter) Add(delta int64) int64 {
+	c.budget.used.Add(delta)
+	if c.counter == nil {
+		return 0
+	}
+	return c.counter.Add(delta)
+}
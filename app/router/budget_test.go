@@ -0,0 +1,148 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
+	routing_session "github.com/xtls/xray-core/features/routing/session"
+)
+
+func TestRuleBudget(t *testing.T) {
+	config := &Config{
+		Rule: []*RoutingRule{
+			{
+				TargetTag: &RoutingRule_Tag{
+					Tag: "metered",
+				},
+				RuleTag:  "video",
+				Networks: []net.Network{net.Network_TCP},
+				Budget: &RuleBudget{
+					Bytes:       100,
+					Window:      3600,
+					OverflowTag: "unmetered",
+				},
+			},
+		},
+	}
+
+	r := new(Router)
+	common.Must(r.Init(context.TODO(), config, nil, nil, nil))
+
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	r.rules[0].budget.now = func() time.Time { return now }
+
+	pick := func() routing.Route {
+		ctx := session.ContextWithOutbounds(context.Background(), []*session.Outbound{{
+			Target: net.TCPDestination(net.DomainAddress("example.com"), 443),
+		}})
+		route, err := r.PickRoute(routing_session.AsRoutingContext(ctx))
+		common.Must(err)
+		return route
+	}
+	dispatch := func(expectedTag string, up, down int64) {
+		route := pick()
+		if tag := route.GetOutboundTag(); tag != expectedTag {
+			t.Fatal("expect outbound ", expectedTag, ", but got ", tag)
+		}
+		counted, ok := route.(routing.CountedRoute)
+		if !ok {
+			t.Fatal("route to ", expectedTag, " is not counted")
+		}
+		uplink, downlink := counted.Dispatched()
+		uplink.Add(up)
+		downlink.Add(down)
+	}
+
+	dispatch("metered", 30, 30)
+	now = now.Add(10 * time.Minute)
+	dispatch("metered", 20, 30)
+
+	// The budget is used up in the middle of the window.
+	now = now.Add(10 * time.Minute)
+	route := pick()
+	if tag := route.GetOutboundTag(); tag != "unmetered" {
+		t.Error("expect overflow outbound, but got ", tag)
+	}
+	if _, ok := route.(routing.CountedRoute); ok {
+		t.Error("overflow route counted against the budget")
+	}
+
+	budgets := r.RuleBudgets()
+	if len(budgets) != 1 {
+		t.Fatal("expect 1 budget, but got ", len(budgets))
+	}
+	expected := routing.RuleBudget{
+		Index:       0,
+		RuleTag:     "video",
+		Bytes:       100,
+		Used:        110,
+		OverflowTag: "unmetered",
+	}
+	actual := budgets[0]
+	if end := time.Date(2024, time.March, 10, 13, 0, 0, 0, time.UTC); !actual.WindowEnd.Equal(end) {
+		t.Error("expect window to end at ", end, ", but got ", actual.WindowEnd)
+	}
+	actual.WindowEnd = time.Time{}
+	if actual != expected {
+		t.Error("expect ", expected, ", but got ", actual)
+	}
+
+	// The budget is renewed at the end of the window.
+	now = time.Date(2024, time.March, 10, 15, 30, 0, 0, time.UTC)
+	dispatch("metered", 10, 0)
+	budgets = r.RuleBudgets()
+	if budgets[0].Used != 10 {
+		t.Error("expect 10 bytes used after renewal, but got ", budgets[0].Used)
+	}
+	if end := time.Date(2024, time.March, 10, 16, 0, 0, 0, time.UTC); !budgets[0].WindowEnd.Equal(end) {
+		t.Error("expect window to end at ", end, ", but got ", budgets[0].WindowEnd)
+	}
+}
+
+func TestRuleBudgetMonth(t *testing.T) {
+	budget, err := newRuleBudget(&RuleBudget{Bytes: 1, OverflowTag: "b"})
+	common.Must(err)
+
+	for _, c := range []struct {
+		now time.Time
+		end time.Time
+	}{
+		{
+			now: time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC),
+			end: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			now: time.Date(2024, time.December, 15, 0, 0, 0, 0, time.UTC),
+			end: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+	} {
+		if end := budget.nextWindowEnd(c.now); !end.Equal(c.end) {
+			t.Error("at ", c.now, ": expect window to end at ", c.end, ", but got ", end)
+		}
+	}
+
+	now := time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)
+	budget.now = func() time.Time { return now }
+	budget.used.Add(5)
+	if !budget.exhausted() {
+		t.Error("expect budget exhausted")
+	}
+	now = now.Add(time.Hour)
+	if budget.exhausted() {
+		t.Error("expect budget renewed with the new month")
+	}
+}
+
+func TestRuleBudgetInvalid(t *testing.T) {
+	if _, err := newRuleBudget(&RuleBudget{Bytes: 1}); err == nil {
+		t.Error("expect error of budget without overflow tag")
+	}
+	if _, err := newRuleBudget(&RuleBudget{OverflowTag: "b"}); err == nil {
+		t.Error("expect error of budget without bytes")
+	}
+}
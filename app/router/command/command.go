@@ -68,6 +68,52 @@ func (s *routingServer) RemoveRule(ctx context.Context, request *RemoveRuleReque
 	return nil, newError("unsupported router implementation")
 }
 
+func (s *routingServer) GetRuleStats(ctx context.Context, request *GetRuleStatsRequest) (*GetRuleStatsResponse, error) {
+	reporter, ok := s.router.(routing.RuleStatsReporter)
+	if !ok {
+		return nil, newError("unsupported router implementation")
+	}
+	ruleStats, err := reporter.RuleStats(request.Reset_)
+	if err != nil {
+		return nil, err
+	}
+	response := &GetRuleStatsResponse{}
+	for _, rs := range ruleStats {
+		msg := &RuleStats{
+			Index:    int32(rs.Index),
+			RuleTag:  rs.RuleTag,
+			Hits:     rs.Hits,
+			Uplink:   rs.Uplink,
+			Downlink: rs.Downlink,
+		}
+		if !rs.LastHit.IsZero() {
+			msg.LastHit = rs.LastHit.Unix()
+		}
+		response.Rules = append(response.Rules, msg)
+	}
+	return response, nil
+}
+
+func (s *routingServer) GetRuleBudgets(ctx context.Context, request *GetRuleBudgetsRequest) (*GetRuleBudgetsResponse, error) {
+	reporter, ok := s.router.(routing.RuleBudgetReporter)
+	if !ok {
+		return nil, newError("unsupported router implementation")
+	}
+	response := &GetRuleBudgetsResponse{}
+	for _, rb := range reporter.RuleBudgets() {
+		response.Budgets = append(response.Budgets, &RuleBudget{
+			Index:       int32(rb.Index),
+			RuleTag:     rb.RuleTag,
+			Bytes:       uint64(rb.Bytes),
+			Used:        uint64(rb.Used),
+			WindowEnd:   rb.WindowEnd.Unix(),
+			OverflowTag: rb.OverflowTag,
+			Exceeded:    rb.Used >= rb.Bytes,
+		})
+	}
+	return response, nil
+}
+
 // NewRoutingServer creates a statistics service with statistics manager.
 func NewRoutingServer(router routing.Router, routingStats stats.Channel) RoutingServiceServer {
 	return &routingServer{
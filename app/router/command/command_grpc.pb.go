@@ -25,6 +25,8 @@ const (
 	RoutingService_OverrideBalancerTarget_FullMethodName = "/xray.app.router.command.RoutingService/OverrideBalancerTarget"
 	RoutingService_AddRule_FullMethodName                = "/xray.app.router.command.RoutingService/AddRule"
 	RoutingService_RemoveRule_FullMethodName             = "/xray.app.router.command.RoutingService/RemoveRule"
+	RoutingService_GetRuleStats_FullMethodName           = "/xray.app.router.command.RoutingService/GetRuleStats"
+	RoutingService_GetRuleBudgets_FullMethodName         = "/xray.app.router.command.RoutingService/GetRuleBudgets"
 )
 
 // RoutingServiceClient is the client API for RoutingService service.
@@ -37,6 +39,8 @@ type RoutingServiceClient interface {
 	OverrideBalancerTarget(ctx context.Context, in *OverrideBalancerTargetRequest, opts ...grpc.CallOption) (*OverrideBalancerTargetResponse, error)
 	AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*AddRuleResponse, error)
 	RemoveRule(ctx context.Context, in *RemoveRuleRequest, opts ...grpc.CallOption) (*RemoveRuleResponse, error)
+	GetRuleStats(ctx context.Context, in *GetRuleStatsRequest, opts ...grpc.CallOption) (*GetRuleStatsResponse, error)
+	GetRuleBudgets(ctx context.Context, in *GetRuleBudgetsRequest, opts ...grpc.CallOption) (*GetRuleBudgetsResponse, error)
 }
 
 type routingServiceClient struct {
@@ -124,6 +128,24 @@ func (c *routingServiceClient) RemoveRule(ctx context.Context, in *RemoveRuleReq
 	return out, nil
 }
 
+func (c *routingServiceClient) GetRuleStats(ctx context.Context, in *GetRuleStatsRequest, opts ...grpc.CallOption) (*GetRuleStatsResponse, error) {
+	out := new(GetRuleStatsResponse)
+	err := c.cc.Invoke(ctx, RoutingService_GetRuleStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routingServiceClient) GetRuleBudgets(ctx context.Context, in *GetRuleBudgetsRequest, opts ...grpc.CallOption) (*GetRuleBudgetsResponse, error) {
+	out := new(GetRuleBudgetsResponse)
+	err := c.cc.Invoke(ctx, RoutingService_GetRuleBudgets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RoutingServiceServer is the server API for RoutingService service.
 // All implementations must embed UnimplementedRoutingServiceServer
 // for forward compatibility
@@ -134,6 +156,8 @@ type RoutingServiceServer interface {
 	OverrideBalancerTarget(context.Context, *OverrideBalancerTargetRequest) (*OverrideBalancerTargetResponse, error)
 	AddRule(context.Context, *AddRuleRequest) (*AddRuleResponse, error)
 	RemoveRule(context.Context, *RemoveRuleRequest) (*RemoveRuleResponse, error)
+	GetRuleStats(context.Context, *GetRuleStatsRequest) (*GetRuleStatsResponse, error)
+	GetRuleBudgets(context.Context, *GetRuleBudgetsRequest) (*GetRuleBudgetsResponse, error)
 	mustEmbedUnimplementedRoutingServiceServer()
 }
 
@@ -159,6 +183,12 @@ func (UnimplementedRoutingServiceServer) AddRule(context.Context, *AddRuleReques
 func (UnimplementedRoutingServiceServer) RemoveRule(context.Context, *RemoveRuleRequest) (*RemoveRuleResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveRule not implemented")
 }
+func (UnimplementedRoutingServiceServer) GetRuleStats(context.Context, *GetRuleStatsRequest) (*GetRuleStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRuleStats not implemented")
+}
+func (UnimplementedRoutingServiceServer) GetRuleBudgets(context.Context, *GetRuleBudgetsRequest) (*GetRuleBudgetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRuleBudgets not implemented")
+}
 func (UnimplementedRoutingServiceServer) mustEmbedUnimplementedRoutingServiceServer() {}
 
 // UnsafeRoutingServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -283,6 +313,42 @@ func _RoutingService_RemoveRule_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RoutingService_GetRuleStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuleStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoutingServiceServer).GetRuleStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RoutingService_GetRuleStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoutingServiceServer).GetRuleStats(ctx, req.(*GetRuleStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RoutingService_GetRuleBudgets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuleBudgetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoutingServiceServer).GetRuleBudgets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RoutingService_GetRuleBudgets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoutingServiceServer).GetRuleBudgets(ctx, req.(*GetRuleBudgetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // RoutingService_ServiceDesc is the grpc.ServiceDesc for RoutingService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -310,6 +376,14 @@ var RoutingService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveRule",
 			Handler:    _RoutingService_RemoveRule_Handler,
 		},
+		{
+			MethodName: "GetRuleStats",
+			Handler:    _RoutingService_GetRuleStats_Handler,
+		},
+		{
+			MethodName: "GetRuleBudgets",
+			Handler:    _RoutingService_GetRuleBudgets_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
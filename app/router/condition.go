@@ -82,6 +82,24 @@ func NewMphMatcherGroup(domains []*Domain) (*DomainMatcher, error) {
 	}, nil
 }
 
+func NewSuccinctMatcherGroup(domains []*Domain) (*DomainMatcher, error) {
+	g := strmatcher.NewSuccinctMatcherGroup()
+	for _, d := range domains {
+		matcherType, f := matcherTypeMap[d.Type]
+		if !f {
+			return nil, newError("unsupported domain type", d.Type)
+		}
+		_, err := g.AddPattern(d.Value, matcherType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	g.Build()
+	return &DomainMatcher{
+		matchers: g,
+	}, nil
+}
+
 func NewDomainMatcher(domains []*Domain) (*DomainMatcher, error) {
 	g := new(strmatcher.MatcherGroup)
 	for _, d := range domains {
@@ -113,6 +131,7 @@ func (m *DomainMatcher) Apply(ctx routing.Context) bool {
 type MultiGeoIPMatcher struct {
 	matchers []*GeoIPMatcher
 	onSource bool
+	onLocal  bool
 }
 
 func NewMultiGeoIPMatcher(geoips []*GeoIP, onSource bool) (*MultiGeoIPMatcher, error) {
@@ -133,10 +152,23 @@ func NewMultiGeoIPMatcher(geoips []*GeoIP, onSource bool) (*MultiGeoIPMatcher, e
 	return matcher, nil
 }
 
+// NewInboundAddressMatcher creates a matcher of the local address connections arrive on, for servers
+// listening on several addresses.
+func NewInboundAddressMatcher(geoips []*GeoIP) (*MultiGeoIPMatcher, error) {
+	matcher, err := NewMultiGeoIPMatcher(geoips, false)
+	if err != nil {
+		return nil, err
+	}
+	matcher.onLocal = true
+	return matcher, nil
+}
+
 // Apply implements Condition.
 func (m *MultiGeoIPMatcher) Apply(ctx routing.Context) bool {
 	var ips []net.IP
-	if m.onSource {
+	if m.onLocal {
+		ips = ctx.GetLocalIPs()
+	} else if m.onSource {
 		ips = ctx.GetSourceIPs()
 	} else {
 		ips = ctx.GetTargetIPs()
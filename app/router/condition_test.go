@@ -3,6 +3,7 @@ package router_test
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"testing"
 
@@ -202,6 +203,34 @@ func TestRoutingRule(t *testing.T) {
 				},
 			},
 		},
+		{
+			rule: &RoutingRule{
+				InboundGeoip: []*GeoIP{
+					{
+						Cidr: []*CIDR{
+							{
+								Ip:     []byte{203, 0, 113, 2},
+								Prefix: 32,
+							},
+						},
+					},
+				},
+			},
+			test: []ruleTest{
+				{
+					input:  withInbound(&session.Inbound{Local: net.TCPDestination(net.ParseAddress("203.0.113.2"), 443)}),
+					output: true,
+				},
+				{
+					input:  withInbound(&session.Inbound{Local: net.TCPDestination(net.ParseAddress("203.0.113.1"), 443), Source: net.TCPDestination(net.ParseAddress("203.0.113.2"), 80)}),
+					output: false,
+				},
+				{
+					input:  withInbound(&session.Inbound{Gateway: net.TCPDestination(net.AnyIP, 443)}),
+					output: false,
+				},
+			},
+		},
 		{
 			rule: &RoutingRule{
 				UserEmail: []string{
@@ -376,6 +405,9 @@ func TestChinaSites(t *testing.T) {
 	acMatcher, err := NewMphMatcherGroup(domains)
 	common.Must(err)
 
+	succinctMatcher, err := NewSuccinctMatcherGroup(domains)
+	common.Must(err)
+
 	type TestCase struct {
 		Domain string
 		Output bool
@@ -406,10 +438,13 @@ func TestChinaSites(t *testing.T) {
 	for _, testCase := range testCases {
 		r1 := matcher.ApplyDomain(testCase.Domain)
 		r2 := acMatcher.ApplyDomain(testCase.Domain)
+		r3 := succinctMatcher.ApplyDomain(testCase.Domain)
 		if r1 != testCase.Output {
 			t.Error("DomainMatcher expected output ", testCase.Output, " for domain ", testCase.Domain, " but got ", r1)
 		} else if r2 != testCase.Output {
 			t.Error("ACDomainMatcher expected output ", testCase.Output, " for domain ", testCase.Domain, " but got ", r2)
+		} else if r3 != testCase.Output {
+			t.Error("SuccinctDomainMatcher expected output ", testCase.Output, " for domain ", testCase.Domain, " but got ", r3)
 		}
 	}
 }
@@ -456,6 +491,79 @@ func BenchmarkMphDomainMatcher(b *testing.B) {
 	}
 }
 
+func BenchmarkSuccinctDomainMatcher(b *testing.B) {
+	domains, err := loadGeoSite("CN")
+	common.Must(err)
+
+	matcher, err := NewSuccinctMatcherGroup(domains)
+	common.Must(err)
+
+	testCases := []string{"163.com", "164.com"}
+	for i := 0; i < 1024; i++ {
+		testCases = append(testCases, strconv.Itoa(i)+".not-exists.com")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, testCase := range testCases {
+			_ = matcher.ApplyDomain(testCase)
+		}
+	}
+}
+
+// domainMatcherMemory returns the heap held by five rules referring to
+// domains, either built one by one with mph or shared with succinct.
+func domainMatcherMemory(domains []*Domain) (mphBytes, succinctBytes uint64) {
+	heapInUse := func() uint64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	base := heapInUse()
+	mph := make([]*DomainMatcher, 5)
+	for j := range mph {
+		matcher, err := NewMphMatcherGroup(domains)
+		common.Must(err)
+		mph[j] = matcher
+	}
+	mphBytes = heapInUse() - base
+	runtime.KeepAlive(mph)
+	mph = nil
+
+	base = heapInUse()
+	succinct, err := NewSuccinctMatcherGroup(domains)
+	common.Must(err)
+	shared := []*DomainMatcher{succinct, succinct, succinct, succinct, succinct}
+	succinctBytes = heapInUse() - base
+	runtime.KeepAlive(shared)
+	return
+}
+
+func TestDomainMatcherMemory(t *testing.T) {
+	domains, err := loadGeoSite("CN")
+	common.Must(err)
+
+	mphBytes, succinctBytes := domainMatcherMemory(domains)
+	if succinctBytes*2 > mphBytes {
+		t.Error("expected succinct matchers to take at most half of ", mphBytes, " bytes, but got ", succinctBytes)
+	}
+}
+
+// BenchmarkDomainMatcherMemory reports the heap held by five rules referring
+// to geosite:cn, either built one by one with mph or shared with succinct.
+func BenchmarkDomainMatcherMemory(b *testing.B) {
+	domains, err := loadGeoSite("CN")
+	common.Must(err)
+
+	for i := 0; i < b.N; i++ {
+		mphBytes, succinctBytes := domainMatcherMemory(domains)
+		b.ReportMetric(float64(mphBytes), "mph-B")
+		b.ReportMetric(float64(succinctBytes), "succinct-B")
+	}
+}
+
 func BenchmarkDomainMatcher(b *testing.B) {
 	domains, err := loadGeoSite("CN")
 	common.Must(err)
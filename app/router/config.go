@@ -1,6 +1,7 @@
 package router
 
 import (
+	"crypto/sha256"
 	"regexp"
 	"strings"
 
@@ -14,6 +15,10 @@ type Rule struct {
 	RuleTag   string
 	Balancer  *Balancer
 	Condition Condition
+
+	// stats is nil unless the router keeps rule statistics.
+	stats  *ruleStats
+	budget *ruleBudget
 }
 
 func (r *Rule) GetTag() (string, error) {
@@ -28,21 +33,61 @@ func (r *Rule) Apply(ctx routing.Context) bool {
 	return r.Condition.Apply(ctx)
 }
 
+// domainMatcherStore shares compiled domain matchers between rules listing
+// the same domains, e.g. the same geosite category used in several rules.
+type domainMatcherStore map[[sha256.Size]byte]*DomainMatcher
+
+func (s domainMatcherStore) get(kind string, domains []*Domain, build func([]*Domain) (*DomainMatcher, error)) (*DomainMatcher, error) {
+	if s == nil {
+		return build(domains)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(kind))
+	for _, d := range domains {
+		h.Write([]byte{0, byte(d.Type)})
+		h.Write([]byte(d.Value))
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	if matcher, found := s[key]; found {
+		return matcher, nil
+	}
+	matcher, err := build(domains)
+	if err != nil {
+		return nil, err
+	}
+	s[key] = matcher
+	return matcher, nil
+}
+
 func (rr *RoutingRule) BuildCondition() (Condition, error) {
+	return rr.buildCondition(nil)
+}
+
+func (rr *RoutingRule) buildCondition(matchers domainMatcherStore) (Condition, error) {
 	conds := NewConditionChan()
 
 	if len(rr.Domain) > 0 {
 		switch rr.DomainMatcher {
 		case "linear":
-			matcher, err := NewDomainMatcher(rr.Domain)
+			matcher, err := matchers.get(rr.DomainMatcher, rr.Domain, NewDomainMatcher)
 			if err != nil {
 				return nil, newError("failed to build domain condition").Base(err)
 			}
 			conds.Add(matcher)
+		case "succinct":
+			matcher, err := matchers.get(rr.DomainMatcher, rr.Domain, NewSuccinctMatcherGroup)
+			if err != nil {
+				return nil, newError("failed to build domain condition with SuccinctDomainMatcher").Base(err)
+			}
+			newError("SuccinctDomainMatcher is enabled for ", len(rr.Domain), " domain rule(s)").AtDebug().WriteToLog()
+			conds.Add(matcher)
 		case "mph", "hybrid":
 			fallthrough
 		default:
-			matcher, err := NewMphMatcherGroup(rr.Domain)
+			matcher, err := matchers.get("mph", rr.Domain, NewMphMatcherGroup)
 			if err != nil {
 				return nil, newError("failed to build domain condition with MphDomainMatcher").Base(err)
 			}
@@ -103,6 +148,14 @@ func (rr *RoutingRule) BuildCondition() (Condition, error) {
 		conds.Add(cond)
 	}
 
+	if len(rr.InboundGeoip) > 0 {
+		cond, err := NewInboundAddressMatcher(rr.InboundGeoip)
+		if err != nil {
+			return nil, err
+		}
+		conds.Add(cond)
+	}
+
 	if len(rr.Protocol) > 0 {
 		conds.Add(NewProtocolMatcher(rr.Protocol))
 	}
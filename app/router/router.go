@@ -4,6 +4,8 @@ package router
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	sync "sync"
 
 	"github.com/xtls/xray-core/common"
@@ -19,6 +21,7 @@ import (
 type Router struct {
 	domainStrategy Config_DomainStrategy
 	rules          []*Rule
+	ruleStats      bool
 	balancers      map[string]*Balancer
 	dns            dns.Client
 
@@ -38,6 +41,7 @@ type Route struct {
 // Init initializes the Router.
 func (r *Router) Init(ctx context.Context, config *Config, d dns.Client, ohm outbound.Manager, dispatcher routing.Dispatcher) error {
 	r.domainStrategy = config.DomainStrategy
+	r.ruleStats = config.RuleStats
 	r.dns = d
 	r.ctx = ctx
 	r.ohm = ohm
@@ -54,8 +58,9 @@ func (r *Router) Init(ctx context.Context, config *Config, d dns.Client, ohm out
 	}
 
 	r.rules = make([]*Rule, 0, len(config.Rule))
+	matchers := make(domainMatcherStore)
 	for _, rule := range config.Rule {
-		cond, err := rule.BuildCondition()
+		cond, err := rule.buildCondition(matchers)
 		if err != nil {
 			return err
 		}
@@ -72,6 +77,16 @@ func (r *Router) Init(ctx context.Context, config *Config, d dns.Client, ohm out
 			}
 			rr.Balancer = brule
 		}
+		if r.ruleStats {
+			rr.stats = new(ruleStats)
+		}
+		if budget := rule.GetBudget(); budget != nil {
+			b, err := newRuleBudget(budget)
+			if err != nil {
+				return err
+			}
+			rr.budget = b
+		}
 		r.rules = append(r.rules, rr)
 	}
 
@@ -84,11 +99,20 @@ func (r *Router) PickRoute(ctx routing.Context) (routing.Route, error) {
 	if err != nil {
 		return nil, err
 	}
-	tag, err := rule.GetTag()
-	if err != nil {
+	budget := rule.budget
+	var tag string
+	if budget != nil && budget.exhausted() {
+		// Traffic of the overflow outbound isn't counted against the budget.
+		tag = budget.overflowTag
+		budget = nil
+	} else if tag, err = rule.GetTag(); err != nil {
 		return nil, err
 	}
-	return &Route{Context: ctx, outboundTag: tag}, nil
+	route := &Route{Context: ctx, outboundTag: tag}
+	if rule.stats != nil || budget != nil {
+		return &countedRoute{Route: route, stats: rule.stats, budget: budget}, nil
+	}
+	return route, nil
 }
 
 // AddRule implements routing.Router.
@@ -125,11 +149,12 @@ func (r *Router) ReloadRules(config *Config, shouldAppend bool) error {
 		r.balancers[rule.Tag] = balancer
 	}
 
+	matchers := make(domainMatcherStore)
 	for _, rule := range config.Rule {
 		if r.RuleExists(rule.GetRuleTag()) {
 			return newError("duplicate ruleTag ", rule.GetRuleTag())
 		}
-		cond, err := rule.BuildCondition()
+		cond, err := rule.buildCondition(matchers)
 		if err != nil {
 			return err
 		}
@@ -146,12 +171,57 @@ func (r *Router) ReloadRules(config *Config, shouldAppend bool) error {
 			}
 			rr.Balancer = brule
 		}
+		if r.ruleStats {
+			rr.stats = new(ruleStats)
+		}
+		if budget := rule.GetBudget(); budget != nil {
+			b, err := newRuleBudget(budget)
+			if err != nil {
+				return err
+			}
+			rr.budget = b
+		}
 		r.rules = append(r.rules, rr)
 	}
 
 	return nil
 }
 
+type rulesSnapshot struct {
+	rules     []*Rule
+	balancers map[string]*Balancer
+}
+
+// SnapshotRules implements routing.RulesSnapshotter.
+func (r *Router) SnapshotRules() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := &rulesSnapshot{
+		rules:     append([]*Rule(nil), r.rules...),
+		balancers: make(map[string]*Balancer, len(r.balancers)),
+	}
+	for tag, balancer := range r.balancers {
+		snapshot.balancers[tag] = balancer
+	}
+	return snapshot
+}
+
+// RestoreRules implements routing.RulesSnapshotter.
+func (r *Router) RestoreRules(snapshot interface{}) error {
+	s, ok := snapshot.(*rulesSnapshot)
+	if !ok {
+		return newError("RestoreRules: snapshot type error")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = s.rules
+	r.balancers = s.balancers
+	return nil
+}
+
 func (r *Router) RuleExists(tag string) bool {
 	if tag != "" {
 		for _, rule := range r.rules {
@@ -238,7 +308,50 @@ func (r *Route) GetOutboundTag() string {
 	return r.outboundTag
 }
 
+// validateConfig checks that the tags the rules and balancers refer to exist.
+func validateConfig(v *core.Validation, _ string, c interface{}) {
+	config := c.(*Config)
+	balancers := make(map[string]bool, len(config.BalancingRule))
+	for _, b := range config.BalancingRule {
+		balancers[b.Tag] = true
+	}
+	v.Defer(func() {
+		for i, rule := range config.Rule {
+			path := "routing.rules[" + strconv.Itoa(i) + "]"
+			if tag := rule.GetTag(); tag != "" && !v.HasOutboundTag(tag) {
+				v.Error(path+".outboundTag", "no outbound with tag ", tag)
+			}
+			if tag := rule.GetBalancingTag(); tag != "" && !balancers[tag] {
+				v.Error(path+".balancerTag", "no balancer with tag ", tag)
+			}
+			for _, tag := range rule.InboundTag {
+				if !v.HasInboundTag(tag) {
+					v.Warning(path+".inboundTag", "no inbound with tag ", tag, ", so the rule never matches it")
+				}
+			}
+		}
+		for i, b := range config.BalancingRule {
+			path := "routing.balancers[" + strconv.Itoa(i) + "]"
+			selected := false
+			for _, tag := range v.OutboundTags() {
+				for _, selector := range b.OutboundSelector {
+					if strings.HasPrefix(tag, selector) {
+						selected = true
+					}
+				}
+			}
+			if !selected {
+				v.Warning(path+".selector", "balancer ", b.Tag, " selects no outbound")
+			}
+			if b.FallbackTag != "" && !v.HasOutboundTag(b.FallbackTag) {
+				v.Error(path+".fallbackTag", "no outbound with tag ", b.FallbackTag)
+			}
+		}
+	})
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), validateConfig))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		r := new(Router)
 		if err := core.RequireFeatures(ctx, func(d dns.Client, ohm outbound.Manager, dispatcher routing.Dispatcher) error {
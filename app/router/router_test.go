@@ -3,6 +3,7 @@ package router_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/xtls/xray-core/app/router"
@@ -11,6 +12,7 @@ import (
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
 	routing_session "github.com/xtls/xray-core/features/routing/session"
 	"github.com/xtls/xray-core/testing/mocks"
 )
@@ -264,3 +266,118 @@ func TestIPIfNonMatchIP(t *testing.T) {
 		t.Error("expect tag 'test', bug actually ", tag)
 	}
 }
+
+func TestRuleStats(t *testing.T) {
+	config := &Config{
+		RuleStats: true,
+		Rule: []*RoutingRule{
+			{
+				TargetTag: &RoutingRule_Tag{
+					Tag: "tcp",
+				},
+				RuleTag:  "tcp-rule",
+				Networks: []net.Network{net.Network_TCP},
+			},
+			{
+				TargetTag: &RoutingRule_Tag{
+					Tag: "udp",
+				},
+				RuleTag:  "udp-rule",
+				Networks: []net.Network{net.Network_UDP},
+			},
+		},
+	}
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	r := new(Router)
+	common.Must(r.Init(context.TODO(), config, mocks.NewDNSClient(mockCtl), &mockOutboundManager{
+		Manager:         mocks.NewOutboundManager(mockCtl),
+		HandlerSelector: mocks.NewOutboundHandlerSelector(mockCtl),
+	}, nil))
+
+	dispatch := func(dest net.Destination, up, down int64) {
+		ctx := session.ContextWithOutbounds(context.Background(), []*session.Outbound{{Target: dest}})
+		route, err := r.PickRoute(routing_session.AsRoutingContext(ctx))
+		common.Must(err)
+		counted, ok := route.(routing.CountedRoute)
+		if !ok {
+			t.Fatal("route of ", dest, " is not counted")
+		}
+		uplink, downlink := counted.Dispatched()
+		uplink.Add(up)
+		downlink.Add(down)
+	}
+	dispatch(net.TCPDestination(net.DomainAddress("example.com"), 80), 100, 1000)
+	dispatch(net.TCPDestination(net.DomainAddress("example.com"), 443), 200, 2000)
+	dispatch(net.UDPDestination(net.LocalHostIP, 53), 10, 20)
+
+	// Picking a route without dispatching along it doesn't count.
+	ctx := session.ContextWithOutbounds(context.Background(), []*session.Outbound{{
+		Target: net.UDPDestination(net.LocalHostIP, 53),
+	}})
+	_, err := r.PickRoute(routing_session.AsRoutingContext(ctx))
+	common.Must(err)
+
+	ruleStats, err := r.RuleStats(true)
+	common.Must(err)
+	if len(ruleStats) != 2 {
+		t.Fatal("expect stats of 2 rules, but got ", len(ruleStats))
+	}
+	for i, expected := range []routing.RuleStats{
+		{Index: 0, RuleTag: "tcp-rule", Hits: 2, Uplink: 300, Downlink: 3000},
+		{Index: 1, RuleTag: "udp-rule", Hits: 1, Uplink: 10, Downlink: 20},
+	} {
+		actual := ruleStats[i]
+		if actual.LastHit.IsZero() {
+			t.Error("rule ", i, " has no last hit")
+		}
+		actual.LastHit = time.Time{}
+		if actual != expected {
+			t.Error("rule ", i, ": expect ", expected, ", but got ", actual)
+		}
+	}
+
+	ruleStats, err = r.RuleStats(false)
+	common.Must(err)
+	for i, actual := range ruleStats {
+		if actual.Hits != 0 || !actual.LastHit.IsZero() || actual.Uplink != 0 || actual.Downlink != 0 {
+			t.Error("rule ", i, " not reset: ", actual)
+		}
+	}
+}
+
+func TestRuleStatsDisabled(t *testing.T) {
+	config := &Config{
+		Rule: []*RoutingRule{
+			{
+				TargetTag: &RoutingRule_Tag{
+					Tag: "test",
+				},
+				Networks: []net.Network{net.Network_TCP},
+			},
+		},
+	}
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	r := new(Router)
+	common.Must(r.Init(context.TODO(), config, mocks.NewDNSClient(mockCtl), &mockOutboundManager{
+		Manager:         mocks.NewOutboundManager(mockCtl),
+		HandlerSelector: mocks.NewOutboundHandlerSelector(mockCtl),
+	}, nil))
+
+	ctx := session.ContextWithOutbounds(context.Background(), []*session.Outbound{{
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 80),
+	}})
+	route, err := r.PickRoute(routing_session.AsRoutingContext(ctx))
+	common.Must(err)
+	if _, ok := route.(routing.CountedRoute); ok {
+		t.Error("route is counted with rule stats disabled")
+	}
+	if _, err := r.RuleStats(false); err == nil {
+		t.Error("expect error getting rule stats while disabled")
+	}
+}
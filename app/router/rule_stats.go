@@ -0,0 +1,97 @@
+package router
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/features/routing"
+	feature_stats "github.com/xtls/xray-core/features/stats"
+)
+
+// ruleStats are the hit and traffic statistics of a rule.
+type ruleStats struct {
+	hits     atomic.Uint64
+	lastHit  atomic.Int64
+	uplink   stats.Counter
+	downlink stats.Counter
+}
+
+func (s *ruleStats) snapshot(reset bool) (hits uint64, lastHit int64, uplink int64, downlink int64) {
+	if reset {
+		return s.hits.Swap(0), s.lastHit.Swap(0), s.uplink.Set(0), s.downlink.Set(0)
+	}
+	return s.hits.Load(), s.lastHit.Load(), s.uplink.Value(), s.downlink.Value()
+}
+
+// countedRoute is a Route picked by a rule that keeps statistics, or has a budget.
+type countedRoute struct {
+	*Route
+	stats  *ruleStats
+	budget *ruleBudget
+}
+
+// Dispatched implements routing.CountedRoute.
+func (r *countedRoute) Dispatched() (feature_stats.Counter, feature_stats.Counter) {
+	var uplink, downlink feature_stats.Counter
+	if r.stats != nil {
+		r.stats.hits.Add(1)
+		r.stats.lastHit.Store(time.Now().UnixNano())
+		uplink, downlink = &r.stats.uplink, &r.stats.downlink
+	}
+	if r.budget != nil {
+		uplink = &budgetCounter{counter: uplink, budget: r.budget}
+		downlink = &budgetCounter{counter: downlink, budget: r.budget}
+	}
+	return uplink, downlink
+}
+
+// RuleStats implements routing.RuleStatsReporter.
+func (r *Router) RuleStats(reset bool) ([]routing.RuleStats, error) {
+	if !r.ruleStats {
+		return nil, newError("rule stats are not enabled")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]routing.RuleStats, 0, len(r.rules))
+	for idx, rule := range r.rules {
+		hits, lastHit, uplink, downlink := rule.stats.snapshot(reset)
+		s := routing.RuleStats{
+			Index:    idx,
+			RuleTag:  rule.RuleTag,
+			Hits:     hits,
+			Uplink:   uplink,
+			Downlink: downlink,
+		}
+		if lastHit != 0 {
+			s.LastHit = time.Unix(0, lastHit)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// RuleBudgets implements routing.RuleBudgetReporter.
+func (r *Router) RuleBudgets() []routing.RuleBudget {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result []routing.RuleBudget
+	for idx, rule := range r.rules {
+		if rule.budget == nil {
+			continue
+		}
+		used, windowEnd := rule.budget.state()
+		result = append(result, routing.RuleBudget{
+			Index:       idx,
+			RuleTag:     rule.RuleTag,
+			Bytes:       rule.budget.bytes,
+			Used:        used,
+			WindowEnd:   windowEnd,
+			OverflowTag: rule.budget.overflowTag,
+		})
+	}
+	return result
+}
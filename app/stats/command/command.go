@@ -9,9 +9,11 @@ import (
 
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/strmatcher"
 	"github.com/xtls/xray-core/core"
 	feature_stats "github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
 	grpc "google.golang.org/grpc"
 )
 
@@ -31,6 +33,17 @@ func NewStatsServer(manager feature_stats.Manager) StatsServiceServer {
 func (s *statsServer) GetStats(ctx context.Context, request *GetStatsRequest) (*GetStatsResponse, error) {
 	c := s.stats.GetCounter(request.Name)
 	if c == nil {
+		if gm, ok := s.stats.(feature_stats.GaugeManager); ok {
+			if g := gm.GetGauge(request.Name); g != nil {
+				// gauges are never reset
+				return &GetStatsResponse{
+					Stat: &Stat{
+						Name:  request.Name,
+						Value: g.Value(),
+					},
+				}, nil
+			}
+		}
 		return nil, newError(request.Name, " not found.")
 	}
 	var value int64
@@ -75,6 +88,15 @@ func (s *statsServer) QueryStats(ctx context.Context, request *QueryStatsRequest
 		}
 		return true
 	})
+	manager.VisitGauges(func(name string, g feature_stats.Counter) bool {
+		if matcher.Match(name) {
+			response.Stat = append(response.Stat, &Stat{
+				Name:  name,
+				Value: g.Value(),
+			})
+		}
+		return true
+	})
 
 	return response, nil
 }
@@ -101,6 +123,33 @@ func (s *statsServer) GetSysStats(ctx context.Context, request *SysStatsRequest)
 	return response, nil
 }
 
+func (s *statsServer) QueryTrafficState(ctx context.Context, request *QueryTrafficStateRequest) (*QueryTrafficStateResponse, error) {
+	response := &QueryTrafficStateResponse{}
+	proxy.TrafficStates(session.ID(request.SessionId), func(id session.ID, state *proxy.TrafficState) {
+		response.State = append(response.State, &TrafficState{
+			SessionId:            uint32(id),
+			PaddedPackets:        state.Stats.PaddedPackets.Load(),
+			PaddingBytes:         state.Stats.PaddingBytes.Load(),
+			UnpaddedPackets:      state.Stats.UnpaddedPackets.Load(),
+			UnpaddedBytes:        state.Stats.UnpaddedBytes.Load(),
+			WriterDirectCopyTime: unixMilli(state.Stats.WriterDirectCopyTime()),
+			ReaderDirectCopyTime: unixMilli(state.Stats.ReaderDirectCopyTime()),
+			Relay:                VisionRelay(state.Stats.Relay()),
+		})
+	})
+	if request.SessionId != 0 && len(response.State) == 0 {
+		return nil, newError("session ", request.SessionId, " has no Vision traffic state.")
+	}
+	return response, nil
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
 func (s *statsServer) mustEmbedUnimplementedStatsServiceServer() {}
 
 type service struct {
@@ -19,9 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	StatsService_GetStats_FullMethodName    = "/xray.app.stats.command.StatsService/GetStats"
-	StatsService_QueryStats_FullMethodName  = "/xray.app.stats.command.StatsService/QueryStats"
-	StatsService_GetSysStats_FullMethodName = "/xray.app.stats.command.StatsService/GetSysStats"
+	StatsService_GetStats_FullMethodName          = "/xray.app.stats.command.StatsService/GetStats"
+	StatsService_QueryStats_FullMethodName        = "/xray.app.stats.command.StatsService/QueryStats"
+	StatsService_GetSysStats_FullMethodName       = "/xray.app.stats.command.StatsService/GetSysStats"
+	StatsService_QueryTrafficState_FullMethodName = "/xray.app.stats.command.StatsService/QueryTrafficState"
 )
 
 // StatsServiceClient is the client API for StatsService service.
@@ -31,6 +32,7 @@ type StatsServiceClient interface {
 	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 	QueryStats(ctx context.Context, in *QueryStatsRequest, opts ...grpc.CallOption) (*QueryStatsResponse, error)
 	GetSysStats(ctx context.Context, in *SysStatsRequest, opts ...grpc.CallOption) (*SysStatsResponse, error)
+	QueryTrafficState(ctx context.Context, in *QueryTrafficStateRequest, opts ...grpc.CallOption) (*QueryTrafficStateResponse, error)
 }
 
 type statsServiceClient struct {
@@ -68,6 +70,15 @@ func (c *statsServiceClient) GetSysStats(ctx context.Context, in *SysStatsReques
 	return out, nil
 }
 
+func (c *statsServiceClient) QueryTrafficState(ctx context.Context, in *QueryTrafficStateRequest, opts ...grpc.CallOption) (*QueryTrafficStateResponse, error) {
+	out := new(QueryTrafficStateResponse)
+	err := c.cc.Invoke(ctx, StatsService_QueryTrafficState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StatsServiceServer is the server API for StatsService service.
 // All implementations must embed UnimplementedStatsServiceServer
 // for forward compatibility
@@ -75,6 +86,7 @@ type StatsServiceServer interface {
 	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	QueryStats(context.Context, *QueryStatsRequest) (*QueryStatsResponse, error)
 	GetSysStats(context.Context, *SysStatsRequest) (*SysStatsResponse, error)
+	QueryTrafficState(context.Context, *QueryTrafficStateRequest) (*QueryTrafficStateResponse, error)
 	mustEmbedUnimplementedStatsServiceServer()
 }
 
@@ -91,6 +103,9 @@ func (UnimplementedStatsServiceServer) QueryStats(context.Context, *QueryStatsRe
 func (UnimplementedStatsServiceServer) GetSysStats(context.Context, *SysStatsRequest) (*SysStatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSysStats not implemented")
 }
+func (UnimplementedStatsServiceServer) QueryTrafficState(context.Context, *QueryTrafficStateRequest) (*QueryTrafficStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryTrafficState not implemented")
+}
 func (UnimplementedStatsServiceServer) mustEmbedUnimplementedStatsServiceServer() {}
 
 // UnsafeStatsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -158,6 +173,24 @@ func _StatsService_GetSysStats_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StatsService_QueryTrafficState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryTrafficStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).QueryTrafficState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatsService_QueryTrafficState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).QueryTrafficState(ctx, req.(*QueryTrafficStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StatsService_ServiceDesc is the grpc.ServiceDesc for StatsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -177,6 +210,10 @@ var StatsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetSysStats",
 			Handler:    _StatsService_GetSysStats_Handler,
 		},
+		{
+			MethodName: "QueryTrafficState",
+			Handler:    _StatsService_QueryTrafficState_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "app/stats/command/command.proto",
@@ -89,3 +89,42 @@ func TestQueryStats(t *testing.T) {
 		t.Error(r)
 	}
 }
+
+func TestQueryStatsGauge(t *testing.T) {
+	m, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+
+	c, err := m.RegisterCounter("inbound>>>in>>>traffic>>>uplink")
+	common.Must(err)
+	c.Set(10)
+	g, err := m.RegisterGauge("inbound>>>in>>>connections")
+	common.Must(err)
+	g.Set(3)
+
+	s := NewStatsServer(m)
+	resp, err := s.QueryStats(context.Background(), &QueryStatsRequest{
+		Pattern: "inbound>>>in>>>",
+		Reset_:  true,
+	})
+	common.Must(err)
+	if r := cmp.Diff(resp.Stat, []*Stat{
+		{Name: "inbound>>>in>>>connections", Value: 3},
+		{Name: "inbound>>>in>>>traffic>>>uplink", Value: 10},
+	}, cmpopts.SortSlices(func(s1, s2 *Stat) bool { return s1.Name < s2.Name }),
+		cmpopts.IgnoreUnexported(Stat{})); r != "" {
+		t.Error(r)
+	}
+
+	// the counter is reset, the gauge is not
+	if c.Value() != 0 || g.Value() != 3 {
+		t.Error("unexpected values after reset: ", c.Value(), " ", g.Value())
+	}
+	resp2, err := s.GetStats(context.Background(), &GetStatsRequest{
+		Name:   "inbound>>>in>>>connections",
+		Reset_: true,
+	})
+	common.Must(err)
+	if resp2.Stat.Value != 3 || g.Value() != 3 {
+		t.Error("gauge reset by GetStats")
+	}
+}
@@ -15,6 +15,7 @@ import (
 type Manager struct {
 	access   sync.RWMutex
 	counters map[string]*Counter
+	gauges   map[string]*Counter
 	channels map[string]*Channel
 	running  bool
 }
@@ -23,6 +24,7 @@ type Manager struct {
 func NewManager(ctx context.Context, config *Config) (*Manager, error) {
 	m := &Manager{
 		counters: make(map[string]*Counter),
+		gauges:   make(map[string]*Counter),
 		channels: make(map[string]*Channel),
 	}
 
@@ -42,6 +44,9 @@ func (m *Manager) RegisterCounter(name string) (stats.Counter, error) {
 	if _, found := m.counters[name]; found {
 		return nil, newError("Counter ", name, " already registered.")
 	}
+	if _, found := m.gauges[name]; found {
+		return nil, newError("Gauge ", name, " already registered.")
+	}
 	newError("create new counter ", name).AtDebug().WriteToLog()
 	c := new(Counter)
 	m.counters[name] = c
@@ -83,6 +88,58 @@ func (m *Manager) VisitCounters(visitor func(string, stats.Counter) bool) {
 	}
 }
 
+// RegisterGauge implements stats.GaugeManager.
+func (m *Manager) RegisterGauge(name string) (stats.Counter, error) {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if _, found := m.gauges[name]; found {
+		return nil, newError("Gauge ", name, " already registered.")
+	}
+	if _, found := m.counters[name]; found {
+		return nil, newError("Counter ", name, " already registered.")
+	}
+	newError("create new gauge ", name).AtDebug().WriteToLog()
+	g := new(Counter)
+	m.gauges[name] = g
+	return g, nil
+}
+
+// UnregisterGauge implements stats.GaugeManager.
+func (m *Manager) UnregisterGauge(name string) error {
+	m.access.Lock()
+	defer m.access.Unlock()
+
+	if _, found := m.gauges[name]; found {
+		newError("remove gauge ", name).AtDebug().WriteToLog()
+		delete(m.gauges, name)
+	}
+	return nil
+}
+
+// GetGauge implements stats.GaugeManager.
+func (m *Manager) GetGauge(name string) stats.Counter {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	if g, found := m.gauges[name]; found {
+		return g
+	}
+	return nil
+}
+
+// VisitGauges calls visitor function on all managed gauges.
+func (m *Manager) VisitGauges(visitor func(string, stats.Counter) bool) {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	for name, g := range m.gauges {
+		if !visitor(name, g) {
+			break
+		}
+	}
+}
+
 // RegisterChannel implements stats.Manager.
 func (m *Manager) RegisterChannel(name string) (stats.Channel, error) {
 	m.access.Lock()
@@ -12,6 +12,28 @@ import (
 
 func TestInterface(t *testing.T) {
 	_ = (stats.Manager)(new(Manager))
+	_ = (stats.GaugeManager)(new(Manager))
+}
+
+func TestGaugeRegistration(t *testing.T) {
+	m, err := NewManager(context.Background(), &Config{})
+	common.Must(err)
+
+	common.Must2(m.RegisterCounter("counter"))
+	if _, err := m.RegisterGauge("counter"); err == nil {
+		t.Error("gauge registered with the name of a counter")
+	}
+	g, err := stats.GetOrRegisterGauge(m, "gauge")
+	common.Must(err)
+	if _, err := m.RegisterCounter("gauge"); err == nil {
+		t.Error("counter registered with the name of a gauge")
+	}
+	if g2, _ := stats.GetOrRegisterGauge(m, "gauge"); g2 != g {
+		t.Error("gauge registered twice")
+	}
+	if m.GetCounter("gauge") != nil {
+		t.Error("gauge returned as counter")
+	}
 }
 
 func TestStatsChannelRunnable(t *testing.T) {
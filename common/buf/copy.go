@@ -12,7 +12,8 @@ import (
 type dataHandler func(MultiBuffer)
 
 type copyHandler struct {
-	onData []dataHandler
+	onData     []dataHandler
+	maxLatency time.Duration
 }
 
 // SizeCounter is for counting bytes copied by Copy().
@@ -52,6 +53,34 @@ func AddToStatCounter(sc stats.Counter) CopyOption {
 	}
 }
 
+// Flusher is a Writer which may hold data back until Flush is called,
+// such as a BufferedWriter.
+type Flusher interface {
+	Flush() error
+}
+
+// FlushWriter is a Writer layered over a Flusher further down the chain,
+// such as a protocol writer over a BufferedWriter, so that Copy can flush it.
+type FlushWriter struct {
+	Writer
+	Flusher Flusher
+}
+
+// Flush implements Flusher.
+func (w *FlushWriter) Flush() error {
+	return w.Flusher.Flush()
+}
+
+// WithMaxLatency is a CopyOption bounding how long copied data may wait in
+// the writer: when the writer is a Flusher, it is flushed once the oldest
+// unflushed byte is older than d. Reads are bounded by the same deadline, so
+// this needs the reader to be a TimeoutReader and takes no extra goroutine.
+func WithMaxLatency(d time.Duration) CopyOption {
+	return func(handler *copyHandler) {
+		handler.maxLatency = d
+	}
+}
+
 type readError struct {
 	error
 }
@@ -89,6 +118,13 @@ func IsWriteError(err error) bool {
 }
 
 func copyInternal(reader Reader, writer Writer, handler *copyHandler) error {
+	if handler.maxLatency > 0 {
+		timeoutReader, ok := reader.(TimeoutReader)
+		flusher, ok2 := writer.(Flusher)
+		if ok && ok2 {
+			return copyWithMaxLatency(reader, timeoutReader, writer, flusher, handler)
+		}
+	}
 	for {
 		buffer, err := reader.ReadMultiBuffer()
 		if !buffer.IsEmpty() {
@@ -107,6 +143,47 @@ func copyInternal(reader Reader, writer Writer, handler *copyHandler) error {
 	}
 }
 
+func copyWithMaxLatency(reader Reader, timeoutReader TimeoutReader, writer Writer, flusher Flusher, handler *copyHandler) error {
+	// when the oldest unflushed byte was written, zero if nothing is pending
+	var pendingSince time.Time
+	for {
+		var buffer MultiBuffer
+		var err error
+		if pendingSince.IsZero() {
+			buffer, err = reader.ReadMultiBuffer()
+		} else if wait := handler.maxLatency - time.Since(pendingSince); wait > 0 {
+			buffer, err = timeoutReader.ReadMultiBufferTimeout(wait)
+			if err == ErrReadTimeout {
+				err = nil
+			}
+		}
+
+		if !buffer.IsEmpty() {
+			for _, handler := range handler.onData {
+				handler(buffer)
+			}
+
+			if werr := writer.WriteMultiBuffer(buffer); werr != nil {
+				return writeError{werr}
+			}
+			if pendingSince.IsZero() {
+				pendingSince = time.Now()
+			}
+		}
+
+		if !pendingSince.IsZero() && (err != nil || time.Since(pendingSince) >= handler.maxLatency) {
+			if werr := flusher.Flush(); werr != nil {
+				return writeError{werr}
+			}
+			pendingSince = time.Time{}
+		}
+
+		if err != nil {
+			return readError{err}
+		}
+	}
+}
+
 // Copy dumps all payload from reader to writer or stops when an error occurs. It returns nil when EOF.
 func Copy(reader Reader, writer Writer, options ...CopyOption) error {
 	var handler copyHandler
@@ -3,12 +3,15 @@ package buf_test
 import (
 	"crypto/rand"
 	"io"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/testing/mocks"
+	"github.com/xtls/xray-core/transport/pipe"
 )
 
 func TestReadError(t *testing.T) {
@@ -53,6 +56,61 @@ func TestWriteError(t *testing.T) {
 	}
 }
 
+type arrivalWriter struct {
+	sync.Mutex
+	arrivals map[byte]time.Time
+}
+
+func (w *arrivalWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.Lock()
+	defer w.Unlock()
+	for _, b := range mb {
+		for _, c := range b.Bytes() {
+			w.arrivals[c] = time.Now()
+		}
+	}
+	buf.ReleaseMulti(mb)
+	return nil
+}
+
+func TestCopyWithMaxLatency(t *testing.T) {
+	const (
+		count      = 40
+		interval   = 5 * time.Millisecond
+		maxLatency = 30 * time.Millisecond
+	)
+
+	reader, writer := pipe.New(pipe.WithoutSizeLimit())
+	sent := make([]time.Time, count)
+	go func() {
+		for i := 0; i < count; i++ {
+			sent[i] = time.Now()
+			b := buf.New()
+			b.WriteByte(byte(i))
+			writer.WriteMultiBuffer(buf.MultiBuffer{b})
+			time.Sleep(interval)
+		}
+		writer.Close()
+	}()
+
+	sink := &arrivalWriter{arrivals: make(map[byte]time.Time)}
+	// without flushing, the dribble would stay in the buffer until the end
+	if err := buf.Copy(reader, buf.NewBufferedWriter(sink), buf.WithMaxLatency(maxLatency)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < count; i++ {
+		arrival, found := sink.arrivals[byte(i)]
+		if !found {
+			t.Fatal("byte ", i, " not delivered")
+		}
+		// some slack for the scheduler
+		if latency := arrival.Sub(sent[i]); latency > maxLatency+20*time.Millisecond {
+			t.Error("byte ", i, " delivered after ", latency)
+		}
+	}
+}
+
 type TestReader struct{}
 
 func (TestReader) Read(b []byte) (int, error) {
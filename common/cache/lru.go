@@ -11,6 +11,10 @@ type Lru interface {
 	GetKeyFromValue(value interface{}) (key interface{}, ok bool)
 	PeekKeyFromValue(value interface{}) (key interface{}, ok bool) // Peek means check but NOT bring to top
 	Put(key, value interface{})
+	// Remove removes key, and returns its value
+	Remove(key interface{}) (value interface{}, ok bool)
+	// Range calls f for each key and value, from the least recently used one, until f returns false
+	Range(f func(key, value interface{}) bool)
 }
 
 type lru struct {
@@ -19,6 +23,7 @@ type lru struct {
 	keyToElement     *sync.Map
 	valueToElement   *sync.Map
 	mu               *sync.Mutex
+	evicted          func(key, value interface{})
 }
 
 type lruElement struct {
@@ -37,6 +42,14 @@ func NewLru(cap int) Lru {
 	}
 }
 
+// NewLruWithEviction initializes a lru cache, which calls evicted with the key and the value of each
+// entry it evicts to make room, while it is locked
+func NewLruWithEviction(cap int, evicted func(key, value interface{})) Lru {
+	l := NewLru(cap).(*lru)
+	l.evicted = evicted
+	return l
+}
+
 func (l *lru) Get(key interface{}) (value interface{}, ok bool) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -83,7 +96,34 @@ func (l *lru) Put(key, value interface{}) {
 			l.doubleLinkedlist.Remove(toBeRemove)
 			l.keyToElement.Delete(toBeRemove.Value.(*lruElement).key)
 			l.valueToElement.Delete(toBeRemove.Value.(*lruElement).value)
+			if l.evicted != nil {
+				l.evicted(toBeRemove.Value.(*lruElement).key, toBeRemove.Value.(*lruElement).value)
+			}
 		}
 	}
 	l.mu.Unlock()
 }
+
+func (l *lru) Remove(key interface{}) (value interface{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.keyToElement.Load(key); ok {
+		element := v.(*list.Element)
+		l.doubleLinkedlist.Remove(element)
+		l.keyToElement.Delete(key)
+		l.valueToElement.Delete(element.Value.(*lruElement).value)
+		return element.Value.(*lruElement).value, true
+	}
+	return nil, false
+}
+
+func (l *lru) Range(f func(key, value interface{}) bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for element := l.doubleLinkedlist.Back(); element != nil; element = element.Prev() {
+		e := element.Value.(*lruElement)
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
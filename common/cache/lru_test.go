@@ -84,3 +84,52 @@ func TestPeekKeyFromValue(t *testing.T) {
 		t.Error("should get 2", v)
 	}
 }
+
+func TestLruRange(t *testing.T) {
+	lru := NewLru(3)
+	lru.Put(1, 1)
+	lru.Put(2, 2)
+	lru.Put(3, 3)
+	lru.Get(1)
+	var keys []interface{}
+	lru.Range(func(key, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 3 || keys[0] != 2 || keys[1] != 3 || keys[2] != 1 {
+		t.Error("should range from the least recently used, but got ", keys)
+	}
+}
+
+func TestLruEviction(t *testing.T) {
+	var evicted []interface{}
+	lru := NewLruWithEviction(2, func(key, value interface{}) {
+		evicted = append(evicted, key, value)
+	})
+	lru.Put(1, 10)
+	lru.Put(2, 20)
+	lru.Get(1)
+	lru.Put(3, 30)
+	if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != 20 {
+		t.Error("should evict 2, but got ", evicted)
+	}
+}
+
+func TestLruRemove(t *testing.T) {
+	lru := NewLru(2)
+	lru.Put(1, 10)
+	lru.Put(2, 20)
+	if v, ok := lru.Remove(1); !ok || v != 10 {
+		t.Error("should remove 1, but got ", v)
+	}
+	if _, ok := lru.Remove(1); ok {
+		t.Error("should not remove 1 twice")
+	}
+	if _, ok := lru.PeekKeyFromValue(10); ok {
+		t.Error("should not find the value removed")
+	}
+	lru.Put(3, 30)
+	if _, ok := lru.Get(2); !ok {
+		t.Error("should keep 2 after a removal made room")
+	}
+}
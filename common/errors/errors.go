@@ -71,6 +71,29 @@ func (err *Error) Error() string {
 	return builder.String()
 }
 
+// Template returns the message of the error with everything but its leading
+// text replaced by placeholders. Errors created at the same place share the
+// template, whatever values they carry.
+func (err *Error) Template() string {
+	builder := strings.Builder{}
+
+	path := err.pkgPath()
+	if len(path) > 0 {
+		builder.WriteString(path)
+		builder.WriteString(": ")
+	}
+
+	for i, m := range err.message {
+		if s, ok := m.(string); ok && i == 0 {
+			builder.WriteString(s)
+		} else {
+			builder.WriteString("%v")
+		}
+	}
+
+	return builder.String()
+}
+
 // Unwrap implements hasInnerError.Unwrap()
 func (err *Error) Unwrap() error {
 	if err.inner == nil {
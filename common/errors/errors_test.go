@@ -59,3 +59,17 @@ func TestErrorMessage(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorTemplate(t *testing.T) {
+	a := New("failed to dial ", "1.1.1.1", ":", 443).Base(io.EOF)
+	b := New("failed to dial ", "8.8.8.8", ":", 53)
+	if a.Template() != b.Template() {
+		t.Error("different templates: ", a.Template(), " ", b.Template())
+	}
+	if r := cmp.Diff(a.Template(), "failed to dial %v%v%v"); r != "" {
+		t.Error(r)
+	}
+	if New("failed to listen ", 443).Template() == a.Template() {
+		t.Error("same template for different messages")
+	}
+}
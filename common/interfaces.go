@@ -17,6 +17,14 @@ type Interruptible interface {
 	Interrupt()
 }
 
+// ErrorInterruptible is an interface for objects that can be stopped with the cause of the stop,
+// which their counterparts get from their next operation.
+//
+// xray:api:beta
+type ErrorInterruptible interface {
+	InterruptWithError(err error)
+}
+
 // Close closes the obj if it is a Closable.
 //
 // xray:api:beta
@@ -38,6 +46,17 @@ func Interrupt(obj interface{}) error {
 	return Close(obj)
 }
 
+// InterruptWithError calls InterruptWithError() if object implements ErrorInterruptible interface, or Interrupt() otherwise.
+//
+// xray:api:beta
+func InterruptWithError(obj interface{}, err error) error {
+	if c, ok := obj.(ErrorInterruptible); ok {
+		c.InterruptWithError(err)
+		return nil
+	}
+	return Interrupt(obj)
+}
+
 // Runnable is the interface for objects that can start to work and stop on demand.
 type Runnable interface {
 	// Start starts the runnable object. Upon the method returning nil, the object begins to function properly.
@@ -27,6 +27,8 @@ type AccessMessage struct {
 	Reason interface{}
 	Email  string
 	Detour string
+	// Bridge identifies the reverse proxy bridge relaying the connection.
+	Bridge string
 }
 
 func (m *AccessMessage) String() string {
@@ -53,6 +55,11 @@ func (m *AccessMessage) String() string {
 		builder.WriteString(m.Email)
 	}
 
+	if len(m.Bridge) > 0 {
+		builder.WriteString(" bridge: ")
+		builder.WriteString(m.Bridge)
+	}
+
 	return builder.String()
 }
 
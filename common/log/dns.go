@@ -7,12 +7,13 @@ import (
 )
 
 type DNSLog struct {
-	Server  string
-	Domain  string
-	Result  []net.IP
-	Status  dnsStatus
-	Elapsed time.Duration
-	Error   error
+	Server    string
+	Domain    string
+	Result    []net.IP
+	Rewritten string
+	Status    dnsStatus
+	Elapsed   time.Duration
+	Error     error
 }
 
 func (l *DNSLog) String() string {
@@ -24,6 +25,12 @@ func (l *DNSLog) String() string {
 	builder.WriteString(string(l.Status))
 	builder.WriteString(" ")
 	builder.WriteString(l.Domain)
+	if l.Status == DNSRewritten {
+		// Server rewritten: domain -> rewritten.domain
+		builder.WriteString(" -> ")
+		builder.WriteString(l.Rewritten)
+		return builder.String()
+	}
 	builder.WriteString(" -> [")
 	builder.WriteString(joinNetIP(l.Result))
 	builder.WriteString("]")
@@ -43,8 +50,9 @@ func (l *DNSLog) String() string {
 type dnsStatus string
 
 var (
-	DNSQueried  = dnsStatus("got answer:")
-	DNSCacheHit = dnsStatus("cache HIT:")
+	DNSQueried   = dnsStatus("got answer:")
+	DNSCacheHit  = dnsStatus("cache HIT:")
+	DNSRewritten = dnsStatus("rewritten:")
 )
 
 func joinNetIP(ips []net.IP) string {
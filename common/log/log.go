@@ -16,6 +16,12 @@ type Handler interface {
 	Handle(msg Message)
 }
 
+// HasTemplate is implemented by log contents built from a fixed template and
+// varying values, such as errors.
+type HasTemplate interface {
+	Template() string
+}
+
 // GeneralMessage is a general log message that can contain all kind of content.
 type GeneralMessage struct {
 	Severity Severity
@@ -167,6 +167,14 @@ func (f *DialingWorkerFactory) Create() (*ClientWorker, error) {
 type ClientStrategy struct {
 	MaxConcurrency uint32
 	MaxConnection  uint32
+	// MaxReuseTime is how long new connections are assigned to a worker. Once
+	// it has passed, the worker is closed after its last connection ends, or
+	// after DrainTimeout.
+	MaxReuseTime time.Duration
+	// DrainTimeout is how long a worker past MaxReuseTime waits for its last
+	// connection. 0 waits for as long as any connection is left, however long
+	// that takes.
+	DrainTimeout time.Duration
 }
 
 type ClientWorker struct {
@@ -174,6 +182,7 @@ type ClientWorker struct {
 	link           transport.Link
 	done           *done.Instance
 	strategy       ClientStrategy
+	created        time.Time
 }
 
 var (
@@ -188,6 +197,7 @@ func NewClientWorker(stream transport.Link, s ClientStrategy) (*ClientWorker, er
 		link:           stream,
 		done:           done.New(),
 		strategy:       s,
+		created:        time.Now(),
 	}
 
 	go c.fetchOutput()
@@ -209,8 +219,17 @@ func (m *ClientWorker) Closed() bool {
 	return m.done.Done()
 }
 
+// draining returns true if no new connections are assigned to this worker anymore.
+func (m *ClientWorker) draining() bool {
+	return m.strategy.MaxReuseTime > 0 && time.Since(m.created) >= m.strategy.MaxReuseTime
+}
+
 func (m *ClientWorker) monitor() {
-	timer := time.NewTicker(time.Second * 16)
+	interval := time.Second * 16
+	if reuse := m.strategy.MaxReuseTime; reuse > 0 && reuse/4 < interval {
+		interval = reuse / 4
+	}
+	timer := time.NewTicker(interval)
 	defer timer.Stop()
 
 	for {
@@ -224,6 +243,9 @@ func (m *ClientWorker) monitor() {
 			size := m.sessionManager.Size()
 			if size == 0 && m.sessionManager.CloseIfNoSession() {
 				common.Must(m.done.Close())
+			} else if m.draining() && m.strategy.DrainTimeout > 0 && time.Since(m.created) >= m.strategy.MaxReuseTime+m.strategy.DrainTimeout {
+				newError("closing drained mux connection with ", size, " connections left").AtInfo().WriteToLog()
+				common.Must(m.done.Close())
 			}
 		}
 	}
@@ -273,7 +295,7 @@ func (m *ClientWorker) IsClosing() bool {
 	if m.strategy.MaxConnection > 0 && sm.Count() >= int(m.strategy.MaxConnection) {
 		return true
 	}
-	return false
+	return m.draining()
 }
 
 func (m *ClientWorker) IsFull() bool {
@@ -2,17 +2,22 @@ package mux_test
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/mux"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/testing/mocks"
 	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/pipe"
 )
 
@@ -114,3 +119,129 @@ func TestClientWorkerClose(t *testing.T) {
 
 	common.Must(w2.Close())
 }
+
+// echoDispatcher sends every connection back to where it came from.
+type echoDispatcher struct{}
+
+func (echoDispatcher) Type() interface{} {
+	return routing.DispatcherType()
+}
+
+func (echoDispatcher) Start() error {
+	return nil
+}
+
+func (echoDispatcher) Close() error {
+	return nil
+}
+
+func (echoDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	upReader, upWriter := pipe.New(pipe.WithoutSizeLimit())
+	downReader, downWriter := pipe.New(pipe.WithoutSizeLimit())
+	go func() {
+		buf.Copy(upReader, downWriter)
+		downWriter.Close()
+	}()
+	return &transport.Link{Reader: downReader, Writer: upWriter}, nil
+}
+
+func (d echoDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+// muxServerOutbound serves each transport connection with a mux server.
+type muxServerOutbound struct {
+	dials atomic.Int32
+}
+
+func (o *muxServerOutbound) Process(ctx context.Context, link *transport.Link, d internet.Dialer) error {
+	o.dials.Add(1)
+	worker, err := mux.NewServerWorker(ctx, echoDispatcher{}, link)
+	if err != nil {
+		return err
+	}
+	for !worker.Closed() {
+		time.Sleep(time.Millisecond * 10)
+	}
+	return nil
+}
+
+// echoFlow sends messages at interval over one mux connection and checks
+// that every one of them comes back.
+func echoFlow(manager *mux.ClientManager, messages int, interval time.Duration) error {
+	inputReader, inputWriter := pipe.New(pipe.WithoutSizeLimit())
+	outputReader, outputWriter := pipe.New(pipe.WithoutSizeLimit())
+	ctx := session.ContextWithOutbounds(context.Background(), []*session.Outbound{{
+		Target: net.TCPDestination(net.DomainAddress("www.example.com"), 80),
+	}})
+	if err := manager.Dispatch(ctx, &transport.Link{Reader: inputReader, Writer: outputWriter}); err != nil {
+		return err
+	}
+	defer inputWriter.Close()
+
+	for i := 0; i < messages; i++ {
+		payload := []byte{byte(i), 'x', 'r', 'a', 'y'}
+		if err := inputWriter.WriteMultiBuffer(buf.MergeBytes(nil, payload)); err != nil {
+			return errors.New("failed to send message ", i).Base(err)
+		}
+		var echo []byte
+		for len(echo) < len(payload) {
+			mb, err := outputReader.ReadMultiBufferTimeout(time.Second * 2)
+			if err != nil {
+				return errors.New("interrupted at message ", i).Base(err)
+			}
+			b := make([]byte, mb.Len())
+			mb.Copy(b)
+			buf.ReleaseMulti(mb)
+			echo = append(echo, b...)
+		}
+		if string(echo) != string(payload) {
+			return errors.New("unexpected echo of message ", i)
+		}
+		time.Sleep(interval)
+	}
+	return nil
+}
+
+func TestClientWorkerRotation(t *testing.T) {
+	outbound := &muxServerOutbound{}
+	manager := &mux.ClientManager{
+		Enabled: true,
+		Picker: &mux.IncrementalWorkerPicker{
+			Factory: &mux.DialingWorkerFactory{
+				Proxy: outbound,
+				Strategy: mux.ClientStrategy{
+					MaxConcurrency: 8,
+					MaxConnection:  128,
+					MaxReuseTime:   time.Millisecond * 300,
+					DrainTimeout:   time.Second * 10,
+				},
+			},
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	flow := func(delay time.Duration, messages int) {
+		defer wg.Done()
+		time.Sleep(delay)
+		if err := echoFlow(manager, messages, time.Millisecond*50); err != nil {
+			errs <- err
+		}
+	}
+	wg.Add(4)
+	// the first two span the rotation, the others start after it
+	go flow(0, 20)
+	go flow(time.Millisecond*100, 16)
+	go flow(time.Millisecond*500, 10)
+	go flow(time.Millisecond*600, 4)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if dials := outbound.dials.Load(); dials != 2 {
+		t.Error("expected 2 transport dials, got ", dials)
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/quic"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/pipe"
 )
@@ -79,6 +82,7 @@ type ServerWorker struct {
 	dispatcher     routing.Dispatcher
 	link           *transport.Link
 	sessionManager *SessionManager
+	stats          stats.Manager
 }
 
 func NewServerWorker(ctx context.Context, d routing.Dispatcher, link *transport.Link) (*ServerWorker, error) {
@@ -87,6 +91,9 @@ func NewServerWorker(ctx context.Context, d routing.Dispatcher, link *transport.
 		link:           link,
 		sessionManager: NewSessionManager(),
 	}
+	if v := core.FromContext(ctx); v != nil {
+		worker.stats, _ = v.GetFeature(stats.ManagerType()).(stats.Manager)
+	}
 	go worker.run(ctx)
 	return worker, nil
 }
@@ -143,6 +150,22 @@ func (w *ServerWorker) handleStatusNew(ctx context.Context, meta *FrameMetadata,
 		if err != nil {
 			return err
 		}
+		// the first packet of a hit is not dispatched again, so it is checked here
+		if err := policy.CheckDestination(ctx, w.stats, meta.Target); err != nil {
+			buf.ReleaseMulti(mb)
+			closingWriter := NewResponseWriter(meta.SessionID, w.link.Writer, protocol.TransferTypePacket)
+			closingWriter.Close()
+			newError("XUDP new ", meta.GlobalID).Base(err).WriteToLog(session.ExportIDToError(ctx))
+			return nil
+		}
+		if inbound := session.InboundFromContext(ctx); inbound != nil && !mb.IsEmpty() && quic.ShouldReject(inbound.User, meta.Target, mb[0].Bytes()) {
+			buf.ReleaseMulti(mb)
+			// only this sub-connection is refused, so that the client falls back to TCP
+			closingWriter := NewResponseWriter(meta.SessionID, w.link.Writer, protocol.TransferTypePacket)
+			closingWriter.Close()
+			newError("QUIC to ", meta.Target, " is refused for ", inbound.User.Email).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			return nil
+		}
 		XUDPManager.Lock()
 		x := XUDPManager.Map[meta.GlobalID]
 		if x == nil {
@@ -237,11 +260,18 @@ func (w *ServerWorker) handleStatusNew(ctx context.Context, meta *FrameMetadata,
 	return nil
 }
 
-func (w *ServerWorker) handleStatusKeep(meta *FrameMetadata, reader *buf.BufferedReader) error {
+func (w *ServerWorker) handleStatusKeep(ctx context.Context, meta *FrameMetadata, reader *buf.BufferedReader) error {
 	if !meta.Option.Has(OptionData) {
 		return nil
 	}
 
+	// XUDP packets carry their own destinations, which the dispatcher never sees
+	if meta.Target.Network == net.Network_UDP {
+		if err := policy.CheckDestination(ctx, w.stats, meta.Target); err != nil {
+			return buf.Copy(NewStreamReader(reader), buf.Discard)
+		}
+	}
+
 	s, found := w.sessionManager.Get(meta.SessionID)
 	if !found {
 		// Notify remote peer to close this session.
@@ -288,7 +318,7 @@ func (w *ServerWorker) handleFrame(ctx context.Context, reader *buf.BufferedRead
 	case SessionStatusNew:
 		err = w.handleStatusNew(ctx, &meta, reader)
 	case SessionStatusKeep:
-		err = w.handleStatusKeep(&meta, reader)
+		err = w.handleStatusKeep(ctx, &meta, reader)
 	default:
 		status := meta.SessionStatus
 		return newError("unknown status: ", status).AtError()
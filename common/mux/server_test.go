@@ -0,0 +1,96 @@
+package mux_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/mux"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// packetDispatcher hands out the uplink of every dispatched connection.
+type packetDispatcher struct {
+	uplinks chan *pipe.Reader
+}
+
+func (packetDispatcher) Type() interface{} {
+	return routing.DispatcherType()
+}
+
+func (packetDispatcher) Start() error {
+	return nil
+}
+
+func (packetDispatcher) Close() error {
+	return nil
+}
+
+func (d packetDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	upReader, upWriter := pipe.New(pipe.WithoutSizeLimit())
+	downReader, _ := pipe.New(pipe.WithoutSizeLimit())
+	d.uplinks <- upReader
+	return &transport.Link{Reader: downReader, Writer: upWriter}, nil
+}
+
+func (packetDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+func TestXUDPTargetPortPerPacket(t *testing.T) {
+	blocked := protocol.NewPortRestriction(nil, &net.PortList{Range: []*net.PortRange{{From: 25, To: 25}}})
+	ctx := session.ContextWithPortRestriction(context.Background(), blocked)
+
+	d := packetDispatcher{uplinks: make(chan *pipe.Reader, 1)}
+	upReader, upWriter := pipe.New(pipe.WithoutSizeLimit())
+	_, downWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(mux.NewServerWorker(ctx, d, &transport.Link{Reader: upReader, Writer: downWriter}))
+
+	first := net.UDPDestination(net.ParseAddress("1.1.1.1"), 53)
+	writer := mux.NewWriter(1, first, upWriter, protocol.TransferTypePacket, [8]byte{'p', 'o', 'r', 't'})
+	for _, dest := range []net.Destination{
+		first,
+		net.UDPDestination(net.ParseAddress("2.2.2.2"), 25),
+		net.UDPDestination(net.ParseAddress("3.3.3.3"), 53),
+	} {
+		b := buf.New()
+		common.Must2(b.WriteString("payload"))
+		b.UDP = &dest
+		common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+	}
+
+	var uplink *pipe.Reader
+	select {
+	case uplink = <-d.uplinks:
+	case <-time.After(time.Second * 5):
+		t.Fatal("XUDP session not dispatched")
+	}
+
+	var got []net.Destination
+	for len(got) < 2 {
+		mb, err := uplink.ReadMultiBufferTimeout(time.Second * 5)
+		if err != nil {
+			t.Fatal("packets not delivered: ", err)
+		}
+		for _, b := range mb {
+			got = append(got, *b.UDP)
+		}
+		buf.ReleaseMulti(mb)
+	}
+	// nothing but the blocked packet may be missing
+	if mb, err := uplink.ReadMultiBufferTimeout(time.Millisecond * 100); err == nil {
+		for _, b := range mb {
+			got = append(got, *b.UDP)
+		}
+	}
+	if len(got) != 2 || got[0].Port != 53 || got[1].Port != 53 {
+		t.Error("unexpected packets: ", got)
+	}
+}
@@ -21,6 +21,8 @@ func DestinationFromAddr(addr net.Addr) Destination {
 		return UDPDestination(IPAddress(addr.IP), Port(addr.Port))
 	case *net.UnixAddr:
 		return UnixDestination(DomainAddress(addr.Name))
+	case *VsockAddr:
+		return VsockDestination(addr)
 	default:
 		panic("Net: Unknown address type.")
 	}
@@ -41,6 +43,15 @@ func ParseDestination(dest string) (Destination, error) {
 	} else if strings.HasPrefix(dest, "unix:") {
 		d = UnixDestination(DomainAddress(dest[5:]))
 		return d, nil
+	} else if IsVsockAddress(dest) {
+		addr, err := ParseVsockAddress(dest)
+		if err != nil {
+			return d, err
+		}
+		return VsockDestination(addr), nil
+	} else if strings.HasPrefix(dest, "icmp:") {
+		d = ICMPDestination(ParseAddress(dest[5:]))
+		return d, nil
 	}
 
 	hstr, pstr, err := SplitHostPort(dest)
@@ -86,12 +97,20 @@ func UnixDestination(address Address) Destination {
 	}
 }
 
+// ICMPDestination creates an ICMP echo destination with given address
+func ICMPDestination(address Address) Destination {
+	return Destination{
+		Network: Network_ICMP,
+		Address: address,
+	}
+}
+
 // NetAddr returns the network address in this Destination in string form.
 func (d Destination) NetAddr() string {
 	addr := ""
 	if d.Network == Network_TCP || d.Network == Network_UDP {
 		addr = d.Address.String() + ":" + d.Port.String()
-	} else if d.Network == Network_UNIX {
+	} else if d.Network == Network_UNIX || d.Network == Network_ICMP {
 		addr = d.Address.String()
 	}
 	return addr
@@ -116,7 +135,9 @@ func (d Destination) RawNetAddr() net.Addr {
 			}
 		}
 	case Network_UNIX:
-		if d.Address.Family().IsDomain() {
+		if vsock := VsockAddrFromAddress(d.Address); vsock != nil {
+			addr = vsock
+		} else if d.Address.Family().IsDomain() {
 			addr = &net.UnixAddr{
 				Name: d.Address.String(),
 				Net:  d.Network.SystemString(),
@@ -135,7 +156,12 @@ func (d Destination) String() string {
 	case Network_UDP:
 		prefix = "udp:"
 	case Network_UNIX:
+		if d.Address.Family().IsDomain() && IsVsockAddress(d.Address.Domain()) {
+			return d.NetAddr()
+		}
 		prefix = "unix:"
+	case Network_ICMP:
+		prefix = "icmp:"
 	}
 	return prefix + d.NetAddr()
 }
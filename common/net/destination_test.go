@@ -32,6 +32,18 @@ func TestDestinationProperty(t *testing.T) {
 			String:    "unix:/tmp/test.sock",
 			NetString: "/tmp/test.sock",
 		},
+		{
+			Input:     VsockDestination(&VsockAddr{CID: 3, Port: 1234}),
+			Network:   Network_UNIX,
+			String:    "vsock:3:1234",
+			NetString: "vsock:3:1234",
+		},
+		{
+			Input:     ICMPDestination(IPAddress([]byte{1, 1, 1, 1})),
+			Network:   Network_ICMP,
+			String:    "icmp:1.1.1.1",
+			NetString: "1.1.1.1",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -66,6 +78,18 @@ func TestDestinationParse(t *testing.T) {
 			Input:  "unix:/tmp/test.sock",
 			Output: UnixDestination(DomainAddress("/tmp/test.sock")),
 		},
+		{
+			Input:  "vsock:2:1234",
+			Output: VsockDestination(&VsockAddr{CID: 2, Port: 1234}),
+		},
+		{
+			Input: "vsock:2",
+			Error: true,
+		},
+		{
+			Input:  "icmp:8.8.8.8",
+			Output: ICMPDestination(IPAddress([]byte{8, 8, 8, 8})),
+		},
 		{
 			Input: "8.8.8.8:53",
 			Output: Destination{
@@ -54,6 +54,7 @@ type (
 // IP is an alias for net.IP.
 type (
 	IP     = net.IP
+	IPAddr = net.IPAddr
 	IPMask = net.IPMask
 	IPNet  = net.IPNet
 )
@@ -0,0 +1,80 @@
+package net
+
+import (
+	"strconv"
+	"strings"
+)
+
+const vsockPrefix = "vsock:"
+
+// VsockCIDAny is the context ID that matches any address when listening.
+const VsockCIDAny = ^uint32(0)
+
+// VsockAddr is the address of a virtio socket (AF_VSOCK) endpoint.
+type VsockAddr struct {
+	CID  uint32
+	Port uint32
+}
+
+// Network implements net.Addr.
+func (a *VsockAddr) Network() string {
+	return "vsock"
+}
+
+// String implements net.Addr. It returns the address in the form of "vsock:<cid>:<port>".
+func (a *VsockAddr) String() string {
+	cid := "any"
+	if a.CID != VsockCIDAny {
+		cid = strconv.FormatUint(uint64(a.CID), 10)
+	}
+	return vsockPrefix + cid + ":" + strconv.FormatUint(uint64(a.Port), 10)
+}
+
+// IsVsockAddress returns true if the string is in the form of a vsock address.
+// It doesn't check whether the context ID and the port are valid.
+func IsVsockAddress(addr string) bool {
+	return strings.HasPrefix(addr, vsockPrefix)
+}
+
+// ParseVsockAddress parses an address in the form of "vsock:<cid>:<port>".
+// The context ID may be "any" when listening.
+func ParseVsockAddress(addr string) (*VsockAddr, error) {
+	if !IsVsockAddress(addr) {
+		return nil, newError("not a vsock address: ", addr)
+	}
+	parts := strings.Split(addr[len(vsockPrefix):], ":")
+	if len(parts) != 2 {
+		return nil, newError("invalid vsock address: ", addr, ", expect vsock:<cid>:<port>")
+	}
+	cid := VsockCIDAny
+	if parts[0] != "any" {
+		v, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, newError("invalid vsock context ID: ", addr).Base(err)
+		}
+		cid = uint32(v)
+	}
+	port, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, newError("invalid vsock port: ", addr).Base(err)
+	}
+	return &VsockAddr{CID: cid, Port: uint32(port)}, nil
+}
+
+// VsockAddrFromAddress returns the vsock address carried by the given Address, or nil if it doesn't carry one.
+func VsockAddrFromAddress(address Address) *VsockAddr {
+	if address == nil || !address.Family().IsDomain() || !IsVsockAddress(address.Domain()) {
+		return nil
+	}
+	addr, err := ParseVsockAddress(address.Domain())
+	if err != nil {
+		return nil
+	}
+	return addr
+}
+
+// VsockDestination creates a Destination of the given vsock address.
+// Like Unix domain sockets, the whole address is carried in the Address of the Destination.
+func VsockDestination(addr *VsockAddr) Destination {
+	return UnixDestination(DomainAddress(addr.String()))
+}
@@ -0,0 +1,102 @@
+package net_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	. "github.com/xtls/xray-core/common/net"
+)
+
+func TestParseVsockAddress(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output *VsockAddr
+		Error  bool
+	}{
+		{
+			Input:  "vsock:3:1234",
+			Output: &VsockAddr{CID: 3, Port: 1234},
+		},
+		{
+			Input:  "vsock:any:80",
+			Output: &VsockAddr{CID: VsockCIDAny, Port: 80},
+		},
+		{
+			Input:  "vsock:4294967295:80",
+			Output: &VsockAddr{CID: VsockCIDAny, Port: 80},
+		},
+		{
+			Input: "vsock:3",
+			Error: true,
+		},
+		{
+			Input: "vsock:3:1234:5",
+			Error: true,
+		},
+		{
+			Input: "vsock:host:1234",
+			Error: true,
+		},
+		{
+			Input: "vsock:3:-1",
+			Error: true,
+		},
+		{
+			Input: "unix:/tmp/test.sock",
+			Error: true,
+		},
+	}
+
+	for _, testcase := range cases {
+		addr, err := ParseVsockAddress(testcase.Input)
+		if testcase.Error {
+			if err == nil {
+				t.Error("for test case: ", testcase.Input, " expected error, but got ", addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("for test case: ", testcase.Input, " expected no error, but got ", err)
+			continue
+		}
+		if r := cmp.Diff(addr, testcase.Output); r != "" {
+			t.Error("for test case: ", testcase.Input, ": ", r)
+		}
+	}
+}
+
+func TestVsockAddrString(t *testing.T) {
+	for _, addr := range []*VsockAddr{
+		{CID: 2, Port: 1024},
+		{CID: VsockCIDAny, Port: 80},
+	} {
+		parsed, err := ParseVsockAddress(addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *parsed != *addr {
+			t.Error("expect ", addr, ", but got ", parsed)
+		}
+	}
+
+	if s := (&VsockAddr{CID: VsockCIDAny, Port: 80}).String(); s != "vsock:any:80" {
+		t.Error("unexpected string of any address: ", s)
+	}
+}
+
+func TestVsockDestination(t *testing.T) {
+	dest := VsockDestination(&VsockAddr{CID: 3, Port: 1234})
+	addr, ok := dest.RawNetAddr().(*VsockAddr)
+	if !ok {
+		t.Fatal("expect a vsock address, but got ", dest.RawNetAddr())
+	}
+	if r := cmp.Diff(addr, &VsockAddr{CID: 3, Port: 1234}); r != "" {
+		t.Error(r)
+	}
+	if d := DestinationFromAddr(addr); d != dest {
+		t.Error("expect ", dest, ", but got ", d)
+	}
+	if VsockAddrFromAddress(DomainAddress("/tmp/test.sock")) != nil {
+		t.Error("unix domain socket address taken as vsock address")
+	}
+}
@@ -9,3 +9,9 @@ type Account interface {
 type AsAccount interface {
 	AsAccount() (Account, error)
 }
+
+// QUICRejecter is an Account which may refuse QUIC proxied to UDP port 443,
+// making clients fall back to TCP.
+type QUICRejecter interface {
+	RejectsQUIC() bool
+}
@@ -90,6 +90,11 @@ var (
 		runtime.GOARCH == "s390x" && hasGCMAsmS390X
 )
 
+// HasAESGCMHardwareSupport returns whether AES-GCM is accelerated by the hardware.
+func HasAESGCMHardwareSupport() bool {
+	return hasAESGCMHardwareSupport
+}
+
 func (sc *SecurityConfig) GetSecurityType() SecurityType {
 	if sc == nil || sc.Type == SecurityType_AUTO {
 		if hasAESGCMHardwareSupport {
@@ -40,6 +40,7 @@ var (
 	methods = [...]string{"get", "post", "head", "put", "delete", "options", "connect"}
 
 	errNotHTTPMethod = errors.New("not an HTTP method")
+	errNoHost        = errors.New("no Host header")
 )
 
 func beginWithHTTPMethod(b []byte) error {
@@ -66,9 +67,13 @@ func SniffHTTP(b []byte) (*SniffHeader, error) {
 	}
 
 	headers := bytes.Split(b, []byte{'\n'})
+	// The last line is not complete yet, unless it is empty.
+	headers = headers[:len(headers)-1]
+	complete := false
 	for i := 1; i < len(headers); i++ {
-		header := headers[i]
+		header := bytes.TrimSuffix(headers[i], []byte{'\r'})
 		if len(header) == 0 {
+			complete = true
 			break
 		}
 		parts := bytes.SplitN(header, []byte{':'}, 2)
@@ -89,6 +94,9 @@ func SniffHTTP(b []byte) (*SniffHeader, error) {
 	if len(sh.host) > 0 {
 		return sh, nil
 	}
+	if complete {
+		return nil, errNoHost
+	}
 
 	return nil, common.ErrNoClue
 }
@@ -85,6 +85,15 @@ first_name=John&last_name=Doe&action=Submit`,
 			domain: "",
 			err:    true,
 		},
+		{
+			input:  "GET / HTTP/1.1\r\nHost: exam",
+			domain: "",
+			err:    true,
+		},
+		{
+			input:  "GET / HTTP/1.1\r\nHost: example.com\r\n",
+			domain: "example.com",
+		},
 	}
 
 	for _, test := range cases {
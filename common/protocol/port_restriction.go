@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"github.com/xtls/xray-core/common/net"
+)
+
+// PortRestriction limits the destination ports users may reach through an inbound.
+type PortRestriction struct {
+	// Allowed ports. Empty means all ports.
+	Allowed net.MemoryPortList
+	// Blocked ports, checked after Allowed.
+	Blocked net.MemoryPortList
+}
+
+// NewPortRestriction creates a PortRestriction from the given lists. It returns nil if both are empty.
+func NewPortRestriction(allowed, blocked *net.PortList) *PortRestriction {
+	if len(allowed.GetRange()) == 0 && len(blocked.GetRange()) == 0 {
+		return nil
+	}
+	r := new(PortRestriction)
+	if allowed != nil {
+		r.Allowed = net.PortListFromProto(allowed)
+	}
+	if blocked != nil {
+		r.Blocked = net.PortListFromProto(blocked)
+	}
+	return r
+}
+
+// Permits returns whether port may be reached. A nil PortRestriction permits all ports.
+func (r *PortRestriction) Permits(port net.Port) bool {
+	if r == nil {
+		return true
+	}
+	if len(r.Allowed) > 0 && !r.Allowed.Contains(port) {
+		return false
+	}
+	return !r.Blocked.Contains(port)
+}
+
+// PortRestricter is an Account with its own PortRestriction, which replaces the one of the inbound.
+type PortRestricter interface {
+	PortRestriction() *PortRestriction
+}
+
+// UserPortRestriction returns the PortRestriction for user, falling back to the one of the inbound.
+func UserPortRestriction(user *MemoryUser, inbound *PortRestriction) *PortRestriction {
+	if user != nil {
+		if pr, ok := user.Account.(PortRestricter); ok {
+			if r := pr.PortRestriction(); r != nil {
+				return r
+			}
+		}
+	}
+	return inbound
+}
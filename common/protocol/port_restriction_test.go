@@ -0,0 +1,72 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+	. "github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/proxy/trojan"
+)
+
+func TestPortRestriction(t *testing.T) {
+	if r := NewPortRestriction(nil, &net.PortList{}); r != nil {
+		t.Error("expected nil restriction for empty lists, but got ", r)
+	}
+
+	var r *PortRestriction
+	if !r.Permits(25) {
+		t.Error("nil restriction should permit all ports")
+	}
+
+	r = NewPortRestriction(&net.PortList{Range: []*net.PortRange{{From: 80, To: 80}, {From: 443, To: 443}}}, nil)
+	if !r.Permits(443) || !r.Permits(80) {
+		t.Error("allowed ports are denied")
+	}
+	if r.Permits(25) {
+		t.Error("port 25 is not in the allowed list")
+	}
+
+	r = NewPortRestriction(&net.PortList{Range: []*net.PortRange{{From: 1, To: 1024}}}, &net.PortList{Range: []*net.PortRange{{From: 25, To: 25}}})
+	if r.Permits(25) {
+		t.Error("blocked port 25 is permitted")
+	}
+	if !r.Permits(443) {
+		t.Error("port 443 is denied")
+	}
+	if r.Permits(8080) {
+		t.Error("port 8080 is not in the allowed list")
+	}
+}
+
+func TestUserPortRestriction(t *testing.T) {
+	inbound := NewPortRestriction(nil, &net.PortList{Range: []*net.PortRange{{From: 25, To: 25}}})
+
+	newUser := func(account *trojan.Account) *MemoryUser {
+		a, err := account.AsAccount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &MemoryUser{Account: a}
+	}
+
+	plain := newUser(&trojan.Account{Password: "plain"})
+	if r := UserPortRestriction(plain, inbound); r != inbound {
+		t.Error("user without restriction should use the inbound one")
+	}
+
+	restricted := newUser(&trojan.Account{
+		Password:           "restricted",
+		AllowedTargetPorts: &net.PortList{Range: []*net.PortRange{{From: 25, To: 25}}},
+	})
+	r := UserPortRestriction(restricted, inbound)
+	if !r.Permits(25) {
+		t.Error("user restriction should replace the inbound one")
+	}
+	if r.Permits(443) {
+		t.Error("port 443 is not allowed for the user")
+	}
+
+	if r := UserPortRestriction(nil, nil); !r.Permits(443) {
+		t.Error("no restriction should permit all ports")
+	}
+}
@@ -0,0 +1,40 @@
+package quic
+
+import (
+	"encoding/binary"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+)
+
+// minInitialSize is the size a client pads its Initial packets to, RFC 9000 section 14.1.
+const minInitialSize = 1200
+
+// IsInitial tells whether b looks like a client Initial packet of a known QUIC
+// version. Only the header is checked, nothing is decrypted.
+func IsInitial(b []byte) bool {
+	if len(b) < minInitialSize {
+		return false
+	}
+	// long header, fixed bit, Initial type
+	if b[0]&0xf0 != 0xc0 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(b[1:5]) {
+	case version1, versionDraft29:
+	default:
+		return false
+	}
+	// destination connection ID of at least 8 bytes, RFC 9000 section 7.2
+	return b[5] >= 8 && b[5] <= 20
+}
+
+// ShouldReject tells whether the UDP flow of user to dest, starting with the
+// packet first, is QUIC the account of user refuses to proxy.
+func ShouldReject(user *protocol.MemoryUser, dest net.Destination, first []byte) bool {
+	if user == nil || dest.Network != net.Network_UDP || dest.Port != 443 {
+		return false
+	}
+	rejecter, ok := user.Account.(protocol.QUICRejecter)
+	return ok && rejecter.RejectsQUIC() && IsInitial(first)
+}
@@ -0,0 +1,85 @@
+package quic_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/quic"
+	"github.com/xtls/xray-core/proxy/trojan"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+func quicInitial() []byte {
+	b := make([]byte, 1250)
+	b[0] = 0xc3 // long header, fixed bit, Initial, 4 byte packet number
+	binary.BigEndian.PutUint32(b[1:5], 1)
+	b[5] = 8
+	return b
+}
+
+func dnsQuery() []byte {
+	// query for example.com A
+	return []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 0x01, 0x00, 0x01,
+	}
+}
+
+func TestIsInitial(t *testing.T) {
+	if !quic.IsInitial(quicInitial()) {
+		t.Error("QUIC Initial not detected")
+	}
+
+	short := quicInitial()[:600]
+	handshake := quicInitial()
+	handshake[0] = 0xe3
+	unknownVersion := quicInitial()
+	binary.BigEndian.PutUint32(unknownVersion[1:5], 0x0a0a0a0a)
+	for name, b := range map[string][]byte{
+		"dns":             dnsQuery(),
+		"short":           short,
+		"handshake":       handshake,
+		"unknown version": unknownVersion,
+		"empty":           {},
+	} {
+		if quic.IsInitial(b) {
+			t.Error(name, " detected as QUIC Initial")
+		}
+	}
+}
+
+func TestShouldReject(t *testing.T) {
+	flagged := []*protocol.MemoryUser{
+		{Email: "vless", Account: &vless.MemoryAccount{RejectQUICUDP443: true}},
+		{Email: "trojan", Account: &trojan.MemoryAccount{RejectQUICUDP443: true}},
+	}
+	unflagged := []*protocol.MemoryUser{
+		{Email: "vless", Account: &vless.MemoryAccount{}},
+		{Email: "trojan", Account: &trojan.MemoryAccount{}},
+	}
+	dest443 := net.UDPDestination(net.ParseAddress("1.1.1.1"), 443)
+	dest53 := net.UDPDestination(net.ParseAddress("1.1.1.1"), 53)
+
+	for _, user := range flagged {
+		if !quic.ShouldReject(user, dest443, quicInitial()) {
+			t.Error(user.Email, ": QUIC to UDP 443 not rejected")
+		}
+		if quic.ShouldReject(user, dest443, dnsQuery()) {
+			t.Error(user.Email, ": DNS to UDP 443 rejected")
+		}
+		if quic.ShouldReject(user, dest53, quicInitial()) {
+			t.Error(user.Email, ": QUIC to UDP 53 rejected")
+		}
+		if quic.ShouldReject(user, net.TCPDestination(dest443.Address, 443), quicInitial()) {
+			t.Error(user.Email, ": TCP rejected")
+		}
+	}
+	for _, user := range unflagged {
+		if quic.ShouldReject(user, dest443, quicInitial()) || quic.ShouldReject(user, dest443, dnsQuery()) {
+			t.Error(user.Email, ": unflagged user rejected")
+		}
+	}
+}
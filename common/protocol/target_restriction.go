@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	gonet "net"
+	"strings"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// targetPresets are the ranges the names in a forbidden targets list stand for.
+var targetPresets = map[string][]string{
+	// 0.0.0.0 and :: reach the host itself as well.
+	"loopback":  {"127.0.0.0/8", "::1/128", "0.0.0.0/8", "::/128"},
+	"private":   {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"},
+	"linklocal": {"169.254.0.0/16", "fe80::/10"},
+}
+
+// TargetRestriction forbids the destination IPs users may reach through an inbound, such as the addresses
+// of the host itself and its cloud metadata service.
+type TargetRestriction struct {
+	forbidden []*gonet.IPNet
+}
+
+// NewTargetRestriction creates a TargetRestriction from a list of CIDRs, IPs and the presets "loopback",
+// "private" and "linklocal". It returns nil if the list is empty.
+func NewTargetRestriction(targets []string) (*TargetRestriction, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	r := new(TargetRestriction)
+	for _, target := range targets {
+		cidrs, ok := targetPresets[strings.ToLower(target)]
+		if !ok {
+			cidrs = []string{target}
+		}
+		for _, cidr := range cidrs {
+			if !strings.Contains(cidr, "/") {
+				ip := net.ParseIP(cidr)
+				if ip == nil {
+					return nil, newError("invalid forbidden target: ", target)
+				}
+				if ip.To4() != nil {
+					cidr += "/32"
+				} else {
+					cidr += "/128"
+				}
+			}
+			_, ipNet, err := gonet.ParseCIDR(cidr)
+			if err != nil {
+				return nil, newError("invalid forbidden target: ", target).Base(err)
+			}
+			r.forbidden = append(r.forbidden, ipNet)
+		}
+	}
+	return r, nil
+}
+
+// Permits returns whether address may be reached. Domains are permitted, as they are checked once resolved.
+// A nil TargetRestriction permits all addresses.
+func (r *TargetRestriction) Permits(address net.Address) bool {
+	if r == nil || address == nil || address.Family().IsDomain() {
+		return true
+	}
+	ip := address.IP()
+	if ip4 := ip.To4(); ip4 != nil {
+		// IPv4-mapped IPv6 addresses reach the IPv4 ones
+		ip = ip4
+	}
+	for _, ipNet := range r.forbidden {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	. "github.com/xtls/xray-core/common/protocol"
+)
+
+func TestTargetRestriction(t *testing.T) {
+	r, err := NewTargetRestriction(nil)
+	common.Must(err)
+	if r != nil || !r.Permits(net.LocalHostIP) {
+		t.Error("nil restriction should permit all addresses")
+	}
+
+	if _, err := NewTargetRestriction([]string{"metadata"}); err == nil {
+		t.Error("expect an error for an unknown preset")
+	}
+
+	r, err = NewTargetRestriction([]string{"loopback", "LinkLocal", "192.0.2.1", "2001:db8::/32"})
+	common.Must(err)
+	for _, address := range []string{"127.0.0.1", "127.1.2.3", "::1", "0.0.0.0", "::ffff:127.0.0.1", "169.254.169.254", "fe80::1", "192.0.2.1", "2001:db8::1"} {
+		if r.Permits(net.ParseAddress(address)) {
+			t.Error(address, " is permitted")
+		}
+	}
+	for _, address := range []string{"1.1.1.1", "192.0.2.2", "10.0.0.1", "2606:4700::1111", "localhost"} {
+		if !r.Permits(net.ParseAddress(address)) {
+			t.Error(address, " is forbidden")
+		}
+	}
+
+	r, err = NewTargetRestriction([]string{"private"})
+	common.Must(err)
+	if r.Permits(net.ParseAddress("10.1.2.3")) || r.Permits(net.ParseAddress("fd00::1")) || !r.Permits(net.ParseAddress("8.8.8.8")) {
+		t.Error("unexpected private preset")
+	}
+}
@@ -10,4 +10,6 @@ type Packet struct {
 	Payload *buf.Buffer
 	Source  net.Destination
 	Target  net.Destination
+	// Local is the address the packet was sent to, for listeners on all addresses. May be nil.
+	Local net.Address
 }
@@ -5,6 +5,7 @@ import (
 	_ "unsafe"
 
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/features/routing"
 )
 
@@ -25,6 +26,9 @@ const (
 	timeoutOnlyKey
 	allowedNetworkKey
 	handlerSessionKey
+	portRestrictionKey
+	probeKey
+	targetRestrictionKey
 )
 
 // ContextWithID returns a new context with the given ID.
@@ -161,3 +165,46 @@ func AllowedNetworkFromContext(ctx context.Context) net.Network {
 	}
 	return net.Network_Unknown
 }
+
+// ContextWithPortRestriction returns a new context whose dispatches are limited to the destination ports permitted by r.
+func ContextWithPortRestriction(ctx context.Context, r *protocol.PortRestriction) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, portRestrictionKey, r)
+}
+
+func PortRestrictionFromContext(ctx context.Context) *protocol.PortRestriction {
+	if r, ok := ctx.Value(portRestrictionKey).(*protocol.PortRestriction); ok {
+		return r
+	}
+	return nil
+}
+
+// ContextWithTargetRestriction returns a new context whose dispatches may not reach the addresses forbidden by r.
+func ContextWithTargetRestriction(ctx context.Context, r *protocol.TargetRestriction) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, targetRestrictionKey, r)
+}
+
+func TargetRestrictionFromContext(ctx context.Context) *protocol.TargetRestriction {
+	if r, ok := ctx.Value(targetRestrictionKey).(*protocol.TargetRestriction); ok {
+		return r
+	}
+	return nil
+}
+
+// ContextWithProbe returns a new context whose connections probe an outbound.
+func ContextWithProbe(ctx context.Context, p *Probe) context.Context {
+	return context.WithValue(ctx, probeKey, p)
+}
+
+// ProbeFromContext returns the probe in this context, or nil if not contained.
+func ProbeFromContext(ctx context.Context) *Probe {
+	if p, ok := ctx.Value(probeKey).(*Probe); ok {
+		return p
+	}
+	return nil
+}
@@ -40,6 +40,9 @@ type Inbound struct {
 	Source net.Destination
 	// Gateway address.
 	Gateway net.Destination
+	// Local address the connection arrived on. Unlike Gateway, it is never the unspecified address
+	// of a listener on all addresses. May be invalid if not known.
+	Local net.Destination
 	// Tag of the inbound proxy that handles the connection.
 	Tag string
 	// Name of the inbound proxy that handles the connection.
@@ -63,11 +66,13 @@ type Outbound struct {
 	RouteTarget    net.Destination
 	// Gateway address
 	Gateway net.Address
+	// Tag of the name server resolving the address when dialing. Empty for the default resolution.
+	Resolver string
 	// Tag of the outbound proxy that handles the connection.
 	Tag string
 	// Name of the outbound proxy that handles the connection.
 	Name string
-	// Conn is actually internet.Connection. May be nil. It is currently nil for outbound with proxySettings 
+	// Conn is actually internet.Connection. May be nil. It is currently nil for outbound with proxySettings
 	Conn net.Conn
 	// CanSpliceCopy is a property for this connection
 	// 1 = can, 2 = after processing protocol info should be able to, 3 = cannot
@@ -101,6 +106,15 @@ type Sockopt struct {
 	Mark int32
 }
 
+// Probe is the metadata of a connection testing an outbound.
+type Probe struct {
+	// RecordStats tells whether the traffic of the probe counts in stats.
+	RecordStats bool
+	// Dialed, if not nil, is called when the outbound has connected to its
+	// server. Connections multiplexed over an existing one don't call it.
+	Dialed func()
+}
+
 // SetAttribute attaches additional string attributes to content.
 func (c *Content) SetAttribute(name string, value string) {
 	if c.Attributes == nil {
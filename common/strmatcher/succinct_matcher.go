@@ -0,0 +1,255 @@
+package strmatcher
+
+import (
+	"math/bits"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A SuccinctMatcherGroup is divided into three parts:
+// 1. `full` and `domain` patterns are matched by a LOUDS encoded trie of the reversed patterns;
+// 2. `substr` patterns are matched by ac automaton;
+// 3. `regex` patterns are matched with the regex library.
+// The trie takes a few bits per node, which keeps large domain lists small in memory.
+type SuccinctMatcherGroup struct {
+	ac            *ACAutomaton
+	otherMatchers []matcherEntry
+	trie          *succinctTrie
+	count         uint32
+	ruleMap       map[string]byte
+}
+
+const (
+	succinctFull byte = 1 << iota
+	succinctDomain
+)
+
+func NewSuccinctMatcherGroup() *SuccinctMatcherGroup {
+	return &SuccinctMatcherGroup{
+		count:   1,
+		ruleMap: make(map[string]byte),
+	}
+}
+
+// AddPattern adds a pattern to SuccinctMatcherGroup
+func (g *SuccinctMatcherGroup) AddPattern(pattern string, t Type) (uint32, error) {
+	switch t {
+	case Substr:
+		if g.ac == nil {
+			g.ac = NewACAutomaton()
+		}
+		g.ac.Add(pattern, t)
+	case Full:
+		g.ruleMap[strings.ToLower(pattern)] |= succinctFull
+	case Domain:
+		g.ruleMap[strings.ToLower(pattern)] |= succinctDomain
+	case Regex:
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return 0, err
+		}
+		g.otherMatchers = append(g.otherMatchers, matcherEntry{
+			m:  &regexMatcher{pattern: r},
+			id: g.count,
+		})
+	default:
+		panic("Unknown type")
+	}
+	return g.count, nil
+}
+
+// Build builds the trie and ac automaton from insert rules
+func (g *SuccinctMatcherGroup) Build() {
+	if g.ac != nil {
+		g.ac.Build()
+	}
+	g.trie = newSuccinctTrie(g.ruleMap)
+	g.ruleMap = nil
+}
+
+// Match implements IndexMatcher.Match.
+func (g *SuccinctMatcherGroup) Match(pattern string) []uint32 {
+	if g.trie.match(pattern) {
+		return []uint32{1}
+	}
+	if g.ac != nil && g.ac.Match(pattern) {
+		return []uint32{1}
+	}
+	for _, e := range g.otherMatchers {
+		if e.m.Match(pattern) {
+			return []uint32{e.id}
+		}
+	}
+	return nil
+}
+
+// bitVector is a bit array supporting rank and select in about constant time.
+type bitVector struct {
+	words []uint64
+	// ranks[i] is the number of ones in words[:i]
+	ranks []uint32
+}
+
+func (v *bitVector) set(i int) {
+	for i/64 >= len(v.words) {
+		v.words = append(v.words, 0)
+	}
+	v.words[i/64] |= 1 << (i % 64)
+}
+
+func (v *bitVector) get(i int) bool {
+	return i/64 < len(v.words) && v.words[i/64]&(1<<(i%64)) != 0
+}
+
+func (v *bitVector) buildRanks() {
+	v.ranks = make([]uint32, len(v.words)+1)
+	for i, w := range v.words {
+		v.ranks[i+1] = v.ranks[i] + uint32(bits.OnesCount64(w))
+	}
+}
+
+// rank returns the number of ones before position i.
+func (v *bitVector) rank(i int) int {
+	w := i / 64
+	r := int(v.ranks[w])
+	if off := i % 64; off > 0 {
+		r += bits.OnesCount64(v.words[w] & (1<<off - 1))
+	}
+	return r
+}
+
+// selectZero returns the position of the k-th zero, k starts from 1.
+func (v *bitVector) selectZero(k int) int {
+	zerosBefore := func(w int) int {
+		return w*64 - int(v.ranks[w])
+	}
+	// the last word whose preceding zeros are fewer than k
+	w := sort.Search(len(v.words), func(w int) bool {
+		return zerosBefore(w) >= k
+	}) - 1
+	word := ^v.words[w]
+	for n := k - zerosBefore(w) - 1; n > 0; n-- {
+		word &= word - 1
+	}
+	return w*64 + bits.TrailingZeros64(word)
+}
+
+// nextZero returns the position of the first zero at or after position i.
+func (v *bitVector) nextZero(i int) int {
+	for w := i / 64; w < len(v.words); w++ {
+		word := ^v.words[w]
+		if w == i/64 {
+			word &^= 1<<(i%64) - 1
+		}
+		if word != 0 {
+			return w*64 + bits.TrailingZeros64(word)
+		}
+	}
+	return len(v.words) * 64
+}
+
+// succinctTrie is a trie in level order unary degree sequence.
+// Node 0 is the root, the children of node x are encoded as ones after the
+// (x+1)-th zero of louds, and a child at position p is node rank(p).
+type succinctTrie struct {
+	louds  bitVector
+	labels []byte
+	full   bitVector
+	domain bitVector
+}
+
+func newSuccinctTrie(rules map[string]byte) *succinctTrie {
+	type key struct {
+		s     string
+		flags byte
+	}
+	keys := make([]key, 0, len(rules))
+	for rule, flags := range rules {
+		r := []byte(rule)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		keys = append(keys, key{string(r), flags})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].s < keys[j].s
+	})
+
+	t := &succinctTrie{labels: []byte{0}}
+	// the super root
+	t.louds.set(0)
+	pos := 2
+
+	type span struct {
+		lo, hi, depth int
+	}
+	queue := []span{{0, len(keys), 0}}
+	for id := 0; id < len(queue); id++ {
+		n := queue[id]
+		lo := n.lo
+		// keys ending here sort before their extensions
+		if lo < n.hi && len(keys[lo].s) == n.depth {
+			if keys[lo].flags&succinctFull != 0 {
+				t.full.set(id)
+			}
+			if keys[lo].flags&succinctDomain != 0 {
+				t.domain.set(id)
+			}
+			lo++
+		}
+		for lo < n.hi {
+			c := keys[lo].s[n.depth]
+			hi := lo + 1
+			for hi < n.hi && keys[hi].s[n.depth] == c {
+				hi++
+			}
+			t.louds.set(pos)
+			pos++
+			t.labels = append(t.labels, c)
+			queue = append(queue, span{lo, hi, n.depth + 1})
+			lo = hi
+		}
+		pos++
+	}
+	// room for the trailing zero
+	t.louds.set(pos)
+	t.louds.words[pos/64] &^= 1 << (pos % 64)
+	t.louds.buildRanks()
+	return t
+}
+
+func (t *succinctTrie) child(node int, c byte) (int, bool) {
+	start := t.louds.selectZero(node+1) + 1
+	end := t.louds.nextZero(start)
+	first := t.louds.rank(start)
+	labels := t.labels[first : first+end-start]
+	i := sort.Search(len(labels), func(i int) bool {
+		return labels[i] >= c
+	})
+	if i < len(labels) && labels[i] == c {
+		return first + i, true
+	}
+	return 0, false
+}
+
+// match reports whether s matches a full or domain rule, ignoring case as the
+// rules are stored in lower case.
+func (t *succinctTrie) match(s string) bool {
+	node := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == '.' && t.domain.get(node) {
+			return true
+		}
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		next, found := t.child(node, c)
+		if !found {
+			return false
+		}
+		node = next
+	}
+	return t.full.get(node) || t.domain.get(node)
+}
@@ -0,0 +1,118 @@
+package strmatcher_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/common/strmatcher"
+)
+
+func TestSuccinctMatcherGroup(t *testing.T) {
+	g := NewSuccinctMatcherGroup()
+	for _, rule := range []struct {
+		Type   Type
+		Domain string
+	}{
+		{Type: Domain, Domain: "googleapis.com"},
+		{Type: Full, Domain: "www.baidu.com"},
+		{Type: Domain, Domain: "Example.COM"},
+		{Type: Full, Domain: "a.b.c"},
+		{Type: Substr, Domain: "cdn"},
+		{Type: Regex, Domain: "^xray\\.[0-9]+$"},
+	} {
+		common.Must2(g.AddPattern(rule.Domain, rule.Type))
+	}
+	g.Build()
+
+	cases := []struct {
+		Input  string
+		Output bool
+	}{
+		{Input: "googleapis.com", Output: true},
+		{Input: "fonts.googleapis.com", Output: true},
+		{Input: "xgoogleapis.com", Output: false},
+		{Input: "www.baidu.com", Output: true},
+		{Input: "baidu.com", Output: false},
+		{Input: "x.www.baidu.com", Output: false},
+		{Input: "example.com", Output: true},
+		{Input: "www.example.com", Output: true},
+		{Input: "WWW.Example.Com", Output: true},
+		{Input: "WWW.BAIDU.COM", Output: true},
+		{Input: "a.b.c", Output: true},
+		{Input: "b.c", Output: false},
+		{Input: "x.a.b.c", Output: false},
+		{Input: "mycdn.org", Output: true},
+		{Input: "xray.123", Output: true},
+		{Input: "xray.abc", Output: false},
+		{Input: "", Output: false},
+		{Input: "com", Output: false},
+	}
+	for _, c := range cases {
+		if r := len(g.Match(c.Input)) > 0; r != c.Output {
+			t.Error("for input ", c.Input, " expected ", c.Output, " but got ", r)
+		}
+	}
+}
+
+func TestEmptySuccinctMatcherGroup(t *testing.T) {
+	g := NewSuccinctMatcherGroup()
+	g.Build()
+	if r := g.Match("example.com"); len(r) != 0 {
+		t.Error("Expect [], but ", r)
+	}
+}
+
+// TestSuccinctMatcherGroupEquivalence checks the succinct trie against the
+// previous matchers for a large random corpus.
+func TestSuccinctMatcherGroupEquivalence(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	labels := []string{"com", "cn", "net", "org", "a", "b", "cdn", "www", "api", "x1", "img", "static", "-", "mail", "com-cn"}
+	randomDomain := func() string {
+		n := 1 + rnd.Intn(5)
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = labels[rnd.Intn(len(labels))]
+		}
+		return strings.Join(parts, ".")
+	}
+
+	succinct := NewSuccinctMatcherGroup()
+	mph := NewMphMatcherGroup()
+	linear := new(MatcherGroup)
+	for i := 0; i < 50000; i++ {
+		pattern := randomDomain()
+		typ := Domain
+		if rnd.Intn(3) == 0 {
+			typ = Full
+		}
+		common.Must2(succinct.AddPattern(pattern, typ))
+		common.Must2(mph.AddPattern(pattern, typ))
+		m, err := typ.New(pattern)
+		common.Must(err)
+		linear.Add(m)
+	}
+	succinct.Build()
+	mph.Build()
+
+	matched := 0
+	for i := 0; i < 200000; i++ {
+		input := randomDomain()
+		if rnd.Intn(10) == 0 {
+			input = input + "."
+		}
+		r1 := len(succinct.Match(input)) > 0
+		r2 := len(mph.Match(input)) > 0
+		r3 := len(linear.Match(input)) > 0
+		if r1 != r2 || r1 != r3 {
+			t.Fatal("for input ", input, " succinct: ", r1, " mph: ", r2, " linear: ", r3)
+		}
+		if r1 {
+			matched++
+		}
+	}
+	if matched == 0 {
+		t.Error("corpus never matched")
+	}
+}
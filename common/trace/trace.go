@@ -0,0 +1,173 @@
+// Package trace records the phases of a connection as a tree of spans.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/serial"
+)
+
+type spanKey int
+
+const currentSpan spanKey = 0
+
+// Attribute is a key-value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Exporter receives the spans of a connection once its root span ends.
+type Exporter interface {
+	Export(spans []*Span)
+}
+
+// Span is a phase of a connection. All methods are no-ops on a nil Span, which is what Start returns when
+// the connection isn't traced.
+type Span struct {
+	Name       string
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+	// Error is the error the phase ended with, if any.
+	Error string
+
+	trace    *traceState
+	children []*Span
+}
+
+type traceState struct {
+	access   sync.Mutex
+	spans    []*Span
+	exporter Exporter
+}
+
+// NewRoot starts the root span of a new trace, which is given to exporter when it ends.
+func NewRoot(ctx context.Context, name string, exporter Exporter) (context.Context, *Span) {
+	s := &Span{
+		Name:      name,
+		StartTime: time.Now(),
+		trace:     &traceState{exporter: exporter},
+	}
+	rand.Read(s.TraceID[:])
+	rand.Read(s.SpanID[:])
+	s.trace.spans = append(s.trace.spans, s)
+	return context.WithValue(ctx, currentSpan, s), s
+}
+
+// Start starts a span as a child of the span in ctx. It returns ctx and nil if ctx has no span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		Name:      name,
+		TraceID:   parent.TraceID,
+		ParentID:  parent.SpanID,
+		StartTime: time.Now(),
+		trace:     parent.trace,
+	}
+	rand.Read(s.SpanID[:])
+
+	parent.trace.access.Lock()
+	parent.trace.spans = append(parent.trace.spans, s)
+	parent.children = append(parent.children, s)
+	parent.trace.access.Unlock()
+	return context.WithValue(ctx, currentSpan, s), s
+}
+
+// FromContext returns the current span in ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Span {
+	if s, ok := ctx.Value(currentSpan).(*Span); ok {
+		return s
+	}
+	return nil
+}
+
+// SetAttribute sets the attribute key of the span to value.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	v := serial.ToString(value)
+
+	s.trace.access.Lock()
+	defer s.trace.access.Unlock()
+	for i := range s.Attributes {
+		if s.Attributes[i].Key == key {
+			s.Attributes[i].Value = v
+			return
+		}
+	}
+	s.Attributes = append(s.Attributes, Attribute{Key: key, Value: v})
+}
+
+// Attribute returns the value of the attribute key of the span.
+func (s *Span) Attribute(key string) string {
+	if s == nil {
+		return ""
+	}
+	s.trace.access.Lock()
+	defer s.trace.access.Unlock()
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// End ends the span, and the spans under it that are still going on. Ending the root span exports the trace.
+func (s *Span) End() {
+	s.EndWithError(nil)
+}
+
+// EndWithError ends the span like End, recording err if it isn't nil.
+func (s *Span) EndWithError(err error) {
+	if s == nil {
+		return
+	}
+	now := time.Now()
+
+	s.trace.access.Lock()
+	if !s.EndTime.IsZero() {
+		s.trace.access.Unlock()
+		return
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.end(now)
+	var spans []*Span
+	if s.trace.spans[0] == s && s.trace.exporter != nil {
+		// spans may still be touched by phases that haven't noticed the end
+		spans = make([]*Span, len(s.trace.spans))
+		for i, span := range s.trace.spans {
+			c := *span
+			c.Attributes = append([]Attribute(nil), span.Attributes...)
+			c.children = nil
+			spans[i] = &c
+		}
+	}
+	s.trace.access.Unlock()
+
+	if spans != nil {
+		s.trace.exporter.Export(spans)
+	}
+}
+
+func (s *Span) end(t time.Time) {
+	s.EndTime = t
+	for _, c := range s.children {
+		if c.EndTime.IsZero() {
+			c.end(t)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package trace_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/xtls/xray-core/common/trace"
+)
+
+type recorder struct {
+	spans []*Span
+}
+
+func (r *recorder) Export(spans []*Span) {
+	r.spans = spans
+}
+
+func TestSpanTree(t *testing.T) {
+	if _, s := Start(context.Background(), "orphan"); s != nil {
+		t.Error("span started without a trace")
+	}
+
+	r := &recorder{}
+	ctx, root := NewRoot(context.Background(), "connection", r)
+	root.SetAttribute("destination", "tcp:example.com:443")
+	dialCtx, dial := Start(ctx, "dial")
+	_, handshake := Start(dialCtx, "tls handshake")
+	handshake.EndWithError(errors.New("bad certificate"))
+	dial.End()
+	_, copying := Start(ctx, "copy")
+	if r.spans != nil {
+		t.Fatal("exported before the root span ends")
+	}
+	root.End()
+	copying.SetAttribute("late", true)
+	copying.End()
+
+	if len(r.spans) != 4 {
+		t.Fatal("expect 4 spans, but got ", len(r.spans))
+	}
+	parents := map[string]string{"connection": "", "dial": "connection", "tls handshake": "dial", "copy": "connection"}
+	byID := make(map[[8]byte]*Span)
+	for _, s := range r.spans {
+		byID[s.SpanID] = s
+	}
+	for _, s := range r.spans {
+		if s.TraceID != root.TraceID {
+			t.Error(s.Name, " is in another trace")
+		}
+		if s.EndTime.IsZero() {
+			t.Error(s.Name, " not ended")
+		}
+		parent := ""
+		if p := byID[s.ParentID]; p != nil {
+			parent = p.Name
+		}
+		if parent != parents[s.Name] {
+			t.Error("parent of ", s.Name, " is ", parent, " instead of ", parents[s.Name])
+		}
+	}
+	if r.spans[0].Attribute("destination") != "tcp:example.com:443" {
+		t.Error("unexpected attributes: ", r.spans[0].Attributes)
+	}
+	if r.spans[2].Error != "bad certificate" {
+		t.Error("unexpected error of ", r.spans[2].Name, ": ", r.spans[2].Error)
+	}
+	if r.spans[3].Attribute("late") != "" || !r.spans[3].EndTime.Equal(root.EndTime) {
+		t.Error("copy changed after the trace was exported")
+	}
+}
@@ -63,13 +63,20 @@ func ParseBytes(b []byte) (UUID, error) {
 	return uuid, nil
 }
 
-// ParseString converts a UUID in string form to object.
+// IsMapped returns true if ParseString maps the string to a UUIDv5, instead
+// of reading a UUID from it.
+func IsMapped(str string) bool {
+	return len(str) > 0 && len(str) <= 30
+}
+
+// ParseString converts a UUID in string form to object. Strings of up to 30
+// bytes are mapped to a UUIDv5 in the zero namespace instead.
 func ParseString(str string) (UUID, error) {
 	var uuid UUID
 
 	text := []byte(str)
 	if l := len(text); l < 32 || l > 36 {
-		if l == 0 || l > 30 {
+		if !IsMapped(str) {
 			return uuid, errors.New("invalid UUID: ", str)
 		}
 		h := sha1.New()
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/xtls/xray-core/common/serial"
+)
+
+// IssueSeverity tells whether an Issue stops a config from being used.
+type IssueSeverity int
+
+const (
+	// IssueWarning is an issue that Xray works around, likely not the way it was meant.
+	IssueWarning IssueSeverity = iota
+	// IssueError is an issue that makes the config unusable.
+	IssueError
+)
+
+func (s IssueSeverity) String() string {
+	if s == IssueError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue is an inconsistency found in a Config.
+type Issue struct {
+	Severity IssueSeverity
+	// Path locates the setting at fault, like "routing.rules[2].outboundTag".
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	return i.Severity.String() + ": " + i.Path + ": " + i.Message
+}
+
+// Validation collects the issues of a Config. It is given to the validators of the settings in the Config.
+type Validation struct {
+	Config *Config
+	// Inbound and Outbound are the handler whose settings are being validated, if any.
+	Inbound  *InboundHandlerConfig
+	Outbound *OutboundHandlerConfig
+
+	inboundTags  map[string]bool
+	outboundTags []string
+	deferred     []func()
+	issues       []Issue
+}
+
+// Warning reports a warning on the setting at path.
+func (v *Validation) Warning(path string, message ...interface{}) {
+	v.issues = append(v.issues, Issue{Severity: IssueWarning, Path: path, Message: serial.Concat(message...)})
+}
+
+// Error reports an error on the setting at path.
+func (v *Validation) Error(path string, message ...interface{}) {
+	v.issues = append(v.issues, Issue{Severity: IssueError, Path: path, Message: serial.Concat(message...)})
+}
+
+// AddInboundTag declares an inbound tag that is not the tag of an inbound handler, like the one of DNS queries.
+func (v *Validation) AddInboundTag(tag string) {
+	if tag != "" {
+		v.inboundTags[tag] = true
+	}
+}
+
+// AddOutboundTag declares an outbound tag that is not the tag of an outbound handler in the config, like the one
+// of a handler an app adds itself.
+func (v *Validation) AddOutboundTag(tag string) {
+	if tag != "" {
+		v.outboundTags = append(v.outboundTags, tag)
+	}
+}
+
+// HasInboundTag returns whether tag is an inbound tag. It is only complete in functions given to Defer.
+func (v *Validation) HasInboundTag(tag string) bool {
+	return v.inboundTags[tag]
+}
+
+// OutboundTags returns the outbound tags. It is only complete in functions given to Defer.
+func (v *Validation) OutboundTags() []string {
+	return v.outboundTags
+}
+
+// HasOutboundTag returns whether tag is an outbound tag. It is only complete in functions given to Defer.
+func (v *Validation) HasOutboundTag(tag string) bool {
+	for _, t := range v.outboundTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Defer runs f after all the settings are validated, when all the tags are known.
+func (v *Validation) Defer(f func()) {
+	v.deferred = append(v.deferred, f)
+}
+
+// ConfigValidator checks config, the settings found at path in the Config of v, and reports their issues to v.
+// Path is empty for the settings of apps, which are found at their own section.
+type ConfigValidator func(v *Validation, path string, config interface{})
+
+var configValidators = make(map[reflect.Type]ConfigValidator)
+
+// RegisterConfigValidator registers the validator of the settings of type configType.
+func RegisterConfigValidator(configType interface{}, validator ConfigValidator) error {
+	t := reflect.TypeOf(configType)
+	if _, found := configValidators[t]; found {
+		return newError(t.String(), " already has a validator")
+	}
+	configValidators[t] = validator
+	return nil
+}
+
+func (v *Validation) validate(path string, message *serial.TypedMessage) {
+	if message == nil {
+		return
+	}
+	config, err := message.GetInstance()
+	if err != nil {
+		v.Error(path, "unknown settings ", message.Type)
+		return
+	}
+	if validator, found := configValidators[reflect.TypeOf(config)]; found {
+		validator(v, path, config)
+	}
+}
+
+// ValidateConfig cross-checks the sections of config, beyond what each of them checks when it is built.
+func ValidateConfig(config *Config) []Issue {
+	v := &Validation{
+		Config:      config,
+		inboundTags: make(map[string]bool),
+	}
+
+	for i, inbound := range config.Inbound {
+		if inbound.Tag == "" {
+			continue
+		}
+		if v.inboundTags[inbound.Tag] {
+			v.Error("inbounds["+strconv.Itoa(i)+"].tag", "duplicated tag ", inbound.Tag)
+		}
+		v.inboundTags[inbound.Tag] = true
+	}
+	for i, outbound := range config.Outbound {
+		if outbound.Tag == "" {
+			continue
+		}
+		if v.HasOutboundTag(outbound.Tag) {
+			v.Error("outbounds["+strconv.Itoa(i)+"].tag", "duplicated tag ", outbound.Tag)
+		}
+		v.outboundTags = append(v.outboundTags, outbound.Tag)
+	}
+
+	for _, app := range config.App {
+		v.validate("", app)
+	}
+	for i, inbound := range config.Inbound {
+		path := "inbounds[" + strconv.Itoa(i) + "]"
+		v.Inbound = inbound
+		v.validate(path, inbound.ReceiverSettings)
+		v.validate(path+".settings", inbound.ProxySettings)
+	}
+	v.Inbound = nil
+	for i, outbound := range config.Outbound {
+		path := "outbounds[" + strconv.Itoa(i) + "]"
+		v.Outbound = outbound
+		v.validate(path, outbound.SenderSettings)
+		v.validate(path+".settings", outbound.ProxySettings)
+	}
+	v.Outbound = nil
+
+	for _, f := range v.deferred {
+		f()
+	}
+	return v.issues
+}
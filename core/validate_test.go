@@ -0,0 +1,229 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/app/dns"
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/reverse"
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	. "github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/proxy/dokodemo"
+	"github.com/xtls/xray-core/proxy/freedom"
+	"github.com/xtls/xray-core/proxy/vless/inbound"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func validationConfig(routing *router.Config) *Config {
+	config := &Config{
+		Inbound: []*InboundHandlerConfig{
+			{
+				Tag:              "in",
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{}),
+				ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+					Address: net.NewIPOrDomain(net.LocalHostIP),
+					Port:    80,
+				}),
+			},
+		},
+		Outbound: []*OutboundHandlerConfig{
+			{
+				Tag:            "direct",
+				SenderSettings: serial.ToTypedMessage(&proxyman.SenderConfig{}),
+				ProxySettings:  serial.ToTypedMessage(&freedom.Config{}),
+			},
+			{
+				Tag:            "proxy-a",
+				SenderSettings: serial.ToTypedMessage(&proxyman.SenderConfig{}),
+				ProxySettings:  serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+	if routing != nil {
+		config.App = append(config.App, serial.ToTypedMessage(routing))
+	}
+	return config
+}
+
+func TestValidateConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   func() *Config
+		path     string
+		severity IssueSeverity
+	}{
+		{
+			name: "duplicated inbound tag",
+			config: func() *Config {
+				c := validationConfig(nil)
+				c.Inbound = append(c.Inbound, c.Inbound[0])
+				return c
+			},
+			path:     "inbounds[1].tag",
+			severity: IssueError,
+		},
+		{
+			name: "duplicated outbound tag",
+			config: func() *Config {
+				c := validationConfig(nil)
+				c.Outbound[1].Tag = "direct"
+				return c
+			},
+			path:     "outbounds[1].tag",
+			severity: IssueError,
+		},
+		{
+			name: "rule to missing outbound",
+			config: func() *Config {
+				return validationConfig(&router.Config{
+					Rule: []*router.RoutingRule{
+						{TargetTag: &router.RoutingRule_Tag{Tag: "direct"}},
+						{TargetTag: &router.RoutingRule_Tag{Tag: "blocked"}},
+					},
+				})
+			},
+			path:     "routing.rules[1].outboundTag",
+			severity: IssueError,
+		},
+		{
+			name: "rule to missing balancer",
+			config: func() *Config {
+				return validationConfig(&router.Config{
+					Rule: []*router.RoutingRule{
+						{TargetTag: &router.RoutingRule_BalancingTag{BalancingTag: "b"}},
+					},
+				})
+			},
+			path:     "routing.rules[0].balancerTag",
+			severity: IssueError,
+		},
+		{
+			name: "rule on missing inbound",
+			config: func() *Config {
+				return validationConfig(&router.Config{
+					Rule: []*router.RoutingRule{
+						{TargetTag: &router.RoutingRule_Tag{Tag: "direct"}, InboundTag: []string{"in", "socks-in"}},
+					},
+				})
+			},
+			path:     "routing.rules[0].inboundTag",
+			severity: IssueWarning,
+		},
+		{
+			name: "balancer selecting no outbound",
+			config: func() *Config {
+				return validationConfig(&router.Config{
+					BalancingRule: []*router.BalancingRule{
+						{Tag: "b", OutboundSelector: []string{"proxy-b"}},
+					},
+				})
+			},
+			path:     "routing.balancers[0].selector",
+			severity: IssueWarning,
+		},
+		{
+			name: "balancer falling back to missing outbound",
+			config: func() *Config {
+				return validationConfig(&router.Config{
+					BalancingRule: []*router.BalancingRule{
+						{Tag: "b", OutboundSelector: []string{"proxy-"}, FallbackTag: "fallback"},
+					},
+				})
+			},
+			path:     "routing.balancers[0].fallbackTag",
+			severity: IssueError,
+		},
+		{
+			name: "destOverride without sniffing",
+			config: func() *Config {
+				c := validationConfig(nil)
+				c.Inbound[0].ReceiverSettings = serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					SniffingSettings: &proxyman.SniffingConfig{DestinationOverride: []string{"http"}},
+				})
+				return c
+			},
+			path:     "inbounds[0].sniffing.destOverride",
+			severity: IssueWarning,
+		},
+		{
+			name: "fallbacks over websocket",
+			config: func() *Config {
+				c := validationConfig(nil)
+				c.Inbound[0].ReceiverSettings = serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					StreamSettings: &internet.StreamConfig{ProtocolName: "websocket"},
+				})
+				c.Inbound[0].ProxySettings = serial.ToTypedMessage(&inbound.Config{
+					Decryption: "none",
+					Fallbacks:  []*inbound.Fallback{{Dest: "80"}},
+				})
+				return c
+			},
+			path:     "inbounds[0].settings.fallbacks",
+			severity: IssueWarning,
+		},
+		{
+			name: "dialer proxy to itself",
+			config: func() *Config {
+				c := validationConfig(nil)
+				c.Outbound[1].SenderSettings = serial.ToTypedMessage(&proxyman.SenderConfig{
+					StreamSettings: &internet.StreamConfig{
+						SocketSettings: &internet.SocketConfig{DialerProxy: "proxy-a"},
+					},
+				})
+				return c
+			},
+			path:     "outbounds[1].streamSettings.sockopt.dialerProxy",
+			severity: IssueError,
+		},
+		{
+			name: "proxy settings to missing outbound",
+			config: func() *Config {
+				c := validationConfig(nil)
+				c.Outbound[1].SenderSettings = serial.ToTypedMessage(&proxyman.SenderConfig{
+					ProxySettings: &internet.ProxyConfig{Tag: "relay"},
+				})
+				return c
+			},
+			path:     "outbounds[1].proxySettings.tag",
+			severity: IssueError,
+		},
+	}
+
+	for _, c := range cases {
+		issues := ValidateConfig(c.config())
+		if len(issues) != 1 || issues[0].Path != c.path || issues[0].Severity != c.severity {
+			t.Error(c.name, ": expect a ", c.severity, " on ", c.path, ", but got ", issues)
+		}
+	}
+}
+
+func TestValidateConfigTagsOfApps(t *testing.T) {
+	config := validationConfig(&router.Config{
+		Rule: []*router.RoutingRule{
+			{TargetTag: &router.RoutingRule_Tag{Tag: "portal"}, InboundTag: []string{"in"}},
+			{TargetTag: &router.RoutingRule_Tag{Tag: "direct"}, InboundTag: []string{"bridge", "dns-in"}},
+			{TargetTag: &router.RoutingRule_BalancingTag{BalancingTag: "b"}},
+		},
+		BalancingRule: []*router.BalancingRule{
+			{Tag: "b", OutboundSelector: []string{"proxy-"}, FallbackTag: "direct"},
+		},
+	})
+	config.App = append(config.App,
+		serial.ToTypedMessage(&reverse.Config{
+			BridgeConfig: []*reverse.BridgeConfig{{Tag: "bridge", Domain: "reverse.example.com"}},
+			PortalConfig: []*reverse.PortalConfig{{Tag: "portal", Domain: "reverse.example.com"}},
+		}),
+		serial.ToTypedMessage(&dns.Config{Tag: "dns-in"}),
+	)
+	config.Outbound[1].SenderSettings = serial.ToTypedMessage(&proxyman.SenderConfig{
+		StreamSettings: &internet.StreamConfig{
+			SocketSettings: &internet.SocketConfig{DialerProxy: "direct"},
+		},
+	})
+
+	if issues := ValidateConfig(config); len(issues) != 0 {
+		t.Error("unexpected issues: ", issues)
+	}
+}
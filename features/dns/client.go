@@ -28,6 +28,14 @@ type HostsLookup interface {
 	LookupHosts(domain string) *net.Address
 }
 
+// ServerLookup is an optional interface of Client for querying a specific name server.
+//
+// xray:api:beta
+type ServerLookup interface {
+	// LookupIPWithServer returns IP addresses of domain queried from the name server with the given tag.
+	LookupIPWithServer(tag string, domain string, option IPOption) ([]net.IP, error)
+}
+
 // ClientType returns the type of Client interface. Can be used for implementing common.HasType.
 //
 // xray:api:beta
@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"time"
+
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/features"
 )
@@ -21,3 +23,29 @@ type FakeDNSEngineRev0 interface {
 	IsIPInIPPool(ip net.Address) bool
 	GetFakeIPForDomain3(domain string, IPv4, IPv6 bool) []net.Address
 }
+
+// FakeDNSEngineRev1 tells the TTL the answers with its fake IPs are to have.
+type FakeDNSEngineRev1 interface {
+	FakeDNSEngineRev0
+	// GetFakeIPTTL returns the TTL of ip, or false if ip is not a fake IP of the engine.
+	GetFakeIPTTL(ip net.Address) (uint32, bool)
+}
+
+// FakeDNSMapping is a domain mapped to a fake IP.
+type FakeDNSMapping struct {
+	Domain string
+	IP     net.Address
+	// LastAccess is when the mapping was last looked up either way, zero if it was not since it
+	// was restored.
+	LastAccess time.Time
+}
+
+// FakeDNSEngineRev2 lets the mappings of the engine be inspected and flushed.
+type FakeDNSEngineRev2 interface {
+	FakeDNSEngineRev1
+	// GetFakeDNSMappings returns the mappings held, from the most recently used one.
+	GetFakeDNSMappings() []FakeDNSMapping
+	// FlushFakeDNS removes the mapping of domain and the one of ip, or all the mappings if neither
+	// is given, and returns the number removed.
+	FlushFakeDNS(domain string, ip net.Address) int
+}
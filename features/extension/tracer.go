@@ -0,0 +1,20 @@
+package extension
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/trace"
+	"github.com/xtls/xray-core/features"
+)
+
+// Tracer is a feature that traces connections as trees of spans.
+type Tracer interface {
+	features.Feature
+
+	// Trace starts the root span of a connection, or returns ctx and nil if the connection isn't sampled.
+	Trace(ctx context.Context) (context.Context, *trace.Span)
+}
+
+func TracerType() interface{} {
+	return (*Tracer)(nil)
+}
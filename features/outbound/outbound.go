@@ -2,8 +2,11 @@ package outbound
 
 import (
 	"context"
+	"time"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/features"
 	"github.com/xtls/xray-core/transport"
 )
@@ -37,6 +40,88 @@ type Manager interface {
 	RemoveHandler(ctx context.Context, tag string) error
 }
 
+// UsageReporter is an optional interface for handlers that keep track of their usage.
+type UsageReporter interface {
+	// LastUsed returns the last time a session was dispatched to the handler, or zero time if never.
+	LastUsed() time.Time
+	// SessionCount returns the number of sessions dispatched to the handler so far.
+	SessionCount() uint64
+}
+
+// AccessAnnotator is an optional interface for handlers that add details to the
+// access log of the connections dispatched to them. It is called right before
+// the access message is recorded, and the returned context is the one passed
+// to Dispatch.
+type AccessAnnotator interface {
+	AnnotateAccess(ctx context.Context, msg *log.AccessMessage) context.Context
+}
+
+// ICMPRelay is an optional interface for handlers that relay ICMP echo. ICMP
+// traffic is only dispatched to handlers that implement it and return true.
+type ICMPRelay interface {
+	RelaysICMP() bool
+}
+
+// RelaysICMP returns true if ICMP echo may be dispatched to h.
+func RelaysICMP(h Handler) bool {
+	r, ok := h.(ICMPRelay)
+	return ok && r.RelaysICMP()
+}
+
+// HandlerLister is an optional interface for Manager that lists all its tagged handlers.
+type HandlerLister interface {
+	ListHandlers() []Handler
+}
+
+// IdleExpirer is an optional interface for Manager that removes handlers left unused.
+type IdleExpirer interface {
+	// ExpireAfterIdle removes the handler with the given tag once it has not been used for the given duration.
+	ExpireAfterIdle(tag string, idle time.Duration) error
+	// IdleExpiry returns the duration set by ExpireAfterIdle for the handler, or 0 if none.
+	IdleExpiry(tag string) time.Duration
+}
+
+// SessionState is the phase of a session dispatched to an outbound handler.
+type SessionState int
+
+const (
+	// SessionHandshaking is a session whose outbound has not connected yet, as with a dial in progress.
+	SessionHandshaking SessionState = iota
+	// SessionEstablished is a session relaying traffic.
+	SessionEstablished
+	// SessionDraining is a session whose inbound side has closed, with the response still being relayed.
+	SessionDraining
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionHandshaking:
+		return "handshaking"
+	case SessionEstablished:
+		return "established"
+	case SessionDraining:
+		return "draining"
+	}
+	return "unknown"
+}
+
+// SessionInfo describes a session dispatched to an outbound handler.
+type SessionInfo struct {
+	ID          uint32
+	InboundTag  string
+	OutboundTag string
+	Source      net.Destination
+	Target      net.Destination
+	Start       time.Time
+	State       SessionState
+}
+
+// SessionLister is an optional interface for Manager that lists the sessions being dispatched to its handlers.
+type SessionLister interface {
+	// ListSessions returns the sessions, the oldest first.
+	ListSessions() []*SessionInfo
+}
+
 // ManagerType returns the type of Manager interface. Can be used to implement common.HasType.
 //
 // xray:api:stable
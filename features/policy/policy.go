@@ -1,11 +1,14 @@
 package policy
 
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
 import (
 	"context"
 	"runtime"
 	"time"
 
 	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features"
 )
 
@@ -35,6 +38,22 @@ type Buffer struct {
 	PerConnection int32
 }
 
+// Latency contains settings for latency sensitive connections.
+type Latency struct {
+	// Whether connections are interactive, so that latency matters more than throughput.
+	HighPriority bool
+	// Longest time copied data may be held back for high priority connections.
+	MaxDelay time.Duration
+}
+
+// CopyDelay returns how long copied data may be held back, 0 for no limit.
+func (l Latency) CopyDelay() time.Duration {
+	if !l.HighPriority {
+		return 0
+	}
+	return l.MaxDelay
+}
+
 // SystemStats contains stat policy settings on system level.
 type SystemStats struct {
 	// Whether or not to enable stat counter for uplink traffic in inbound handlers.
@@ -45,6 +64,10 @@ type SystemStats struct {
 	OutboundUplink bool
 	// Whether or not to enable stat counter for downlink traffic in outbound handlers.
 	OutboundDownlink bool
+	// Whether or not to enable stat gauge for open connections in inbound handlers.
+	InboundConnections bool
+	// Whether or not to enable stat gauge for open connections in outbound handlers.
+	OutboundConnections bool
 }
 
 // System contains policy settings at system level.
@@ -58,6 +81,7 @@ type Session struct {
 	Timeouts Timeout // Timeout settings
 	Stats    Stats
 	Buffer   Buffer
+	Latency  Latency
 }
 
 // Manager is a feature that provides Policy for the given user by its id or level.
@@ -73,6 +97,22 @@ type Manager interface {
 	ForSystem() System
 }
 
+// InboundManager is an optional interface of Manager, for policies that may be overridden per inbound handler.
+type InboundManager interface {
+	// ForInbound returns the Session policy for the given user level, with the overrides of the inbound handler applied.
+	ForInbound(tag string, level uint32) Session
+}
+
+// ForContext returns the Session policy for the given user level, taking the inbound handler in ctx into account.
+func ForContext(ctx context.Context, m Manager, level uint32) Session {
+	if im, ok := m.(InboundManager); ok {
+		if inbound := session.InboundFromContext(ctx); inbound != nil && len(inbound.Tag) > 0 {
+			return im.ForInbound(inbound.Tag, level)
+		}
+	}
+	return m.ForLevel(level)
+}
+
 // ManagerType returns the type of Manager interface. Can be used to implement common.HasType.
 //
 // xray:api:stable
@@ -125,6 +165,10 @@ func SessionDefault() Session {
 			UserDownlink: false,
 		},
 		Buffer: defaultBufferPolicy(),
+		Latency: Latency{
+			HighPriority: false,
+			MaxDelay:     time.Millisecond * 10,
+		},
 	}
 }
 
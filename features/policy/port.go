@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// CheckTargetPort rejects destinations outside the port restriction of the session,
+// with an access log entry and a count in stats. sm may be nil, when nothing is counted.
+func CheckTargetPort(ctx context.Context, sm stats.Manager, destination net.Destination) error {
+	r := session.PortRestrictionFromContext(ctx)
+	if r.Permits(destination.Port) {
+		return nil
+	}
+
+	reject(ctx, sm, destination, "target port not allowed", "port")
+	return newError("target port of ", destination, " is not allowed").AtInfo()
+}
+
+// reject logs the rejection of destination, and counts it in the "rejected>>>kind" counters of the inbound and the user.
+func reject(ctx context.Context, sm stats.Manager, destination net.Destination, reason string, kind string) {
+	var email string
+	msg := &log.AccessMessage{
+		From:   "unknown",
+		To:     destination,
+		Status: log.AccessRejected,
+		Reason: reason,
+	}
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		if inbound.Source.IsValid() {
+			msg.From = inbound.Source
+		}
+		if inbound.User != nil {
+			email = inbound.User.Email
+			msg.Email = email
+		}
+		if len(inbound.Tag) > 0 && sm != nil {
+			if c, _ := stats.GetOrRegisterCounter(sm, "inbound>>>"+inbound.Tag+">>>rejected>>>"+kind); c != nil {
+				c.Add(1)
+			}
+		}
+	}
+	if len(email) > 0 && sm != nil {
+		if c, _ := stats.GetOrRegisterCounter(sm, "user>>>"+email+">>>rejected>>>"+kind); c != nil {
+			c.Add(1)
+		}
+	}
+	log.Record(msg)
+}
@@ -0,0 +1,86 @@
+package policy_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/policy"
+)
+
+func TestCheckTargetPort(t *testing.T) {
+	m, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+
+	restriction := protocol.NewPortRestriction(&net.PortList{Range: []*net.PortRange{{From: 443, To: 443}}}, nil)
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+		Tag:  "in",
+		User: &protocol.MemoryUser{Email: "love@example.com"},
+	})
+	restricted := session.ContextWithPortRestriction(ctx, restriction)
+
+	if err := policy.CheckTargetPort(restricted, m, net.TCPDestination(net.DomainAddress("example.com"), 443)); err != nil {
+		t.Error("port 443 should be allowed: ", err)
+	}
+	if err := policy.CheckTargetPort(restricted, m, net.TCPDestination(net.DomainAddress("example.com"), 25)); err == nil {
+		t.Error("port 25 should be rejected")
+	}
+	if err := policy.CheckTargetPort(ctx, m, net.TCPDestination(net.DomainAddress("example.com"), 25)); err != nil {
+		t.Error("unrestricted session should reach port 25: ", err)
+	}
+
+	for _, name := range []string{"inbound>>>in>>>rejected>>>port", "user>>>love@example.com>>>rejected>>>port"} {
+		c := m.GetCounter(name)
+		if c == nil {
+			t.Fatal("counter ", name, " is not registered")
+		}
+		if v := c.Value(); v != 1 {
+			t.Error("counter ", name, ": expected 1, but got ", v)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	m, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+
+	restriction, err := protocol.NewTargetRestriction([]string{"loopback", "linklocal"})
+	common.Must(err)
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+		Tag:  "in",
+		User: &protocol.MemoryUser{Email: "love@example.com"},
+	})
+	restricted := session.ContextWithTargetRestriction(ctx, restriction)
+
+	for _, address := range []net.Address{net.LocalHostIP, net.ParseAddress("169.254.169.254")} {
+		err := policy.CheckTarget(restricted, m, net.TCPDestination(address, 80))
+		if errors.Cause(err) != policy.ErrTargetForbidden {
+			t.Error(address, " should be rejected, but got ", err)
+		}
+	}
+	for _, address := range []net.Address{net.ParseAddress("1.1.1.1"), net.DomainAddress("localhost")} {
+		if err := policy.CheckTarget(restricted, m, net.TCPDestination(address, 80)); err != nil {
+			t.Error(address, " should be allowed: ", err)
+		}
+	}
+	if err := policy.CheckTarget(ctx, m, net.TCPDestination(net.LocalHostIP, 80)); err != nil {
+		t.Error("unrestricted session should reach loopback: ", err)
+	}
+
+	for _, name := range []string{"inbound>>>in>>>rejected>>>target", "user>>>love@example.com>>>rejected>>>target"} {
+		c := m.GetCounter(name)
+		if c == nil {
+			t.Fatal("counter ", name, " is not registered")
+		}
+		if v := c.Value(); v != 2 {
+			t.Error("counter ", name, ": expected 2, but got ", v)
+		}
+	}
+}
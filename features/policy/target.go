@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// ErrTargetForbidden is the cause of the errors of CheckTarget, which has logged the rejection already.
+var ErrTargetForbidden = newError("target address not allowed")
+
+// CheckTarget rejects destinations whose IP is forbidden by the target restriction of the session,
+// with an access log entry and a count in stats. sm may be nil, when nothing is counted. Domains pass,
+// and are to be checked once resolved.
+func CheckTarget(ctx context.Context, sm stats.Manager, destination net.Destination) error {
+	r := session.TargetRestrictionFromContext(ctx)
+	if r.Permits(destination.Address) {
+		return nil
+	}
+
+	reject(ctx, sm, destination, "target address not allowed", "target")
+	return newError("target ", destination, " is not allowed").Base(ErrTargetForbidden).AtInfo()
+}
+
+// CheckDestination rejects destinations that CheckTargetPort or CheckTarget rejects.
+func CheckDestination(ctx context.Context, sm stats.Manager, destination net.Destination) error {
+	if err := CheckTargetPort(ctx, sm, destination); err != nil {
+		return err
+	}
+	return CheckTarget(ctx, sm, destination)
+}
@@ -17,6 +17,9 @@ type Context interface {
 	// GetSourcePort returns the source port of the connection.
 	GetSourcePort() net.Port
 
+	// GetLocalIPs returns the local IPs the connection arrived on.
+	GetLocalIPs() []net.IP
+
 	// GetTargetIPs returns the target IP of the connection or resolved IPs of target domain.
 	GetTargetIPs() []net.IP
 
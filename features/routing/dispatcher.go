@@ -26,3 +26,10 @@ type Dispatcher interface {
 func DispatcherType() interface{} {
 	return (*Dispatcher)(nil)
 }
+
+// RouteLocker is implemented by dispatchers which can hold routing decisions
+// back, so that several changes to rules and handlers are seen all at once.
+type RouteLocker interface {
+	LockRoutes()
+	UnlockRoutes()
+}
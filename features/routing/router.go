@@ -1,9 +1,12 @@
 package routing
 
 import (
+	"time"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/features"
+	"github.com/xtls/xray-core/features/stats"
 )
 
 // Router is a feature to choose an outbound tag for the given request.
@@ -18,6 +21,13 @@ type Router interface {
 	RemoveRule(tag string) error
 }
 
+// RulesSnapshotter is implemented by routers which can go back to a
+// previously saved set of rules and balancers.
+type RulesSnapshotter interface {
+	SnapshotRules() interface{}
+	RestoreRules(snapshot interface{}) error
+}
+
 // Route is the routing result of Router feature.
 //
 // xray:api:stable
@@ -32,6 +42,45 @@ type Route interface {
 	GetOutboundTag() string
 }
 
+// CountedRoute is implemented by routes picked by a rule that keeps statistics or has a budget.
+type CountedRoute interface {
+	// Dispatched records a hit on the rule, and returns the counters for the
+	// bytes sent and received by the connection dispatched along the route.
+	Dispatched() (uplink stats.Counter, downlink stats.Counter)
+}
+
+// RuleStats are the statistics of a routing rule.
+type RuleStats struct {
+	Index    int
+	RuleTag  string
+	Hits     uint64
+	LastHit  time.Time
+	Uplink   int64
+	Downlink int64
+}
+
+// RuleStatsReporter is implemented by routers which can keep statistics of their rules.
+type RuleStatsReporter interface {
+	// RuleStats returns the statistics of all rules in order, and zeroes them if reset is set.
+	RuleStats(reset bool) ([]RuleStats, error)
+}
+
+// RuleBudget is the state of the byte budget of a routing rule.
+type RuleBudget struct {
+	Index       int
+	RuleTag     string
+	Bytes       int64
+	Used        int64
+	WindowEnd   time.Time
+	OverflowTag string
+}
+
+// RuleBudgetReporter is implemented by routers which support byte budgets on their rules.
+type RuleBudgetReporter interface {
+	// RuleBudgets returns the state of the budgets of all rules having one, in order.
+	RuleBudgets() []RuleBudget
+}
+
 // RouterType return the type of Router interface. Can be used to implement common.HasType.
 //
 // xray:api:stable
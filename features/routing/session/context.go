@@ -44,6 +44,14 @@ func (ctx *Context) GetSourcePort() net.Port {
 	return ctx.Inbound.Source.Port
 }
 
+// GetLocalIPs implements routing.Context.
+func (ctx *Context) GetLocalIPs() []net.IP {
+	if ctx.Inbound == nil || !ctx.Inbound.Local.IsValid() || !ctx.Inbound.Local.Address.Family().IsIP() {
+		return nil
+	}
+	return []net.IP{ctx.Inbound.Local.Address.IP()}
+}
+
 // GetTargetIPs implements routing.Context.
 func (ctx *Context) GetTargetIPs() []net.IP {
 	if ctx.Outbound == nil || !ctx.Outbound.Target.IsValid() {
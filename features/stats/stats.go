@@ -79,6 +79,32 @@ type Manager interface {
 	GetChannel(string) Channel
 }
 
+// GaugeManager is the interface for Managers keeping gauges in addition to
+// counters. A gauge is a Counter whose value goes up and down, like the number
+// of open connections, so it is never reset when queried.
+type GaugeManager interface {
+	// RegisterGauge registers a new gauge to the manager. The identifier string must not be empty, and unique among other counters and gauges.
+	RegisterGauge(string) (Counter, error)
+	// UnregisterGauge unregisters a gauge from the manager by its identifier.
+	UnregisterGauge(string) error
+	// GetGauge returns a gauge by its identifier.
+	GetGauge(string) Counter
+}
+
+// GetOrRegisterGauge tries to get the gauge first. If not exist, it then tries to create a new gauge.
+func GetOrRegisterGauge(m Manager, name string) (Counter, error) {
+	gm, ok := m.(GaugeManager)
+	if !ok {
+		return nil, newError("gauges are not supported by the stats manager")
+	}
+	gauge := gm.GetGauge(name)
+	if gauge != nil {
+		return gauge, nil
+	}
+
+	return gm.RegisterGauge(name)
+}
+
 // GetOrRegisterCounter tries to get the StatCounter first. If not exist, it then tries to create a new counter.
 func GetOrRegisterCounter(m Manager, name string) (Counter, error) {
 	counter := m.GetCounter(name)
@@ -4,8 +4,10 @@ import (
 	"strings"
 
 	"github.com/xtls/xray-core/app/commander"
+	fakednsservice "github.com/xtls/xray-core/app/dns/fakedns/command"
 	loggerservice "github.com/xtls/xray-core/app/log/command"
 	observatoryservice "github.com/xtls/xray-core/app/observatory/command"
+	policyservice "github.com/xtls/xray-core/app/policy/command"
 	handlerservice "github.com/xtls/xray-core/app/proxyman/command"
 	routerservice "github.com/xtls/xray-core/app/router/command"
 	statsservice "github.com/xtls/xray-core/app/stats/command"
@@ -13,9 +15,27 @@ import (
 )
 
 type APIConfig struct {
-	Tag      string   `json:"tag"`
-	Listen   string   `json:"listen"`
-	Services []string `json:"services"`
+	Tag      string            `json:"tag"`
+	Listen   string            `json:"listen"`
+	Services []string          `json:"services"`
+	Gateway  *APIGatewayConfig `json:"gateway"`
+}
+
+type APIGatewayConfig struct {
+	Listen         string   `json:"listen"`
+	Token          string   `json:"token"`
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+func (c *APIGatewayConfig) Build() (*commander.GatewayConfig, error) {
+	if c.Listen == "" {
+		return nil, newError("API gateway listen can't be empty.")
+	}
+	return &commander.GatewayConfig{
+		Listen:         c.Listen,
+		Token:          c.Token,
+		AllowedOrigins: c.AllowedOrigins,
+	}, nil
 }
 
 func (c *APIConfig) Build() (*commander.Config, error) {
@@ -38,12 +58,24 @@ func (c *APIConfig) Build() (*commander.Config, error) {
 			services = append(services, serial.ToTypedMessage(&observatoryservice.Config{}))
 		case "routingservice":
 			services = append(services, serial.ToTypedMessage(&routerservice.Config{}))
+		case "policyservice":
+			services = append(services, serial.ToTypedMessage(&policyservice.Config{}))
+		case "fakednsservice":
+			services = append(services, serial.ToTypedMessage(&fakednsservice.Config{}))
 		}
 	}
 
-	return &commander.Config{
+	config := &commander.Config{
 		Tag:     c.Tag,
 		Listen:  c.Listen,
 		Service: services,
-	}, nil
+	}
+	if c.Gateway != nil {
+		gateway, err := c.Gateway.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.Gateway = gateway
+	}
+	return config, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/uuid"
 )
 
 type StringList []string
@@ -67,6 +68,8 @@ func (v Network) Build() net.Network {
 		return net.Network_UDP
 	case "unix":
 		return net.Network_UNIX
+	case "icmp":
+		return net.Network_ICMP
 	default:
 		return net.Network_Unknown
 	}
@@ -241,3 +244,25 @@ func (v *User) Build() *protocol.User {
 		Level: uint32(v.LevelByte),
 	}
 }
+
+// accountIDs tracks the ids of the accounts in a list, to tell when an id
+// mapped to a UUID collides with the UUID of another account.
+type accountIDs map[uuid.UUID]string
+
+// parse returns the UUID of the id of an account in the list, in its
+// canonical form.
+func (ids accountIDs) parse(list string, id string) (string, error) {
+	u, err := uuid.ParseString(id)
+	if err != nil {
+		return "", err
+	}
+	mapped := uuid.IsMapped(id)
+	if other, found := ids[u]; found && other != id && (mapped || uuid.IsMapped(other)) {
+		return "", newError(list, `: id "`, id, `" and id "`, other, `" are both `, u.String())
+	}
+	ids[u] = id
+	if mapped {
+		newError(list, `: id "`, id, `" is mapped to `, u.String()).AtInfo().WriteToLog()
+	}
+	return u.String(), nil
+}
@@ -18,6 +18,9 @@ type NameServerConfig struct {
 	Domains       []string
 	ExpectIPs     StringList
 	QueryStrategy string
+	Tag           string
+	AnswerOrder   string
+	DNSSEC        string
 }
 
 func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
@@ -35,6 +38,9 @@ func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
 		Domains       []string   `json:"domains"`
 		ExpectIPs     StringList `json:"expectIps"`
 		QueryStrategy string     `json:"queryStrategy"`
+		Tag           string     `json:"tag"`
+		AnswerOrder   string     `json:"answerOrder"`
+		DNSSEC        string     `json:"dnssec"`
 	}
 	if err := json.Unmarshal(data, &advanced); err == nil {
 		c.Address = advanced.Address
@@ -44,6 +50,9 @@ func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
 		c.Domains = advanced.Domains
 		c.ExpectIPs = advanced.ExpectIPs
 		c.QueryStrategy = advanced.QueryStrategy
+		c.Tag = advanced.Tag
+		c.AnswerOrder = advanced.AnswerOrder
+		c.DNSSEC = advanced.DNSSEC
 		return nil
 	}
 
@@ -104,6 +113,16 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		myClientIP = []byte(c.ClientIP.IP())
 	}
 
+	answerOrder, err := resolveAnswerOrder(c.AnswerOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	dnssec, err := resolveDNSSECMode(c.DNSSEC)
+	if err != nil {
+		return nil, err
+	}
+
 	return &dns.NameServer{
 		Address: &net.Endpoint{
 			Network: net.Network_UDP,
@@ -116,6 +135,9 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		Geoip:             geoipList,
 		OriginalRules:     originalRules,
 		QueryStrategy:     resolveQueryStrategy(c.QueryStrategy),
+		Tag:               c.Tag,
+		AnswerOrder:       answerOrder,
+		Dnssec:            dnssec,
 	}, nil
 }
 
@@ -128,14 +150,43 @@ var typeMap = map[router.Domain_Type]dns.DomainMatchingType{
 
 // DNSConfig is a JSON serializable object for dns.Config.
 type DNSConfig struct {
-	Servers                []*NameServerConfig `json:"servers"`
-	Hosts                  *HostsWrapper       `json:"hosts"`
-	ClientIP               *Address            `json:"clientIp"`
-	Tag                    string              `json:"tag"`
-	QueryStrategy          string              `json:"queryStrategy"`
-	DisableCache           bool                `json:"disableCache"`
-	DisableFallback        bool                `json:"disableFallback"`
-	DisableFallbackIfMatch bool                `json:"disableFallbackIfMatch"`
+	Servers                []*NameServerConfig   `json:"servers"`
+	Hosts                  *HostsWrapper         `json:"hosts"`
+	ClientIP               *Address              `json:"clientIp"`
+	Tag                    string                `json:"tag"`
+	QueryStrategy          string                `json:"queryStrategy"`
+	DisableCache           bool                  `json:"disableCache"`
+	DisableFallback        bool                  `json:"disableFallback"`
+	DisableFallbackIfMatch bool                  `json:"disableFallbackIfMatch"`
+	Rewrites               []*QueryRewriteConfig `json:"rewrites"`
+	AnswerOrder            string                `json:"answerOrder"`
+	TrustAnchors           []string              `json:"trustAnchors"`
+}
+
+// QueryRewriteConfig rewrites queries of domains matching Match to Replace.
+type QueryRewriteConfig struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+}
+
+// Build implements Buildable
+func (c *QueryRewriteConfig) Build() ([]*dns.Config_QueryRewrite, error) {
+	if len(c.Replace) == 0 {
+		return nil, newError("empty replacement of rewrite rule: ", c.Match)
+	}
+	parsedDomain, err := parseDomainRule(c.Match)
+	if err != nil {
+		return nil, newError("invalid rewrite rule: ", c.Match).Base(err)
+	}
+	rewrites := make([]*dns.Config_QueryRewrite, 0, len(parsedDomain))
+	for _, pd := range parsedDomain {
+		rewrites = append(rewrites, &dns.Config_QueryRewrite{
+			Type:        toDomainMatchingType(pd.Type),
+			Domain:      pd.Value,
+			Replacement: c.Replace,
+		})
+	}
+	return rewrites, nil
 }
 
 type HostAddress struct {
@@ -316,8 +367,15 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		DisableFallback:        c.DisableFallback,
 		DisableFallbackIfMatch: c.DisableFallbackIfMatch,
 		QueryStrategy:          resolveQueryStrategy(c.QueryStrategy),
+		TrustAnchors:           c.TrustAnchors,
 	}
 
+	answerOrder, err := resolveAnswerOrder(c.AnswerOrder)
+	if err != nil {
+		return nil, err
+	}
+	config.AnswerOrder = answerOrder
+
 	if c.ClientIP != nil {
 		if !c.ClientIP.Family().IsIP() {
 			return nil, newError("not an IP address:", c.ClientIP.String())
@@ -341,9 +399,32 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		config.StaticHosts = append(config.StaticHosts, staticHosts...)
 	}
 
+	for _, rewrite := range c.Rewrites {
+		rewrites, err := rewrite.Build()
+		if err != nil {
+			return nil, newError("failed to build rewrites").Base(err)
+		}
+		config.Rewrites = append(config.Rewrites, rewrites...)
+	}
+
 	return config, nil
 }
 
+func resolveAnswerOrder(answerOrder string) (dns.AnswerOrder, error) {
+	switch strings.ToLower(answerOrder) {
+	case "":
+		return dns.AnswerOrder_DEFAULT_ORDER, nil
+	case "asreceived":
+		return dns.AnswerOrder_AS_RECEIVED, nil
+	case "shuffle":
+		return dns.AnswerOrder_SHUFFLE, nil
+	case "rttsorted":
+		return dns.AnswerOrder_RTT_SORTED, nil
+	default:
+		return dns.AnswerOrder_DEFAULT_ORDER, newError("unknown answer order: ", answerOrder)
+	}
+}
+
 func resolveQueryStrategy(queryStrategy string) dns.QueryStrategy {
 	switch strings.ToLower(queryStrategy) {
 	case "useip", "use_ip", "use-ip":
@@ -356,3 +437,14 @@ func resolveQueryStrategy(queryStrategy string) dns.QueryStrategy {
 		return dns.QueryStrategy_USE_IP
 	}
 }
+
+func resolveDNSSECMode(mode string) (dns.DnssecMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "ignore":
+		return dns.DnssecMode_IGNORE, nil
+	case "validate":
+		return dns.DnssecMode_VALIDATE, nil
+	default:
+		return dns.DnssecMode_IGNORE, newError("unknown DNSSEC mode: ", mode)
+	}
+}
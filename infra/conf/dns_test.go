@@ -145,5 +145,54 @@ func TestDNSConfigParsing(t *testing.T) {
 				DisableFallback: true,
 			},
 		},
+		{
+			Input: `{
+				"rewrites": [
+					{"match": "full:telemetry.vendor.com", "replace": "blackhole.local"},
+					{"match": "domain:internal.example.com", "replace": "internal.example.corp"}
+				]
+			}`,
+			Parser: parserCreator(),
+			Output: &dns.Config{
+				Rewrites: []*dns.Config_QueryRewrite{
+					{
+						Type:        dns.DomainMatchingType_Full,
+						Domain:      "telemetry.vendor.com",
+						Replacement: "blackhole.local",
+					},
+					{
+						Type:        dns.DomainMatchingType_Subdomain,
+						Domain:      "internal.example.com",
+						Replacement: "internal.example.corp",
+					},
+				},
+			},
+		},
+		{
+			Input: `{
+				"servers": [{
+					"address": "tcp://1.1.1.1",
+					"dnssec": "validate"
+				}],
+				"trustAnchors": [". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"]
+			}`,
+			Parser: parserCreator(),
+			Output: &dns.Config{
+				NameServer: []*dns.NameServer{
+					{
+						Address: &net.Endpoint{
+							Address: &net.IPOrDomain{
+								Address: &net.IPOrDomain_Domain{
+									Domain: "tcp://1.1.1.1",
+								},
+							},
+							Network: net.Network_UDP,
+						},
+						Dnssec: dns.DnssecMode_VALIDATE,
+					},
+				},
+				TrustAnchors: []string{". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"},
+			},
+		},
 	})
 }
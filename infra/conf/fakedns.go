@@ -9,8 +9,10 @@ import (
 )
 
 type FakeDNSPoolElementConfig struct {
-	IPPool  string `json:"ipPool"`
-	LRUSize int64  `json:"poolSize"`
+	IPPool      string `json:"ipPool"`
+	LRUSize     int64  `json:"poolSize"`
+	PersistPath string `json:"persistPath"`
+	TTL         uint32 `json:"ttl"`
 }
 
 type FakeDNSConfig struct {
@@ -38,15 +40,24 @@ func (f *FakeDNSConfig) Build() (*fakedns.FakeDnsPoolMulti, error) {
 
 	if f.pool != nil {
 		fakeDNSPool.Pools = append(fakeDNSPool.Pools, &fakedns.FakeDnsPool{
-			IpPool:  f.pool.IPPool,
-			LruSize: f.pool.LRUSize,
+			IpPool:      f.pool.IPPool,
+			LruSize:     f.pool.LRUSize,
+			PersistPath: f.pool.PersistPath,
+			Ttl:         f.pool.TTL,
 		})
 		return &fakeDNSPool, nil
 	}
 
 	if f.pools != nil {
+		persistPaths := make(map[string]bool)
 		for _, v := range f.pools {
-			fakeDNSPool.Pools = append(fakeDNSPool.Pools, &fakedns.FakeDnsPool{IpPool: v.IPPool, LruSize: v.LRUSize})
+			if v.PersistPath != "" {
+				if persistPaths[v.PersistPath] {
+					return nil, newError("fakedns pools can't share persistPath ", v.PersistPath)
+				}
+				persistPaths[v.PersistPath] = true
+			}
+			fakeDNSPool.Pools = append(fakeDNSPool.Pools, &fakedns.FakeDnsPool{IpPool: v.IPPool, LruSize: v.LRUSize, PersistPath: v.PersistPath, Ttl: v.TTL})
 		}
 		return &fakeDNSPool, nil
 	}
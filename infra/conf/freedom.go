@@ -18,6 +18,8 @@ type FreedomConfig struct {
 	UserLevel      uint32    `json:"userLevel"`
 	Fragment       *Fragment `json:"fragment"`
 	ProxyProtocol  uint32    `json:"proxyProtocol"`
+	AllowICMP      bool      `json:"allowICMP"`
+	FakeReply      string    `json:"fakeReply"`
 }
 
 type Fragment struct {
@@ -147,7 +149,16 @@ func (c *FreedomConfig) Build() (proto.Message, error) {
 		config.Timeout = *c.Timeout
 	}
 	config.UserLevel = c.UserLevel
-	if len(c.Redirect) > 0 {
+	if v2net.IsVsockAddress(c.Redirect) {
+		if _, err := v2net.ParseVsockAddress(c.Redirect); err != nil {
+			return nil, newError("invalid redirect address: ", c.Redirect).Base(err)
+		}
+		config.DestinationOverride = &freedom.DestinationOverride{
+			Server: &protocol.ServerEndpoint{
+				Address: v2net.NewIPOrDomain(v2net.DomainAddress(c.Redirect)),
+			},
+		}
+	} else if len(c.Redirect) > 0 {
 		host, portStr, err := net.SplitHostPort(c.Redirect)
 		if err != nil {
 			return nil, newError("invalid redirect address: ", c.Redirect, ": ", err).Base(err)
@@ -169,5 +180,17 @@ func (c *FreedomConfig) Build() (proto.Message, error) {
 	if c.ProxyProtocol > 0 && c.ProxyProtocol <= 2 {
 		config.ProxyProtocol = c.ProxyProtocol
 	}
+
+	config.AllowIcmp = c.AllowICMP
+	switch strings.ToLower(c.FakeReply) {
+	case "onfailure", "":
+		config.FakeReply = freedom.Config_FAKE_ON_FAILURE
+	case "never":
+		config.FakeReply = freedom.Config_FAKE_NEVER
+	case "always":
+		config.FakeReply = freedom.Config_FAKE_ALWAYS
+	default:
+		return nil, newError("unsupported fake reply mode: ", c.FakeReply)
+	}
 	return config, nil
 }
@@ -39,5 +39,33 @@ func TestFreedomConfig(t *testing.T) {
 				UserLevel: 1,
 			},
 		},
+		{
+			Input: `{
+				"redirect": "vsock:2:1234"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &freedom.Config{
+				DestinationOverride: &freedom.DestinationOverride{
+					Server: &protocol.ServerEndpoint{
+						Address: &net.IPOrDomain{
+							Address: &net.IPOrDomain_Domain{
+								Domain: "vsock:2:1234",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Input: `{
+				"allowICMP": true,
+				"fakeReply": "always"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &freedom.Config{
+				AllowIcmp: true,
+				FakeReply: freedom.Config_FAKE_ALWAYS,
+			},
+		},
 	})
 }
@@ -52,12 +52,14 @@ type HTTPRemoteConfig struct {
 }
 
 type HTTPClientConfig struct {
-	Servers []*HTTPRemoteConfig `json:"servers"`
-	Headers map[string]string   `json:"headers"`
+	Servers         []*HTTPRemoteConfig `json:"servers"`
+	Headers         map[string]string   `json:"headers"`
+	RetryIdempotent bool                `json:"retryIdempotent"`
 }
 
 func (v *HTTPClientConfig) Build() (proto.Message, error) {
 	config := new(http.ClientConfig)
+	config.RetryIdempotent = v.RetryIdempotent
 	config.Server = make([]*protocol.ServerEndpoint, len(v.Servers))
 	for idx, serverConfig := range v.Servers {
 		server := &protocol.ServerEndpoint{
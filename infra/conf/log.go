@@ -15,16 +15,41 @@ func DefaultLogConfig() *log.Config {
 	}
 }
 
+type LogRateLimitConfig struct {
+	Burst  uint32 `json:"burst"`
+	Window uint32 `json:"window"`
+}
+
+func (v *LogRateLimitConfig) Build() (*log.RateLimit, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if v.Burst == 0 || v.Window == 0 {
+		return nil, newError("log rate limit requires both burst and window")
+	}
+	return &log.RateLimit{
+		Burst:  v.Burst,
+		Window: v.Window,
+	}, nil
+}
+
+type LogRateLimitsConfig struct {
+	Error   *LogRateLimitConfig `json:"error"`
+	Warning *LogRateLimitConfig `json:"warning"`
+	Info    *LogRateLimitConfig `json:"info"`
+}
+
 type LogConfig struct {
-	AccessLog string `json:"access"`
-	ErrorLog  string `json:"error"`
-	LogLevel  string `json:"loglevel"`
-	DNSLog    bool   `json:"dnsLog"`
+	AccessLog string               `json:"access"`
+	ErrorLog  string               `json:"error"`
+	LogLevel  string               `json:"loglevel"`
+	DNSLog    bool                 `json:"dnsLog"`
+	RateLimit *LogRateLimitsConfig `json:"rateLimit"`
 }
 
-func (v *LogConfig) Build() *log.Config {
+func (v *LogConfig) Build() (*log.Config, error) {
 	if v == nil {
-		return nil
+		return nil, nil
 	}
 	config := &log.Config{
 		ErrorLogType:  log.LogType_Console,
@@ -59,5 +84,18 @@ func (v *LogConfig) Build() *log.Config {
 	default:
 		config.ErrorLogLevel = clog.Severity_Warning
 	}
-	return config
+
+	if v.RateLimit != nil {
+		var err error
+		if config.ErrorRateLimit, err = v.RateLimit.Error.Build(); err != nil {
+			return nil, err
+		}
+		if config.WarningRateLimit, err = v.RateLimit.Warning.Build(); err != nil {
+			return nil, err
+		}
+		if config.InfoRateLimit, err = v.RateLimit.Info.Build(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
 }
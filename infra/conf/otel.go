@@ -0,0 +1,29 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/app/otel"
+)
+
+type OTelConfig struct {
+	Endpoint           string            `json:"endpoint"`
+	SampleRatio        *float64          `json:"sampleRatio"`
+	ResourceAttributes map[string]string `json:"resourceAttributes"`
+}
+
+func (c *OTelConfig) Build() (*otel.Config, error) {
+	if c.Endpoint == "" {
+		return nil, newError("otel endpoint can't be empty.")
+	}
+	config := &otel.Config{
+		Endpoint:           c.Endpoint,
+		SampleRatio:        1,
+		ResourceAttributes: c.ResourceAttributes,
+	}
+	if c.SampleRatio != nil {
+		if *c.SampleRatio < 0 || *c.SampleRatio > 1 {
+			return nil, newError("otel sampleRatio must be between 0 and 1.")
+		}
+		config.SampleRatio = *c.SampleRatio
+	}
+	return config, nil
+}
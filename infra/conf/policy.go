@@ -1,6 +1,8 @@
 package conf
 
 import (
+	"strings"
+
 	"github.com/xtls/xray-core/app/policy"
 )
 
@@ -12,6 +14,8 @@ type Policy struct {
 	StatsUserUplink   bool    `json:"statsUserUplink"`
 	StatsUserDownlink bool    `json:"statsUserDownlink"`
 	BufferSize        *int32  `json:"bufferSize"`
+	LatencyPriority   string  `json:"latencyPriority"`
+	MaxLatency        *uint32 `json:"maxLatency"`
 }
 
 func (t *Policy) Build() (*policy.Policy, error) {
@@ -31,10 +35,14 @@ func (t *Policy) Build() (*policy.Policy, error) {
 
 	p := &policy.Policy{
 		Timeout: config,
-		Stats: &policy.Policy_Stats{
+	}
+
+	// Leave stats unset when disabled, so that inbound overrides don't turn them off.
+	if t.StatsUserUplink || t.StatsUserDownlink {
+		p.Stats = &policy.Policy_Stats{
 			UserUplink:   t.StatsUserUplink,
 			UserDownlink: t.StatsUserDownlink,
-		},
+		}
 	}
 
 	if t.BufferSize != nil {
@@ -47,6 +55,20 @@ func (t *Policy) Build() (*policy.Policy, error) {
 		}
 	}
 
+	if t.LatencyPriority != "" || t.MaxLatency != nil {
+		p.Latency = &policy.Policy_Latency{}
+		switch strings.ToLower(t.LatencyPriority) {
+		case "", "normal":
+		case "high":
+			p.Latency.HighPriority = true
+		default:
+			return nil, newError("unknown latency priority: ", t.LatencyPriority)
+		}
+		if t.MaxLatency != nil {
+			p.Latency.MaxDelay = *t.MaxLatency
+		}
+	}
+
 	return p, nil
 }
 
@@ -55,6 +77,9 @@ type SystemPolicy struct {
 	StatsInboundDownlink  bool `json:"statsInboundDownlink"`
 	StatsOutboundUplink   bool `json:"statsOutboundUplink"`
 	StatsOutboundDownlink bool `json:"statsOutboundDownlink"`
+
+	StatsInboundConnections  bool `json:"statsInboundConnections"`
+	StatsOutboundConnections bool `json:"statsOutboundConnections"`
 }
 
 func (p *SystemPolicy) Build() (*policy.SystemPolicy, error) {
@@ -64,13 +89,17 @@ func (p *SystemPolicy) Build() (*policy.SystemPolicy, error) {
 			InboundDownlink:  p.StatsInboundDownlink,
 			OutboundUplink:   p.StatsOutboundUplink,
 			OutboundDownlink: p.StatsOutboundDownlink,
+
+			InboundConnections:  p.StatsInboundConnections,
+			OutboundConnections: p.StatsOutboundConnections,
 		},
 	}, nil
 }
 
 type PolicyConfig struct {
-	Levels map[uint32]*Policy `json:"levels"`
-	System *SystemPolicy      `json:"system"`
+	Levels                 map[uint32]*Policy `json:"levels"`
+	System                 *SystemPolicy      `json:"system"`
+	InboundPolicyOverrides map[string]*Policy `json:"inboundPolicyOverrides"`
 }
 
 func (c *PolicyConfig) Build() (*policy.Config, error) {
@@ -98,3 +127,19 @@ func (c *PolicyConfig) Build() (*policy.Config, error) {
 
 	return config, nil
 }
+
+// BuildInboundOverrides builds the per-inbound policy overrides, which are applied by the inbound handler manager.
+func (c *PolicyConfig) BuildInboundOverrides() (map[string]*policy.Policy, error) {
+	overrides := make(map[string]*policy.Policy)
+	for tag, p := range c.InboundPolicyOverrides {
+		if p == nil {
+			continue
+		}
+		pp, err := p.Build()
+		if err != nil {
+			return nil, newError("invalid policy override for inbound ", tag).Base(err)
+		}
+		overrides[tag] = pp
+	}
+	return overrides, nil
+}
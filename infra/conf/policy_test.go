@@ -38,3 +38,23 @@ func TestBufferSize(t *testing.T) {
 		}
 	}
 }
+
+func TestLatencyPriority(t *testing.T) {
+	maxLatency := uint32(20)
+	pConf := Policy{
+		LatencyPriority: "high",
+		MaxLatency:      &maxLatency,
+	}
+	p, err := pConf.Build()
+	common.Must(err)
+	if !p.Latency.HighPriority || p.Latency.MaxDelay != 20 {
+		t.Error("unexpected latency policy: ", p.Latency)
+	}
+
+	pConf = Policy{
+		LatencyPriority: "urgent",
+	}
+	if _, err := pConf.Build(); err == nil {
+		t.Error("expected unknown latency priority to fail")
+	}
+}
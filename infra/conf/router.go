@@ -5,11 +5,13 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/platform/filesystem"
 	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/units"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -81,6 +83,7 @@ type RouterConfig struct {
 	Balancers      []*BalancingRule   `json:"balancers"`
 
 	DomainMatcher string `json:"domainMatcher"`
+	RuleStats     bool   `json:"ruleStats"`
 }
 
 func (c *RouterConfig) getDomainStrategy() router.Config_DomainStrategy {
@@ -106,6 +109,7 @@ func (c *RouterConfig) getDomainStrategy() router.Config_DomainStrategy {
 func (c *RouterConfig) Build() (*router.Config, error) {
 	config := new(router.Config)
 	config.DomainStrategy = c.getDomainStrategy()
+	config.RuleStats = c != nil && c.RuleStats
 
 	var rawRuleList []json.RawMessage
 	if c != nil {
@@ -145,6 +149,40 @@ type RouterRule struct {
 	BalancerTag string `json:"balancerTag"`
 
 	DomainMatcher string `json:"domainMatcher"`
+
+	Budget *RuleBudgetConfig `json:"budget"`
+}
+
+// RuleBudgetConfig is the budget of bytes of a routing rule.
+type RuleBudgetConfig struct {
+	Bytes       string `json:"bytes"`
+	Period      string `json:"period"`
+	OverflowTag string `json:"overflowTag"`
+}
+
+// Build implements Buildable.
+func (c *RuleBudgetConfig) Build() (*router.RuleBudget, error) {
+	var bytes units.ByteSize
+	if err := bytes.Parse(c.Bytes); err != nil {
+		return nil, newError("invalid budget bytes: ", c.Bytes).Base(err)
+	}
+	if len(c.OverflowTag) == 0 {
+		return nil, newError("budget without overflowTag")
+	}
+	budget := &router.RuleBudget{
+		Bytes:       uint64(bytes),
+		OverflowTag: c.OverflowTag,
+	}
+	switch strings.ToLower(c.Period) {
+	case "", "month":
+	default:
+		window, err := time.ParseDuration(c.Period)
+		if err != nil || window < time.Second {
+			return nil, newError("invalid budget period: ", c.Period, `, expect "month" or a duration of at least 1s`)
+		}
+		budget.Window = uint64(window / time.Second)
+	}
+	return budget, nil
 }
 
 func ParseIP(s string) (*router.CIDR, error) {
@@ -551,6 +589,7 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		SourcePort *PortList         `json:"sourcePort"`
 		User       *StringList       `json:"user"`
 		InboundTag *StringList       `json:"inboundTag"`
+		InboundIP  *StringList       `json:"inboundAddress"`
 		Protocols  *StringList       `json:"protocol"`
 		Attributes map[string]string `json:"attrs"`
 	}
@@ -639,6 +678,14 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		}
 	}
 
+	if rawFieldRule.InboundIP != nil {
+		geoipList, err := ToCidrList(*rawFieldRule.InboundIP)
+		if err != nil {
+			return nil, err
+		}
+		rule.InboundGeoip = geoipList
+	}
+
 	if rawFieldRule.Protocols != nil {
 		for _, s := range *rawFieldRule.Protocols {
 			rule.Protocol = append(rule.Protocol, s)
@@ -649,6 +696,14 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		rule.Attributes = rawFieldRule.Attributes
 	}
 
+	if rawFieldRule.Budget != nil {
+		budget, err := rawFieldRule.Budget.Build()
+		if err != nil {
+			return nil, err
+		}
+		rule.Budget = budget
+	}
+
 	return rule, nil
 }
 
@@ -352,5 +352,62 @@ func TestRouterConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			Input: `{
+				"rules": [
+					{
+						"type": "field",
+						"domain": ["youtube.com"],
+						"outboundTag": "metered",
+						"budget": {
+							"bytes": "50GB",
+							"overflowTag": "unmetered"
+						}
+					},
+					{
+						"type": "field",
+						"network": "udp",
+						"outboundTag": "metered",
+						"budget": {
+							"bytes": "1MB",
+							"period": "24h",
+							"overflowTag": "block"
+						}
+					}
+				]
+			}`,
+			Parser: createParser(),
+			Output: &router.Config{
+				DomainStrategy: router.Config_AsIs,
+				Rule: []*router.RoutingRule{
+					{
+						Domain: []*router.Domain{
+							{
+								Type:  router.Domain_Plain,
+								Value: "youtube.com",
+							},
+						},
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "metered",
+						},
+						Budget: &router.RuleBudget{
+							Bytes:       50 << 30,
+							OverflowTag: "unmetered",
+						},
+					},
+					{
+						Networks: []net.Network{net.Network_UDP},
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "metered",
+						},
+						Budget: &router.RuleBudget{
+							Bytes:       1 << 20,
+							Window:      86400,
+							OverflowTag: "block",
+						},
+					},
+				},
+			},
+		},
 	})
 }
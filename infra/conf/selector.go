@@ -0,0 +1,35 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/proxy/selector"
+	"google.golang.org/protobuf/proto"
+)
+
+type SelectorConfig struct {
+	Candidates []string `json:"candidates"`
+	Default    string   `json:"default"`
+	StateFile  string   `json:"stateFile"`
+}
+
+func (c *SelectorConfig) Build() (proto.Message, error) {
+	if len(c.Candidates) == 0 {
+		return nil, newError("no candidates in selector")
+	}
+	if len(c.Default) > 0 {
+		found := false
+		for _, candidate := range c.Candidates {
+			if candidate == c.Default {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, newError("selector default ", c.Default, " is not a candidate")
+		}
+	}
+	return &selector.Config{
+		Candidates: c.Candidates,
+		Default:    c.Default,
+		StateFile:  c.StateFile,
+	}, nil
+}
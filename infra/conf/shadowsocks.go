@@ -24,6 +24,8 @@ func cipherFromString(c string) shadowsocks.CipherType {
 		return shadowsocks.CipherType_XCHACHA20_POLY1305
 	case "none", "plain":
 		return shadowsocks.CipherType_NONE
+	case "auto":
+		return shadowsocks.CipherType_AUTO
 	default:
 		return shadowsocks.CipherType_UNKNOWN
 	}
@@ -36,6 +38,9 @@ type ShadowsocksUserConfig struct {
 	Email    string   `json:"email"`
 	Address  *Address `json:"address"`
 	Port     uint16   `json:"port"`
+
+	AllowedTargetPorts *PortList `json:"allowedTargetPorts"`
+	BlockedTargetPorts *PortList `json:"blockedTargetPorts"`
 }
 
 type ShadowsocksServerConfig struct {
@@ -46,6 +51,9 @@ type ShadowsocksServerConfig struct {
 	Users       []*ShadowsocksUserConfig `json:"clients"`
 	NetworkList *NetworkList             `json:"network"`
 	IVCheck     bool                     `json:"ivCheck"`
+
+	AllowedTargetPorts *PortList `json:"allowedTargetPorts"`
+	BlockedTargetPorts *PortList `json:"blockedTargetPorts"`
 }
 
 func (v *ShadowsocksServerConfig) Build() (proto.Message, error) {
@@ -55,6 +63,12 @@ func (v *ShadowsocksServerConfig) Build() (proto.Message, error) {
 
 	config := new(shadowsocks.ServerConfig)
 	config.Network = v.NetworkList.Build()
+	if v.AllowedTargetPorts != nil {
+		config.AllowedTargetPorts = v.AllowedTargetPorts.Build()
+	}
+	if v.BlockedTargetPorts != nil {
+		config.BlockedTargetPorts = v.BlockedTargetPorts.Build()
+	}
 
 	if v.Users != nil {
 		for _, user := range v.Users {
@@ -66,10 +80,17 @@ func (v *ShadowsocksServerConfig) Build() (proto.Message, error) {
 			if account.Password == "" {
 				return nil, newError("Shadowsocks password is not specified.")
 			}
-			if account.CipherType < shadowsocks.CipherType_AES_128_GCM ||
-				account.CipherType > shadowsocks.CipherType_XCHACHA20_POLY1305 {
+			if (account.CipherType < shadowsocks.CipherType_AES_128_GCM ||
+				account.CipherType > shadowsocks.CipherType_XCHACHA20_POLY1305) &&
+				account.CipherType != shadowsocks.CipherType_AUTO {
 				return nil, newError("unsupported cipher method: ", user.Cipher)
 			}
+			if user.AllowedTargetPorts != nil {
+				account.AllowedTargetPorts = user.AllowedTargetPorts.Build()
+			}
+			if user.BlockedTargetPorts != nil {
+				account.BlockedTargetPorts = user.BlockedTargetPorts.Build()
+			}
 			config.Users = append(config.Users, &protocol.User{
 				Email:   user.Email,
 				Level:   uint32(user.Level),
@@ -34,6 +34,8 @@ type SocksServerConfig struct {
 	Host       *Address        `json:"ip"`
 	Timeout    uint32          `json:"timeout"`
 	UserLevel  uint32          `json:"userLevel"`
+
+	Socks4UserIDAuth bool `json:"socks4UserIdAuth"`
 }
 
 func (v *SocksServerConfig) Build() (proto.Message, error) {
@@ -62,6 +64,7 @@ func (v *SocksServerConfig) Build() (proto.Message, error) {
 
 	config.Timeout = v.Timeout
 	config.UserLevel = v.UserLevel
+	config.Socks4UseridAuth = v.Socks4UserIDAuth
 	return config, nil
 }
 
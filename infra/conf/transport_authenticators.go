@@ -6,6 +6,7 @@ import (
 	"github.com/xtls/xray-core/transport/internet/headers/dns"
 	"github.com/xtls/xray-core/transport/internet/headers/http"
 	"github.com/xtls/xray-core/transport/internet/headers/noop"
+	"github.com/xtls/xray-core/transport/internet/headers/quic"
 	"github.com/xtls/xray-core/transport/internet/headers/srtp"
 	"github.com/xtls/xray-core/transport/internet/headers/tls"
 	"github.com/xtls/xray-core/transport/internet/headers/utp"
@@ -63,6 +64,12 @@ func (v *DNSAuthenticator) Build() (proto.Message, error) {
 	return config, nil
 }
 
+type QUICAuthenticator struct{}
+
+func (QUICAuthenticator) Build() (proto.Message, error) {
+	return new(quic.Config), nil
+}
+
 type DTLSAuthenticator struct{}
 
 func (DTLSAuthenticator) Build() (proto.Message, error) {
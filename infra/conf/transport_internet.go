@@ -21,6 +21,7 @@ import (
 	"github.com/xtls/xray-core/transport/internet/http"
 	"github.com/xtls/xray-core/transport/internet/httpupgrade"
 	"github.com/xtls/xray-core/transport/internet/kcp"
+	"github.com/xtls/xray-core/transport/internet/masque"
 	"github.com/xtls/xray-core/transport/internet/quic"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/tcp"
@@ -38,6 +39,7 @@ var (
 		"dtls":         func() interface{} { return new(DTLSAuthenticator) },
 		"wireguard":    func() interface{} { return new(WireguardAuthenticator) },
 		"dns":          func() interface{} { return new(DNSAuthenticator) },
+		"quic-like":    func() interface{} { return new(QUICAuthenticator) },
 	}, "type", "")
 
 	tcpHeaderLoader = NewJSONConfigLoader(ConfigCreatorCache{
@@ -56,6 +58,15 @@ type KCPConfig struct {
 	WriteBufferSize *uint32         `json:"writeBufferSize"`
 	HeaderConfig    json.RawMessage `json:"header"`
 	Seed            *string         `json:"seed"`
+	SeedRotation    uint32          `json:"seedRotation"`
+	SeedGrace       uint32          `json:"seedGrace"`
+	Fallback        *KCPFallback    `json:"fallback"`
+}
+
+type KCPFallback struct {
+	Address     *Address `json:"address"`
+	Port        uint16   `json:"port"`
+	IdleTimeout uint32   `json:"idleTimeout"`
 }
 
 // Build implements Buildable.
@@ -114,7 +125,27 @@ func (c *KCPConfig) Build() (proto.Message, error) {
 	}
 
 	if c.Seed != nil {
-		config.Seed = &kcp.EncryptionSeed{Seed: *c.Seed}
+		config.Seed = &kcp.EncryptionSeed{
+			Seed:     *c.Seed,
+			Rotation: c.SeedRotation,
+			Grace:    c.SeedGrace,
+		}
+		if c.SeedGrace > 0 && c.SeedGrace > c.SeedRotation/4 {
+			return nil, newError("mKCP seedGrace must not exceed a quarter of seedRotation").AtError()
+		}
+	} else if c.SeedRotation > 0 {
+		return nil, newError("mKCP seedRotation requires a seed").AtError()
+	}
+
+	if c.Fallback != nil {
+		if c.Fallback.Address == nil || c.Fallback.Port == 0 {
+			return nil, newError("mKCP fallback: address and port are required").AtError()
+		}
+		config.Fallback = &kcp.Fallback{
+			Address:     c.Fallback.Address.Build(),
+			Port:        uint32(c.Fallback.Port),
+			IdleTimeout: c.Fallback.IdleTimeout,
+		}
 	}
 
 	return config, nil
@@ -150,6 +181,8 @@ type WebSocketConfig struct {
 	Path                string            `json:"path"`
 	Headers             map[string]string `json:"headers"`
 	AcceptProxyProtocol bool              `json:"acceptProxyProtocol"`
+	Resume              bool              `json:"resume"`
+	ResumeWindowSeconds uint32            `json:"resumeWindowSeconds"`
 }
 
 // Build implements Buildable.
@@ -179,6 +212,8 @@ func (c *WebSocketConfig) Build() (proto.Message, error) {
 		Header:              c.Headers,
 		AcceptProxyProtocol: c.AcceptProxyProtocol,
 		Ed:                  ed,
+		Resume:              c.Resume,
+		ResumeWindow:        c.ResumeWindowSeconds,
 	}
 	return config, nil
 }
@@ -208,10 +243,10 @@ func (c *HttpUpgradeConfig) Build() (proto.Message, error) {
 	// Host priority: Host field > headers field > address.
 	if c.Host == "" && c.Headers["host"] != "" {
 		c.Host = c.Headers["host"]
-		delete(c.Headers,"host")
+		delete(c.Headers, "host")
 	} else if c.Host == "" && c.Headers["Host"] != "" {
 		c.Host = c.Headers["Host"]
-		delete(c.Headers,"Host")
+		delete(c.Headers, "Host")
 	}
 	config := &httpupgrade.Config{
 		Path:                path,
@@ -223,6 +258,27 @@ func (c *HttpUpgradeConfig) Build() (proto.Message, error) {
 	return config, nil
 }
 
+type MasqueConfig struct {
+	Address     string `json:"address"`
+	Port        uint32 `json:"port"`
+	URITemplate string `json:"uriTemplate"`
+}
+
+// Build implements Buildable.
+func (c *MasqueConfig) Build() (proto.Message, error) {
+	if c.Address == "" {
+		return nil, newError("MASQUE proxy address is not specified.")
+	}
+	if c.URITemplate != "" && (!strings.Contains(c.URITemplate, "{target_host}") || !strings.Contains(c.URITemplate, "{target_port}")) {
+		return nil, newError("MASQUE URI template must contain {target_host} and {target_port}: ", c.URITemplate)
+	}
+	return &masque.Config{
+		Address:     c.Address,
+		Port:        c.Port,
+		UriTemplate: c.URITemplate,
+	}, nil
+}
+
 type HTTPConfig struct {
 	Host               *StringList            `json:"host"`
 	Path               string                 `json:"path"`
@@ -399,6 +455,7 @@ type TLSConfig struct {
 	PinnedPeerCertificateChainSha256     *[]string        `json:"pinnedPeerCertificateChainSha256"`
 	PinnedPeerCertificatePublicKeySha256 *[]string        `json:"pinnedPeerCertificatePublicKeySha256"`
 	MasterKeyLog                         string           `json:"masterKeyLog"`
+	PostQuantum                          bool             `json:"postQuantum"`
 }
 
 // Build implements Buildable.
@@ -429,6 +486,10 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 	if config.Fingerprint != "" && tls.GetFingerprint(config.Fingerprint) == nil {
 		return nil, newError(`unknown fingerprint: `, config.Fingerprint)
 	}
+	config.PostQuantum = c.PostQuantum
+	if config.PostQuantum && strings.Contains(config.Fingerprint, "randomized") {
+		return nil, newError(`"postQuantum" can't be used with fingerprint: `, config.Fingerprint)
+	}
 	config.RejectUnknownSni = c.RejectUnknownSNI
 
 	if c.PinnedPeerCertificateChainSha256 != nil {
@@ -459,17 +520,19 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 }
 
 type REALITYConfig struct {
-	Show         bool            `json:"show"`
-	MasterKeyLog string          `json:"masterKeyLog"`
-	Dest         json.RawMessage `json:"dest"`
-	Type         string          `json:"type"`
-	Xver         uint64          `json:"xver"`
-	ServerNames  []string        `json:"serverNames"`
-	PrivateKey   string          `json:"privateKey"`
-	MinClientVer string          `json:"minClientVer"`
-	MaxClientVer string          `json:"maxClientVer"`
-	MaxTimeDiff  uint64          `json:"maxTimeDiff"`
-	ShortIds     []string        `json:"shortIds"`
+	Show           bool            `json:"show"`
+	MasterKeyLog   string          `json:"masterKeyLog"`
+	Dest           json.RawMessage `json:"dest"`
+	Type           string          `json:"type"`
+	Xver           uint64          `json:"xver"`
+	ServerNames    []string        `json:"serverNames"`
+	PrivateKey     string          `json:"privateKey"`
+	MinClientVer   string          `json:"minClientVer"`
+	MaxClientVer   string          `json:"maxClientVer"`
+	MaxTimeDiff    uint64          `json:"maxTimeDiff"`
+	ShortIds       []string        `json:"shortIds"`
+	MimicTiming    bool            `json:"mimicTiming"`
+	MaxTimingDelay uint64          `json:"maxTimingDelay"`
 
 	Fingerprint string `json:"fingerprint"`
 	ServerName  string `json:"serverName"`
@@ -567,6 +630,8 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 		config.Xver = c.Xver
 		config.ServerNames = c.ServerNames
 		config.MaxTimeDiff = c.MaxTimeDiff
+		config.MimicTiming = c.MimicTiming
+		config.MaxTimingDelay = c.MaxTimingDelay
 	} else {
 		if c.Fingerprint == "" {
 			return nil, newError(`empty "fingerprint"`)
@@ -648,6 +713,8 @@ func (p TransportProtocol) Build() (string, error) {
 		return "grpc", nil
 	case "httpupgrade":
 		return "httpupgrade", nil
+	case "masque":
+		return "masque", nil
 	default:
 		return "", newError("Config: unknown transport protocol: ", p)
 	}
@@ -662,6 +729,7 @@ type SocketConfig struct {
 	DialerProxy          string      `json:"dialerProxy"`
 	TCPKeepAliveInterval int32       `json:"tcpKeepAliveInterval"`
 	TCPKeepAliveIdle     int32       `json:"tcpKeepAliveIdle"`
+	TCPKeepAliveCount    int32       `json:"tcpKeepAliveCount"`
 	TCPCongestion        string      `json:"tcpCongestion"`
 	TCPWindowClamp       int32       `json:"tcpWindowClamp"`
 	TCPMaxSeg            int32       `json:"tcpMaxSeg"`
@@ -670,6 +738,7 @@ type SocketConfig struct {
 	V6only               bool        `json:"v6only"`
 	Interface            string      `json:"interface"`
 	TcpMptcp             bool        `json:"tcpMptcp"`
+	IdleClose            uint32      `json:"idleClose"`
 }
 
 // Build implements Buildable.
@@ -736,6 +805,7 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		DialerProxy:          c.DialerProxy,
 		TcpKeepAliveInterval: c.TCPKeepAliveInterval,
 		TcpKeepAliveIdle:     c.TCPKeepAliveIdle,
+		TcpKeepAliveCount:    c.TCPKeepAliveCount,
 		TcpCongestion:        c.TCPCongestion,
 		TcpWindowClamp:       c.TCPWindowClamp,
 		TcpMaxSeg:            c.TCPMaxSeg,
@@ -744,6 +814,7 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		V6Only:               c.V6only,
 		Interface:            c.Interface,
 		TcpMptcp:             c.TcpMptcp,
+		IdleClose:            c.IdleClose,
 	}, nil
 }
 
@@ -762,6 +833,8 @@ type StreamConfig struct {
 	GRPCConfig          *GRPCConfig         `json:"grpcSettings"`
 	GUNConfig           *GRPCConfig         `json:"gunSettings"`
 	HTTPUPGRADESettings *HttpUpgradeConfig  `json:"httpupgradeSettings"`
+	MasqueSettings      *MasqueConfig       `json:"masqueSettings"`
+	DialRetries         uint32              `json:"dialRetries"`
 }
 
 // Build implements Buildable.
@@ -892,6 +965,16 @@ func (c *StreamConfig) Build() (*internet.StreamConfig, error) {
 			Settings:     serial.ToTypedMessage(hs),
 		})
 	}
+	if c.MasqueSettings != nil {
+		ms, err := c.MasqueSettings.Build()
+		if err != nil {
+			return nil, newError("Failed to build MASQUE config.").Base(err)
+		}
+		config.TransportSettings = append(config.TransportSettings, &internet.TransportConfig{
+			ProtocolName: "masque",
+			Settings:     serial.ToTypedMessage(ms),
+		})
+	}
 	if c.SocketSettings != nil {
 		ss, err := c.SocketSettings.Build()
 		if err != nil {
@@ -899,6 +982,7 @@ func (c *StreamConfig) Build() (*internet.StreamConfig, error) {
 		}
 		config.SocketSettings = ss
 	}
+	config.DialRetries = c.DialRetries
 	return config, nil
 }
 
@@ -27,7 +27,8 @@ type TrojanServerTarget struct {
 
 // TrojanClientConfig is configuration of trojan servers
 type TrojanClientConfig struct {
-	Servers []*TrojanServerTarget `json:"servers"`
+	Servers      []*TrojanServerTarget `json:"servers"`
+	InterceptDNS string                `json:"interceptDns"`
 }
 
 // Build implements Buildable
@@ -40,6 +41,13 @@ func (c *TrojanClientConfig) Build() (proto.Message, error) {
 		Server: make([]*protocol.ServerEndpoint, len(c.Servers)),
 	}
 
+	switch c.InterceptDNS {
+	case "", "tunnel", "local", "skip":
+	default:
+		return nil, newError(`Trojan settings: unknown "interceptDns": `, c.InterceptDNS)
+	}
+	config.InterceptDns = c.InterceptDNS
+
 	for idx, rec := range c.Servers {
 		if rec.Address == nil {
 			return nil, newError("Trojan server address is not set.")
@@ -88,6 +96,11 @@ type TrojanUserConfig struct {
 	Level    byte   `json:"level"`
 	Email    string `json:"email"`
 	Flow     string `json:"flow"`
+
+	RejectQUICUDP443 bool `json:"rejectQuicUdp443"`
+
+	AllowedTargetPorts *PortList `json:"allowedTargetPorts"`
+	BlockedTargetPorts *PortList `json:"blockedTargetPorts"`
 }
 
 // TrojanServerConfig is Inbound configuration
@@ -95,6 +108,9 @@ type TrojanServerConfig struct {
 	Clients   []*TrojanUserConfig      `json:"clients"`
 	Fallback  *TrojanInboundFallback   `json:"fallback"`
 	Fallbacks []*TrojanInboundFallback `json:"fallbacks"`
+
+	AllowedTargetPorts *PortList `json:"allowedTargetPorts"`
+	BlockedTargetPorts *PortList `json:"blockedTargetPorts"`
 }
 
 // Build implements Buildable
@@ -108,15 +124,31 @@ func (c *TrojanServerConfig) Build() (proto.Message, error) {
 			return nil, newError(`Trojan doesn't support "flow" anymore.`)
 		}
 
+		account := &trojan.Account{
+			Password:         rawUser.Password,
+			RejectQuicUdp443: rawUser.RejectQUICUDP443,
+		}
+		if rawUser.AllowedTargetPorts != nil {
+			account.AllowedTargetPorts = rawUser.AllowedTargetPorts.Build()
+		}
+		if rawUser.BlockedTargetPorts != nil {
+			account.BlockedTargetPorts = rawUser.BlockedTargetPorts.Build()
+		}
+
 		config.Users[idx] = &protocol.User{
-			Level: uint32(rawUser.Level),
-			Email: rawUser.Email,
-			Account: serial.ToTypedMessage(&trojan.Account{
-				Password: rawUser.Password,
-			}),
+			Level:   uint32(rawUser.Level),
+			Email:   rawUser.Email,
+			Account: serial.ToTypedMessage(account),
 		}
 	}
 
+	if c.AllowedTargetPorts != nil {
+		config.AllowedTargetPorts = c.AllowedTargetPorts.Build()
+	}
+	if c.BlockedTargetPorts != nil {
+		config.BlockedTargetPorts = c.BlockedTargetPorts.Build()
+	}
+
 	if c.Fallback != nil {
 		return nil, newError(`Trojan settings: please use "fallbacks":[{}] instead of "fallback":{}`)
 	}
@@ -0,0 +1,30 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/proxy/tun"
+	"google.golang.org/protobuf/proto"
+)
+
+type TunConfig struct {
+	Name        string   `json:"name"`
+	Fd          int32    `json:"fd"`
+	MTU         uint32   `json:"mtu"`
+	Address     []string `json:"address"`
+	ExcludeMark uint32   `json:"excludeMark"`
+}
+
+func (c *TunConfig) Build() (proto.Message, error) {
+	if len(c.Address) == 0 {
+		return nil, newError("TUN device requires at least one address")
+	}
+	if c.Fd < 0 {
+		return nil, newError("invalid fd: ", c.Fd)
+	}
+	return &tun.Config{
+		Name:        c.Name,
+		Fd:          c.Fd,
+		Mtu:         c.MTU,
+		Address:     c.Address,
+		ExcludeMark: c.ExcludeMark,
+	}, nil
+}
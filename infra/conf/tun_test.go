@@ -0,0 +1,43 @@
+package conf_test
+
+import (
+	"testing"
+
+	. "github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/tun"
+)
+
+func TestTunConfig(t *testing.T) {
+	creator := func() Buildable {
+		return new(TunConfig)
+	}
+
+	runMultiTestCase(t, []TestCase{
+		{
+			Input: `{
+				"name": "xray1",
+				"mtu": 9000,
+				"address": ["172.19.0.1/30", "fdfe:dcba:9876::1/126"],
+				"excludeMark": 255
+			}`,
+			Parser: loadJSON(creator),
+			Output: &tun.Config{
+				Name:        "xray1",
+				Mtu:         9000,
+				Address:     []string{"172.19.0.1/30", "fdfe:dcba:9876::1/126"},
+				ExcludeMark: 255,
+			},
+		},
+		{
+			Input: `{
+				"fd": 7,
+				"address": ["172.19.0.1/30"]
+			}`,
+			Parser: loadJSON(creator),
+			Output: &tun.Config{
+				Fd:      7,
+				Address: []string{"172.19.0.1/30"},
+			},
+		},
+	})
+}
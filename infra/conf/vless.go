@@ -11,7 +11,6 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
-	"github.com/xtls/xray-core/common/uuid"
 	"github.com/xtls/xray-core/proxy/vless"
 	"github.com/xtls/xray-core/proxy/vless/inbound"
 	"github.com/xtls/xray-core/proxy/vless/outbound"
@@ -32,12 +31,31 @@ type VLessInboundConfig struct {
 	Decryption string                  `json:"decryption"`
 	Fallback   *VLessInboundFallback   `json:"fallback"`
 	Fallbacks  []*VLessInboundFallback `json:"fallbacks"`
+
+	AllowedTargetPorts *PortList `json:"allowedTargetPorts"`
+	BlockedTargetPorts *PortList `json:"blockedTargetPorts"`
+}
+
+// vLessClientOptions are the options of an inbound client not carried by the
+// JSON form of vless.Account.
+type vLessClientOptions struct {
+	RejectQUICUDP443 bool `json:"rejectQuicUdp443"`
+
+	AllowedTargetPorts *PortList `json:"allowedTargetPorts"`
+	BlockedTargetPorts *PortList `json:"blockedTargetPorts"`
+}
+
+// vLessUserOptions are the options of an outbound user not carried by the
+// JSON form of vless.Account.
+type vLessUserOptions struct {
+	PacketAddress bool `json:"packetAddress"`
 }
 
 // Build implements Buildable
 func (c *VLessInboundConfig) Build() (proto.Message, error) {
 	config := new(inbound.Config)
 	config.Clients = make([]*protocol.User, len(c.Clients))
+	ids := make(accountIDs)
 	for idx, rawUser := range c.Clients {
 		user := new(protocol.User)
 		if err := json.Unmarshal(rawUser, user); err != nil {
@@ -48,11 +66,11 @@ func (c *VLessInboundConfig) Build() (proto.Message, error) {
 			return nil, newError(`VLESS clients: invalid user`).Base(err)
 		}
 
-		u, err := uuid.ParseString(account.Id)
+		id, err := ids.parse("VLESS clients", account.Id)
 		if err != nil {
 			return nil, err
 		}
-		account.Id = u.String()
+		account.Id = id
 
 		switch account.Flow {
 		case "", vless.XRV:
@@ -64,6 +82,18 @@ func (c *VLessInboundConfig) Build() (proto.Message, error) {
 			return nil, newError(`VLESS clients: "encryption" should not in inbound settings`)
 		}
 
+		options := new(vLessClientOptions)
+		if err := json.Unmarshal(rawUser, options); err != nil {
+			return nil, newError(`VLESS clients: invalid user`).Base(err)
+		}
+		account.RejectQuicUdp443 = options.RejectQUICUDP443
+		if options.AllowedTargetPorts != nil {
+			account.AllowedTargetPorts = options.AllowedTargetPorts.Build()
+		}
+		if options.BlockedTargetPorts != nil {
+			account.BlockedTargetPorts = options.BlockedTargetPorts.Build()
+		}
+
 		user.Account = serial.ToTypedMessage(account)
 		config.Clients[idx] = user
 	}
@@ -73,6 +103,13 @@ func (c *VLessInboundConfig) Build() (proto.Message, error) {
 	}
 	config.Decryption = c.Decryption
 
+	if c.AllowedTargetPorts != nil {
+		config.AllowedTargetPorts = c.AllowedTargetPorts.Build()
+	}
+	if c.BlockedTargetPorts != nil {
+		config.BlockedTargetPorts = c.BlockedTargetPorts.Build()
+	}
+
 	if c.Fallback != nil {
 		return nil, newError(`VLESS settings: please use "fallbacks":[{}] instead of "fallback":{}`)
 	}
@@ -139,13 +176,35 @@ type VLessOutboundVnext struct {
 }
 
 type VLessOutboundConfig struct {
-	Vnext []*VLessOutboundVnext `json:"vnext"`
+	Vnext                []*VLessOutboundVnext `json:"vnext"`
+	InterceptDNS         string                `json:"interceptDns"`
+	VisionMode           string                `json:"visionMode"`
+	VisionPlainThreshold uint32                `json:"visionPlainThreshold"`
 }
 
 // Build implements Buildable
 func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 	config := new(outbound.Config)
 
+	switch c.InterceptDNS {
+	case "", "tunnel", "local", "skip":
+	default:
+		return nil, newError(`VLESS settings: unknown "interceptDns": `, c.InterceptDNS)
+	}
+	config.InterceptDns = c.InterceptDNS
+
+	switch strings.ToLower(c.VisionMode) {
+	case "", "auto":
+		config.VisionMode = outbound.VisionMode_AUTO
+	case "tls":
+		config.VisionMode = outbound.VisionMode_TLS
+	case "plain":
+		config.VisionMode = outbound.VisionMode_PLAIN
+	default:
+		return nil, newError(`VLESS settings: unknown "visionMode": `, c.VisionMode)
+	}
+	config.VisionPlainThreshold = c.VisionPlainThreshold
+
 	if len(c.Vnext) == 0 {
 		return nil, newError(`VLESS settings: "vnext" is empty`)
 	}
@@ -162,6 +221,7 @@ func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 			Port:    uint32(rec.Port),
 			User:    make([]*protocol.User, len(rec.Users)),
 		}
+		ids := make(accountIDs)
 		for idx, rawUser := range rec.Users {
 			user := new(protocol.User)
 			if err := json.Unmarshal(rawUser, user); err != nil {
@@ -172,11 +232,11 @@ func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 				return nil, newError(`VLESS users: invalid user`).Base(err)
 			}
 
-			u, err := uuid.ParseString(account.Id)
+			id, err := ids.parse("VLESS users", account.Id)
 			if err != nil {
 				return nil, err
 			}
-			account.Id = u.String()
+			account.Id = id
 
 			switch account.Flow {
 			case "", vless.XRV, vless.XRV + "-udp443":
@@ -188,6 +248,12 @@ func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 				return nil, newError(`VLESS users: please add/set "encryption":"none" for every user`)
 			}
 
+			options := new(vLessUserOptions)
+			if err := json.Unmarshal(rawUser, options); err != nil {
+				return nil, newError(`VLESS users: invalid user`).Base(err)
+			}
+			account.PacketAddress = options.PacketAddress
+
 			user.Account = serial.ToTypedMessage(account)
 			spec.User[idx] = user
 		}
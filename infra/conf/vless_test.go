@@ -1,8 +1,10 @@
 package conf_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
@@ -57,6 +59,86 @@ func TestVLessOutbound(t *testing.T) {
 				},
 			},
 		},
+		{
+			Input: `{
+				"vnext": [{
+					"address": "example.com",
+					"port": 443,
+					"users": [
+						{
+							"id": "27848739-7e62-4138-9fd3-098a63964b6b",
+							"encryption": "none",
+							"packetAddress": true
+						}
+					]
+				}],
+				"interceptDns": "local"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &outbound.Config{
+				Vnext: []*protocol.ServerEndpoint{
+					{
+						Address: &net.IPOrDomain{
+							Address: &net.IPOrDomain_Domain{
+								Domain: "example.com",
+							},
+						},
+						Port: 443,
+						User: []*protocol.User{
+							{
+								Account: serial.ToTypedMessage(&vless.Account{
+									Id:            "27848739-7e62-4138-9fd3-098a63964b6b",
+									Encryption:    "none",
+									PacketAddress: true,
+								}),
+							},
+						},
+					},
+				},
+				InterceptDns: "local",
+			},
+		},
+		{
+			Input: `{
+				"vnext": [{
+					"address": "example.com",
+					"port": 443,
+					"users": [
+						{
+							"id": "27848739-7e62-4138-9fd3-098a63964b6b",
+							"flow": "xtls-rprx-vision",
+							"encryption": "none"
+						}
+					]
+				}],
+				"visionMode": "tls",
+				"visionPlainThreshold": 4096
+			}`,
+			Parser: loadJSON(creator),
+			Output: &outbound.Config{
+				Vnext: []*protocol.ServerEndpoint{
+					{
+						Address: &net.IPOrDomain{
+							Address: &net.IPOrDomain_Domain{
+								Domain: "example.com",
+							},
+						},
+						Port: 443,
+						User: []*protocol.User{
+							{
+								Account: serial.ToTypedMessage(&vless.Account{
+									Id:         "27848739-7e62-4138-9fd3-098a63964b6b",
+									Flow:       "xtls-rprx-vision",
+									Encryption: "none",
+								}),
+							},
+						},
+					},
+				},
+				VisionMode:           outbound.VisionMode_TLS,
+				VisionPlainThreshold: 4096,
+			},
+		},
 	})
 }
 
@@ -130,5 +212,87 @@ func TestVLessInbound(t *testing.T) {
 				},
 			},
 		},
+		{
+			Input: `{
+				"clients": [
+					{
+						"id": "27848739-7e62-4138-9fd3-098a63964b6b",
+						"email": "love@example.com",
+						"rejectQuicUdp443": true
+					}
+				],
+				"decryption": "none"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &inbound.Config{
+				Clients: []*protocol.User{
+					{
+						Account: serial.ToTypedMessage(&vless.Account{
+							Id:               "27848739-7e62-4138-9fd3-098a63964b6b",
+							RejectQuicUdp443: true,
+						}),
+						Email: "love@example.com",
+					},
+				},
+				Decryption: "none",
+			},
+		},
 	})
 }
+
+func TestVLessMappedID(t *testing.T) {
+	const derived = "fead3400-4993-5684-a711-41af93aaa5e9"
+
+	inboundConfig, err := loadJSON(func() Buildable {
+		return new(VLessInboundConfig)
+	})(`{
+		"clients": [
+			{
+				"id": "alice-phone-2024"
+			},
+			{
+				"id": "27848739-7e62-4138-9fd3-098a63964b6b"
+			}
+		],
+		"decryption": "none"
+	}`)
+	common.Must(err)
+	outboundConfig, err := loadJSON(func() Buildable {
+		return new(VLessOutboundConfig)
+	})(`{
+		"vnext": [{
+			"address": "example.com",
+			"port": 443,
+			"users": [
+				{
+					"id": "alice-phone-2024",
+					"encryption": "none"
+				}
+			]
+		}]
+	}`)
+	common.Must(err)
+
+	serverAccount, err := inboundConfig.(*inbound.Config).Clients[0].Account.GetInstance()
+	common.Must(err)
+	clientAccount, err := outboundConfig.(*outbound.Config).Vnext[0].User[0].Account.GetInstance()
+	common.Must(err)
+	if id := serverAccount.(*vless.Account).Id; id != derived {
+		t.Error("server id: ", id)
+	}
+	if id := clientAccount.(*vless.Account).Id; id != derived {
+		t.Error("client id: ", id)
+	}
+
+	for _, clients := range []string{
+		`[{"id": "alice-phone-2024"}, {"id": "` + derived + `"}]`,
+		`[{"id": "` + strings.ToUpper(derived) + `"}, {"id": "alice-phone-2024"}]`,
+	} {
+		_, err := loadJSON(func() Buildable {
+			return new(VLessInboundConfig)
+		})(`{"clients": ` + clients + `, "decryption": "none"}`)
+		if err == nil {
+			t.Error("collision not detected: ", clients)
+		}
+	}
+}
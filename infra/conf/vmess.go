@@ -6,7 +6,6 @@ import (
 
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
-	"github.com/xtls/xray-core/common/uuid"
 	"github.com/xtls/xray-core/proxy/vmess"
 	"github.com/xtls/xray-core/proxy/vmess/inbound"
 	"github.com/xtls/xray-core/proxy/vmess/outbound"
@@ -93,6 +92,7 @@ func (c *VMessInboundConfig) Build() (proto.Message, error) {
 	}
 
 	config.User = make([]*protocol.User, len(c.Users))
+	ids := make(accountIDs)
 	for idx, rawData := range c.Users {
 		user := new(protocol.User)
 		if err := json.Unmarshal(rawData, user); err != nil {
@@ -103,11 +103,11 @@ func (c *VMessInboundConfig) Build() (proto.Message, error) {
 			return nil, newError("invalid VMess user").Base(err)
 		}
 
-		u, err := uuid.ParseString(account.ID)
+		id, err := ids.parse("VMess users", account.ID)
 		if err != nil {
 			return nil, err
 		}
-		account.ID = u.String()
+		account.ID = id
 
 		user.Account = serial.ToTypedMessage(account.Build())
 		config.User[idx] = user
@@ -123,13 +123,21 @@ type VMessOutboundTarget struct {
 }
 
 type VMessOutboundConfig struct {
-	Receivers []*VMessOutboundTarget `json:"vnext"`
+	Receivers    []*VMessOutboundTarget `json:"vnext"`
+	InterceptDNS string                 `json:"interceptDns"`
 }
 
 // Build implements Buildable
 func (c *VMessOutboundConfig) Build() (proto.Message, error) {
 	config := new(outbound.Config)
 
+	switch c.InterceptDNS {
+	case "", "tunnel", "local", "skip":
+	default:
+		return nil, newError(`VMess settings: unknown "interceptDns": `, c.InterceptDNS)
+	}
+	config.InterceptDns = c.InterceptDNS
+
 	if len(c.Receivers) == 0 {
 		return nil, newError("0 VMess receiver configured")
 	}
@@ -145,6 +153,7 @@ func (c *VMessOutboundConfig) Build() (proto.Message, error) {
 			Address: rec.Address.Build(),
 			Port:    uint32(rec.Port),
 		}
+		ids := make(accountIDs)
 		for _, rawUser := range rec.Users {
 			user := new(protocol.User)
 			if err := json.Unmarshal(rawUser, user); err != nil {
@@ -155,11 +164,11 @@ func (c *VMessOutboundConfig) Build() (proto.Message, error) {
 				return nil, newError("invalid VMess user").Base(err)
 			}
 
-			u, err := uuid.ParseString(account.ID)
+			id, err := ids.parse("VMess users", account.ID)
 			if err != nil {
 				return nil, err
 			}
-			account.ID = u.String()
+			account.ID = id
 
 			user.Account = serial.ToTypedMessage(account.Build())
 			spec.User = append(spec.User, user)
@@ -58,6 +58,18 @@ type WireGuardConfig struct {
 	NumWorkers     int32                  `json:"workers"`
 	Reserved       []byte                 `json:"reserved"`
 	DomainStrategy string                 `json:"domainStrategy"`
+	UserLevel      uint32                 `json:"userLevel"`
+	ForwardPing    bool                   `json:"forwardPing"`
+
+	OnDemand            bool   `json:"onDemand"`
+	IdleShutdownSeconds uint32 `json:"idleShutdownSeconds"`
+
+	HandshakeTimeout uint32 `json:"handshakeTimeout"`
+	ClampMSS         uint32 `json:"clampMss"`
+
+	Obfuscation *WireGuardObfuscationConfig `json:"obfuscation"`
+
+	EndpointDomainStrategy string `json:"endpointDomainStrategy"`
 }
 
 func (c *WireGuardConfig) Build() (proto.Message, error) {
@@ -116,7 +128,46 @@ func (c *WireGuardConfig) Build() (proto.Message, error) {
 		return nil, newError("unsupported domain strategy: ", c.DomainStrategy)
 	}
 
+	switch strings.ToLower(c.EndpointDomainStrategy) {
+	case "":
+		config.EndpointDomainStrategy = wireguard.DeviceConfig_ENDPOINT_AS_TUNNEL
+	case "forceip":
+		config.EndpointDomainStrategy = wireguard.DeviceConfig_ENDPOINT_FORCE_IP
+	case "forceipv4":
+		config.EndpointDomainStrategy = wireguard.DeviceConfig_ENDPOINT_FORCE_IP4
+	case "forceipv6":
+		config.EndpointDomainStrategy = wireguard.DeviceConfig_ENDPOINT_FORCE_IP6
+	case "forceipv4v6":
+		config.EndpointDomainStrategy = wireguard.DeviceConfig_ENDPOINT_FORCE_IP46
+	case "forceipv6v4":
+		config.EndpointDomainStrategy = wireguard.DeviceConfig_ENDPOINT_FORCE_IP64
+	default:
+		return nil, newError("unsupported endpoint domain strategy: ", c.EndpointDomainStrategy)
+	}
+
 	config.IsClient = c.IsClient
+	config.UserLevel = c.UserLevel
+	config.ForwardPing = c.ForwardPing
+	if c.OnDemand {
+		config.OnDemand = true
+		config.IdleShutdown = c.IdleShutdownSeconds
+		if config.IdleShutdown == 0 {
+			config.IdleShutdown = 300
+		}
+	}
+	config.HandshakeTimeout = c.HandshakeTimeout
+	if c.ClampMSS != 0 && (c.ClampMSS < 536 || c.ClampMSS > 65535) {
+		return nil, newError(`"clampMss" should be between 536 and 65535`)
+	}
+	config.ClampMss = c.ClampMSS
+	if c.Obfuscation != nil {
+		if config.Obfuscation, err = c.Obfuscation.Build(); err != nil {
+			return nil, err
+		}
+		if config.Obfuscation.InitHeader != 0 && len(config.Reserved) != 0 {
+			return nil, newError(`"reserved" can't be used with the headers of "obfuscation"`)
+		}
+	}
 	if c.KernelMode != nil {
 		config.KernelMode = *c.KernelMode
 		if config.KernelMode && !wireguard.KernelTunSupported() {
@@ -132,6 +183,62 @@ func (c *WireGuardConfig) Build() (proto.Message, error) {
 	return config, nil
 }
 
+// WireGuardObfuscationConfig takes the parameters of AmneziaWG by their names there.
+type WireGuardObfuscationConfig struct {
+	JunkCount        uint32 `json:"jc"`
+	JunkMinSize      uint32 `json:"jmin"`
+	JunkMaxSize      uint32 `json:"jmax"`
+	InitJunkSize     uint32 `json:"s1"`
+	ResponseJunkSize uint32 `json:"s2"`
+	InitHeader       uint32 `json:"h1"`
+	ResponseHeader   uint32 `json:"h2"`
+	CookieHeader     uint32 `json:"h3"`
+	TransportHeader  uint32 `json:"h4"`
+	MaskSecret       string `json:"maskSecret"`
+}
+
+func (c *WireGuardObfuscationConfig) Build() (*wireguard.Obfuscation, error) {
+	if c.JunkCount > 128 {
+		return nil, newError(`"jc" should be at most 128`)
+	}
+	if c.JunkCount != 0 && (c.JunkMaxSize == 0 || c.JunkMinSize > c.JunkMaxSize || c.JunkMaxSize > 1280) {
+		return nil, newError(`"jmin" and "jmax" should be a range of sizes up to 1280`)
+	}
+	// the handshake messages must keep fitting in the MTU, and can't be told apart if of the same size
+	if c.InitJunkSize > 1132 || c.ResponseJunkSize > 1188 {
+		return nil, newError(`"s1" should be at most 1132, and "s2" at most 1188`)
+	}
+	if c.InitJunkSize+56 == c.ResponseJunkSize {
+		return nil, newError(`"s1" plus 56 should not be "s2"`)
+	}
+	headers := []uint32{c.InitHeader, c.ResponseHeader, c.CookieHeader, c.TransportHeader}
+	if c.InitHeader != 0 || c.ResponseHeader != 0 || c.CookieHeader != 0 || c.TransportHeader != 0 {
+		for i, h := range headers {
+			if h == 0 {
+				return nil, newError(`"h1" to "h4" should be all set or none`)
+			}
+			for _, other := range headers[:i] {
+				if h == other {
+					return nil, newError(`"h1" to "h4" should be different`)
+				}
+			}
+		}
+	}
+
+	return &wireguard.Obfuscation{
+		JunkCount:        c.JunkCount,
+		JunkMinSize:      c.JunkMinSize,
+		JunkMaxSize:      c.JunkMaxSize,
+		InitJunkSize:     c.InitJunkSize,
+		ResponseJunkSize: c.ResponseJunkSize,
+		InitHeader:       c.InitHeader,
+		ResponseHeader:   c.ResponseHeader,
+		CookieHeader:     c.CookieHeader,
+		TransportHeader:  c.TransportHeader,
+		MaskSecret:       c.MaskSecret,
+	}, nil
+}
+
 func ParseWireGuardKey(str string) (string, error) {
 	var err error
 
@@ -26,7 +26,10 @@ func TestWireGuardConfig(t *testing.T) {
 				"mtu": 1300,
 				"workers": 2,
 				"domainStrategy": "ForceIPv6v4",
-				"kernelMode": false
+				"endpointDomainStrategy": "ForceIPv4",
+				"kernelMode": false,
+				"userLevel": 2,
+				"forwardPing": true
 			}`,
 			Parser: loadJSON(creator),
 			Output: &wireguard.DeviceConfig{
@@ -42,10 +45,79 @@ func TestWireGuardConfig(t *testing.T) {
 						AllowedIps: []string{"0.0.0.0/0", "::0/0"},
 					},
 				},
-				Mtu:            1300,
-				NumWorkers:     2,
-				DomainStrategy: wireguard.DeviceConfig_FORCE_IP64,
-				KernelMode:     false,
+				Mtu:                    1300,
+				NumWorkers:             2,
+				DomainStrategy:         wireguard.DeviceConfig_FORCE_IP64,
+				EndpointDomainStrategy: wireguard.DeviceConfig_ENDPOINT_FORCE_IP4,
+				KernelMode:             false,
+				UserLevel:              2,
+				ForwardPing:            true,
+			},
+		},
+		{
+			Input: `{
+				"secretKey": "uJv5tZMDltsiYEn+kUwb0Ll/CXWhMkaSCWWhfPEZM3A=",
+				"peers": [
+					{
+						"publicKey": "6e65ce0be17517110c17d77288ad87e7fd5252dcc7d09b95a39d61db03df832a",
+						"endpoint": "127.0.0.1:1234"
+					}
+				],
+				"kernelMode": false,
+				"onDemand": true,
+				"handshakeTimeout": 180,
+				"clampMss": 1240
+			}`,
+			Parser: loadJSON(creator),
+			Output: &wireguard.DeviceConfig{
+				SecretKey: "b89bf9b5930396db226049fe914c1bd0b97f0975a13246920965a17cf1193370",
+				Endpoint:  []string{"10.0.0.1", "fd59:7153:2388:b5fd:0000:0000:0000:0001"},
+				Peers: []*wireguard.PeerConfig{
+					{
+						PublicKey:  "6e65ce0be17517110c17d77288ad87e7fd5252dcc7d09b95a39d61db03df832a",
+						Endpoint:   "127.0.0.1:1234",
+						AllowedIps: []string{"0.0.0.0/0", "::0/0"},
+					},
+				},
+				Mtu:              1420,
+				OnDemand:         true,
+				IdleShutdown:     300,
+				HandshakeTimeout: 180,
+				ClampMss:         1240,
+			},
+		},
+		{
+			Input: `{
+				"secretKey": "uJv5tZMDltsiYEn+kUwb0Ll/CXWhMkaSCWWhfPEZM3A=",
+				"kernelMode": false,
+				"obfuscation": {
+					"jc": 4,
+					"jmin": 40,
+					"jmax": 70,
+					"s1": 15,
+					"s2": 30,
+					"h1": 1528462337,
+					"h2": 1528462338,
+					"h3": 1528462339,
+					"h4": 1528462340
+				}
+			}`,
+			Parser: loadJSON(creator),
+			Output: &wireguard.DeviceConfig{
+				SecretKey: "b89bf9b5930396db226049fe914c1bd0b97f0975a13246920965a17cf1193370",
+				Endpoint:  []string{"10.0.0.1", "fd59:7153:2388:b5fd:0000:0000:0000:0001"},
+				Mtu:       1420,
+				Obfuscation: &wireguard.Obfuscation{
+					JunkCount:        4,
+					JunkMinSize:      40,
+					JunkMaxSize:      70,
+					InitJunkSize:     15,
+					ResponseJunkSize: 30,
+					InitHeader:       1528462337,
+					ResponseHeader:   1528462338,
+					CookieHeader:     1528462339,
+					TransportHeader:  1528462340,
+				},
 			},
 		},
 	})
@@ -12,6 +12,7 @@ import (
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/transport/internet"
@@ -26,6 +27,7 @@ var (
 		"vless":         func() interface{} { return new(VLessInboundConfig) },
 		"vmess":         func() interface{} { return new(VMessInboundConfig) },
 		"trojan":        func() interface{} { return new(TrojanServerConfig) },
+		"tun":           func() interface{} { return new(TunConfig) },
 		"wireguard":     func() interface{} { return &WireGuardConfig{IsClient: false} },
 	}, "protocol", "settings")
 
@@ -41,6 +43,7 @@ var (
 		"trojan":      func() interface{} { return new(TrojanClientConfig) },
 		"dns":         func() interface{} { return new(DNSOutboundConfig) },
 		"wireguard":   func() interface{} { return &WireGuardConfig{IsClient: true} },
+		"selector":    func() interface{} { return new(SelectorConfig) },
 	}, "protocol", "settings")
 
 	ctllog = log.New(os.Stderr, "xctl> ", 0)
@@ -112,6 +115,9 @@ type MuxConfig struct {
 	Concurrency     int16  `json:"concurrency"`
 	XudpConcurrency int16  `json:"xudpConcurrency"`
 	XudpProxyUDP443 string `json:"xudpProxyUDP443"`
+
+	ConnectionReuseTimeout uint32 `json:"connectionReuseTimeout"`
+	DrainTimeout           uint32 `json:"drainTimeout"`
 }
 
 // Build creates MultiplexingConfig, Concurrency < 0 completely disables mux.
@@ -128,6 +134,9 @@ func (m *MuxConfig) Build() (*proxyman.MultiplexingConfig, error) {
 		Concurrency:     int32(m.Concurrency),
 		XudpConcurrency: int32(m.XudpConcurrency),
 		XudpProxyUDP443: m.XudpProxyUDP443,
+
+		ConnectionReuseTimeout: m.ConnectionReuseTimeout,
+		DrainTimeout:           m.DrainTimeout,
 	}, nil
 }
 
@@ -175,6 +184,8 @@ type InboundDetourConfig struct {
 	StreamSetting  *StreamConfig                  `json:"streamSettings"`
 	DomainOverride *StringList                    `json:"domainOverride"`
 	SniffingConfig *SniffingConfig                `json:"sniffing"`
+
+	ForbiddenTargets *StringList `json:"forbiddenTargets"`
 }
 
 // Build implements Buildable.
@@ -184,13 +195,23 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 	if c.ListenOn == nil {
 		// Listen on anyip, must set PortList
 		if c.PortList == nil {
-			return nil, newError("Listen on AnyIP but no Port(s) set in InboundDetour.")
+			// TUN device takes traffic from a device instead of listening
+			if strings.ToLower(c.Protocol) != "tun" {
+				return nil, newError("Listen on AnyIP but no Port(s) set in InboundDetour.")
+			}
+		} else {
+			receiverSettings.PortList = c.PortList.Build()
 		}
-		receiverSettings.PortList = c.PortList.Build()
 	} else {
-		// Listen on specific IP or Unix Domain Socket
+		// Listen on specific IP, Unix Domain Socket or vsock
 		receiverSettings.Listen = c.ListenOn.Build()
 		listenDS := c.ListenOn.Family().IsDomain() && (filepath.IsAbs(c.ListenOn.Domain()) || c.ListenOn.Domain()[0] == '@')
+		if c.ListenOn.Family().IsDomain() && net.IsVsockAddress(c.ListenOn.Domain()) {
+			if _, err := net.ParseVsockAddress(c.ListenOn.Domain()); err != nil {
+				return nil, newError("invalid listen address: ", c.ListenOn.Domain()).Base(err)
+			}
+			listenDS = true
+		}
 		listenIP := c.ListenOn.Family().IsIP() || (c.ListenOn.Family().IsDomain() && c.ListenOn.Domain() == "localhost")
 		if listenIP {
 			// Listen on specific IP, must set PortList
@@ -201,7 +222,7 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 			receiverSettings.PortList = c.PortList.Build()
 		} else if listenDS {
 			if c.PortList != nil {
-				// Listen on Unix Domain Socket, PortList should be nil
+				// Listen on Unix Domain Socket or vsock, PortList should be nil
 				receiverSettings.PortList = nil
 			}
 		} else {
@@ -254,6 +275,12 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 		}
 		receiverSettings.DomainOverride = kp
 	}
+	if c.ForbiddenTargets != nil {
+		if _, err := protocol.NewTargetRestriction(*c.ForbiddenTargets); err != nil {
+			return nil, newError("failed to parse forbiddenTargets").Base(err)
+		}
+		receiverSettings.ForbiddenTargets = *c.ForbiddenTargets
+	}
 
 	settings := []byte("{}")
 	if c.Settings != nil {
@@ -286,6 +313,8 @@ type OutboundDetourConfig struct {
 	StreamSetting *StreamConfig    `json:"streamSettings"`
 	ProxySettings *ProxyConfig     `json:"proxySettings"`
 	MuxSettings   *MuxConfig       `json:"mux"`
+
+	ServerAddressResolver string `json:"serverAddressResolver"`
 }
 
 func (c *OutboundDetourConfig) checkChainProxyConfig() error {
@@ -354,6 +383,14 @@ func (c *OutboundDetourConfig) Build() (*core.OutboundHandlerConfig, error) {
 		senderSettings.MultiplexSettings = ms
 	}
 
+	if len(c.ServerAddressResolver) > 0 {
+		switch strings.ToLower(c.Protocol) {
+		case "freedom", "blackhole", "dns", "loopback", "selector":
+			return nil, newError(`"serverAddressResolver" can't be used with protocol: `, c.Protocol)
+		}
+		senderSettings.ServerAddressResolver = c.ServerAddressResolver
+	}
+
 	settings := []byte("{}")
 	if c.Settings != nil {
 		settings = ([]byte)(*c.Settings)
@@ -412,6 +449,7 @@ type Config struct {
 	Policy           *PolicyConfig           `json:"policy"`
 	API              *APIConfig              `json:"api"`
 	Metrics          *MetricsConfig          `json:"metrics"`
+	OTel             *OTelConfig             `json:"otel"`
 	Stats            *StatsConfig            `json:"stats"`
 	Reverse          *ReverseConfig          `json:"reverse"`
 	FakeDNS          *FakeDNSConfig          `json:"fakeDns"`
@@ -466,6 +504,9 @@ func (c *Config) Override(o *Config, fn string) {
 	if o.Metrics != nil {
 		c.Metrics = o.Metrics
 	}
+	if o.OTel != nil {
+		c.OTel = o.OTel
+	}
 	if o.Stats != nil {
 		c.Stats = o.Stats
 	}
@@ -565,14 +606,32 @@ func (c *Config) Build() (*core.Config, error) {
 		return nil, err
 	}
 
+	inboundConfig := &proxyman.InboundConfig{}
+	if c.Policy != nil {
+		overrides, err := c.Policy.BuildInboundOverrides()
+		if err != nil {
+			return nil, err
+		}
+		inboundConfig.InboundPolicyOverrides = overrides
+	}
+
 	config := &core.Config{
 		App: []*serial.TypedMessage{
 			serial.ToTypedMessage(&dispatcher.Config{}),
-			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+			serial.ToTypedMessage(inboundConfig),
 			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
 		},
 	}
 
+	// before the features the dispatcher depends on, which looks up the tracer once they are all there
+	if c.OTel != nil {
+		otelConf, err := c.OTel.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.App = append(config.App, serial.ToTypedMessage(otelConf))
+	}
+
 	if c.API != nil {
 		apiConf, err := c.API.Build()
 		if err != nil {
@@ -597,7 +656,11 @@ func (c *Config) Build() (*core.Config, error) {
 
 	var logConfMsg *serial.TypedMessage
 	if c.LogConfig != nil {
-		logConfMsg = serial.ToTypedMessage(c.LogConfig.Build())
+		logConf, err := c.LogConfig.Build()
+		if err != nil {
+			return nil, err
+		}
+		logConfMsg = serial.ToTypedMessage(logConf)
 	} else {
 		logConfMsg = serial.ToTypedMessage(DefaultLogConfig())
 	}
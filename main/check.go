@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdCheck = &base.Command{
+	UsageLine: "{{.Exec}} check [-c config.json] [-confdir dir]",
+	Short:     "Check config for inconsistencies",
+	Long: `
+Check config for inconsistencies across its sections, like a 
+routing rule sending to an outbound that doesn't exist, and 
+print them with their location in the config.
+
+Errors make Xray refuse to start with the config, while 
+warnings are only logged. The command exits with a non-zero 
+status if there is any error.
+
+The -config=file, -c=file, -confdir=dir and -format=json flags 
+are the same as those of "{{.Exec}} run".
+	`,
+}
+
+func init() {
+	cmdCheck.Run = executeCheck // break init loop
+
+	cmdCheck.Flag.Var(&configFiles, "config", "Config path for Xray.")
+	cmdCheck.Flag.Var(&configFiles, "c", "Short alias of -config")
+	cmdCheck.Flag.StringVar(&configDir, "confdir", "", "A dir with multiple json config")
+	cmdCheck.Flag.StringVar(format, "format", "auto", "Format of input file.")
+}
+
+func executeCheck(cmd *base.Command, args []string) {
+	configFiles := getConfigFilePath(false)
+	c, err := core.LoadConfig(getConfigFormat(), configFiles)
+	if err != nil {
+		fmt.Println("Failed to load config files:", err)
+		os.Exit(23)
+	}
+
+	failed := false
+	for _, issue := range core.ValidateConfig(c) {
+		fmt.Println(issue)
+		if issue.Severity == core.IssueError {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(23)
+	}
+	fmt.Println("Configuration OK.")
+}
+
+// checkConfig logs the warnings about c, and returns an error if c has any error.
+func checkConfig(c *core.Config) error {
+	var errs []interface{}
+	for _, issue := range core.ValidateConfig(c) {
+		if issue.Severity == core.IssueError {
+			errs = append(errs, "\n  ", issue.Path, ": ", issue.Message)
+			continue
+		}
+		log.Println("Config", issue)
+	}
+	if len(errs) > 0 {
+		return newError(append([]interface{}{"invalid config:"}, errs...)...)
+	}
+	return nil
+}
@@ -21,8 +21,17 @@ var CmdAPI = &base.Command{
 		cmdAddOutbounds,
 		cmdRemoveInbounds,
 		cmdRemoveOutbounds,
+		cmdListOutbounds,
+		cmdListConnections,
+		cmdProbeOutbound,
+		cmdSelectOutbound,
+		cmdWireguardPeers,
 		cmdAddRules,
 		cmdRemoveRules,
+		cmdRuleStats,
+		cmdRuleBudgets,
 		cmdSourceIpBlock,
+		cmdListFakeDNS,
+		cmdFlushFakeDNS,
 	},
 }
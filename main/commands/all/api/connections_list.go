@@ -0,0 +1,42 @@
+package api
+
+import (
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdListConnections = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api lsc [--server=127.0.0.1:8080] [outboundTag]",
+	Short:       "List connections",
+	Long: `
+List the connections being relayed by the outbounds of Xray, the oldest
+first, with their state: handshaking until the outbound connects,
+established, or draining once the client has closed its side.
+Connections multiplexed by mux are not listed.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 proxy
+`,
+	Run: executeListConnections,
+}
+
+func executeListConnections(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	resp, err := client.ListConnections(ctx, &handlerService.ListConnectionsRequest{OutboundTag: cmd.Flag.Arg(0)})
+	if err != nil {
+		base.Fatalf("failed to list connections: %s", err)
+	}
+	showJSONResponse(resp)
+}
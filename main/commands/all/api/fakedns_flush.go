@@ -0,0 +1,56 @@
+package api
+
+import (
+	fakednsService "github.com/xtls/xray-core/app/dns/fakedns/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdFlushFakeDNS = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api flushfakedns [--server=127.0.0.1:8080] <-domain domain|-ip ip|-all>",
+	Short:       "Flush fake DNS mappings",
+	Long: `
+Flush the fake IP mapping of a domain, the mapping of a fake IP, or all
+the mappings. Clients get new fake IPs for the domains flushed once they
+ask again. Requires "FakeDNSService" in the API services.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-domain
+		The domain whose mapping to flush
+	-ip
+		The fake IP whose mapping to flush
+	-all
+		Flush all the mappings
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -domain example.com
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -ip 198.18.0.5
+`,
+	Run: executeFlushFakeDNS,
+}
+
+func executeFlushFakeDNS(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	domain := cmd.Flag.String("domain", "", "")
+	ip := cmd.Flag.String("ip", "", "")
+	all := cmd.Flag.Bool("all", false, "")
+	cmd.Flag.Parse(args)
+	if (*domain == "" && *ip == "") == !*all {
+		base.Fatalf("specify -domain or -ip, or -all")
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := fakednsService.NewFakeDNSServiceClient(conn)
+	resp, err := client.FlushFakeDNS(ctx, &fakednsService.FlushFakeDNSRequest{
+		Domain: *domain,
+		Ip:     *ip,
+	})
+	if err != nil {
+		base.Fatalf("failed to flush fake DNS mappings: %s", err)
+	}
+	showJSONResponse(resp)
+}
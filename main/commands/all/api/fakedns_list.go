@@ -0,0 +1,49 @@
+package api
+
+import (
+	fakednsService "github.com/xtls/xray-core/app/dns/fakedns/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdListFakeDNS = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api lsfakedns [--server=127.0.0.1:8080] [-offset 0] [-limit 0]",
+	Short:       "List fake DNS mappings",
+	Long: `
+List the domains mapped to fake IPs, the most recently used first, with
+the time they were last looked up. Requires "FakeDNSService" in the API
+services.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-offset
+		Number of mappings to skip. Default 0
+	-limit
+		Most mappings to list, all of them if 0. Default 0
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -offset 100 -limit 100
+`,
+	Run: executeListFakeDNS,
+}
+
+func executeListFakeDNS(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	offset := cmd.Flag.Uint("offset", 0, "")
+	limit := cmd.Flag.Uint("limit", 0, "")
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := fakednsService.NewFakeDNSServiceClient(conn)
+	resp, err := client.ListFakeDNS(ctx, &fakednsService.ListFakeDNSRequest{
+		Offset: uint32(*offset),
+		Limit:  uint32(*limit),
+	})
+	if err != nil {
+		base.Fatalf("failed to list fake DNS mappings: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -20,6 +20,9 @@ Arguments:
 		The API server address. Default 127.0.0.1:8080
 	-t, -timeout
 		Timeout seconds to call API. Default 3
+	-expire
+		Remove the outbounds automatically after being unused for the
+		given seconds. Default 0 (never)
 Example:
     {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 c1.json c2.json
 `,
@@ -28,6 +31,7 @@ Example:
 
 func executeAddOutbounds(cmd *base.Command, args []string) {
 	setSharedFlags(cmd)
+	expire := cmd.Flag.Uint("expire", 0, "")
 	cmd.Flag.Parse(args)
 	unnamedArgs := cmd.Flag.Args()
 	if len(unnamedArgs) == 0 {
@@ -62,7 +66,8 @@ func executeAddOutbounds(cmd *base.Command, args []string) {
 			base.Fatalf("failed to build conf: %s", err)
 		}
 		r := &handlerService.AddOutboundRequest{
-			Outbound: o,
+			Outbound:        o,
+			ExpireAfterIdle: uint32(*expire),
 		}
 		resp, err := client.AddOutbound(ctx, r)
 		if err != nil {
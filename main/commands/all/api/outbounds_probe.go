@@ -0,0 +1,63 @@
+package api
+
+import (
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdProbeOutbound = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api probe [--server=127.0.0.1:8080] <tag>",
+	Short:       "Probe an outbound",
+	Long: `
+Send a GET request through an outbound, and report the time it took to
+connect, the TLS handshake time, the HTTP status and the total time.
+The probe doesn't count in stats unless -stats is set.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. It is raised to cover the probe
+		timeout if needed. Default 3
+	-url
+		The URL to request. Default https://www.google.com/generate_204
+	-probetimeout
+		Timeout milliseconds of the probe. Default 5000
+	-stats
+		Count the probe in the stats of the outbound.
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 proxy
+`,
+	Run: executeProbeOutbound,
+}
+
+func executeProbeOutbound(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	testURL := cmd.Flag.String("url", "", "")
+	probeTimeout := cmd.Flag.Uint("probetimeout", 5000, "")
+	recordStats := cmd.Flag.Bool("stats", false, "")
+	cmd.Flag.Parse(args)
+
+	tag := cmd.Flag.Arg(0)
+	if tag == "" {
+		base.Fatalf("no outbound specified")
+	}
+	if t := int(*probeTimeout/1000) + 1; apiTimeout < t {
+		apiTimeout = t
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	resp, err := client.ProbeOutbound(ctx, &handlerService.ProbeOutboundRequest{
+		Tag:         tag,
+		TestUrl:     *testURL,
+		TimeoutMs:   uint32(*probeTimeout),
+		RecordStats: *recordStats,
+	})
+	if err != nil {
+		base.Fatalf("failed to probe outbound: %s", err)
+	}
+	showJSONResponse(resp)
+}
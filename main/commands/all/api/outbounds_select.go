@@ -0,0 +1,56 @@
+package api
+
+import (
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdSelectOutbound = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api sel [--server=127.0.0.1:8080] <selectorTag> [outboundTag]",
+	Short:       "Select the outbound of a selector",
+	Long: `
+Select the outbound that new connections of a selector outbound go to.
+Connections already established are not affected. Without outboundTag,
+the selected outbound and the candidates are shown.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 proxy
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 proxy us-west
+`,
+	Run: executeSelectOutbound,
+}
+
+func executeSelectOutbound(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+	if cmd.Flag.NArg() < 1 {
+		base.Fatalf("selector tag not specified")
+	}
+	selector := cmd.Flag.Arg(0)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	if cmd.Flag.NArg() > 1 {
+		_, err := client.SetSelected(ctx, &handlerService.SetSelectedRequest{
+			SelectorTag: selector,
+			OutboundTag: cmd.Flag.Arg(1),
+		})
+		if err != nil {
+			base.Fatalf("failed to select outbound: %s", err)
+		}
+	}
+	resp, err := client.GetSelected(ctx, &handlerService.GetSelectedRequest{
+		SelectorTag: selector,
+	})
+	if err != nil {
+		base.Fatalf("failed to get selected outbound: %s", err)
+	}
+	showJSONResponse(resp)
+}
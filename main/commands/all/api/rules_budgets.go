@@ -0,0 +1,39 @@
+package api
+
+import (
+	routerService "github.com/xtls/xray-core/app/router/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdRuleBudgets = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api rulebudgets [--server=127.0.0.1:8080]",
+	Short:       "Get routing rule budgets",
+	Long: `
+Get the bytes used in the current window by the routing rules having a
+budget, and whether their traffic goes to the overflow outbound.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080
+`,
+	Run: executeRuleBudgets,
+}
+
+func executeRuleBudgets(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := routerService.NewRoutingServiceClient(conn)
+	resp, err := client.GetRuleBudgets(ctx, &routerService.GetRuleBudgetsRequest{})
+	if err != nil {
+		base.Fatalf("failed to get rule budgets: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	routerService "github.com/xtls/xray-core/app/router/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdRuleStats = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api rulestats [--server=127.0.0.1:8080] [-reset]",
+	Short:       "Get routing rule statistics",
+	Long: `
+Get the hit count, last hit time and traffic of every routing rule.
+Requires "ruleStats" to be enabled in the routing config.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-reset
+		Reset the statistics after reading
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -reset
+`,
+	Run: executeRuleStats,
+}
+
+func executeRuleStats(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	reset := cmd.Flag.Bool("reset", false, "")
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := routerService.NewRoutingServiceClient(conn)
+	resp, err := client.GetRuleStats(ctx, &routerService.GetRuleStatsRequest{Reset_: *reset})
+	if err != nil {
+		base.Fatalf("failed to get rule stats: %s", err)
+	}
+	showJSONResponse(resp)
+}
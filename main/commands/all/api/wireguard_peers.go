@@ -0,0 +1,43 @@
+package api
+
+import (
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdWireguardPeers = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api wgpeers [--server=127.0.0.1:8080] <tag>",
+	Short:       "Show the peers of a WireGuard inbound or outbound",
+	Long: `
+Show the peers of a WireGuard inbound or outbound, with their endpoints,
+the time of their last handshake and the bytes received from and sent
+to them.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 wireguard
+`,
+	Run: executeWireguardPeers,
+}
+
+func executeWireguardPeers(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+	if cmd.Flag.NArg() < 1 {
+		base.Fatalf("tag not specified")
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	resp, err := client.GetWireguardPeers(ctx, &handlerService.GetWireguardPeersRequest{Tag: cmd.Flag.Arg(0)})
+	if err != nil {
+		base.Fatalf("failed to get the peers: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -15,6 +15,7 @@ func main() {
 	base.RootCommand.Commands = append(
 		[]*base.Command{
 			cmdRun,
+			cmdCheck,
 			cmdVersion,
 		},
 		base.RootCommand.Commands...,
@@ -36,7 +36,7 @@ The -format=json flag sets the format of config files.
 Default "auto".
 
 The -test flag tells Xray to test config files only, 
-without launching the server.
+without launching the server. See also "{{.Exec}} check".
 
 The -dump flag tells Xray to print the merged config.
 	`,
@@ -220,6 +220,10 @@ func startXray() (core.Server, error) {
 		return nil, newError("failed to load config files: [", configFiles.String(), "]").Base(err)
 	}
 
+	if err := checkConfig(c); err != nil {
+		return nil, err
+	}
+
 	server, err := core.New(c)
 	if err != nil {
 		return nil, newError("failed to create server").Base(err)
@@ -222,6 +222,12 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, d internet.
 	return nil
 }
 
+// isFakeIP tells whether ip is from a pool of the fake DNS.
+func (h *Handler) isFakeIP(ip net.IP) bool {
+	fkr, ok := h.fdns.(dns.FakeDNSEngineRev0)
+	return ok && fkr.IsIPInIPPool(net.IPAddress(ip))
+}
+
 func (h *Handler) handleIPQuery(id uint16, qType dnsmessage.Type, domain string, writer dns_proto.MessageWriter) {
 	var ips []net.IP
 	var err error
@@ -249,20 +255,32 @@ func (h *Handler) handleIPQuery(id uint16, qType dnsmessage.Type, domain string,
 		return
 	}
 
-	if fkr0, ok := h.fdns.(dns.FakeDNSEngineRev0); ok && len(ips) > 0 && fkr0.IsIPInIPPool(net.IPAddress(ips[0])) {
-		ttl = 1
+	if len(ips) > 0 {
+		switch fkr := h.fdns.(type) {
+		case dns.FakeDNSEngineRev1:
+			if fakeTTL, ok := fkr.GetFakeIPTTL(net.IPAddress(ips[0])); ok {
+				ttl = fakeTTL
+			}
+		case dns.FakeDNSEngineRev0:
+			if fkr.IsIPInIPPool(net.IPAddress(ips[0])) {
+				ttl = 1
+			}
+		}
 	}
 
-	switch qType {
-	case dnsmessage.TypeA:
-		for i, ip := range ips {
-			ips[i] = ip.To4()
-		}
-	case dnsmessage.TypeAAAA:
-		for i, ip := range ips {
-			ips[i] = ip.To16()
+	// only the IPs of the family asked for are answered, as a fake DNS pool of the other one
+	// may come first. IPv4-mapped IPv6 addresses are answered to AAAA queries, even once
+	// shortened to 4 bytes, unless they come from a fake pool.
+	answers := ips[:0]
+	for _, ip := range ips {
+		switch {
+		case qType == dnsmessage.TypeA && ip.To4() != nil:
+			answers = append(answers, ip.To4())
+		case qType == dnsmessage.TypeAAAA && (len(ip) == net.IPv6len || !h.isFakeIP(ip)):
+			answers = append(answers, ip.To16())
 		}
 	}
+	ips = answers
 
 	b := buf.New()
 	rawBytes := b.Extend(buf.Size)
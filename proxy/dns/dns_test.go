@@ -66,6 +66,11 @@ func (*staticHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			common.Must(err)
 			ans.Answer = append(ans.Answer, rr)
 
+		case q.Name == "mapped.google.com." && q.Qtype == dns.TypeAAAA:
+			rr, err := dns.NewRR("mapped.google.com. IN AAAA ::ffff:8.8.8.8")
+			common.Must(err)
+			ans.Answer = append(ans.Answer, rr)
+
 		case q.Name == "notexist.google.com." && q.Qtype == dns.TypeAAAA:
 			ans.MsgHdr.Rcode = dns.RcodeNameError
 		}
@@ -174,6 +179,30 @@ func TestUDPDNSTunnel(t *testing.T) {
 		}
 	}
 
+	{
+		m1 := new(dns.Msg)
+		m1.Id = dns.Id()
+		m1.RecursionDesired = true
+		m1.Question = make([]dns.Question, 1)
+		m1.Question[0] = dns.Question{Name: "mapped.google.com.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}
+
+		c := new(dns.Client)
+		in, _, err := c.Exchange(m1, "127.0.0.1:"+strconv.Itoa(int(serverPort)))
+		common.Must(err)
+
+		if len(in.Answer) != 1 {
+			t.Fatal("len(answer): ", len(in.Answer))
+		}
+
+		rr, ok := in.Answer[0].(*dns.AAAA)
+		if !ok {
+			t.Fatal("not AAAA record")
+		}
+		if r := cmp.Diff(rr.AAAA[:], net.ParseIP("::ffff:8.8.8.8")); r != "" {
+			t.Error(r)
+		}
+	}
+
 	{
 		m1 := new(dns.Msg)
 		m1.Id = dns.Id()
@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/dns"
+	"github.com/xtls/xray-core/transport"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// The ways outbounds handle the DNS queries sent to port 53 over UDP.
+const (
+	// InterceptDNSTunnel tunnels the queries to the resolver they were sent to, as any UDP traffic.
+	InterceptDNSTunnel = "tunnel"
+	// InterceptDNSLocal answers the queries with the DNS client of Xray, without any tunnel bytes.
+	InterceptDNSLocal = "local"
+	// InterceptDNSSkip drops the queries, neither tunneled nor answered.
+	InterceptDNSSkip = "skip"
+)
+
+// dnsUDPSize is the UDP payload size advertised in the answers to EDNS0 queries.
+const dnsUDPSize = 1232
+
+// DNSInterceptor takes over the UDP flows of an outbound to port 53, as long as they start with a DNS
+// query. Flows starting with anything else are left to the tunnel.
+type DNSInterceptor struct {
+	mode    string
+	client  dns.Client
+	fdns    dns.FakeDNSEngine
+	timeout time.Duration
+}
+
+// NewDNSInterceptor creates the DNSInterceptor of an outbound for mode, nil when the queries are
+// tunneled. A flow is closed once it stays idle for timeout.
+func NewDNSInterceptor(ctx context.Context, mode string, timeout time.Duration) (*DNSInterceptor, error) {
+	i := &DNSInterceptor{
+		mode:    mode,
+		timeout: timeout,
+	}
+	switch i.mode {
+	case "", InterceptDNSTunnel:
+		return nil, nil
+	case InterceptDNSLocal:
+		if err := core.RequireFeatures(ctx, func(client dns.Client) {
+			i.client = client
+		}); err != nil {
+			return nil, err
+		}
+		core.RequireFeatures(ctx, func(fdns dns.FakeDNSEngine) {
+			i.fdns = fdns
+		})
+	case InterceptDNSSkip:
+	default:
+		return nil, newError("unknown DNS interception: ", mode)
+	}
+	return i, nil
+}
+
+// Intercept handles the flow of link to target if it goes to port 53 over UDP and starts with a DNS
+// query, and reports whether it did. Otherwise the reader of link replays what was read, for the tunnel.
+func (i *DNSInterceptor) Intercept(ctx context.Context, target net.Destination, link *transport.Link) (bool, error) {
+	if i == nil || target.Network != net.Network_UDP || target.Port != 53 {
+		return false, nil
+	}
+
+	mb, err := link.Reader.ReadMultiBuffer()
+	if mb.IsEmpty() {
+		if err == nil || errors.Cause(err) == io.EOF {
+			return true, nil
+		}
+		return true, newError("failed to read DNS query").Base(err)
+	}
+	if !isDNSQuery(mb[0].Bytes()) {
+		newError("tunneling UDP traffic to ", target, " that is not DNS").AtDebug().WriteToLog(session.ExportIDToError(ctx))
+		link.Reader = &buf.BufferedReader{Reader: link.Reader, Buffer: mb}
+		return false, nil
+	}
+	newError(i.mode, " DNS queries to ", target).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, i.timeout)
+
+	handle := func(b *buf.Buffer) {
+		defer b.Release()
+		if i.mode == InterceptDNSSkip {
+			return
+		}
+		answer, err := i.answer(b.Bytes())
+		if err != nil {
+			newError("failed to answer DNS query").Base(err).WriteToLog(session.ExportIDToError(ctx))
+			return
+		}
+		answer.UDP = b.UDP
+		if err := link.Writer.WriteMultiBuffer(buf.MultiBuffer{answer}); err != nil {
+			newError("failed to write DNS answer").Base(err).WriteToLog(session.ExportIDToError(ctx))
+			return
+		}
+		timer.Update()
+	}
+
+	request := func() error {
+		for {
+			for _, b := range mb {
+				go handle(b)
+			}
+			timer.Update()
+
+			var err error
+			if mb, err = link.Reader.ReadMultiBuffer(); err != nil {
+				buf.ReleaseMulti(mb)
+				if errors.Cause(err) == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+
+	if err := task.Run(ctx, request); err != nil {
+		return true, newError("connection ends").Base(err)
+	}
+	return true, nil
+}
+
+// isDNSQuery tells if b holds a standard DNS query with a question.
+func isDNSQuery(b []byte) bool {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(b)
+	if err != nil || header.Response || header.OpCode != 0 {
+		return false
+	}
+	_, err = parser.Question()
+	return err == nil
+}
+
+// answer resolves the A or AAAA question of query with the DNS client, in lower case, and answers the other
+// questions as not implemented. Answers to EDNS0 queries carry an OPT record as well, and answers larger
+// than the query allows are truncated.
+func (i *DNSInterceptor) answer(query []byte) (*buf.Buffer, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	if err := parser.SkipAllAnswers(); err != nil {
+		return nil, err
+	}
+	if err := parser.SkipAllAuthorities(); err != nil {
+		return nil, err
+	}
+	maxLen := 512
+	edns0 := false
+	for {
+		h, err := parser.AdditionalHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Type == dnsmessage.TypeOPT {
+			edns0 = true
+			if size := int(h.Class); size > maxLen {
+				maxLen = size
+			}
+		}
+		if err := parser.SkipAdditional(); err != nil {
+			return nil, err
+		}
+	}
+	if maxLen > buf.Size {
+		maxLen = buf.Size
+	}
+
+	var ips []net.IP
+	var ttl uint32 = 600
+	rcode := dnsmessage.RCodeNotImplemented
+	if q.Class == dnsmessage.ClassINET && (q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeAAAA) {
+		ips, err = i.client.LookupIP(strings.ToLower(strings.TrimSuffix(q.Name.String(), ".")), dns.IPOption{
+			IPv4Enable: q.Type == dnsmessage.TypeA,
+			IPv6Enable: q.Type == dnsmessage.TypeAAAA,
+			FakeEnable: true,
+		})
+		rcode = dnsmessage.RCode(dns.RCodeFromError(err))
+		if rcode == dnsmessage.RCodeSuccess && err != nil && !errors.AllEqual(dns.ErrEmptyResponse, errors.Cause(err)) {
+			newError("failed to look up ", q.Name).Base(err).WriteToLog()
+			rcode = dnsmessage.RCodeServerFailure
+		}
+		if fkr0, ok := i.fdns.(dns.FakeDNSEngineRev0); ok && len(ips) > 0 && fkr0.IsIPInIPPool(net.IPAddress(ips[0])) {
+			ttl = 1
+		}
+	}
+
+	b := buf.New()
+	rawBytes := b.Extend(buf.Size)
+	build := func(truncated bool) ([]byte, error) {
+		builder := dnsmessage.NewBuilder(rawBytes[:0], dnsmessage.Header{
+			ID:                 header.ID,
+			Response:           true,
+			OpCode:             header.OpCode,
+			Truncated:          truncated,
+			RecursionDesired:   header.RecursionDesired,
+			RecursionAvailable: true,
+			RCode:              rcode & 0xf,
+		})
+		builder.EnableCompression()
+		common.Must(builder.StartQuestions())
+		if err := builder.Question(q); err != nil {
+			return nil, err
+		}
+		common.Must(builder.StartAnswers())
+		rHeader := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: ttl}
+		answers := ips
+		if truncated {
+			answers = nil
+		}
+		for _, ip := range answers {
+			switch ip4 := ip.To4(); {
+			case q.Type == dnsmessage.TypeA && ip4 != nil:
+				var r dnsmessage.AResource
+				copy(r.A[:], ip4)
+				common.Must(builder.AResource(rHeader, r))
+			case q.Type == dnsmessage.TypeAAAA && ip4 == nil:
+				var r dnsmessage.AAAAResource
+				copy(r.AAAA[:], ip.To16())
+				common.Must(builder.AAAAResource(rHeader, r))
+			}
+		}
+		if edns0 {
+			common.Must(builder.StartAdditionals())
+			var opt dnsmessage.ResourceHeader
+			common.Must(opt.SetEDNS0(dnsUDPSize, rcode, false))
+			common.Must(builder.OPTResource(opt, dnsmessage.OPTResource{}))
+		}
+		return builder.Finish()
+	}
+
+	msg, err := build(false)
+	if err == nil && len(msg) > maxLen {
+		msg, err = build(true)
+	}
+	if err != nil {
+		b.Release()
+		return nil, err
+	}
+	b.Resize(0, int32(len(msg)))
+	return b, nil
+}
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type staticDNS map[string][]net.IP
+
+func (staticDNS) Type() interface{} { return dns.ClientType() }
+func (staticDNS) Start() error      { return nil }
+func (staticDNS) Close() error      { return nil }
+
+func (c staticDNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, error) {
+	var ips []net.IP
+	for _, ip := range c[domain] {
+		if (ip.To4() != nil && option.IPv4Enable) || (ip.To4() == nil && option.IPv6Enable) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, dns.ErrEmptyResponse
+	}
+	return ips, nil
+}
+
+func buildQuery(id uint16, name string, qType dnsmessage.Type, udpSize int) *buf.Buffer {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	common.Must(builder.StartQuestions())
+	common.Must(builder.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name),
+		Type:  qType,
+		Class: dnsmessage.ClassINET,
+	}))
+	if udpSize > 0 {
+		common.Must(builder.StartAdditionals())
+		var opt dnsmessage.ResourceHeader
+		common.Must(opt.SetEDNS0(udpSize, dnsmessage.RCodeSuccess, false))
+		common.Must(builder.OPTResource(opt, dnsmessage.OPTResource{}))
+	}
+	b, err := builder.Finish()
+	common.Must(err)
+	return buf.FromBytes(b)
+}
+
+func TestDNSInterceptorLocal(t *testing.T) {
+	many := make([]net.IP, 64)
+	for i := range many {
+		many[i] = net.IP{10, 0, 0, byte(i)}
+	}
+	interceptor := &DNSInterceptor{
+		mode: InterceptDNSLocal,
+		client: staticDNS{
+			"example.com":  {net.IP{192, 0, 2, 1}, net.ParseIP("2001:db8::1")},
+			"many.example": many,
+		},
+		timeout: time.Minute,
+	}
+
+	uplinkReader, uplinkWriter := pipe.New()
+	downlinkReader, downlinkWriter := pipe.New()
+	link := &transport.Link{Reader: uplinkReader, Writer: downlinkWriter}
+	done := make(chan error, 1)
+	go func() {
+		handled, err := interceptor.Intercept(context.Background(), net.UDPDestination(net.ParseAddress("8.8.8.8"), 53), link)
+		if !handled {
+			err = newError("not intercepted")
+		}
+		done <- err
+	}()
+
+	cases := []struct {
+		Name      string
+		QName     string
+		QType     dnsmessage.Type
+		UDPSize   int
+		RCode     dnsmessage.RCode
+		Answers   int
+		Truncated bool
+	}{
+		{Name: "A", QName: "example.com.", QType: dnsmessage.TypeA, Answers: 1},
+		{Name: "AAAA with EDNS0", QName: "Example.COM.", QType: dnsmessage.TypeAAAA, UDPSize: 1232, Answers: 1},
+		{Name: "no record", QName: "example.org.", QType: dnsmessage.TypeA, UDPSize: 1232},
+		{Name: "not an IP query", QName: "example.com.", QType: dnsmessage.TypeTXT, UDPSize: 1232, RCode: dnsmessage.RCodeNotImplemented},
+		{Name: "too large", QName: "many.example.", QType: dnsmessage.TypeA, Truncated: true},
+		{Name: "large with EDNS0", QName: "many.example.", QType: dnsmessage.TypeA, UDPSize: 4096, Answers: len(many)},
+	}
+	for i, c := range cases {
+		id := uint16(i + 1)
+		common.Must(uplinkWriter.WriteMultiBuffer(buf.MultiBuffer{buildQuery(id, c.QName, c.QType, c.UDPSize)}))
+		mb, err := downlinkReader.ReadMultiBuffer()
+		common.Must(err)
+		if len(mb) != 1 {
+			t.Fatal(c.Name, ": expect one answer, but got ", len(mb))
+		}
+		size := 512
+		if c.UDPSize > size {
+			size = c.UDPSize
+		}
+		if mb[0].Len() > int32(size) {
+			t.Error(c.Name, ": answer of ", mb[0].Len(), " bytes exceeds ", size)
+		}
+		var message dnsmessage.Message
+		common.Must(message.Unpack(mb[0].Bytes()))
+		buf.ReleaseMulti(mb)
+
+		if message.ID != id || !message.Response || !message.RecursionDesired || message.RCode != c.RCode || message.Truncated != c.Truncated {
+			t.Error(c.Name, ": unexpected header ", message.Header)
+		}
+		if len(message.Questions) != 1 || message.Questions[0].Name.String() != c.QName || message.Questions[0].Type != c.QType {
+			t.Error(c.Name, ": unexpected questions ", message.Questions)
+		}
+		if len(message.Answers) != c.Answers {
+			t.Error(c.Name, ": expect ", c.Answers, " answers, but got ", len(message.Answers))
+		}
+		opts := 0
+		for _, r := range message.Additionals {
+			if r.Header.Type == dnsmessage.TypeOPT {
+				opts++
+			}
+		}
+		if edns0 := c.UDPSize > 0; edns0 != (opts == 1) {
+			t.Error(c.Name, ": expect an OPT record ", edns0, ", but got ", opts)
+		}
+	}
+
+	common.Must(uplinkWriter.Close())
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDNSInterceptorNotDNS(t *testing.T) {
+	interceptor := &DNSInterceptor{
+		mode:    InterceptDNSLocal,
+		client:  staticDNS{},
+		timeout: time.Minute,
+	}
+
+	uplinkReader, uplinkWriter := pipe.New()
+	_, downlinkWriter := pipe.New()
+	link := &transport.Link{Reader: uplinkReader, Writer: downlinkWriter}
+	b := buf.New()
+	b.WriteString("not a DNS query")
+	common.Must(uplinkWriter.WriteMultiBuffer(buf.MultiBuffer{b}))
+
+	handled, err := interceptor.Intercept(context.Background(), net.UDPDestination(net.ParseAddress("8.8.8.8"), 53), link)
+	common.Must(err)
+	if handled {
+		t.Fatal("expect traffic that is not DNS left to the tunnel")
+	}
+	mb, err := link.Reader.ReadMultiBuffer()
+	common.Must(err)
+	if mb.String() != "not a DNS query" {
+		t.Error("expect the traffic replayed, but got ", mb.String())
+	}
+	buf.ReleaseMulti(mb)
+}
@@ -4,6 +4,7 @@ package dokodemo
 
 import (
 	"context"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -55,16 +56,20 @@ func (d *DokodemoDoor) Init(config *Config, pm policy.Manager, sockopt *session.
 
 // Network implements proxy.Inbound.
 func (d *DokodemoDoor) Network() []net.Network {
-	if len(d.config.Networks) > 0 {
-		return d.config.Networks
+	networks := d.config.Networks
+	if len(networks) == 0 {
+		networks = d.config.NetworkList.Network
 	}
-
-	return d.config.NetworkList.Network
+	// Streams may also be accepted on Unix domain and vsock sockets.
+	if net.HasNetwork(networks, net.Network_TCP) && !net.HasNetwork(networks, net.Network_UNIX) {
+		networks = append(networks[:len(networks):len(networks)], net.Network_UNIX)
+	}
+	return networks
 }
 
-func (d *DokodemoDoor) policy() policy.Session {
+func (d *DokodemoDoor) policy(ctx context.Context) policy.Session {
 	config := d.config
-	p := d.policyManager.ForLevel(config.UserLevel)
+	p := policy.ForContext(ctx, d.policyManager, config.UserLevel)
 	if config.Timeout > 0 && config.UserLevel == 0 {
 		p.Timeouts.ConnectionIdle = time.Duration(config.Timeout) * time.Second
 	}
@@ -83,12 +88,16 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 		Address: d.address,
 		Port:    d.port,
 	}
+	if network == net.Network_UNIX && !isSocketAddress(d.address) {
+		// Streams accepted on Unix domain or vsock sockets reach network targets over TCP.
+		dest.Network = net.Network_TCP
+	}
 
 	destinationOverridden := false
 	if d.config.FollowRedirect {
 		outbounds := session.OutboundsFromContext(ctx)
 		if len(outbounds) > 0 {
-			ob := outbounds[len(outbounds) - 1]
+			ob := outbounds[len(outbounds)-1]
 			if ob.Target.IsValid() {
 				dest = ob.Target
 				destinationOverridden = true
@@ -121,7 +130,7 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 	})
 	newError("received request for ", conn.RemoteAddr()).WriteToLog(session.ExportIDToError(ctx))
 
-	plcy := d.policy()
+	plcy := d.policy(ctx)
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
 
@@ -315,3 +324,12 @@ func (w *PacketWriter) Close() error {
 	}
 	return nil
 }
+
+// isSocketAddress returns true if the address is the path of a Unix domain socket, or a vsock address.
+func isSocketAddress(addr net.Address) bool {
+	if addr == nil || !addr.Family().IsDomain() {
+		return false
+	}
+	domain := addr.Domain()
+	return filepath.IsAbs(domain) || domain[0] == '@' || net.IsVsockAddress(domain)
+}
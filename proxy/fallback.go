@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/core"
+)
+
+// ValidateFallbacks warns of fallbacks that are never used, as they only work over the tcp transport.
+// fallbacks is how many the inbound at path has.
+func ValidateFallbacks(v *core.Validation, path string, fallbacks int) {
+	if fallbacks == 0 || v.Inbound.ReceiverSettings == nil {
+		return
+	}
+	receiver, err := v.Inbound.ReceiverSettings.GetInstance()
+	if err != nil {
+		return
+	}
+	if config, ok := receiver.(*proxyman.ReceiverConfig); ok {
+		if protocol := config.StreamSettings.GetEffectiveProtocol(); protocol != "tcp" {
+			v.Warning(path+".fallbacks", "fallbacks are not used over the ", protocol, " transport")
+		}
+	}
+}
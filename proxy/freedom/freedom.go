@@ -65,6 +65,11 @@ func (h *Handler) Init(config *Config, pm policy.Manager, d dns.Client) error {
 	return nil
 }
 
+// RelaysICMP implements outbound.ICMPRelay.
+func (h *Handler) RelaysICMP() bool {
+	return h.config.AllowIcmp
+}
+
 func (h *Handler) policy() policy.Session {
 	p := h.policyManager.ForLevel(h.config.UserLevel)
 	if h.config.Timeout > 0 && h.config.UserLevel == 0 {
@@ -107,7 +112,7 @@ func isValidAddress(addr *net.IPOrDomain) bool {
 // Process implements proxy.Outbound.
 func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	if !ob.Target.IsValid() {
 		return newError("target not specified.")
 	}
@@ -129,13 +134,19 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		}
 	}
 
+	if destination.Network == net.Network_ICMP {
+		return h.processEcho(ctx, link, destination)
+	}
+
 	input := link.Reader
 	output := link.Writer
 
 	var conn stat.Connection
+	// forbidden stops the retries when a domain resolves to a forbidden target
+	var forbidden error
 	err := retry.ExponentialBackoff(5, 100).On(func() error {
 		dialDest := destination
-		if h.config.hasStrategy() && dialDest.Address.Family().IsDomain() {
+		if h.config.hasStrategy() && dialDest.Address.Family().IsDomain() && !net.IsVsockAddress(dialDest.Address.Domain()) {
 			ip := h.resolveIP(ctx, dialDest.Address.Domain(), dialer.Address())
 			if ip != nil {
 				dialDest = net.Destination{
@@ -143,6 +154,9 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 					Address: ip,
 					Port:    dialDest.Port,
 				}
+				if forbidden = policy.CheckTarget(ctx, nil, dialDest); forbidden != nil {
+					return nil
+				}
 				newError("dialing to ", dialDest).WriteToLog(session.ExportIDToError(ctx))
 			} else if h.config.forceIP() {
 				return dns.ErrEmptyResponse
@@ -168,6 +182,9 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		conn = rawConn
 		return nil
 	})
+	if forbidden != nil {
+		return newError("failed to open connection to ", destination).Base(forbidden)
+	}
 	if err != nil {
 		return newError("failed to open connection to ", destination).Base(err)
 	}
@@ -338,6 +355,10 @@ func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 				ip := w.Handler.resolveIP(w.Context, b.UDP.Address.Domain(), nil)
 				if ip != nil {
 					b.UDP.Address = ip
+					if policy.CheckTarget(w.Context, nil, *b.UDP) != nil {
+						b.Release()
+						continue
+					}
 				}
 			}
 			destAddr, _ := net.ResolveUDPAddr("udp", b.UDP.NetAddr())
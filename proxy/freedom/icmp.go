@@ -0,0 +1,276 @@
+package freedom
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/transport"
+	"golang.org/x/net/icmp"
+)
+
+// fakeEchoLatency is how long a faked echo reply waits, so ping shows
+// something that looks like a network round trip.
+const fakeEchoLatency = 20 * time.Millisecond
+
+const (
+	icmpv4EchoRequest = 8
+	icmpv4EchoReply   = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// pinger sends ICMP echo requests and receives the replies. Both are whole
+// ICMP messages; checksums are left to whoever writes the IP packet back.
+// Receive returns io.EOF once the pinger is closed.
+type pinger interface {
+	Send(request []byte) error
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// processEcho relays ICMP echo requests of link to destination.
+func (h *Handler) processEcho(ctx context.Context, link *transport.Link, destination net.Destination) error {
+	if !h.config.AllowIcmp {
+		return newError("ICMP echo to ", destination, " is not allowed")
+	}
+	if destination.Address.Family().IsDomain() {
+		ip := h.resolveIP(ctx, destination.Address.Domain(), nil)
+		if ip == nil {
+			return newError("failed to resolve ", destination)
+		}
+		destination.Address = ip
+	}
+	ip := destination.Address.IP()
+
+	var p pinger
+	if h.config.FakeReply != Config_FAKE_ALWAYS {
+		socket, err := newICMPPinger(ip)
+		switch {
+		case err == nil:
+			p = socket
+		case h.config.FakeReply == Config_FAKE_NEVER:
+			return newError("failed to open ICMP socket").Base(err)
+		default:
+			newError("failed to open ICMP socket, faking echo replies").Base(err).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+		}
+	}
+	if p == nil {
+		p = newFakePinger(ip.To4() != nil)
+	}
+	defer p.Close()
+	newError("relaying ICMP echo to ", destination).WriteToLog(session.ExportIDToError(ctx))
+
+	plcy := h.policy()
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+
+	requestDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
+		for {
+			mb, err := link.Reader.ReadMultiBuffer()
+			if err != nil {
+				return nil
+			}
+			timer.Update()
+			for _, b := range mb {
+				if err := p.Send(b.Bytes()); err != nil {
+					newError("failed to send echo request").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+				}
+			}
+			buf.ReleaseMulti(mb)
+		}
+	}
+
+	responseDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
+		for {
+			reply, err := p.Receive()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return newError("failed to receive echo reply").Base(err)
+			}
+			timer.Update()
+			b := buf.New()
+			b.Write(reply)
+			if err := link.Writer.WriteMultiBuffer(buf.MultiBuffer{b}); err != nil {
+				return err
+			}
+		}
+	}
+
+	closePinger := func() error {
+		return p.Close()
+	}
+	if err := task.Run(ctx, task.OnSuccess(requestDone, closePinger), responseDone); err != nil {
+		return newError("echo ends").Base(err)
+	}
+	return nil
+}
+
+func isEchoRequest(message []byte, v4 bool) bool {
+	if len(message) < 8 {
+		return false
+	}
+	if v4 {
+		return message[0] == icmpv4EchoRequest
+	}
+	return message[0] == icmpv6EchoRequest
+}
+
+// icmpPinger sends real echo requests. Unprivileged ICMP sockets are
+// preferred, raw sockets are used if the process is allowed to.
+type icmpPinger struct {
+	conn       *icmp.PacketConn
+	dst        net.IP
+	v4         bool
+	privileged bool
+	closed     atomic.Bool
+
+	access sync.Mutex
+	// id of the requests, restored in the replies since the kernel
+	// rewrites it on unprivileged sockets
+	id uint16
+}
+
+func newICMPPinger(dst net.IP) (*icmpPinger, error) {
+	p := &icmpPinger{
+		dst: dst,
+		v4:  dst.To4() != nil,
+	}
+	network, rawNetwork, address := "udp6", "ip6:ipv6-icmp", "::"
+	if p.v4 {
+		network, rawNetwork, address = "udp4", "ip4:icmp", "0.0.0.0"
+	}
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		var rawErr error
+		conn, rawErr = icmp.ListenPacket(rawNetwork, address)
+		if rawErr != nil {
+			return nil, err
+		}
+		p.privileged = true
+	}
+	p.conn = conn
+	return p, nil
+}
+
+func (p *icmpPinger) Send(request []byte) error {
+	if !isEchoRequest(request, p.v4) {
+		return newError("not an echo request")
+	}
+	p.access.Lock()
+	p.id = binary.BigEndian.Uint16(request[4:6])
+	p.access.Unlock()
+
+	var addr net.Addr = &net.UDPAddr{IP: p.dst}
+	if p.privileged {
+		addr = &net.IPAddr{IP: p.dst}
+	}
+	_, err := p.conn.WriteTo(request, addr)
+	return err
+}
+
+func (p *icmpPinger) Receive() ([]byte, error) {
+	b := make([]byte, buf.Size)
+	for {
+		n, addr, err := p.conn.ReadFrom(b)
+		if err != nil {
+			if p.closed.Load() {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		var from net.IP
+		switch addr := addr.(type) {
+		case *net.UDPAddr:
+			from = addr.IP
+		case *net.IPAddr:
+			from = addr.IP
+		}
+		reply := b[:n]
+		if !from.Equal(p.dst) || len(reply) < 8 {
+			continue
+		}
+		if (p.v4 && reply[0] != icmpv4EchoReply) || (!p.v4 && reply[0] != icmpv6EchoReply) {
+			continue
+		}
+		p.access.Lock()
+		id := p.id
+		p.access.Unlock()
+		// raw sockets see the replies of everyone
+		if p.privileged && binary.BigEndian.Uint16(reply[4:6]) != id {
+			continue
+		}
+		binary.BigEndian.PutUint16(reply[4:6], id)
+		return reply, nil
+	}
+}
+
+func (p *icmpPinger) Close() error {
+	if p.closed.Swap(true) {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+// fakePinger answers every echo request itself after fakeEchoLatency.
+type fakePinger struct {
+	v4        bool
+	replies   chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakePinger(v4 bool) *fakePinger {
+	return &fakePinger{
+		v4:      v4,
+		replies: make(chan []byte, 16),
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *fakePinger) Send(request []byte) error {
+	if !isEchoRequest(request, p.v4) {
+		return newError("not an echo request")
+	}
+	reply := append([]byte(nil), request...)
+	if p.v4 {
+		reply[0] = icmpv4EchoReply
+	} else {
+		reply[0] = icmpv6EchoReply
+	}
+	time.AfterFunc(fakeEchoLatency, func() {
+		select {
+		case p.replies <- reply:
+		case <-p.done:
+		}
+	})
+	return nil
+}
+
+func (p *fakePinger) Receive() ([]byte, error) {
+	select {
+	case reply := <-p.replies:
+		return reply, nil
+	case <-p.done:
+		return nil, io.EOF
+	}
+}
+
+func (p *fakePinger) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
@@ -0,0 +1,83 @@
+package freedom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+)
+
+func echoRequest(id, seq uint16, payload []byte) []byte {
+	b := make([]byte, 8+len(payload))
+	b[0] = icmpv4EchoRequest
+	binary.BigEndian.PutUint16(b[4:6], id)
+	binary.BigEndian.PutUint16(b[6:8], seq)
+	copy(b[8:], payload)
+	sum := uint32(0)
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	binary.BigEndian.PutUint16(b[2:4], ^uint16(sum))
+	return b
+}
+
+func checkEchoReply(t *testing.T, reply []byte, id, seq uint16, payload []byte) {
+	t.Helper()
+	if len(reply) < 8 || reply[0] != icmpv4EchoReply {
+		t.Fatal("not an echo reply: ", reply)
+	}
+	if binary.BigEndian.Uint16(reply[4:6]) != id || binary.BigEndian.Uint16(reply[6:8]) != seq {
+		t.Error("unexpected id or sequence: ", reply[4:8])
+	}
+	if !bytes.Equal(reply[8:], payload) {
+		t.Error("unexpected payload: ", reply[8:])
+	}
+}
+
+func TestFakePinger(t *testing.T) {
+	p := newFakePinger(true)
+	defer p.Close()
+
+	payload := []byte("xray ping")
+	start := time.Now()
+	common.Must(p.Send(echoRequest(1234, 1, payload)))
+	reply, err := p.Receive()
+	common.Must(err)
+	if d := time.Since(start); d < fakeEchoLatency {
+		t.Error("reply came too early: ", d)
+	}
+	checkEchoReply(t, reply, 1234, 1, payload)
+
+	if err := p.Send([]byte{icmpv4EchoReply, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("expect error for non echo request")
+	}
+
+	p.Close()
+	if _, err := p.Receive(); err == nil {
+		t.Error("expect error after close")
+	}
+}
+
+func TestICMPPinger(t *testing.T) {
+	p, err := newICMPPinger(net.IP{127, 0, 0, 1})
+	if err != nil {
+		t.Skip("ICMP sockets are not available: ", err)
+	}
+	defer p.Close()
+
+	payload := []byte("xray ping")
+	common.Must(p.Send(echoRequest(4321, 7, payload)))
+	common.Must(p.conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	reply, err := p.Receive()
+	common.Must(err)
+	checkEchoReply(t, reply, 4321, 7, payload)
+}
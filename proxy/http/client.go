@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
@@ -30,9 +31,10 @@ import (
 )
 
 type Client struct {
-	serverPicker  protocol.ServerPicker
-	policyManager policy.Manager
-	header        []*Header
+	serverPicker    protocol.ServerPicker
+	policyManager   policy.Manager
+	header          []*Header
+	retryIdempotent bool
 }
 
 type h2Conn struct {
@@ -61,16 +63,17 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 
 	v := core.MustFromContext(ctx)
 	return &Client{
-		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
-		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
-		header:        config.Header,
+		serverPicker:    protocol.NewRoundRobinServerPicker(serverList),
+		policyManager:   v.GetFeature(policy.ManagerType()).(policy.Manager),
+		header:          config.Header,
+		retryIdempotent: config.RetryIdempotent,
 	}, nil
 }
 
 // Process implements proxy.Outbound.Process. We first create a socket tunnel via HTTP CONNECT method, then redirect all inbound traffic to that tunnel.
 func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	if !ob.Target.IsValid() {
 		return newError("target not specified.")
 	}
@@ -100,23 +103,26 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		return newError("failed to fill out header").Base(err)
 	}
 
-	if err := retry.ExponentialBackoff(5, 100).On(func() error {
-		server := c.serverPicker.PickServer()
-		dest := server.Destination()
-		user = server.PickUser()
-
-		netConn, err := setUpHTTPTunnel(ctx, dest, targetAddr, user, dialer, header, firstPayload)
-		if netConn != nil {
-			if _, ok := netConn.(*http2Conn); !ok {
-				if _, err := netConn.Write(firstPayload); err != nil {
-					netConn.Close()
-					return err
+	dial := func() error {
+		return retry.ExponentialBackoff(5, 100).On(func() error {
+			server := c.serverPicker.PickServer()
+			dest := server.Destination()
+			user = server.PickUser()
+
+			netConn, err := setUpHTTPTunnel(ctx, dest, targetAddr, user, dialer, header, firstPayload)
+			if netConn != nil {
+				if _, ok := netConn.(*http2Conn); !ok {
+					if _, err := netConn.Write(firstPayload); err != nil {
+						netConn.Close()
+						return err
+					}
 				}
+				conn = stat.Connection(netConn)
 			}
-			conn = stat.Connection(netConn)
-		}
-		return err
-	}); err != nil {
+			return err
+		})
+	}
+	if err := dial(); err != nil {
 		return newError("failed to find an available destination").Base(err)
 	}
 
@@ -145,12 +151,36 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		}
 	}, p.Timeouts.ConnectionIdle)
 
+	var firstResponse buf.MultiBuffer
+	if c.retryIdempotent && isIdempotentRequest(firstPayload) {
+		start := time.Now()
+		firstResponse, err = readFirstResponse(ctx, conn)
+		// a connection lost within the handshake timeout of the request is taken for a stale one
+		if err != nil && ctx.Err() == nil && time.Since(start) < p.Timeouts.Handshake {
+			newError("connection lost before response, sending request again").Base(err).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			lost := conn
+			if err = dial(); err == nil {
+				lost.Close()
+				firstResponse, err = readFirstResponse(ctx, conn)
+			}
+		}
+		if err != nil {
+			return newError("failed to read response").Base(err)
+		}
+		timer.Update()
+	}
+
 	requestFunc := func() error {
 		defer timer.SetTimeout(p.Timeouts.DownlinkOnly)
 		return buf.Copy(link.Reader, buf.NewWriter(conn), buf.UpdateActivity(timer))
 	}
 	responseFunc := func() error {
 		defer timer.SetTimeout(p.Timeouts.UplinkOnly)
+		if !firstResponse.IsEmpty() {
+			if err := link.Writer.WriteMultiBuffer(firstResponse); err != nil {
+				return err
+			}
+		}
 		return buf.Copy(buf.NewReader(conn), link.Writer, buf.UpdateActivity(timer))
 	}
 
@@ -166,6 +196,38 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	return nil
 }
 
+// isIdempotentRequest checks whether payload is exactly one complete GET or
+// HEAD request, which is safe to send again.
+func isIdempotentRequest(payload []byte) bool {
+	r := bytes.NewReader(payload)
+	reader := bufio.NewReader(r)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.ContentLength == 0 && reader.Buffered() == 0 && r.Len() == 0
+}
+
+// readFirstResponse reads the beginning of the response from conn, conn is
+// closed if ctx is done before anything arrives.
+func readFirstResponse(ctx context.Context, conn net.Conn) (buf.MultiBuffer, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	return buf.NewReader(conn).ReadMultiBuffer()
+}
+
 // fillRequestHeader will fill out the template of the headers
 func fillRequestHeader(ctx context.Context, header []*Header) ([]*Header, error) {
 	if len(header) == 0 {
@@ -174,7 +236,7 @@ func fillRequestHeader(ctx context.Context, header []*Header) ([]*Header, error)
 
 	inbound := session.InboundFromContext(ctx)
 	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 
 	if inbound == nil || ob == nil {
 		return nil, newError("missing inbound or outbound metadata from context")
@@ -22,6 +22,7 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport/internet/stat"
 )
 
@@ -29,6 +30,7 @@ import (
 type Server struct {
 	config        *ServerConfig
 	policyManager policy.Manager
+	stats         stats.Manager
 }
 
 // NewServer creates a new HTTP inbound handler.
@@ -37,14 +39,15 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 	s := &Server{
 		config:        config,
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
+		stats:         v.GetFeature(stats.ManagerType()).(stats.Manager),
 	}
 
 	return s, nil
 }
 
-func (s *Server) policy() policy.Session {
+func (s *Server) policy(ctx context.Context) policy.Session {
 	config := s.config
-	p := s.policyManager.ForLevel(config.UserLevel)
+	p := policy.ForContext(ctx, s.policyManager, config.UserLevel)
 	if config.Timeout > 0 && config.UserLevel == 0 {
 		p.Timeouts.ConnectionIdle = time.Duration(config.Timeout) * time.Second
 	}
@@ -93,7 +96,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	reader := bufio.NewReaderSize(readerOnly{conn}, buf.Size)
 
 Start:
-	if err := conn.SetReadDeadline(time.Now().Add(s.policy().Timeouts.Handshake)); err != nil {
+	if err := conn.SetReadDeadline(time.Now().Add(s.policy(ctx).Timeouts.Handshake)); err != nil {
 		newError("failed to set read deadline").Base(err).WriteToLog(session.ExportIDToError(ctx))
 	}
 
@@ -133,6 +136,10 @@ Start:
 	if err != nil {
 		return newError("malformed proxy host: ", host).AtWarning().Base(err)
 	}
+	if err := policy.CheckTarget(ctx, s.stats, dest); err != nil {
+		conn.Write([]byte("HTTP/1.1 403 Forbidden\r\nConnection: close\r\n\r\n"))
+		return err
+	}
 	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
 		From:   conn.RemoteAddr(),
 		To:     request.URL,
@@ -163,7 +170,7 @@ func (s *Server) handleConnect(ctx context.Context, _ *http.Request, reader *buf
 		return newError("failed to write back OK response").Base(err)
 	}
 
-	plcy := s.policy()
+	plcy := s.policy(ctx)
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
 
@@ -112,7 +112,13 @@ func (l *Loopback) init(config *Config, dispatcherInstance routing.Dispatcher) e
 	return nil
 }
 
+// declareTag declares the inbound tag the loopback sends connections with.
+func declareTag(v *core.Validation, _ string, c interface{}) {
+	v.AddInboundTag(c.(*Config).InboundTag)
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), declareTag))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		l := new(Loopback)
 		err := core.RequireFeatures(ctx, func(dispatcherInstance routing.Dispatcher) error {
@@ -65,6 +65,14 @@ type Inbound interface {
 	Process(context.Context, net.Network, stat.Connection, routing.Dispatcher) error
 }
 
+// A DeviceInbound is an Inbound that takes traffic from a local device instead of accepting connections on ports.
+type DeviceInbound interface {
+	Inbound
+
+	// Serve forwards the traffic of the device through the Dispatcher until the context is cancelled.
+	Serve(context.Context, routing.Dispatcher) error
+}
+
 // An Outbound process outbound connections.
 type Outbound interface {
 	// Process processes the given connection. The given dialer may be used to dial a system outbound connection.
@@ -88,6 +96,34 @@ type GetOutbound interface {
 	GetOutbound() Outbound
 }
 
+// Selector is the interface for outbounds that pass connections on to another outbound, selected at runtime.
+type Selector interface {
+	// Select makes new connections go to the outbound of the given tag, which must be one of the candidates.
+	Select(tag string) error
+	// Selected returns the tag of the selected outbound, and the tags of all candidates.
+	Selected() (string, []string)
+}
+
+// PeerStatus is the status of a peer of a proxy, as told by the peer's device.
+type PeerStatus struct {
+	PublicKey string
+	Endpoint  string
+	// LastHandshake is zero if no handshake has completed.
+	LastHandshake time.Time
+	RxBytes       uint64
+	TxBytes       uint64
+}
+
+// PeerReporter is the interface for proxies with peers of their own, such as WireGuard.
+type PeerReporter interface {
+	// Peers returns the status of the peers, and refreshes their stats.
+	Peers() ([]*PeerStatus, error)
+}
+
+// DefaultVisionPlainThreshold is the number of bytes Vision looks for a TLS handshake in before it
+// relays a connection plainly.
+const DefaultVisionPlainThreshold = 2048
+
 // TrafficState is used to track uplink and downlink of one connection
 // It is used by XTLS to determine if switch to raw copy mode, It is used by Vision to calculate padding
 type TrafficState struct {
@@ -99,10 +135,15 @@ type TrafficState struct {
 	Cipher                 uint16
 	RemainingServerHello   int32
 
+	// PlainThreshold is the number of bytes filtered without a TLS handshake after which the connection
+	// is relayed plainly, with neither padding nor filtering. 0 to filter by NumberOfPacketToFilter only.
+	PlainThreshold int64
+	FilteredBytes  int64
+
 	// reader link state
 	WithinPaddingBuffers     bool
 	ReaderSwitchToDirectCopy bool
-	RemainingCommand		 int32
+	RemainingCommand         int32
 	RemainingContent         int32
 	RemainingPadding         int32
 	CurrentCommand           int
@@ -110,12 +151,15 @@ type TrafficState struct {
 	// write link state
 	IsPadding                bool
 	WriterSwitchToDirectCopy bool
+
+	Stats VisionStats
 }
 
 func NewTrafficState(userUUID []byte) *TrafficState {
 	return &TrafficState{
 		UserUUID:                 userUUID,
 		NumberOfPacketToFilter:   8,
+		PlainThreshold:           DefaultVisionPlainThreshold,
 		EnableXtls:               false,
 		IsTLS12orAbove:           false,
 		IsTLS:                    false,
@@ -132,6 +176,15 @@ func NewTrafficState(userUUID []byte) *TrafficState {
 	}
 }
 
+// RelayPlain stops filtering the connection, and padding it once what is being written is done, as it
+// doesn't carry TLS.
+func (s *TrafficState) RelayPlain(ctx context.Context, reason ...interface{}) {
+	s.NumberOfPacketToFilter = 0
+	if s.Stats.relay.CompareAndSwap(int32(VisionRelayUndecided), int32(VisionRelayPlain)) {
+		newError(append([]interface{}{"Vision relays plainly: "}, reason...)...).WriteToLog(session.ExportIDToError(ctx))
+	}
+}
+
 // VisionReader is used to read xtls vision protocol
 // Note Vision probably only make sense as the inner most layer of reader, since it need assess traffic state from origin proxy traffic
 type VisionReader struct {
@@ -167,6 +220,7 @@ func (w *VisionReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
 			} else if w.trafficState.CurrentCommand == 2 {
 				w.trafficState.WithinPaddingBuffers = false
 				w.trafficState.ReaderSwitchToDirectCopy = true
+				w.trafficState.Stats.readerDirectCopy.CompareAndSwap(0, time.Now().UnixNano())
 			} else {
 				newError("XtlsRead unknown command ", w.trafficState.CurrentCommand, buffer.Len()).WriteToLog(session.ExportIDToError(w.ctx))
 			}
@@ -204,7 +258,7 @@ func (w *VisionWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 	}
 	if w.trafficState.IsPadding {
 		if len(mb) == 1 && mb[0] == nil {
-			mb[0] = XtlsPadding(nil, CommandPaddingContinue, &w.writeOnceUserUUID, true, w.ctx) // we do a long padding to hide vless header
+			mb[0] = w.padding(nil, CommandPaddingContinue, true) // we do a long padding to hide vless header
 			return w.Writer.WriteMultiBuffer(mb)
 		}
 		mb = ReshapeMultiBuffer(w.ctx, mb)
@@ -213,36 +267,45 @@ func (w *VisionWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 			if w.trafficState.IsTLS && b.Len() >= 6 && bytes.Equal(TlsApplicationDataStart, b.BytesTo(3)) {
 				if w.trafficState.EnableXtls {
 					w.trafficState.WriterSwitchToDirectCopy = true
+					w.trafficState.Stats.writerDirectCopy.CompareAndSwap(0, time.Now().UnixNano())
 				}
 				var command byte = CommandPaddingContinue
-				if i == len(mb) - 1 {
+				if i == len(mb)-1 {
 					command = CommandPaddingEnd
 					if w.trafficState.EnableXtls {
 						command = CommandPaddingDirect
 					}
 				}
-				mb[i] = XtlsPadding(b, command, &w.writeOnceUserUUID, true, w.ctx)
+				mb[i] = w.padding(b, command, true)
 				w.trafficState.IsPadding = false // padding going to end
 				longPadding = false
 				continue
 			} else if !w.trafficState.IsTLS12orAbove && w.trafficState.NumberOfPacketToFilter <= 1 { // For compatibility with earlier vision receiver, we finish padding 1 packet early
 				w.trafficState.IsPadding = false
-				mb[i] = XtlsPadding(b, CommandPaddingEnd, &w.writeOnceUserUUID, longPadding, w.ctx)
+				mb[i] = w.padding(b, CommandPaddingEnd, longPadding)
 				break
 			}
 			var command byte = CommandPaddingContinue
-			if i == len(mb) - 1 && !w.trafficState.IsPadding {
+			if i == len(mb)-1 && !w.trafficState.IsPadding {
 				command = CommandPaddingEnd
 				if w.trafficState.EnableXtls {
 					command = CommandPaddingDirect
 				}
 			}
-			mb[i] = XtlsPadding(b, command, &w.writeOnceUserUUID, longPadding, w.ctx)
+			mb[i] = w.padding(b, command, longPadding)
 		}
 	}
 	return w.Writer.WriteMultiBuffer(mb)
 }
 
+// padding pads b with XtlsPadding, and counts it to the traffic state.
+func (w *VisionWriter) padding(b *buf.Buffer, command byte, longPadding bool) *buf.Buffer {
+	withUUID := len(w.writeOnceUserUUID) > 0
+	b = XtlsPadding(b, command, &w.writeOnceUserUUID, longPadding, w.ctx)
+	w.trafficState.Stats.countPadding(b, withUUID)
+	return b
+}
+
 // ReshapeMultiBuffer prepare multi buffer for padding stucture (max 21 bytes)
 func ReshapeMultiBuffer(ctx context.Context, buffer buf.MultiBuffer) buf.MultiBuffer {
 	needReshape := 0
@@ -338,13 +401,15 @@ func XtlsUnpadding(b *buf.Buffer, s *TrafficState, ctx context.Context) *buf.Buf
 			case 5:
 				s.CurrentCommand = int(data)
 			case 4:
-				s.RemainingContent = int32(data)<<8
+				s.RemainingContent = int32(data) << 8
 			case 3:
 				s.RemainingContent = s.RemainingContent | int32(data)
 			case 2:
-				s.RemainingPadding = int32(data)<<8
+				s.RemainingPadding = int32(data) << 8
 			case 1:
 				s.RemainingPadding = s.RemainingPadding | int32(data)
+				s.Stats.UnpaddedPackets.Add(1)
+				s.Stats.UnpaddedBytes.Add(int64(s.RemainingPadding))
 				newError("Xtls Unpadding new block, content ", s.RemainingContent, " padding ", s.RemainingPadding, " command ", s.CurrentCommand).WriteToLog(session.ExportIDToError(ctx))
 			}
 			s.RemainingCommand--
@@ -393,12 +458,14 @@ func XtlsFilterTls(buffer buf.MultiBuffer, trafficState *TrafficState, ctx conte
 			continue
 		}
 		trafficState.NumberOfPacketToFilter--
+		trafficState.FilteredBytes += int64(b.Len())
 		if b.Len() >= 6 {
 			startsBytes := b.BytesTo(6)
 			if bytes.Equal(TlsServerHandShakeStart, startsBytes[:3]) && startsBytes[5] == TlsHandshakeTypeServerHello {
 				trafficState.RemainingServerHello = (int32(startsBytes[3])<<8 | int32(startsBytes[4])) + 5
 				trafficState.IsTLS12orAbove = true
 				trafficState.IsTLS = true
+				trafficState.Stats.relay.CompareAndSwap(int32(VisionRelayUndecided), int32(VisionRelayTLS))
 				if b.Len() >= 79 && trafficState.RemainingServerHello >= 79 {
 					sessionIdLen := int32(b.Byte(43))
 					cipherSuite := b.BytesRange(43+sessionIdLen+1, 43+sessionIdLen+3)
@@ -408,6 +475,7 @@ func XtlsFilterTls(buffer buf.MultiBuffer, trafficState *TrafficState, ctx conte
 				}
 			} else if bytes.Equal(TlsClientHandShakeStart, startsBytes[:2]) && startsBytes[5] == TlsHandshakeTypeClientHello {
 				trafficState.IsTLS = true
+				trafficState.Stats.relay.CompareAndSwap(int32(VisionRelayUndecided), int32(VisionRelayTLS))
 				newError("XtlsFilterTls found tls client hello! ", buffer.Len()).WriteToLog(session.ExportIDToError(ctx))
 			}
 		}
@@ -434,8 +502,15 @@ func XtlsFilterTls(buffer buf.MultiBuffer, trafficState *TrafficState, ctx conte
 			}
 			newError("XtlsFilterTls inconclusive server hello ", b.Len(), " ", trafficState.RemainingServerHello).WriteToLog(session.ExportIDToError(ctx))
 		}
+		if !trafficState.IsTLS && trafficState.PlainThreshold > 0 && trafficState.FilteredBytes >= trafficState.PlainThreshold {
+			trafficState.RelayPlain(ctx, "no tls handshake in ", trafficState.FilteredBytes, " bytes")
+			return
+		}
 		if trafficState.NumberOfPacketToFilter <= 0 {
 			newError("XtlsFilterTls stop filtering", buffer.Len()).WriteToLog(session.ExportIDToError(ctx))
+			if !trafficState.IsTLS {
+				trafficState.RelayPlain(ctx, "no tls handshake in ", trafficState.FilteredBytes, " bytes of the first packets")
+			}
 		}
 	}
 }
@@ -508,7 +583,7 @@ func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net
 			newError("CopyRawConn splice").WriteToLog(session.ExportIDToError(ctx))
 			statWriter, _ := writer.(*dispatcher.SizeStatWriter)
 			//runtime.Gosched() // necessary
-			time.Sleep(time.Millisecond) // without this, there will be a rare ssl error for freedom splice
+			time.Sleep(time.Millisecond)    // without this, there will be a rare ssl error for freedom splice
 			timer.SetTimeout(8 * time.Hour) // prevent leak, just in case
 			if inTimer != nil {
 				inTimer.SetTimeout(8 * time.Hour)
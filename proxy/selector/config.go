@@ -0,0 +1,3 @@
+package selector
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
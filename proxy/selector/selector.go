@@ -0,0 +1,158 @@
+package selector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// Handler is an outbound that passes connections on to the outbound selected among its candidates.
+type Handler struct {
+	candidates []string
+	stateFile  string
+	ohm        outbound.Manager
+
+	access   sync.RWMutex
+	selected string
+}
+
+// New creates a new selector outbound.
+func New(ctx context.Context, config *Config) (*Handler, error) {
+	if len(config.Candidates) == 0 {
+		return nil, newError("no candidates in selector")
+	}
+	h := &Handler{
+		candidates: config.Candidates,
+		stateFile:  config.StateFile,
+		selected:   config.Default,
+	}
+	if len(h.selected) == 0 {
+		h.selected = h.candidates[0]
+	}
+	if !h.isCandidate(h.selected) {
+		return nil, newError("default outbound ", h.selected, " is not a candidate")
+	}
+	if len(h.stateFile) > 0 {
+		if tag, err := h.loadState(); err != nil {
+			newError("failed to load selection from ", h.stateFile).Base(err).AtWarning().WriteToLog()
+		} else if len(tag) > 0 {
+			h.selected = tag
+		}
+	}
+
+	if err := core.RequireFeatures(ctx, func(ohm outbound.Manager) {
+		h.ohm = ohm
+	}); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *Handler) isCandidate(tag string) bool {
+	for _, candidate := range h.candidates {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// loadState returns the tag kept in the state file, or empty if there is none.
+func (h *Handler) loadState() (string, error) {
+	b, err := os.ReadFile(h.stateFile)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	tag := strings.TrimSpace(string(b))
+	if !h.isCandidate(tag) {
+		return "", newError("outbound ", tag, " is not a candidate")
+	}
+	return tag, nil
+}
+
+// saveState writes the tag into the state file, replacing it as a whole.
+func (h *Handler) saveState(tag string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(h.stateFile), filepath.Base(h.stateFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(tag + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), h.stateFile)
+}
+
+// Select implements proxy.Selector.
+func (h *Handler) Select(tag string) error {
+	if !h.isCandidate(tag) {
+		return newError("outbound ", tag, " is not a candidate")
+	}
+
+	h.access.Lock()
+	defer h.access.Unlock()
+
+	if len(h.stateFile) > 0 && tag != h.selected {
+		if err := h.saveState(tag); err != nil {
+			return newError("failed to save selection to ", h.stateFile).Base(err)
+		}
+	}
+	h.selected = tag
+	return nil
+}
+
+// Selected implements proxy.Selector.
+func (h *Handler) Selected() (string, []string) {
+	h.access.RLock()
+	defer h.access.RUnlock()
+
+	return h.selected, h.candidates
+}
+
+// RelaysICMP implements outbound.ICMPRelay.
+func (h *Handler) RelaysICMP() bool {
+	tag, _ := h.Selected()
+	handler := h.ohm.GetHandler(tag)
+	return handler != nil && outbound.RelaysICMP(handler)
+}
+
+// Process implements proxy.Outbound.
+func (h *Handler) Process(ctx context.Context, link *transport.Link, _ internet.Dialer) error {
+	tag, _ := h.Selected()
+	outbounds := session.OutboundsFromContext(ctx)
+	ob := outbounds[len(outbounds)-1]
+	if tag == ob.Tag {
+		return newError("selector ", tag, " selects itself")
+	}
+
+	handler := h.ohm.GetHandler(tag)
+	if handler == nil {
+		return newError("selected outbound ", tag, " not found")
+	}
+	newError("taking selected outbound [", tag, "] for [", ob.Target, "]").WriteToLog(session.ExportIDToError(ctx))
+	ob.Tag = tag
+	handler.Dispatch(ctx, link)
+	return nil
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
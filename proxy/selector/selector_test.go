@@ -0,0 +1,187 @@
+package selector_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	. "github.com/xtls/xray-core/proxy/selector"
+	"github.com/xtls/xray-core/transport"
+)
+
+const xrayKey core.XrayKey = 1
+
+// testHandler records the connections dispatched to it, and holds each until it is released.
+type testHandler struct {
+	tag     string
+	release chan struct{}
+
+	access     sync.Mutex
+	dispatched int
+	started    chan struct{}
+}
+
+func newTestHandler(tag string) *testHandler {
+	return &testHandler{
+		tag:     tag,
+		release: make(chan struct{}),
+		started: make(chan struct{}, 8),
+	}
+}
+
+func (h *testHandler) Tag() string  { return h.tag }
+func (h *testHandler) Start() error { return nil }
+func (h *testHandler) Close() error { return nil }
+
+func (h *testHandler) Dispatch(ctx context.Context, link *transport.Link) {
+	h.access.Lock()
+	h.dispatched++
+	h.access.Unlock()
+	h.started <- struct{}{}
+	<-h.release
+}
+
+func (h *testHandler) count() int {
+	h.access.Lock()
+	defer h.access.Unlock()
+	return h.dispatched
+}
+
+type testManager struct {
+	handlers map[string]outbound.Handler
+}
+
+func (m *testManager) Type() interface{}                           { return outbound.ManagerType() }
+func (m *testManager) Start() error                                { return nil }
+func (m *testManager) Close() error                                { return nil }
+func (m *testManager) GetHandler(tag string) outbound.Handler      { return m.handlers[tag] }
+func (m *testManager) GetDefaultHandler() outbound.Handler         { return nil }
+func (m *testManager) RemoveHandler(context.Context, string) error { return nil }
+func (m *testManager) AddHandler(ctx context.Context, h outbound.Handler) error {
+	m.handlers[h.Tag()] = h
+	return nil
+}
+
+func newContext(t *testing.T, handlers ...outbound.Handler) context.Context {
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	m := &testManager{handlers: make(map[string]outbound.Handler)}
+	for _, h := range handlers {
+		common.Must(m.AddHandler(context.Background(), h))
+	}
+	common.Must(v.AddFeature(m))
+	return context.WithValue(context.Background(), xrayKey, v)
+}
+
+func process(ctx context.Context, selector *Handler) <-chan error {
+	ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{
+		Tag:    "selector",
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 443),
+	}})
+	done := make(chan error, 1)
+	go func() {
+		done <- selector.Process(ctx, &transport.Link{}, nil)
+	}()
+	return done
+}
+
+func TestSelectorSwitch(t *testing.T) {
+	a, b := newTestHandler("a"), newTestHandler("b")
+	ctx := newContext(t, a, b)
+	selector, err := New(ctx, &Config{Candidates: []string{"a", "b"}})
+	common.Must(err)
+
+	inFlight := process(ctx, selector)
+	<-a.started
+
+	common.Must(selector.Select("b"))
+	if selected, _ := selector.Selected(); selected != "b" {
+		t.Fatal("expect b selected, but got ", selected)
+	}
+
+	next := process(ctx, selector)
+	<-b.started
+	close(b.release)
+	common.Must(<-next)
+
+	select {
+	case err := <-inFlight:
+		t.Fatal("expect the connection on a to be in flight, but it ended with ", err)
+	default:
+	}
+	close(a.release)
+	common.Must(<-inFlight)
+
+	if a.count() != 1 || b.count() != 1 {
+		t.Error("expect one connection on each outbound, but got ", a.count(), " and ", b.count())
+	}
+}
+
+func TestSelectorInvalid(t *testing.T) {
+	ctx := newContext(t, newTestHandler("a"))
+
+	if _, err := New(ctx, &Config{}); err == nil {
+		t.Error("expect error without candidates")
+	}
+	if _, err := New(ctx, &Config{Candidates: []string{"a"}, Default: "b"}); err == nil {
+		t.Error("expect error on a default that is not a candidate")
+	}
+
+	selector, err := New(ctx, &Config{Candidates: []string{"a", "missing"}})
+	common.Must(err)
+	if err := selector.Select("b"); err == nil {
+		t.Error("expect error selecting a tag that is not a candidate")
+	}
+	if selected, _ := selector.Selected(); selected != "a" {
+		t.Error("expect a still selected, but got ", selected)
+	}
+
+	common.Must(selector.Select("missing"))
+	if err := <-process(ctx, selector); err == nil {
+		t.Error("expect error selecting an outbound that doesn't exist")
+	}
+}
+
+func TestSelectorStateFile(t *testing.T) {
+	ctx := newContext(t)
+	stateFile := filepath.Join(t.TempDir(), "selected")
+	config := &Config{
+		Candidates: []string{"a", "b", "c"},
+		Default:    "b",
+		StateFile:  stateFile,
+	}
+
+	selector, err := New(ctx, config)
+	common.Must(err)
+	if selected, _ := selector.Selected(); selected != "b" {
+		t.Fatal("expect the default selected without a state file, but got ", selected)
+	}
+	common.Must(selector.Select("c"))
+
+	selector, err = New(ctx, config)
+	common.Must(err)
+	if selected, _ := selector.Selected(); selected != "c" {
+		t.Error("expect the selection restored, but got ", selected)
+	}
+
+	common.Must(os.WriteFile(stateFile, []byte("removed\n"), 0o600))
+	selector, err = New(ctx, config)
+	common.Must(err)
+	if selected, _ := selector.Selected(); selected != "b" {
+		t.Error("expect the default selected on a stale state file, but got ", selected)
+	}
+
+	b, err := os.ReadFile(stateFile)
+	common.Must(err)
+	if strings.TrimSpace(string(b)) != "removed" {
+		t.Error("expect the state file untouched until a selection, but got ", string(b))
+	}
+}
@@ -20,11 +20,20 @@ import (
 // MemoryAccount is an account type converted from Account.
 type MemoryAccount struct {
 	Cipher Cipher
-	Key    []byte
+	// Fallback is accepted by servers in addition to Cipher. It is only set
+	// for the auto cipher type, and shares key and IV size with Cipher.
+	Fallback    *AEADCipher
+	Key         []byte
+	TargetPorts *protocol.PortRestriction
 
 	replayFilter antireplay.GeneralizedReplayFilter
 }
 
+// PortRestriction implements protocol.PortRestricter.
+func (a *MemoryAccount) PortRestriction() *protocol.PortRestriction {
+	return a.TargetPorts
+}
+
 var ErrIVNotUnique = newError("IV is not unique")
 
 // Equals implements protocol.Account.Equals().
@@ -45,6 +54,15 @@ func (a *MemoryAccount) CheckIV(iv []byte) error {
 	return ErrIVNotUnique
 }
 
+// withFallbackCipher returns a copy of the user that speaks the fallback cipher of its account.
+func withFallbackCipher(user *protocol.MemoryUser) *protocol.MemoryUser {
+	account := *user.Account.(*MemoryAccount)
+	account.Cipher, account.Fallback = account.Fallback, nil
+	u := *user
+	u.Account = &account
+	return &u
+}
+
 func createAesGcm(key []byte) cipher.AEAD {
 	block, err := aes.NewCipher(key)
 	common.Must(err)
@@ -65,6 +83,25 @@ func createXChaCha20Poly1305(key []byte) cipher.AEAD {
 	return XChaChaPoly1305
 }
 
+// autoCiphers returns the cipher preferred on this machine for the auto
+// cipher type, and the other one.
+func autoCiphers() (preferred *AEADCipher, fallback *AEADCipher) {
+	aes := &AEADCipher{
+		KeyBytes:        32,
+		IVBytes:         32,
+		AEADAuthCreator: createAesGcm,
+	}
+	chacha := &AEADCipher{
+		KeyBytes:        32,
+		IVBytes:         32,
+		AEADAuthCreator: createChaCha20Poly1305,
+	}
+	if protocol.HasAESGCMHardwareSupport() {
+		return aes, chacha
+	}
+	return chacha, aes
+}
+
 func (a *Account) getCipher() (Cipher, error) {
 	switch a.CipherType {
 	case CipherType_AES_128_GCM:
@@ -85,6 +122,9 @@ func (a *Account) getCipher() (Cipher, error) {
 			IVBytes:         32,
 			AEADAuthCreator: createChaCha20Poly1305,
 		}, nil
+	case CipherType_AUTO:
+		preferred, _ := autoCiphers()
+		return preferred, nil
 	case CipherType_XCHACHA20_POLY1305:
 		return &AEADCipher{
 			KeyBytes:        32,
@@ -104,9 +144,16 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 	if err != nil {
 		return nil, newError("failed to get cipher").Base(err)
 	}
+	var fallback *AEADCipher
+	if a.CipherType == CipherType_AUTO {
+		_, fallback = autoCiphers()
+	}
 	return &MemoryAccount{
-		Cipher: Cipher,
-		Key:    passwordToCipherKey([]byte(a.Password), Cipher.KeySize()),
+		Cipher:   Cipher,
+		Fallback: fallback,
+		Key:      passwordToCipherKey([]byte(a.Password), Cipher.KeySize()),
+
+		TargetPorts: protocol.NewPortRestriction(a.AllowedTargetPorts, a.BlockedTargetPorts),
 		replayFilter: func() antireplay.GeneralizedReplayFilter {
 			if a.IvCheck {
 				return antireplay.NewBloomRing()
@@ -236,3 +236,97 @@ func TestUDPReaderWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestAutoCipher(t *testing.T) {
+	serverUser := &protocol.MemoryUser{
+		Email: "love@example.com",
+		Account: toAccount(&Account{
+			Password:   "password",
+			CipherType: CipherType_AUTO,
+		}),
+	}
+	validator := new(Validator)
+	common.Must(validator.Add(serverUser))
+
+	newRequest := func(command protocol.RequestCommand, password string, cipherType CipherType) *protocol.RequestHeader {
+		return &protocol.RequestHeader{
+			Version: Version,
+			Command: command,
+			Address: net.DomainAddress("example.com"),
+			Port:    1234,
+			User: &protocol.MemoryUser{
+				Email: "love@example.com",
+				Account: toAccount(&Account{
+					Password:   password,
+					CipherType: cipherType,
+				}),
+			},
+		}
+	}
+	payload := []byte("test string")
+
+	for _, cipherType := range []CipherType{CipherType_AES_256_GCM, CipherType_CHACHA20_POLY1305} {
+		request := newRequest(protocol.RequestCommandTCP, "password", cipherType)
+		cache := buf.New()
+		writer, err := WriteTCPRequest(request, cache)
+		common.Must(err)
+		common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{buf.FromBytes(append([]byte(nil), payload...))}))
+
+		decodedRequest, reader, err := ReadTCPSession(validator, cache)
+		if err != nil {
+			t.Fatal(cipherType, ": ", err)
+		}
+		if decodedRequest.User.Email != serverUser.Email {
+			t.Error(cipherType, ": unexpected user ", decodedRequest.User.Email)
+		}
+		decodedData, err := reader.ReadMultiBuffer()
+		common.Must(err)
+		if r := cmp.Diff(decodedData[0].Bytes(), payload); r != "" {
+			t.Error(cipherType, ": request data: ", r)
+		}
+
+		// the response must use the cipher the client has chosen
+		response := buf.New()
+		responseWriter, err := WriteTCPResponse(decodedRequest, response)
+		common.Must(err)
+		common.Must(responseWriter.WriteMultiBuffer(buf.MultiBuffer{buf.FromBytes(append([]byte(nil), payload...))}))
+		responseReader, err := ReadTCPResponse(request.User, response)
+		common.Must(err)
+		decodedData, err = responseReader.ReadMultiBuffer()
+		if err != nil {
+			t.Fatal(cipherType, ": failed to read response: ", err)
+		}
+		if r := cmp.Diff(decodedData[0].Bytes(), payload); r != "" {
+			t.Error(cipherType, ": response data: ", r)
+		}
+		cache.Release()
+		response.Release()
+
+		udpRequest := newRequest(protocol.RequestCommandUDP, "password", cipherType)
+		packet, err := EncodeUDPPacket(udpRequest, payload)
+		common.Must(err)
+		if _, data, err := DecodeUDPPacket(validator, packet); err != nil {
+			t.Error(cipherType, ": ", err)
+		} else if r := cmp.Diff(data.Bytes(), payload); r != "" {
+			t.Error(cipherType, ": udp data: ", r)
+		}
+	}
+
+	for _, cipherType := range []CipherType{CipherType_AES_256_GCM, CipherType_CHACHA20_POLY1305} {
+		request := newRequest(protocol.RequestCommandTCP, "wrong password", cipherType)
+		cache := buf.New()
+		writer, err := WriteTCPRequest(request, cache)
+		common.Must(err)
+		common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{buf.FromBytes(append([]byte(nil), payload...))}))
+		if _, _, err := ReadTCPSession(validator, cache); err == nil {
+			t.Error(cipherType, ": accepted a wrong key")
+		}
+		cache.Release()
+
+		packet, err := EncodeUDPPacket(newRequest(protocol.RequestCommandUDP, "wrong password", cipherType), payload)
+		common.Must(err)
+		if _, _, err := DecodeUDPPacket(validator, packet); err == nil {
+			t.Error(cipherType, ": accepted a wrong key for udp")
+		}
+	}
+}
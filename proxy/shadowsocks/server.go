@@ -16,6 +16,7 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/udp"
 )
@@ -24,6 +25,8 @@ type Server struct {
 	config        *ServerConfig
 	validator     *Validator
 	policyManager policy.Manager
+	stats         stats.Manager
+	targetPorts   *protocol.PortRestriction
 	cone          bool
 }
 
@@ -46,6 +49,8 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 		config:        config,
 		validator:     validator,
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
+		stats:         v.GetFeature(stats.ManagerType()).(stats.Manager),
+		targetPorts:   protocol.NewPortRestriction(config.AllowedTargetPorts, config.BlockedTargetPorts),
 		cone:          ctx.Value("cone").(bool),
 	}
 
@@ -74,7 +79,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	inbound := session.InboundFromContext(ctx)
 	inbound.Name = "shadowsocks"
 	inbound.CanSpliceCopy = 3
-	
+
 	switch network {
 	case net.Network_TCP:
 		return s.handleConnection(ctx, conn, dispatcher)
@@ -164,6 +169,11 @@ func (s *Server) handleUDPPayload(ctx context.Context, conn stat.Connection, dis
 					Email:  request.User.Email,
 				})
 			}
+			currentPacketCtx = session.ContextWithPortRestriction(currentPacketCtx, protocol.UserPortRestriction(request.User, s.targetPorts))
+			if err := policy.CheckDestination(currentPacketCtx, s.stats, destination); err != nil {
+				data.Release()
+				continue
+			}
 			newError("tunnelling request to ", destination).WriteToLog(session.ExportIDToError(currentPacketCtx))
 
 			data.UDP = &destination
@@ -181,7 +191,7 @@ func (s *Server) handleUDPPayload(ctx context.Context, conn stat.Connection, dis
 }
 
 func (s *Server) handleConnection(ctx context.Context, conn stat.Connection, dispatcher routing.Dispatcher) error {
-	sessionPolicy := s.policyManager.ForLevel(0)
+	sessionPolicy := policy.ForContext(ctx, s.policyManager, 0)
 	if err := conn.SetReadDeadline(time.Now().Add(sessionPolicy.Timeouts.Handshake)); err != nil {
 		return newError("unable to set read deadline").Base(err).AtWarning()
 	}
@@ -204,6 +214,7 @@ func (s *Server) handleConnection(ctx context.Context, conn stat.Connection, dis
 		panic("no inbound metadata")
 	}
 	inbound.User = request.User
+	ctx = session.ContextWithPortRestriction(ctx, protocol.UserPortRestriction(request.User, s.targetPorts))
 
 	dest := request.Destination()
 	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
@@ -215,7 +226,7 @@ func (s *Server) handleConnection(ctx context.Context, conn stat.Connection, dis
 	})
 	newError("tunnelling request to ", dest).WriteToLog(session.ExportIDToError(ctx))
 
-	sessionPolicy = s.policyManager.ForLevel(request.User.Level)
+	sessionPolicy = policy.ForContext(ctx, s.policyManager, request.User.Level)
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
 
@@ -74,6 +74,12 @@ func (v *Validator) Del(email string) error {
 }
 
 // Get a Shadowsocks user.
+//
+// An account of the auto cipher type is tried with its fallback cipher when
+// the preferred one fails. Both ciphers share the subkey, so such an account
+// costs at most one more AEAD open of the first chunk (18 bytes for TCP, the
+// whole packet for UDP) than other accounts. The returned user then speaks the
+// cipher that matched.
 func (v *Validator) Get(bs []byte, command protocol.RequestCommand) (u *protocol.MemoryUser, aead cipher.AEAD, ret []byte, ivLen int32, err error) {
 	v.RLock()
 	defer v.RUnlock()
@@ -91,16 +97,14 @@ func (v *Validator) Get(bs []byte, command protocol.RequestCommand) (u *protocol
 			subkey := make([]byte, 32)
 			subkey = subkey[:aeadCipher.KeyBytes]
 			hkdfSHA1(account.Key, iv, subkey)
-			aead = aeadCipher.AEADAuthCreator(subkey)
 
 			var matchErr error
-			switch command {
-			case protocol.RequestCommandTCP:
-				data := make([]byte, 4+aead.NonceSize())
-				ret, matchErr = aead.Open(data[:0], data[4:], bs[ivLen:ivLen+18], nil)
-			case protocol.RequestCommandUDP:
-				data := make([]byte, 8192)
-				ret, matchErr = aead.Open(data[:0], data[8192-aead.NonceSize():8192], bs[ivLen:], nil)
+			aead, ret, matchErr = openFirstChunk(aeadCipher, subkey, bs[ivLen:], command)
+			if matchErr != nil && account.Fallback != nil {
+				aead, ret, matchErr = openFirstChunk(account.Fallback, subkey, bs[ivLen:], command)
+				if matchErr == nil {
+					user = withFallbackCipher(user)
+				}
 			}
 
 			if matchErr == nil {
@@ -119,6 +123,19 @@ func (v *Validator) Get(bs []byte, command protocol.RequestCommand) (u *protocol
 	return nil, nil, nil, 0, ErrNotFound
 }
 
+func openFirstChunk(c *AEADCipher, subkey []byte, payload []byte, command protocol.RequestCommand) (aead cipher.AEAD, ret []byte, err error) {
+	aead = c.AEADAuthCreator(subkey)
+	switch command {
+	case protocol.RequestCommandTCP:
+		data := make([]byte, 4+aead.NonceSize())
+		ret, err = aead.Open(data[:0], data[4:], payload[:18], nil)
+	case protocol.RequestCommandUDP:
+		data := make([]byte, 8192)
+		ret, err = aead.Open(data[:0], data[8192-aead.NonceSize():8192], payload, nil)
+	}
+	return
+}
+
 func (v *Validator) GetBehaviorSeed() uint64 {
 	v.Lock()
 	defer v.Unlock()
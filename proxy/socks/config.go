@@ -13,6 +13,12 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 	return a, nil
 }
 
+// HasUsername returns true if there is an account named username.
+func (c *ServerConfig) HasUsername(username string) bool {
+	_, found := c.Accounts[username]
+	return found
+}
+
 func (c *ServerConfig) HasAccount(username, password string) bool {
 	if c.Accounts == nil {
 		return false
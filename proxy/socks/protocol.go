@@ -29,6 +29,7 @@ const (
 	authNoMatchingMethod = 0xFF
 
 	statusSuccess       = 0x00
+	statusNotAllowed    = 0x02
 	statusCmdNotSupport = 0x07
 )
 
@@ -43,10 +44,12 @@ type ServerSession struct {
 	address      net.Address
 	port         net.Port
 	localAddress net.Address
+	// checkTarget, if set, rejects the destinations of connect requests before they are granted.
+	checkTarget func(request *protocol.RequestHeader) error
 }
 
 func (s *ServerSession) handshake4(cmd byte, reader io.Reader, writer io.Writer) (*protocol.RequestHeader, error) {
-	if s.config.AuthType == AuthType_PASSWORD {
+	if s.config.AuthType == AuthType_PASSWORD && !s.config.Socks4UseridAuth {
 		writeSocks4Response(writer, socks4RequestRejected, net.AnyIP, net.Port(0))
 		return nil, newError("socks 4 is not allowed when auth is required.")
 	}
@@ -65,7 +68,8 @@ func (s *ServerSession) handshake4(cmd byte, reader io.Reader, writer io.Writer)
 		buffer.Release()
 	}
 
-	if _, err := ReadUntilNull(reader); /* user id */ err != nil {
+	userID, err := ReadUntilNull(reader)
+	if err != nil {
 		return nil, err
 	}
 	if address.IP()[0] == 0x00 {
@@ -76,6 +80,15 @@ func (s *ServerSession) handshake4(cmd byte, reader io.Reader, writer io.Writer)
 		address = net.DomainAddress(domain)
 	}
 
+	var user *protocol.MemoryUser
+	if s.config.AuthType == AuthType_PASSWORD {
+		if !s.config.HasUsername(userID) {
+			writeSocks4Response(writer, socks4RequestRejected, net.AnyIP, net.Port(0))
+			return nil, newError("invalid socks 4 userid: ", userID)
+		}
+		user = &protocol.MemoryUser{Email: userID}
+	}
+
 	switch cmd {
 	case cmdTCPConnect:
 		request := &protocol.RequestHeader{
@@ -83,11 +96,21 @@ func (s *ServerSession) handshake4(cmd byte, reader io.Reader, writer io.Writer)
 			Address: address,
 			Port:    port,
 			Version: socks4Version,
+			User:    user,
+		}
+		if s.checkTarget != nil {
+			if err := s.checkTarget(request); err != nil {
+				writeSocks4Response(writer, socks4RequestRejected, net.AnyIP, net.Port(0))
+				return nil, err
+			}
 		}
 		if err := writeSocks4Response(writer, socks4RequestGranted, net.AnyIP, net.Port(0)); err != nil {
 			return nil, err
 		}
 		return request, nil
+	case cmdTCPBind:
+		writeSocks4Response(writer, socks4RequestRejected, net.AnyIP, net.Port(0))
+		return nil, newError("TCP bind is not supported.")
 	default:
 		writeSocks4Response(writer, socks4RequestRejected, net.AnyIP, net.Port(0))
 		return nil, newError("unsupported command: ", cmd)
@@ -199,6 +222,12 @@ func (s *ServerSession) handshake5(nMethod byte, reader io.Reader, writer io.Wri
 			responseAddress = s.localAddress
 		}
 	}
+	if request.Command == protocol.RequestCommandTCP && s.checkTarget != nil {
+		if err := s.checkTarget(request); err != nil {
+			writeSocks5Response(writer, statusNotAllowed, net.AnyIP, net.Port(0))
+			return nil, err
+		}
+	}
 	if err := writeSocks5Response(writer, statusSuccess, responseAddress, responsePort); err != nil {
 		return nil, err
 	}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
@@ -18,6 +19,7 @@ import (
 	"github.com/xtls/xray-core/features"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/udp"
 )
@@ -26,6 +28,7 @@ import (
 type Server struct {
 	config        *ServerConfig
 	policyManager policy.Manager
+	stats         stats.Manager
 	cone          bool
 	udpFilter     *UDPFilter
 }
@@ -36,6 +39,7 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 	s := &Server{
 		config:        config,
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
+		stats:         v.GetFeature(stats.ManagerType()).(stats.Manager),
 		cone:          ctx.Value("cone").(bool),
 	}
 	if config.AuthType == AuthType_PASSWORD {
@@ -44,9 +48,9 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 	return s, nil
 }
 
-func (s *Server) policy() policy.Session {
+func (s *Server) policy(ctx context.Context) policy.Session {
 	config := s.config
-	p := s.policyManager.ForLevel(config.UserLevel)
+	p := policy.ForContext(ctx, s.policyManager, config.UserLevel)
 	if config.Timeout > 0 {
 		features.PrintDeprecatedFeatureWarning("Socks timeout")
 	}
@@ -58,7 +62,7 @@ func (s *Server) policy() policy.Session {
 
 // Network implements proxy.Inbound.
 func (s *Server) Network() []net.Network {
-	list := []net.Network{net.Network_TCP}
+	list := []net.Network{net.Network_TCP, net.Network_UNIX}
 	if s.config.UdpEnabled {
 		list = append(list, net.Network_UDP)
 	}
@@ -75,7 +79,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	}
 
 	switch network {
-	case net.Network_TCP:
+	case net.Network_TCP, net.Network_UNIX:
 		return s.processTCP(ctx, conn, dispatcher)
 	case net.Network_UDP:
 		return s.handleUDPPayload(ctx, conn, dispatcher)
@@ -85,7 +89,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 }
 
 func (s *Server) processTCP(ctx context.Context, conn stat.Connection, dispatcher routing.Dispatcher) error {
-	plcy := s.policy()
+	plcy := s.policy(ctx)
 	if err := conn.SetReadDeadline(time.Now().Add(plcy.Timeouts.Handshake)); err != nil {
 		newError("failed to set deadline").Base(err).WriteToLog(session.ExportIDToError(ctx))
 	}
@@ -99,13 +103,26 @@ func (s *Server) processTCP(ctx context.Context, conn stat.Connection, dispatche
 		config:       s.config,
 		address:      inbound.Gateway.Address,
 		port:         inbound.Gateway.Port,
-		localAddress: net.IPAddress(conn.LocalAddr().(*net.TCPAddr).IP),
+		localAddress: net.AnyIP,
+		checkTarget: func(request *protocol.RequestHeader) error {
+			if request.User != nil {
+				inbound.User.Email = request.User.Email
+			}
+			return policy.CheckTarget(ctx, s.stats, request.Destination())
+		},
+	}
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		svrSession.localAddress = net.IPAddress(addr.IP)
+	} else {
+		// Unix domain or vsock socket, which has no address to tell the client.
+		svrSession.address = net.AnyIP
 	}
 
 	reader := &buf.BufferedReader{Reader: buf.NewReader(conn)}
 	request, err := svrSession.Handshake(reader, conn)
 	if err != nil {
-		if inbound.Source.IsValid() {
+		// CheckTarget has logged the rejection already
+		if inbound.Source.IsValid() && errors.Cause(err) != policy.ErrTargetForbidden {
 			log.Record(&log.AccessMessage{
 				From:   inbound.Source,
 				To:     "",
@@ -156,13 +173,13 @@ func (*Server) handleUDP(c io.Reader) error {
 
 func (s *Server) transport(ctx context.Context, reader io.Reader, writer io.Writer, dest net.Destination, dispatcher routing.Dispatcher, inbound *session.Inbound) error {
 	ctx, cancel := context.WithCancel(ctx)
-	timer := signal.CancelAfterInactivity(ctx, cancel, s.policy().Timeouts.ConnectionIdle)
+	timer := signal.CancelAfterInactivity(ctx, cancel, s.policy(ctx).Timeouts.ConnectionIdle)
 
 	if inbound != nil {
 		inbound.Timer = timer
 	}
 
-	plcy := s.policy()
+	plcy := s.policy(ctx)
 	ctx = policy.ContextWithBufferPolicy(ctx, plcy.Buffer)
 	link, err := dispatcher.Dispatch(ctx, dest)
 	if err != nil {
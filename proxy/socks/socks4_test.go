@@ -0,0 +1,164 @@
+package socks
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+)
+
+func TestSocks4Handshake(t *testing.T) {
+	passwordConfig := func(useridAuth bool) *ServerConfig {
+		return &ServerConfig{
+			AuthType:         AuthType_PASSWORD,
+			Accounts:         map[string]string{"alice": "password"},
+			Socks4UseridAuth: useridAuth,
+		}
+	}
+	connect := func(userid string) []byte {
+		return append([]byte{socks4Version, cmdTCPConnect, 0, 80, 1, 2, 3, 4}, append([]byte(userid), 0)...)
+	}
+
+	testCases := []struct {
+		name    string
+		config  *ServerConfig
+		input   []byte
+		reply   byte
+		address net.Address
+		email   string
+	}{
+		{
+			name:    "socks4",
+			config:  &ServerConfig{},
+			input:   connect("anyone"),
+			reply:   socks4RequestGranted,
+			address: net.IPAddress([]byte{1, 2, 3, 4}),
+		},
+		{
+			name:    "socks4a",
+			config:  &ServerConfig{},
+			input:   append([]byte{socks4Version, cmdTCPConnect, 0, 80, 0, 0, 0, 1, 0}, []byte("example.com\x00")...),
+			reply:   socks4RequestGranted,
+			address: net.DomainAddress("example.com"),
+		},
+		{
+			name:   "bind",
+			config: &ServerConfig{},
+			input:  []byte{socks4Version, cmdTCPBind, 0, 80, 1, 2, 3, 4, 0},
+			reply:  socks4RequestRejected,
+		},
+		{
+			name:   "password required",
+			config: passwordConfig(false),
+			input:  connect("alice"),
+			reply:  socks4RequestRejected,
+		},
+		{
+			name:    "userid matching an account",
+			config:  passwordConfig(true),
+			input:   connect("alice"),
+			reply:   socks4RequestGranted,
+			address: net.IPAddress([]byte{1, 2, 3, 4}),
+			email:   "alice",
+		},
+		{
+			name:   "userid not matching an account",
+			config: passwordConfig(true),
+			input:  connect("mallory"),
+			reply:  socks4RequestRejected,
+		},
+	}
+
+	for _, tc := range testCases {
+		session := &ServerSession{
+			config: tc.config,
+		}
+		var output bytes.Buffer
+		request, err := session.Handshake(bytes.NewReader(tc.input), &output)
+
+		if reply := output.Bytes(); len(reply) != 8 || reply[0] != 0 || reply[1] != tc.reply {
+			t.Error(tc.name, ": unexpected reply ", reply)
+		}
+		if tc.reply == socks4RequestRejected {
+			if err == nil {
+				t.Error(tc.name, ": expected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(tc.name, ": ", err)
+			continue
+		}
+		if request.Address != tc.address || request.Port != 80 {
+			t.Error(tc.name, ": unexpected destination ", request.Destination())
+		}
+		if tc.email == "" && request.User != nil {
+			t.Error(tc.name, ": unexpected user ", request.User.Email)
+		}
+		if tc.email != "" && (request.User == nil || request.User.Email != tc.email) {
+			t.Error(tc.name, ": expected user ", tc.email)
+		}
+	}
+}
+
+func TestHandshakeForbiddenTarget(t *testing.T) {
+	checkTarget := func(request *protocol.RequestHeader) error {
+		if request.Address == net.LocalHostIP {
+			return newError("target not allowed")
+		}
+		return nil
+	}
+	testCases := []struct {
+		name    string
+		input   []byte
+		granted bool
+	}{
+		{
+			name:  "socks4 to loopback",
+			input: []byte{socks4Version, cmdTCPConnect, 0, 80, 127, 0, 0, 1, 0},
+		},
+		{
+			name:    "socks4 to public address",
+			input:   []byte{socks4Version, cmdTCPConnect, 0, 80, 1, 2, 3, 4, 0},
+			granted: true,
+		},
+		{
+			name:  "socks5 to loopback",
+			input: []byte{socks5Version, 1, authNotRequired, socks5Version, cmdTCPConnect, 0, 1, 127, 0, 0, 1, 0, 80},
+		},
+		{
+			name:    "socks5 to public address",
+			input:   []byte{socks5Version, 1, authNotRequired, socks5Version, cmdTCPConnect, 0, 1, 1, 2, 3, 4, 0, 80},
+			granted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		session := &ServerSession{
+			config:      &ServerConfig{},
+			address:     net.AnyIP,
+			checkTarget: checkTarget,
+		}
+		var output bytes.Buffer
+		_, err := session.Handshake(bytes.NewReader(tc.input), &output)
+		if tc.granted != (err == nil) {
+			t.Error(tc.name, ": unexpected error ", err)
+		}
+
+		reply := output.Bytes()
+		var status byte
+		if tc.input[0] == socks4Version {
+			status = reply[1]
+			if tc.granted != (status == socks4RequestGranted) {
+				t.Error(tc.name, ": unexpected reply ", reply)
+			}
+		} else {
+			// after the authentication reply
+			status = reply[3]
+			if tc.granted != (status == statusSuccess) || !tc.granted && status != statusNotAllowed {
+				t.Error(tc.name, ": unexpected reply ", reply)
+			}
+		}
+	}
+}
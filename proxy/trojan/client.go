@@ -15,6 +15,7 @@ import (
 	"github.com/xtls/xray-core/common/task"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
@@ -22,8 +23,9 @@ import (
 
 // Client is a inbound handler for trojan protocol
 type Client struct {
-	serverPicker  protocol.ServerPicker
-	policyManager policy.Manager
+	serverPicker   protocol.ServerPicker
+	policyManager  policy.Manager
+	dnsInterceptor *proxy.DNSInterceptor
 }
 
 // NewClient create a new trojan client.
@@ -45,6 +47,12 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 	}
+
+	dnsInterceptor, err := proxy.NewDNSInterceptor(ctx, config.InterceptDns, client.policyManager.ForLevel(0).Timeouts.ConnectionIdle)
+	if err != nil {
+		return nil, newError("failed to intercept DNS").Base(err)
+	}
+	client.dnsInterceptor = dnsInterceptor
 	return client, nil
 }
 
@@ -57,6 +65,10 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	}
 	ob.Name = "trojan"
 	ob.CanSpliceCopy = 3
+
+	if handled, err := c.dnsInterceptor.Intercept(ctx, ob.Target, link); handled {
+		return err
+	}
 	destination := ob.Target
 	network := destination.Network
 
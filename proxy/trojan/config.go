@@ -13,6 +13,9 @@ import (
 type MemoryAccount struct {
 	Password string
 	Key      []byte
+
+	RejectQUICUDP443 bool
+	TargetPorts      *protocol.PortRestriction
 }
 
 // AsAccount implements protocol.AsAccount.
@@ -22,9 +25,22 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 	return &MemoryAccount{
 		Password: password,
 		Key:      key,
+
+		RejectQUICUDP443: a.RejectQuicUdp443,
+		TargetPorts:      protocol.NewPortRestriction(a.AllowedTargetPorts, a.BlockedTargetPorts),
 	}, nil
 }
 
+// RejectsQUIC implements protocol.QUICRejecter.
+func (a *MemoryAccount) RejectsQUIC() bool {
+	return a.RejectQUICUDP443
+}
+
+// PortRestriction implements protocol.PortRestricter.
+func (a *MemoryAccount) PortRestriction() *protocol.PortRestriction {
+	return a.TargetPorts
+}
+
 // Equals implements protocol.Account.Equals().
 func (a *MemoryAccount) Equals(another protocol.Account) bool {
 	if account, ok := another.(*MemoryAccount); ok {
@@ -13,6 +13,7 @@ import (
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/quic"
 	udp_proto "github.com/xtls/xray-core/common/protocol/udp"
 	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
@@ -21,13 +22,20 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
 	"github.com/xtls/xray-core/transport/internet/udp"
 )
 
+func validateConfig(v *core.Validation, path string, c interface{}) {
+	proxy.ValidateFallbacks(v, path, len(c.(*ServerConfig).Fallbacks))
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*ServerConfig)(nil), validateConfig))
 	common.Must(common.RegisterConfig((*ServerConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return NewServer(ctx, config.(*ServerConfig))
 	}))
@@ -39,6 +47,8 @@ type Server struct {
 	validator     *Validator
 	fallbacks     map[string]map[string]map[string]*Fallback // or nil
 	cone          bool
+	stats         stats.Manager
+	targetPorts   *protocol.PortRestriction
 }
 
 // NewServer creates a new trojan inbound handler.
@@ -60,6 +70,8 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		validator:     validator,
 		cone:          ctx.Value("cone").(bool),
+		stats:         v.GetFeature(stats.ManagerType()).(stats.Manager),
+		targetPorts:   protocol.NewPortRestriction(config.AllowedTargetPorts, config.BlockedTargetPorts),
 	}
 
 	if config.Fallbacks != nil {
@@ -140,7 +152,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 		iConn = statConn.Connection
 	}
 
-	sessionPolicy := s.policyManager.ForLevel(0)
+	sessionPolicy := policy.ForContext(ctx, s.policyManager, 0)
 	if err := conn.SetReadDeadline(time.Now().Add(sessionPolicy.Timeouts.Handshake)); err != nil {
 		return newError("unable to set read deadline").Base(err).AtWarning()
 	}
@@ -217,7 +229,8 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	inbound.Name = "trojan"
 	inbound.CanSpliceCopy = 3
 	inbound.User = user
-	sessionPolicy = s.policyManager.ForLevel(user.Level)
+	ctx = session.ContextWithPortRestriction(ctx, protocol.UserPortRestriction(user, s.targetPorts))
+	sessionPolicy = policy.ForContext(ctx, s.policyManager, user.Level)
 
 	if destination.Network == net.Network_UDP { // handle udp request
 		return s.handleUDPPayload(ctx, &PacketReader{Reader: clientReader}, &PacketWriter{Writer: conn}, dispatcher)
@@ -251,6 +264,8 @@ func (s *Server) handleUDPPayload(ctx context.Context, clientReader *PacketReade
 	user := inbound.User
 
 	var dest *net.Destination
+	// destinations on port 443 whose first packet has been let through
+	flows := make(map[net.Destination]bool)
 
 	for {
 		select {
@@ -271,6 +286,17 @@ func (s *Server) handleUDPPayload(ctx context.Context, clientReader *PacketReade
 			}
 			destination := *b.UDP
 
+			if destination.Port == 443 && !flows[destination] {
+				if quic.ShouldReject(user, destination, b.Bytes()) {
+					// only this flow is refused, the session may carry others
+					buf.ReleaseMulti(mb2)
+					b.Release()
+					newError("QUIC to ", destination, " is refused for ", user.Email).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+					continue
+				}
+				flows[destination] = true
+			}
+
 			currentPacketCtx := ctx
 			if inbound.Source.IsValid() {
 				currentPacketCtx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
@@ -281,6 +307,12 @@ func (s *Server) handleUDPPayload(ctx context.Context, clientReader *PacketReade
 					Email:  user.Email,
 				})
 			}
+			// in cone mode, packets to other destinations share the first dispatch
+			if err := policy.CheckDestination(currentPacketCtx, s.stats, destination); err != nil {
+				buf.ReleaseMulti(mb2)
+				b.Release()
+				continue
+			}
 			newError("tunnelling request to ", destination).WriteToLog(session.ExportIDToError(ctx))
 
 			if !s.cone || dest == nil {
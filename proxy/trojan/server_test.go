@@ -0,0 +1,101 @@
+package trojan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+type recordDispatcher struct {
+	reader *pipe.Reader
+}
+
+func (d *recordDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, _ := pipe.New(pipe.WithoutSizeLimit())
+	d.reader = uplinkReader
+	return &transport.Link{Reader: downlinkReader, Writer: uplinkWriter}, nil
+}
+
+func (d *recordDispatcher) DispatchLink(ctx context.Context, destination net.Destination, outbound *transport.Link) error {
+	return nil
+}
+
+func (d *recordDispatcher) Start() error {
+	return nil
+}
+
+func (d *recordDispatcher) Close() error {
+	return nil
+}
+
+func (*recordDispatcher) Type() interface{} {
+	return routing.DispatcherType()
+}
+
+func quicInitial() []byte {
+	b := make([]byte, 1250)
+	b[0] = 0xc3 // long header, fixed bit, Initial
+	binary.BigEndian.PutUint32(b[1:5], 1)
+	b[5] = 8
+	return b
+}
+
+func TestUDPRejectQUICKeepsSession(t *testing.T) {
+	user := &protocol.MemoryUser{
+		Email: "love@example.com",
+		Account: &MemoryAccount{
+			Password:         "password",
+			RejectQUICUDP443: true,
+		},
+	}
+
+	dns := net.UDPDestination(net.ParseAddress("1.1.1.1"), 53)
+	quicDest := net.UDPDestination(net.ParseAddress("2.2.2.2"), 443)
+	other := net.UDPDestination(net.ParseAddress("3.3.3.3"), 53)
+
+	// one session carrying packets to several destinations
+	var stream bytes.Buffer
+	writer := &PacketWriter{Writer: &stream}
+	for _, p := range []struct {
+		dest    net.Destination
+		payload []byte
+	}{
+		{dns, []byte("first")},
+		{quicDest, quicInitial()},
+		{other, []byte("second")},
+	} {
+		b := buf.New()
+		common.Must2(b.Write(p.payload))
+		b.UDP = &p.dest
+		common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+	}
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{User: user})
+	dispatcher := new(recordDispatcher)
+	s := &Server{}
+	if err := s.handleUDPPayload(ctx, &PacketReader{Reader: &stream}, &PacketWriter{Writer: new(bytes.Buffer)}, dispatcher); err != nil {
+		t.Fatal("session ended with error: ", err)
+	}
+
+	mb, err := dispatcher.reader.ReadMultiBuffer()
+	common.Must(err)
+	var got []net.Destination
+	for _, b := range mb {
+		got = append(got, *b.UDP)
+	}
+	buf.ReleaseMulti(mb)
+	if len(got) != 2 || got[0] != dns || got[1] != other {
+		t.Error("unexpected packets dispatched to ", got)
+	}
+}
@@ -0,0 +1,47 @@
+package tun
+
+import (
+	"net/netip"
+	"runtime"
+)
+
+const defaultMTU = 1500
+
+func (c *Config) mtu() int {
+	if c.Mtu == 0 {
+		return defaultMTU
+	}
+	return int(c.Mtu)
+}
+
+func (c *Config) name() string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case runtime.GOOS == "darwin":
+		// utun picks the next free unit
+		return "utun"
+	default:
+		return "xray0"
+	}
+}
+
+// prefixes parses the addresses of the device. An address without prefix length is a single host.
+func (c *Config) prefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.Address))
+	for _, str := range c.Address {
+		prefix, err := netip.ParsePrefix(str)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(str)
+			if aerr != nil {
+				return nil, newError("invalid address: ", str).Base(err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	if len(prefixes) == 0 {
+		return nil, newError("no address for TUN device")
+	}
+	return prefixes, nil
+}
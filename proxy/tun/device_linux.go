@@ -0,0 +1,148 @@
+//go:build linux && !android
+
+package tun
+
+import (
+	"net"
+	"net/netip"
+	"os"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	wgtun "golang.zx2c4.com/wireguard/tun"
+)
+
+// device is a TUN device created by name, together with the addresses,
+// routes and rules installed for it, which are removed on Close.
+type device struct {
+	wgtun.Device
+
+	handle *netlink.Handle
+	routes []*netlink.Route
+	rules  []*netlink.Rule
+}
+
+func (d *device) Close() error {
+	for _, rule := range d.rules {
+		if err := d.handle.RuleDel(rule); err != nil {
+			newError("failed to delete rule ", rule).Base(err).AtWarning().WriteToLog()
+		}
+	}
+	for _, route := range d.routes {
+		if err := d.handle.RouteDel(route); err != nil {
+			newError("failed to delete route ", route).Base(err).AtWarning().WriteToLog()
+		}
+	}
+	d.handle.Close()
+	return d.Device.Close()
+}
+
+func openDevice(config *Config, prefixes []netip.Prefix) (dev wgtun.Device, err error) {
+	if config.Fd > 0 {
+		// already configured by whoever opened it
+		return wgtun.CreateTUNFromFile(os.NewFile(uintptr(config.Fd), "tun"), config.mtu())
+	}
+
+	t, err := wgtun.CreateTUN(config.name(), config.mtu())
+	if err != nil {
+		return nil, err
+	}
+	d := &device{Device: t}
+	defer func() {
+		if err != nil {
+			if d.handle != nil {
+				d.Close()
+			} else {
+				t.Close()
+			}
+		}
+	}()
+
+	name, err := t.Name()
+	if err != nil {
+		return nil, err
+	}
+	if d.handle, err = netlink.NewHandle(); err != nil {
+		return nil, err
+	}
+	l, err := d.handle.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	hasV4, hasV6 := false, false
+	for _, prefix := range prefixes {
+		addr := &netlink.Addr{
+			IPNet: &net.IPNet{
+				IP:   prefix.Addr().AsSlice(),
+				Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+			},
+		}
+		if err = d.handle.AddrAdd(l, addr); err != nil {
+			return nil, newError("failed to add address ", prefix, " to ", name).Base(err)
+		}
+		hasV4 = hasV4 || prefix.Addr().Is4()
+		hasV6 = hasV6 || prefix.Addr().Is6()
+	}
+	if err = d.handle.LinkSetMTU(l, config.mtu()); err != nil {
+		return nil, err
+	}
+	if err = d.handle.LinkSetUp(l); err != nil {
+		return nil, err
+	}
+
+	if config.ExcludeMark == 0 {
+		return d, nil
+	}
+
+	table, err := findFreeTable(d.handle)
+	if err != nil {
+		return nil, err
+	}
+	families := map[int]*net.IPNet{}
+	if hasV4 {
+		families[unix.AF_INET] = &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	}
+	if hasV6 {
+		families[unix.AF_INET6] = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	}
+	for family, dst := range families {
+		route := &netlink.Route{
+			LinkIndex: l.Attrs().Index,
+			Dst:       dst,
+			Table:     table,
+		}
+		if err = d.handle.RouteAdd(route); err != nil {
+			return nil, newError("failed to add route ", route).Base(err)
+		}
+		d.routes = append(d.routes, route)
+
+		// everything without the mark goes into the device
+		rule := netlink.NewRule()
+		rule.Family = family
+		rule.Table = table
+		rule.Mark = int(config.ExcludeMark)
+		rule.Invert = true
+		if err = d.handle.RuleAdd(rule); err != nil {
+			return nil, newError("failed to add rule ", rule).Base(err)
+		}
+		d.rules = append(d.rules, rule)
+	}
+
+	return d, nil
+}
+
+// findFreeTable returns a routing table without routes.
+func findFreeTable(handle *netlink.Handle) (int, error) {
+	for table := 2022; table > 1024; table-- {
+		filter := &netlink.Route{Table: table}
+		routes, err := handle.RouteListFiltered(netlink.FAMILY_ALL, filter, netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return 0, err
+		}
+		if len(routes) == 0 {
+			return table, nil
+		}
+	}
+	return 0, newError("no free routing table")
+}
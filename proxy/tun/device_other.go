@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !android && !windows
+
+package tun
+
+import (
+	"net/netip"
+
+	wgtun "golang.zx2c4.com/wireguard/tun"
+)
+
+func openDevice(config *Config, prefixes []netip.Prefix) (wgtun.Device, error) {
+	return nil, newError("TUN device is not supported on this platform")
+}
@@ -0,0 +1,21 @@
+//go:build darwin || freebsd || openbsd || android
+
+package tun
+
+import (
+	"net/netip"
+	"os"
+
+	wgtun "golang.zx2c4.com/wireguard/tun"
+)
+
+func openDevice(config *Config, prefixes []netip.Prefix) (wgtun.Device, error) {
+	if config.ExcludeMark != 0 {
+		return nil, newError("excludeMark is only supported on Linux")
+	}
+	if config.Fd > 0 {
+		return wgtun.CreateTUNFromFile(os.NewFile(uintptr(config.Fd), "tun"), config.mtu())
+	}
+	newError("addresses of TUN device ", config.name(), " must be configured by the system").AtWarning().WriteToLog()
+	return wgtun.CreateTUN(config.name(), config.mtu())
+}
@@ -0,0 +1,18 @@
+package tun
+
+import (
+	"net/netip"
+
+	wgtun "golang.zx2c4.com/wireguard/tun"
+)
+
+func openDevice(config *Config, prefixes []netip.Prefix) (wgtun.Device, error) {
+	if config.Fd > 0 {
+		return nil, newError("TUN device from fd is not supported on Windows")
+	}
+	if config.ExcludeMark != 0 {
+		return nil, newError("excludeMark is only supported on Linux")
+	}
+	newError("addresses of TUN device ", config.name(), " must be configured by the system").AtWarning().WriteToLog()
+	return wgtun.CreateTUN(config.name(), config.mtu())
+}
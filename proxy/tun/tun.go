@@ -0,0 +1,168 @@
+package tun
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy/wireguard"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	wgtun "golang.zx2c4.com/wireguard/tun"
+)
+
+const (
+	// offset reserved in front of each packet, which some platform devices need for their headers.
+	packetOffset = 16
+	// platform devices with offloading may hand out segments larger than the MTU.
+	maxPacketSize = 65535
+)
+
+// Server is an inbound that reads packets from a TUN device, terminates them
+// in a userspace netstack, and dispatches the TCP and UDP flows.
+type Server struct {
+	device    wgtun.Device
+	stack     wgtun.Device
+	forwarder *wireguard.Forwarder
+	closeOnce sync.Once
+}
+
+// New creates a Server with the TUN device described by config.
+func New(ctx context.Context, config *Config) (*Server, error) {
+	v := core.MustFromContext(ctx)
+
+	prefixes, err := config.prefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := openDevice(config, prefixes)
+	if err != nil {
+		return nil, newError("failed to open TUN device").Base(err)
+	}
+
+	s, err := newServer(device, prefixes, config.mtu(), v.GetFeature(policy.ManagerType()).(policy.Manager))
+	if err != nil {
+		device.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newServer(device wgtun.Device, prefixes []netip.Prefix, mtu int, policyManager policy.Manager) (*Server, error) {
+	addresses := make([]netip.Addr, len(prefixes))
+	for i, prefix := range prefixes {
+		addresses[i] = prefix.Addr()
+	}
+
+	s := &Server{
+		device:    device,
+		forwarder: wireguard.NewForwarder(policyManager),
+	}
+	stack, err := wireguard.CreatePromiscuousNetTUN(addresses, mtu, s.forwarder.ForwardConnection)
+	if err != nil {
+		return nil, newError("failed to create netstack").Base(err)
+	}
+	s.stack = stack
+	return s, nil
+}
+
+// Network implements proxy.Inbound. The traffic comes from the device, not from listeners.
+func (*Server) Network() []net.Network {
+	return nil
+}
+
+// Process implements proxy.Inbound.
+func (*Server) Process(ctx context.Context, network net.Network, conn stat.Connection, dispatcher routing.Dispatcher) error {
+	return newError("TUN inbound doesn't accept connections")
+}
+
+// Serve implements proxy.DeviceInbound.
+func (s *Server) Serve(ctx context.Context, dispatcher routing.Dispatcher) error {
+	inbound := session.InboundFromContext(ctx)
+	inbound.Name = "tun"
+	inbound.CanSpliceCopy = 3
+
+	s.forwarder.SetRoutingInfo(ctx, dispatcher)
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- copyPackets(s.stack, s.device)
+	}()
+	go func() {
+		errc <- copyPackets(s.device, s.stack)
+	}()
+
+	select {
+	case <-ctx.Done():
+		s.Close()
+		return nil
+	case err := <-errc:
+		s.Close()
+		return err
+	}
+}
+
+// Close implements common.Closable.
+func (s *Server) Close() error {
+	var errs []error
+	s.closeOnce.Do(func() {
+		errs = append(errs, s.device.Close(), s.stack.Close())
+	})
+	return errors.Join(errs...)
+}
+
+// copyPackets moves packets from src to dst until either of them fails.
+func copyPackets(dst, src wgtun.Device) error {
+	batch := src.BatchSize()
+	bufs := make([][]byte, batch)
+	for i := range bufs {
+		bufs[i] = make([]byte, packetOffset+maxPacketSize)
+	}
+	sizes := make([]int, batch)
+	packets := make([][]byte, 0, batch)
+
+	for {
+		n, err := src.Read(bufs, sizes, packetOffset)
+		if err != nil {
+			if errors.Is(err, wgtun.ErrTooManySegments) {
+				newError("dropped packets of too many segments").AtDebug().WriteToLog()
+			} else {
+				return err
+			}
+		}
+
+		packets = packets[:0]
+		for i := 0; i < n; i++ {
+			if sizes[i] == 0 {
+				continue
+			}
+			packets = append(packets, bufs[i][:packetOffset+sizes[i]])
+		}
+		if len(packets) == 0 {
+			continue
+		}
+		if _, err := dst.Write(packets, packetOffset); err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return err
+			}
+			// a malformed packet shouldn't stop the device
+			newError("failed to write packets").Base(err).AtDebug().WriteToLog()
+		}
+	}
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
@@ -0,0 +1,202 @@
+package tun
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/netip"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy/wireguard/gvisortun"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+	wgtun "golang.zx2c4.com/wireguard/tun"
+)
+
+// pipeDevice is a packet device whose written packets are read from its peer.
+type pipeDevice struct {
+	in, out   chan []byte
+	events    chan wgtun.Event
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeDevices() (*pipeDevice, *pipeDevice) {
+	a2b := make(chan []byte, 256)
+	b2a := make(chan []byte, 256)
+	newDevice := func(in, out chan []byte) *pipeDevice {
+		return &pipeDevice{
+			in:     in,
+			out:    out,
+			events: make(chan wgtun.Event, 1),
+			closed: make(chan struct{}),
+		}
+	}
+	return newDevice(b2a, a2b), newDevice(a2b, b2a)
+}
+
+func (d *pipeDevice) File() *os.File { return nil }
+
+func (d *pipeDevice) Read(bufs [][]byte, sizes []int, offset int) (int, error) {
+	select {
+	case p := <-d.in:
+		sizes[0] = copy(bufs[0][offset:], p)
+		return 1, nil
+	case <-d.closed:
+		return 0, os.ErrClosed
+	}
+}
+
+func (d *pipeDevice) Write(bufs [][]byte, offset int) (int, error) {
+	for _, b := range bufs {
+		p := append([]byte(nil), b[offset:]...)
+		select {
+		case d.out <- p:
+		case <-d.closed:
+			return 0, os.ErrClosed
+		}
+	}
+	return len(bufs), nil
+}
+
+func (d *pipeDevice) MTU() (int, error)          { return 1500, nil }
+func (d *pipeDevice) Name() (string, error)      { return "pipe", nil }
+func (d *pipeDevice) Events() <-chan wgtun.Event { return d.events }
+func (d *pipeDevice) BatchSize() int             { return 1 }
+
+func (d *pipeDevice) Close() error {
+	d.closeOnce.Do(func() { close(d.closed) })
+	return nil
+}
+
+// echoDispatcher echoes every dispatched flow, and records the destinations.
+type echoDispatcher struct {
+	access sync.Mutex
+	dests  []net.Destination
+	tags   []string
+}
+
+func (*echoDispatcher) Type() interface{} {
+	return routing.DispatcherType()
+}
+
+func (*echoDispatcher) Start() error {
+	return nil
+}
+
+func (*echoDispatcher) Close() error {
+	return nil
+}
+
+func (d *echoDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	d.access.Lock()
+	d.dests = append(d.dests, dest)
+	d.tags = append(d.tags, session.InboundFromContext(ctx).Tag)
+	d.access.Unlock()
+
+	upReader, upWriter := pipe.New(pipe.WithoutSizeLimit())
+	downReader, downWriter := pipe.New(pipe.WithoutSizeLimit())
+	go func() {
+		buf.Copy(upReader, downWriter)
+		downWriter.Close()
+	}()
+	return &transport.Link{Reader: downReader, Writer: upWriter}, nil
+}
+
+func (*echoDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+const xrayKey core.XrayKey = 1
+
+func TestForwarding(t *testing.T) {
+	serverSide, clientSide := newPipeDevices()
+	s, err := newServer(serverSide, []netip.Prefix{netip.MustParsePrefix("172.19.0.1/30")}, 1500, policy.DefaultManager{})
+	common.Must(err)
+
+	// a client netstack stands in for the applications behind the device
+	client, clientNet, _, err := gvisortun.CreateNetTUN([]netip.Addr{netip.MustParseAddr("172.19.0.2")}, 1500, false)
+	common.Must(err)
+	defer client.Close()
+	go copyPackets(clientSide, client)
+	go copyPackets(client, clientSide)
+
+	dispatcher := new(echoDispatcher)
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), xrayKey, v))
+	ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{}})
+	ctx = session.ContextWithInbound(ctx, &session.Inbound{Tag: "tun-in"})
+	serveDone := make(chan error, 1)
+	go func() {
+		serveDone <- s.Serve(ctx, dispatcher)
+	}()
+
+	payload := bytes.Repeat([]byte("xray"), 16*1024)
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer dialCancel()
+	tcpConn, err := clientNet.DialContextTCPAddrPort(dialCtx, netip.MustParseAddrPort("1.2.3.4:80"))
+	common.Must(err)
+	tcpConn.SetDeadline(time.Now().Add(5 * time.Second))
+	go tcpConn.Write(payload)
+	response := make([]byte, len(payload))
+	common.Must2(io.ReadFull(tcpConn, response))
+	if !bytes.Equal(response, payload) {
+		t.Error("TCP response mismatch")
+	}
+	tcpConn.Close()
+
+	udpConn, err := clientNet.DialUDPAddrPort(netip.AddrPort{}, netip.MustParseAddrPort("5.6.7.8:53"))
+	common.Must(err)
+	udpConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := udpConn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16)
+	n, err := udpConn.Read(b)
+	common.Must(err)
+	if string(b[:n]) != "ping" {
+		t.Error("unexpected UDP response: ", string(b[:n]))
+	}
+	udpConn.Close()
+
+	dispatcher.access.Lock()
+	dests, tags := dispatcher.dests, dispatcher.tags
+	dispatcher.access.Unlock()
+	expected := []net.Destination{
+		net.TCPDestination(net.ParseAddress("1.2.3.4"), 80),
+		net.UDPDestination(net.ParseAddress("5.6.7.8"), 53),
+	}
+	if len(dests) != len(expected) {
+		t.Fatal("expect ", len(expected), " dispatched flows, but got ", dests)
+	}
+	for i := range expected {
+		if dests[i] != expected[i] {
+			t.Error("expect destination ", expected[i], ", but got ", dests[i])
+		}
+		if tags[i] != "tun-in" {
+			t.Error("expect inbound tag tun-in, but got ", tags[i])
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-serveDone:
+		if err != nil {
+			t.Error("unexpected error from Serve: ", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Serve doesn't return after cancel")
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/session"
+)
+
+// VisionRelay is how Vision relays a connection, once it has told what the connection carries.
+type VisionRelay int32
+
+const (
+	// VisionRelayUndecided is a connection still being filtered.
+	VisionRelayUndecided VisionRelay = iota
+	// VisionRelayTLS is a connection carrying TLS, padded through the handshake.
+	VisionRelayTLS
+	// VisionRelayPlain is a connection carrying something else, relayed without padding or filtering.
+	VisionRelayPlain
+)
+
+// VisionStats counts what Vision does on one connection, for tuning it. It is safe to read while the connection
+// is in use.
+type VisionStats struct {
+	// PaddedPackets and PaddingBytes are the packets written with padding, and the padding added to them.
+	PaddedPackets atomic.Int64
+	PaddingBytes  atomic.Int64
+	// UnpaddedPackets and UnpaddedBytes are the padded blocks read, and the padding removed from them.
+	UnpaddedPackets atomic.Int64
+	UnpaddedBytes   atomic.Int64
+
+	writerDirectCopy atomic.Int64
+	readerDirectCopy atomic.Int64
+	relay            atomic.Int32
+}
+
+// Relay returns how the connection is relayed.
+func (s *VisionStats) Relay() VisionRelay {
+	return VisionRelay(s.relay.Load())
+}
+
+// WriterDirectCopyTime returns when the writer switched to direct copy, or the zero time if it has not.
+func (s *VisionStats) WriterDirectCopyTime() time.Time {
+	return unixNano(s.writerDirectCopy.Load())
+}
+
+// ReaderDirectCopyTime returns when the reader switched to direct copy, or the zero time if it has not.
+func (s *VisionStats) ReaderDirectCopyTime() time.Time {
+	return unixNano(s.readerDirectCopy.Load())
+}
+
+func unixNano(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, t)
+}
+
+// countPadding counts b, a packet XtlsPadding returned, which starts with the user UUID if withUUID.
+func (s *VisionStats) countPadding(b *buf.Buffer, withUUID bool) {
+	var offset int32
+	if withUUID {
+		offset = 16
+	}
+	if b.Len() < offset+5 {
+		return
+	}
+	s.PaddedPackets.Add(1)
+	s.PaddingBytes.Add(int64(b.Byte(offset+3))<<8 | int64(b.Byte(offset+4)))
+}
+
+var trafficStates = struct {
+	sync.Mutex
+	m map[session.ID][]*TrafficState
+}{
+	m: make(map[session.ID][]*TrafficState),
+}
+
+// TrackTrafficState makes state visible to TrafficStates under the session ID of ctx, until the returned function
+// is called.
+func TrackTrafficState(ctx context.Context, state *TrafficState) func() {
+	id := session.IDFromContext(ctx)
+	trafficStates.Lock()
+	trafficStates.m[id] = append(trafficStates.m[id], state)
+	trafficStates.Unlock()
+
+	return func() {
+		trafficStates.Lock()
+		defer trafficStates.Unlock()
+		states := trafficStates.m[id]
+		for i, s := range states {
+			if s == state {
+				states = append(states[:i], states[i+1:]...)
+				break
+			}
+		}
+		if len(states) == 0 {
+			delete(trafficStates.m, id)
+		} else {
+			trafficStates.m[id] = states
+		}
+	}
+}
+
+// TrafficStates calls f with the tracked states of session id, or of all sessions if id is 0.
+func TrafficStates(id session.ID, f func(session.ID, *TrafficState)) {
+	trafficStates.Lock()
+	defer trafficStates.Unlock()
+	if id != 0 {
+		for _, s := range trafficStates.m[id] {
+			f(id, s)
+		}
+		return
+	}
+	for id, states := range trafficStates.m {
+		for _, s := range states {
+			f(id, s)
+		}
+	}
+}
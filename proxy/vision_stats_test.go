@@ -0,0 +1,70 @@
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/uuid"
+	. "github.com/xtls/xray-core/proxy"
+)
+
+func TestVisionStats(t *testing.T) {
+	id := uuid.New()
+	ctx := session.ContextWithID(context.Background(), session.NewID())
+	writerState := NewTrafficState(id.Bytes())
+	readerState := NewTrafficState(id.Bytes())
+	untrack := TrackTrafficState(ctx, writerState)
+
+	link := &buf.MultiBufferContainer{}
+	writer := NewVisionWriter(link, writerState, ctx)
+	var payload []byte
+	for i := 0; i < 10; i++ {
+		b := buf.New()
+		common.Must2(b.WriteString("not a TLS record"))
+		payload = append(payload, b.Bytes()...)
+		common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+	}
+
+	reader := NewVisionReader(link, readerState, ctx)
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	received := make([]byte, mb.Len())
+	buf.SplitBytes(mb, received)
+	if !bytes.Equal(received, payload) {
+		t.Error("unexpected payload: ", string(received))
+	}
+
+	w, r := &writerState.Stats, &readerState.Stats
+	if w.PaddedPackets.Load() == 0 || w.PaddingBytes.Load() == 0 {
+		t.Error("padding not counted: ", w.PaddedPackets.Load(), " ", w.PaddingBytes.Load())
+	}
+	if w.PaddedPackets.Load() != r.UnpaddedPackets.Load() {
+		t.Error("padded ", w.PaddedPackets.Load(), " packets, unpadded ", r.UnpaddedPackets.Load())
+	}
+	if w.PaddingBytes.Load() != r.UnpaddedBytes.Load() {
+		t.Error("padded ", w.PaddingBytes.Load(), " bytes, unpadded ", r.UnpaddedBytes.Load())
+	}
+	if !w.WriterDirectCopyTime().IsZero() || !r.ReaderDirectCopyTime().IsZero() {
+		t.Error("direct copy without XTLS")
+	}
+
+	var found []*TrafficState
+	TrafficStates(session.IDFromContext(ctx), func(_ session.ID, s *TrafficState) {
+		found = append(found, s)
+	})
+	if len(found) != 1 || found[0] != writerState {
+		t.Error("tracked state not found: ", found)
+	}
+	untrack()
+	found = nil
+	TrafficStates(session.IDFromContext(ctx), func(_ session.ID, s *TrafficState) {
+		found = append(found, s)
+	})
+	if len(found) != 0 {
+		t.Error("state still tracked: ", found)
+	}
+}
@@ -0,0 +1,108 @@
+package proxy_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/uuid"
+	. "github.com/xtls/xray-core/proxy"
+)
+
+func newPacket(payload []byte) buf.MultiBuffer {
+	b := buf.New()
+	common.Must2(b.Write(payload))
+	return buf.MultiBuffer{b}
+}
+
+func TestVisionRelayPlain(t *testing.T) {
+	id := uuid.New()
+	ctx := context.Background()
+	writerState := NewTrafficState(id.Bytes())
+	writerState.PlainThreshold = 100
+	link := &buf.MultiBufferContainer{}
+	writer := NewVisionWriter(link, writerState, ctx)
+
+	// an SSH banner exchange, then the key exchange
+	var payload []byte
+	write := func(p []byte) {
+		payload = append(payload, p...)
+		common.Must(writer.WriteMultiBuffer(newPacket(p)))
+	}
+	write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	XtlsFilterTls(newPacket([]byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n")), writerState, ctx)
+	if writerState.Stats.Relay() != VisionRelayUndecided || !writerState.IsPadding {
+		t.Fatal("decided before the threshold")
+	}
+	kex := bytes.Repeat([]byte{0x14}, 40)
+	write(kex)
+	if writerState.Stats.Relay() != VisionRelayPlain {
+		t.Fatal("expect plain relay past ", writerState.FilteredBytes, " bytes")
+	}
+	if writerState.IsPadding || writerState.NumberOfPacketToFilter > 0 {
+		t.Error("expect no padding nor filtering in plain relay")
+	}
+	padded := writerState.Stats.PaddedPackets.Load()
+	for i := 0; i < 10; i++ {
+		write(kex)
+	}
+	if writerState.Stats.PaddedPackets.Load() != padded {
+		t.Error("padded after the threshold")
+	}
+
+	reader := NewVisionReader(link, NewTrafficState(id.Bytes()), ctx)
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	received := make([]byte, mb.Len())
+	buf.SplitBytes(mb, received)
+	if !bytes.Equal(received, payload) {
+		t.Error("unexpected payload: ", received)
+	}
+}
+
+func TestVisionRelayForcedPlain(t *testing.T) {
+	id := uuid.New()
+	state := NewTrafficState(id.Bytes())
+	state.RelayPlain(context.Background(), "forced")
+	writer := NewVisionWriter(&buf.MultiBufferContainer{}, state, context.Background())
+	common.Must(writer.WriteMultiBuffer(newPacket([]byte("GET / HTTP/1.1\r\n\r\n"))))
+	if state.IsPadding || state.Stats.PaddedPackets.Load() != 1 {
+		t.Error("expect the padding to end with the first packet")
+	}
+}
+
+func TestVisionRelayTLS(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	state := NewTrafficState(id.Bytes())
+	state.PlainThreshold = 16
+	writer := NewVisionWriter(&buf.MultiBufferContainer{}, state, ctx)
+
+	clientHello := append([]byte{0x16, 0x03, 0x01, 0x00, 0xc3, 0x01}, make([]byte, 194)...)
+	common.Must(writer.WriteMultiBuffer(newPacket(clientHello)))
+	if state.Stats.Relay() != VisionRelayTLS || !state.IsPadding {
+		t.Fatal("expect the client hello to be padded as TLS")
+	}
+
+	// a TLS 1.3 server hello, with TLS_AES_128_GCM_SHA256
+	serverHello := []byte{0x16, 0x03, 0x03, 0x00, 0x52, 0x02, 0x00, 0x00, 0x4e, 0x03, 0x03}
+	serverHello = append(serverHello, make([]byte, 32)...)
+	serverHello = append(serverHello, 32)
+	serverHello = append(serverHello, make([]byte, 32)...)
+	serverHello = append(serverHello, 0x13, 0x01, 0x00, 0x00, 0x06)
+	serverHello = append(serverHello, Tls13SupportedVersions...)
+	XtlsFilterTls(newPacket(serverHello), state, ctx)
+	if !state.EnableXtls {
+		t.Fatal("expect XTLS enabled by the server hello")
+	}
+
+	common.Must(writer.WriteMultiBuffer(newPacket([]byte{0x17, 0x03, 0x03, 0x00, 0x04, 1, 2, 3, 4})))
+	if !state.WriterSwitchToDirectCopy || state.Stats.WriterDirectCopyTime().IsZero() {
+		t.Error("expect direct copy")
+	}
+	if state.Stats.Relay() != VisionRelayTLS {
+		t.Error("unexpected relay ", state.Stats.Relay())
+	}
+}
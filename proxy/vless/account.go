@@ -15,6 +15,10 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 		ID:         protocol.NewID(id),
 		Flow:       a.Flow,       // needs parser here?
 		Encryption: a.Encryption, // needs parser here?
+
+		RejectQUICUDP443: a.RejectQuicUdp443,
+		TargetPorts:      protocol.NewPortRestriction(a.AllowedTargetPorts, a.BlockedTargetPorts),
+		PacketAddress:    a.PacketAddress,
 	}, nil
 }
 
@@ -26,6 +30,22 @@ type MemoryAccount struct {
 	Flow string
 	// Encryption of the account. Used for client connections, and only accepts "none" for now.
 	Encryption string
+	// RejectQUICUDP443 refuses QUIC to UDP port 443 for this user.
+	RejectQUICUDP443 bool
+	// TargetPorts restricts the destination ports of this user. Nil for the restriction of the inbound.
+	TargetPorts *protocol.PortRestriction
+	// PacketAddress sends UDP packets with their own destinations. Used for client connections.
+	PacketAddress bool
+}
+
+// RejectsQUIC implements protocol.QUICRejecter.
+func (a *MemoryAccount) RejectsQUIC() bool {
+	return a.RejectQUICUDP443
+}
+
+// PortRestriction implements protocol.PortRestricter.
+func (a *MemoryAccount) PortRestriction() *protocol.PortRestriction {
+	return a.TargetPorts
 }
 
 // Equals implements protocol.Account.Equals().
@@ -1,23 +1,33 @@
 package encoding
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"math"
+	"net"
 
 	"github.com/xtls/xray-core/common/buf"
+	xnet "github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/proxy/vless"
 	"google.golang.org/protobuf/proto"
 )
 
 func EncodeHeaderAddons(buffer *buf.Buffer, addons *Addons) error {
-	switch addons.Flow {
-	case vless.XRV:
+	switch {
+	case addons.Flow == vless.XRV, addons.PacketAddress:
 		bytes, err := proto.Marshal(addons)
 		if err != nil {
 			return newError("failed to marshal addons protobuf value").Base(err)
 		}
+		if len(bytes) > maxAddonsLength {
+			return newError("addons protobuf value too long: ", len(bytes))
+		}
 		if err := buffer.WriteByte(byte(len(bytes))); err != nil {
 			return newError("failed to write addons protobuf length").Base(err)
 		}
@@ -33,27 +43,88 @@ func EncodeHeaderAddons(buffer *buf.Buffer, addons *Addons) error {
 	return nil
 }
 
+// maxAddonsLength is the maximum length of the header addons, which carry no more than a flow and a seed.
+const maxAddonsLength = 128
+
+// ErrInvalidAddons is the root cause of errors decoding header addons that are too long or malformed.
+var ErrInvalidAddons = newError("invalid header addons")
+
 func DecodeHeaderAddons(buffer *buf.Buffer, reader io.Reader) (*Addons, error) {
-	addons := new(Addons)
 	buffer.Clear()
 	if _, err := buffer.ReadFullFrom(reader, 1); err != nil {
 		return nil, newError("failed to read addons protobuf length").Base(err)
 	}
 
-	if length := int32(buffer.Byte(0)); length != 0 {
-		buffer.Clear()
-		if _, err := buffer.ReadFullFrom(reader, length); err != nil {
+	length := int32(buffer.Byte(0))
+	if length == 0 {
+		return new(Addons), nil
+	}
+	if length > maxAddonsLength {
+		return nil, newError("addons protobuf length ", length, " exceeds ", maxAddonsLength).Base(ErrInvalidAddons)
+	}
+
+	buffer.Clear()
+	if _, err := buffer.ReadFullFrom(reader, length); err != nil {
+		return nil, newError("failed to read addons protobuf value").Base(err)
+	}
+	return unmarshalHeaderAddons(buffer.Bytes())
+}
+
+// checkHeaderAddons checks the header addons at the beginning of b, without consuming them.
+// Addons not wholly in b are left to DecodeHeaderAddons.
+func checkHeaderAddons(b []byte) error {
+	if len(b) == 0 || b[0] == 0 {
+		return nil
+	}
+	length := int(b[0])
+	if length > maxAddonsLength {
+		return newError("addons protobuf length ", length, " exceeds ", maxAddonsLength).Base(ErrInvalidAddons)
+	}
+	if len(b) < 1+length {
+		return nil
+	}
+	_, err := unmarshalHeaderAddons(b[1 : 1+length])
+	return err
+}
+
+// peekHeaderAddons returns the bytes following the user id in first, with the rest of the header
+// addons read into reader if the first read ended within them. Nothing is consumed, so that the
+// connection can still fall back with every byte read.
+func peekHeaderAddons(first *buf.Buffer, reader io.Reader) ([]byte, error) {
+	b := first.BytesFrom(17)
+	if len(b) == 0 || int(b[0]) > maxAddonsLength || len(b) >= 1+int(b[0]) {
+		return b, nil
+	}
+	br, ok := reader.(*buf.BufferedReader)
+	if !ok {
+		return b, nil
+	}
+	length := 1 + int(b[0])
+	b = append(make([]byte, 0, length), b...)
+	for len(b) < length {
+		mb, err := br.Reader.ReadMultiBuffer()
+		br.Buffer = append(br.Buffer, mb...)
+		if err != nil {
 			return nil, newError("failed to read addons protobuf value").Base(err)
 		}
-
-		if err := proto.Unmarshal(buffer.Bytes(), addons); err != nil {
-			return nil, newError("failed to unmarshal addons protobuf value").Base(err)
+		for _, buffer := range mb {
+			b = append(b, buffer.Bytes()...)
 		}
+	}
+	return b, nil
+}
 
-		// Verification.
-		switch addons.Flow {
-		default:
-		}
+func unmarshalHeaderAddons(b []byte) (*Addons, error) {
+	addons := new(Addons)
+	if err := proto.Unmarshal(b, addons); err != nil {
+		return nil, newError("failed to unmarshal addons protobuf value: ", err).Base(ErrInvalidAddons)
+	}
+
+	// Verification.
+	switch addons.Flow {
+	case "", vless.XRV:
+	default:
+		return nil, newError("unknown addons flow ", addons.Flow).Base(ErrInvalidAddons)
 	}
 
 	return addons, nil
@@ -62,7 +133,12 @@ func DecodeHeaderAddons(buffer *buf.Buffer, reader io.Reader) (*Addons, error) {
 // EncodeBodyAddons returns a Writer that auto-encrypt content written by caller.
 func EncodeBodyAddons(writer io.Writer, request *protocol.RequestHeader, requestAddons *Addons, state *proxy.TrafficState, context context.Context) buf.Writer {
 	if request.Command == protocol.RequestCommandUDP {
-		return NewMultiLengthPacketWriter(writer.(buf.Writer))
+		if requestAddons.PacketAddress {
+			return NewPacketAddressWriter(writer, request.Destination())
+		}
+		w := NewMultiLengthPacketWriter(writer.(buf.Writer))
+		w.Dropped = droppedPacketCounter(context)
+		return w
 	}
 	w := buf.NewWriter(writer)
 	if requestAddons.Flow == vless.XRV {
@@ -71,11 +147,37 @@ func EncodeBodyAddons(writer io.Writer, request *protocol.RequestHeader, request
 	return w
 }
 
+// droppedPacketCounter returns the counter of UDP packets the handler of ctx drops for being too large, nil
+// if there are no stats.
+func droppedPacketCounter(ctx context.Context) stats.Counter {
+	v := core.FromContext(ctx)
+	if v == nil {
+		return nil
+	}
+	sm, ok := v.GetFeature(stats.ManagerType()).(stats.Manager)
+	if !ok {
+		return nil
+	}
+	var name string
+	if outbounds := session.OutboundsFromContext(ctx); len(outbounds) > 0 && len(outbounds[len(outbounds)-1].Tag) > 0 {
+		name = "outbound>>>" + outbounds[len(outbounds)-1].Tag + ">>>dropped>>>udp"
+	} else if inbound := session.InboundFromContext(ctx); inbound != nil && len(inbound.Tag) > 0 {
+		name = "inbound>>>" + inbound.Tag + ">>>dropped>>>udp"
+	} else {
+		return nil
+	}
+	c, _ := stats.GetOrRegisterCounter(sm, name)
+	return c
+}
+
 // DecodeBodyAddons returns a Reader from which caller can fetch decrypted body.
 func DecodeBodyAddons(reader io.Reader, request *protocol.RequestHeader, addons *Addons) buf.Reader {
 	switch addons.Flow {
 	default:
 		if request.Command == protocol.RequestCommandUDP {
+			if addons.PacketAddress {
+				return NewPacketAddressReader(reader, request.Destination())
+			}
 			return NewLengthPacketReader(reader)
 		}
 	}
@@ -88,8 +190,12 @@ func NewMultiLengthPacketWriter(writer buf.Writer) *MultiLengthPacketWriter {
 	}
 }
 
+// MultiLengthPacketWriter writes each packet after its length. Peers may read a frame into one buffer only,
+// so longer packets are dropped; they need PacketAddress, which both ends negotiate.
 type MultiLengthPacketWriter struct {
 	buf.Writer
+	// Dropped counts the packets dropped for being too large, if set.
+	Dropped stats.Counter
 }
 
 func (w *MultiLengthPacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
@@ -97,22 +203,21 @@ func (w *MultiLengthPacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 	mb2Write := make(buf.MultiBuffer, 0, len(mb)+1)
 	for _, b := range mb {
 		length := b.Len()
-		if length == 0 || length+2 > buf.Size {
-			continue
-		}
-		eb := buf.New()
-		if err := eb.WriteByte(byte(length >> 8)); err != nil {
-			eb.Release()
-			continue
-		}
-		if err := eb.WriteByte(byte(length)); err != nil {
-			eb.Release()
+		if length == 0 {
 			continue
 		}
-		if _, err := eb.Write(b.Bytes()); err != nil {
-			eb.Release()
+		// the peer reads the frame into one buffer, which is shorter than maxPacketLength
+		if length+2 > buf.Size {
+			newError("dropped a UDP packet of ", length, " bytes").Base(ErrPacketTooLarge).AtWarning().WriteToLog()
+			if w.Dropped != nil {
+				w.Dropped.Add(1)
+			}
 			continue
 		}
+		eb := buf.New()
+		eb.WriteByte(byte(length >> 8))
+		eb.WriteByte(byte(length))
+		eb.Write(b.Bytes())
 		mb2Write = append(mb2Write, eb)
 	}
 	if mb2Write.IsEmpty() {
@@ -124,66 +229,251 @@ func (w *MultiLengthPacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 func NewLengthPacketWriter(writer io.Writer) *LengthPacketWriter {
 	return &LengthPacketWriter{
 		Writer: writer,
-		cache:  make([]byte, 0, 65536),
 	}
 }
 
 type LengthPacketWriter struct {
 	io.Writer
-	cache []byte
+	length [2]byte
+	cache  [][]byte
 }
 
+// WriteMultiBuffer writes mb as one packet. The payload is written as is after its length, without being copied.
 func (w *LengthPacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+
 	length := mb.Len() // none of mb is nil
 	// fmt.Println("Write", length)
 	if length == 0 {
 		return nil
 	}
+	if length > maxPacketLength {
+		return newError("packet of ", length, " bytes is over the limit of ", maxPacketLength).Base(ErrPacketTooLarge)
+	}
+
+	w.length[0], w.length[1] = byte(length>>8), byte(length)
+	bs := append(w.cache[:0], w.length[:])
+	for _, b := range mb {
+		bs = append(bs, b.Bytes())
+	}
 	defer func() {
-		w.cache = w.cache[:0]
+		for i := range bs {
+			bs[i] = nil
+		}
+		w.cache = bs[:0]
 	}()
-	w.cache = append(w.cache, byte(length>>8), byte(length))
-	for i, b := range mb {
-		w.cache = append(w.cache, b.Bytes()...)
-		b.Release()
-		mb[i] = nil
-	}
-	if _, err := w.Write(w.cache); err != nil {
+
+	nb := net.Buffers(bs)
+	if _, err := nb.WriteTo(w.Writer); err != nil {
 		return newError("failed to write a packet").Base(err)
 	}
 	return nil
 }
 
+// maxPacketLength is the largest payload of a UDP datagram, and so the longest packet the writers here frame and
+// LengthPacketReader accepts.
+const maxPacketLength = math.MaxUint16 - 8
+
+// ErrPacketTooLarge is returned by LengthPacketReader on frames longer than its MaxLength.
+var ErrPacketTooLarge = newError("packet too large")
+
 func NewLengthPacketReader(reader io.Reader) *LengthPacketReader {
 	return &LengthPacketReader{
-		Reader: reader,
-		cache:  make([]byte, 2),
+		Reader:    reader,
+		MaxLength: maxPacketLength,
+		cache:     make([]byte, 2),
 	}
 }
 
 type LengthPacketReader struct {
 	io.Reader
-	cache []byte
+	// MaxLength is the longest frame accepted. 0 means no limit other than the length field.
+	MaxLength int32
+	cache     []byte
 }
 
 func (r *LengthPacketReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
-	if _, err := io.ReadFull(r.Reader, r.cache); err != nil { // maybe EOF
+	if _, err := io.ReadFull(r.Reader, r.cache); err != nil {
+		if err == io.EOF {
+			// The stream ends between frames.
+			return nil, io.EOF
+		}
 		return nil, newError("failed to read packet length").Base(err)
 	}
 	length := int32(r.cache[0])<<8 | int32(r.cache[1])
 	// fmt.Println("Read", length)
-	mb := make(buf.MultiBuffer, 0, length/buf.Size+1)
-	for length > 0 {
-		size := length
-		if size > buf.Size {
-			size = buf.Size
+	if r.MaxLength > 0 && length > r.MaxLength {
+		return nil, newError("packet of ", length, " bytes is over the limit of ", r.MaxLength).Base(ErrPacketTooLarge)
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	var b *buf.Buffer
+	var payload []byte
+	if length > buf.Size {
+		// Large packets are kept in one buffer, as each buffer is a packet.
+		b = buf.NewExisted(make([]byte, length))
+		payload = b.Bytes()
+	} else {
+		b = buf.New()
+		payload = b.Extend(length)
+	}
+	if _, err := io.ReadFull(r.Reader, payload); err != nil {
+		b.Release()
+		return nil, newError("failed to read packet payload").Base(err)
+	}
+	return buf.MultiBuffer{b}, nil
+}
+
+// Packets framed by PacketAddressWriter start with one of these, telling if an address follows.
+const (
+	packetToTarget  byte = 0
+	packetToAddress byte = 1
+)
+
+func NewPacketAddressWriter(writer io.Writer, target xnet.Destination) *PacketAddressWriter {
+	return &PacketAddressWriter{
+		Writer: writer,
+		Target: target,
+	}
+}
+
+// PacketAddressWriter writes each packet after its length and, unless it is for Target, its address and port.
+type PacketAddressWriter struct {
+	io.Writer
+	// Target is the destination of the request, which packets without one of their own go to.
+	Target xnet.Destination
+	cache  [][]byte
+}
+
+func (w *PacketAddressWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+
+	headers := make(buf.MultiBuffer, 0, len(mb))
+	defer buf.ReleaseMulti(headers)
+
+	bs := w.cache[:0]
+	defer func() {
+		for i := range bs {
+			bs[i] = nil
+		}
+		w.cache = bs[:0]
+	}()
+
+	for _, b := range mb {
+		if b.IsEmpty() {
+			continue
+		}
+		header := buf.New()
+		headers = append(headers, header)
+		header.Extend(2)
+		if b.UDP == nil || (b.UDP.Address == w.Target.Address && b.UDP.Port == w.Target.Port) {
+			header.WriteByte(packetToTarget)
+		} else {
+			header.WriteByte(packetToAddress)
+			if err := addrParser.WriteAddressPort(header, b.UDP.Address, b.UDP.Port); err != nil {
+				newError("dropped a UDP packet to ", b.UDP).Base(err).AtWarning().WriteToLog()
+				continue
+			}
+		}
+		length := header.Len() - 2 + b.Len()
+		if b.Len() > maxPacketLength || length > math.MaxUint16 {
+			newError("dropped a UDP packet of ", b.Len(), " bytes").Base(ErrPacketTooLarge).AtWarning().WriteToLog()
+			continue
+		}
+		header.SetByte(0, byte(length>>8))
+		header.SetByte(1, byte(length))
+		bs = append(bs, header.Bytes(), b.Bytes())
+	}
+	if len(bs) == 0 {
+		return nil
+	}
+
+	nb := net.Buffers(bs)
+	if _, err := nb.WriteTo(w.Writer); err != nil {
+		return newError("failed to write packets").Base(err)
+	}
+	return nil
+}
+
+func NewPacketAddressReader(reader io.Reader, target xnet.Destination) *PacketAddressReader {
+	return &PacketAddressReader{
+		Reader: reader,
+		Target: target,
+	}
+}
+
+// PacketAddressReader reads packets written by PacketAddressWriter, with their destinations in Buffer.UDP.
+type PacketAddressReader struct {
+	io.Reader
+	// Target is the destination of the request, for packets without one of their own.
+	Target xnet.Destination
+	// Restriction drops packets to the ports it does not permit. Nil permits all ports.
+	Restriction *protocol.PortRestriction
+	cache       [2]byte
+}
+
+func (r *PacketAddressReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	for {
+		b, err := r.readPacket()
+		if err != nil || b == nil {
+			return nil, err
 		}
-		length -= size
-		b := buf.New()
-		if _, err := b.ReadFullFrom(r.Reader, size); err != nil {
-			return nil, newError("failed to read packet payload").Base(err)
+		if !r.Restriction.Permits(b.UDP.Port) {
+			newError("dropped a UDP packet to ", b.UDP, ", whose port is not allowed").AtInfo().WriteToLog()
+			b.Release()
+			continue
 		}
-		mb = append(mb, b)
+		return buf.MultiBuffer{b}, nil
+	}
+}
+
+func (r *PacketAddressReader) readPacket() (*buf.Buffer, error) {
+	if _, err := io.ReadFull(r.Reader, r.cache[:]); err != nil {
+		if err == io.EOF {
+			// The stream ends between frames.
+			return nil, io.EOF
+		}
+		return nil, newError("failed to read packet length").Base(err)
+	}
+	length := int32(r.cache[0])<<8 | int32(r.cache[1])
+	if length == 0 {
+		return nil, nil
+	}
+	var b *buf.Buffer
+	var frame []byte
+	if length > buf.Size {
+		b = buf.NewExisted(make([]byte, length))
+		frame = b.Bytes()
+	} else {
+		b = buf.New()
+		frame = b.Extend(length)
+	}
+	if _, err := io.ReadFull(r.Reader, frame); err != nil {
+		b.Release()
+		return nil, newError("failed to read packet").Base(err)
+	}
+
+	dest := r.Target
+	switch frame[0] {
+	case packetToTarget:
+		b.Advance(1)
+	case packetToAddress:
+		addrBuffer := buf.StackNew()
+		reader := bytes.NewReader(frame[1:])
+		addr, port, err := addrParser.ReadAddressPort(&addrBuffer, reader)
+		addrBuffer.Release()
+		if err != nil {
+			b.Release()
+			return nil, newError("failed to read packet address").Base(err)
+		}
+		dest.Address, dest.Port = addr, port
+		b.Advance(int32(len(frame) - reader.Len()))
+	default:
+		b.Release()
+		return nil, newError("unknown packet address flag ", frame[0])
 	}
-	return mb, nil
+	dest.Network = xnet.Network_UDP
+	b.UDP = &dest
+	return b, nil
 }
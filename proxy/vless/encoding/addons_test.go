@@ -0,0 +1,458 @@
+package encoding_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/proxy/vless"
+	. "github.com/xtls/xray-core/proxy/vless/encoding"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecodeInvalidHeaderAddons(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Input   []byte
+		Invalid bool
+	}{
+		{
+			Name:    "oversized",
+			Input:   append([]byte{200}, make([]byte, 200)...),
+			Invalid: true,
+		},
+		{
+			Name:    "malformed",
+			Input:   []byte{3, 0xff, 0xff, 0xff},
+			Invalid: true,
+		},
+		{
+			Name:  "truncated",
+			Input: []byte{10, 0x0a, 0x02},
+		},
+	}
+
+	for _, c := range cases {
+		buffer := buf.New()
+		_, err := DecodeHeaderAddons(buffer, bytes.NewReader(c.Input))
+		buffer.Release()
+		if err == nil {
+			t.Error(c.Name, ": expect error, but got nil")
+			continue
+		}
+		if invalid := errors.Cause(err) == ErrInvalidAddons; invalid != c.Invalid {
+			t.Error(c.Name, ": expect invalid addons ", c.Invalid, ", but got ", err)
+		}
+	}
+}
+
+func TestInvalidHeaderAddonsFallback(t *testing.T) {
+	id := uuid.New()
+	user := &protocol.MemoryUser{
+		Email:   "test@example.com",
+		Account: toAccount(&vless.Account{Id: id.String()}),
+	}
+	validator := new(vless.Validator)
+	validator.Add(user)
+
+	first := buf.New()
+	defer first.Release()
+	first.WriteByte(Version)
+	first.Write(id.Bytes())
+	first.WriteByte(200)
+	first.Write(bytes.Repeat([]byte{0xff}, 200))
+	firstLen := first.Len()
+
+	reader := &buf.BufferedReader{
+		Reader: buf.NewReader(bytes.NewReader(nil)),
+		Buffer: buf.MultiBuffer{first},
+	}
+	_, _, isfb, err := DecodeRequestHeader(true, first, reader, validator)
+	if errors.Cause(err) != ErrInvalidAddons {
+		t.Fatal("expect invalid addons, but got ", err)
+	}
+	if !isfb {
+		t.Error("expect falling back on invalid addons")
+	}
+	if first.Len() != firstLen {
+		t.Error("first buffer consumed: ", first.Len(), " bytes left of ", firstLen)
+	}
+}
+
+// probe decodes header as the inbound does, with the first read ending after firstLen bytes and
+// the rest read a byte at a time. It returns the bytes replayed to the fallback, nil without one.
+func probe(t *testing.T, validator *vless.Validator, header []byte, firstLen int, fallback bool) []byte {
+	first := buf.New()
+	first.Write(header[:firstLen])
+	reader := &buf.BufferedReader{
+		Reader: buf.NewReader(iotest.OneByteReader(bytes.NewReader(header[firstLen:]))),
+		Buffer: buf.MultiBuffer{first},
+	}
+	_, _, isfb, err := DecodeRequestHeader(fallback, first, reader, validator)
+	if err == nil {
+		t.Fatal("expect error, but got nil")
+	}
+	if !isfb {
+		return nil
+	}
+	var replayed bytes.Buffer
+	common.Must(buf.Copy(reader, buf.NewWriter(&replayed)))
+	return replayed.Bytes()
+}
+
+func TestInvalidHeaderAddonsProbe(t *testing.T) {
+	id := uuid.New()
+	user := &protocol.MemoryUser{
+		Email:   "test@example.com",
+		Account: toAccount(&vless.Account{Id: id.String()}),
+	}
+	validator := new(vless.Validator)
+	validator.Add(user)
+
+	newHeader := func(id uuid.UUID, addons []byte) []byte {
+		header := append([]byte{Version}, id.Bytes()...)
+		header = append(header, addons...)
+		return append(header, byte(protocol.RequestCommandTCP), 1, 187, byte(protocol.AddressTypeIPv4), 127, 0, 0, 1)
+	}
+	unknownFlow, err := proto.Marshal(&Addons{Flow: vless.XRV + "-unknown"})
+	common.Must(err)
+
+	cases := []struct {
+		Name     string
+		Header   []byte
+		FirstLen int
+	}{
+		{
+			Name:     "invalid user id",
+			Header:   newHeader(uuid.New(), []byte{0}),
+			FirstLen: 26,
+		},
+		{
+			Name:     "malformed addons",
+			Header:   newHeader(id, []byte{3, 0xff, 0xff, 0xff}),
+			FirstLen: 29,
+		},
+		{
+			Name:     "malformed addons past the first read",
+			Header:   newHeader(id, []byte{3, 0xff, 0xff, 0xff}),
+			FirstLen: 19,
+		},
+		{
+			Name:     "unknown flow",
+			Header:   newHeader(id, append([]byte{byte(len(unknownFlow))}, unknownFlow...)),
+			FirstLen: 18,
+		},
+		{
+			Name:     "truncated addons",
+			Header:   append(append([]byte{Version}, id.Bytes()...), 10, 0x0a, 0x02),
+			FirstLen: 18,
+		},
+	}
+
+	for _, c := range cases {
+		if replayed := probe(t, validator, c.Header, c.FirstLen, false); replayed != nil {
+			t.Error(c.Name, ": expect no fallback, but replayed ", len(replayed), " bytes")
+		}
+		if replayed := probe(t, validator, c.Header, c.FirstLen, true); !bytes.Equal(replayed, c.Header) {
+			t.Error(c.Name, ": expect the probe replayed to the fallback, but got ", replayed)
+		}
+	}
+
+	// valid addons past the first read are consumed as usual
+	addons := buf.New()
+	common.Must(EncodeHeaderAddons(addons, &Addons{Flow: vless.XRV}))
+	defer addons.Release()
+	header := append(newHeader(id, addons.Bytes()), "payload"...)
+	first := buf.New()
+	first.Write(header[:18])
+	reader := &buf.BufferedReader{
+		Reader: buf.NewReader(iotest.OneByteReader(bytes.NewReader(header[18:]))),
+		Buffer: buf.MultiBuffer{first},
+	}
+	request, requestAddons, _, err := DecodeRequestHeader(true, first, reader, validator)
+	common.Must(err)
+	if requestAddons.Flow != vless.XRV || request.Port != 443 {
+		t.Error("expect ", vless.XRV, " to port 443, but got ", requestAddons.Flow, " to port ", request.Port)
+	}
+	var payload bytes.Buffer
+	common.Must(buf.Copy(reader, buf.NewWriter(&payload)))
+	if payload.String() != "payload" {
+		t.Error("expect the payload after the header, but got ", payload.String())
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += n
+	return n, err
+}
+
+func FuzzDecodeHeaderAddons(f *testing.F) {
+	buffer := buf.New()
+	common.Must(EncodeHeaderAddons(buffer, &Addons{Flow: vless.XRV}))
+	f.Add(buffer.Bytes())
+	buffer.Release()
+	f.Add([]byte{0})
+	f.Add([]byte{255, 1, 2, 3})
+	f.Add([]byte{3, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := &countingReader{Reader: bytes.NewReader(data)}
+		buffer := buf.New()
+		defer buffer.Release()
+		addons, err := DecodeHeaderAddons(buffer, reader)
+		if reader.n > 1+128 {
+			t.Fatal("read ", reader.n, " bytes of addons")
+		}
+		if err == nil && addons == nil {
+			t.Fatal("nil addons without error")
+		}
+	})
+}
+
+func TestLargeUDPPacket(t *testing.T) {
+	request := &protocol.RequestHeader{
+		Version: Version,
+		Command: protocol.RequestCommandUDP,
+		Address: net.LocalHostIP,
+		Port:    net.Port(1234),
+	}
+
+	newPackets := func() (buf.MultiBuffer, string) {
+		large := buf.NewExisted(make([]byte, 9000))
+		common.Must2(rand.Read(large.Bytes()))
+		small := buf.New()
+		small.WriteString("small")
+		return buf.MultiBuffer{large, buf.NewExisted(make([]byte, 70000)), small}, large.String()
+	}
+
+	// without PacketAddress, a peer reads each frame into one buffer, so the large packets are dropped
+	var wire bytes.Buffer
+	bufferWriter := buf.NewBufferedWriter(buf.NewWriter(&wire))
+	common.Must(bufferWriter.SetBuffered(false))
+	writer := EncodeBodyAddons(bufferWriter, request, &Addons{}, nil, context.Background())
+	mb, _ := newPackets()
+	common.Must(writer.WriteMultiBuffer(mb))
+
+	reader := DecodeBodyAddons(&wire, request, &Addons{})
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	if mb.String() != "small" {
+		t.Error("expect only the small packet, but got ", mb.Len(), " bytes")
+	}
+	buf.ReleaseMulti(mb)
+	if wire.Len() != 0 {
+		t.Error("expect the large packets to be dropped, but ", wire.Len(), " bytes left")
+	}
+
+	// with PacketAddress, packets up to the frame limit get through in one buffer
+	wire.Reset()
+	addons := &Addons{PacketAddress: true}
+	writer = EncodeBodyAddons(&wire, request, addons, nil, context.Background())
+	mb, payload := newPackets()
+	common.Must(writer.WriteMultiBuffer(mb))
+
+	reader = DecodeBodyAddons(&wire, request, addons)
+	mb, err = reader.ReadMultiBuffer()
+	common.Must(err)
+	if len(mb) != 1 || mb[0].String() != payload {
+		t.Fatal("expect the large packet in one buffer, but got ", len(mb), " buffers of ", mb.Len(), " bytes")
+	}
+	buf.ReleaseMulti(mb)
+	mb, err = reader.ReadMultiBuffer()
+	common.Must(err)
+	if mb.String() != "small" {
+		t.Error("expect the small packet after the large one, but got ", mb.String())
+	}
+	buf.ReleaseMulti(mb)
+}
+
+func TestLengthPacketReader(t *testing.T) {
+	reader := NewLengthPacketReader(bytes.NewReader([]byte{0, 0, 0, 3, 'a', 'b', 'c'}))
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	if !mb.IsEmpty() {
+		t.Error("expect no payload in a zero-length frame, but got ", mb.Len(), " bytes")
+	}
+	mb, err = reader.ReadMultiBuffer()
+	common.Must(err)
+	if mb.String() != "abc" {
+		t.Error("expect abc after a zero-length frame, but got ", mb.String())
+	}
+	buf.ReleaseMulti(mb)
+	if _, err := reader.ReadMultiBuffer(); err != io.EOF {
+		t.Error("expect io.EOF between frames, but got ", err)
+	}
+
+	reader = NewLengthPacketReader(bytes.NewReader([]byte{0, 10, 'a', 'b', 'c'}))
+	if _, err := reader.ReadMultiBuffer(); err == nil || err == io.EOF {
+		t.Error("expect error on a truncated frame, but got ", err)
+	}
+
+	reader = NewLengthPacketReader(bytes.NewReader([]byte{0}))
+	if _, err := reader.ReadMultiBuffer(); err == nil || err == io.EOF {
+		t.Error("expect error on a truncated length, but got ", err)
+	}
+
+	reader = NewLengthPacketReader(bytes.NewReader([]byte{0x10, 0, 'a'}))
+	reader.MaxLength = 1024
+	if _, err := reader.ReadMultiBuffer(); errors.Cause(err) != ErrPacketTooLarge {
+		t.Error("expect packet too large, but got ", err)
+	}
+}
+
+func TestLengthPacketWriter(t *testing.T) {
+	var wire bytes.Buffer
+	writer := NewLengthPacketWriter(&wire)
+
+	mb := buf.MultiBuffer{buf.New(), buf.New()}
+	mb[0].WriteString("abc")
+	mb[1].WriteString("defg")
+	common.Must(writer.WriteMultiBuffer(mb))
+
+	// the longest payload of a UDP datagram, which the reader takes
+	const maxPacketLength = math.MaxUint16 - 8
+	common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{buf.NewExisted(make([]byte, maxPacketLength))}))
+	if err := writer.WriteMultiBuffer(buf.MultiBuffer{buf.NewExisted(make([]byte, maxPacketLength+1))}); errors.Cause(err) != ErrPacketTooLarge {
+		t.Error("expect packet too large, but got ", err)
+	}
+
+	reader := NewLengthPacketReader(&wire)
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	if mb.String() != "abcdefg" {
+		t.Error("expect abcdefg, but got ", mb.String())
+	}
+	buf.ReleaseMulti(mb)
+	mb, err = reader.ReadMultiBuffer()
+	common.Must(err)
+	if mb.Len() != maxPacketLength {
+		t.Error("expect a packet of ", maxPacketLength, " bytes, but got ", mb.Len())
+	}
+	buf.ReleaseMulti(mb)
+	if wire.Len() != 0 {
+		t.Error("expect nothing written for the packet too large, but got ", wire.Len(), " bytes")
+	}
+}
+
+func TestPacketAddress(t *testing.T) {
+	buffer := buf.New()
+	common.Must(EncodeHeaderAddons(buffer, &Addons{PacketAddress: true}))
+	addons, err := DecodeHeaderAddons(buf.New(), buffer)
+	common.Must(err)
+	if !addons.PacketAddress {
+		t.Fatal("expect PacketAddress in decoded header addons")
+	}
+
+	request := &protocol.RequestHeader{
+		Version: Version,
+		Command: protocol.RequestCommandUDP,
+		Address: net.ParseAddress("1.1.1.1"),
+		Port:    3478,
+	}
+	var wire bytes.Buffer
+	writer := EncodeBodyAddons(&wire, request, addons, nil, context.Background())
+
+	packets := []struct {
+		UDP      *net.Destination
+		Expected net.Destination
+	}{
+		{UDP: nil, Expected: request.Destination()},
+		{UDP: &net.Destination{Network: net.Network_UDP, Address: net.ParseAddress("1.1.1.1"), Port: 3478}, Expected: request.Destination()},
+		{UDP: &net.Destination{Network: net.Network_UDP, Address: net.ParseAddress("2001:db8::1"), Port: 3479}, Expected: net.UDPDestination(net.ParseAddress("2001:db8::1"), 3479)},
+		{UDP: &net.Destination{Network: net.Network_UDP, Address: net.DomainAddress("stun.example.com"), Port: 25}},
+		{UDP: &net.Destination{Network: net.Network_UDP, Address: net.DomainAddress("stun.example.com"), Port: 19302}, Expected: net.UDPDestination(net.DomainAddress("stun.example.com"), 19302)},
+	}
+	mb := make(buf.MultiBuffer, 0, len(packets))
+	for i, p := range packets {
+		b := buf.New()
+		b.WriteByte(byte(i))
+		b.UDP = p.UDP
+		mb = append(mb, b)
+	}
+	common.Must(writer.WriteMultiBuffer(mb))
+
+	reader := DecodeBodyAddons(&wire, request, addons).(*PacketAddressReader)
+	reader.Restriction = &protocol.PortRestriction{Blocked: net.MemoryPortList{{From: 25, To: 25}}}
+	for i, p := range packets {
+		if !p.Expected.IsValid() {
+			// dropped for its port
+			continue
+		}
+		mb, err := reader.ReadMultiBuffer()
+		common.Must(err)
+		if len(mb) != 1 || mb[0].Len() != 1 || mb[0].Byte(0) != byte(i) {
+			t.Fatal("expect packet ", i, ", but got ", mb)
+		}
+		if mb[0].UDP == nil || *mb[0].UDP != p.Expected {
+			t.Error("expect packet ", i, " to ", p.Expected, ", but got ", mb[0].UDP)
+		}
+		buf.ReleaseMulti(mb)
+	}
+	if _, err := reader.ReadMultiBuffer(); err != io.EOF {
+		t.Error("expect io.EOF after all packets, but got ", err)
+	}
+}
+
+// cacheLengthPacketWriter is LengthPacketWriter as it was before writing with net.Buffers,
+// copying each packet into a cache first. It is kept as the baseline of BenchmarkLengthPacketWriter.
+type cacheLengthPacketWriter struct {
+	io.Writer
+	cache []byte
+}
+
+func (w *cacheLengthPacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	length := mb.Len()
+	if length == 0 {
+		return nil
+	}
+	defer func() {
+		w.cache = w.cache[:0]
+	}()
+	w.cache = append(w.cache, byte(length>>8), byte(length))
+	for i, b := range mb {
+		w.cache = append(w.cache, b.Bytes()...)
+		b.Release()
+		mb[i] = nil
+	}
+	_, err := w.Write(w.cache)
+	return err
+}
+
+func BenchmarkLengthPacketWriter(b *testing.B) {
+	writers := []struct {
+		Name   string
+		Writer buf.Writer
+	}{
+		{Name: "Cache", Writer: &cacheLengthPacketWriter{Writer: io.Discard, cache: make([]byte, 0, 65536)}},
+		{Name: "Buffers", Writer: NewLengthPacketWriter(io.Discard)},
+	}
+	for _, w := range writers {
+		writer := w.Writer
+		b.Run(w.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				mb := buf.MultiBuffer{buf.New(), buf.New(), buf.New()}
+				for _, b := range mb {
+					b.Extend(buf.Size / 2)
+				}
+				common.Must(writer.WriteMultiBuffer(mb))
+			}
+		})
+	}
+}
@@ -99,6 +99,14 @@ func DecodeRequestHeader(isfb bool, first *buf.Buffer, reader io.Reader, validat
 		}
 
 		if isfb {
+			// Addons are checked before the first buffer is consumed, so that bad ones can still fall back.
+			b, err := peekHeaderAddons(first, reader)
+			if err == nil {
+				err = checkHeaderAddons(b)
+			}
+			if err != nil {
+				return nil, nil, isfb, newError("failed to decode request header addons").Base(err)
+			}
 			first.Advance(17)
 		}
 
@@ -19,6 +19,7 @@ import (
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/quic"
 	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
@@ -36,7 +37,12 @@ import (
 	"github.com/xtls/xray-core/transport/internet/tls"
 )
 
+func validateConfig(v *core.Validation, path string, c interface{}) {
+	proxy.ValidateFallbacks(v, path, len(c.(*Config).Fallbacks))
+}
+
 func init() {
+	common.Must(core.RegisterConfigValidator((*Config)(nil), validateConfig))
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		var dc dns.Client
 		if err := core.RequireFeatures(ctx, func(d dns.Client) error {
@@ -56,6 +62,7 @@ type Handler struct {
 	validator             *vless.Validator
 	dns                   dns.Client
 	fallbacks             map[string]map[string]map[string]*Fallback // or nil
+	targetPorts           *protocol.PortRestriction
 	// regexps               map[string]*regexp.Regexp       // or nil
 }
 
@@ -67,6 +74,7 @@ func New(ctx context.Context, config *Config, dc dns.Client) (*Handler, error) {
 		policyManager:         v.GetFeature(policy.ManagerType()).(policy.Manager),
 		validator:             new(vless.Validator),
 		dns:                   dc,
+		targetPorts:           protocol.NewPortRestriction(config.AllowedTargetPorts, config.BlockedTargetPorts),
 	}
 
 	for _, user := range config.Clients {
@@ -184,7 +192,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 		iConn = statConn.Connection
 	}
 
-	sessionPolicy := h.policyManager.ForLevel(0)
+	sessionPolicy := policy.ForContext(ctx, h.policyManager, 0)
 	if err := connection.SetReadDeadline(time.Now().Add(sessionPolicy.Timeouts.Handshake)); err != nil {
 		return newError("unable to set read deadline").Base(err).AtWarning()
 	}
@@ -407,8 +415,8 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 			}
 
 			if err := task.Run(ctx, task.OnSuccess(postRequest, task.Close(serverWriter)), task.OnSuccess(getResponse, task.Close(writer))); err != nil {
-				common.Interrupt(serverReader)
-				common.Interrupt(serverWriter)
+				common.InterruptWithError(serverReader, err)
+				common.InterruptWithError(serverWriter, err)
 				return newError("fallback ends").Base(err).AtInfo()
 			}
 			return nil
@@ -437,12 +445,18 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	}
 	inbound.Name = "vless"
 	inbound.User = request.User
+	ctx = session.ContextWithPortRestriction(ctx, protocol.UserPortRestriction(request.User, h.targetPorts))
 
 	account := request.User.Account.(*vless.MemoryAccount)
 
 	responseAddons := &encoding.Addons{
 		// Flow: requestAddons.Flow,
 	}
+	if request.Command == protocol.RequestCommandUDP && requestAddons.PacketAddress {
+		responseAddons.PacketAddress = true
+	} else {
+		requestAddons.PacketAddress = false
+	}
 
 	var input *bytes.Reader
 	var rawInput *bytes.Buffer
@@ -499,12 +513,35 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 		ctx = session.ContextWithAllowedNetwork(ctx, net.Network_UDP)
 	}
 
-	sessionPolicy = h.policyManager.ForLevel(request.User.Level)
+	sessionPolicy = policy.ForContext(ctx, h.policyManager, request.User.Level)
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
 	inbound.Timer = timer
 	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
 
+	// default: clientReader := reader
+	clientReader := encoding.DecodeBodyAddons(reader, request, requestAddons)
+	if r, ok := clientReader.(*encoding.PacketAddressReader); ok {
+		r.Restriction = session.PortRestrictionFromContext(ctx)
+	}
+
+	if request.Command == protocol.RequestCommandUDP && request.Port == 443 && account.RejectQUICUDP443 {
+		// the first packet decides, before anything is dispatched
+		if err := connection.SetReadDeadline(time.Now().Add(sessionPolicy.Timeouts.Handshake)); err != nil {
+			newError("unable to set read deadline").Base(err).AtWarning().WriteToLog(sid)
+		}
+		mb, err := clientReader.ReadMultiBuffer()
+		if err != nil {
+			return newError("failed to read first UDP packet").Base(err).AtInfo()
+		}
+		connection.SetReadDeadline(time.Time{})
+		if !mb.IsEmpty() && quic.ShouldReject(request.User, request.Destination(), mb[0].Bytes()) {
+			buf.ReleaseMulti(mb)
+			return newError("QUIC to ", request.Destination(), " is refused for ", request.User.Email).AtInfo()
+		}
+		clientReader = &buf.BufferedReader{Reader: clientReader, Buffer: mb}
+	}
+
 	link, err := dispatcher.Dispatch(ctx, request.Destination())
 	if err != nil {
 		return newError("failed to dispatch request to ", request.Destination()).Base(err).AtWarning()
@@ -513,12 +550,12 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	serverReader := link.Reader // .(*pipe.Reader)
 	serverWriter := link.Writer // .(*pipe.Writer)
 	trafficState := proxy.NewTrafficState(account.ID.Bytes())
+	if requestAddons.Flow == vless.XRV {
+		defer proxy.TrackTrafficState(ctx, trafficState)()
+	}
 	postRequest := func() error {
 		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
 
-		// default: clientReader := reader
-		clientReader := encoding.DecodeBodyAddons(reader, request, requestAddons)
-
 		var err error
 
 		if requestAddons.Flow == vless.XRV {
@@ -554,8 +591,16 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 		if err := clientWriter.WriteMultiBuffer(multiBuffer); err != nil {
 			return err // ...
 		}
-		// Flush; bufferWriter.WriteMultiBufer now is bufferWriter.writer.WriteMultiBuffer
-		if err := bufferWriter.SetBuffered(false); err != nil {
+		_, ok := serverReader.(buf.TimeoutReader)
+		copyDelay := sessionPolicy.Latency.CopyDelay()
+		if copyDelay > 0 && ok && requestAddons.Flow != vless.XRV {
+			// keep coalescing small writes, Copy flushes them within copyDelay
+			if err := bufferWriter.Flush(); err != nil {
+				return newError("failed to write A response payload").Base(err).AtWarning()
+			}
+			clientWriter = &buf.FlushWriter{Writer: clientWriter, Flusher: bufferWriter}
+		} else if err := bufferWriter.SetBuffered(false); err != nil {
+			// Flush; bufferWriter.WriteMultiBufer now is bufferWriter.writer.WriteMultiBuffer
 			return newError("failed to write A response payload").Base(err).AtWarning()
 		}
 
@@ -564,7 +609,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 			err = encoding.XtlsWrite(serverReader, clientWriter, timer, connection, trafficState, nil, ctx)
 		} else {
 			// from serverReader.ReadMultiBuffer to clientWriter.WriteMultiBufer
-			err = buf.Copy(serverReader, clientWriter, buf.UpdateActivity(timer))
+			err = buf.Copy(serverReader, clientWriter, buf.UpdateActivity(timer), buf.WithMaxLatency(copyDelay))
 		}
 		if err != nil {
 			return newError("failed to transfer response payload").Base(err).AtInfo()
@@ -578,8 +623,8 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	}
 
 	if err := task.Run(ctx, task.OnSuccess(postRequest, task.Close(serverWriter)), getResponse); err != nil {
-		common.Interrupt(serverReader)
-		common.Interrupt(serverWriter)
+		common.InterruptWithError(serverReader, err)
+		common.InterruptWithError(serverWriter, err)
 		return newError("connection ends").Base(err).AtInfo()
 	}
 
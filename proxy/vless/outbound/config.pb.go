@@ -21,12 +21,74 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// VisionMode is how the xtls-rprx-vision flow tells what a connection carries.
+type VisionMode int32
+
+const (
+	// Looks for a TLS handshake, and relays the connection plainly once
+	// vision_plain_threshold bytes went by without one.
+	VisionMode_AUTO VisionMode = 0
+	// Looks for a TLS handshake in the first packets, however many bytes they
+	// hold.
+	VisionMode_TLS VisionMode = 1
+	// Relays the connection plainly, with the padding ended after the request
+	// header.
+	VisionMode_PLAIN VisionMode = 2
+)
+
+// Enum value maps for VisionMode.
+var (
+	VisionMode_name = map[int32]string{
+		0: "AUTO",
+		1: "TLS",
+		2: "PLAIN",
+	}
+	VisionMode_value = map[string]int32{
+		"AUTO":  0,
+		"TLS":   1,
+		"PLAIN": 2,
+	}
+)
+
+func (x VisionMode) Enum() *VisionMode {
+	p := new(VisionMode)
+	*p = x
+	return p
+}
+
+func (x VisionMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (VisionMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_proxy_vless_outbound_config_proto_enumTypes[0].Descriptor()
+}
+
+func (VisionMode) Type() protoreflect.EnumType {
+	return &file_proxy_vless_outbound_config_proto_enumTypes[0]
+}
+
+func (x VisionMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use VisionMode.Descriptor instead.
+func (VisionMode) EnumDescriptor() ([]byte, []int) {
+	return file_proxy_vless_outbound_config_proto_rawDescGZIP(), []int{0}
+}
+
 type Config struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	Vnext []*protocol.ServerEndpoint `protobuf:"bytes,1,rep,name=vnext,proto3" json:"vnext,omitempty"`
+	// How the queries to port 53 over UDP are handled: "tunnel" (default),
+	// "local" or "skip".
+	InterceptDns string     `protobuf:"bytes,2,opt,name=intercept_dns,json=interceptDns,proto3" json:"intercept_dns,omitempty"`
+	VisionMode   VisionMode `protobuf:"varint,3,opt,name=vision_mode,json=visionMode,proto3,enum=xray.proxy.vless.outbound.VisionMode" json:"vision_mode,omitempty"`
+	// Defaults to 2048.
+	VisionPlainThreshold uint32 `protobuf:"varint,4,opt,name=vision_plain_threshold,json=visionPlainThreshold,proto3" json:"vision_plain_threshold,omitempty"`
 }
 
 func (x *Config) Reset() {
@@ -68,6 +130,27 @@ func (x *Config) GetVnext() []*protocol.ServerEndpoint {
 	return nil
 }
 
+func (x *Config) GetInterceptDns() string {
+	if x != nil {
+		return x.InterceptDns
+	}
+	return ""
+}
+
+func (x *Config) GetVisionMode() VisionMode {
+	if x != nil {
+		return x.VisionMode
+	}
+	return VisionMode_AUTO
+}
+
+func (x *Config) GetVisionPlainThreshold() uint32 {
+	if x != nil {
+		return x.VisionPlainThreshold
+	}
+	return 0
+}
+
 var File_proxy_vless_outbound_config_proto protoreflect.FileDescriptor
 
 var file_proxy_vless_outbound_config_proto_rawDesc = []byte{
@@ -77,11 +160,24 @@ var file_proxy_vless_outbound_config_proto_rawDesc = []byte{
 	0x76, 0x6c, 0x65, 0x73, 0x73, 0x2e, 0x6f, 0x75, 0x74, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x1a, 0x21,
 	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2f,
 	0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x73, 0x70, 0x65, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x22, 0x44, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x3a, 0x0a, 0x05, 0x76,
-	0x6e, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x78, 0x72, 0x61,
-	0x79, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
-	0x6c, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
-	0x52, 0x05, 0x76, 0x6e, 0x65, 0x78, 0x74, 0x42, 0x6d, 0x0a, 0x1d, 0x63, 0x6f, 0x6d, 0x2e, 0x78,
+	0x6f, 0x22, 0xe7, 0x01, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x3a, 0x0a, 0x05,
+	0x76, 0x6e, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x78, 0x72,
+	0x61, 0x79, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x6f, 0x6c, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e,
+	0x74, 0x52, 0x05, 0x76, 0x6e, 0x65, 0x78, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x6e, 0x74, 0x65,
+	0x72, 0x63, 0x65, 0x70, 0x74, 0x5f, 0x64, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0c, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x63, 0x65, 0x70, 0x74, 0x44, 0x6e, 0x73, 0x12, 0x46, 0x0a,
+	0x0b, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x25, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x2e,
+	0x76, 0x6c, 0x65, 0x73, 0x73, 0x2e, 0x6f, 0x75, 0x74, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x2e, 0x56,
+	0x69, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x6f, 0x64, 0x65, 0x52, 0x0a, 0x76, 0x69, 0x73, 0x69, 0x6f,
+	0x6e, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x34, 0x0a, 0x16, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x5f,
+	0x70, 0x6c, 0x61, 0x69, 0x6e, 0x5f, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x14, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x50, 0x6c, 0x61,
+	0x69, 0x6e, 0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x2a, 0x2a, 0x0a, 0x0a, 0x56,
+	0x69, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x41, 0x55, 0x54,
+	0x4f, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x54, 0x4c, 0x53, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05,
+	0x50, 0x4c, 0x41, 0x49, 0x4e, 0x10, 0x02, 0x42, 0x6d, 0x0a, 0x1d, 0x63, 0x6f, 0x6d, 0x2e, 0x78,
 	0x72, 0x61, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x2e, 0x76, 0x6c, 0x65, 0x73, 0x73, 0x2e,
 	0x6f, 0x75, 0x74, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x50, 0x01, 0x5a, 0x2e, 0x67, 0x69, 0x74, 0x68,
 	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x78, 0x74, 0x6c, 0x73, 0x2f, 0x78, 0x72, 0x61, 0x79,
@@ -103,18 +199,21 @@ func file_proxy_vless_outbound_config_proto_rawDescGZIP() []byte {
 	return file_proxy_vless_outbound_config_proto_rawDescData
 }
 
+var file_proxy_vless_outbound_config_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
 var file_proxy_vless_outbound_config_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
 var file_proxy_vless_outbound_config_proto_goTypes = []interface{}{
-	(*Config)(nil),                  // 0: xray.proxy.vless.outbound.Config
-	(*protocol.ServerEndpoint)(nil), // 1: xray.common.protocol.ServerEndpoint
+	(VisionMode)(0),                 // 0: xray.proxy.vless.outbound.VisionMode
+	(*Config)(nil),                  // 1: xray.proxy.vless.outbound.Config
+	(*protocol.ServerEndpoint)(nil), // 2: xray.common.protocol.ServerEndpoint
 }
 var file_proxy_vless_outbound_config_proto_depIdxs = []int32{
-	1, // 0: xray.proxy.vless.outbound.Config.vnext:type_name -> xray.common.protocol.ServerEndpoint
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	2, // 0: xray.proxy.vless.outbound.Config.vnext:type_name -> xray.common.protocol.ServerEndpoint
+	0, // 1: xray.proxy.vless.outbound.Config.vision_mode:type_name -> xray.proxy.vless.outbound.VisionMode
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proxy_vless_outbound_config_proto_init() }
@@ -141,13 +240,14 @@ func file_proxy_vless_outbound_config_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proxy_vless_outbound_config_proto_rawDesc,
-			NumEnums:      0,
+			NumEnums:      1,
 			NumMessages:   1,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_proxy_vless_outbound_config_proto_goTypes,
 		DependencyIndexes: file_proxy_vless_outbound_config_proto_depIdxs,
+		EnumInfos:         file_proxy_vless_outbound_config_proto_enumTypes,
 		MessageInfos:      file_proxy_vless_outbound_config_proto_msgTypes,
 	}.Build()
 	File_proxy_vless_outbound_config_proto = out.File
@@ -40,10 +40,14 @@ func init() {
 
 // Handler is an outbound connection handler for VLess protocol.
 type Handler struct {
-	serverList    *protocol.ServerList
-	serverPicker  protocol.ServerPicker
-	policyManager policy.Manager
-	cone          bool
+	serverList     *protocol.ServerList
+	serverPicker   protocol.ServerPicker
+	policyManager  policy.Manager
+	cone           bool
+	dnsInterceptor *proxy.DNSInterceptor
+	visionMode     VisionMode
+	// plainThreshold is the PlainThreshold of the traffic states, in VisionMode_AUTO.
+	plainThreshold int64
 }
 
 // New creates a new VLess outbound handler.
@@ -59,11 +63,22 @@ func New(ctx context.Context, config *Config) (*Handler, error) {
 
 	v := core.MustFromContext(ctx)
 	handler := &Handler{
-		serverList:    serverList,
-		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
-		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
-		cone:          ctx.Value("cone").(bool),
+		serverList:     serverList,
+		serverPicker:   protocol.NewRoundRobinServerPicker(serverList),
+		policyManager:  v.GetFeature(policy.ManagerType()).(policy.Manager),
+		cone:           ctx.Value("cone").(bool),
+		visionMode:     config.VisionMode,
+		plainThreshold: proxy.DefaultVisionPlainThreshold,
 	}
+	if config.VisionPlainThreshold != 0 {
+		handler.plainThreshold = int64(config.VisionPlainThreshold)
+	}
+
+	dnsInterceptor, err := proxy.NewDNSInterceptor(ctx, config.InterceptDns, handler.policyManager.ForLevel(0).Timeouts.ConnectionIdle)
+	if err != nil {
+		return nil, newError("failed to intercept DNS").Base(err)
+	}
+	handler.dnsInterceptor = dnsInterceptor
 
 	return handler, nil
 }
@@ -71,12 +86,16 @@ func New(ctx context.Context, config *Config) (*Handler, error) {
 // Process implements proxy.Outbound.Process().
 func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
+	ob := outbounds[len(outbounds)-1]
 	if !ob.Target.IsValid() {
 		return newError("target not specified").AtError()
 	}
 	ob.Name = "vless"
 
+	if handled, err := h.dnsInterceptor.Intercept(ctx, ob.Target, link); handled {
+		return err
+	}
+
 	var rec *protocol.ServerSpec
 	var conn stat.Connection
 	if err := retry.ExponentialBackoff(5, 200).On(func() error {
@@ -120,6 +139,9 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	requestAddons := &encoding.Addons{
 		Flow: account.Flow,
 	}
+	if request.Command == protocol.RequestCommandUDP && requestAddons.Flow == "" && account.PacketAddress {
+		requestAddons.PacketAddress = true
+	}
 
 	var input *bytes.Reader
 	var rawInput *bytes.Buffer
@@ -180,7 +202,18 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	clientReader := link.Reader // .(*pipe.Reader)
 	clientWriter := link.Writer // .(*pipe.Writer)
 	trafficState := proxy.NewTrafficState(account.ID.Bytes())
-	if request.Command == protocol.RequestCommandUDP && (requestAddons.Flow == vless.XRV || (h.cone && request.Port != 53 && request.Port != 443)) {
+	if requestAddons.Flow == vless.XRV {
+		defer proxy.TrackTrafficState(ctx, trafficState)()
+		switch h.visionMode {
+		case VisionMode_AUTO:
+			trafficState.PlainThreshold = h.plainThreshold
+		case VisionMode_TLS:
+			trafficState.PlainThreshold = 0
+		case VisionMode_PLAIN:
+			trafficState.RelayPlain(ctx, "forced by the config")
+		}
+	}
+	if request.Command == protocol.RequestCommandUDP && (requestAddons.Flow == vless.XRV || (h.cone && !requestAddons.PacketAddress && request.Port != 53 && request.Port != 443)) {
 		request.Command = protocol.RequestCommandMux
 		request.Address = net.DomainAddress("v1.mux.cool")
 		request.Port = net.Port(666)
@@ -201,7 +234,11 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		}
 		timeoutReader, ok := clientReader.(buf.TimeoutReader)
 		if ok {
-			multiBuffer, err1 := timeoutReader.ReadMultiBufferTimeout(time.Millisecond * 500)
+			firstPayloadTimeout := time.Millisecond * 500
+			if delay := sessionPolicy.Latency.CopyDelay(); delay > 0 && delay < firstPayloadTimeout {
+				firstPayloadTimeout = delay
+			}
+			multiBuffer, err1 := timeoutReader.ReadMultiBufferTimeout(firstPayloadTimeout)
 			if err1 == nil {
 				if err := serverWriter.WriteMultiBuffer(multiBuffer); err != nil {
 					return err // ...
@@ -218,8 +255,15 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		} else {
 			newError("Reader is not timeout reader, will send out vless header separately from first payload").AtDebug().WriteToLog(session.ExportIDToError(ctx))
 		}
-		// Flush; bufferWriter.WriteMultiBufer now is bufferWriter.writer.WriteMultiBuffer
-		if err := bufferWriter.SetBuffered(false); err != nil {
+		copyDelay := sessionPolicy.Latency.CopyDelay()
+		if copyDelay > 0 && ok && requestAddons.Flow != vless.XRV {
+			// keep coalescing small writes, Copy flushes them within copyDelay
+			if err := bufferWriter.Flush(); err != nil {
+				return newError("failed to write A request payload").Base(err).AtWarning()
+			}
+			serverWriter = &buf.FlushWriter{Writer: serverWriter, Flusher: bufferWriter}
+		} else if err := bufferWriter.SetBuffered(false); err != nil {
+			// Flush; bufferWriter.WriteMultiBufer now is bufferWriter.writer.WriteMultiBuffer
 			return newError("failed to write A request payload").Base(err).AtWarning()
 		}
 
@@ -238,7 +282,7 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 			err = encoding.XtlsWrite(clientReader, serverWriter, timer, conn, trafficState, ob, ctx1)
 		} else {
 			// from clientReader.ReadMultiBuffer to serverWriter.WriteMultiBufer
-			err = buf.Copy(clientReader, serverWriter, buf.UpdateActivity(timer))
+			err = buf.Copy(clientReader, serverWriter, buf.UpdateActivity(timer), buf.WithMaxLatency(copyDelay))
 		}
 		if err != nil {
 			return newError("failed to transfer request payload").Base(err).AtInfo()
@@ -258,6 +302,9 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		if err != nil {
 			return newError("failed to decode response header").Base(err).AtInfo()
 		}
+		if requestAddons.PacketAddress && !responseAddons.PacketAddress {
+			return newError("server does not support UDP packets with their own destinations").AtWarning()
+		}
 
 		// default: serverReader := buf.NewReader(conn)
 		serverReader := encoding.DecodeBodyAddons(conn, request, responseAddons)
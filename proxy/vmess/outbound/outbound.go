@@ -21,6 +21,7 @@ import (
 	"github.com/xtls/xray-core/common/xudp"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/proxy/vmess"
 	"github.com/xtls/xray-core/proxy/vmess/encoding"
 	"github.com/xtls/xray-core/transport"
@@ -30,10 +31,11 @@ import (
 
 // Handler is an outbound connection handler for VMess protocol.
 type Handler struct {
-	serverList    *protocol.ServerList
-	serverPicker  protocol.ServerPicker
-	policyManager policy.Manager
-	cone          bool
+	serverList     *protocol.ServerList
+	serverPicker   protocol.ServerPicker
+	policyManager  policy.Manager
+	cone           bool
+	dnsInterceptor *proxy.DNSInterceptor
 }
 
 // New creates a new VMess outbound handler.
@@ -55,6 +57,12 @@ func New(ctx context.Context, config *Config) (*Handler, error) {
 		cone:          ctx.Value("cone").(bool),
 	}
 
+	dnsInterceptor, err := proxy.NewDNSInterceptor(ctx, config.InterceptDns, handler.policyManager.ForLevel(0).Timeouts.ConnectionIdle)
+	if err != nil {
+		return nil, newError("failed to intercept DNS").Base(err)
+	}
+	handler.dnsInterceptor = dnsInterceptor
+
 	return handler, nil
 }
 
@@ -66,6 +74,10 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		return newError("target not specified").AtError()
 	}
 	ob.Name = "vmess"
+
+	if handled, err := h.dnsInterceptor.Intercept(ctx, ob.Target, link); handled {
+		return err
+	}
 	ob.CanSpliceCopy = 3
 
 	var rec *protocol.ServerSpec
@@ -8,9 +8,11 @@ import (
 	"net/netip"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"golang.zx2c4.com/wireguard/conn"
 
+	"github.com/xtls/xray-core/common/buf"
 	xnet "github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/transport/internet"
@@ -29,11 +31,16 @@ type netReadInfo struct {
 
 // reduce duplicated code
 type netBind struct {
-	dns       dns.Client
-	dnsOption dns.IPOption
+	dns dns.Client
+	// dnsOptions are tried in turn to resolve the domains of endpoints.
+	dnsOptions []dns.IPOption
+	// obfs disguises the packets sent, and recovers the ones received.
+	obfs *obfuscator
 
 	workers   int
 	readQueue chan *netReadInfo
+	// closed tells if readQueue is closed, as the device closes the bind again when it is brought up
+	closed bool
 }
 
 // SetMark implements conn.Bind
@@ -54,11 +61,9 @@ func (n *netBind) ParseEndpoint(s string) (conn.Endpoint, error) {
 
 	addr := xnet.ParseAddress(ipStr)
 	if addr.Family() == xnet.AddressFamilyDomain {
-		ips, err := n.dns.LookupIP(addr.Domain(), n.dnsOption)
+		ips, err := n.lookupEndpoint(addr.Domain())
 		if err != nil {
 			return nil, err
-		} else if len(ips) == 0 {
-			return nil, dns.ErrEmptyResponse
 		}
 		addr = xnet.IPAddress(ips[0])
 	}
@@ -74,6 +79,24 @@ func (n *netBind) ParseEndpoint(s string) (conn.Endpoint, error) {
 	}, nil
 }
 
+// lookupEndpoint resolves the domain of an endpoint with the options in turn, until one gives IPs.
+func (n *netBind) lookupEndpoint(domain string) ([]net.IP, error) {
+	var err error = dns.ErrEmptyResponse
+	for _, option := range n.dnsOptions {
+		if !option.IPv4Enable && !option.IPv6Enable {
+			continue
+		}
+		ips, e := n.dns.LookupIP(domain, option)
+		if e == nil && len(ips) > 0 {
+			return ips, nil
+		}
+		if e != nil {
+			err = e
+		}
+	}
+	return nil, err
+}
+
 // BatchSize implements conn.Bind
 func (bind *netBind) BatchSize() int {
 	return 1
@@ -82,6 +105,7 @@ func (bind *netBind) BatchSize() int {
 // Open implements conn.Bind
 func (bind *netBind) Open(uport uint16) ([]conn.ReceiveFunc, uint16, error) {
 	bind.readQueue = make(chan *netReadInfo)
+	bind.closed = false
 
 	fun := func(bufs [][]byte, sizes []int, eps []conn.Endpoint) (n int, err error) {
 		defer func() {
@@ -114,8 +138,9 @@ func (bind *netBind) Open(uport uint16) ([]conn.ReceiveFunc, uint16, error) {
 
 // Close implements conn.Bind
 func (bind *netBind) Close() error {
-	if bind.readQueue != nil {
+	if bind.readQueue != nil && !bind.closed {
 		close(bind.readQueue)
+		bind.closed = true
 	}
 	return nil
 }
@@ -126,6 +151,24 @@ type netBindClient struct {
 	ctx      context.Context
 	dialer   internet.Dialer
 	reserved []byte
+
+	access    sync.Mutex
+	endpoints []*netEndpoint
+}
+
+// Close implements conn.Bind. The connections to the endpoints are closed too, as nothing reads them
+// anymore, and are dialed again once the device is brought up again.
+func (bind *netBindClient) Close() error {
+	bind.access.Lock()
+	for _, endpoint := range bind.endpoints {
+		if endpoint.conn != nil {
+			endpoint.conn.Close()
+			endpoint.conn = nil
+		}
+	}
+	bind.endpoints = nil
+	bind.access.Unlock()
+	return bind.netBind.Close()
 }
 
 func (bind *netBindClient) connectTo(endpoint *netEndpoint) error {
@@ -134,6 +177,9 @@ func (bind *netBindClient) connectTo(endpoint *netEndpoint) error {
 		return err
 	}
 	endpoint.conn = c
+	bind.access.Lock()
+	bind.endpoints = append(bind.endpoints, endpoint)
+	bind.access.Unlock()
 
 	go func(readQueue <-chan *netReadInfo, endpoint *netEndpoint) {
 		for {
@@ -142,6 +188,11 @@ func (bind *netBindClient) connectTo(endpoint *netEndpoint) error {
 				return
 			}
 			i, err := c.Read(v.buff)
+			if i > 0 {
+				// junk is handed to the device empty, which skips it
+				packet, _ := bind.obfs.open(v.buff[:i])
+				i = copy(v.buff, packet)
+			}
 
 			if i > 3 {
 				v.buff[1] = 0
@@ -182,15 +233,216 @@ func (bind *netBindClient) Send(buff [][]byte, endpoint conn.Endpoint) error {
 		if len(buff) > 3 && len(bind.reserved) == 3 {
 			copy(buff[1:], bind.reserved)
 		}
-		if _, err = nend.conn.Write(buff); err != nil {
+		if err = bind.obfs.send(buff, func(b []byte) error {
+			_, err := nend.conn.Write(b)
+			return err
+		}); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// netBindServer hands every packet to the device with the endpoint of the
+// connection it came in on. The device switches a peer over to that endpoint
+// only once the packet has been authenticated, so a client that roams to a new
+// source gets the replies there right away, and spoofed packets can't redirect
+// them.
 type netBindServer struct {
 	netBind
+
+	access sync.Mutex
+	queues *packetQueues
+	// dropped is the number of packets dropped as the queues of their endpoints were full.
+	dropped atomic.Uint64
+}
+
+// endpointQueueSize is the number of packets from an endpoint waiting for the device,
+// beyond which they are dropped.
+const endpointQueueSize = 128
+
+// endpointQueue is the ring of the packets from an endpoint waiting for the device. It is
+// guarded by the lock of the packetQueues it is in.
+type endpointQueue struct {
+	endpoint *netEndpoint
+	packets  [endpointQueueSize]*buf.Buffer
+	head     int
+	size     int
+	// ready tells if the queue is in the ready list of its packetQueues.
+	ready bool
+}
+
+func (q *endpointQueue) push(b *buf.Buffer) bool {
+	if q.size == endpointQueueSize {
+		return false
+	}
+	q.packets[(q.head+q.size)%endpointQueueSize] = b
+	q.size++
+	return true
+}
+
+func (q *endpointQueue) pop() *buf.Buffer {
+	b := q.packets[q.head]
+	q.packets[q.head] = nil
+	q.head = (q.head + 1) % endpointQueueSize
+	q.size--
+	return b
+}
+
+// packetQueues hands the packets of the endpoints to the device, taking turns between the
+// endpoints, so that the connections they come in on are read regardless of the device.
+type packetQueues struct {
+	access sync.Mutex
+	cond   *sync.Cond
+	ready  []*endpointQueue
+	closed bool
+}
+
+func newPacketQueues() *packetQueues {
+	q := &packetQueues{}
+	q.cond = sync.NewCond(&q.access)
+	return q
+}
+
+// push queues b, and returns false without queueing it if the queue is full. It returns
+// net.ErrClosed once the queues are closed.
+func (q *packetQueues) push(queue *endpointQueue, b *buf.Buffer) (bool, error) {
+	q.access.Lock()
+	defer q.access.Unlock()
+
+	if q.closed {
+		return false, net.ErrClosed
+	}
+	if !queue.push(b) {
+		return false, nil
+	}
+	if !queue.ready {
+		queue.ready = true
+		q.ready = append(q.ready, queue)
+	}
+	q.cond.Signal()
+	return true, nil
+}
+
+// pop waits for a packet from any endpoint. It returns net.ErrClosed once the queues are closed.
+func (q *packetQueues) pop() (*buf.Buffer, *netEndpoint, error) {
+	q.access.Lock()
+	defer q.access.Unlock()
+
+	for len(q.ready) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, nil, net.ErrClosed
+	}
+	queue := q.ready[0]
+	q.ready = q.ready[1:]
+	b := queue.pop()
+	if queue.size > 0 {
+		q.ready = append(q.ready, queue)
+	} else {
+		queue.ready = false
+	}
+	return b, queue.endpoint, nil
+}
+
+// close releases the packets queued, and wakes up the receivers.
+func (q *packetQueues) close() {
+	q.access.Lock()
+	defer q.access.Unlock()
+
+	q.closed = true
+	for _, queue := range q.ready {
+		for queue.size > 0 {
+			queue.pop().Release()
+		}
+		queue.ready = false
+	}
+	q.ready = nil
+	q.cond.Broadcast()
+}
+
+// Open implements conn.Bind
+func (bind *netBindServer) Open(uport uint16) ([]conn.ReceiveFunc, uint16, error) {
+	queues := newPacketQueues()
+	bind.access.Lock()
+	bind.queues = queues
+	bind.access.Unlock()
+
+	fun := func(bufs [][]byte, sizes []int, eps []conn.Endpoint) (int, error) {
+		for {
+			b, endpoint, err := queues.pop()
+			if err != nil {
+				return 0, err
+			}
+			packet, ok := bind.obfs.open(b.Bytes())
+			if ok {
+				sizes[0], eps[0] = copy(bufs[0], packet), endpoint
+			}
+			b.Release()
+			if ok {
+				return 1, nil
+			}
+		}
+	}
+	workers := bind.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	arr := make([]conn.ReceiveFunc, workers)
+	for i := 0; i < workers; i++ {
+		arr[i] = fun
+	}
+
+	return arr, uport, nil
+}
+
+// Close implements conn.Bind
+func (bind *netBindServer) Close() error {
+	bind.access.Lock()
+	defer bind.access.Unlock()
+
+	if bind.queues != nil {
+		bind.queues.close()
+		bind.queues = nil
+	}
+	return nil
+}
+
+// serve queues the packets read from reader for the device, with endpoint as their source.
+// Packets are dropped when the queue of the endpoint is full, so that reader is drained even
+// when the device lags behind. It returns nil once the bind is closed.
+func (bind *netBindServer) serve(reader buf.Reader, endpoint *netEndpoint) error {
+	queue := &endpointQueue{endpoint: endpoint}
+	for {
+		mb, err := reader.ReadMultiBuffer()
+		if err != nil {
+			return err
+		}
+
+		bind.access.Lock()
+		queues := bind.queues
+		bind.access.Unlock()
+		for i, b := range mb {
+			if b.IsEmpty() {
+				b.Release()
+				continue
+			}
+			var queued bool
+			if queues != nil {
+				queued, err = queues.push(queue, b)
+			}
+			if queues == nil || err != nil {
+				buf.ReleaseMulti(mb[i:])
+				return nil
+			}
+			if !queued {
+				bind.dropped.Add(1)
+				newError("queue of ", endpoint.DstToString(), " full, packet dropped").AtDebug().WriteToLog()
+				b.Release()
+			}
+		}
+	}
 }
 
 func (bind *netBindServer) Send(buff [][]byte, endpoint conn.Endpoint) error {
@@ -201,12 +453,16 @@ func (bind *netBindServer) Send(buff [][]byte, endpoint conn.Endpoint) error {
 		return conn.ErrWrongEndpointType
 	}
 
-	if nend.conn == nil {
+	_, c := nend.get()
+	if c == nil {
 		return newError("connection not open yet")
 	}
 
 	for _, buff := range buff {
-		if _, err = nend.conn.Write(buff); err != nil {
+		if err = bind.obfs.send(buff, func(b []byte) error {
+			_, err := c.Write(b)
+			return err
+		}); err != nil {
 			return err
 		}
 	}
@@ -215,36 +471,66 @@ func (bind *netBindServer) Send(buff [][]byte, endpoint conn.Endpoint) error {
 }
 
 type netEndpoint struct {
-	dst  xnet.Destination
-	conn net.Conn
+	access sync.RWMutex
+	dst    xnet.Destination
+	conn   net.Conn
+}
+
+func (e *netEndpoint) get() (xnet.Destination, net.Conn) {
+	e.access.RLock()
+	defer e.access.RUnlock()
+
+	return e.dst, e.conn
+}
+
+// attach sets the connection that packets to the endpoint are sent on.
+func (e *netEndpoint) attach(c net.Conn) {
+	e.access.Lock()
+	defer e.access.Unlock()
+
+	e.conn = c
+}
+
+// release clears the connection of the endpoint if it is still c.
+func (e *netEndpoint) release(c net.Conn) bool {
+	e.access.Lock()
+	defer e.access.Unlock()
+
+	if e.conn != c {
+		return false
+	}
+	e.conn = nil
+	return true
 }
 
-func (netEndpoint) ClearSrc() {}
+func (*netEndpoint) ClearSrc() {}
 
-func (e netEndpoint) DstIP() netip.Addr {
+func (e *netEndpoint) DstIP() netip.Addr {
 	return netip.Addr{}
 }
 
-func (e netEndpoint) SrcIP() netip.Addr {
+func (e *netEndpoint) SrcIP() netip.Addr {
 	return netip.Addr{}
 }
 
-func (e netEndpoint) DstToBytes() []byte {
+func (e *netEndpoint) DstToBytes() []byte {
+	dst, _ := e.get()
 	var dat []byte
-	if e.dst.Address.Family().IsIPv4() {
-		dat = e.dst.Address.IP().To4()[:]
+	if dst.Address.Family().IsIPv4() {
+		dat = dst.Address.IP().To4()[:]
 	} else {
-		dat = e.dst.Address.IP().To16()[:]
+		dat = dst.Address.IP().To16()[:]
 	}
-	dat = append(dat, byte(e.dst.Port), byte(e.dst.Port>>8))
+	dat = append(dat, byte(dst.Port), byte(dst.Port>>8))
 	return dat
 }
 
-func (e netEndpoint) DstToString() string {
-	return e.dst.NetAddr()
+func (e *netEndpoint) DstToString() string {
+	dst, _ := e.get()
+	return dst.NetAddr()
 }
 
-func (e netEndpoint) SrcToString() string {
+func (e *netEndpoint) SrcToString() string {
 	return ""
 }
 
@@ -0,0 +1,431 @@
+package wireguard
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/conn"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+)
+
+type recordConn struct {
+	net.Conn
+	packets [][]byte
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.packets = append(c.packets, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func newTestEndpoint(bind *netBindServer, source string, c net.Conn) *netEndpoint {
+	ep, err := bind.ParseEndpoint(source)
+	common.Must(err)
+	nep := ep.(*netEndpoint)
+	nep.attach(c)
+	return nep
+}
+
+func TestServerBindEndpointRelease(t *testing.T) {
+	bind := &netBindServer{}
+
+	oldConn := &recordConn{}
+	oldEndpoint := newTestEndpoint(bind, "127.0.0.1:10000", oldConn)
+	newConn := &recordConn{}
+	newEndpoint := newTestEndpoint(bind, "127.0.0.1:20000", newConn)
+	if s := newEndpoint.DstToString(); s != "127.0.0.1:20000" {
+		t.Error("unexpected destination: ", s)
+	}
+
+	common.Must(bind.Send([][]byte{{1, 2, 3, 4}}, newEndpoint))
+	if len(oldConn.packets) != 0 || len(newConn.packets) != 1 {
+		t.Fatal("packet not sent on the connection of the endpoint")
+	}
+
+	// the old connection going away must not affect the new endpoint
+	if !oldEndpoint.release(oldConn) {
+		t.Error("old endpoint should release its connection")
+	}
+	if newEndpoint.release(oldConn) {
+		t.Error("new endpoint released a connection it doesn't use")
+	}
+	common.Must(bind.Send([][]byte{{1, 2, 3, 4}}, newEndpoint))
+	if len(newConn.packets) != 2 {
+		t.Error("packet not sent on the new connection after old connection closed")
+	}
+
+	newEndpoint.release(newConn)
+	if err := bind.Send([][]byte{{1, 2, 3, 4}}, newEndpoint); err == nil {
+		t.Error("expected error after connection closed")
+	}
+}
+
+// roamingBind is the bind of a client device, whose packets reach the server bind on the connection it
+// last roamed to, as they would from a new source address.
+type roamingBind struct {
+	access   sync.Mutex
+	conn     net.Conn
+	endpoint conn.Endpoint
+	packets  chan []byte
+	done     chan struct{}
+	// received is the number of packets received on each connection.
+	received map[net.Conn]int
+}
+
+func newRoamingBind() *roamingBind {
+	return &roamingBind{
+		packets:  make(chan []byte, 64),
+		received: make(map[net.Conn]int),
+	}
+}
+
+// roam sends the packets on c from now on, and receives the ones coming on it.
+func (b *roamingBind) roam(c net.Conn) {
+	b.access.Lock()
+	b.conn = c
+	b.access.Unlock()
+
+	go func() {
+		for {
+			packet := make([]byte, 2048)
+			n, err := c.Read(packet)
+			if err != nil {
+				return
+			}
+			b.access.Lock()
+			b.received[c]++
+			b.access.Unlock()
+			b.packets <- packet[:n]
+		}
+	}()
+}
+
+func (b *roamingBind) receivedOn(c net.Conn) int {
+	b.access.Lock()
+	defer b.access.Unlock()
+
+	return b.received[c]
+}
+
+func (b *roamingBind) Open(uint16) ([]conn.ReceiveFunc, uint16, error) {
+	b.access.Lock()
+	defer b.access.Unlock()
+
+	done := make(chan struct{})
+	b.done = done
+	return []conn.ReceiveFunc{func(bufs [][]byte, sizes []int, eps []conn.Endpoint) (int, error) {
+		select {
+		case packet := <-b.packets:
+			sizes[0], eps[0] = copy(bufs[0], packet), b.endpoint
+			return 1, nil
+		case <-done:
+			return 0, net.ErrClosed
+		}
+	}}, 0, nil
+}
+
+func (b *roamingBind) Close() error {
+	b.access.Lock()
+	defer b.access.Unlock()
+
+	if b.done != nil {
+		close(b.done)
+		b.done = nil
+	}
+	return nil
+}
+
+func (*roamingBind) SetMark(uint32) error {
+	return nil
+}
+
+func (b *roamingBind) Send(bufs [][]byte, _ conn.Endpoint) error {
+	b.access.Lock()
+	c := b.conn
+	b.access.Unlock()
+
+	for _, packet := range bufs {
+		if _, err := c.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *roamingBind) ParseEndpoint(s string) (conn.Endpoint, error) {
+	ep, err := (&netBind{}).ParseEndpoint(s)
+	b.endpoint = ep
+	return ep, err
+}
+
+func (*roamingBind) BatchSize() int {
+	return 1
+}
+
+func TestServerBindRoaming(t *testing.T) {
+	// the server echoes the UDP packets sent to it through the tunnel
+	serverTun, err := createGVisorTun([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, 1420, func(_, _ xnet.Destination, c net.Conn) {
+		defer c.Close()
+		b := make([]byte, 1500)
+		for {
+			n, err := c.Read(b)
+			if err != nil {
+				return
+			}
+			if _, err := c.Write(b[:n]); err != nil {
+				return
+			}
+		}
+	})
+	common.Must(err)
+	defer serverTun.Close()
+	serverBind := &netBindServer{}
+	common.Must(serverTun.BuildDevice(createIPCRequest(&DeviceConfig{
+		SecretKey: "20303b46515c67727d88939ea9b4bfcad5e0ebf6010c17222d38434e59646f7a",
+		Peers: []*PeerConfig{{
+			PublicKey:  "9c4f052e81b330a4c6d241b7891f6a8d3ff9fc31eab91a02d6c5ed320d2b2d78",
+			AllowedIps: []string{"10.0.0.2/32"},
+		}},
+	}), serverBind))
+
+	clientTun, err := createGVisorTun([]netip.Addr{netip.MustParseAddr("10.0.0.2")}, 1420, nil)
+	common.Must(err)
+	defer clientTun.Close()
+	clientBind := newRoamingBind()
+	common.Must(clientTun.BuildDevice(createIPCRequest(&DeviceConfig{
+		IsClient:  true,
+		SecretKey: "4855606b76818c97a2adb8c3ced9e4effa05101b26313c47525d68737e89945f",
+		Peers: []*PeerConfig{{
+			PublicKey:  "9c7c9d24ecfa178ee6fb76b73552f5cd538d3be7dcbeeb3bf6f997fb2935c93a",
+			Endpoint:   "127.0.0.1:51820",
+			AllowedIps: []string{"10.0.0.1/32"},
+		}},
+	}), clientBind))
+
+	// connect gives the client a new source address, as the inbound does for every one it sees
+	connect := func(source string) net.Conn {
+		client, server := net.Pipe()
+		endpoint := newTestEndpoint(serverBind, source, server)
+		go serverBind.serve(buf.NewPacketReader(server), endpoint)
+		clientBind.roam(client)
+		return client
+	}
+	echo := func(c net.Conn, payload string) {
+		t.Helper()
+		common.Must2(c.Write([]byte(payload)))
+		common.Must(c.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		b := make([]byte, 64)
+		n, err := c.Read(b)
+		if err != nil {
+			t.Fatal("no echo of ", payload, ": ", err)
+		}
+		if string(b[:n]) != payload {
+			t.Error("expect echo ", payload, ", but got ", string(b[:n]))
+		}
+	}
+
+	oldConn := connect("127.0.0.1:10000")
+	defer oldConn.Close()
+	c, err := clientTun.DialUDPAddrPort(netip.AddrPort{}, netip.MustParseAddrPort("10.0.0.1:7"))
+	common.Must(err)
+	defer c.Close()
+	echo(c, "before")
+	ipc, err := serverTun.IpcGet()
+	common.Must(err)
+	handshake := lastHandshake(ipc)
+	if handshake.IsZero() {
+		t.Fatal("expect a handshake")
+	}
+	if endpoint, _ := serverTun.PeerEndpoint(netip.MustParseAddr("10.0.0.2")); endpoint != "127.0.0.1:10000" {
+		t.Error("unexpected endpoint of the peer: ", endpoint)
+	}
+
+	// the client shows up from another source in the middle of the session
+	newConn := connect("127.0.0.1:20000")
+	defer newConn.Close()
+	received := clientBind.receivedOn(oldConn)
+	echo(c, "after")
+	if n := clientBind.receivedOn(newConn); n == 0 {
+		t.Error("reply not sent to the new source")
+	}
+	if n := clientBind.receivedOn(oldConn); n != received {
+		t.Error("reply sent to the old source")
+	}
+	if endpoint, _ := serverTun.PeerEndpoint(netip.MustParseAddr("10.0.0.2")); endpoint != "127.0.0.1:20000" {
+		t.Error("expect the peer moved over to the new source, but got ", endpoint)
+	}
+	ipc, err = serverTun.IpcGet()
+	common.Must(err)
+	if h := lastHandshake(ipc); !h.Equal(handshake) {
+		t.Error("expect no new handshake, but got one at ", h)
+	}
+}
+
+func TestServerBindEndpointDestination(t *testing.T) {
+	bind := &netBindServer{}
+
+	ep := newTestEndpoint(bind, "[2001:db8::1]:51820", &recordConn{})
+	dst, _ := ep.get()
+	if dst != xnet.UDPDestination(xnet.ParseAddress("2001:db8::1"), 51820) {
+		t.Error("unexpected destination: ", dst)
+	}
+	if b := ep.DstToBytes(); len(b) != 18 || b[16] != byte(51820&0xff) || b[17] != byte(51820>>8) {
+		t.Error("unexpected destination bytes: ", b)
+	}
+}
+
+// packetSource reads the given number of packets holding its id, or packets without end if negative.
+type packetSource struct {
+	id      byte
+	packets int
+}
+
+func (s *packetSource) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	if s.packets == 0 {
+		return nil, io.EOF
+	}
+	s.packets--
+	b := buf.New()
+	b.WriteByte(s.id)
+	return buf.MultiBuffer{b}, nil
+}
+
+func TestServerBindStalledDevice(t *testing.T) {
+	bind := &netBindServer{}
+	receivers, _, err := bind.Open(0)
+	common.Must(err)
+	receive := func() (byte, conn.Endpoint, error) {
+		bufs, sizes, eps := [][]byte{make([]byte, 1500)}, make([]int, 1), make([]conn.Endpoint, 1)
+		_, err := receivers[0](bufs, sizes, eps)
+		return bufs[0][0], eps[0], err
+	}
+
+	// the connections are drained while the device reads nothing
+	const packets = 1000
+	served := make(chan error, 2)
+	endpoints := make([]*netEndpoint, 2)
+	for i := range endpoints {
+		endpoints[i] = newTestEndpoint(bind, "127.0.0.1:1000"+string(rune('0'+i)), &recordConn{})
+		go func(i int) {
+			served <- bind.serve(&packetSource{id: byte(i), packets: packets}, endpoints[i])
+		}(i)
+	}
+	for range endpoints {
+		select {
+		case err := <-served:
+			if err != io.EOF {
+				t.Fatal("unexpected error: ", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocked by a stalled device")
+		}
+	}
+	if dropped := bind.dropped.Load(); dropped != 2*(packets-endpointQueueSize) {
+		t.Error("expect ", 2*(packets-endpointQueueSize), " packets dropped, but got ", dropped)
+	}
+
+	// the device takes turns between the endpoints
+	first, _, err := receive()
+	common.Must(err)
+	for i := 1; i < 4; i++ {
+		id, ep, err := receive()
+		common.Must(err)
+		if expected := first ^ byte(i%2); id != expected || ep != endpoints[expected] {
+			t.Error("unexpected packet ", id, " from ", ep.DstToString())
+		}
+	}
+
+	// closing while packets come in and the device reads them
+	go func() {
+		served <- bind.serve(&packetSource{packets: -1}, endpoints[0])
+	}()
+	received := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := receive(); err != nil {
+				received <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	common.Must(bind.Close())
+	if err := <-served; err != nil {
+		t.Error("expect no error once the bind is closed, but got ", err)
+	}
+	if err := <-received; !errors.Is(err, net.ErrClosed) {
+		t.Error("expect the receiver to stop with net.ErrClosed, but got ", err)
+	}
+}
+
+// staticDNS answers with an IPv4 and an IPv6 address, or nothing for the families missing.
+type staticDNS struct {
+	ipv4, ipv6 net.IP
+}
+
+func (*staticDNS) Type() interface{} {
+	return dns.ClientType()
+}
+
+func (*staticDNS) Start() error {
+	return nil
+}
+
+func (*staticDNS) Close() error {
+	return nil
+}
+
+func (d *staticDNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, error) {
+	var ips []net.IP
+	if option.IPv4Enable && d.ipv4 != nil {
+		ips = append(ips, d.ipv4)
+	}
+	if option.IPv6Enable && d.ipv6 != nil {
+		ips = append(ips, d.ipv6)
+	}
+	return ips, nil
+}
+
+func TestBindEndpointDomainStrategy(t *testing.T) {
+	ipv4, ipv6 := net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")
+	for _, c := range []struct {
+		config     *DeviceConfig
+		hasIPv6    bool
+		dns        *staticDNS
+		expected   net.IP
+		unresolved bool
+	}{
+		// a tunnel of IPv6 only resolves to IPv6 by default, even into itself
+		{&DeviceConfig{}, true, &staticDNS{ipv4, ipv6}, ipv6, false},
+		{&DeviceConfig{EndpointDomainStrategy: DeviceConfig_ENDPOINT_FORCE_IP4}, true, &staticDNS{ipv4, ipv6}, ipv4, false},
+		{&DeviceConfig{EndpointDomainStrategy: DeviceConfig_ENDPOINT_FORCE_IP4}, true, &staticDNS{nil, ipv6}, nil, true},
+		{&DeviceConfig{EndpointDomainStrategy: DeviceConfig_ENDPOINT_FORCE_IP46}, true, &staticDNS{nil, ipv6}, ipv6, false},
+		{&DeviceConfig{EndpointDomainStrategy: DeviceConfig_ENDPOINT_FORCE_IP64}, false, &staticDNS{ipv4, ipv6}, ipv6, false},
+		{&DeviceConfig{DomainStrategy: DeviceConfig_FORCE_IP6}, false, &staticDNS{ipv4, ipv6}, nil, true},
+	} {
+		bind := &netBind{
+			dns:        c.dns,
+			dnsOptions: c.config.endpointIPOptions(!c.hasIPv6, c.hasIPv6),
+		}
+		ep, err := bind.ParseEndpoint("wg.example.com:51820")
+		if c.unresolved {
+			if err == nil {
+				t.Error("expect ", c.config.EndpointDomainStrategy, " unresolved, but got ", ep.DstToString())
+			}
+			continue
+		}
+		common.Must(err)
+		if ip := ep.(*netEndpoint).dst.Address.IP(); !ip.Equal(c.expected) {
+			t.Error("expect ", c.expected, " for ", c.config.EndpointDomainStrategy, ", but got ", ip)
+		}
+	}
+}
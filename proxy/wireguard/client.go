@@ -26,6 +26,7 @@ import (
 	"net/netip"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
@@ -39,6 +40,8 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
 )
@@ -52,8 +55,17 @@ type Handler struct {
 	dns           dns.Client
 	// cached configuration
 	endpoints        []netip.Addr
+	allowedIPs       []netip.Prefix
 	hasIPv4, hasIPv6 bool
 	wgLock           sync.Mutex
+	// demand is nil unless the device is kept up on demand
+	demand *onDemand
+	// health is nil unless conf.HandshakeTimeout is set, or the endpoint of a peer is a domain
+	health       *healthCheck
+	handshakeAge stats.Counter
+	statsManager stats.Manager
+	gaugeOnce    sync.Once
+	peerGauges   *task.Periodic
 }
 
 // New creates a new wireguard handler.
@@ -65,15 +77,61 @@ func New(ctx context.Context, conf *DeviceConfig) (*Handler, error) {
 		return nil, err
 	}
 
+	allowedIPs, err := parseAllowedIPs(conf)
+	if err != nil {
+		return nil, err
+	}
+
 	d := v.GetFeature(dns.ClientType()).(dns.Client)
-	return &Handler{
+	h := &Handler{
 		conf:          conf,
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		dns:           d,
 		endpoints:     endpoints,
+		allowedIPs:    allowedIPs,
 		hasIPv4:       hasIPv4,
 		hasIPv6:       hasIPv6,
-	}, nil
+	}
+	h.statsManager, _ = v.GetFeature(stats.ManagerType()).(stats.Manager)
+	if conf.OnDemand {
+		h.demand = newOnDemand(time.Duration(conf.IdleShutdown)*time.Second, h.policyManager.ForLevel(0).Timeouts.Handshake)
+	}
+	if h.statsManager != nil {
+		h.peerGauges = refreshPeerGauges(func() ipcDevice {
+			h.wgLock.Lock()
+			defer h.wgLock.Unlock()
+			if h.net == nil {
+				return nil
+			}
+			return h.net
+		}, h.statsManager, peerGaugeInterval)
+		common.Must(h.peerGauges.Start())
+	}
+	return h, nil
+}
+
+// Close implements common.Closable. It brings the device down, once the outbound is removed.
+func (h *Handler) Close() error {
+	if h.peerGauges != nil {
+		h.peerGauges.Close()
+	}
+
+	h.wgLock.Lock()
+	defer h.wgLock.Unlock()
+
+	if h.health != nil {
+		_ = h.health.Close()
+		h.health = nil
+	}
+	if h.net != nil {
+		_ = h.net.Close()
+		h.net = nil
+	}
+	if h.bind != nil {
+		_ = h.bind.Close()
+		h.bind = nil
+	}
+	return nil
 }
 
 func (h *Handler) processWireGuard(ctx context.Context, dialer internet.Dialer) (err error) {
@@ -89,6 +147,10 @@ func (h *Handler) processWireGuard(ctx context.Context, dialer internet.Dialer)
 		Content:  "switching dialer",
 	})
 
+	if h.health != nil {
+		_ = h.health.Close()
+		h.health = nil
+	}
 	if h.net != nil {
 		_ = h.net.Close()
 		h.net = nil
@@ -101,14 +163,12 @@ func (h *Handler) processWireGuard(ctx context.Context, dialer internet.Dialer)
 	// bind := conn.NewStdNetBind() // TODO: conn.Bind wrapper for dialer
 	bind := &netBindClient{
 		netBind: netBind{
-			dns: h.dns,
-			dnsOption: dns.IPOption{
-				IPv4Enable: h.hasIPv4,
-				IPv6Enable: h.hasIPv6,
-			},
-			workers: int(h.conf.NumWorkers),
+			dns:        h.dns,
+			dnsOptions: h.conf.endpointIPOptions(h.hasIPv4, h.hasIPv6),
+			workers:    int(h.conf.NumWorkers),
+			obfs:       newObfuscator(h.conf.Obfuscation),
 		},
-		ctx:      ctx,
+		ctx:      bindContext(ctx),
 		dialer:   dialer,
 		reserved: h.conf.Reserved,
 	}
@@ -123,9 +183,59 @@ func (h *Handler) processWireGuard(ctx context.Context, dialer internet.Dialer)
 		return newError("failed to create virtual tun interface").Base(err)
 	}
 	h.bind = bind
+	if timeout := h.healthCheckTimeout(); timeout > 0 {
+		h.startHealthCheck(h.net, bind, timeout)
+	}
 	return nil
 }
 
+// endpointRefreshTimeout is the handshake timeout of the peers whose endpoints are domains, unless one is set, so
+// that they are resolved again once the IPs they had stop answering. The device gives up on a handshake after as
+// long.
+const endpointRefreshTimeout = 90 * time.Second
+
+func (h *Handler) healthCheckTimeout() time.Duration {
+	if h.conf.HandshakeTimeout > 0 {
+		return time.Duration(h.conf.HandshakeTimeout) * time.Second
+	}
+	if h.conf.hasDomainEndpoint() {
+		return endpointRefreshTimeout
+	}
+	return 0
+}
+
+// startHealthCheck checks the peers of device every third of the handshake timeout, and reconnects them with the
+// endpoints resolved again when they don't answer.
+func (h *Handler) startHealthCheck(device Tunnel, bind *netBindClient, timeout time.Duration) {
+	h.health = newHealthCheck(device, timeout, h.policyManager.ForLevel(0).Timeouts.Handshake, func() string {
+		return h.createIPCRequest(bind, h.conf)
+	})
+	if h.demand != nil {
+		h.health.active = func() bool {
+			return h.demand.isUp(device)
+		}
+	}
+	if h.handshakeAge != nil {
+		h.health.setGauge(h.handshakeAge)
+	}
+	interval := timeout / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	h.health.start(interval)
+}
+
+// bindContext detaches ctx from the flow that brings the device up, as the bind outlives it, and
+// the chained outbounds it dials through with it. It keeps what the dialer needs: the instance and
+// the outbound itself.
+func bindContext(ctx context.Context) context.Context {
+	outbound := &session.Outbound{}
+	if outbounds := session.OutboundsFromContext(ctx); len(outbounds) > 0 {
+		outbound.Tag = outbounds[len(outbounds)-1].Tag
+	}
+	return session.ContextWithOutbounds(core.ToBackgroundDetachedContext(ctx), []*session.Outbound{outbound})
+}
+
 // Process implements OutboundHandler.Dispatch().
 func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbounds := session.OutboundsFromContext(ctx)
@@ -136,9 +246,20 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	ob.Name = "wireguard"
 	ob.CanSpliceCopy = 3
 
+	h.registerGauges(ob.Tag)
 	if err := h.processWireGuard(ctx, dialer); err != nil {
 		return err
 	}
+	if h.demand != nil {
+		h.wgLock.Lock()
+		device := h.net
+		h.wgLock.Unlock()
+		release, err := h.demand.acquire(ctx, device)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
 
 	// Destination of the inner request.
 	destination := ob.Target
@@ -169,6 +290,9 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		}
 		addr = net.IPAddress(ips[dice.Roll(len(ips))])
 	}
+	if !routes(h.allowedIPs, toNetIpAddr(addr)) {
+		return newError("no peer has ", addr, " in its allowed IPs")
+	}
 
 	var newCtx context.Context
 	var newCancel context.CancelFunc
@@ -228,14 +352,53 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 
 	responseDonePost := task.OnSuccess(responseFunc, task.Close(link.Writer))
 	if err := task.Run(ctx, requestFunc, responseDonePost); err != nil {
-		common.Interrupt(link.Reader)
-		common.Interrupt(link.Writer)
+		common.InterruptWithError(link.Reader, err)
+		common.InterruptWithError(link.Writer, err)
 		return newError("connection ends").Base(err)
 	}
 
 	return nil
 }
 
+// Peers implements proxy.PeerReporter. There are none before the first connection brings the device up.
+func (h *Handler) Peers() ([]*proxy.PeerStatus, error) {
+	h.wgLock.Lock()
+	defer h.wgLock.Unlock()
+
+	if h.net == nil {
+		return nil, nil
+	}
+	return reportPeers(h.net, h.statsManager, time.Now())
+}
+
+// registerGauges registers the gauges of the outbound once its tag is known: "outbound>>>tag>>>device", which is 1
+// while the device is up, and "outbound>>>tag>>>handshake_age", the seconds since the last handshake with a peer.
+func (h *Handler) registerGauges(tag string) {
+	h.gaugeOnce.Do(func() {
+		if tag == "" || h.statsManager == nil {
+			return
+		}
+		if h.demand != nil {
+			if g := h.registerGauge(tag, "device"); g != nil {
+				h.demand.setGauge(g)
+			}
+		}
+		if h.conf.HandshakeTimeout > 0 {
+			h.handshakeAge = h.registerGauge(tag, "handshake_age")
+		}
+	})
+}
+
+func (h *Handler) registerGauge(tag, gauge string) stats.Counter {
+	name := "outbound>>>" + tag + ">>>" + gauge
+	g, err := stats.GetOrRegisterGauge(h.statsManager, name)
+	if err != nil {
+		newError("failed to register ", name).Base(err).AtWarning().WriteToLog()
+		return nil
+	}
+	return g
+}
+
 // creates a tun interface on netstack given a configuration
 func (h *Handler) makeVirtualTun(bind *netBindClient) (Tunnel, error) {
 	t, err := h.conf.createTun()(h.endpoints, int(h.conf.Mtu), nil)
@@ -243,8 +406,12 @@ func (h *Handler) makeVirtualTun(bind *netBindClient) (Tunnel, error) {
 		return nil, err
 	}
 
-	bind.dnsOption.IPv4Enable = h.hasIPv4
-	bind.dnsOption.IPv6Enable = h.hasIPv6
+	if g, ok := t.(*gvisorNet); ok && len(h.allowedIPs) > 0 {
+		g.net.SetRoutes(h.allowedIPs)
+	}
+	if h.conf.ClampMss != 0 {
+		clampMSS(t, h.conf.ClampMss)
+	}
 
 	if err = t.BuildDevice(h.createIPCRequest(bind, h.conf), bind); err != nil {
 		_ = t.Close()
@@ -284,22 +451,9 @@ func (h *Handler) createIPCRequest(bind *netBindClient, conf *DeviceConfig) stri
 				addr = net.ParseAddress(dialerIp.String())
 				newError("createIPCRequest use dialer dest ip: ", addr).WriteToLog()
 			} else {
-				ips, err := h.dns.LookupIP(addr.Domain(), dns.IPOption{
-					IPv4Enable: h.hasIPv4 && h.conf.preferIP4(),
-					IPv6Enable: h.hasIPv6 && h.conf.preferIP6(),
-				})
-				{ // Resolve fallback
-					if (len(ips) == 0 || err != nil) && h.conf.hasFallback() {
-						ips, err = h.dns.LookupIP(addr.Domain(), dns.IPOption{
-							IPv4Enable: h.hasIPv4 && h.conf.fallbackIP4(),
-							IPv6Enable: h.hasIPv6 && h.conf.fallbackIP6(),
-						})
-					}
-				}
+				ips, err := bind.lookupEndpoint(addr.Domain())
 				if err != nil {
 					newError("createIPCRequest failed to lookup DNS").Base(err).WriteToLog()
-				} else if len(ips) == 0 {
-					newError("createIPCRequest empty lookup DNS").WriteToLog()
 				} else {
 					addr = net.IPAddress(ips[dice.Roll(len(ips))])
 				}
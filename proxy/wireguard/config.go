@@ -1,5 +1,10 @@
 package wireguard
 
+import (
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+)
+
 func (c *DeviceConfig) preferIP4() bool {
 	return c.DomainStrategy == DeviceConfig_FORCE_IP ||
 		c.DomainStrategy == DeviceConfig_FORCE_IP4 ||
@@ -24,6 +29,44 @@ func (c *DeviceConfig) fallbackIP6() bool {
 	return c.DomainStrategy == DeviceConfig_FORCE_IP46
 }
 
+// endpointIPOptions returns the options the domains of the endpoints of the peers are resolved with, in turn until
+// one gives IPs.
+func (c *DeviceConfig) endpointIPOptions(hasIPv4, hasIPv6 bool) []dns.IPOption {
+	switch c.EndpointDomainStrategy {
+	case DeviceConfig_ENDPOINT_FORCE_IP:
+		return []dns.IPOption{{IPv4Enable: true, IPv6Enable: true}}
+	case DeviceConfig_ENDPOINT_FORCE_IP4:
+		return []dns.IPOption{{IPv4Enable: true}}
+	case DeviceConfig_ENDPOINT_FORCE_IP6:
+		return []dns.IPOption{{IPv6Enable: true}}
+	case DeviceConfig_ENDPOINT_FORCE_IP46:
+		return []dns.IPOption{{IPv4Enable: true}, {IPv6Enable: true}}
+	case DeviceConfig_ENDPOINT_FORCE_IP64:
+		return []dns.IPOption{{IPv6Enable: true}, {IPv4Enable: true}}
+	}
+	options := []dns.IPOption{{
+		IPv4Enable: hasIPv4 && c.preferIP4(),
+		IPv6Enable: hasIPv6 && c.preferIP6(),
+	}}
+	if c.hasFallback() {
+		options = append(options, dns.IPOption{
+			IPv4Enable: hasIPv4 && c.fallbackIP4(),
+			IPv6Enable: hasIPv6 && c.fallbackIP6(),
+		})
+	}
+	return options
+}
+
+// hasDomainEndpoint tells if the endpoint of a peer is a domain, whose IP may change.
+func (c *DeviceConfig) hasDomainEndpoint() bool {
+	for _, peer := range c.Peers {
+		if host, _, err := net.SplitHostPort(peer.Endpoint); err == nil && net.ParseAddress(host).Family().IsDomain() {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *DeviceConfig) createTun() tunCreator {
 	if c.KernelMode {
 		return createKernelTun
@@ -0,0 +1,174 @@
+package wireguard
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/proxy/wireguard/gvisortun"
+)
+
+// echoTTL is the TTL of echo replies written back to the tunnel.
+const echoTTL = 64
+
+type echoKey struct {
+	src, dst netip.Addr
+	id       uint16
+}
+
+// echoForwarder groups ICMP echo requests of one ping session
+// (source, destination and identifier) into a connection for handler.
+// Each buffer read from or written to such connection is a whole ICMP message.
+// A session that handler ends without any reply is answered with destination unreachable,
+// so that ping doesn't just wait.
+type echoForwarder struct {
+	access  sync.Mutex
+	net     *gvisortun.Net
+	handler promiscuousModeHandler
+	conns   map[echoKey]*echoConn
+}
+
+func newEchoForwarder(n *gvisortun.Net, handler promiscuousModeHandler) *echoForwarder {
+	return &echoForwarder{
+		net:     n,
+		handler: handler,
+		conns:   make(map[echoKey]*echoConn),
+	}
+}
+
+func (f *echoForwarder) handle(src, dst netip.Addr, message []byte) {
+	key := echoKey{src: src, dst: dst, id: binary.BigEndian.Uint16(message[4:6])}
+
+	f.access.Lock()
+	conn, found := f.conns[key]
+	if !found {
+		conn = &echoConn{
+			forwarder: f,
+			key:       key,
+			requests:  make(chan *buf.Buffer, 16),
+			done:      make(chan struct{}),
+		}
+		f.conns[key] = conn
+		request := append([]byte(nil), message...)
+		go func() {
+			f.handler(xnet.ICMPDestination(xnet.IPAddress(src.AsSlice())), xnet.ICMPDestination(xnet.IPAddress(dst.AsSlice())), conn)
+			conn.Close()
+			if !conn.replied.Load() {
+				if err := f.net.WriteUnreachable(src, dst, request, echoTTL); err != nil {
+					newError("failed to answer ICMP echo to ", dst, " with unreachable").Base(err).AtDebug().WriteToLog()
+				}
+			}
+		}()
+	}
+	f.access.Unlock()
+
+	b := buf.New()
+	if _, err := b.Write(message); err != nil {
+		b.Release()
+		return
+	}
+	select {
+	case conn.requests <- b:
+	default:
+		// ping is lossy anyway
+		b.Release()
+	}
+}
+
+func (f *echoForwarder) remove(c *echoConn) {
+	f.access.Lock()
+	if f.conns[c.key] == c {
+		delete(f.conns, c.key)
+	}
+	f.access.Unlock()
+}
+
+type echoConn struct {
+	forwarder *echoForwarder
+	key       echoKey
+	requests  chan *buf.Buffer
+	done      chan struct{}
+	closeOnce sync.Once
+	replied   atomic.Bool
+}
+
+// ReadMultiBuffer implements buf.Reader.
+func (c *echoConn) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	select {
+	case b := <-c.requests:
+		return buf.MultiBuffer{b}, nil
+	case <-c.done:
+		return nil, io.EOF
+	}
+}
+
+// WriteMultiBuffer implements buf.Writer.
+func (c *echoConn) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+	for _, b := range mb {
+		if _, err := c.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *echoConn) Read(p []byte) (int, error) {
+	mb, err := c.ReadMultiBuffer()
+	if err != nil {
+		return 0, err
+	}
+	mb, n := buf.SplitBytes(mb, p)
+	buf.ReleaseMulti(mb)
+	return n, nil
+}
+
+func (c *echoConn) Write(p []byte) (int, error) {
+	if len(p) < 8 {
+		return 0, newError("invalid ICMP message")
+	}
+	select {
+	case <-c.done:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	if err := c.forwarder.net.WriteEcho(c.key.dst, c.key.src, p, echoTTL); err != nil {
+		return 0, err
+	}
+	c.replied.Store(true)
+	return len(p), nil
+}
+
+func (c *echoConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.forwarder.remove(c)
+	})
+	return nil
+}
+
+func (c *echoConn) LocalAddr() net.Addr {
+	return &net.IPAddr{IP: c.key.dst.AsSlice()}
+}
+
+func (c *echoConn) RemoteAddr() net.Addr {
+	return &net.IPAddr{IP: c.key.src.AsSlice()}
+}
+
+func (*echoConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (*echoConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (*echoConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
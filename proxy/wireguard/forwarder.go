@@ -0,0 +1,241 @@
+package wireguard
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/routing"
+)
+
+type routingInfo struct {
+	ctx         context.Context
+	dispatcher  routing.Dispatcher
+	inboundTag  *session.Inbound
+	outboundTag *session.Outbound
+	contentTag  *session.Content
+}
+
+func newRoutingInfo(ctx context.Context, dispatcher routing.Dispatcher) *routingInfo {
+	var ob *session.Outbound
+	if outbounds := session.OutboundsFromContext(ctx); len(outbounds) > 0 {
+		ob = outbounds[len(outbounds)-1]
+	}
+	return &routingInfo{
+		ctx:         core.ToBackgroundDetachedContext(ctx),
+		dispatcher:  dispatcher,
+		inboundTag:  session.InboundFromContext(ctx),
+		outboundTag: ob,
+		contentTag:  session.ContentFromContext(ctx),
+	}
+}
+
+// Forwarder dispatches the connections accepted by a promiscuous netstack,
+// using the session of the inbound that feeds the netstack.
+type Forwarder struct {
+	access        sync.RWMutex
+	info          routingInfo
+	peers         map[string]*routingInfo
+	policyManager policy.Manager
+	level         uint32
+
+	// peerOf returns the endpoint of the peer that the tunneled packets from source come from.
+	peerOf func(source netip.Addr) (string, bool)
+
+	// done is canceled by Close, interrupting the connections being forwarded.
+	done       context.Context
+	cancel     context.CancelFunc
+	forwarding sync.WaitGroup
+}
+
+// NewForwarder creates a Forwarder with the given policy manager.
+func NewForwarder(policyManager policy.Manager) *Forwarder {
+	done, cancel := context.WithCancel(context.Background())
+	return &Forwarder{
+		policyManager: policyManager,
+		done:          done,
+		cancel:        cancel,
+	}
+}
+
+// Close interrupts the connections being forwarded and waits for them to end. Connections
+// handed to the Forwarder afterwards are closed at once.
+func (f *Forwarder) Close() error {
+	f.access.Lock()
+	f.cancel()
+	f.info = routingInfo{}
+	f.peers = nil
+	f.access.Unlock()
+
+	f.forwarding.Wait()
+	return nil
+}
+
+// begin counts a connection being forwarded, unless the Forwarder is closed.
+func (f *Forwarder) begin() bool {
+	f.access.Lock()
+	defer f.access.Unlock()
+
+	if f.done.Err() != nil {
+		return false
+	}
+	f.forwarding.Add(1)
+	return true
+}
+
+// SetRoutingInfo records the inbound session in ctx and the dispatcher for connections forwarded afterwards.
+func (f *Forwarder) SetRoutingInfo(ctx context.Context, dispatcher routing.Dispatcher) {
+	info := newRoutingInfo(ctx, dispatcher)
+
+	f.access.Lock()
+	defer f.access.Unlock()
+
+	f.info = *info
+}
+
+// setPeerRoutingInfo records the inbound session in ctx and the dispatcher for connections from the peer at
+// endpoint, until the returned function is called.
+func (f *Forwarder) setPeerRoutingInfo(endpoint string, ctx context.Context, dispatcher routing.Dispatcher) func() {
+	info := newRoutingInfo(ctx, dispatcher)
+
+	f.access.Lock()
+	defer f.access.Unlock()
+
+	if f.peers == nil {
+		f.peers = make(map[string]*routingInfo)
+	}
+	f.peers[endpoint] = info
+	return func() {
+		f.access.Lock()
+		defer f.access.Unlock()
+
+		// the peer may have come back in another connection already
+		if f.peers[endpoint] == info {
+			delete(f.peers, endpoint)
+		}
+	}
+}
+
+// routingInfo returns the routing info of the peer that connections from source come from, or the one set by
+// SetRoutingInfo if the Forwarder doesn't tell peers apart.
+func (f *Forwarder) routingInfo(source net.Destination) routingInfo {
+	if f.peerOf == nil {
+		f.access.RLock()
+		defer f.access.RUnlock()
+		return f.info
+	}
+
+	if !source.IsValid() || !source.Address.Family().IsIP() {
+		return routingInfo{}
+	}
+	src, ok := netip.AddrFromSlice(source.Address.IP())
+	if !ok {
+		return routingInfo{}
+	}
+	endpoint, found := f.peerOf(src.Unmap())
+	if !found {
+		return routingInfo{}
+	}
+
+	f.access.RLock()
+	defer f.access.RUnlock()
+	if info := f.peers[endpoint]; info != nil {
+		return *info
+	}
+	return routingInfo{}
+}
+
+// ForwardConnection dispatches conn from source, an address inside the tunnel, to dest, and returns when the
+// connection ends.
+func (f *Forwarder) ForwardConnection(source, dest net.Destination, conn net.Conn) {
+	info := f.routingInfo(source)
+	if info.dispatcher == nil {
+		newError("no inbound connection of the peer sending from ", source).AtWarning().WriteToLog()
+		conn.Close()
+		return
+	}
+	defer conn.Close()
+	if !f.begin() {
+		return
+	}
+	defer f.forwarding.Done()
+
+	ctx, cancel := context.WithCancel(core.ToBackgroundDetachedContext(info.ctx))
+	defer context.AfterFunc(f.done, cancel)()
+	ctx = session.ContextWithID(ctx, session.NewID())
+
+	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
+		From:   source,
+		To:     dest,
+		Status: log.AccessAccepted,
+		Reason: "",
+	})
+
+	// connections are handled concurrently, so each one gets its own copy of the session,
+	// whose source is the one inside the tunnel rather than the address of the peer
+	inbound := session.Inbound{}
+	if info.inboundTag != nil {
+		inbound = *info.inboundTag
+	}
+	inbound.Source = source
+	if inbound.User == nil {
+		inbound.User = &protocol.MemoryUser{Level: f.level}
+	}
+	ctx = session.ContextWithInbound(ctx, &inbound)
+	if info.outboundTag != nil {
+		outbound := *info.outboundTag
+		ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{&outbound})
+	} else {
+		ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{}})
+	}
+	if info.contentTag != nil {
+		content := *info.contentTag
+		ctx = session.ContextWithContent(ctx, &content)
+	}
+	plcy := policy.ForContext(ctx, f.policyManager, f.level)
+	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+
+	link, err := info.dispatcher.Dispatch(ctx, dest)
+	if err != nil {
+		newError("dispatch connection").Base(err).AtError().WriteToLog()
+		cancel()
+		return
+	}
+	defer cancel()
+
+	requestDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
+		if err := buf.Copy(buf.NewReader(conn), link.Writer, buf.UpdateActivity(timer)); err != nil {
+			return newError("failed to transport all TCP request").Base(err)
+		}
+
+		return nil
+	}
+
+	responseDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
+		if err := buf.Copy(link.Reader, buf.NewWriter(conn), buf.UpdateActivity(timer)); err != nil {
+			return newError("failed to transport all TCP response").Base(err)
+		}
+
+		return nil
+	}
+
+	requestDonePost := task.OnSuccess(requestDone, task.Close(link.Writer))
+	if err := task.Run(ctx, requestDonePost, responseDone); err != nil {
+		common.InterruptWithError(link.Reader, err)
+		common.InterruptWithError(link.Writer, err)
+		newError("connection ends").Base(err).AtDebug().WriteToLog()
+		return
+	}
+}
@@ -0,0 +1,237 @@
+package wireguard
+
+import (
+	"context"
+	"io"
+	gonet "net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/routing"
+	routing_session "github.com/xtls/xray-core/features/routing/session"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+const xrayKey core.XrayKey = 1
+
+type tagDispatcher struct {
+	access sync.Mutex
+	tags   map[net.Destination]string
+}
+
+func (*tagDispatcher) Type() interface{} {
+	return routing.DispatcherType()
+}
+
+func (*tagDispatcher) Start() error {
+	return nil
+}
+
+func (*tagDispatcher) Close() error {
+	return nil
+}
+
+func (d *tagDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	d.access.Lock()
+	defer d.access.Unlock()
+	d.tags[dest] = session.InboundFromContext(ctx).Tag
+	return nil, newError("recorded")
+}
+
+func (*tagDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+type nopConn struct {
+	net.Conn
+}
+
+func (nopConn) Close() error {
+	return nil
+}
+
+func TestForwarderPeerRoutingInfo(t *testing.T) {
+	ipc := "private_key=0000\nlisten_port=1337\n" +
+		"public_key=1111\nendpoint=192.0.2.1:40000\nallowed_ip=10.0.0.2/32\n" +
+		"public_key=2222\nendpoint=[2001:db8::2]:50000\nallowed_ip=10.0.0.0/24\nallowed_ip=fd00::/64\n"
+	f := NewForwarder(policy.DefaultManager{})
+	f.peerOf = func(src netip.Addr) (string, bool) {
+		return peerEndpoint(ipc, src)
+	}
+
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	dispatcher := &tagDispatcher{tags: make(map[net.Destination]string)}
+	for endpoint, tag := range map[string]string{"192.0.2.1:40000": "client-a", "[2001:db8::2]:50000": "client-b"} {
+		ctx := context.WithValue(context.Background(), xrayKey, v)
+		ctx = session.ContextWithInbound(ctx, &session.Inbound{Tag: tag})
+		defer f.setPeerRoutingInfo(endpoint, ctx, dispatcher)()
+	}
+
+	// both clients open connections at the same time
+	sources := map[string]string{
+		"10.0.0.2":  "client-a",
+		"10.0.0.3":  "client-b",
+		"fd00::3":   "client-b",
+		"192.0.2.9": "",
+	}
+	var wg sync.WaitGroup
+	port := net.Port(1000)
+	expected := make(map[net.Destination]string)
+	for source, tag := range sources {
+		dest := net.TCPDestination(net.ParseAddress("1.2.3.4"), port)
+		port++
+		if tag != "" {
+			expected[dest] = tag
+		}
+		src := net.TCPDestination(net.ParseAddress(source), 12345)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.ForwardConnection(src, dest, nopConn{})
+		}()
+	}
+	wg.Wait()
+
+	if len(dispatcher.tags) != len(expected) {
+		t.Error("expect ", len(expected), " dispatched connections, but got ", dispatcher.tags)
+	}
+	for dest, tag := range expected {
+		if dispatcher.tags[dest] != tag {
+			t.Error("connection to ", dest, " dispatched as ", dispatcher.tags[dest], " instead of ", tag)
+		}
+	}
+}
+
+type levelManager struct {
+	policy.DefaultManager
+}
+
+func (levelManager) ForLevel(level uint32) policy.Session {
+	p := policy.SessionDefault()
+	if level == 2 {
+		p.Timeouts.ConnectionIdle = 5 * time.Second
+	}
+	return p
+}
+
+type idleDispatcher struct {
+	tagDispatcher
+	level chan uint32
+}
+
+func (d *idleDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	d.level <- session.InboundFromContext(ctx).User.Level
+	reader, writer := pipe.New(pipe.WithoutSizeLimit())
+	return &transport.Link{Reader: reader, Writer: writer}, nil
+}
+
+func TestForwarderUserLevel(t *testing.T) {
+	f := NewForwarder(levelManager{})
+	f.level = 2
+
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+	ctx = session.ContextWithInbound(ctx, &session.Inbound{Tag: "wg-in"})
+	dispatcher := &idleDispatcher{level: make(chan uint32, 1)}
+	f.SetRoutingInfo(ctx, dispatcher)
+
+	client, server := gonet.Pipe()
+	defer client.Close()
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		f.ForwardConnection(net.TCPDestination(net.ParseAddress("10.0.0.2"), 12345), net.TCPDestination(net.ParseAddress("1.2.3.4"), 80), server)
+		close(done)
+	}()
+	if level := <-dispatcher.level; level != 2 {
+		t.Error("dispatched with level ", level)
+	}
+
+	// neither side sends anything
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("idle connection not closed")
+	}
+	if elapsed := time.Since(start); elapsed < 4*time.Second {
+		t.Error("connection closed before the idle timeout: ", elapsed)
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Error("expect EOF on the client side, but got ", err)
+	}
+}
+
+type routeDispatcher struct {
+	tagDispatcher
+	router routing.Router
+}
+
+func (d *routeDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	route, err := d.router.PickRoute(routing_session.AsRoutingContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	d.access.Lock()
+	defer d.access.Unlock()
+	d.tags[dest] = route.GetOutboundTag()
+	return nil, newError("recorded")
+}
+
+func TestForwarderRouteBySource(t *testing.T) {
+	r := new(router.Router)
+	common.Must(r.Init(context.Background(), &router.Config{
+		Rule: []*router.RoutingRule{
+			{
+				TargetTag: &router.RoutingRule_Tag{Tag: "peer-a"},
+				SourceGeoip: []*router.GeoIP{{
+					Cidr: []*router.CIDR{{Ip: []byte{10, 0, 0, 2}, Prefix: 32}},
+				}},
+			},
+			{
+				TargetTag: &router.RoutingRule_Tag{Tag: "subnet"},
+				SourceGeoip: []*router.GeoIP{{
+					Cidr: []*router.CIDR{{Ip: []byte{10, 0, 0, 0}, Prefix: 24}},
+				}},
+			},
+		},
+	}, nil, nil, nil))
+
+	f := NewForwarder(policy.DefaultManager{})
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+	// the source of the inbound is the address of the peer, which must not be routed on
+	ctx = session.ContextWithInbound(ctx, &session.Inbound{
+		Tag:    "wg-in",
+		Source: net.UDPDestination(net.ParseAddress("192.0.2.1"), 40000),
+	})
+	dispatcher := &routeDispatcher{tagDispatcher: tagDispatcher{tags: make(map[net.Destination]string)}, router: r}
+	f.SetRoutingInfo(ctx, dispatcher)
+
+	expected := map[string]string{
+		"10.0.0.2": "peer-a",
+		"10.0.0.3": "subnet",
+		"10.0.1.2": "",
+	}
+	port := net.Port(1000)
+	for source, tag := range expected {
+		dest := net.TCPDestination(net.ParseAddress("1.2.3.4"), port)
+		port++
+		f.ForwardConnection(net.TCPDestination(net.ParseAddress(source), 12345), dest, nopConn{})
+		if dispatcher.tags[dest] != tag {
+			t.Error("connection from ", source, " routed to ", dispatcher.tags[dest], " instead of ", tag)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package gvisortun
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// clampMSS lowers the MSS option of a TCP SYN in packet, an IPv4 or IPv6 packet, to mss, and updates the
+// checksum of the segment. It returns whether packet is changed.
+func clampMSS(packet []byte, mss uint16) bool {
+	var segment []byte
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return false
+		}
+		ihl := int(packet[0]&0x0f) * 4
+		length := int(binary.BigEndian.Uint16(packet[2:4]))
+		// only the first fragment has the TCP header
+		if packet[9] != 6 || binary.BigEndian.Uint16(packet[6:8])&0x1fff != 0 ||
+			ihl < 20 || length > len(packet) || length < ihl+20 {
+			return false
+		}
+		segment = packet[ihl:length]
+	case 6:
+		if len(packet) < 40 {
+			return false
+		}
+		length := 40 + int(binary.BigEndian.Uint16(packet[4:6]))
+		// SYNs don't come with extension headers
+		if packet[6] != 6 || length > len(packet) || length < 60 {
+			return false
+		}
+		segment = packet[40:length]
+	default:
+		return false
+	}
+
+	if segment[13]&0x02 == 0 {
+		return false
+	}
+	dataOffset := int(segment[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(segment) {
+		return false
+	}
+	for i := 20; i < dataOffset; {
+		switch segment[i] {
+		case 0:
+			return false
+		case 1:
+			i++
+			continue
+		}
+		if i+1 >= dataOffset {
+			return false
+		}
+		size := int(segment[i+1])
+		if size < 2 || i+size > dataOffset {
+			return false
+		}
+		if segment[i] == 2 && size == 4 {
+			old := binary.BigEndian.Uint16(segment[i+2:])
+			if old <= mss {
+				return false
+			}
+			binary.BigEndian.PutUint16(segment[i+2:], mss)
+
+			// incremental update of RFC 1624, with the bytes of a field at an odd offset
+			// falling into two words of the checksum
+			oldWord, newWord := old, mss
+			if i%2 == 1 {
+				oldWord, newWord = bits.ReverseBytes16(old), bits.ReverseBytes16(mss)
+			}
+			sum := uint32(^binary.BigEndian.Uint16(segment[16:18])) + uint32(^oldWord) + uint32(newWord)
+			for sum > 0xffff {
+				sum = sum>>16 + sum&0xffff
+			}
+			binary.BigEndian.PutUint16(segment[16:18], ^uint16(sum))
+			return true
+		}
+		i += size
+	}
+	return false
+}
@@ -7,9 +7,11 @@ package gvisortun
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"net/netip"
 	"os"
+	"sync"
 	"syscall"
 
 	"golang.zx2c4.com/wireguard/tun"
@@ -33,8 +35,22 @@ type netTun struct {
 	incomingPacket chan *buffer.View
 	mtu            int
 	hasV4, hasV6   bool
+	// mss, if not 0, is what the MSS of TCP SYNs through the device is clamped to
+	mss uint16
+
+	// echoHandler takes over ICMP echo requests before they reach netstack,
+	// which would otherwise answer every ping in promiscuous mode.
+	echoHandler EchoHandler
+	access      sync.RWMutex
+	closed      bool
+	closing     chan struct{}
+	closeOnce   sync.Once
 }
 
+// EchoHandler receives an ICMP echo request written to the tun device.
+// message starts at the ICMP header and is only valid during the call.
+type EchoHandler func(src, dst netip.Addr, message []byte)
+
 type Net netTun
 
 func CreateNetTUN(localAddresses []netip.Addr, mtu int, promiscuousMode bool) (tun.Device, *Net, *stack.Stack, error) {
@@ -49,6 +65,7 @@ func CreateNetTUN(localAddresses []netip.Addr, mtu int, promiscuousMode bool) (t
 		events:         make(chan tun.Event, 1),
 		incomingPacket: make(chan *buffer.View),
 		mtu:            mtu,
+		closing:        make(chan struct{}),
 	}
 	dev.ep.AddNotify(dev)
 	tcpipErr := dev.stack.CreateNIC(1, dev.ep)
@@ -120,8 +137,10 @@ func (tun *netTun) Events() <-chan tun.Event {
 // Read implements tun.Device
 
 func (tun *netTun) Read(buf [][]byte, sizes []int, offset int) (int, error) {
-	view, ok := <-tun.incomingPacket
-	if !ok {
+	var view *buffer.View
+	select {
+	case view = <-tun.incomingPacket:
+	case <-tun.closing:
 		return 0, os.ErrClosed
 	}
 
@@ -129,6 +148,9 @@ func (tun *netTun) Read(buf [][]byte, sizes []int, offset int) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	if tun.mss != 0 {
+		clampMSS(buf[0][offset:offset+n], tun.mss)
+	}
 	sizes[0] = n
 	return 1, nil
 }
@@ -141,6 +163,13 @@ func (tun *netTun) Write(buf [][]byte, offset int) (int, error) {
 			continue
 		}
 
+		if tun.echoHandler != nil && tun.handleEcho(packet) {
+			continue
+		}
+		if tun.mss != 0 {
+			clampMSS(packet, tun.mss)
+		}
+
 		pkb := stack.NewPacketBuffer(stack.PacketBufferOptions{Payload: buffer.MakeWithData(packet)})
 		switch packet[0] >> 4 {
 		case 4:
@@ -164,7 +193,12 @@ func (tun *netTun) WriteNotify() {
 	view := pkt.ToView()
 	pkt.DecRef()
 
-	tun.incomingPacket <- view
+	// the device stops reading once closed
+	select {
+	case tun.incomingPacket <- view:
+	case <-tun.closing:
+		view.Release()
+	}
 }
 
 // Flush  implements tun.Device
@@ -174,6 +208,15 @@ func (tun *netTun) Flush() error {
 
 // Close implements tun.Device
 func (tun *netTun) Close() error {
+	// wake up pending echo replies before waiting for them
+	tun.closeOnce.Do(func() { close(tun.closing) })
+	tun.access.Lock()
+	if tun.closed {
+		tun.access.Unlock()
+		return nil
+	}
+	tun.closed = true
+
 	tun.stack.RemoveNIC(1)
 
 	if tun.events != nil {
@@ -181,10 +224,12 @@ func (tun *netTun) Close() error {
 	}
 
 	tun.ep.Close()
+	tun.access.Unlock()
 
-	if tun.incomingPacket != nil {
-		close(tun.incomingPacket)
-	}
+	// closing the endpoints of the stack interrupts the connections it handed out,
+	// and its goroutines are gone once it is waited for
+	tun.stack.Close()
+	tun.stack.Wait()
 
 	return nil
 }
@@ -194,6 +239,179 @@ func (tun *netTun) MTU() (int, error) {
 	return tun.mtu, nil
 }
 
+func (tun *netTun) handleEcho(packet []byte) bool {
+	var src, dst netip.Addr
+	var message []byte
+	var protoNumber tcpip.NetworkProtocolNumber
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < header.IPv4MinimumSize {
+			return false
+		}
+		ihl := int(packet[0]&0x0f) * 4
+		length := int(binary.BigEndian.Uint16(packet[2:4]))
+		// fragments are left to netstack
+		if packet[9] != 1 || binary.BigEndian.Uint16(packet[6:8])&0x3fff != 0 ||
+			ihl < header.IPv4MinimumSize || length > len(packet) || length < ihl+8 {
+			return false
+		}
+		message = packet[ihl:length]
+		if message[0] != 8 {
+			return false
+		}
+		src = netip.AddrFrom4([4]byte(packet[12:16]))
+		dst = netip.AddrFrom4([4]byte(packet[16:20]))
+		protoNumber = ipv4.ProtocolNumber
+	case 6:
+		if len(packet) < header.IPv6MinimumSize {
+			return false
+		}
+		length := header.IPv6MinimumSize + int(binary.BigEndian.Uint16(packet[4:6]))
+		if packet[6] != 58 || length > len(packet) || length < header.IPv6MinimumSize+8 {
+			return false
+		}
+		message = packet[header.IPv6MinimumSize:length]
+		if message[0] != 128 {
+			return false
+		}
+		src = netip.AddrFrom16([16]byte(packet[8:24]))
+		dst = netip.AddrFrom16([16]byte(packet[24:40]))
+		protoNumber = ipv6.ProtocolNumber
+	default:
+		return false
+	}
+	// echo requests sent to the tunnel itself are still answered by netstack
+	if tun.stack.CheckLocalAddress(1, protoNumber, tcpip.AddrFromSlice(dst.AsSlice())) != 0 {
+		return false
+	}
+	tun.echoHandler(src, dst, message)
+	return true
+}
+
+// HandleEcho makes the device pass ICMP echo requests to handler instead of
+// netstack. It must be called before the device starts.
+func (net *Net) HandleEcho(handler EchoHandler) {
+	net.echoHandler = handler
+}
+
+// ClampMSS makes the device lower the MSS of TCP SYNs through it to mss both
+// ways, so that the connections of netstack fit through links of a smaller MTU
+// than its own. It must be called before the device starts.
+func (net *Net) ClampMSS(mss uint16) {
+	net.mss = mss
+}
+
+// SetRoutes makes netstack route only prefixes to the device, instead of every
+// address of the families of its local addresses, so that connections to others
+// fail at once rather than being dropped by the device.
+func (net *Net) SetRoutes(prefixes []netip.Prefix) {
+	var routes []tcpip.Route
+	for _, prefix := range prefixes {
+		prefix = prefix.Masked()
+		if prefix.Addr().Is4() && !net.hasV4 || prefix.Addr().Is6() && !net.hasV6 {
+			continue
+		}
+		subnet := tcpip.AddressWithPrefix{
+			Address:   tcpip.AddrFromSlice(prefix.Addr().AsSlice()),
+			PrefixLen: prefix.Bits(),
+		}.Subnet()
+		routes = append(routes, tcpip.Route{Destination: subnet, NIC: 1})
+	}
+	net.stack.SetRouteTable(routes)
+}
+
+// WriteEcho sends an ICMP message from src to dst out of the device.
+// The checksum of message is filled in here.
+func (net *Net) WriteEcho(src, dst netip.Addr, message []byte, ttl uint8) error {
+	return net.write(icmpPacket(src, dst, message, ttl))
+}
+
+// WriteUnreachable answers request, an ICMP echo request sent from src to dst,
+// with a destination unreachable message from dst.
+func (net *Net) WriteUnreachable(src, dst netip.Addr, request []byte, ttl uint8) error {
+	original := icmpPacket(src, dst, request, ttl)
+	var message []byte
+	if src.Is4() {
+		// host unreachable, quoting the IP header and the first 8 bytes of the request
+		message = append([]byte{3, 1, 0, 0, 0, 0, 0, 0}, original[:header.IPv4MinimumSize+8]...)
+	} else {
+		// address unreachable, quoting as much of the request as fits in the minimum MTU
+		if limit := header.IPv6MinimumMTU - header.IPv6MinimumSize - 8; len(original) > limit {
+			original = original[:limit]
+		}
+		message = append([]byte{1, 3, 0, 0, 0, 0, 0, 0}, original...)
+	}
+	return net.write(icmpPacket(dst, src, message, ttl))
+}
+
+// icmpPacket builds an IP packet carrying the ICMP message from src to dst.
+// The checksum of message is filled in here.
+func icmpPacket(src, dst netip.Addr, message []byte, ttl uint8) []byte {
+	var packet []byte
+	if src.Is4() {
+		packet = make([]byte, header.IPv4MinimumSize+len(message))
+		packet[0] = 0x45
+		binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+		packet[8] = ttl
+		packet[9] = 1
+		copy(packet[12:16], src.AsSlice())
+		copy(packet[16:20], dst.AsSlice())
+		binary.BigEndian.PutUint16(packet[10:12], ^checksum(packet[:header.IPv4MinimumSize], 0))
+
+		icmp := packet[header.IPv4MinimumSize:]
+		copy(icmp, message)
+		icmp[2], icmp[3] = 0, 0
+		binary.BigEndian.PutUint16(icmp[2:4], ^checksum(icmp, 0))
+	} else {
+		packet = make([]byte, header.IPv6MinimumSize+len(message))
+		packet[0] = 0x60
+		binary.BigEndian.PutUint16(packet[4:6], uint16(len(message)))
+		packet[6] = 58
+		packet[7] = ttl
+		copy(packet[8:24], src.AsSlice())
+		copy(packet[24:40], dst.AsSlice())
+
+		icmp := packet[header.IPv6MinimumSize:]
+		copy(icmp, message)
+		icmp[2], icmp[3] = 0, 0
+		// pseudo header: addresses, length and next header
+		sum := checksum(packet[8:40], 0)
+		sum = checksum([]byte{0, 0, byte(len(icmp) >> 8), byte(len(icmp)), 0, 0, 0, 58}, sum)
+		binary.BigEndian.PutUint16(icmp[2:4], ^checksum(icmp, sum))
+	}
+	return packet
+}
+
+func (net *Net) write(packet []byte) error {
+	net.access.RLock()
+	defer net.access.RUnlock()
+	if net.closed {
+		return os.ErrClosed
+	}
+	select {
+	case net.incomingPacket <- buffer.NewViewWithData(packet):
+		return nil
+	case <-net.closing:
+		return os.ErrClosed
+	}
+}
+
+// checksum computes the one's complement sum of b, continuing from initial.
+func checksum(b []byte, initial uint16) uint16 {
+	sum := uint32(initial)
+	for len(b) >= 2 {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return uint16(sum)
+}
+
 func convertToFullAddr(endpoint netip.AddrPort) (tcpip.FullAddress, tcpip.NetworkProtocolNumber) {
 	var protoNumber tcpip.NetworkProtocolNumber
 	if endpoint.Addr().Is4() {
@@ -0,0 +1,143 @@
+package gvisortun_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/proxy/wireguard/gvisortun"
+	"golang.zx2c4.com/wireguard/tun"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+)
+
+func TestWriteUnreachable(t *testing.T) {
+	dev, n, _, err := gvisortun.CreateNetTUN([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, 1420, true)
+	common.Must(err)
+	defer dev.Close()
+
+	src, dst := netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("192.0.2.1")
+	request := []byte{8, 0, 0, 0, 0x12, 0x34, 0, 1, 'p', 'i', 'n', 'g'}
+	// the message waits for the device to read it
+	go func() {
+		common.Must(n.WriteUnreachable(src, dst, request, 64))
+	}()
+
+	bufs, sizes := [][]byte{make([]byte, 1500)}, []int{0}
+	common.Must2(dev.Read(bufs, sizes, 0))
+	packet := bufs[0][:sizes[0]]
+
+	if len(packet) != 20+8+20+8 {
+		t.Fatal("unexpected length ", len(packet))
+	}
+	if !bytes.Equal(packet[12:16], dst.AsSlice()) || !bytes.Equal(packet[16:20], src.AsSlice()) {
+		t.Error("unexpected addresses ", packet[12:20])
+	}
+	message := packet[20:]
+	if message[0] != 3 || message[1] != 1 {
+		t.Error("expect host unreachable, but got type ", message[0], " code ", message[1])
+	}
+	var sum uint32
+	for i := 0; i < len(message); i += 2 {
+		sum += uint32(message[i])<<8 | uint32(message[i+1])
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	if sum != 0xffff {
+		t.Error("invalid checksum")
+	}
+
+	quoted := message[8:]
+	if !bytes.Equal(quoted[12:16], src.AsSlice()) || !bytes.Equal(quoted[16:20], dst.AsSlice()) {
+		t.Error("unexpected addresses of the request ", quoted[12:20])
+	}
+	// ping matches the reply by the identifier and sequence number
+	if !bytes.Equal(quoted[24:28], request[4:8]) {
+		t.Error("unexpected request ", quoted[20:])
+	}
+}
+
+func TestSetRoutes(t *testing.T) {
+	dev, n, _, err := gvisortun.CreateNetTUN([]netip.Addr{netip.MustParseAddr("10.0.0.1")}, 1420, false)
+	common.Must(err)
+	defer dev.Close()
+
+	n.SetRoutes([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("10.2.3.4/32"), netip.MustParsePrefix("fd00::/8")})
+	for _, addr := range []string{"10.1.2.3", "10.2.3.4"} {
+		conn, err := n.DialUDPAddrPort(netip.AddrPort{}, netip.AddrPortFrom(netip.MustParseAddr(addr), 53))
+		if err != nil {
+			t.Error(addr, " is not routed: ", err)
+			continue
+		}
+		conn.Close()
+	}
+	for _, addr := range []string{"10.2.3.5", "192.0.2.1", "fd00::1"} {
+		if conn, err := n.DialUDPAddrPort(netip.AddrPort{}, netip.AddrPortFrom(netip.MustParseAddr(addr), 53)); err == nil {
+			conn.Close()
+			t.Error(addr, " is routed")
+		}
+	}
+}
+
+func TestClampMSS(t *testing.T) {
+	serverAddr, clientAddr := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")
+	serverDev, serverNet, serverStack, err := gvisortun.CreateNetTUN([]netip.Addr{serverAddr}, 1420, false)
+	common.Must(err)
+	defer serverDev.Close()
+	clientDev, clientNet, _, err := gvisortun.CreateNetTUN([]netip.Addr{clientAddr}, 1420, false)
+	common.Must(err)
+	defer clientDev.Close()
+	serverNet.ClampMSS(1240)
+
+	// the path between the devices drops what doesn't fit into 1280 bytes, as a tunnel in a tunnel would
+	pipe := func(from, to tun.Device) {
+		bufs, sizes := [][]byte{make([]byte, 1500)}, []int{0}
+		for {
+			if _, err := from.Read(bufs, sizes, 0); err != nil {
+				return
+			}
+			if sizes[0] > 1280 {
+				continue
+			}
+			if _, err := to.Write([][]byte{bufs[0][:sizes[0]]}, 0); err != nil {
+				return
+			}
+		}
+	}
+	go pipe(serverDev, clientDev)
+	go pipe(clientDev, serverDev)
+
+	listener, err := gonet.ListenTCP(serverStack, tcpip.FullAddress{NIC: 1, Addr: tcpip.AddrFromSlice(serverAddr.AsSlice()), Port: 80}, ipv4.ProtocolNumber)
+	common.Must(err)
+	defer listener.Close()
+	response := make([]byte, 256*1024)
+	for i := range response {
+		response[i] = byte(i)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(response)
+	}()
+
+	conn, err := clientNet.DialContextTCPAddrPort(context.Background(), netip.AddrPortFrom(serverAddr, 80))
+	common.Must(err)
+	defer conn.Close()
+	common.Must(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	received, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal("got ", len(received), " bytes: ", err)
+	}
+	if !bytes.Equal(received, response) {
+		t.Error("unexpected response of ", len(received), " bytes")
+	}
+}
@@ -0,0 +1,202 @@
+package wireguard
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// stalledChecks is the number of checks in a row in which a peer is sent data but sends nothing back,
+// after which its path is taken as broken. The device of the peer answers data with a keepalive
+// within 10 seconds anyway.
+const stalledChecks = 3
+
+// peerStatus is what IpcGet tells about a peer.
+type peerStatus struct {
+	publicKey     string
+	endpoint      string
+	lastHandshake time.Time
+	rx, tx        uint64
+}
+
+// parsePeerStatus parses the output of IpcGet.
+func parsePeerStatus(ipc string) []*peerStatus {
+	var peers []*peerStatus
+	var peer *peerStatus
+	var sec int64
+	for _, line := range strings.Split(ipc, "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		if key == "public_key" {
+			peer = &peerStatus{publicKey: value}
+			peers = append(peers, peer)
+			continue
+		}
+		if peer == nil {
+			continue
+		}
+		switch key {
+		case "endpoint":
+			peer.endpoint = value
+		case "last_handshake_time_sec":
+			sec, _ = strconv.ParseInt(value, 10, 64)
+		case "last_handshake_time_nsec":
+			nsec, _ := strconv.ParseInt(value, 10, 64)
+			if sec != 0 {
+				peer.lastHandshake = time.Unix(sec, nsec)
+			}
+		case "rx_bytes":
+			peer.rx, _ = strconv.ParseUint(value, 10, 64)
+		case "tx_bytes":
+			peer.tx, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return peers
+}
+
+// healthTunnel is the part of a Tunnel that healthCheck drives.
+type healthTunnel interface {
+	IpcGet() (string, error)
+	Reconnect(ipc string) error
+	Handshake(ctx context.Context) error
+}
+
+type peerCounters struct {
+	rx, tx  uint64
+	stalled int
+}
+
+// healthCheck watches the peers of a device, and reconnects them when data is sent but nothing comes back,
+// or no handshake completes for a while, instead of waiting for the device to give up on the session.
+type healthCheck struct {
+	access sync.Mutex
+	tunnel healthTunnel
+	peers  map[string]*peerCounters
+	since  time.Time
+	age    stats.Counter
+	done   *done.Instance
+
+	// timeout is how long a peer that is sent data may go without a handshake.
+	timeout time.Duration
+	// handshakeTimeout is how long a reconnection waits for the handshake.
+	handshakeTimeout time.Duration
+	// ipc returns the config to reconnect with, with the endpoints resolved again.
+	ipc func() string
+	// active tells whether the device is up, as it is only checked then.
+	active func() bool
+	now    func() time.Time
+}
+
+func newHealthCheck(tunnel healthTunnel, timeout, handshakeTimeout time.Duration, ipc func() string) *healthCheck {
+	return &healthCheck{
+		tunnel:           tunnel,
+		peers:            make(map[string]*peerCounters),
+		since:            time.Now(),
+		done:             done.New(),
+		timeout:          timeout,
+		handshakeTimeout: handshakeTimeout,
+		ipc:              ipc,
+		active:           func() bool { return true },
+		now:              time.Now,
+	}
+}
+
+// setGauge sets the gauge of the seconds since the last handshake.
+func (c *healthCheck) setGauge(gauge stats.Counter) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	c.age = gauge
+}
+
+// start checks the device every interval, until Close.
+func (c *healthCheck) start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.check()
+			case <-c.done.Wait():
+				return
+			}
+		}
+	}()
+}
+
+func (c *healthCheck) Close() error {
+	return c.done.Close()
+}
+
+func (c *healthCheck) check() {
+	if !c.active() {
+		return
+	}
+	ipc, err := c.tunnel.IpcGet()
+	if err != nil {
+		return
+	}
+
+	c.access.Lock()
+	now := c.now()
+	var latest time.Time
+	var reason []interface{}
+	for _, peer := range parsePeerStatus(ipc) {
+		if peer.lastHandshake.After(latest) {
+			latest = peer.lastHandshake
+		}
+		counters := c.peers[peer.publicKey]
+		if counters == nil {
+			c.peers[peer.publicKey] = &peerCounters{rx: peer.rx, tx: peer.tx}
+			continue
+		}
+		sending := peer.tx > counters.tx
+		if sending && peer.rx == counters.rx {
+			counters.stalled++
+		} else {
+			counters.stalled = 0
+		}
+		counters.rx, counters.tx = peer.rx, peer.tx
+
+		since := peer.lastHandshake
+		if since.Before(c.since) {
+			since = c.since
+		}
+		switch {
+		case reason != nil:
+		case counters.stalled >= stalledChecks:
+			reason = []interface{}{"peer ", peer.publicKey, " sends nothing back"}
+		case sending && now.Sub(since) > c.timeout:
+			reason = []interface{}{"no handshake with peer ", peer.publicKey, " for ", now.Sub(since)}
+		}
+	}
+	if c.age != nil && !latest.IsZero() {
+		c.age.Set(int64(now.Sub(latest) / time.Second))
+	}
+	if reason != nil {
+		c.since = now
+		for _, counters := range c.peers {
+			counters.stalled = 0
+		}
+	}
+	c.access.Unlock()
+
+	if reason == nil {
+		return
+	}
+	newError(append([]interface{}{"reconnecting, as "}, reason...)...).AtInfo().WriteToLog()
+	if err := c.tunnel.Reconnect(c.ipc()); err != nil {
+		newError("failed to reconnect").Base(err).AtWarning().WriteToLog()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.handshakeTimeout)
+	defer cancel()
+	if err := c.tunnel.Handshake(ctx); err != nil {
+		newError("no handshake after reconnecting").Base(err).AtInfo().WriteToLog()
+	}
+}
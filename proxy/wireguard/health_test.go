@@ -0,0 +1,114 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+)
+
+type fakeHealthTunnel struct {
+	lastHandshake time.Time
+	rx, tx        uint64
+	reconnects    []string
+}
+
+func (t *fakeHealthTunnel) IpcGet() (string, error) {
+	return fmt.Sprintf("private_key=00\npublic_key=01\nendpoint=192.0.2.1:51820\nlast_handshake_time_sec=%d\nlast_handshake_time_nsec=%d\nrx_bytes=%d\ntx_bytes=%d\n",
+		t.lastHandshake.Unix(), t.lastHandshake.Nanosecond(), t.rx, t.tx), nil
+}
+
+func (t *fakeHealthTunnel) Reconnect(ipc string) error {
+	t.reconnects = append(t.reconnects, ipc)
+	return nil
+}
+
+func (t *fakeHealthTunnel) Handshake(ctx context.Context) error {
+	return nil
+}
+
+func TestParsePeerStatus(t *testing.T) {
+	peers := parsePeerStatus("private_key=00\nlisten_port=1337\npublic_key=01\nlast_handshake_time_sec=0\nlast_handshake_time_nsec=0\nrx_bytes=0\ntx_bytes=148\n" +
+		"public_key=02\nlast_handshake_time_sec=1700000000\nlast_handshake_time_nsec=5\nrx_bytes=92\ntx_bytes=148\n")
+	if len(peers) != 2 {
+		t.Fatal("expect 2 peers, but got ", len(peers))
+	}
+	if !peers[0].lastHandshake.IsZero() || peers[0].tx != 148 {
+		t.Error("unexpected status of peer 01: ", *peers[0])
+	}
+	if !peers[1].lastHandshake.Equal(time.Unix(1700000000, 5)) || peers[1].rx != 92 {
+		t.Error("unexpected status of peer 02: ", *peers[1])
+	}
+	if !lastHandshake("").IsZero() {
+		t.Error("expect no handshake without peers")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start
+	tunnel := &fakeHealthTunnel{lastHandshake: start}
+	c := newHealthCheck(tunnel, time.Minute, time.Second, func() string { return "ipc" })
+	c.since = start
+	c.now = func() time.Time { return now }
+	gauge := new(stats.Counter)
+	c.setGauge(gauge)
+	defer c.Close()
+
+	step := func(rx, tx uint64) {
+		now = now.Add(20 * time.Second)
+		tunnel.rx += rx
+		tunnel.tx += tx
+		c.check()
+	}
+	expect := func(reconnects int) {
+		t.Helper()
+		if len(tunnel.reconnects) != reconnects {
+			t.Fatal("expect ", reconnects, " reconnects, but got ", len(tunnel.reconnects))
+		}
+	}
+
+	// an idle peer is left alone, however old its handshake
+	for i := 0; i < 10; i++ {
+		step(0, 0)
+	}
+	expect(0)
+	if gauge.Value() != 200 {
+		t.Error("expect a handshake age of 200, but got ", gauge.Value())
+	}
+
+	// traffic both ways, with the handshakes renewed
+	for i := 0; i < 10; i++ {
+		tunnel.lastHandshake = now
+		step(100, 100)
+	}
+	expect(0)
+
+	// the peer sends nothing back
+	tunnel.lastHandshake = now
+	step(0, 100)
+	step(0, 100)
+	expect(0)
+	step(0, 100)
+	expect(1)
+	if tunnel.reconnects[0] != "ipc" {
+		t.Error("unexpected ipc ", tunnel.reconnects[0])
+	}
+
+	// the peer answers, but no handshake completes after the reconnection
+	step(10, 100)
+	step(10, 100)
+	step(10, 100)
+	expect(1)
+	step(10, 100)
+	expect(2)
+
+	// nothing is checked while the device is down
+	c.active = func() bool { return false }
+	for i := 0; i < 10; i++ {
+		step(0, 100)
+	}
+	expect(2)
+}
@@ -0,0 +1,136 @@
+package wireguard
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"golang.zx2c4.com/wireguard/device"
+
+	"github.com/xtls/xray-core/common/dice"
+)
+
+// obfuscator disguises the packets of a device the way AmneziaWG does: junk packets before
+// handshake initiations, random bytes before the handshake messages, and message types of its
+// own. The packets may also be masked with a secret, which AmneziaWG doesn't do. A nil
+// obfuscator leaves the packets as they are.
+type obfuscator struct {
+	junkCount          int
+	junkMin, junkMax   int
+	initJunk, respJunk int
+	// headers are the message types of the packets, or nil to keep them. The other bytes of the
+	// types of WireGuard, which some servers use, are then left alone too.
+	headers []uint32
+	mask    []byte
+}
+
+func newObfuscator(config *Obfuscation) *obfuscator {
+	if config == nil {
+		return nil
+	}
+	o := &obfuscator{
+		junkCount: int(config.JunkCount),
+		junkMin:   int(config.JunkMinSize),
+		junkMax:   int(config.JunkMaxSize),
+		initJunk:  int(config.InitJunkSize),
+		respJunk:  int(config.ResponseJunkSize),
+	}
+	if config.InitHeader != 0 {
+		o.headers = []uint32{config.InitHeader, config.ResponseHeader, config.CookieHeader, config.TransportHeader}
+	}
+	if config.MaskSecret != "" {
+		mask := sha256.Sum256([]byte(config.MaskSecret))
+		o.mask = mask[:]
+	}
+	if o.junkCount == 0 && o.initJunk == 0 && o.respJunk == 0 && o.headers == nil && o.mask == nil {
+		return nil
+	}
+	if o.junkMax < o.junkMin {
+		o.junkMax = o.junkMin
+	}
+	return o
+}
+
+func (o *obfuscator) xor(b []byte) {
+	for i := range b {
+		b[i] ^= o.mask[i%len(o.mask)]
+	}
+}
+
+func (o *obfuscator) junk(size int) []byte {
+	b := make([]byte, size)
+	rand.Read(b)
+	return b
+}
+
+// send writes packet with write, disguised, after the junk packets if it is a handshake
+// initiation. packet may be modified.
+func (o *obfuscator) send(packet []byte, write func([]byte) error) error {
+	if o == nil || len(packet) < 4 {
+		return write(packet)
+	}
+	var prefix int
+	t := uint32(packet[0])
+	switch t {
+	case device.MessageInitiationType:
+		for i := 0; i < o.junkCount; i++ {
+			j := o.junk(o.junkMin + dice.Roll(o.junkMax-o.junkMin+1))
+			if o.mask != nil {
+				o.xor(j)
+			}
+			if err := write(j); err != nil {
+				return err
+			}
+		}
+		prefix = o.initJunk
+	case device.MessageResponseType:
+		prefix = o.respJunk
+	case device.MessageCookieReplyType, device.MessageTransportType:
+	default:
+		return write(packet)
+	}
+	if o.headers != nil {
+		binary.LittleEndian.PutUint32(packet, o.headers[t-1])
+	}
+	if prefix > 0 {
+		packet = append(o.junk(prefix), packet...)
+	}
+	if o.mask != nil {
+		o.xor(packet)
+	}
+	return write(packet)
+}
+
+// open returns the packet of the device that b disguises, or false if b is junk or not from a
+// peer with the same obfuscation. b is modified, and the packet returned is a part of it.
+func (o *obfuscator) open(b []byte) ([]byte, bool) {
+	if o == nil {
+		return b, true
+	}
+	if o.mask != nil {
+		o.xor(b)
+	}
+	is := func(offset int, t uint32) bool {
+		if o.headers == nil {
+			return uint32(b[offset]) == t
+		}
+		return binary.LittleEndian.Uint32(b[offset:]) == o.headers[t-1]
+	}
+	var t uint32
+	switch {
+	case len(b) == o.initJunk+device.MessageInitiationSize && is(o.initJunk, device.MessageInitiationType):
+		b, t = b[o.initJunk:], device.MessageInitiationType
+	case len(b) == o.respJunk+device.MessageResponseSize && is(o.respJunk, device.MessageResponseType):
+		b, t = b[o.respJunk:], device.MessageResponseType
+	case len(b) == device.MessageCookieReplySize && is(0, device.MessageCookieReplyType):
+		t = device.MessageCookieReplyType
+	case len(b) >= device.MessageTransportSize && is(0, device.MessageTransportType):
+		t = device.MessageTransportType
+	default:
+		return nil, false
+	}
+	if o.headers != nil {
+		binary.LittleEndian.PutUint32(b, t)
+	}
+	return b, true
+}
@@ -0,0 +1,169 @@
+package wireguard
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"google.golang.org/protobuf/proto"
+)
+
+var testObfuscation = &Obfuscation{
+	JunkCount:        3,
+	JunkMinSize:      40,
+	JunkMaxSize:      70,
+	InitJunkSize:     15,
+	ResponseJunkSize: 30,
+	InitHeader:       0x5f1e2d3c,
+	ResponseHeader:   0x2a3b4c5d,
+	CookieHeader:     0x11223344,
+	TransportHeader:  0x7a6b5c4d,
+	MaskSecret:       "secret",
+}
+
+func newTestMessage(t uint32, size int) []byte {
+	b := make([]byte, size)
+	rand.Read(b[4:])
+	binary.LittleEndian.PutUint32(b, t)
+	return b
+}
+
+func TestObfuscator(t *testing.T) {
+	if newObfuscator(nil) != nil || newObfuscator(&Obfuscation{}) != nil {
+		t.Fatal("expect no obfuscation by default")
+	}
+
+	o := newObfuscator(testObfuscation)
+	otherObfuscation := proto.Clone(testObfuscation).(*Obfuscation)
+	otherObfuscation.MaskSecret = "other"
+	other := newObfuscator(otherObfuscation)
+	for _, c := range []struct {
+		message []byte
+		junk    int
+		size    int
+	}{
+		{newTestMessage(device.MessageInitiationType, device.MessageInitiationSize), 3, device.MessageInitiationSize + 15},
+		{newTestMessage(device.MessageResponseType, device.MessageResponseSize), 0, device.MessageResponseSize + 30},
+		{newTestMessage(device.MessageCookieReplyType, device.MessageCookieReplySize), 0, device.MessageCookieReplySize},
+		{newTestMessage(device.MessageTransportType, 1200), 0, 1200},
+	} {
+		message := append([]byte(nil), c.message...)
+		var sent [][]byte
+		common.Must(o.send(c.message, func(b []byte) error {
+			sent = append(sent, append([]byte(nil), b...))
+			return nil
+		}))
+		if len(sent) != c.junk+1 {
+			t.Fatal("expect ", c.junk, " junk packets, but got ", len(sent)-1)
+		}
+		for _, junk := range sent[:c.junk] {
+			if len(junk) < 40 || len(junk) > 70 {
+				t.Error("unexpected junk size ", len(junk))
+			}
+			if _, ok := o.open(junk); ok {
+				t.Error("junk taken for a packet")
+			}
+		}
+		packet := sent[c.junk]
+		if len(packet) != c.size || bytes.Contains(packet, message[4:]) {
+			t.Error("packet of type ", message[0], " not disguised")
+		}
+		// a peer without the same obfuscation can't tell the packet
+		if _, ok := other.open(append([]byte(nil), packet...)); ok {
+			t.Error("packet of type ", message[0], " opened with another secret")
+		}
+		opened, ok := o.open(packet)
+		if !ok || !bytes.Equal(opened, message) {
+			t.Error("packet of type ", message[0], " not recovered")
+		}
+	}
+}
+
+type pipeDialer struct {
+	conn net.Conn
+}
+
+func (d *pipeDialer) Dial(context.Context, xnet.Destination) (stat.Connection, error) {
+	return d.conn, nil
+}
+
+func (*pipeDialer) Address() xnet.Address {
+	return nil
+}
+
+func (*pipeDialer) DestIpAddress() net.IP {
+	return nil
+}
+
+func TestBindObfuscation(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	client := &netBindClient{
+		netBind: netBind{obfs: newObfuscator(testObfuscation)},
+		ctx:     context.Background(),
+		dialer:  &pipeDialer{clientConn},
+	}
+	server := &netBindServer{
+		netBind: netBind{obfs: newObfuscator(testObfuscation)},
+	}
+	clientReceivers, _, err := client.Open(0)
+	common.Must(err)
+	defer client.Close()
+	serverReceivers, _, err := server.Open(0)
+	common.Must(err)
+	defer server.Close()
+
+	serverEndpoint := newTestEndpoint(server, "127.0.0.1:10000", serverConn)
+	go server.serve(buf.NewPacketReader(serverConn), serverEndpoint)
+	clientEndpoint, err := client.ParseEndpoint("127.0.0.1:51820")
+	common.Must(err)
+
+	receive := func(receiver conn.ReceiveFunc) []byte {
+		bufs, sizes, eps := [][]byte{make([]byte, 1500)}, make([]int, 1), make([]conn.Endpoint, 1)
+		_, err := receiver(bufs, sizes, eps)
+		common.Must(err)
+		return bufs[0][:sizes[0]]
+	}
+	expect := func(received, message []byte) {
+		t.Helper()
+		if !bytes.Equal(received, message) {
+			t.Error("packet of type ", message[0], " not received as sent")
+		}
+	}
+	toServer := func(message []byte) {
+		common.Must(client.Send([][]byte{append([]byte(nil), message...)}, clientEndpoint))
+		expect(receive(serverReceivers[0]), message)
+	}
+	toClient := func(message []byte) {
+		sent := make(chan error, 1)
+		go func() {
+			sent <- server.Send([][]byte{append([]byte(nil), message...)}, serverEndpoint)
+		}()
+		// the junk the server sends is handed over empty
+		received := receive(clientReceivers[0])
+		for len(received) == 0 {
+			received = receive(clientReceivers[0])
+		}
+		common.Must(<-sent)
+		expect(received, message)
+	}
+
+	toServer(newTestMessage(device.MessageInitiationType, device.MessageInitiationSize))
+	toClient(newTestMessage(device.MessageResponseType, device.MessageResponseSize))
+	toServer(newTestMessage(device.MessageTransportType, 1000))
+	toClient(newTestMessage(device.MessageTransportType, 1000))
+	toClient(newTestMessage(device.MessageInitiationType, device.MessageInitiationSize))
+	toServer(newTestMessage(device.MessageCookieReplyType, device.MessageCookieReplySize))
+}
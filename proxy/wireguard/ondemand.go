@@ -0,0 +1,147 @@
+package wireguard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// onDemandDevice is the part of a Tunnel that onDemand drives.
+type onDemandDevice interface {
+	Up() error
+	Down() error
+	Handshake(ctx context.Context) error
+}
+
+type idleTimer interface {
+	Stop() bool
+}
+
+// onDemand keeps a device up only while flows go through it: it brings the device up for the first flow,
+// and down once no flow is left for the idle timeout, so that its timers don't keep the radio awake.
+type onDemand struct {
+	access sync.Mutex
+	device onDemandDevice
+	up     bool
+	flows  int
+	// idle counts the idle timers, so that one that fires after a new flow started does nothing.
+	idle  uint64
+	timer idleTimer
+	gauge stats.Counter
+
+	idleTimeout      time.Duration
+	handshakeTimeout time.Duration
+	afterFunc        func(d time.Duration, f func()) idleTimer
+}
+
+func newOnDemand(idleTimeout, handshakeTimeout time.Duration) *onDemand {
+	return &onDemand{
+		idleTimeout:      idleTimeout,
+		handshakeTimeout: handshakeTimeout,
+		afterFunc: func(d time.Duration, f func()) idleTimer {
+			return time.AfterFunc(d, f)
+		},
+	}
+}
+
+// setGauge sets the gauge that tells whether the device is up.
+func (o *onDemand) setGauge(gauge stats.Counter) {
+	o.access.Lock()
+	defer o.access.Unlock()
+
+	o.gauge = gauge
+	o.updateGauge()
+}
+
+func (o *onDemand) updateGauge() {
+	if o.gauge == nil {
+		return
+	}
+	if o.up {
+		o.gauge.Set(1)
+	} else {
+		o.gauge.Set(0)
+	}
+}
+
+// acquire counts a flow through device until release is called. A device acquire hasn't seen before
+// is taken as up, as it is when created. If device is down, it is brought up, and acquire waits for
+// a handshake so the flow doesn't start into a device that can't send yet.
+func (o *onDemand) acquire(ctx context.Context, device onDemandDevice) (release func(), err error) {
+	o.access.Lock()
+	if device != o.device {
+		o.device = device
+		o.up = true
+		o.updateGauge()
+	}
+	o.flows++
+	o.idle++
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+	wasUp := o.up
+	if !o.up {
+		if err := device.Up(); err != nil {
+			o.flows--
+			o.access.Unlock()
+			return nil, newError("failed to bring the device up").Base(err)
+		}
+		o.up = true
+		o.updateGauge()
+		newError("device brought up").AtDebug().WriteToLog()
+	}
+	o.access.Unlock()
+
+	release = func() {
+		o.access.Lock()
+		defer o.access.Unlock()
+
+		o.flows--
+		if o.flows > 0 || o.device != device {
+			return
+		}
+		o.idle++
+		idle := o.idle
+		o.timer = o.afterFunc(o.idleTimeout, func() {
+			o.shutdown(device, idle)
+		})
+	}
+
+	if !wasUp {
+		ctx, cancel := context.WithTimeout(ctx, o.handshakeTimeout)
+		defer cancel()
+		if err := device.Handshake(ctx); err != nil {
+			// the packets sent meanwhile are queued by the device until the handshake completes
+			newError("no handshake yet after the device was brought up").Base(err).AtInfo().WriteToLog()
+		}
+	}
+	return release, nil
+}
+
+// isUp tells whether device is up, taking a device acquire hasn't seen before as up.
+func (o *onDemand) isUp(device onDemandDevice) bool {
+	o.access.Lock()
+	defer o.access.Unlock()
+
+	return o.device != device || o.up
+}
+
+func (o *onDemand) shutdown(device onDemandDevice, idle uint64) {
+	o.access.Lock()
+	defer o.access.Unlock()
+
+	if o.idle != idle || o.flows > 0 || o.device != device || !o.up {
+		return
+	}
+	o.timer = nil
+	if err := device.Down(); err != nil {
+		newError("failed to bring the device down").Base(err).AtWarning().WriteToLog()
+		return
+	}
+	o.up = false
+	o.updateGauge()
+	newError("device brought down after being idle for ", o.idleTimeout).AtDebug().WriteToLog()
+}
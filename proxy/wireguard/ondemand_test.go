@@ -0,0 +1,170 @@
+package wireguard
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+)
+
+type fakeDevice struct {
+	access     sync.Mutex
+	up         bool
+	handshaken bool
+	events     []string
+}
+
+func (d *fakeDevice) Up() error {
+	d.access.Lock()
+	defer d.access.Unlock()
+	d.up = true
+	d.handshaken = false
+	d.events = append(d.events, "up")
+	return nil
+}
+
+func (d *fakeDevice) Down() error {
+	d.access.Lock()
+	defer d.access.Unlock()
+	d.up = false
+	d.events = append(d.events, "down")
+	return nil
+}
+
+func (d *fakeDevice) Handshake(ctx context.Context) error {
+	d.access.Lock()
+	defer d.access.Unlock()
+	d.handshaken = true
+	return nil
+}
+
+// send is what a flow does first, which must not be lost.
+func (d *fakeDevice) send() bool {
+	d.access.Lock()
+	defer d.access.Unlock()
+	return d.up && d.handshaken
+}
+
+func (d *fakeDevice) transitions() []string {
+	d.access.Lock()
+	defer d.access.Unlock()
+	return append([]string(nil), d.events...)
+}
+
+type fakeTimer struct {
+	at      time.Duration
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.stopped = true
+	return true
+}
+
+type fakeClock struct {
+	access sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) idleTimer {
+	c.access.Lock()
+	defer c.access.Unlock()
+	t := &fakeTimer{at: c.now + d, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.access.Lock()
+	c.now += d
+	var due []*fakeTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case t.at <= c.now:
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.access.Unlock()
+
+	for _, t := range due {
+		t.f()
+	}
+}
+
+func TestOnDemand(t *testing.T) {
+	clock := &fakeClock{}
+	o := newOnDemand(time.Minute, time.Second)
+	o.afterFunc = clock.afterFunc
+	gauge := new(stats.Counter)
+	o.setGauge(gauge)
+
+	device := new(fakeDevice)
+	expect := func(state int64, transitions ...string) {
+		t.Helper()
+		if gauge.Value() != state {
+			t.Error("expect gauge ", state, ", but got ", gauge.Value())
+		}
+		if got := device.transitions(); len(got) != len(transitions) {
+			t.Fatal("expect transitions ", transitions, ", but got ", got)
+		}
+	}
+	acquire := func() func() {
+		t.Helper()
+		release, err := o.acquire(context.Background(), device)
+		common.Must(err)
+		if !device.send() {
+			t.Fatal("first packet sent before the device is up and handshaken")
+		}
+		return release
+	}
+
+	// the device is up when created, as it is created for the first flow
+	common.Must(device.Up())
+	common.Must(device.Handshake(context.Background()))
+	release := acquire()
+	expect(1, "up")
+
+	// concurrent flows
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := o.acquire(context.Background(), device)
+			common.Must(err)
+			release()
+		}()
+	}
+	wg.Wait()
+	clock.advance(30 * time.Second)
+	expect(1, "up")
+
+	release()
+	clock.advance(59 * time.Second)
+	expect(1, "up")
+
+	// a flow before the idle timeout keeps the device up
+	release = acquire()
+	clock.advance(time.Hour)
+	expect(1, "up")
+	release()
+	clock.advance(time.Minute)
+	expect(0, "up", "down")
+
+	// and the next flow brings it up again
+	release = acquire()
+	expect(1, "up", "down", "up")
+	release()
+	clock.advance(time.Minute)
+	expect(0, "up", "down", "up", "down")
+}
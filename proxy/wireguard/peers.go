@@ -0,0 +1,79 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
+)
+
+// peerGaugeInterval is how often the gauges of the peers are refreshed.
+const peerGaugeInterval = 10 * time.Second
+
+type ipcDevice interface {
+	IpcGet() (string, error)
+}
+
+// reportPeers returns the status of the peers of device, and sets their gauges in manager:
+// "wireguard>>>peer>>>key>>>rx_bytes" and "tx_bytes", the bytes received from and sent to
+// the peer, and "handshake_age", the seconds since the last handshake with it. The keys
+// are in base64, as in the config.
+func reportPeers(device ipcDevice, manager stats.Manager, now time.Time) ([]*proxy.PeerStatus, error) {
+	ipc, err := device.IpcGet()
+	if err != nil {
+		return nil, newError("failed to get the status of the device").Base(err)
+	}
+	var peers []*proxy.PeerStatus
+	for _, peer := range parsePeerStatus(ipc) {
+		status := &proxy.PeerStatus{
+			PublicKey:     peer.publicKey,
+			Endpoint:      peer.endpoint,
+			LastHandshake: peer.lastHandshake,
+			RxBytes:       peer.rx,
+			TxBytes:       peer.tx,
+		}
+		if key, err := hex.DecodeString(peer.publicKey); err == nil {
+			status.PublicKey = base64.StdEncoding.EncodeToString(key)
+		}
+		peers = append(peers, status)
+
+		if manager == nil {
+			continue
+		}
+		prefix := "wireguard>>>peer>>>" + status.PublicKey + ">>>"
+		setGauge(manager, prefix+"rx_bytes", int64(peer.rx))
+		setGauge(manager, prefix+"tx_bytes", int64(peer.tx))
+		if !peer.lastHandshake.IsZero() {
+			setGauge(manager, prefix+"handshake_age", int64(now.Sub(peer.lastHandshake)/time.Second))
+		}
+	}
+	return peers, nil
+}
+
+// refreshPeerGauges sets the gauges of the peers every interval, so that they are current however the stats are
+// read, until it is closed. device returns nil while there is none.
+func refreshPeerGauges(device func() ipcDevice, manager stats.Manager, interval time.Duration) *task.Periodic {
+	return &task.Periodic{
+		Interval: interval,
+		Execute: func() error {
+			if d := device(); d != nil {
+				if _, err := reportPeers(d, manager, time.Now()); err != nil {
+					newError("failed to refresh the gauges of the peers").Base(err).AtDebug().WriteToLog()
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func setGauge(manager stats.Manager, name string, value int64) {
+	gauge, err := stats.GetOrRegisterGauge(manager, name)
+	if err != nil {
+		newError("failed to register ", name).Base(err).AtWarning().WriteToLog()
+		return
+	}
+	gauge.Set(value)
+}
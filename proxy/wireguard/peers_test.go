@@ -0,0 +1,87 @@
+package wireguard
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+)
+
+type fakeIpcDevice string
+
+func (d fakeIpcDevice) IpcGet() (string, error) {
+	return string(d), nil
+}
+
+func TestReportPeers(t *testing.T) {
+	m, err := stats.NewManager(nil, &stats.Config{})
+	common.Must(err)
+	device := fakeIpcDevice("private_key=00\npublic_key=0102\nendpoint=192.0.2.1:51820\nlast_handshake_time_sec=1700000000\nlast_handshake_time_nsec=0\nrx_bytes=92\ntx_bytes=148\n" +
+		"public_key=0304\nlast_handshake_time_sec=0\nlast_handshake_time_nsec=0\nrx_bytes=0\ntx_bytes=148\n")
+	now := time.Unix(1700000030, 0)
+
+	// the gauges are set to the latest values, however often they are reported
+	for i := 0; i < 2; i++ {
+		peers, err := reportPeers(device, m, now)
+		common.Must(err)
+		if len(peers) != 2 {
+			t.Fatal("expect 2 peers, but got ", len(peers))
+		}
+		if peers[0].PublicKey != "AQI=" || peers[0].Endpoint != "192.0.2.1:51820" || peers[0].RxBytes != 92 {
+			t.Error("unexpected status of the 1st peer: ", *peers[0])
+		}
+		if !peers[1].LastHandshake.IsZero() {
+			t.Error("expect no handshake with the 2nd peer, but got ", peers[1].LastHandshake)
+		}
+	}
+	for name, value := range map[string]int64{
+		"wireguard>>>peer>>>AQI=>>>rx_bytes":      92,
+		"wireguard>>>peer>>>AQI=>>>tx_bytes":      148,
+		"wireguard>>>peer>>>AQI=>>>handshake_age": 30,
+		"wireguard>>>peer>>>AwQ=>>>tx_bytes":      148,
+	} {
+		gauge := m.GetGauge(name)
+		if gauge == nil || gauge.Value() != value {
+			t.Error("expect ", name, " to be ", value)
+		}
+	}
+	if m.GetGauge("wireguard>>>peer>>>AwQ=>>>handshake_age") != nil {
+		t.Error("expect no handshake age without a handshake")
+	}
+
+	// nothing to set without a manager
+	if _, err := reportPeers(device, nil, now); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshPeerGauges(t *testing.T) {
+	m, err := stats.NewManager(nil, &stats.Config{})
+	common.Must(err)
+	var device atomic.Value
+	device.Store(fakeIpcDevice("public_key=0102\nrx_bytes=92\ntx_bytes=148\n"))
+	gauges := refreshPeerGauges(func() ipcDevice {
+		return device.Load().(fakeIpcDevice)
+	}, m, 10*time.Millisecond)
+	common.Must(gauges.Start())
+	defer gauges.Close()
+
+	// the gauges are read through the manager alone, nothing asks for the status of the peers
+	gauge := m.GetGauge("wireguard>>>peer>>>AQI=>>>rx_bytes")
+	if gauge == nil || gauge.Value() != 92 {
+		t.Fatal("expect the gauges set once started")
+	}
+	device.Store(fakeIpcDevice("public_key=0102\nrx_bytes=200\ntx_bytes=148\n"))
+	for deadline := time.Now().Add(time.Second); gauge.Value() != 200; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expect the gauges refreshed, but got ", gauge.Value())
+		}
+	}
+
+	// no device, nothing to refresh
+	none := refreshPeerGauges(func() ipcDevice { return nil }, m, time.Hour)
+	common.Must(none.Start())
+	none.Close()
+}
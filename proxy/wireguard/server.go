@@ -2,38 +2,29 @@ package wireguard
 
 import (
 	"context"
-	"errors"
-	"io"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
-	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
-	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport/internet/stat"
 )
 
-var nullDestination = net.TCPDestination(net.AnyIP, 0)
-
 type Server struct {
-	bindServer *netBindServer
-
-	info          routingInfo
-	policyManager policy.Manager
-}
-
-type routingInfo struct {
-	ctx         context.Context
-	dispatcher  routing.Dispatcher
-	inboundTag  *session.Inbound
-	outboundTag *session.Outbound
-	contentTag  *session.Content
+	bindServer   *netBindServer
+	forwarder    *Forwarder
+	tun          Tunnel
+	statsManager stats.Manager
+	peerGauges   *task.Periodic
 }
 
 func NewServer(ctx context.Context, conf *DeviceConfig) (*Server, error) {
@@ -47,29 +38,66 @@ func NewServer(ctx context.Context, conf *DeviceConfig) (*Server, error) {
 	server := &Server{
 		bindServer: &netBindServer{
 			netBind: netBind{
-				dns: v.GetFeature(dns.ClientType()).(dns.Client),
-				dnsOption: dns.IPOption{
-					IPv4Enable: hasIPv4,
-					IPv6Enable: hasIPv6,
-				},
+				dns:        v.GetFeature(dns.ClientType()).(dns.Client),
+				dnsOptions: conf.endpointIPOptions(hasIPv4, hasIPv6),
+				obfs:       newObfuscator(conf.Obfuscation),
 			},
 		},
-		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
+		forwarder: NewForwarder(v.GetFeature(policy.ManagerType()).(policy.Manager)),
 	}
 
-	tun, err := conf.createTun()(endpoints, int(conf.Mtu), server.forwardConnection)
+	handler := server.forwarder.ForwardConnection
+	if !conf.ForwardPing {
+		handler = func(source, dest net.Destination, conn net.Conn) {
+			if dest.Network == net.Network_ICMP {
+				// answered with destination unreachable
+				conn.Close()
+				return
+			}
+			server.forwarder.ForwardConnection(source, dest, conn)
+		}
+	}
+	tun, err := conf.createTun()(endpoints, int(conf.Mtu), handler)
 	if err != nil {
 		return nil, err
 	}
+	if conf.ClampMss != 0 && clampMSS(tun, conf.ClampMss) {
+		newError("device created with MTU ", conf.Mtu, ", clamping the MSS to ", conf.ClampMss).AtInfo().WriteToLog()
+	} else {
+		newError("device created with MTU ", conf.Mtu).AtInfo().WriteToLog()
+	}
 
 	if err = tun.BuildDevice(createIPCRequest(conf), server.bindServer); err != nil {
 		_ = tun.Close()
 		return nil, err
 	}
+	server.forwarder.peerOf = tun.PeerEndpoint
+	server.forwarder.level = conf.UserLevel
+	server.tun = tun
+	server.statsManager, _ = v.GetFeature(stats.ManagerType()).(stats.Manager)
+	if server.statsManager != nil {
+		server.peerGauges = refreshPeerGauges(func() ipcDevice { return tun }, server.statsManager, peerGaugeInterval)
+		common.Must(server.peerGauges.Start())
+	}
 
 	return server, nil
 }
 
+// Close implements common.Closable. Bringing the device down closes the bind, which stops the
+// connections of the inbound feeding it, and closing the netstack interrupts the connections
+// forwarded from it.
+func (s *Server) Close() error {
+	if s.peerGauges != nil {
+		s.peerGauges.Close()
+	}
+	return errors.Combine(s.tun.Close(), s.forwarder.Close())
+}
+
+// Peers implements proxy.PeerReporter.
+func (s *Server) Peers() ([]*proxy.PeerStatus, error) {
+	return reportPeers(s.tun, s.statsManager, time.Now())
+}
+
 // Network implements proxy.Inbound.
 func (*Server) Network() []net.Network {
 	return []net.Network{net.Network_UDP}
@@ -80,16 +108,6 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	inbound := session.InboundFromContext(ctx)
 	inbound.Name = "wireguard"
 	inbound.CanSpliceCopy = 3
-	outbounds := session.OutboundsFromContext(ctx)
-	ob := outbounds[len(outbounds) - 1]
-
-	s.info = routingInfo{
-		ctx:         core.ToBackgroundDetachedContext(ctx),
-		dispatcher:  dispatcher,
-		inboundTag:  session.InboundFromContext(ctx),
-		outboundTag: ob,
-		contentTag:  session.ContentFromContext(ctx),
-	}
 
 	ep, err := s.bindServer.ParseEndpoint(conn.RemoteAddr().String())
 	if err != nil {
@@ -97,91 +115,12 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	}
 
 	nep := ep.(*netEndpoint)
-	nep.conn = conn
-
-	reader := buf.NewPacketReader(conn)
-	for {
-		mpayload, err := reader.ReadMultiBuffer()
-		if err != nil {
-			return err
-		}
-
-		for _, payload := range mpayload {
-			v, ok := <-s.bindServer.readQueue
-			if !ok {
-				return nil
-			}
-			i, err := payload.Read(v.buff)
-
-			v.bytes = i
-			v.endpoint = nep
-			v.err = err
-			v.waiter.Done()
-			if err != nil && errors.Is(err, io.EOF) {
-				nep.conn = nil
-				return nil
-			}
-		}
-	}
-}
-
-func (s *Server) forwardConnection(dest net.Destination, conn net.Conn) {
-	if s.info.dispatcher == nil {
-		newError("unexpected: dispatcher == nil").AtError().WriteToLog()
-		return
-	}
-	defer conn.Close()
-
-	ctx, cancel := context.WithCancel(core.ToBackgroundDetachedContext(s.info.ctx))
-	plcy := s.policyManager.ForLevel(0)
-	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+	nep.attach(conn)
+	defer nep.release(conn)
 
-	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
-		From:   nullDestination,
-		To:     dest,
-		Status: log.AccessAccepted,
-		Reason: "",
-	})
+	// the device reports the endpoint of a peer as the one its last authenticated packet came from,
+	// so connections it tunnels are dispatched with the session of that client
+	defer s.forwarder.setPeerRoutingInfo(nep.DstToString(), ctx, dispatcher)()
 
-	if s.info.inboundTag != nil {
-		ctx = session.ContextWithInbound(ctx, s.info.inboundTag)
-	}
-	if s.info.outboundTag != nil {
-		ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{s.info.outboundTag})
-	}
-	if s.info.contentTag != nil {
-		ctx = session.ContextWithContent(ctx, s.info.contentTag)
-	}
-
-	link, err := s.info.dispatcher.Dispatch(ctx, dest)
-	if err != nil {
-		newError("dispatch connection").Base(err).AtError().WriteToLog()
-	}
-	defer cancel()
-
-	requestDone := func() error {
-		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
-		if err := buf.Copy(buf.NewReader(conn), link.Writer, buf.UpdateActivity(timer)); err != nil {
-			return newError("failed to transport all TCP request").Base(err)
-		}
-
-		return nil
-	}
-
-	responseDone := func() error {
-		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
-		if err := buf.Copy(link.Reader, buf.NewWriter(conn), buf.UpdateActivity(timer)); err != nil {
-			return newError("failed to transport all TCP response").Base(err)
-		}
-
-		return nil
-	}
-
-	requestDonePost := task.OnSuccess(requestDone, task.Close(link.Writer))
-	if err := task.Run(ctx, requestDonePost, responseDone); err != nil {
-		common.Interrupt(link.Reader)
-		common.Interrupt(link.Writer)
-		newError("connection ends").Base(err).AtDebug().WriteToLog()
-		return
-	}
+	return s.bindServer.serve(buf.NewPacketReader(conn), nep)
 }
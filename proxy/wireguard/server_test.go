@@ -0,0 +1,117 @@
+package wireguard
+
+import (
+	"context"
+	gonet "net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	_ "github.com/xtls/xray-core/app/proxyman/inbound"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/testing/servers/udp"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// stuckDispatcher dispatches connections to an outbound that never answers.
+type stuckDispatcher struct {
+	dispatched chan struct{}
+}
+
+func (*stuckDispatcher) Type() interface{} {
+	return routing.DispatcherType()
+}
+
+func (*stuckDispatcher) Start() error {
+	return nil
+}
+
+func (*stuckDispatcher) Close() error {
+	return nil
+}
+
+func (d *stuckDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	_, uplinkWriter := pipe.New()
+	downlinkReader, _ := pipe.New()
+	d.dispatched <- struct{}{}
+	return &transport.Link{Reader: downlinkReader, Writer: uplinkWriter}, nil
+}
+
+func (*stuckDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+func TestServerRemoveHandler(t *testing.T) {
+	// the logger stops by itself once idle
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent(),
+		goleak.IgnoreTopFunction("github.com/xtls/xray-core/common/log.(*generalLogger).run"))
+
+	instance, err := core.New(&core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+		},
+	})
+	common.Must(err)
+	common.Must(instance.Start())
+	defer instance.Close()
+
+	config := &core.InboundHandlerConfig{
+		Tag: "wireguard",
+		ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+			PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(udp.PickPort())}},
+			Listen:   net.NewIPOrDomain(net.LocalHostIP),
+		}),
+		ProxySettings: serial.ToTypedMessage(&DeviceConfig{
+			SecretKey: "b89bf9b5930396db226049fe914c1bd0b97f0975a13246920965a17cf1193370",
+			Endpoint:  []string{"10.0.0.1"},
+			Mtu:       1420,
+			Peers: []*PeerConfig{{
+				PublicKey:  "6e65ce0be17517110c17d77288ad87e7fd5252dcc7d09b95a39d61db03df832a",
+				AllowedIps: []string{"10.0.0.2/32"},
+			}},
+		}),
+	}
+	manager := instance.GetFeature(inbound.ManagerType()).(inbound.Manager)
+	for i := 0; i < 2; i++ {
+		common.Must(core.AddInboundHandler(instance, config))
+		handler, err := manager.GetHandler(context.Background(), "wireguard")
+		common.Must(err)
+		p, _, _ := handler.(interface {
+			GetRandomInboundProxy() (interface{}, net.Port, int)
+		}).GetRandomInboundProxy()
+		server := p.(*Server)
+
+		// a connection from the peer through the tunnel, whose outbound never answers
+		const endpoint = "192.0.2.1:51820"
+		server.forwarder.peerOf = func(netip.Addr) (string, bool) {
+			return endpoint, true
+		}
+		dispatcher := &stuckDispatcher{dispatched: make(chan struct{}, 1)}
+		server.forwarder.setPeerRoutingInfo(endpoint, context.WithValue(context.Background(), xrayKey, instance), dispatcher)
+		local, remote := gonet.Pipe()
+		forwarded := make(chan struct{})
+		go func() {
+			server.forwarder.ForwardConnection(net.TCPDestination(net.ParseAddress("10.0.0.2"), 40000),
+				net.TCPDestination(net.ParseAddress("192.0.2.2"), 443), remote)
+			close(forwarded)
+		}()
+		<-dispatcher.dispatched
+
+		common.Must(manager.RemoveHandler(context.Background(), "wireguard"))
+		select {
+		case <-forwarded:
+		case <-time.After(5 * time.Second):
+			t.Fatal("forwarded connection not interrupted by the removal of the handler")
+		}
+		local.Close()
+	}
+}
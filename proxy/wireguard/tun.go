@@ -28,12 +28,24 @@ import (
 
 type tunCreator func(localAddresses []netip.Addr, mtu int, handler promiscuousModeHandler) (Tunnel, error)
 
-type promiscuousModeHandler func(dest xnet.Destination, conn net.Conn)
+type promiscuousModeHandler func(source, dest xnet.Destination, conn net.Conn)
 
 type Tunnel interface {
 	BuildDevice(ipc string, bind conn.Bind) error
 	DialContextTCPAddrPort(ctx context.Context, addr netip.AddrPort) (net.Conn, error)
 	DialUDPAddrPort(laddr, raddr netip.AddrPort) (net.Conn, error)
+	// PeerEndpoint returns the endpoint the device last saw the peer at, whose allowed IPs route src.
+	PeerEndpoint(src netip.Addr) (string, bool)
+	// Up and Down bring the device up and down. A device that is down keeps its config, but has
+	// no timers running and its bind closed.
+	Up() error
+	Down() error
+	// Handshake starts handshakes with the peers, and returns when one completes or ctx is done.
+	Handshake(ctx context.Context) error
+	// IpcGet returns the config and the state of the device.
+	IpcGet() (string, error)
+	// Reconnect brings the device down, sets ipc and brings it up again, dropping the sessions with the peers.
+	Reconnect(ipc string) error
 	Close() error
 }
 
@@ -76,6 +88,144 @@ func (t *tunnel) BuildDevice(ipc string, bind conn.Bind) (err error) {
 	return nil
 }
 
+func (t *tunnel) PeerEndpoint(src netip.Addr) (string, bool) {
+	t.rw.Lock()
+	device := t.device
+	t.rw.Unlock()
+
+	if device == nil {
+		return "", false
+	}
+	ipc, err := device.IpcGet()
+	if err != nil {
+		return "", false
+	}
+	return peerEndpoint(ipc, src)
+}
+
+func (t *tunnel) Up() error {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+
+	if t.device == nil {
+		return errors.New("device is closed")
+	}
+	return t.device.Up()
+}
+
+func (t *tunnel) Down() error {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+
+	if t.device == nil {
+		return errors.New("device is closed")
+	}
+	return t.device.Down()
+}
+
+func (t *tunnel) IpcGet() (string, error) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+
+	if t.device == nil {
+		return "", errors.New("device is closed")
+	}
+	return t.device.IpcGet()
+}
+
+func (t *tunnel) Reconnect(ipc string) error {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+
+	if t.device == nil {
+		return errors.New("device is closed")
+	}
+	if err := t.device.Down(); err != nil {
+		return err
+	}
+	if err := t.device.IpcSet(ipc); err != nil {
+		return err
+	}
+	return t.device.Up()
+}
+
+func (t *tunnel) Handshake(ctx context.Context) error {
+	t.rw.Lock()
+	dev := t.device
+	t.rw.Unlock()
+
+	if dev == nil {
+		return errors.New("device is closed")
+	}
+	ipc, err := dev.IpcGet()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for _, line := range strings.Split(ipc, "\n") {
+		if key, value, _ := strings.Cut(line, "="); key == "public_key" {
+			var pk device.NoisePublicKey
+			if pk.FromHex(value) != nil {
+				continue
+			}
+			if peer := dev.LookupPeer(pk); peer != nil {
+				_ = peer.SendHandshakeInitiation(false)
+			}
+		}
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		ipc, err := dev.IpcGet()
+		if err != nil {
+			return err
+		}
+		if lastHandshake(ipc).After(start) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// lastHandshake returns the time of the latest handshake with any peer in the output of IpcGet.
+func lastHandshake(ipc string) time.Time {
+	var latest time.Time
+	for _, peer := range parsePeerStatus(ipc) {
+		if peer.lastHandshake.After(latest) {
+			latest = peer.lastHandshake
+		}
+	}
+	return latest
+}
+
+// peerEndpoint finds the peer whose allowed IPs route src in the output of IpcGet, by longest prefix match
+// like the device, and returns its endpoint.
+func peerEndpoint(ipc string, src netip.Addr) (string, bool) {
+	var endpoint, found string
+	bits := -1
+	for _, line := range strings.Split(ipc, "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "public_key":
+			endpoint = ""
+		case "endpoint":
+			endpoint = value
+		case "allowed_ip":
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil || !prefix.Contains(src) || prefix.Bits() <= bits {
+				continue
+			}
+			found, bits = endpoint, prefix.Bits()
+		}
+	}
+	return found, found != ""
+}
+
 func (t *tunnel) Close() (err error) {
 	t.rw.Lock()
 	defer t.rw.Unlock()
@@ -137,6 +287,18 @@ func (g *gvisorNet) DialUDPAddrPort(laddr, raddr netip.AddrPort) (net.Conn, erro
 	return g.net.DialUDPAddrPort(laddr, raddr)
 }
 
+// clampMSS makes t lower the MSS of TCP connections through it to mss, and returns whether it does.
+// Only netstack can, as the kernel has its own means.
+func clampMSS(t Tunnel, mss uint32) bool {
+	g, ok := t.(*gvisorNet)
+	if !ok {
+		newError("MSS clamping is not supported in kernel mode, use the TCPMSS target of iptables instead").AtWarning().WriteToLog()
+		return false
+	}
+	g.net.ClampMSS(uint16(mss))
+	return true
+}
+
 func createGVisorTun(localAddresses []netip.Addr, mtu int, handler promiscuousModeHandler) (Tunnel, error) {
 	out := &gvisorNet{}
 	tun, n, stack, err := gvisortun.CreateNetTUN(localAddresses, mtu, handler != nil)
@@ -168,8 +330,9 @@ func createGVisorTun(localAddresses []netip.Addr, mtu int, handler promiscuousMo
 				// enable tcp keep-alive to prevent hanging connections
 				ep.SocketOptions().SetKeepAlive(true)
 
-				// local address is actually destination
-				handler(xnet.TCPDestination(xnet.IPAddress(id.LocalAddress.AsSlice()), xnet.Port(id.LocalPort)), gonet.NewTCPConn(&wq, ep))
+				// local address is actually destination, and remote address the source inside the tunnel
+				handler(xnet.TCPDestination(xnet.IPAddress(id.RemoteAddress.AsSlice()), xnet.Port(id.RemotePort)),
+					xnet.TCPDestination(xnet.IPAddress(id.LocalAddress.AsSlice()), xnet.Port(id.LocalPort)), gonet.NewTCPConn(&wq, ep))
 			}(r)
 		})
 		stack.SetTransportProtocolHandler(tcp.ProtocolNumber, tcpForwarder.HandlePacket)
@@ -194,12 +357,26 @@ func createGVisorTun(localAddresses []netip.Addr, mtu int, handler promiscuousMo
 					Timeout: 15 * time.Second,
 				})
 
-				handler(xnet.UDPDestination(xnet.IPAddress(id.LocalAddress.AsSlice()), xnet.Port(id.LocalPort)), gonet.NewUDPConn(stack, &wq, ep))
+				handler(xnet.UDPDestination(xnet.IPAddress(id.RemoteAddress.AsSlice()), xnet.Port(id.RemotePort)),
+					xnet.UDPDestination(xnet.IPAddress(id.LocalAddress.AsSlice()), xnet.Port(id.LocalPort)), gonet.NewUDPConn(stack, &wq, ep))
 			}(r)
 		})
 		stack.SetTransportProtocolHandler(udp.ProtocolNumber, udpForwarder.HandlePacket)
+
+		n.HandleEcho(newEchoForwarder(n, handler).handle)
 	}
 
 	out.tun, out.net = tun, n
 	return out, nil
 }
+
+// CreatePromiscuousNetTUN creates a netstack device that accepts every TCP and UDP flow
+// written to it, and hands them to handler together with their original destination.
+// Packets read from the device are the replies of these flows.
+func CreatePromiscuousNetTUN(localAddresses []netip.Addr, mtu int, handler func(source, dest xnet.Destination, conn net.Conn)) (tun.Device, error) {
+	t, err := createGVisorTun(localAddresses, mtu, handler)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*gvisorNet).tun, nil
+}
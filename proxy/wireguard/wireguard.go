@@ -74,6 +74,35 @@ func parseEndpoints(conf *DeviceConfig) ([]netip.Addr, bool, bool, error) {
 	return endpoints, hasIPv4, hasIPv6, nil
 }
 
+// parseAllowedIPs returns the union of the allowed IPs of the peers, the destinations the device has a peer for.
+func parseAllowedIPs(conf *DeviceConfig) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, peer := range conf.Peers {
+		for _, str := range peer.AllowedIps {
+			prefix, err := netip.ParsePrefix(str)
+			if err != nil {
+				return nil, newError("invalid allowed IP of peer ", peer.PublicKey).Base(err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		}
+	}
+	return prefixes, nil
+}
+
+// routes tells whether addr is in one of prefixes. Any address is, when there are no prefixes.
+func routes(prefixes []netip.Prefix, addr netip.Addr) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // serialize the config into an IPC request
 func createIPCRequest(conf *DeviceConfig) string {
 	var request strings.Builder
@@ -2,14 +2,17 @@ package scenarios
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	stdhttp "net/http"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/gorilla/websocket"
 	"github.com/xtls/xray-core/app/commander"
 	"github.com/xtls/xray-core/app/policy"
 	"github.com/xtls/xray-core/app/proxyman"
@@ -31,6 +34,9 @@ import (
 	"github.com/xtls/xray-core/testing/servers/tcp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
 )
 
 func TestCommanderListenConfigurationItem(t *testing.T) {
@@ -565,3 +571,146 @@ func TestCommanderStats(t *testing.T) {
 		t.Error("value < 10240*1024: ", sresp.Stat.Value)
 	}
 }
+
+func TestCommanderGateway(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	clientPort := tcp.PickPort()
+	cmdPort := tcp.PickPort()
+	gatewayPort := tcp.PickPort()
+	const token = "secret"
+	newInbound := func(tag string, port net.Port) *core.InboundHandlerConfig {
+		return &core.InboundHandlerConfig{
+			Tag: tag,
+			ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+				PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(port)}},
+				Listen:   net.NewIPOrDomain(net.LocalHostIP),
+			}),
+			ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+				Address:  net.NewIPOrDomain(dest.Address),
+				Port:     uint32(dest.Port),
+				Networks: []net.Network{net.Network_TCP},
+			}),
+		}
+	}
+	clientConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&commander.Config{
+				Tag:    "api",
+				Listen: fmt.Sprintf("127.0.0.1:%d", cmdPort),
+				Service: []*serial.TypedMessage{
+					serial.ToTypedMessage(&command.Config{}),
+					serial.ToTypedMessage(&statscmd.Config{}),
+				},
+				Gateway: &commander.GatewayConfig{
+					Listen:         fmt.Sprintf("127.0.0.1:%d", gatewayPort),
+					Token:          token,
+					AllowedOrigins: []string{"https://panel.example"},
+				},
+			}),
+			serial.ToTypedMessage(&policy.Config{
+				System: &policy.SystemPolicy{
+					Stats: &policy.SystemPolicy_Stats{
+						InboundUplink:   true,
+						InboundDownlink: true,
+					},
+				},
+			}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			newInbound("d", clientPort),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(clientConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	if err := testTCPConn(clientPort, 1024, time.Second*5)(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmdConn, err := grpc.Dial(fmt.Sprintf("127.0.0.1:%d", cmdPort), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	common.Must(err)
+	defer cmdConn.Close()
+
+	gatewayURL := fmt.Sprintf("ws://127.0.0.1:%d/api/ws", gatewayPort)
+	if _, resp, err := websocket.DefaultDialer.Dial(gatewayURL, nil); err == nil || resp == nil || resp.StatusCode != 401 {
+		t.Fatal("expect 401 without the token, but got ", resp, err)
+	}
+	if _, resp, err := websocket.DefaultDialer.Dial(gatewayURL+"?access_token="+token, stdhttp.Header{"Origin": {"https://evil.example"}}); err == nil || resp == nil || resp.StatusCode != 403 {
+		t.Fatal("expect 403 from an origin not allowed, but got ", resp, err)
+	}
+	ws, _, err := websocket.DefaultDialer.Dial(gatewayURL+"?access_token="+token, stdhttp.Header{"Origin": {"https://panel.example"}})
+	common.Must(err)
+	defer ws.Close()
+
+	call := func(id int, method string, request, response proto.Message) {
+		params, err := protojson.Marshal(request)
+		common.Must(err)
+		common.Must(ws.WriteJSON(map[string]interface{}{
+			"id":     id,
+			"method": method,
+			"params": json.RawMessage(params),
+		}))
+		var reply struct {
+			ID     int             `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		common.Must(ws.ReadJSON(&reply))
+		if reply.ID != id {
+			t.Fatal("expect reply to ", id, ", but got ", reply.ID)
+		}
+		if reply.Error != nil {
+			t.Fatal(method, " failed: ", reply.Error.Code, " ", reply.Error.Message)
+		}
+		common.Must(protojson.Unmarshal(reply.Result, response))
+	}
+
+	sClient := statscmd.NewStatsServiceClient(cmdConn)
+	statsRequest := &statscmd.QueryStatsRequest{Pattern: "inbound>>>d>>>"}
+	grpcStats, err := sClient.QueryStats(context.Background(), statsRequest)
+	common.Must(err)
+	wsStats := new(statscmd.QueryStatsResponse)
+	call(1, "xray.app.stats.command.StatsService/QueryStats", statsRequest, wsStats)
+	byName := protocmp.SortRepeated(func(a, b *statscmd.Stat) bool { return a.Name < b.Name })
+	if len(grpcStats.Stat) == 0 {
+		t.Error("expect inbound stats")
+	}
+	if r := cmp.Diff(grpcStats, wsStats, protocmp.Transform(), byName); r != "" {
+		t.Error(r)
+	}
+
+	hsClient := command.NewHandlerServiceClient(cmdConn)
+	grpcAdd, err := hsClient.AddInbound(context.Background(), &command.AddInboundRequest{
+		Inbound: newInbound("grpc", tcp.PickPort()),
+	})
+	common.Must(err)
+	wsAdd := new(command.AddInboundResponse)
+	call(2, "xray.app.proxyman.command.HandlerService/AddInbound", &command.AddInboundRequest{
+		Inbound: newInbound("ws", tcp.PickPort()),
+	}, wsAdd)
+	if r := cmp.Diff(grpcAdd, wsAdd, protocmp.Transform()); r != "" {
+		t.Error(r)
+	}
+	for _, tag := range []string{"grpc", "ws"} {
+		if _, err := hsClient.RemoveInbound(context.Background(), &command.RemoveInboundRequest{Tag: tag}); err != nil {
+			t.Error("inbound ", tag, " not added: ", err)
+		}
+	}
+}
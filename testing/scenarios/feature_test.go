@@ -446,6 +446,96 @@ func TestBlackhole(t *testing.T) {
 	}
 }
 
+func TestInboundAddressRouting(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	tcpDest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	udpServer := udp.Server{
+		MsgProcessor: xor,
+	}
+	udpDest, err := udpServer.Start()
+	common.Must(err)
+	defer udpServer.Close()
+
+	// both inbounds listen on all addresses, and what comes to 127.0.0.2 is blocked
+	inbound := func(port net.Port, dest net.Destination) *core.InboundHandlerConfig {
+		return &core.InboundHandlerConfig{
+			ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+				PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(port)}},
+				Listen:   net.NewIPOrDomain(net.AnyIP),
+			}),
+			ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+				Address: net.NewIPOrDomain(dest.Address),
+				Port:    uint32(dest.Port),
+				NetworkList: &net.NetworkList{
+					Network: []net.Network{dest.Network},
+				},
+			}),
+		}
+	}
+	tcpPort := tcp.PickPort()
+	udpPort := udp.PickPort()
+	serverConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&router.Config{
+				Rule: []*router.RoutingRule{
+					{
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "blocked",
+						},
+						InboundGeoip: []*router.GeoIP{{
+							Cidr: []*router.CIDR{{Ip: []byte{127, 0, 0, 2}, Prefix: 32}},
+						}},
+					},
+				},
+			}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			inbound(tcpPort, tcpDest),
+			inbound(udpPort, udpDest),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+			{
+				Tag:           "blocked",
+				ProxySettings: serial.ToTypedMessage(&blackhole.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	for _, c := range []struct {
+		ip      net.IP
+		blocked bool
+	}{
+		{net.IP{127, 0, 0, 1}, false},
+		{net.IP{127, 0, 0, 2}, true},
+	} {
+		tcpConn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: c.ip, Port: int(tcpPort)})
+		common.Must(err)
+		if err := testTCPConn2(tcpConn, 1024, time.Second*2)(); (err != nil) != c.blocked {
+			t.Error("TCP to ", c.ip, ", expect blocked: ", c.blocked, ", but got ", err)
+		}
+		tcpConn.Close()
+
+		udpConn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: c.ip, Port: int(udpPort)})
+		common.Must(err)
+		if err := testTCPConn2(udpConn, 1024, time.Second*2)(); (err != nil) != c.blocked {
+			t.Error("UDP to ", c.ip, ", expect blocked: ", c.blocked, ", but got ", err)
+		}
+		udpConn.Close()
+	}
+}
+
 func TestForward(t *testing.T) {
 	tcpServer := tcp.Server{
 		MsgProcessor: xor,
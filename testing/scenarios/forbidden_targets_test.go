@@ -0,0 +1,98 @@
+package scenarios
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/dns"
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/proxy/freedom"
+	"github.com/xtls/xray-core/proxy/socks"
+	"github.com/xtls/xray-core/testing/servers/tcp"
+	xproxy "golang.org/x/net/proxy"
+)
+
+func TestForbiddenTargets(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	socksInbound := func(port net.Port, forbiddenTargets ...string) *core.InboundHandlerConfig {
+		return &core.InboundHandlerConfig{
+			ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+				PortList:         &net.PortList{Range: []*net.PortRange{net.SinglePortRange(port)}},
+				Listen:           net.NewIPOrDomain(net.LocalHostIP),
+				ForbiddenTargets: forbiddenTargets,
+			}),
+			ProxySettings: serial.ToTypedMessage(&socks.ServerConfig{
+				AuthType: socks.AuthType_NO_AUTH,
+				Address:  net.NewIPOrDomain(net.LocalHostIP),
+			}),
+		}
+	}
+	// the test server stands for a public target for the inbound that only forbids private addresses
+	restrictedPort := tcp.PickPort()
+	privatePort := tcp.PickPort()
+	serverConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&dns.Config{
+				Hosts: map[string]*net.IPOrDomain{
+					"internal.example.com": net.NewIPOrDomain(dest.Address),
+				},
+			}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			socksInbound(restrictedPort, "loopback", "linklocal"),
+			socksInbound(privatePort, "private"),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{
+					DomainStrategy: freedom.Config_USE_IP,
+				}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	dial := func(port net.Port, address string) (net.Conn, error) {
+		dialer, err := xproxy.SOCKS5("tcp", net.TCPDestination(net.LocalHostIP, port).NetAddr(), nil, xproxy.Direct)
+		common.Must(err)
+		return dialer.Dial("tcp", fmt.Sprintf("%s:%d", address, dest.Port))
+	}
+
+	// a forbidden IP is rejected in the handshake
+	if conn, err := dial(restrictedPort, dest.Address.String()); err == nil {
+		conn.Close()
+		t.Error("connected to a forbidden IP")
+	}
+
+	// a domain resolving to a forbidden IP is rejected by the outbound
+	conn, err := dial(restrictedPort, "internal.example.com")
+	common.Must(err)
+	if err := testTCPConn2(conn, 1024, time.Second*2)(); err == nil {
+		t.Error("connected to a domain resolving to a forbidden IP")
+	}
+	conn.Close()
+
+	// and the targets not forbidden are reached
+	for _, address := range []string{dest.Address.String(), "internal.example.com"} {
+		conn, err := dial(privatePort, address)
+		common.Must(err)
+		if err := testTCPConn2(conn, 1024, time.Second*5)(); err != nil {
+			t.Error(address, ": ", err)
+		}
+		conn.Close()
+	}
+}
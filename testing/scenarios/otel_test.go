@@ -0,0 +1,196 @@
+package scenarios
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/otel"
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/proxy/freedom"
+	v2http "github.com/xtls/xray-core/proxy/http"
+	"github.com/xtls/xray-core/testing/servers/tcp"
+)
+
+type collectedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+}
+
+func (s *collectedSpan) attribute(key string) string {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value.StringValue
+		}
+	}
+	return ""
+}
+
+func TestOTelTrace(t *testing.T) {
+	var access sync.Mutex
+	var spans []*collectedSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []*collectedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if r.URL.Path != "/v1/traces" || json.NewDecoder(r.Body).Decode(&request) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		access.Lock()
+		for _, rs := range request.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+		access.Unlock()
+	}))
+	defer collector.Close()
+
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	serverPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&otel.Config{
+				Endpoint:    collector.URL + "/v1/traces",
+				SampleRatio: 1,
+			}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				Tag: "http-in",
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+					SniffingSettings: &proxyman.SniffingConfig{
+						Enabled:             true,
+						DestinationOverride: []string{"http", "tls"},
+					},
+				}),
+				ProxySettings: serial.ToTypedMessage(&v2http.ServerConfig{
+					Accounts: map[string]string{
+						"alice": "pw",
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				Tag:           "direct",
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	{
+		conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+			IP:   []byte{127, 0, 0, 1},
+			Port: int(serverPort),
+		})
+		common.Must(err)
+
+		target := "127.0.0.1:" + dest.Port.String()
+		auth := base64.StdEncoding.EncodeToString([]byte("alice:pw"))
+		common.Must2(conn.Write([]byte("CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\nProxy-Authorization: Basic " + auth + "\r\n\r\n")))
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		common.Must(err)
+		if resp.StatusCode != 200 {
+			t.Fatal("status: ", resp.StatusCode)
+		}
+
+		payload := []byte("traced payload")
+		common.Must2(conn.Write(payload))
+		response := make([]byte, len(payload))
+		common.Must2(io.ReadFull(reader, response))
+		if r := xor(response); string(r) != string(payload) {
+			t.Error("unexpected response: ", r)
+		}
+		conn.Close()
+	}
+
+	byName := make(map[string]*collectedSpan)
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		access.Lock()
+		for _, s := range spans {
+			byName[s.Name] = s
+		}
+		access.Unlock()
+		if byName["connection"] != nil && byName["copy"] != nil {
+			break
+		}
+	}
+
+	root := byName["connection"]
+	if root == nil {
+		t.Fatal("no connection span among ", len(byName), " spans")
+	}
+	if root.ParentSpanID != "" {
+		t.Error("connection span has a parent: ", root.ParentSpanID)
+	}
+	for attribute, expected := range map[string]string{
+		"inbound.tag":  "http-in",
+		"user":         "alice",
+		"outbound.tag": "direct",
+	} {
+		if v := root.attribute(attribute); v != expected {
+			t.Error("expected ", attribute, " ", expected, ", but got ", v)
+		}
+	}
+	if v := root.attribute("destination"); !strings.Contains(v, dest.Port.String()) {
+		t.Error("unexpected destination: ", v)
+	}
+
+	for child, parent := range map[string]string{
+		"sniffing": "connection",
+		"routing":  "connection",
+		"outbound": "connection",
+		"dial":     "outbound",
+		"copy":     "outbound",
+	} {
+		span := byName[child]
+		if span == nil {
+			t.Error("missing span ", child)
+			continue
+		}
+		if span.TraceID != root.TraceID {
+			t.Error("span ", child, " in another trace")
+		}
+		if p := byName[parent]; p == nil || span.ParentSpanID != p.SpanID {
+			t.Error("span ", child, " is not a child of ", parent)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package scenarios
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"testing"
 	"time"
 
+	"github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/app/dns"
 	"github.com/xtls/xray-core/app/log"
+	"github.com/xtls/xray-core/app/policy"
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
 	clog "github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
@@ -16,16 +22,19 @@ import (
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/common/uuid"
 	core "github.com/xtls/xray-core/core"
+	fstats "github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/proxy/dokodemo"
 	"github.com/xtls/xray-core/proxy/freedom"
 	"github.com/xtls/xray-core/proxy/vless"
 	"github.com/xtls/xray-core/proxy/vless/inbound"
 	"github.com/xtls/xray-core/proxy/vless/outbound"
 	"github.com/xtls/xray-core/testing/servers/tcp"
+	"github.com/xtls/xray-core/testing/servers/udp"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	transtcp "github.com/xtls/xray-core/transport/internet/tcp"
 	"github.com/xtls/xray-core/transport/internet/tls"
+	"golang.org/x/net/dns/dnsmessage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -127,6 +136,241 @@ func TestVless(t *testing.T) {
 	}
 }
 
+func TestVlessMaxLatency(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	const maxDelay = 30 * time.Millisecond
+	// both ends keep the connection buffered, and only flushing by deadline
+	// gets a single byte through before the buffer fills up
+	latencyPolicy := serial.ToTypedMessage(&policy.Config{
+		Level: map[uint32]*policy.Policy{
+			0: {
+				Latency: &policy.Policy_Latency{
+					HighPriority: true,
+					MaxDelay:     uint32(maxDelay / time.Millisecond),
+				},
+			},
+		},
+	})
+
+	userID := protocol.NewID(uuid.New())
+	serverPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&log.Config{
+				ErrorLogLevel: clog.Severity_Debug,
+				ErrorLogType:  log.LogType_Console,
+			}),
+			latencyPolicy,
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&inbound.Config{
+					Clients: []*protocol.User{
+						{
+							Account: serial.ToTypedMessage(&vless.Account{
+								Id: userID.String(),
+							}),
+						},
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	clientPort := tcp.PickPort()
+	clientConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&log.Config{
+				ErrorLogLevel: clog.Severity_Debug,
+				ErrorLogType:  log.LogType_Console,
+			}),
+			latencyPolicy,
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(clientPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+					Address: net.NewIPOrDomain(dest.Address),
+					Port:    uint32(dest.Port),
+					NetworkList: &net.NetworkList{
+						Network: []net.Network{net.Network_TCP},
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&outbound.Config{
+					Vnext: []*protocol.ServerEndpoint{
+						{
+							Address: net.NewIPOrDomain(net.LocalHostIP),
+							Port:    uint32(serverPort),
+							User: []*protocol.User{
+								{
+									Account: serial.ToTypedMessage(&vless.Account{
+										Id: userID.String(),
+									}),
+								},
+							},
+						},
+					},
+				}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig, clientConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(clientPort),
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		common.Must2(conn.Write([]byte{byte(i)}))
+		response, err := readFrom2(conn, time.Second*5, 1)
+		if err != nil {
+			t.Fatal("byte ", i, " not echoed: ", err)
+		}
+		if response[0] != xor([]byte{byte(i)})[0] {
+			t.Fatal("unexpected response: ", response)
+		}
+		// held back at most maxDelay on each way, plus some slack for the scheduler;
+		// the first byte also waits for the connection to the server
+		if rtt := time.Since(start); i > 0 && rtt > 2*maxDelay+50*time.Millisecond {
+			t.Error("byte ", i, " echoed after ", rtt)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestVlessUDPLargePacket(t *testing.T) {
+	// plain UDP, which cone mode would carry over XUDP instead
+	t.Setenv("XRAY_CONE_DISABLED", "true")
+
+	udpServer := udp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := udpServer.Start()
+	common.Must(err)
+	defer udpServer.Close()
+
+	userID := protocol.NewID(uuid.New())
+	serverPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&log.Config{
+				ErrorLogLevel: clog.Severity_Debug,
+				ErrorLogType:  log.LogType_Console,
+			}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&inbound.Config{
+					Clients: []*protocol.User{
+						{
+							Account: serial.ToTypedMessage(&vless.Account{
+								Id: userID.String(),
+							}),
+						},
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	clientConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&stats.Config{}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				Tag: "proxy",
+				ProxySettings: serial.ToTypedMessage(&outbound.Config{
+					Vnext: []*protocol.ServerEndpoint{
+						{
+							Address: net.NewIPOrDomain(net.LocalHostIP),
+							Port:    uint32(serverPort),
+							User: []*protocol.User{
+								{
+									Account: serial.ToTypedMessage(&vless.Account{
+										Id: userID.String(),
+									}),
+								},
+							},
+						},
+					},
+				}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	client, err := core.New(clientConfig)
+	common.Must(err)
+	common.Must(client.Start())
+	defer client.Close()
+
+	conn, err := core.Dial(context.Background(), client, dest)
+	common.Must(err)
+	defer conn.Close()
+
+	// a packet that does not fit in one buffer on the server is dropped without PacketAddress,
+	// and the ones after it still get through
+	large := buf.NewExisted(make([]byte, 9000))
+	common.Must(conn.(buf.Writer).WriteMultiBuffer(buf.MultiBuffer{large}))
+	common.Must2(conn.Write([]byte("small")))
+
+	response, err := readFrom2(conn, time.Second*5, 5)
+	common.Must(err)
+	if string(response) != string(xor([]byte("small"))) {
+		t.Error("unexpected response: ", response)
+	}
+
+	sm := client.GetFeature(fstats.ManagerType()).(fstats.Manager)
+	if c := sm.GetCounter("outbound>>>proxy>>>dropped>>>udp"); c == nil || c.Value() != 1 {
+		t.Error("expect one dropped packet counted, but got ", c)
+	}
+}
+
 func TestVlessTls(t *testing.T) {
 	tcpServer := tcp.Server{
 		MsgProcessor: xor,
@@ -517,3 +761,148 @@ func TestVlessXtlsVisionReality(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestVlessInterceptDNS(t *testing.T) {
+	// the VLESS server, which must not be dialed but for the traffic that is not DNS
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer server.Close()
+	dialed := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := server.Accept()
+			if err != nil {
+				return
+			}
+			dialed <- struct{}{}
+			conn.Close()
+		}
+	}()
+	serverAddr := server.Addr().(*net.TCPAddr)
+
+	clientPort := udp.PickPort()
+	clientConfig := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&log.Config{
+				ErrorLogLevel: clog.Severity_Debug,
+				ErrorLogType:  log.LogType_Console,
+			}),
+			serial.ToTypedMessage(&dns.Config{
+				Hosts: map[string]*net.IPOrDomain{
+					"example.com": net.NewIPOrDomain(net.ParseAddress("192.0.2.1")),
+				},
+			}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(clientPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+					Address: net.NewIPOrDomain(net.ParseAddress("8.8.8.8")),
+					Port:    53,
+					NetworkList: &net.NetworkList{
+						Network: []net.Network{net.Network_UDP},
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&outbound.Config{
+					Vnext: []*protocol.ServerEndpoint{
+						{
+							Address: net.NewIPOrDomain(net.LocalHostIP),
+							Port:    uint32(serverAddr.Port),
+							User: []*protocol.User{
+								{
+									Account: serial.ToTypedMessage(&vless.Account{
+										Id: protocol.NewID(uuid.New()).String(),
+									}),
+								},
+							},
+						},
+					},
+					InterceptDns: "local",
+				}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(clientConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(clientPort),
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	query := func(id uint16, edns0 bool) dnsmessage.Message {
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+		common.Must(builder.StartQuestions())
+		common.Must(builder.Question(dnsmessage.Question{
+			Name:  dnsmessage.MustNewName("example.com."),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}))
+		if edns0 {
+			common.Must(builder.StartAdditionals())
+			var opt dnsmessage.ResourceHeader
+			common.Must(opt.SetEDNS0(4096, dnsmessage.RCodeSuccess, false))
+			common.Must(builder.OPTResource(opt, dnsmessage.OPTResource{}))
+		}
+		b, err := builder.Finish()
+		common.Must(err)
+		common.Must2(conn.Write(b))
+
+		response := make([]byte, 1500)
+		common.Must(conn.SetReadDeadline(time.Now().Add(time.Second * 5)))
+		n, err := conn.Read(response)
+		common.Must(err)
+		var message dnsmessage.Message
+		common.Must(message.Unpack(response[:n]))
+		return message
+	}
+
+	for i, edns0 := range []bool{true, false} {
+		message := query(uint16(i+1), edns0)
+		if message.ID != uint16(i+1) || !message.Response || message.RCode != dnsmessage.RCodeSuccess {
+			t.Fatal("unexpected answer header: ", message.Header)
+		}
+		if len(message.Answers) != 1 || message.Answers[0].Body.(*dnsmessage.AResource).A != [4]byte{192, 0, 2, 1} {
+			t.Error("expect 192.0.2.1, but got ", message.Answers)
+		}
+		var opt *dnsmessage.Resource
+		for j := range message.Additionals {
+			if message.Additionals[j].Header.Type == dnsmessage.TypeOPT {
+				opt = &message.Additionals[j]
+			}
+		}
+		if edns0 != (opt != nil) {
+			t.Error("expect an OPT record ", edns0, ", but got ", message.Additionals)
+		}
+	}
+	select {
+	case <-dialed:
+		t.Fatal("VLESS server dialed for DNS queries")
+	default:
+	}
+
+	// traffic to port 53 that is not DNS is tunneled, from another flow
+	other, err := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(clientPort),
+	})
+	common.Must(err)
+	defer other.Close()
+	common.Must2(other.Write([]byte("not a DNS query")))
+	select {
+	case <-dialed:
+	case <-time.After(time.Second * 5):
+		t.Error("VLESS server not dialed for traffic that is not DNS")
+	}
+}
@@ -1,22 +1,27 @@
 package scenarios
 
 import (
+	"bytes"
+	"io"
 	"testing"
-	//"time"
+	"time"
 
 	"github.com/xtls/xray-core/app/log"
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
 	clog "github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/infra/conf"
 	"github.com/xtls/xray-core/proxy/dokodemo"
 	"github.com/xtls/xray-core/proxy/freedom"
+	"github.com/xtls/xray-core/proxy/socks"
 	"github.com/xtls/xray-core/proxy/wireguard"
 	"github.com/xtls/xray-core/testing/servers/tcp"
 	"github.com/xtls/xray-core/testing/servers/udp"
+	"github.com/xtls/xray-core/transport/internet"
 	//"golang.org/x/sync/errgroup"
 )
 
@@ -121,4 +126,266 @@ func TestWireguard(t *testing.T) {
 	// if err := errg.Wait(); err != nil {
 	// 	t.Error(err)
 	// }
-}
\ No newline at end of file
+}
+func TestWireguardPeersByAllowedIPs(t *testing.T) {
+	xorServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	xorDest, err := xorServer.Start()
+	common.Must(err)
+	defer xorServer.Close()
+
+	upperServer := tcp.Server{
+		MsgProcessor: bytes.ToUpper,
+	}
+	upperDest, err := upperServer.Start()
+	common.Must(err)
+	defer upperServer.Close()
+
+	clientPrivate, _ := conf.ParseWireGuardKey("pkLtinmotjalWKZdBtbsbcrY9Px5P9P/906Wd/3zOV0=")
+	clientPublic, _ := conf.ParseWireGuardKey("j4Wv5RNwJrcw9HkVF8he//uEgwahYIvVNNNmZCA2CFs=")
+	serverAPrivate, _ := conf.ParseWireGuardKey("P2JDzozkcyiYoxNzPHyV8bXiaZvztQ8eDhSkk1LhbuA=")
+	serverAPublic, _ := conf.ParseWireGuardKey("BnlZAYg0Xo+w2HVZwwwOpTN9Jir2CAK0VDXuX99Qy18=")
+	serverBPrivate, _ := conf.ParseWireGuardKey("K0YMG8M+VwIQy4R5aK7ejJuJXR+PEt0zsPUiuMkscSU=")
+	serverBPublic, _ := conf.ParseWireGuardKey("edUewBPwXmV/2AkwhpXiWQFOpj/I7gnwao2xhY5l7wk=")
+
+	// each server sends whatever comes out of the tunnel to its own echo server
+	wireguardServer := func(port net.Port, secretKey string, address string, echo net.Destination) *core.Config {
+		return &core.Config{
+			Inbound: []*core.InboundHandlerConfig{
+				{
+					ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+						PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(port)}},
+						Listen:   net.NewIPOrDomain(net.LocalHostIP),
+					}),
+					ProxySettings: serial.ToTypedMessage(&wireguard.DeviceConfig{
+						Endpoint:  []string{address},
+						Mtu:       1420,
+						SecretKey: secretKey,
+						Peers: []*wireguard.PeerConfig{{
+							PublicKey:  clientPublic,
+							AllowedIps: []string{"10.0.0.2/32"},
+						}},
+					}),
+				},
+			},
+			Outbound: []*core.OutboundHandlerConfig{
+				{
+					ProxySettings: serial.ToTypedMessage(&freedom.Config{
+						DestinationOverride: &freedom.DestinationOverride{
+							Server: &protocol.ServerEndpoint{
+								Address: net.NewIPOrDomain(echo.Address),
+								Port:    uint32(echo.Port),
+							},
+						},
+					}),
+				},
+			},
+		}
+	}
+	serverAPort := udp.PickPort()
+	serverBPort := udp.PickPort()
+
+	tunnelInbound := func(port net.Port, address string) *core.InboundHandlerConfig {
+		return &core.InboundHandlerConfig{
+			ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+				PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(port)}},
+				Listen:   net.NewIPOrDomain(net.LocalHostIP),
+			}),
+			ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+				Address: net.NewIPOrDomain(net.ParseAddress(address)),
+				Port:    80,
+				NetworkList: &net.NetworkList{
+					Network: []net.Network{net.Network_TCP},
+				},
+			}),
+		}
+	}
+	clientAPort := tcp.PickPort()
+	clientBPort := tcp.PickPort()
+	clientNoPeerPort := tcp.PickPort()
+	clientConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			tunnelInbound(clientAPort, "10.1.0.1"),
+			tunnelInbound(clientBPort, "10.2.0.1"),
+			tunnelInbound(clientNoPeerPort, "10.3.0.1"),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&wireguard.DeviceConfig{
+					IsClient:  true,
+					Endpoint:  []string{"10.0.0.2"},
+					Mtu:       1420,
+					SecretKey: clientPrivate,
+					Peers: []*wireguard.PeerConfig{
+						{
+							Endpoint:   "127.0.0.1:" + serverAPort.String(),
+							PublicKey:  serverAPublic,
+							AllowedIps: []string{"10.1.0.0/16"},
+						},
+						{
+							Endpoint:   "127.0.0.1:" + serverBPort.String(),
+							PublicKey:  serverBPublic,
+							AllowedIps: []string{"10.2.0.0/16"},
+						},
+					},
+				}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(
+		wireguardServer(serverAPort, serverAPrivate, "10.1.0.1", xorDest),
+		wireguardServer(serverBPort, serverBPrivate, "10.2.0.1", upperDest),
+		clientConfig,
+	)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	exchange := func(port net.Port, payload []byte) ([]byte, error) {
+		conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+			IP:   []byte{127, 0, 0, 1},
+			Port: int(port),
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		common.Must(conn.SetDeadline(time.Now().Add(10 * time.Second)))
+
+		if _, err := conn.Write(payload); err != nil {
+			return nil, err
+		}
+		response := make([]byte, len(payload))
+		_, err = io.ReadFull(conn, response)
+		return response, err
+	}
+
+	payload := []byte("wireguard")
+	if response, err := exchange(clientAPort, payload); err != nil || !bytes.Equal(response, xor(payload)) {
+		t.Error("10.1.0.1 is not reached through peer A: ", response, err)
+	}
+	if response, err := exchange(clientBPort, payload); err != nil || !bytes.Equal(response, []byte("WIREGUARD")) {
+		t.Error("10.2.0.1 is not reached through peer B: ", response, err)
+	}
+	if response, err := exchange(clientNoPeerPort, payload); err == nil {
+		t.Error("10.3.0.1 is reached without a peer for it: ", response)
+	}
+}
+
+func TestWireguardThroughSocks(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	clientPrivate, _ := conf.ParseWireGuardKey("pkLtinmotjalWKZdBtbsbcrY9Px5P9P/906Wd/3zOV0=")
+	clientPublic, _ := conf.ParseWireGuardKey("j4Wv5RNwJrcw9HkVF8he//uEgwahYIvVNNNmZCA2CFs=")
+	serverPrivate, _ := conf.ParseWireGuardKey("P2JDzozkcyiYoxNzPHyV8bXiaZvztQ8eDhSkk1LhbuA=")
+	serverPublic, _ := conf.ParseWireGuardKey("BnlZAYg0Xo+w2HVZwwwOpTN9Jir2CAK0VDXuX99Qy18=")
+
+	// the wireguard server is only reached through the socks server next to it
+	serverPort := udp.PickPort()
+	socksPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&wireguard.DeviceConfig{
+					Endpoint:  []string{"10.0.0.1"},
+					Mtu:       1420,
+					SecretKey: serverPrivate,
+					Peers: []*wireguard.PeerConfig{{
+						PublicKey:  clientPublic,
+						AllowedIps: []string{"10.0.0.2/32"},
+					}},
+				}),
+			},
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(socksPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&socks.ServerConfig{
+					AuthType:   socks.AuthType_NO_AUTH,
+					Address:    net.NewIPOrDomain(net.LocalHostIP),
+					UdpEnabled: true,
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	clientPort := tcp.PickPort()
+	clientConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(clientPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+					Address: net.NewIPOrDomain(dest.Address),
+					Port:    uint32(dest.Port),
+					NetworkList: &net.NetworkList{
+						Network: []net.Network{net.Network_TCP},
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				SenderSettings: serial.ToTypedMessage(&proxyman.SenderConfig{
+					StreamSettings: &internet.StreamConfig{
+						SocketSettings: &internet.SocketConfig{
+							DialerProxy: "socks",
+						},
+					},
+				}),
+				ProxySettings: serial.ToTypedMessage(&wireguard.DeviceConfig{
+					IsClient:  true,
+					Endpoint:  []string{"10.0.0.2"},
+					Mtu:       1420,
+					SecretKey: clientPrivate,
+					Peers: []*wireguard.PeerConfig{{
+						Endpoint:   "127.0.0.1:" + serverPort.String(),
+						PublicKey:  serverPublic,
+						AllowedIps: []string{"0.0.0.0/0", "::0/0"},
+					}},
+				}),
+			},
+			{
+				Tag: "socks",
+				ProxySettings: serial.ToTypedMessage(&socks.ClientConfig{
+					Server: []*protocol.ServerEndpoint{
+						{
+							Address: net.NewIPOrDomain(net.LocalHostIP),
+							Port:    uint32(socksPort),
+						},
+					},
+				}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig, clientConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	// the connections after the first still go through the socks outbound, as the first
+	// one that brought the device up has ended
+	for i := 0; i < 3; i++ {
+		if err := testTCPConn(clientPort, 1024, time.Second*10)(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package internet
 
 import (
 	"context"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/dice"
@@ -41,6 +42,40 @@ func RegisterTransportDialer(protocol string, dialer dialFunc) error {
 	return nil
 }
 
+var transportPacketDialerCache = make(map[string]dialFunc)
+
+// RegisterTransportPacketDialer registers a Dialer with given name for UDP
+// destinations. Transports without one leave UDP to the system dialer.
+func RegisterTransportPacketDialer(protocol string, dialer dialFunc) error {
+	if _, found := transportPacketDialerCache[protocol]; found {
+		return newError(protocol, " packet dialer already registered").AtError()
+	}
+	transportPacketDialerCache[protocol] = dialer
+	return nil
+}
+
+var transportReleaserCache = make(map[string]func(streamSettings *MemoryStreamConfig))
+
+// RegisterTransportReleaser registers a function releasing what the dialer of the transport keeps
+// for the stream settings of an outbound, once it is closed.
+func RegisterTransportReleaser(protocol string, release func(streamSettings *MemoryStreamConfig)) error {
+	if _, found := transportReleaserCache[protocol]; found {
+		return newError(protocol, " releaser already registered").AtError()
+	}
+	transportReleaserCache[protocol] = release
+	return nil
+}
+
+// Release releases what the transport keeps for streamSettings, which are no longer dialed with.
+func Release(streamSettings *MemoryStreamConfig) {
+	if streamSettings == nil {
+		return
+	}
+	if release := transportReleaserCache[streamSettings.ProtocolName]; release != nil {
+		release(streamSettings)
+	}
+}
+
 // Dial dials a internet connection towards the given destination.
 func Dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStreamConfig) (stat.Connection, error) {
 	if dest.Network == net.Network_TCP {
@@ -61,6 +96,11 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStrea
 	}
 
 	if dest.Network == net.Network_UDP {
+		if streamSettings != nil {
+			if dialer := transportPacketDialerCache[streamSettings.ProtocolName]; dialer != nil {
+				return dialer(ctx, dest, streamSettings)
+			}
+		}
 		udpDialer := transportDialerCache["udp"]
 		if udpDialer == nil {
 			return nil, newError("UDP dialer not registered").AtError()
@@ -102,8 +142,25 @@ func lookupIP(domain string, strategy DomainStrategy, localAddr net.Address) ([]
 	return ips, err
 }
 
+func lookupIPWithServer(tag string, domain string, strategy DomainStrategy, localAddr net.Address) ([]net.IP, error) {
+	lookup, ok := dnsClient.(dns.ServerLookup)
+	if !ok {
+		return nil, newError("DNS client doesn't support looking up with a specific name server")
+	}
+
+	option := dns.IPOption{
+		IPv4Enable: localAddr == nil || localAddr.Family().IsIPv4(),
+		IPv6Enable: localAddr == nil || localAddr.Family().IsIPv6(),
+	}
+	if strategy.hasStrategy() {
+		option.IPv4Enable = option.IPv4Enable && strategy.preferIP4()
+		option.IPv6Enable = option.IPv6Enable && strategy.preferIP6()
+	}
+	return lookup.LookupIPWithServer(tag, domain, option)
+}
+
 func canLookupIP(ctx context.Context, dst net.Destination, sockopt *SocketConfig) bool {
-	if dst.Address.Family().IsIP() || dnsClient == nil {
+	if dst.Address.Family().IsIP() || dnsClient == nil || net.IsVsockAddress(dst.Address.Domain()) {
 		return false
 	}
 	return sockopt.DomainStrategy.hasStrategy()
@@ -113,19 +170,24 @@ func redirect(ctx context.Context, dst net.Destination, obt string) net.Conn {
 	newError("redirecting request " + dst.String() + " to " + obt).WriteToLog(session.ExportIDToError(ctx))
 	h := obm.GetHandler(obt)
 	outbounds := session.OutboundsFromContext(ctx)
-    ctx = session.ContextWithOutbounds(ctx, append(outbounds, &session.Outbound{
-		Target: dst, 
+	ctx = session.ContextWithOutbounds(ctx, append(outbounds, &session.Outbound{
+		Target:  dst,
 		Gateway: nil,
-		Tag: obt,
+		Tag:     obt,
 	})) // add another outbound in session ctx
 	if h != nil {
 		ur, uw := pipe.New(pipe.OptionsFromContext(ctx)...)
 		dr, dw := pipe.New(pipe.OptionsFromContext(ctx)...)
 
 		go h.Dispatch(ctx, &transport.Link{Reader: ur, Writer: dw})
+		output := cnc.ConnectionOutputMulti(dr)
+		if dst.Network == net.Network_UDP {
+			// a read returns a single packet
+			output = cnc.ConnectionOutputMultiUDP(dr)
+		}
 		nc := cnc.NewConnection(
 			cnc.ConnectionInputMulti(uw),
-			cnc.ConnectionOutputMulti(dr),
+			output,
 			cnc.ConnectionOnClose(common.ChainedClosable{uw, dw}),
 		)
 		return nc
@@ -136,13 +198,29 @@ func redirect(ctx context.Context, dst net.Destination, obt string) net.Conn {
 // DialSystem calls system dialer to create a network connection.
 func DialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
 	var src net.Address
+	var resolver string
 	outbounds := session.OutboundsFromContext(ctx)
 	if len(outbounds) > 0 {
-		ob := outbounds[len(outbounds) - 1]
+		ob := outbounds[len(outbounds)-1]
 		src = ob.Gateway
+		resolver = ob.Resolver
+	}
+
+	if len(resolver) > 0 && dest.Address.Family().IsDomain() && !net.IsVsockAddress(dest.Address.Domain()) {
+		// never fall back to other name servers, that would leak the server address
+		ips, err := lookupIPWithServer(resolver, dest.Address.Domain(), sockopt.GetDomainStrategy(), src)
+		if err != nil {
+			return nil, newError("failed to resolve ", dest.Address, " with name server ", resolver).Base(err)
+		}
+		if len(ips) == 0 {
+			return nil, newError("failed to resolve ", dest.Address, " with name server ", resolver).Base(dns.ErrEmptyResponse)
+		}
+		dest.Address = net.IPAddress(ips[dice.Roll(len(ips))])
+		newError("replace destination with " + dest.String()).AtInfo().WriteToLog()
 	}
+
 	if sockopt == nil {
-		return effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
+		return dialSystem(ctx, src, dest, sockopt)
 	}
 
 	if canLookupIP(ctx, dest, sockopt) {
@@ -162,7 +240,25 @@ func DialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig
 		}
 	}
 
-	return effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
+	return dialSystem(ctx, src, dest, sockopt)
+}
+
+// dialSystem dials with the system dialer, records how long TCP connections
+// to IPs take to set up, and applies the idle close of sockopt.
+func dialSystem(ctx context.Context, src net.Address, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
+	start := time.Now()
+	conn, err := effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
+	if err != nil || dest.Network != net.Network_TCP {
+		return conn, err
+	}
+	// with TCP Fast Open, the dial returns before the handshake completes
+	if dest.Address.Family().IsIP() && sockopt.GetTfo() == 0 {
+		recordRTT(dest.Address.IP(), time.Since(start))
+	}
+	if idle := sockopt.GetIdleClose(); idle > 0 {
+		conn = newIdleCloseConn(conn, time.Duration(idle)*time.Minute)
+	}
+	return conn, nil
 }
 
 func InitSystemDialer(dc dns.Client, om outbound.Manager) {
@@ -9,7 +9,6 @@ import (
 	"os"
 	"strings"
 
-	goreality "github.com/xtls/reality"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/transport/internet"
@@ -23,7 +22,7 @@ type Listener struct {
 	addr          *net.UnixAddr
 	ln            net.Listener
 	tlsConfig     *gotls.Config
-	realityConfig *goreality.Config
+	realityConfig *reality.ServerConfig
 	config        *Config
 	addConn       internet.ConnHandler
 	locker        *fileLocker
@@ -62,7 +61,7 @@ func Listen(ctx context.Context, address net.Address, port net.Port, streamSetti
 		ln.tlsConfig = config.GetTLSConfig()
 	}
 	if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
-		ln.realityConfig = config.GetREALITYConfig()
+		ln.realityConfig = config.GetREALITYServerConfig()
 	}
 
 	go ln.run()
@@ -16,15 +16,24 @@ import (
 	"github.com/xtls/xray-core/transport/internet/tls"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
 func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
 	newError("creating connection to ", dest).WriteToLog(session.ExportIDToError(ctx))
 
-	conn, err := dialgRPC(ctx, dest, streamSettings)
+	conn, err := internet.DialWithRetry(ctx, streamSettings.DialRetries, func(ctx context.Context) (stat.Connection, error) {
+		conn, err := dialgRPC(ctx, dest, streamSettings)
+		if status.Code(err) == codes.Unavailable {
+			// the connection was lost, and is being made again
+			return nil, internet.Retryable(err)
+		}
+		return conn, err
+	})
 	if err != nil {
 		return nil, newError("failed to dial gRPC").Base(err)
 	}
@@ -129,7 +138,7 @@ func getGrpcClient(ctx context.Context, dest net.Destination, streamSettings *in
 						config.ServerName = address.Domain()
 					}
 					if fingerprint := tls.GetFingerprint(tlsConfig.Fingerprint); fingerprint != nil {
-						return tls.UClient(c, config, fingerprint), nil
+						return tls.UClient(c, config, fingerprint, tlsConfig.PostQuantum), nil
 					} else { // Fallback to normal gRPC TLS
 						return tls.Client(c, config), nil
 					}
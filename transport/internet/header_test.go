@@ -5,7 +5,9 @@ import (
 
 	"github.com/xtls/xray-core/common"
 	. "github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/headers/dns"
 	"github.com/xtls/xray-core/transport/internet/headers/noop"
+	"github.com/xtls/xray-core/transport/internet/headers/quic"
 	"github.com/xtls/xray-core/transport/internet/headers/srtp"
 	"github.com/xtls/xray-core/transport/internet/headers/utp"
 	"github.com/xtls/xray-core/transport/internet/headers/wechat"
@@ -37,6 +39,14 @@ func TestAllHeadersLoadable(t *testing.T) {
 			Input: new(wireguard.WireguardConfig),
 			Size:  4,
 		},
+		{
+			Input: &dns.Config{Domain: "www.example.com"},
+			Size:  12 + 17 + 4,
+		},
+		{
+			Input: new(quic.Config),
+			Size:  23,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,43 @@
+package dns_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	. "github.com/xtls/xray-core/transport/internet/headers/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSHeader(t *testing.T) {
+	content := []byte{'a', 'b', 'c', 'd', 'e', 'f', 'g'}
+	raw, err := NewDNS(context.Background(), &Config{Domain: "www.example.com"})
+	common.Must(err)
+	header := raw.(DNS)
+
+	payload := buf.New()
+	defer payload.Release()
+	header.Serialize(payload.Extend(header.Size()))
+	payload.Write(content)
+
+	var p dnsmessage.Parser
+	h, err := p.Start(payload.Bytes())
+	common.Must(err)
+	if h.Response || h.OpCode != 0 || !h.RecursionDesired {
+		t.Error("not a standard query: ", h)
+	}
+	q, err := p.Question()
+	common.Must(err)
+	if q.Name.String() != "www.example.com." || q.Type != dnsmessage.TypeA || q.Class != dnsmessage.ClassINET {
+		t.Error("unexpected question: ", q)
+	}
+	if binary.BigEndian.Uint16(payload.BytesTo(2)) != h.ID {
+		t.Error("unexpected ID")
+	}
+	if !bytes.Equal(payload.BytesFrom(header.Size()), content) {
+		t.Error("unexpected payload: ", payload.BytesFrom(header.Size()))
+	}
+}
@@ -0,0 +1,45 @@
+package quic
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/dice"
+)
+
+const connectionIDLen = 8
+
+// VersionNegotiation makes packets look like QUIC Version Negotiation packets, whose supported versions are
+// the payload.
+type VersionNegotiation struct {
+	destinationID [connectionIDLen]byte
+	sourceID      [connectionIDLen]byte
+}
+
+func (*VersionNegotiation) Size() int32 {
+	return 1 + 4 + 1 + connectionIDLen + 1 + connectionIDLen
+}
+
+// Serialize implements PacketHeader.
+func (v *VersionNegotiation) Serialize(b []byte) {
+	b[0] = 0x80 | byte(dice.Roll(0x80)) // long header, the rest is unused
+	b[1], b[2], b[3], b[4] = 0, 0, 0, 0 // version 0 means version negotiation
+	b[5] = connectionIDLen
+	copy(b[6:], v.destinationID[:])
+	b[6+connectionIDLen] = connectionIDLen
+	copy(b[7+connectionIDLen:], v.sourceID[:])
+}
+
+// New returns a new VersionNegotiation header with random connection IDs.
+func New(ctx context.Context, config interface{}) (interface{}, error) {
+	v := &VersionNegotiation{}
+	for i := range v.destinationID {
+		v.destinationID[i] = byte(dice.Roll(256))
+		v.sourceID[i] = byte(dice.Roll(256))
+	}
+	return v, nil
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), New))
+}
@@ -0,0 +1,48 @@
+package quic_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	. "github.com/xtls/xray-core/transport/internet/headers/quic"
+)
+
+func TestVersionNegotiation(t *testing.T) {
+	content := []byte{0xff, 0x00, 0x00, 0x1d, 0x00, 0x00, 0x00, 0x01}
+	raw, err := New(context.Background(), &Config{})
+	common.Must(err)
+	header := raw.(*VersionNegotiation)
+
+	var connectionIDs []byte
+	for i := 0; i < 2; i++ {
+		payload := buf.New()
+		header.Serialize(payload.Extend(header.Size()))
+		payload.Write(content)
+
+		b := payload.Bytes()
+		if b[0]&0x80 == 0 {
+			t.Error("not a long header: ", b[0])
+		}
+		if !bytes.Equal(b[1:5], []byte{0, 0, 0, 0}) {
+			t.Error("unexpected version: ", b[1:5])
+		}
+		dcidLen := int32(b[5])
+		scidLen := int32(b[6+dcidLen])
+		if 7+dcidLen+scidLen != header.Size() {
+			t.Error("connection IDs don't fill the header: ", dcidLen, " ", scidLen)
+		}
+		ids := b[6:header.Size()]
+		if connectionIDs == nil {
+			connectionIDs = append([]byte(nil), ids...)
+		} else if !bytes.Equal(ids, connectionIDs) {
+			t.Error("connection IDs changed between packets")
+		}
+		if !bytes.Equal(payload.BytesFrom(header.Size()), content) {
+			t.Error("unexpected payload: ", payload.BytesFrom(header.Size()))
+		}
+		payload.Release()
+	}
+}
@@ -83,7 +83,7 @@ func getHTTPClient(ctx context.Context, dest net.Destination, streamSettings *in
 
 			var cn tls.Interface
 			if fingerprint := tls.GetFingerprint(tlsConfigs.Fingerprint); fingerprint != nil {
-				cn = tls.UClient(pconn, tlsConfig, fingerprint).(*tls.UConn)
+				cn = tls.UClient(pconn, tlsConfig, fingerprint, tlsConfigs.PostQuantum).(*tls.UConn)
 			} else {
 				cn = tls.Client(pconn, tlsConfig).(*tls.Conn)
 			}
@@ -56,7 +56,7 @@ func dialhttpUpgrade(ctx context.Context, dest net.Destination, streamSettings *
 	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
 		tlsConfig := config.GetTLSConfig(tls.WithDestination(dest), tls.WithNextProto("http/1.1"))
 		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
-			conn = tls.UClient(pconn, tlsConfig, fingerprint)
+			conn = tls.UClient(pconn, tlsConfig, fingerprint, config.PostQuantum)
 			if err := conn.(*tls.UConn).WebsocketHandshakeContext(ctx); err != nil {
 				return nil, err
 			}
@@ -104,9 +104,9 @@ func dialhttpUpgrade(ctx context.Context, dest net.Destination, streamSettings *
 	return connRF, nil
 }
 
-//http.Header.Add() will convert headers to MIME header format.
-//Some people don't like this because they want to send "Web*S*ocket".
-//So we add a simple function to replace that method.
+// http.Header.Add() will convert headers to MIME header format.
+// Some people don't like this because they want to send "Web*S*ocket".
+// So we add a simple function to replace that method.
 func AddHeader(header http.Header, key, value string) {
 	header[key] = append(header[key], value)
 }
@@ -0,0 +1,85 @@
+package internet
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// idleCloseConn closes the connection once no payload has gone through it in
+// either direction for a while. Unlike the policy timeouts, it can't be kept
+// alive by TCP keepalive probes, as those never reach Read or Write.
+//
+// The wrapper hides the underlying *net.TCPConn, so such connections are
+// never spliced.
+type idleCloseConn struct {
+	net.Conn
+	idle time.Duration
+	now  func() time.Time
+
+	lastActive atomic.Int64
+	access     sync.Mutex
+	timer      *time.Timer
+	closed     bool
+}
+
+func newIdleCloseConn(conn net.Conn, idle time.Duration) *idleCloseConn {
+	c := &idleCloseConn{
+		Conn: conn,
+		idle: idle,
+		now:  time.Now,
+	}
+	c.touch()
+	c.timer = time.AfterFunc(idle, c.check)
+	return c
+}
+
+func (c *idleCloseConn) touch() {
+	c.lastActive.Store(c.now().UnixNano())
+}
+
+func (c *idleCloseConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *idleCloseConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+// check closes the connection if it has been idle for long enough, or
+// schedules the next check otherwise.
+func (c *idleCloseConn) check() {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.closed {
+		return
+	}
+	idle := c.now().Sub(time.Unix(0, c.lastActive.Load()))
+	if idle < c.idle {
+		c.timer.Reset(c.idle - idle)
+		return
+	}
+	newError("closing connection to ", c.RemoteAddr(), " idle for ", idle).AtDebug().WriteToLog()
+	c.closed = true
+	c.Conn.Close()
+}
+
+func (c *idleCloseConn) Close() error {
+	c.access.Lock()
+	c.closed = true
+	c.timer.Stop()
+	c.access.Unlock()
+
+	return c.Conn.Close()
+}
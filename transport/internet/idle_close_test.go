@@ -0,0 +1,43 @@
+package internet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+)
+
+func TestIdleCloseConn(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go io.Copy(remote, remote)
+
+	now := time.Unix(1700000000, 0)
+	conn := newIdleCloseConn(local, 10*time.Minute)
+	conn.timer.Stop()
+	conn.now = func() time.Time { return now }
+	conn.touch()
+
+	// traffic in either direction postpones the close
+	now = now.Add(6 * time.Minute)
+	common.Must2(conn.Write([]byte("ping")))
+	common.Must2(io.ReadFull(conn, make([]byte, 4)))
+	now = now.Add(6 * time.Minute)
+	conn.check()
+	conn.timer.Stop()
+	if conn.closed {
+		t.Fatal("connection closed while active")
+	}
+
+	// nothing but the timer running, as with keepalive probes on the socket
+	now = now.Add(10 * time.Minute)
+	conn.check()
+	if !conn.closed {
+		t.Fatal("idle connection not closed")
+	}
+	if _, err := local.Write([]byte("ping")); err == nil {
+		t.Error("underlying connection still writable")
+	}
+}
@@ -2,6 +2,7 @@ package kcp
 
 import (
 	"crypto/cipher"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/transport/internet"
@@ -60,6 +61,13 @@ func (c *Config) GetReadBufferSize() uint32 {
 // GetSecurity returns the security settings.
 func (c *Config) GetSecurity() (cipher.AEAD, error) {
 	if c.Seed != nil {
+		if c.Seed.Rotation > 0 {
+			aead, err := newRotatingAEAD(c.Seed.Seed, time.Duration(c.Seed.Rotation)*time.Second, time.Duration(c.Seed.Grace)*time.Second)
+			if err != nil {
+				return nil, err
+			}
+			return aead, nil
+		}
 		return NewAEADAESGCMBasedOnSeed(c.Seed.Seed), nil
 	}
 	return NewSimpleAuthenticator(), nil
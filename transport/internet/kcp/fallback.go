@@ -0,0 +1,132 @@
+package kcp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/udp"
+)
+
+const defaultFallbackIdleTimeout = time.Minute
+
+// fallbackRelay relays the datagrams a listener can't read as mKCP to another
+// UDP server. Each source gets its own socket towards the server, so that
+// replies find their way back.
+type fallbackRelay struct {
+	sync.Mutex
+	ctx   context.Context
+	dest  net.Destination
+	idle  time.Duration
+	hub   *udp.Hub
+	conns map[net.Destination]*fallbackConn
+}
+
+type fallbackConn struct {
+	net.Conn
+	lastActive atomic.Int64 // unix nano
+}
+
+func (c *fallbackConn) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+func (c *fallbackConn) idleFor() time.Duration {
+	return time.Since(time.Unix(0, c.lastActive.Load()))
+}
+
+func newFallbackRelay(ctx context.Context, config *Fallback, hub *udp.Hub) *fallbackRelay {
+	idle := time.Duration(config.IdleTimeout) * time.Second
+	if idle == 0 {
+		idle = defaultFallbackIdleTimeout
+	}
+	return &fallbackRelay{
+		ctx:   ctx,
+		dest:  net.UDPDestination(config.Address.AsAddress(), net.Port(config.Port)),
+		idle:  idle,
+		hub:   hub,
+		conns: make(map[net.Destination]*fallbackConn),
+	}
+}
+
+func (r *fallbackRelay) relay(payload []byte, src net.Destination) {
+	conn, err := r.getConn(src)
+	if err != nil {
+		newError("failed to relay datagram from ", src, " to fallback ", r.dest).Base(err).WriteToLog()
+		return
+	}
+	conn.touch()
+	if _, err := conn.Write(payload); err != nil {
+		newError("failed to relay datagram from ", src, " to fallback ", r.dest).Base(err).AtDebug().WriteToLog()
+	}
+}
+
+func (r *fallbackRelay) getConn(src net.Destination) (*fallbackConn, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if conn, found := r.conns[src]; found {
+		return conn, nil
+	}
+	rawConn, err := internet.DialSystem(r.ctx, r.dest, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn := &fallbackConn{Conn: rawConn}
+	conn.touch()
+	r.conns[src] = conn
+	go r.relayReplies(conn, src)
+	return conn, nil
+}
+
+// relayReplies sends the replies of the fallback server back to src, until
+// neither has sent anything for the idle timeout.
+func (r *fallbackRelay) relayReplies(conn *fallbackConn, src net.Destination) {
+	defer func() {
+		r.Lock()
+		if r.conns[src] == conn {
+			delete(r.conns, src)
+		}
+		r.Unlock()
+		conn.Close()
+	}()
+
+	payload := make([]byte, buf.Size)
+	for {
+		conn.SetReadDeadline(time.Now().Add(r.idle - conn.idleFor()))
+		n, err := conn.Read(payload)
+		if n > 0 {
+			conn.touch()
+			if _, err := r.hub.WriteTo(payload[:n], src); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() && conn.idleFor() < r.idle {
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (r *fallbackRelay) activeSources() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return len(r.conns)
+}
+
+func (r *fallbackRelay) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+	return nil
+}
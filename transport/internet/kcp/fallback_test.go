@@ -0,0 +1,130 @@
+package kcp_test
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	. "github.com/xtls/xray-core/transport/internet/kcp"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// quicInitial looks like the Initial packet of a QUIC client.
+func quicInitial() []byte {
+	b := make([]byte, 1200)
+	common.Must2(rand.Read(b))
+	copy(b, []byte{0xc3, 0, 0, 0, 1})
+	return b
+}
+
+func TestFallback(t *testing.T) {
+	// the fallback server replies with the address the datagram came from
+	fallback, err := net.ListenUDP("udp", &net.UDPAddr{IP: []byte{127, 0, 0, 1}})
+	common.Must(err)
+	defer fallback.Close()
+	var leaked atomic.Int32
+	go func() {
+		b := make([]byte, 2048)
+		for {
+			n, addr, err := fallback.ReadFromUDP(b)
+			if err != nil {
+				return
+			}
+			if n > 0 && b[0] == 0xc3 {
+				fallback.WriteToUDP([]byte(addr.String()), addr)
+			} else {
+				leaked.Add(1)
+			}
+		}
+	}()
+
+	listener, err := NewListener(context.Background(), net.LocalHostIP, net.Port(0), &internet.MemoryStreamConfig{
+		ProtocolName: "mkcp",
+		ProtocolSettings: &Config{
+			Fallback: &Fallback{
+				Address:     net.NewIPOrDomain(net.LocalHostIP),
+				Port:        uint32(fallback.LocalAddr().(*net.UDPAddr).Port),
+				IdleTimeout: 1,
+			},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			b := make([]byte, 1024)
+			for {
+				n, err := conn.Read(b)
+				if err != nil {
+					return
+				}
+				conn.Write(b[:n])
+			}
+		}()
+	})
+	common.Must(err)
+	defer listener.Close()
+	listenerAddr := listener.Addr().(*net.UDPAddr)
+
+	// relayedFrom sends a QUIC packet from conn, and returns the address the
+	// fallback server saw it coming from
+	relayedFrom := func(conn *net.UDPConn) string {
+		common.Must2(conn.WriteToUDP(quicInitial(), listenerAddr))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		b := make([]byte, 2048)
+		n, addr, err := conn.ReadFromUDP(b)
+		if err != nil {
+			t.Fatal("no reply from the fallback server: ", err)
+		}
+		if addr.Port != listenerAddr.Port {
+			t.Fatal("reply from ", addr, " instead of the listener")
+		}
+		return string(b[:n])
+	}
+
+	h3a, err := net.ListenUDP("udp", &net.UDPAddr{IP: []byte{127, 0, 0, 1}})
+	common.Must(err)
+	defer h3a.Close()
+	h3b, err := net.ListenUDP("udp", &net.UDPAddr{IP: []byte{127, 0, 0, 1}})
+	common.Must(err)
+	defer h3b.Close()
+
+	kcpConn, err := DialKCP(context.Background(), net.UDPDestination(net.LocalHostIP, net.Port(listenerAddr.Port)), &internet.MemoryStreamConfig{
+		ProtocolName:     "mkcp",
+		ProtocolSettings: &Config{},
+	})
+	common.Must(err)
+	defer kcpConn.Close()
+
+	a := relayedFrom(h3a)
+	b := relayedFrom(h3b)
+	if a == b {
+		t.Error("sources share the same relay: ", a)
+	}
+
+	payload := make([]byte, 1000)
+	common.Must2(rand.Read(payload))
+	common.Must2(kcpConn.Write(payload))
+	echo := make([]byte, len(payload))
+	common.Must2(io.ReadFull(kcpConn, echo))
+	if string(echo) != string(payload) {
+		t.Error("mKCP payload corrupted")
+	}
+
+	if again := relayedFrom(h3a); again != a {
+		t.Error("source relayed from ", again, " then ", a)
+	}
+
+	if n := leaked.Load(); n != 0 {
+		t.Error(n, " mKCP packets relayed to the fallback server")
+	}
+
+	time.Sleep(2 * time.Second)
+	if again := relayedFrom(h3a); again == a {
+		t.Error("relay not closed after idle timeout")
+	}
+}
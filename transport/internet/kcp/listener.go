@@ -32,6 +32,7 @@ type Listener struct {
 	header    internet.PacketHeader
 	security  cipher.AEAD
 	addConn   internet.ConnHandler
+	fallback  *fallbackRelay
 }
 
 func NewListener(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (*Listener, error) {
@@ -66,6 +67,9 @@ func NewListener(ctx context.Context, address net.Address, port net.Port, stream
 	}
 	l.Lock()
 	l.hub = hub
+	if kcpSettings.Fallback != nil {
+		l.fallback = newFallbackRelay(ctx, kcpSettings.Fallback, hub)
+	}
 	l.Unlock()
 	newError("listening on ", address, ":", port).WriteToLog()
 
@@ -83,12 +87,16 @@ func (l *Listener) handlePackets() {
 
 func (l *Listener) OnReceive(payload *buf.Buffer, src net.Destination) {
 	segments := l.reader.Read(payload.Bytes())
-	payload.Release()
-
 	if len(segments) == 0 {
-		newError("discarding invalid payload from ", src).WriteToLog()
+		if l.fallback != nil {
+			l.fallback.relay(payload.Bytes(), src)
+		} else {
+			newError("discarding invalid payload from ", src).WriteToLog()
+		}
+		payload.Release()
 		return
 	}
+	payload.Release()
 
 	conv := segments[0].Conversation()
 	cmd := segments[0].Command()
@@ -148,6 +156,9 @@ func (l *Listener) Remove(id ConnectionID) {
 // Close stops listening on the UDP address. Already Accepted connections are not closed.
 func (l *Listener) Close() error {
 	l.hub.Close()
+	if l.fallback != nil {
+		l.fallback.Close()
+	}
 
 	l.Lock()
 	defer l.Unlock()
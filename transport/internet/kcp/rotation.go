@@ -0,0 +1,135 @@
+package kcp
+
+import (
+	"crypto/cipher"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+)
+
+const defaultSeedGrace = time.Minute
+
+// rotatingAEAD seals packets with a seed derived from the configured one and
+// the current time slot, so the obfuscation changes over time without any
+// handshake. Packets of the adjacent slot are accepted around the boundary.
+type rotatingAEAD struct {
+	seed     string
+	rotation time.Duration
+	grace    time.Duration
+	now      func() time.Time
+
+	access  sync.Mutex
+	ciphers map[int64]cipher.AEAD
+
+	// skewed counts the packets sealed with the seed of an adjacent slot
+	// outside of the grace window, i.e. by a peer whose clock is too far off.
+	skewed     atomic.Uint64
+	skewLogged atomic.Int64
+}
+
+func newRotatingAEAD(seed string, rotation, grace time.Duration) (*rotatingAEAD, error) {
+	if rotation <= 0 {
+		return nil, newError("invalid seed rotation: ", rotation)
+	}
+	if grace == 0 {
+		grace = defaultSeedGrace
+		if grace > rotation/4 {
+			grace = rotation / 4
+		}
+	}
+	if grace > rotation/4 {
+		return nil, newError("seed grace ", grace, " is longer than a quarter of the rotation ", rotation)
+	}
+	return &rotatingAEAD{
+		seed:     seed,
+		rotation: rotation,
+		grace:    grace,
+		now:      time.Now,
+		ciphers:  make(map[int64]cipher.AEAD),
+	}, nil
+}
+
+func (a *rotatingAEAD) slotOf(t time.Time) int64 {
+	return t.UnixNano() / int64(a.rotation)
+}
+
+// cipher returns the AEAD of the given slot. The ones of past slots are dropped.
+func (a *rotatingAEAD) cipher(slot int64) cipher.AEAD {
+	a.access.Lock()
+	defer a.access.Unlock()
+
+	if c, found := a.ciphers[slot]; found {
+		return c
+	}
+	c := NewAEADAESGCMBasedOnSeed(a.seed + "/" + strconv.FormatInt(slot, 10))
+	for s := range a.ciphers {
+		if s < slot-2 {
+			delete(a.ciphers, s)
+		}
+	}
+	a.ciphers[slot] = c
+	return c
+}
+
+// NonceSize implements cipher.AEAD.NonceSize().
+func (a *rotatingAEAD) NonceSize() int {
+	return a.cipher(a.slotOf(a.now())).NonceSize()
+}
+
+// Overhead implements cipher.AEAD.Overhead().
+func (a *rotatingAEAD) Overhead() int {
+	return a.cipher(a.slotOf(a.now())).Overhead()
+}
+
+// Seal implements cipher.AEAD.Seal().
+func (a *rotatingAEAD) Seal(dst, nonce, plain, extra []byte) []byte {
+	return a.cipher(a.slotOf(a.now())).Seal(dst, nonce, plain, extra)
+}
+
+// Open implements cipher.AEAD.Open().
+func (a *rotatingAEAD) Open(dst, nonce, cipherText, extra []byte) ([]byte, error) {
+	now := a.now()
+	slot := a.slotOf(now)
+	elapsed := time.Duration(now.UnixNano() - slot*int64(a.rotation))
+
+	// Slots in the order they are tried, the ones out of the grace window last.
+	slots := [3]int64{slot, slot - 1, slot + 1}
+	accepted := 1
+	switch {
+	case elapsed < a.grace:
+		accepted = 2
+	case elapsed >= a.rotation-a.grace:
+		slots[1], slots[2] = slots[2], slots[1]
+		accepted = 2
+	}
+
+	// A failed Open clears its output, which may be cipherText itself.
+	scratch := buf.StackNew()
+	defer scratch.Release()
+	source := cipherText
+	if len(cipherText) <= int(buf.Size) {
+		scratch.Write(cipherText)
+		source = scratch.Bytes()
+	} else {
+		source = append([]byte(nil), cipherText...)
+	}
+
+	for i, s := range slots {
+		out, err := a.cipher(s).Open(dst, nonce, source, extra)
+		if err != nil {
+			continue
+		}
+		if i < accepted {
+			return out, nil
+		}
+		skewed := a.skewed.Add(1)
+		if a.skewLogged.Swap(slot) != slot {
+			newError("rejected an mKCP packet sealed with the seed of slot ", s, " at slot ", slot, ", the clocks differ by more than ", a.grace, " (", skewed, " rejected in total)").AtWarning().WriteToLog()
+		}
+		return nil, newError("seed of slot ", s, " is out of the grace window")
+	}
+	return nil, newError("invalid auth")
+}
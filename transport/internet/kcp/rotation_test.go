@@ -0,0 +1,104 @@
+package kcp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestRotatingAEAD(t *testing.T, clock *fakeClock) *rotatingAEAD {
+	a, err := newRotatingAEAD("test seed", time.Hour, 0)
+	common.Must(err)
+	a.now = clock.Now
+	return a
+}
+
+// transfer sends a segment from the client to the server and reports whether it arrived.
+func transfer(client, server *rotatingAEAD, number uint32) bool {
+	var wire bytes.Buffer
+	writer := &KCPPacketWriter{Security: client, Writer: &wire}
+	seg := NewDataSegment()
+	seg.Conv = 1
+	seg.Number = number
+	seg.Data().WriteString("payload")
+	b := make([]byte, seg.ByteSize())
+	seg.Serialize(b)
+	seg.Release()
+	common.Must2(writer.Write(b))
+
+	reader := &KCPPacketReader{Security: server}
+	segs := reader.Read(wire.Bytes())
+	if len(segs) != 1 {
+		return false
+	}
+	return segs[0].(*DataSegment).Number == number
+}
+
+func TestRotatingSeedAcrossSlots(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 50, 0, 0, time.UTC)
+	clientClock := &fakeClock{now: start.Add(-30 * time.Second)}
+	serverClock := &fakeClock{now: start}
+	client := newTestRotatingAEAD(t, clientClock)
+	server := newTestRotatingAEAD(t, serverClock)
+
+	for i := uint32(0); i < 20*60; i++ {
+		if !transfer(client, server, i) {
+			t.Fatal("segment ", i, " lost at server time ", serverClock.now)
+		}
+		if !transfer(server, client, i) {
+			t.Fatal("segment ", i, " lost at client time ", clientClock.now)
+		}
+		clientClock.now = clientClock.now.Add(time.Second)
+		serverClock.now = serverClock.now.Add(time.Second)
+	}
+	if client.slotOf(clientClock.now) == client.slotOf(start) {
+		t.Fatal("expect to cross a slot boundary")
+	}
+	if n := server.skewed.Load(); n != 0 {
+		t.Error("expect no skewed packets, but got ", n)
+	}
+}
+
+func TestRotatingSeedRejectsSkew(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC)
+	client := newTestRotatingAEAD(t, &fakeClock{now: start.Add(-10 * time.Minute)})
+	server := newTestRotatingAEAD(t, &fakeClock{now: start})
+
+	if transfer(client, server, 1) {
+		t.Fatal("expect packets of the previous slot to be rejected out of the grace window")
+	}
+	if n := server.skewed.Load(); n != 1 {
+		t.Error("expect 1 skewed packet, but got ", n)
+	}
+
+	stranger, err := newRotatingAEAD("another seed", time.Hour, 0)
+	common.Must(err)
+	stranger.now = client.now
+	if transfer(stranger, server, 2) {
+		t.Fatal("expect packets of another seed to be rejected")
+	}
+	if n := server.skewed.Load(); n != 1 {
+		t.Error("expect packets of another seed not to be counted as skewed, but got ", n)
+	}
+}
+
+func TestRotatingSeedGrace(t *testing.T) {
+	if _, err := newRotatingAEAD("seed", time.Minute, 30*time.Second); err == nil {
+		t.Error("expect error for a grace longer than a quarter of the rotation")
+	}
+	a, err := newRotatingAEAD("seed", time.Minute, 0)
+	common.Must(err)
+	if a.grace != 15*time.Second {
+		t.Error("expect default grace to be capped at 15s, but got ", a.grace)
+	}
+}
@@ -0,0 +1,58 @@
+package masque
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+const defaultURITemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+
+// GetProxyDestination returns the destination of the MASQUE proxy.
+func (c *Config) GetProxyDestination() net.Destination {
+	port := c.Port
+	if port == 0 {
+		port = 443
+	}
+	return net.UDPDestination(net.ParseAddress(c.Address), net.Port(port))
+}
+
+// GetURL expands the URI template for the given target.
+func (c *Config) GetURL(target net.Destination) (*url.URL, error) {
+	template := c.UriTemplate
+	if template == "" {
+		template = defaultURITemplate
+	}
+	// IPv6 addresses go without brackets, and their colons are not allowed unescaped
+	// in a path segment, RFC 9298 section 2
+	host := target.Address.String()
+	if target.Address.Family().IsIP() {
+		host = target.Address.IP().String()
+	}
+	host = strings.ReplaceAll(host, ":", "%3A")
+	template = strings.NewReplacer(
+		"{target_host}", host,
+		"{target_port}", target.Port.String(),
+	).Replace(template)
+
+	if strings.HasPrefix(template, "/") {
+		template = "https://" + c.GetProxyDestination().NetAddr() + template
+	}
+	u, err := url.Parse(template)
+	if err != nil {
+		return nil, newError("invalid URI template: ", c.UriTemplate).Base(err)
+	}
+	if u.Scheme != "https" {
+		return nil, newError("MASQUE proxy must be reached over https, got ", u.Scheme)
+	}
+	return u, nil
+}
+
+func init() {
+	common.Must(internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
+		return new(Config)
+	}))
+}
@@ -0,0 +1,222 @@
+package masque
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal/done"
+)
+
+// datagramCapsule carries an HTTP datagram on the request stream, RFC 9297 section 3.5.
+const datagramCapsule http3.CapsuleType = 0x00
+
+// contextIDUDP is the context ID of UDP payloads, RFC 9298 section 4.
+const contextIDUDP = 0
+
+// maxCapsuleLength is the largest DATAGRAM capsule read, one with a UDP payload of the largest size and
+// its context ID. Larger ones are skipped.
+const maxCapsuleLength = 65535 + 8
+
+// conn is a CONNECT-UDP tunnel. Each Read and Write carries one UDP payload.
+// Payloads go in QUIC datagrams when they fit, otherwise in DATAGRAM capsules
+// on the request stream.
+type conn struct {
+	str    http3.Stream
+	local  net.Addr
+	remote net.Addr
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      *done.Instance
+	closeOnce sync.Once
+	incoming  chan []byte
+
+	writeAccess sync.Mutex
+	// largest HTTP datagram payload fitting into a QUIC datagram, 0 until
+	// the QUIC connection has refused one
+	maxDatagramPayload atomic.Int64
+	readDeadline       atomic.Value
+}
+
+func newConn(str http3.Stream, local, remote net.Addr) *conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &conn{
+		str:      str,
+		local:    local,
+		remote:   remote,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     done.New(),
+		incoming: make(chan []byte, 64),
+	}
+	c.readDeadline.Store(time.Time{})
+	go c.receiveDatagrams()
+	go c.receiveCapsules()
+	return c
+}
+
+func (c *conn) deliver(payload []byte) {
+	id, n, err := quicvarint.Parse(payload)
+	if err != nil || id != contextIDUDP {
+		// unknown contexts are dropped, RFC 9298 section 4
+		return
+	}
+	select {
+	case c.incoming <- payload[n:]:
+	case <-c.done.Wait():
+	default:
+		// like UDP, drop when nobody keeps up reading
+	}
+}
+
+func (c *conn) receiveDatagrams() {
+	for {
+		b, err := c.str.ReceiveDatagram(c.ctx)
+		if err != nil {
+			return
+		}
+		c.deliver(b)
+	}
+}
+
+func (c *conn) receiveCapsules() {
+	defer c.Close()
+
+	r := bufio.NewReader(c.str)
+	for {
+		typ, payload, err := readCapsule(r)
+		if err != nil {
+			if err != io.EOF && !c.done.Done() {
+				newError("failed to parse capsule").Base(err).AtDebug().WriteToLog()
+			}
+			return
+		}
+		// unknown capsules are skipped, RFC 9297 section 3.2
+		if typ == datagramCapsule {
+			c.deliver(payload)
+		}
+	}
+}
+
+// readCapsule reads a whole capsule, or skips its value if it is not a DATAGRAM capsule. The value reader of
+// http3.ParseCapsule fails on reads returning less than what is left, which the stream does.
+func readCapsule(r *bufio.Reader) (http3.CapsuleType, []byte, error) {
+	typ, err := quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	if http3.CapsuleType(typ) != datagramCapsule || length > maxCapsuleLength {
+		if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return http3.CapsuleType(typ), nil, nil
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return datagramCapsule, payload, nil
+}
+
+// MTU returns the largest UDP payload sent as a QUIC datagram, or 0 if the
+// limit is not known yet. Larger payloads are still delivered, on the stream.
+func (c *conn) MTU() int {
+	if max := c.maxDatagramPayload.Load(); max > 0 {
+		return int(max) - quicvarint.Len(contextIDUDP)
+	}
+	return 0
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	var timeout <-chan time.Time
+	if deadline := c.readDeadline.Load().(time.Time); !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case payload := <-c.incoming:
+		return copy(b, payload), nil
+	case <-c.done.Wait():
+		return 0, io.EOF
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if c.done.Done() {
+		return 0, io.ErrClosedPipe
+	}
+	payload := make([]byte, 0, quicvarint.Len(contextIDUDP)+len(b))
+	payload = quicvarint.Append(payload, contextIDUDP)
+	payload = append(payload, b...)
+
+	if max := c.maxDatagramPayload.Load(); max == 0 || int64(len(payload)) <= max {
+		err := c.str.SendDatagram(payload)
+		if err == nil {
+			return len(b), nil
+		}
+		var tooLarge *quic.DatagramTooLargeError
+		if !errors.As(err, &tooLarge) {
+			return 0, err
+		}
+		// the quarter stream ID takes room in the QUIC datagram too
+		overhead := int64(quicvarint.Len(uint64(c.str.StreamID() / 4)))
+		c.maxDatagramPayload.Store(tooLarge.MaxDatagramPayloadSize - overhead)
+	}
+
+	c.writeAccess.Lock()
+	defer c.writeAccess.Unlock()
+	if err := http3.WriteCapsule(quicvarint.NewWriter(c.str), datagramCapsule, payload); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.done.Close()
+		c.cancel()
+		c.str.CancelRead(quic.StreamErrorCode(http3.ErrCodeNoError))
+		err = c.str.Close()
+	})
+	return err
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(t)
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	return c.str.SetWriteDeadline(t)
+}
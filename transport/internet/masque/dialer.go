@@ -0,0 +1,305 @@
+package masque
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"google.golang.org/protobuf/proto"
+)
+
+// connectUDP is the :protocol of CONNECT-UDP requests.
+const connectUDP = "connect-udp"
+
+type proxyConnection struct {
+	rawConn net.PacketConn
+	conn    quic.Connection
+	client  *http3.SingleDestinationRoundTripper
+	h3conn  http3.Connection
+}
+
+func (c *proxyConnection) isActive() bool {
+	select {
+	case <-c.conn.Context().Done():
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *proxyConnection) close() {
+	c.conn.CloseWithError(0, "")
+	c.rawConn.Close()
+}
+
+// proxyKey identifies the connections that may be shared, those to the same
+// proxy with the same stream settings, whichever outbound they are of.
+type proxyKey struct {
+	dest     net.Destination
+	protocol string
+	security string
+	socket   string
+}
+
+func newProxyKey(dest net.Destination, streamSettings *internet.MemoryStreamConfig) proxyKey {
+	return proxyKey{
+		dest:     dest,
+		protocol: marshalSettings(streamSettings.ProtocolSettings),
+		security: marshalSettings(streamSettings.SecuritySettings),
+		socket:   marshalSettings(streamSettings.SocketSettings),
+	}
+}
+
+func marshalSettings(settings interface{}) string {
+	m, ok := settings.(proto.Message)
+	if !ok {
+		return ""
+	}
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	return string(b)
+}
+
+// proxyDial is a dial in progress, which others wanting the same connection wait for.
+type proxyDial struct {
+	done chan struct{}
+	conn *proxyConnection
+	err  error
+}
+
+type proxyConnections struct {
+	access  sync.Mutex
+	conns   map[proxyKey]*proxyConnection
+	dialing map[proxyKey]*proxyDial
+	// users are the stream settings of the outbounds dialing through the connections
+	users map[*internet.MemoryStreamConfig]proxyKey
+}
+
+var connections = proxyConnections{
+	conns:   make(map[proxyKey]*proxyConnection),
+	dialing: make(map[proxyKey]*proxyDial),
+	users:   make(map[*internet.MemoryStreamConfig]proxyKey),
+}
+
+// get returns a connection to the proxy with HTTP datagrams and extended
+// CONNECT negotiated, dialing a new one if there is no usable one. Only one
+// dial runs for each key, and it runs without holding the lock.
+func (p *proxyConnections) get(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig, tlsConfig *tls.Config) (*proxyConnection, error) {
+	key := newProxyKey(dest, streamSettings)
+
+	p.access.Lock()
+	p.users[streamSettings] = key
+	if c, found := p.conns[key]; found {
+		if c.isActive() {
+			p.access.Unlock()
+			return c, nil
+		}
+		c.close()
+		delete(p.conns, key)
+	}
+	if d, found := p.dialing[key]; found {
+		p.access.Unlock()
+		select {
+		case <-d.done:
+			return d.conn, d.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	d := &proxyDial{done: make(chan struct{})}
+	p.dialing[key] = d
+	p.access.Unlock()
+
+	// the dial is shared with the callers waiting for it, so the one starting
+	// it giving up must not cancel it, only its deadline is kept
+	dialCtx := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithDeadline(dialCtx, deadline)
+		defer cancel()
+	}
+	d.conn, d.err = dialProxy(dialCtx, dest, tlsConfig, streamSettings.SocketSettings)
+
+	p.access.Lock()
+	delete(p.dialing, key)
+	if d.err == nil && p.used(key) {
+		p.conns[key] = d.conn
+	}
+	p.access.Unlock()
+	close(d.done)
+	return d.conn, d.err
+}
+
+// used tells whether an outbound still dials through the connection of key.
+func (p *proxyConnections) used(key proxyKey) bool {
+	for _, k := range p.users {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// release closes the connection the outbound with streamSettings dialed
+// through, unless other outbounds still do.
+func (p *proxyConnections) release(streamSettings *internet.MemoryStreamConfig) {
+	p.access.Lock()
+	defer p.access.Unlock()
+
+	key, found := p.users[streamSettings]
+	if !found {
+		return
+	}
+	delete(p.users, streamSettings)
+	if p.used(key) {
+		return
+	}
+	if c, found := p.conns[key]; found {
+		c.close()
+		delete(p.conns, key)
+	}
+}
+
+func dialProxy(ctx context.Context, dest net.Destination, tlsConfig *tls.Config, sockopt *internet.SocketConfig) (*proxyConnection, error) {
+	newError("dialing MASQUE proxy ", dest).WriteToLog(session.ExportIDToError(ctx))
+
+	// the system dialer resolves the proxy with the DNS and socket settings
+	rawConn, err := internet.DialSystem(ctx, dest, sockopt)
+	if err != nil {
+		return nil, newError("failed to dial to proxy").Base(err)
+	}
+	var udpConn *net.UDPConn
+	switch conn := rawConn.(type) {
+	case *net.UDPConn:
+		udpConn = conn
+	case *internet.PacketConnWrapper:
+		udpConn = conn.Conn.(*net.UDPConn)
+	default:
+		rawConn.Close()
+		return nil, newError("MASQUE with sockopt is unsupported").AtWarning()
+	}
+	destAddr, ok := rawConn.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		rawConn.Close()
+		return nil, newError("unexpected proxy address ", rawConn.RemoteAddr())
+	}
+
+	tr := &quic.Transport{
+		Conn: udpConn,
+	}
+	quicConfig := &quic.Config{
+		EnableDatagrams:      true,
+		HandshakeIdleTimeout: time.Second * 8,
+		MaxIdleTimeout:       time.Second * 300,
+		KeepAlivePeriod:      time.Second * 15,
+	}
+	conn, err := tr.Dial(ctx, destAddr, tlsConfig.GetTLSConfig(tls.WithDestination(dest), tls.WithNextProto(http3.NextProtoH3)), quicConfig)
+	if err != nil {
+		udpConn.Close()
+		return nil, newError("failed to dial QUIC to proxy").Base(err)
+	}
+
+	client := &http3.SingleDestinationRoundTripper{
+		Connection:      conn,
+		EnableDatagrams: true,
+	}
+	c := &proxyConnection{
+		rawConn: udpConn,
+		conn:    conn,
+		client:  client,
+		h3conn:  client.Start(),
+	}
+
+	// CONNECT-UDP can only be sent once the SETTINGS of the proxy are known
+	select {
+	case <-c.h3conn.ReceivedSettings():
+	case <-ctx.Done():
+		c.close()
+		return nil, ctx.Err()
+	case <-conn.Context().Done():
+		c.close()
+		return nil, newError("proxy closed the connection before sending SETTINGS")
+	}
+	settings := c.h3conn.Settings()
+	if !settings.EnableExtendedConnect || !settings.EnableDatagrams {
+		c.close()
+		return nil, newError("proxy does not support extended CONNECT with HTTP datagrams")
+	}
+	return c, nil
+}
+
+// Dial opens a CONNECT-UDP tunnel to dest through the configured proxy.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	if dest.Network != net.Network_UDP {
+		return nil, newError("MASQUE only relays UDP, not ", dest.Network)
+	}
+	config := streamSettings.ProtocolSettings.(*Config)
+	if config.Address == "" {
+		return nil, newError("MASQUE proxy address is not set")
+	}
+	proxy := config.GetProxyDestination()
+	u, err := config.GetURL(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := tls.ConfigFromStreamSettings(streamSettings)
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	c, err := connections.get(ctx, proxy, streamSettings, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	str, err := c.client.OpenRequestStream(ctx)
+	if err != nil {
+		return nil, newError("failed to open request stream").Base(err)
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		Proto:  connectUDP,
+		Host:   u.Host,
+		URL:    u,
+		Header: http.Header{
+			"Capsule-Protocol": []string{"?1"},
+		},
+	}
+	if err := str.SendRequestHeader(req); err != nil {
+		str.CancelRead(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		str.Close()
+		return nil, newError("failed to send CONNECT-UDP request").Base(err)
+	}
+	resp, err := str.ReadResponse()
+	if err != nil {
+		str.CancelRead(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		str.Close()
+		return nil, newError("failed to read CONNECT-UDP response").Base(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		str.CancelRead(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		str.Close()
+		return nil, newError("proxy refused CONNECT-UDP to ", dest, ": ", resp.Status)
+	}
+	newError("CONNECT-UDP to ", dest, " through ", proxy).WriteToLog(session.ExportIDToError(ctx))
+
+	var remote net.Addr = &net.UDPAddr{Port: int(dest.Port)}
+	if dest.Address.Family().IsIP() {
+		remote = &net.UDPAddr{IP: dest.Address.IP(), Port: int(dest.Port)}
+	}
+	return newConn(str, c.conn.LocalAddr(), remote), nil
+}
+
+func init() {
+	common.Must(internet.RegisterTransportPacketDialer(protocolName, Dial))
+	common.Must(internet.RegisterTransportReleaser(protocolName, connections.release))
+}
@@ -0,0 +1,21 @@
+package masque
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common"
+)
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+// MASQUE relays UDP through an HTTP/3 proxy with CONNECT-UDP (RFC 9298).
+// Only the client side is implemented; it is a packet transport, so it only
+// carries UDP destinations.
+
+const protocolName = "masque"
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return nil, newError("masque is a transport protocol.")
+	}))
+}
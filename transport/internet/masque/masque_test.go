@@ -0,0 +1,305 @@
+package masque_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
+	"github.com/xtls/xray-core/testing/servers/udp"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/masque"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+// masqueServer is a minimal CONNECT-UDP proxy.
+type masqueServer struct {
+	t *testing.T
+}
+
+func (s *masqueServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /.well-known/masque/udp/{target_host}/{target_port}/
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodConnect || r.Proto != "connect-udp" || len(parts) != 5 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Capsule-Protocol") != "?1" {
+		s.t.Error("missing Capsule-Protocol header")
+	}
+	port, err := net.PortFromString(parts[4])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target, err := net.ResolveUDPAddr("udp", net.UDPDestination(net.ParseAddress(parts[3]), port).NetAddr())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	udpConn, err := net.DialUDP("udp", nil, target)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer udpConn.Close()
+
+	w.WriteHeader(http.StatusOK)
+	str := w.(http3.HTTPStreamer).HTTPStream()
+	defer str.Close()
+
+	var writeAccess sync.Mutex
+	send := func(b []byte) error {
+		payload := append([]byte{0}, b...)
+		err := str.SendDatagram(payload)
+		var tooLarge *quic.DatagramTooLargeError
+		if errors.As(err, &tooLarge) {
+			writeAccess.Lock()
+			defer writeAccess.Unlock()
+			return http3.WriteCapsule(quicvarint.NewWriter(str), 0, payload)
+		}
+		return err
+	}
+	go func() {
+		b := make([]byte, 2048)
+		for {
+			n, err := udpConn.Read(b)
+			if err != nil {
+				return
+			}
+			if err := send(b[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		r := bufio.NewReader(str)
+		for {
+			// the value reader of http3.ParseCapsule fails on short reads
+			typ, err := quicvarint.Read(r)
+			if err != nil {
+				return
+			}
+			length, err := quicvarint.Read(r)
+			if err != nil {
+				return
+			}
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if typ == 0 && len(payload) > 0 && payload[0] == 0 {
+				udpConn.Write(payload[1:])
+			}
+		}
+	}()
+	for {
+		payload, err := str.ReceiveDatagram(r.Context())
+		if err != nil {
+			return
+		}
+		if len(payload) > 0 && payload[0] == 0 {
+			udpConn.Write(payload[1:])
+		}
+	}
+}
+
+func startMasqueServer(t *testing.T) net.Port {
+	port := udp.PickPort()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.LocalHostIP.IP(), Port: int(port)})
+	common.Must(err)
+
+	tlsConfig := (&tls.Config{
+		Certificate: []*tls.Certificate{
+			tls.ParseCertificate(cert.MustGenerate(nil, cert.DNSNames("www.example.com"))),
+		},
+	}).GetTLSConfig()
+	tlsConfig.SessionTicketsDisabled = true
+	server := &http3.Server{
+		Handler:         &masqueServer{t: t},
+		TLSConfig:       http3.ConfigureTLSConfig(tlsConfig),
+		EnableDatagrams: true,
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+	})
+	return port
+}
+
+func xor(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[i] = v ^ 'c'
+	}
+	return r
+}
+
+func TestMasqueRoundTrip(t *testing.T) {
+	udpServer := udp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := udpServer.Start()
+	common.Must(err)
+	defer udpServer.Close()
+
+	proxyPort := startMasqueServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	conn, err := masque.Dial(ctx, dest, &internet.MemoryStreamConfig{
+		ProtocolName: "masque",
+		ProtocolSettings: &masque.Config{
+			Address: "127.0.0.1",
+			Port:    uint32(proxyPort),
+		},
+		SecurityType: "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	// the large payload does not fit into a QUIC datagram and goes in a capsule
+	for _, size := range []int{1, 512, 1800} {
+		payload := make([]byte, size)
+		common.Must2(rand.Read(payload))
+		common.Must2(conn.Write(payload))
+
+		common.Must(conn.SetReadDeadline(time.Now().Add(time.Second * 5)))
+		response := make([]byte, 2048)
+		n, err := conn.Read(response)
+		if err != nil {
+			t.Fatal("size ", size, ": ", err)
+		}
+		if r := cmp.Diff(response[:n], xor(payload)); r != "" {
+			t.Error("size ", size, ": ", r)
+		}
+	}
+}
+
+func TestMasqueRefused(t *testing.T) {
+	proxyPort := startMasqueServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	_, err := masque.Dial(ctx, net.UDPDestination(net.LocalHostIP, 53), &internet.MemoryStreamConfig{
+		ProtocolName: "masque",
+		ProtocolSettings: &masque.Config{
+			Address:     "127.0.0.1",
+			Port:        uint32(proxyPort),
+			UriTemplate: "/udp?h={target_host}&p={target_port}",
+		},
+		SecurityType: "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	if err == nil {
+		t.Error("expected CONNECT-UDP to be refused")
+	}
+}
+
+func masqueStreamSettings(proxyPort net.Port) *internet.MemoryStreamConfig {
+	return &internet.MemoryStreamConfig{
+		ProtocolName: "masque",
+		ProtocolSettings: &masque.Config{
+			Address: "127.0.0.1",
+			Port:    uint32(proxyPort),
+		},
+		SecurityType: "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	}
+}
+
+func TestMasqueRelease(t *testing.T) {
+	proxyPort := startMasqueServer(t)
+	dest := net.UDPDestination(net.LocalHostIP, 53)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// outbounds with the same settings share the connection to the proxy
+	settings1, settings2 := masqueStreamSettings(proxyPort), masqueStreamSettings(proxyPort)
+	conn1, err := masque.Dial(ctx, dest, settings1)
+	common.Must(err)
+	defer conn1.Close()
+	conn2, err := masque.Dial(ctx, dest, settings2)
+	common.Must(err)
+	defer conn2.Close()
+	if conn1.LocalAddr().String() != conn2.LocalAddr().String() {
+		t.Error("expect the connection to the proxy shared, but got ", conn1.LocalAddr(), " and ", conn2.LocalAddr())
+	}
+
+	// it is closed once none of them is left
+	internet.Release(settings1)
+	conn3, err := masque.Dial(ctx, dest, settings2)
+	common.Must(err)
+	defer conn3.Close()
+	if conn3.LocalAddr().String() != conn2.LocalAddr().String() {
+		t.Error("connection closed while still used")
+	}
+	internet.Release(settings2)
+	common.Must(conn2.SetReadDeadline(time.Now().Add(time.Second * 5)))
+	if _, err := conn2.Read(make([]byte, 2048)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("expect the connection closed once released, but got ", err)
+	}
+}
+
+func TestMasqueDialCancelled(t *testing.T) {
+	proxyPort := startMasqueServer(t)
+	dest := net.UDPDestination(net.LocalHostIP, 53)
+
+	// the dial to the proxy is shared, so the caller starting it giving up
+	// doesn't fail the others
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if conn, err := masque.Dial(cancelled, dest, masqueStreamSettings(proxyPort)); err == nil {
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	conn, err := masque.Dial(ctx, dest, masqueStreamSettings(proxyPort))
+	if err != nil {
+		t.Fatal("expect the dial to succeed, but got ", err)
+	}
+	conn.Close()
+	<-done
+}
+
+func TestConfigURL(t *testing.T) {
+	config := &masque.Config{
+		Address: "proxy.example.com",
+	}
+	u, err := config.GetURL(net.UDPDestination(net.ParseAddress("2001:db8::1"), 443))
+	common.Must(err)
+	if r := cmp.Diff(u.String(), "https://proxy.example.com:443/.well-known/masque/udp/2001%3Adb8%3A%3A1/443/"); r != "" {
+		t.Error(r)
+	}
+}
@@ -7,6 +7,7 @@ type MemoryStreamConfig struct {
 	SecurityType     string
 	SecuritySettings interface{}
 	SocketSettings   *SocketConfig
+	DialRetries      uint32
 }
 
 // ToMemoryStreamConfig converts a StreamConfig to MemoryStreamConfig. It returns a default non-nil MemoryStreamConfig for nil input.
@@ -23,6 +24,7 @@ func ToMemoryStreamConfig(s *StreamConfig) (*MemoryStreamConfig, error) {
 
 	if s != nil {
 		mss.SocketSettings = s.SocketSettings
+		mss.DialRetries = s.DialRetries
 	}
 
 	if s != nil && s.HasSecuritySettings() {
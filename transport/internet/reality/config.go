@@ -1,6 +1,7 @@
 package reality
 
 import (
+	"context"
 	"io"
 	"net"
 	"os"
@@ -41,6 +42,37 @@ func (c *Config) GetREALITYConfig() *reality.Config {
 	return config
 }
 
+// ServerConfig is the REALITY config of a listener, together with the
+// handshake timing of its dest if it is mimicked.
+type ServerConfig struct {
+	*reality.Config
+	timing *handshakeTiming
+}
+
+// GetREALITYServerConfig returns the config for Server. The handshake timing
+// is measured and applied across the connections sharing the returned config.
+func (c *Config) GetREALITYServerConfig() *ServerConfig {
+	config := &ServerConfig{Config: c.GetREALITYConfig()}
+	if c.MimicTiming {
+		maxDelay := time.Duration(c.MaxTimingDelay) * time.Millisecond
+		if maxDelay == 0 {
+			maxDelay = defaultMaxTimingDelay
+		}
+		timing := newHandshakeTiming(maxDelay)
+		dialContext := config.DialContext
+		config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			client, _ := ctx.Value(timedConnKey{}).(*timedConn)
+			return &measuredConn{Conn: conn, timing: timing, client: client}, nil
+		}
+		config.timing = timing
+	}
+	return config
+}
+
 func KeyLogWriterFromConfig(c *Config) io.Writer {
 	if len(c.MasterKeyLog) <= 0 || c.MasterKeyLog == "none" {
 		return nil
@@ -57,8 +57,24 @@ func (c *Conn) HandshakeAddress() net.Address {
 	return net.ParseAddress(state.ServerName)
 }
 
-func Server(c net.Conn, config *reality.Config) (net.Conn, error) {
-	realityConn, err := reality.Server(context.Background(), c, config)
+// NetConn returns the underlying connection, without the handshake timing wrapper.
+func (c *Conn) NetConn() net.Conn {
+	conn := c.Conn.NetConn()
+	if timed, ok := conn.(*timedConn); ok {
+		return timed.Conn
+	}
+	return conn
+}
+
+func Server(c net.Conn, config *ServerConfig) (net.Conn, error) {
+	ctx := context.Background()
+	if config.timing != nil {
+		timed := &timedConn{Conn: c, timing: config.timing}
+		// the connection to dest finds the client connection in ctx
+		ctx = context.WithValue(ctx, timedConnKey{}, timed)
+		c = timed
+	}
+	realityConn, err := reality.Server(ctx, c, config.Config)
 	return &Conn{Conn: realityConn}, err
 }
 
@@ -0,0 +1,209 @@
+package reality
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+const (
+	// timingRefreshInterval is how long the latency statistics of dest are
+	// used before they are recomputed from the latest measurements.
+	timingRefreshInterval = time.Hour
+	// timingMinSamples is the number of measurements the statistics are
+	// recomputed from on every handshake until it is reached.
+	timingMinSamples = 8
+	timingMaxSamples = 256
+
+	defaultMaxTimingDelay = time.Millisecond * 500
+
+	// forwardedPrefixSize is how much of the first answer of dest is kept to
+	// recognize it being forwarded to the client, which covers the random of
+	// a ServerHello.
+	forwardedPrefixSize = 64
+)
+
+// timedConnKey is the context key of the client connection a REALITY
+// handshake opens a connection to dest for.
+type timedConnKey struct{}
+
+// handshakeTiming measures how long dest takes to answer a ClientHello, on the
+// connections REALITY opens to it anyway, and delays our ServerHello to match.
+type handshakeTiming struct {
+	now      func() time.Time
+	sleep    func(time.Duration)
+	normal   func() float64
+	maxDelay time.Duration
+
+	access sync.Mutex
+	// measurements since the statistics were last refreshed
+	samples   []time.Duration
+	refreshed time.Time
+	// number of measurements mean and stddev are computed from
+	count  int
+	mean   time.Duration
+	stddev time.Duration
+}
+
+func newHandshakeTiming(maxDelay time.Duration) *handshakeTiming {
+	return &handshakeTiming{
+		now:      time.Now,
+		sleep:    time.Sleep,
+		normal:   rand.NormFloat64,
+		maxDelay: maxDelay,
+	}
+}
+
+func (t *handshakeTiming) record(latency time.Duration) {
+	t.access.Lock()
+	defer t.access.Unlock()
+
+	if len(t.samples) == timingMaxSamples {
+		copy(t.samples, t.samples[1:])
+		t.samples = t.samples[:timingMaxSamples-1]
+	}
+	t.samples = append(t.samples, latency)
+	if t.count < timingMinSamples || (len(t.samples) >= timingMinSamples && t.now().Sub(t.refreshed) >= timingRefreshInterval) {
+		t.refresh()
+	}
+}
+
+func (t *handshakeTiming) refresh() {
+	var sum float64
+	for _, s := range t.samples {
+		sum += float64(s)
+	}
+	mean := sum / float64(len(t.samples))
+	var variance float64
+	for _, s := range t.samples {
+		variance += (float64(s) - mean) * (float64(s) - mean)
+	}
+	variance /= float64(len(t.samples))
+
+	t.count = len(t.samples)
+	t.mean = time.Duration(mean)
+	t.stddev = time.Duration(math.Sqrt(variance))
+	t.refreshed = t.now()
+	if t.count >= timingMinSamples {
+		t.samples = t.samples[:0]
+	}
+}
+
+// delay returns how long dest would take to answer a ClientHello, sampled
+// from the measured distribution, or 0 if nothing has been measured yet.
+func (t *handshakeTiming) delay() time.Duration {
+	t.access.Lock()
+	defer t.access.Unlock()
+
+	if t.count == 0 {
+		return 0
+	}
+	d := t.mean + time.Duration(t.normal()*float64(t.stddev))
+	if d < 0 {
+		return 0
+	}
+	if d > t.maxDelay {
+		return t.maxDelay
+	}
+	return d
+}
+
+// wait blocks until dest would have answered a ClientHello received at since.
+func (t *handshakeTiming) wait(since time.Time) {
+	if w := since.Add(t.delay()).Sub(t.now()); w > 0 {
+		t.sleep(w)
+	}
+}
+
+// measuredConn is a connection to dest. The time between the last write
+// before dest answers, the forwarded ClientHello, and the answer is recorded.
+type measuredConn struct {
+	net.Conn
+	timing *handshakeTiming
+	// client is the connection the handshake is for, nil if unknown
+	client *timedConn
+
+	access   sync.Mutex
+	sentAt   time.Time
+	measured bool
+}
+
+func (c *measuredConn) Write(b []byte) (int, error) {
+	c.access.Lock()
+	if !c.measured {
+		c.sentAt = c.timing.now()
+	}
+	c.access.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *measuredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.access.Lock()
+		if !c.measured {
+			c.measured = true
+			if !c.sentAt.IsZero() {
+				c.timing.record(c.timing.now().Sub(c.sentAt))
+			}
+			if c.client != nil {
+				c.client.answered(b[:n])
+			}
+		}
+		c.access.Unlock()
+	}
+	return n, err
+}
+
+// timedConn is a client connection whose first write, our ServerHello, waits
+// as long as dest would have taken to answer the ClientHello. When the
+// handshake is not authenticated, REALITY forwards the answer of dest
+// instead, which already took as long as it takes and is not delayed.
+type timedConn struct {
+	net.Conn
+	timing *handshakeTiming
+
+	access    sync.Mutex
+	helloAt   time.Time
+	written   bool
+	forwarded []byte // start of the first answer of dest
+}
+
+func (c *timedConn) answered(b []byte) {
+	if len(b) > forwardedPrefixSize {
+		b = b[:forwardedPrefixSize]
+	}
+	c.access.Lock()
+	c.forwarded = append([]byte(nil), b...)
+	c.access.Unlock()
+}
+
+func (c *timedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.access.Lock()
+		if c.helloAt.IsZero() {
+			c.helloAt = c.timing.now()
+		}
+		c.access.Unlock()
+	}
+	return n, err
+}
+
+func (c *timedConn) Write(b []byte) (int, error) {
+	c.access.Lock()
+	first := !c.written
+	c.written = true
+	helloAt := c.helloAt
+	n := min(len(b), len(c.forwarded))
+	forwarded := n > 0 && bytes.Equal(b[:n], c.forwarded[:n])
+	c.access.Unlock()
+	if first && !helloAt.IsZero() && !forwarded {
+		c.timing.wait(helloAt)
+	}
+	return c.Conn.Write(b)
+}
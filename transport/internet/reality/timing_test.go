@@ -0,0 +1,186 @@
+package reality
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// nopConn reads and writes instantly.
+type nopConn struct {
+	net.Conn
+}
+
+func (nopConn) Read(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (nopConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func newFakeTiming(clock *fakeClock, maxDelay time.Duration) *handshakeTiming {
+	r := rand.New(rand.NewSource(1))
+	return &handshakeTiming{
+		now:      clock.Now,
+		sleep:    clock.Sleep,
+		normal:   r.NormFloat64,
+		maxDelay: maxDelay,
+	}
+}
+
+// target makes a handshake with a dest answering after latency.
+func target(clock *fakeClock, timing *handshakeTiming, latency time.Duration) {
+	conn := &measuredConn{Conn: nopConn{}, timing: timing}
+	conn.Write([]byte("ClientHello"))
+	clock.Sleep(latency)
+	conn.Read(make([]byte, 1))
+	conn.Read(make([]byte, 1))
+}
+
+// serverHello returns how long after the ClientHello our ServerHello is sent,
+// when generating it takes processing.
+func serverHello(clock *fakeClock, timing *handshakeTiming, processing time.Duration) time.Duration {
+	conn := &timedConn{Conn: nopConn{}, timing: timing}
+	conn.Read(make([]byte, 1))
+	helloAt := clock.Now()
+	clock.Sleep(processing)
+	conn.Write([]byte("ServerHello"))
+	sentAt := clock.Now()
+	conn.Write([]byte("Certificate"))
+	return sentAt.Sub(helloAt)
+}
+
+func TestHandshakeTimingTracksTarget(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timing := newFakeTiming(clock, time.Second)
+
+	if d := serverHello(clock, timing, time.Millisecond); d != time.Millisecond {
+		t.Error("delayed without measurement: ", d)
+	}
+
+	for i := 0; i < 100; i++ {
+		target(clock, timing, time.Millisecond*time.Duration(80+i%2*40)) // 100±20ms
+	}
+
+	var sum time.Duration
+	const n = 200
+	for i := 0; i < n; i++ {
+		d := serverHello(clock, timing, time.Millisecond*2)
+		if d < time.Millisecond*2 {
+			t.Fatal("ServerHello sent before it was ready: ", d)
+		}
+		sum += d
+	}
+	if mean := sum / n; mean < time.Millisecond*90 || mean > time.Millisecond*110 {
+		t.Error("mean handshake delay ", mean, " does not track target")
+	}
+}
+
+func TestHandshakeTimingCap(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timing := newFakeTiming(clock, time.Millisecond*50)
+
+	for i := 0; i < timingMinSamples; i++ {
+		target(clock, timing, time.Millisecond*300)
+	}
+	if d := serverHello(clock, timing, 0); d != time.Millisecond*50 {
+		t.Error("delay not capped: ", d)
+	}
+	if d := serverHello(clock, timing, time.Millisecond*80); d != time.Millisecond*80 {
+		t.Error("slow ServerHello delayed further: ", d)
+	}
+}
+
+func TestHandshakeTimingRefreshesHourly(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timing := newFakeTiming(clock, time.Second)
+
+	for i := 0; i < timingMinSamples; i++ {
+		target(clock, timing, time.Millisecond*100)
+	}
+	if d := serverHello(clock, timing, 0); d != time.Millisecond*100 {
+		t.Fatal("unexpected delay: ", d)
+	}
+
+	// the target slows down, which is noticed an hour later
+	for i := 0; i < timingMinSamples; i++ {
+		target(clock, timing, time.Millisecond*200)
+	}
+	if d := serverHello(clock, timing, 0); d != time.Millisecond*100 {
+		t.Error("refreshed within the hour: ", d)
+	}
+	clock.Sleep(timingRefreshInterval)
+	target(clock, timing, time.Millisecond*200)
+	if d := serverHello(clock, timing, 0); d != time.Millisecond*200 {
+		t.Error("not refreshed after an hour: ", d)
+	}
+}
+
+func TestHandshakeTimingSkipsForwarded(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timing := newFakeTiming(clock, time.Second)
+
+	for i := 0; i < timingMinSamples; i++ {
+		target(clock, timing, time.Millisecond*100)
+	}
+
+	// handshake makes a handshake with a dest answering after latency, and
+	// returns how long after the ClientHello the client gets its first write
+	handshake := func(authenticated bool, latency time.Duration) time.Duration {
+		client := &timedConn{Conn: nopConn{}, timing: timing}
+		dest := &measuredConn{Conn: nopConn{}, timing: timing, client: client}
+		client.Read(make([]byte, 1))
+		helloAt := clock.Now()
+		dest.Write([]byte("ClientHello"))
+		clock.Sleep(latency)
+		answer := make([]byte, 16)
+		dest.Read(answer)
+		if authenticated {
+			client.Write([]byte("ServerHello"))
+		} else {
+			client.Write(answer)
+		}
+		return clock.Now().Sub(helloAt)
+	}
+
+	if d := handshake(false, time.Millisecond*10); d != time.Millisecond*10 {
+		t.Error("forwarded answer of dest delayed: ", d)
+	}
+	if d := handshake(true, time.Millisecond*10); d <= time.Millisecond*10 {
+		t.Error("authenticated handshake not delayed: ", d)
+	}
+}
+
+func TestHandshakeTimingPerConfig(t *testing.T) {
+	mimic := &Config{MimicTiming: true}
+	first := mimic.GetREALITYServerConfig()
+	second := mimic.GetREALITYServerConfig()
+	if first.timing == nil || second.timing == nil {
+		t.Fatal("handshake timing not set up")
+	}
+	if first.timing == second.timing {
+		t.Error("handshake timing shared between listeners")
+	}
+	if first.timing.maxDelay != defaultMaxTimingDelay {
+		t.Error("unexpected max delay: ", first.timing.maxDelay)
+	}
+
+	if config := (&Config{}).GetREALITYServerConfig(); config.timing != nil {
+		t.Error("handshake timing set up without mimicTiming")
+	}
+}
@@ -0,0 +1,120 @@
+package internet
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	gonet "net"
+	"syscall"
+	"time"
+
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// retryableError marks an error of a dial as worth another attempt.
+type retryableError struct {
+	error
+}
+
+func (e retryableError) Unwrap() error {
+	return e.error
+}
+
+// Retryable marks err as worth another attempt, for the errors of transports
+// that IsRetryable can't tell, such as the unavailable status of gRPC.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return retryableError{err}
+}
+
+// IsRetryable returns whether a dial that failed with err may succeed if tried again,
+// as with timeouts, reset connections and temporary DNS failures. Certificate errors
+// and the like fail again, and are returned at once.
+func IsRetryable(err error) bool {
+	var x509Unknown x509.UnknownAuthorityError
+	var x509Invalid x509.CertificateInvalidError
+	var x509Hostname x509.HostnameError
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &x509Unknown), errors.As(err, &x509Invalid), errors.As(err, &x509Hostname):
+		return false
+	case errors.As(err, new(retryableError)):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return true
+	// a reset during a TLS handshake often ends it with EOF
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	var dnsErr *gonet.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var netErr gonet.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff is how long to wait between the attempts of a dial.
+type backoff struct {
+	// initial is the wait after the first failure, doubled after each one up to max.
+	initial, max time.Duration
+	// jitter returns a random duration in [d/2, d], so that dials failing together
+	// don't retry together.
+	jitter func(d time.Duration) time.Duration
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+var dialBackoff = &backoff{
+	initial: 100 * time.Millisecond,
+	max:     2 * time.Second,
+	jitter: func(d time.Duration) time.Duration {
+		return d/2 + time.Duration(dice.Roll(int(d/2)+1))
+	},
+	now: time.Now,
+	sleep: func(ctx context.Context, d time.Duration) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	},
+}
+
+// DialWithRetry calls dial, and up to retries more times while it fails with errors
+// IsRetryable accepts, waiting an exponential backoff with jitter in between. No retry
+// begins past the deadline of ctx.
+func DialWithRetry(ctx context.Context, retries uint32, dial func(ctx context.Context) (stat.Connection, error)) (stat.Connection, error) {
+	return dialBackoff.dial(ctx, retries, dial)
+}
+
+func (b *backoff) dial(ctx context.Context, retries uint32, dial func(ctx context.Context) (stat.Connection, error)) (stat.Connection, error) {
+	wait := b.initial
+	for attempt := uint32(0); ; attempt++ {
+		conn, err := dial(ctx)
+		if err == nil || attempt == retries || ctx.Err() != nil || !IsRetryable(err) {
+			return conn, err
+		}
+
+		d := b.jitter(wait)
+		if deadline, ok := ctx.Deadline(); ok && !b.now().Add(d).Before(deadline) {
+			return nil, err
+		}
+		newError("dial failed, retrying in ", d).Base(err).WriteToLog(session.ExportIDToError(ctx))
+		if b.sleep(ctx, d) != nil {
+			return nil, err
+		}
+		if wait *= 2; wait > b.max {
+			wait = b.max
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package internet
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for _, c := range []struct {
+		err       error
+		retryable bool
+	}{
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{newError("failed to dial").Base(io.EOF), true},
+		{&net.DNSError{Err: "server misbehaving", IsTemporary: true}, true},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{&net.OpError{Op: "dial", Err: &timeoutError{}}, true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, false},
+		{newError("tls handshake").Base(x509.UnknownAuthorityError{}), false},
+		{x509.HostnameError{Host: "example.com"}, false},
+		{Retryable(errors.New("unavailable")), true},
+		{context.Canceled, false},
+	} {
+		if IsRetryable(c.err) != c.retryable {
+			t.Error("expect ", c.err, " retryable: ", c.retryable)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "i/o timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
+
+func TestDialWithRetry(t *testing.T) {
+	// contexts expire by the real clock, so the fake one starts from it
+	now := time.Now()
+	var sleeps []time.Duration
+	b := &backoff{
+		initial: 100 * time.Millisecond,
+		max:     time.Second,
+		jitter:  func(d time.Duration) time.Duration { return d },
+		now:     func() time.Time { return now },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			now = now.Add(d)
+			return nil
+		},
+	}
+	reset := &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+	failing := func(failures int, err error) (func(context.Context) (stat.Connection, error), *int) {
+		attempts := new(int)
+		return func(context.Context) (stat.Connection, error) {
+			*attempts++
+			if *attempts <= failures {
+				return nil, err
+			}
+			local, remote := net.Pipe()
+			remote.Close()
+			return local, nil
+		}, attempts
+	}
+	expectSleeps := func(expected ...time.Duration) {
+		t.Helper()
+		if len(sleeps) != len(expected) {
+			t.Fatal("expect sleeps ", expected, ", but got ", sleeps)
+		}
+		for i := range expected {
+			if sleeps[i] != expected[i] {
+				t.Fatal("expect sleeps ", expected, ", but got ", sleeps)
+			}
+		}
+		sleeps = nil
+	}
+
+	// the 5th attempt succeeds, with the backoff doubled up to its max
+	dial, attempts := failing(4, reset)
+	conn, err := b.dial(context.Background(), 5, dial)
+	if err != nil || *attempts != 5 {
+		t.Fatal("expect success in 5 attempts, but got ", err, " in ", *attempts)
+	}
+	conn.Close()
+	expectSleeps(100*time.Millisecond, 200*time.Millisecond, 400*time.Millisecond, 800*time.Millisecond)
+
+	// out of retries
+	dial, attempts = failing(4, reset)
+	if _, err := b.dial(context.Background(), 2, dial); err != reset || *attempts != 3 {
+		t.Fatal("expect the error of the 3rd attempt, but got ", err, " in ", *attempts)
+	}
+	expectSleeps(100*time.Millisecond, 200*time.Millisecond)
+
+	// a single attempt by default
+	dial, attempts = failing(1, reset)
+	if _, err := b.dial(context.Background(), 0, dial); err != reset || *attempts != 1 {
+		t.Fatal("expect a single attempt, but got ", *attempts)
+	}
+	expectSleeps()
+
+	// certificate errors are not retried
+	dial, attempts = failing(1, x509.UnknownAuthorityError{})
+	if _, err := b.dial(context.Background(), 5, dial); err == nil || *attempts != 1 {
+		t.Fatal("expect a single attempt, but got ", *attempts)
+	}
+	expectSleeps()
+
+	// no retry would end past the dial timeout
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Second))
+	defer cancel()
+	dial, attempts = failing(10, reset)
+	if _, err := b.dial(ctx, 10, dial); err != reset || *attempts != 4 {
+		t.Fatal("expect 4 attempts within the timeout, but got ", *attempts)
+	}
+	expectSleeps(100*time.Millisecond, 200*time.Millisecond, 400*time.Millisecond)
+}
@@ -0,0 +1,69 @@
+package internet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+const (
+	rttRegistrySize = 4096
+	rttExpiry       = 10 * time.Minute
+)
+
+type rttEntry struct {
+	rtt     time.Duration
+	updated time.Time
+}
+
+// rttRegistry keeps the TCP connection setup time of recently dialed IPs, as
+// a passive estimation of their round trip time.
+var rttRegistry = struct {
+	sync.Mutex
+	entries map[string]rttEntry
+}{
+	entries: make(map[string]rttEntry),
+}
+
+func recordRTT(ip net.IP, rtt time.Duration) {
+	key := string(ip.To16())
+	now := time.Now()
+
+	rttRegistry.Lock()
+	defer rttRegistry.Unlock()
+
+	if entry, found := rttRegistry.entries[key]; found && now.Sub(entry.updated) < rttExpiry {
+		rtt = (entry.rtt*7 + rtt) / 8
+	} else if len(rttRegistry.entries) >= rttRegistrySize {
+		for k, entry := range rttRegistry.entries {
+			if now.Sub(entry.updated) >= rttExpiry {
+				delete(rttRegistry.entries, k)
+			}
+		}
+		if len(rttRegistry.entries) >= rttRegistrySize {
+			// map iteration order is random enough to pick a victim
+			for k := range rttRegistry.entries {
+				delete(rttRegistry.entries, k)
+				break
+			}
+		}
+	}
+	rttRegistry.entries[key] = rttEntry{
+		rtt:     rtt,
+		updated: now,
+	}
+}
+
+// LookupRTT returns the round trip time to the IP estimated from recent TCP
+// dials, and whether there is such an estimation.
+func LookupRTT(ip net.IP) (time.Duration, bool) {
+	rttRegistry.Lock()
+	defer rttRegistry.Unlock()
+
+	entry, found := rttRegistry.entries[string(ip.To16())]
+	if !found || time.Since(entry.updated) >= rttExpiry {
+		return 0, false
+	}
+	return entry.rtt, true
+}
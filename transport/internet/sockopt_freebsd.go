@@ -140,7 +140,7 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 				return newError("failed to set TCP_FASTOPEN_CONNECT=", tfo).Base(err)
 			}
 		}
-		if config.TcpKeepAliveIdle > 0 || config.TcpKeepAliveInterval > 0 {
+		if config.TcpKeepAliveIdle > 0 || config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveCount > 0 {
 			if config.TcpKeepAliveIdle > 0 {
 				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, int(config.TcpKeepAliveIdle)); err != nil {
 					return newError("failed to set TCP_KEEPIDLE", err)
@@ -151,10 +151,15 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 					return newError("failed to set TCP_KEEPINTVL", err)
 				}
 			}
+			if config.TcpKeepAliveCount > 0 {
+				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, int(config.TcpKeepAliveCount)); err != nil {
+					return newError("failed to set TCP_KEEPCNT", err)
+				}
+			}
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1); err != nil {
 				return newError("failed to set SO_KEEPALIVE", err)
 			}
-		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 {
+		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 || config.TcpKeepAliveCount < 0 {
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 0); err != nil {
 				return newError("failed to unset SO_KEEPALIVE", err)
 			}
@@ -189,7 +194,7 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 				return newError("failed to set TCP_FASTOPEN=", tfo).Base(err)
 			}
 		}
-		if config.TcpKeepAliveIdle > 0 || config.TcpKeepAliveInterval > 0 {
+		if config.TcpKeepAliveIdle > 0 || config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveCount > 0 {
 			if config.TcpKeepAliveIdle > 0 {
 				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, int(config.TcpKeepAliveIdle)); err != nil {
 					return newError("failed to set TCP_KEEPIDLE", err)
@@ -200,10 +205,15 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 					return newError("failed to set TCP_KEEPINTVL", err)
 				}
 			}
+			if config.TcpKeepAliveCount > 0 {
+				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, int(config.TcpKeepAliveCount)); err != nil {
+					return newError("failed to set TCP_KEEPCNT", err)
+				}
+			}
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1); err != nil {
 				return newError("failed to set SO_KEEPALIVE", err)
 			}
-		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 {
+		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 || config.TcpKeepAliveCount < 0 {
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 0); err != nil {
 				return newError("failed to unset SO_KEEPALIVE", err)
 			}
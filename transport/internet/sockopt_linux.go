@@ -57,7 +57,7 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 			}
 		}
 
-		if config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveIdle > 0 {
+		if config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveIdle > 0 || config.TcpKeepAliveCount > 0 {
 			if config.TcpKeepAliveInterval > 0 {
 				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, int(config.TcpKeepAliveInterval)); err != nil {
 					return newError("failed to set TCP_KEEPINTVL", err)
@@ -68,10 +68,15 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 					return newError("failed to set TCP_KEEPIDLE", err)
 				}
 			}
+			if config.TcpKeepAliveCount > 0 {
+				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, int(config.TcpKeepAliveCount)); err != nil {
+					return newError("failed to set TCP_KEEPCNT", err)
+				}
+			}
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1); err != nil {
 				return newError("failed to set SO_KEEPALIVE", err)
 			}
-		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 {
+		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 || config.TcpKeepAliveCount < 0 {
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 0); err != nil {
 				return newError("failed to unset SO_KEEPALIVE", err)
 			}
@@ -132,7 +137,7 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 			}
 		}
 
-		if config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveIdle > 0 {
+		if config.TcpKeepAliveInterval > 0 || config.TcpKeepAliveIdle > 0 || config.TcpKeepAliveCount > 0 {
 			if config.TcpKeepAliveInterval > 0 {
 				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, int(config.TcpKeepAliveInterval)); err != nil {
 					return newError("failed to set TCP_KEEPINTVL", err)
@@ -143,10 +148,15 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 					return newError("failed to set TCP_KEEPIDLE", err)
 				}
 			}
+			if config.TcpKeepAliveCount > 0 {
+				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, int(config.TcpKeepAliveCount)); err != nil {
+					return newError("failed to set TCP_KEEPCNT", err)
+				}
+			}
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1); err != nil {
 				return newError("failed to set SO_KEEPALIVE", err)
 			}
-		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 {
+		} else if config.TcpKeepAliveInterval < 0 || config.TcpKeepAliveIdle < 0 || config.TcpKeepAliveCount < 0 {
 			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 0); err != nil {
 				return newError("failed to unset SO_KEEPALIVE", err)
 			}
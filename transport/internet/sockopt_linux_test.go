@@ -40,3 +40,43 @@ func TestSockOptMark(t *testing.T) {
 	})
 	common.Must(err)
 }
+
+func TestSockOptKeepAlive(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: func(b []byte) []byte {
+			return b
+		},
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	dialer := DefaultSystemDialer{}
+	conn, err := dialer.Dial(context.Background(), nil, dest, &SocketConfig{
+		TcpKeepAliveIdle:     30,
+		TcpKeepAliveInterval: 10,
+		TcpKeepAliveCount:    4,
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	common.Must(err)
+	err = rawConn.Control(func(fd uintptr) {
+		for _, opt := range []struct {
+			level, name, value int
+		}{
+			{syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1},
+			{syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, 30},
+			{syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, 10},
+			{syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, 4},
+		} {
+			v, err := syscall.GetsockoptInt(int(fd), opt.level, opt.name)
+			common.Must(err)
+			if v != opt.value {
+				t.Error("unexpected value of socket option ", opt.name, ": ", v, ", want ", opt.value)
+			}
+		}
+	})
+	common.Must(err)
+}
@@ -2,6 +2,7 @@ package stat
 
 import (
 	"net"
+	"sync/atomic"
 
 	"github.com/xtls/xray-core/features/stats"
 )
@@ -14,6 +15,10 @@ type CounterConnection struct {
 	Connection
 	ReadCounter  stats.Counter
 	WriteCounter stats.Counter
+	// Gauge counts the connection as open. It has been incremented by the
+	// creator of the connection and is decremented on the first Close.
+	Gauge  stats.Counter
+	closed atomic.Bool
 }
 
 func (c *CounterConnection) Read(b []byte) (int, error) {
@@ -32,3 +37,10 @@ func (c *CounterConnection) Write(b []byte) (int, error) {
 	}
 	return nBytes, err
 }
+
+func (c *CounterConnection) Close() error {
+	if c.Gauge != nil && c.closed.CompareAndSwap(false, true) {
+		c.Gauge.Add(-1)
+	}
+	return c.Connection.Close()
+}
@@ -50,6 +50,14 @@ func hasBindAddr(sockopt *SocketConfig) bool {
 func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
 	newError("dialing to " + dest.String()).AtDebug().WriteToLog()
 
+	if dest.Address.Family().IsDomain() && net.IsVsockAddress(dest.Address.Domain()) {
+		addr, err := net.ParseVsockAddress(dest.Address.Domain())
+		if err != nil {
+			return nil, err
+		}
+		return dialVsock(ctx, addr)
+	}
+
 	if dest.Network == net.Network_UDP && !hasBindAddr(sockopt) {
 		srcAddr := resolveSrcAddr(net.Network_UDP, src)
 		if srcAddr == nil {
@@ -83,7 +91,7 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 		}, nil
 	}
 	goStdKeepAlive := time.Duration(0)
-	if sockopt != nil && (sockopt.TcpKeepAliveInterval != 0 || sockopt.TcpKeepAliveIdle != 0) {
+	if sockopt != nil && (sockopt.TcpKeepAliveInterval != 0 || sockopt.TcpKeepAliveIdle != 0 || sockopt.TcpKeepAliveCount != 0) {
 		goStdKeepAlive = time.Duration(-1)
 	}
 	dialer := &net.Dialer{
@@ -129,7 +129,11 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 		}
 	}
 
-	l, err = lc.Listen(ctx, network, address)
+	if vsock, ok := addr.(*net.VsockAddr); ok {
+		l, err = listenVsock(vsock)
+	} else {
+		l, err = lc.Listen(ctx, network, address)
+	}
 	l, err = callback(l, err)
 	if sockopt != nil && sockopt.AcceptProxyProtocol {
 		policyFunc := func(upstream net.Addr) (proxyproto.Policy, error) { return proxyproto.REQUIRE, nil }
@@ -6,6 +6,7 @@ import (
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/trace"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/stat"
@@ -14,6 +15,12 @@ import (
 
 // Dial dials a new TCP connection to the given destination.
 func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	return internet.DialWithRetry(ctx, streamSettings.DialRetries, func(ctx context.Context) (stat.Connection, error) {
+		return dial(ctx, dest, streamSettings)
+	})
+}
+
+func dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
 	newError("dialing TCP to ", dest).WriteToLog(session.ExportIDToError(ctx))
 	conn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
 	if err != nil {
@@ -23,15 +30,24 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
 		tlsConfig := config.GetTLSConfig(tls.WithDestination(dest))
 		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
-			conn = tls.UClient(conn, tlsConfig, fingerprint)
-			if err := conn.(*tls.UConn).HandshakeContext(ctx); err != nil {
+			conn = tls.UClient(conn, tlsConfig, fingerprint, config.PostQuantum)
+			_, span := trace.Start(ctx, "tls handshake")
+			span.SetAttribute("security", "tls")
+			err := conn.(*tls.UConn).HandshakeContext(ctx)
+			span.EndWithError(err)
+			if err != nil {
+				conn.Close()
 				return nil, err
 			}
 		} else {
 			conn = tls.Client(conn, tlsConfig)
 		}
 	} else if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
-		if conn, err = reality.UClient(conn, config, ctx, dest); err != nil {
+		_, span := trace.Start(ctx, "tls handshake")
+		span.SetAttribute("security", "reality")
+		conn, err = reality.UClient(conn, config, ctx, dest)
+		span.EndWithError(err)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	goreality "github.com/xtls/reality"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
@@ -20,7 +19,7 @@ import (
 type Listener struct {
 	listener      net.Listener
 	tlsConfig     *gotls.Config
-	realityConfig *goreality.Config
+	realityConfig *reality.ServerConfig
 	authConfig    internet.ConnectionAuthenticator
 	config        *Config
 	addConn       internet.ConnHandler
@@ -41,7 +40,17 @@ func ListenTCP(ctx context.Context, address net.Address, port net.Port, streamSe
 	}
 	var listener net.Listener
 	var err error
-	if port == net.Port(0) { // unix
+	if address.Family().IsDomain() && net.IsVsockAddress(address.Domain()) {
+		vsock, err := net.ParseVsockAddress(address.Domain())
+		if err != nil {
+			return nil, err
+		}
+		listener, err = internet.ListenSystem(ctx, vsock, streamSettings.SocketSettings)
+		if err != nil {
+			return nil, newError("failed to listen VSOCK on ", address).Base(err)
+		}
+		newError("listening VSOCK on ", address).WriteToLog(session.ExportIDToError(ctx))
+	} else if port == net.Port(0) { // unix
 		listener, err = internet.ListenSystem(ctx, &net.UnixAddr{
 			Name: address.Domain(),
 			Net:  "unix",
@@ -71,7 +80,7 @@ func ListenTCP(ctx context.Context, address net.Address, port net.Port, streamSe
 		l.tlsConfig = config.GetTLSConfig()
 	}
 	if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
-		l.realityConfig = config.GetREALITYConfig()
+		l.realityConfig = config.GetREALITYServerConfig()
 	}
 
 	if tcpSettings.HeaderSettings != nil {
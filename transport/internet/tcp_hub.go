@@ -41,6 +41,9 @@ func ListenUnix(ctx context.Context, address net.Address, settings *MemoryStream
 	if listenFunc == nil {
 		return nil, newError(protocol, " unix istener not registered.").AtError()
 	}
+	if address.Family().IsDomain() && net.IsVsockAddress(address.Domain()) && protocol != "tcp" {
+		return nil, newError("listening on vsock is not supported by ", protocol, " transport").AtError()
+	}
 	listener, err := listenFunc(ctx, address, net.Port(0), settings, handler)
 	if err != nil {
 		return nil, newError("failed to listen on unix address: ", address).Base(err)
@@ -41,6 +41,7 @@ func (t grpcUtlsInfo) GetSecurityValue() credentials.ChannelzSecurityValue {
 type grpcUtls struct {
 	config      *gotls.Config
 	fingerprint *utls.ClientHelloID
+	postQuantum bool
 }
 
 func (c grpcUtls) Info() credentials.ProtocolInfo {
@@ -62,7 +63,7 @@ func (c *grpcUtls) ClientHandshake(ctx context.Context, authority string, rawCon
 		}
 		cfg.ServerName = serverName
 	}
-	conn := UClient(rawConn, cfg, c.fingerprint).(*UConn)
+	conn := UClient(rawConn, cfg, c.fingerprint, c.postQuantum).(*UConn)
 	errChannel := make(chan error, 1)
 	go func() {
 		errChannel <- conn.HandshakeContext(ctx)
@@ -93,7 +94,7 @@ func (c *grpcUtls) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, er
 }
 
 func (c *grpcUtls) Clone() credentials.TransportCredentials {
-	return NewGrpcUtls(c.config, c.fingerprint)
+	return NewGrpcUtls(c.config, c.fingerprint, c.postQuantum)
 }
 
 func (c *grpcUtls) OverrideServerName(serverNameOverride string) error {
@@ -102,7 +103,7 @@ func (c *grpcUtls) OverrideServerName(serverNameOverride string) error {
 }
 
 // NewGrpcUtls uses c to construct a TransportCredentials based on uTLS.
-func NewGrpcUtls(c *gotls.Config, fingerprint *utls.ClientHelloID) credentials.TransportCredentials {
-	tc := &grpcUtls{c.Clone(), fingerprint}
+func NewGrpcUtls(c *gotls.Config, fingerprint *utls.ClientHelloID, postQuantum bool) credentials.TransportCredentials {
+	tc := &grpcUtls{c.Clone(), fingerprint, postQuantum}
 	return tc
 }
@@ -0,0 +1,56 @@
+package tls
+
+import (
+	utls "github.com/refraction-networking/utls"
+)
+
+// postQuantumSpec returns the ClientHelloSpec of fingerprint with a hybrid
+// X25519Kyber768Draft00 key share offered ahead of the classical ones, the
+// way Chrome does since version 124.
+func postQuantumSpec(fingerprint *utls.ClientHelloID) (*utls.ClientHelloSpec, error) {
+	switch fingerprint.Client {
+	case utls.HelloRandomized.Client, utls.HelloRandomizedALPN.Client, utls.HelloRandomizedNoALPN.Client:
+		// a new spec would be generated on every call, with key shares of its own
+		return nil, newError("randomized fingerprint ", fingerprint.Str(), " has no fixed ClientHelloSpec")
+	}
+	spec, err := utls.UTLSIdToSpec(*fingerprint)
+	if err != nil {
+		return nil, newError("failed to get ClientHelloSpec of ", fingerprint.Str()).Base(err)
+	}
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.SupportedCurvesExtension:
+			if !hasPostQuantumCurve(e.Curves) {
+				i := skipGREASECurves(e.Curves)
+				e.Curves = append(e.Curves[:i:i], append([]utls.CurveID{utls.X25519Kyber768Draft00}, e.Curves[i:]...)...)
+			}
+		case *utls.KeyShareExtension:
+			shares := make([]utls.CurveID, 0, len(e.KeyShares))
+			for _, share := range e.KeyShares {
+				shares = append(shares, share.Group)
+			}
+			if !hasPostQuantumCurve(shares) {
+				i := skipGREASECurves(shares)
+				e.KeyShares = append(e.KeyShares[:i:i], append([]utls.KeyShare{{Group: utls.X25519Kyber768Draft00}}, e.KeyShares[i:]...)...)
+			}
+		}
+	}
+	return &spec, nil
+}
+
+func hasPostQuantumCurve(curves []utls.CurveID) bool {
+	for _, c := range curves {
+		if c == utls.X25519Kyber768Draft00 {
+			return true
+		}
+	}
+	return false
+}
+
+func skipGREASECurves(curves []utls.CurveID) int {
+	i := 0
+	for i < len(curves) && curves[i] == utls.GREASE_PLACEHOLDER {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,12 @@
+//go:build go1.24
+// +build go1.24
+
+package tls
+
+import (
+	"crypto/tls"
+)
+
+// postQuantumCurves prefers the hybrid X25519MLKEM768 group, which crypto/tls
+// supports since Go 1.24.
+var postQuantumCurves = []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384}
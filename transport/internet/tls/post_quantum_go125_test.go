@@ -0,0 +1,62 @@
+//go:build go1.25
+// +build go1.25
+
+package tls_test
+
+import (
+	"context"
+	gotls "crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
+	. "github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func TestPostQuantumHandshake(t *testing.T) {
+	server := &Config{
+		Certificate: []*Certificate{ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com"), cert.DNSNames("www.example.com")))},
+		PostQuantum: true,
+	}
+
+	cases := []struct {
+		name   string
+		client *gotls.Config
+		curve  gotls.CurveID
+	}{
+		{
+			name:   "post-quantum",
+			client: (&Config{AllowInsecure: true, ServerName: "www.example.com", PostQuantum: true}).GetTLSConfig(),
+			curve:  gotls.X25519MLKEM768,
+		},
+		{
+			name: "classical",
+			client: &gotls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         "www.example.com",
+				CurvePreferences:   []gotls.CurveID{gotls.X25519},
+			},
+			curve: gotls.X25519,
+		},
+	}
+
+	for _, c := range cases {
+		cc, sc := net.Pipe()
+		errc := make(chan error, 1)
+		go func() {
+			errc <- Server(sc, server.GetTLSConfig()).(*Conn).HandshakeContext(context.Background())
+		}()
+		conn := Client(cc, c.client).(*Conn)
+		if err := conn.HandshakeContext(context.Background()); err != nil {
+			t.Fatal(c.name, ": ", err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(c.name, ": ", err)
+		}
+		if curve := conn.ConnectionState().CurveID; curve != c.curve {
+			t.Error(c.name, ": expected ", c.curve, ", but got ", curve)
+		}
+		conn.Close()
+		sc.Close()
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !go1.24
+// +build !go1.24
+
+package tls
+
+import (
+	"crypto/tls"
+)
+
+// postQuantumCurves is empty, crypto/tls has no hybrid post-quantum group
+// before Go 1.24.
+var postQuantumCurves []tls.CurveID
@@ -0,0 +1,66 @@
+package tls
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
+)
+
+func TestPostQuantumSpec(t *testing.T) {
+	spec, err := postQuantumSpec(&utls.HelloChrome_102)
+	common.Must(err)
+
+	var curves, shares []utls.CurveID
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.SupportedCurvesExtension:
+			curves = e.Curves
+		case *utls.KeyShareExtension:
+			for _, share := range e.KeyShares {
+				shares = append(shares, share.Group)
+			}
+		}
+	}
+	for _, list := range [][]utls.CurveID{curves, shares} {
+		i := skipGREASECurves(list)
+		if i >= len(list)-1 || list[i] != utls.X25519Kyber768Draft00 || list[i+1] != utls.X25519 {
+			t.Error("expected X25519Kyber768Draft00 ahead of X25519, but got ", list)
+		}
+	}
+
+	if _, err := postQuantumSpec(&utls.HelloRandomized); err == nil {
+		t.Error("expected error for randomized fingerprint")
+	}
+}
+
+func TestPostQuantumUClient(t *testing.T) {
+	server := &Config{
+		Certificate: []*Certificate{ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com"), cert.DNSNames("www.example.com")))},
+	}
+	client := &Config{
+		AllowInsecure: true,
+		ServerName:    "www.example.com",
+		PostQuantum:   true,
+	}
+
+	for _, fingerprint := range []*utls.ClientHelloID{&utls.HelloChrome_Auto, &utls.HelloRandomized} {
+		c, s := net.Pipe()
+		errc := make(chan error, 1)
+		go func() {
+			errc <- Server(s, server.GetTLSConfig()).(*Conn).HandshakeContext(context.Background())
+		}()
+		conn := UClient(c, client.GetTLSConfig(), fingerprint, true).(*UConn)
+		if err := conn.HandshakeContext(context.Background()); err != nil {
+			t.Error(fingerprint.Str(), ": ", err)
+		}
+		if err := <-errc; err != nil {
+			t.Error(fingerprint.Str(), ": ", err)
+		}
+		conn.Close()
+		s.Close()
+	}
+}
@@ -130,7 +130,19 @@ func (c *UConn) NegotiatedProtocol() string {
 	return state.NegotiatedProtocol
 }
 
-func UClient(c net.Conn, config *tls.Config, fingerprint *utls.ClientHelloID) net.Conn {
+// UClient initiates a uTLS client handshake on the given connection. If postQuantum is set, a
+// hybrid post-quantum key share is added to the ClientHello of fingerprint.
+func UClient(c net.Conn, config *tls.Config, fingerprint *utls.ClientHelloID, postQuantum bool) net.Conn {
+	if postQuantum {
+		spec, err := postQuantumSpec(fingerprint)
+		if err == nil {
+			utlsConn := utls.UClient(c, copyConfig(config), utls.HelloCustom)
+			if err = utlsConn.ApplyPreset(spec); err == nil {
+				return &UConn{UConn: utlsConn}
+			}
+		}
+		newError("post-quantum key exchange is disabled").Base(err).AtWarning().WriteToLog()
+	}
 	utlsConn := utls.UClient(c, copyConfig(config), *fingerprint)
 	return &UConn{UConn: utlsConn}
 }
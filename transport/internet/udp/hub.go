@@ -28,6 +28,7 @@ type Hub struct {
 	cache        chan *udp.Packet
 	capacity     int
 	recvOrigDest bool
+	recvLocal    bool
 }
 
 func ListenUDP(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, options ...HubOption) (*Hub, error) {
@@ -56,6 +57,10 @@ func ListenUDP(ctx context.Context, address net.Address, port net.Port, streamSe
 	}
 	newError("listening UDP on ", address, ":", port).WriteToLog()
 	hub.conn = udpConn.(*net.UDPConn)
+	if address.IP().IsUnspecified() {
+		// the local address of each packet tells which of the addresses of the host it came to
+		hub.recvLocal = enableLocalAddress(hub.conn)
+	}
 	hub.cache = make(chan *udp.Packet, hub.capacity)
 
 	go hub.start()
@@ -113,6 +118,10 @@ func (h *Hub) start() {
 			}
 		}
 
+		if h.recvLocal && noob > 0 {
+			payload.Local = RetrieveLocalAddress(oobBytes[:noob])
+		}
+
 		select {
 		case c <- payload:
 		default:
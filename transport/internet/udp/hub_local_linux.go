@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+package udp
+
+import (
+	"syscall"
+
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/unix"
+)
+
+// enableLocalAddress makes conn receive the destination addresses of packets, and returns
+// whether it does.
+func enableLocalAddress(conn *net.UDPConn) bool {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var enabled bool
+	rawConn.Control(func(fd uintptr) {
+		// a socket of IPv6 reports IPv4 packets by IPV6_PKTINFO as well
+		if unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_RECVPKTINFO, 1) == nil {
+			enabled = true
+		}
+		if unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_PKTINFO, 1) == nil {
+			enabled = true
+		}
+	})
+	return enabled
+}
+
+// RetrieveLocalAddress returns the destination address of a packet from its control messages.
+func RetrieveLocalAddress(oob []byte) net.Address {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.SOL_IP && msg.Header.Type == syscall.IP_PKTINFO && len(msg.Data) >= unix.SizeofInet4Pktinfo {
+			// struct in_pktinfo: ifindex, local address, header destination address
+			return net.IPAddress(msg.Data[8:12])
+		} else if msg.Header.Level == syscall.SOL_IPV6 && msg.Header.Type == unix.IPV6_PKTINFO && len(msg.Data) >= unix.SizeofInet6Pktinfo {
+			return net.IPAddress(msg.Data[0:16])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package udp
+
+import (
+	"github.com/xtls/xray-core/common/net"
+)
+
+func enableLocalAddress(conn *net.UDPConn) bool {
+	return false
+}
+
+func RetrieveLocalAddress(oob []byte) net.Address {
+	return nil
+}
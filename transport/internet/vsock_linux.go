@@ -0,0 +1,225 @@
+package internet
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/unix"
+)
+
+// vsock sockets are not supported by the standard library. They are created
+// non-blocking and handed to os.File, which puts them on the runtime poller.
+
+func newVsockSocket() (int, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, newError("failed to create vsock socket").Base(err)
+	}
+	return fd, nil
+}
+
+func vsockAddrFromSockaddr(sa unix.Sockaddr) *net.VsockAddr {
+	if vm, ok := sa.(*unix.SockaddrVM); ok {
+		return &net.VsockAddr{CID: vm.CID, Port: vm.Port}
+	}
+	return &net.VsockAddr{}
+}
+
+func listenVsock(addr *net.VsockAddr) (net.Listener, error) {
+	fd, err := newVsockSocket()
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Bind(fd, &unix.SockaddrVM{CID: addr.CID, Port: addr.Port}); err != nil {
+		unix.Close(fd)
+		return nil, newError("failed to bind ", addr).Base(err)
+	}
+	if err := unix.Listen(fd, unix.SOMAXCONN); err != nil {
+		unix.Close(fd)
+		return nil, newError("failed to listen on ", addr).Base(err)
+	}
+
+	local := addr
+	if sa, err := unix.Getsockname(fd); err == nil {
+		local = vsockAddrFromSockaddr(sa)
+	}
+	return &vsockListener{
+		file: os.NewFile(uintptr(fd), local.String()),
+		addr: local,
+	}, nil
+}
+
+type vsockListener struct {
+	file *os.File
+	addr *net.VsockAddr
+}
+
+func (l *vsockListener) Accept() (net.Conn, error) {
+	rawConn, err := l.file.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var fd int
+	var sa unix.Sockaddr
+	var acceptErr error
+	if err := rawConn.Read(func(s uintptr) bool {
+		fd, sa, acceptErr = unix.Accept4(int(s), unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC)
+		return acceptErr != unix.EAGAIN
+	}); err != nil {
+		return nil, err
+	}
+	if acceptErr != nil {
+		return nil, newError("failed to accept on ", l.addr).Base(acceptErr)
+	}
+
+	remote := vsockAddrFromSockaddr(sa)
+	return &vsockConn{
+		file:   os.NewFile(uintptr(fd), remote.String()),
+		local:  l.addr,
+		remote: remote,
+	}, nil
+}
+
+func (l *vsockListener) Close() error {
+	return l.file.Close()
+}
+
+func (l *vsockListener) Addr() net.Addr {
+	return l.addr
+}
+
+func dialVsock(ctx context.Context, addr *net.VsockAddr) (net.Conn, error) {
+	fd, err := newVsockSocket()
+	if err != nil {
+		return nil, err
+	}
+	err = unix.Connect(fd, &unix.SockaddrVM{CID: addr.CID, Port: addr.Port})
+	if err != nil && err != unix.EINPROGRESS {
+		unix.Close(fd)
+		return nil, newError("failed to dial ", addr).Base(err)
+	}
+
+	file := os.NewFile(uintptr(fd), addr.String())
+	if err == unix.EINPROGRESS {
+		if err := waitVsockConnect(ctx, file); err != nil {
+			file.Close()
+			return nil, newError("failed to dial ", addr).Base(err)
+		}
+	}
+
+	local := &net.VsockAddr{}
+	if sa, err := unix.Getsockname(fd); err == nil {
+		local = vsockAddrFromSockaddr(sa)
+	}
+	return &vsockConn{
+		file:   file,
+		local:  local,
+		remote: addr,
+	}, nil
+}
+
+// waitVsockConnect waits for a non-blocking connect to finish, or the context to be done.
+func waitVsockConnect(ctx context.Context, file *os.File) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		file.SetWriteDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		file.SetWriteDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	rawConn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var connectErr error
+	waited := false
+	if err := rawConn.Write(func(s uintptr) bool {
+		// The first call comes before the socket is polled.
+		if !waited {
+			waited = true
+			return false
+		}
+		errno, err := unix.GetsockoptInt(int(s), unix.SOL_SOCKET, unix.SO_ERROR)
+		if err != nil {
+			connectErr = err
+			return true
+		}
+		switch unix.Errno(errno) {
+		case unix.EINPROGRESS, unix.EALREADY, unix.EINTR:
+			return false
+		case 0:
+			if _, err := unix.Getpeername(int(s)); err == unix.ENOTCONN {
+				return false
+			}
+			return true
+		default:
+			connectErr = unix.Errno(errno)
+			return true
+		}
+	}); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if connectErr != nil {
+		return connectErr
+	}
+	return file.SetWriteDeadline(time.Time{})
+}
+
+type vsockConn struct {
+	file   *os.File
+	local  *net.VsockAddr
+	remote *net.VsockAddr
+}
+
+func (c *vsockConn) Read(b []byte) (int, error) {
+	return c.file.Read(b)
+}
+
+func (c *vsockConn) Write(b []byte) (int, error) {
+	return c.file.Write(b)
+}
+
+func (c *vsockConn) Close() error {
+	return c.file.Close()
+}
+
+// CloseWrite shuts down the writing side of the connection.
+func (c *vsockConn) CloseWrite() error {
+	rawConn, err := c.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var shutdownErr error
+	if err := rawConn.Control(func(s uintptr) {
+		shutdownErr = unix.Shutdown(int(s), unix.SHUT_WR)
+	}); err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+func (c *vsockConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *vsockConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *vsockConn) SetDeadline(t time.Time) error {
+	return c.file.SetDeadline(t)
+}
+
+func (c *vsockConn) SetReadDeadline(t time.Time) error {
+	return c.file.SetReadDeadline(t)
+}
+
+func (c *vsockConn) SetWriteDeadline(t time.Time) error {
+	return c.file.SetWriteDeadline(t)
+}
@@ -0,0 +1,72 @@
+package internet_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"golang.org/x/sys/unix"
+)
+
+func TestVsockLoopback(t *testing.T) {
+	l, err := internet.ListenSystem(context.Background(), &net.VsockAddr{
+		CID:  unix.VMADDR_CID_LOCAL,
+		Port: unix.VMADDR_PORT_ANY,
+	}, nil)
+	if err != nil {
+		t.Skip("vsock loopback not available: ", err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	addr := l.Addr().(*net.VsockAddr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := internet.DialSystem(ctx, net.VsockDestination(addr), nil)
+	common.Must(err)
+	defer conn.Close()
+
+	if remote := conn.RemoteAddr().String(); remote != addr.String() {
+		t.Error("expect remote address ", addr, ", but got ", remote)
+	}
+
+	payload := []byte("vsock round trip")
+	common.Must2(conn.Write(payload))
+	common.Must(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	received := make([]byte, len(payload))
+	common.Must2(io.ReadFull(conn, received))
+	if string(received) != string(payload) {
+		t.Error("expect ", string(payload), ", but got ", string(received))
+	}
+}
+
+func TestVsockDialRefused(t *testing.T) {
+	l, err := internet.ListenSystem(context.Background(), &net.VsockAddr{
+		CID:  unix.VMADDR_CID_LOCAL,
+		Port: unix.VMADDR_PORT_ANY,
+	}, nil)
+	if err != nil {
+		t.Skip("vsock loopback not available: ", err)
+	}
+	addr := l.Addr().(*net.VsockAddr)
+	l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if conn, err := internet.DialSystem(ctx, net.VsockDestination(addr), nil); err == nil {
+		conn.Close()
+		t.Error("expect error dialing a closed vsock port")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package internet
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+func listenVsock(addr *net.VsockAddr) (net.Listener, error) {
+	return nil, newError("failed to listen on ", addr, ": vsock is not supported on ", runtime.GOOS)
+}
+
+func dialVsock(ctx context.Context, addr *net.VsockAddr) (net.Conn, error) {
+	return nil, newError("failed to dial ", addr, ": vsock is not supported on ", runtime.GOOS)
+}
@@ -53,7 +53,15 @@ func init() {
 func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
 	newError("creating connection to ", dest).WriteToLog(session.ExportIDToError(ctx))
 	var conn net.Conn
-	if streamSettings.ProtocolSettings.(*Config).Ed > 0 {
+	if streamSettings.ProtocolSettings.(*Config).Resume {
+		var err error
+		if conn, err = internet.DialWithRetry(ctx, streamSettings.DialRetries, func(ctx context.Context) (stat.Connection, error) {
+			return dialResumable(ctx, dest, streamSettings)
+		}); err != nil {
+			return nil, newError("failed to dial WebSocket").Base(err)
+		}
+	} else if streamSettings.ProtocolSettings.(*Config).Ed > 0 {
+		// the dial waits for the early data, after which it can't be retried
 		ctx, cancel := context.WithCancel(ctx)
 		conn = &delayDialConn{
 			dialed:         make(chan bool, 1),
@@ -64,7 +72,9 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 		}
 	} else {
 		var err error
-		if conn, err = dialWebSocket(ctx, dest, streamSettings, nil); err != nil {
+		if conn, err = internet.DialWithRetry(ctx, streamSettings.DialRetries, func(ctx context.Context) (stat.Connection, error) {
+			return dialWebSocket(ctx, dest, streamSettings, nil)
+		}); err != nil {
 			return nil, newError("failed to dial WebSocket").Base(err)
 		}
 	}
@@ -76,6 +86,55 @@ func init() {
 }
 
 func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig, ed []byte) (net.Conn, error) {
+	conn, _, err := dialRawWebSocket(ctx, dest, streamSettings, ed, "")
+	if err != nil {
+		return nil, err
+	}
+	return newConnection(conn, conn.RemoteAddr(), nil), nil
+}
+
+// dialResumable dials a WebSocket connection wrapped in a resumable session. It falls back to a plain
+// connection if the server doesn't support resumption.
+func dialResumable(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (net.Conn, error) {
+	conn, resumeValue, err := dialRawWebSocket(ctx, dest, streamSettings, nil, "new")
+	if err != nil {
+		return nil, err
+	}
+	if resumeValue == "" {
+		newError("session resumption is not supported by the server").AtWarning().WriteToLog(session.ExportIDToError(ctx))
+		return newConnection(conn, conn.RemoteAddr(), nil), nil
+	}
+	token, _, err := parseResumeHeader(resumeValue)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := newResumeConn(token, streamSettings.ProtocolSettings.(*Config).getResumeWindow(), conn.LocalAddr(), conn.RemoteAddr())
+	// the connection outlives the dialing context
+	redialCtx := context.WithoutCancel(ctx)
+	c.redial = func(token string, received uint64) (*websocket.Conn, uint64, error) {
+		conn, resumeValue, err := dialRawWebSocket(redialCtx, dest, streamSettings, nil, formatResumeHeader(token, received))
+		if err != nil {
+			return nil, 0, err
+		}
+		_, peerReceived, err := parseResumeHeader(resumeValue)
+		if err != nil {
+			conn.Close()
+			return nil, 0, err
+		}
+		return conn, peerReceived, nil
+	}
+	if err := c.attach(conn, 0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dialRawWebSocket dials a WebSocket connection. If resumeValue is not empty, it is sent in the resume
+// header and the one of the response is returned.
+func dialRawWebSocket(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig, ed []byte, resumeValue string) (*websocket.Conn, string, error) {
 	wsSettings := streamSettings.ProtocolSettings.(*Config)
 
 	dialer := &websocket.Dialer{
@@ -102,7 +161,7 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 					return nil, err
 				}
 				// TLS and apply the handshake
-				cn := tls.UClient(pconn, tlsConfig, fingerprint).(*tls.UConn)
+				cn := tls.UClient(pconn, tlsConfig, fingerprint, config.PostQuantum).(*tls.UConn)
 				if err := cn.WebsocketHandshakeContext(ctx); err != nil {
 					newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
 					return nil, err
@@ -140,12 +199,13 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 		}
 		if _, p, err := conn.ReadMessage(); err != nil {
 			conn.Close()
-			return nil, err
+			return nil, "", err
 		} else if s := string(p); s != "ok" {
 			conn.Close()
-			return nil, newError(s)
+			return nil, "", newError(s)
 		}
-		return newConnection(conn, conn.RemoteAddr(), nil), nil
+		// the browser dialer can't send the resume header
+		return conn, "", nil
 	}
 
 	header := wsSettings.GetRequestHeader()
@@ -153,6 +213,9 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 		// RawURLEncoding is support by both V2Ray/V2Fly and XRay.
 		header.Set("Sec-WebSocket-Protocol", base64.RawURLEncoding.EncodeToString(ed))
 	}
+	if resumeValue != "" {
+		header.Set(resumeHeader, resumeValue)
+	}
 
 	conn, resp, err := dialer.DialContext(ctx, uri, header)
 	if err != nil {
@@ -160,10 +223,13 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 		if resp != nil {
 			reason = resp.Status
 		}
-		return nil, newError("failed to dial to (", uri, "): ", reason).Base(err)
+		return nil, "", newError("failed to dial to (", uri, "): ", reason).Base(err)
 	}
 
-	return newConnection(conn, conn.RemoteAddr(), nil), nil
+	if resumeValue != "" {
+		return conn, resp.Header.Get(resumeHeader), nil
+	}
+	return conn, "", nil
 }
 
 type delayDialConn struct {
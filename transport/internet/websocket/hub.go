@@ -58,8 +58,29 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		}
 	}
 
+	var resumed *resumeConn
+	var isNew bool
+	var peerReceived uint64
+	if h.ln.resume != nil {
+		if value := request.Header.Get(resumeHeader); len(value) > 0 {
+			var response string
+			var err error
+			resumed, isNew, peerReceived, response, err = h.ln.resume.accept(value)
+			if err != nil {
+				newError("failed to resume WebSocket session").Base(err).WriteToLog()
+				rejectUpgrade(writer)
+				return
+			}
+			responseHeader.Set(resumeHeader, response)
+			extraReader = nil
+		}
+	}
+
 	conn, err := upgrader.Upgrade(writer, request, responseHeader)
 	if err != nil {
+		if isNew {
+			resumed.fail(err)
+		}
 		newError("failed to convert to WebSocket connection").Base(err).WriteToLog()
 		return
 	}
@@ -73,15 +94,39 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		}
 	}
 
+	if resumed != nil {
+		if isNew {
+			resumed.localAddr = conn.LocalAddr()
+			resumed.remoteAddr = remoteAddr
+		}
+		if err := resumed.attach(conn, peerReceived); err != nil {
+			newError("failed to resume WebSocket session").Base(err).WriteToLog()
+			conn.Close()
+			return
+		}
+		if isNew {
+			h.ln.addConn(resumed)
+		}
+		return
+	}
+
 	h.ln.addConn(newConnection(conn, remoteAddr, extraReader))
 }
 
+// rejectUpgrade answers the way upgrader does to a request it can't upgrade, so that a refused session
+// looks like any other failed upgrade.
+func rejectUpgrade(writer http.ResponseWriter) {
+	writer.Header().Set("Sec-Websocket-Version", "13")
+	http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+}
+
 type Listener struct {
 	sync.Mutex
 	server   http.Server
 	listener net.Listener
 	config   *Config
 	addConn  internet.ConnHandler
+	resume   *resumeHub
 }
 
 func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
@@ -130,6 +175,10 @@ func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSet
 
 	l.listener = listener
 
+	if wsSettings.Resume {
+		l.resume = newResumeHub(wsSettings.getResumeWindow())
+	}
+
 	l.server = http.Server{
 		Handler: &requestHandler{
 			host: wsSettings.Host,
@@ -156,6 +205,17 @@ func (ln *Listener) Addr() net.Addr {
 
 // Close implements net.Listener.Close().
 func (ln *Listener) Close() error {
+	if ln.resume != nil {
+		ln.resume.Lock()
+		sessions := make([]*resumeConn, 0, len(ln.resume.sessions))
+		for _, s := range ln.resume.sessions {
+			sessions = append(sessions, s)
+		}
+		ln.resume.Unlock()
+		for _, s := range sessions {
+			s.Close()
+		}
+	}
 	return ln.listener.Close()
 }
 
@@ -0,0 +1,553 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xtls/xray-core/common"
+)
+
+// resumeHeader carries "new" or "<token> <received>" in the upgrade request, and "<token> <received>"
+// in the response, where received is the number of bytes the sender has got so far.
+const resumeHeader = "X-Resume-Session"
+
+const (
+	resumeFrameData byte = 0
+	resumeFrameAck  byte = 1
+
+	resumeFrameHeaderSize = 9
+	resumeFrameSize       = 16 * 1024
+	// resumeBufferSize is the most unacknowledged bytes kept for retransmission. Writes block when it is full.
+	resumeBufferSize = 512 * 1024
+	// resumeMaxPending is the most sessions a listener keeps without a connection, either not attached yet or
+	// waiting to be resumed. Each of them may hold a full buffer, so further new sessions are refused.
+	resumeMaxPending = 256
+)
+
+func (c *Config) getResumeWindow() time.Duration {
+	if c.ResumeWindow == 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.ResumeWindow) * time.Second
+}
+
+// resumeConn is a connection over a series of WebSocket connections. Every write is framed with its stream
+// offset and kept until the peer acknowledges it, so that a replacement connection can pick up where the
+// broken one stopped.
+type resumeConn struct {
+	access  sync.Mutex
+	writing sync.Mutex
+	ws      *websocket.Conn
+	gen     uint64
+	changed chan struct{}
+	err     error
+
+	token  string
+	window time.Duration
+	// redial creates a replacement connection, only set on the client side.
+	redial  func(token string, received uint64) (*websocket.Conn, uint64, error)
+	onClose func()
+
+	localAddr  net.Addr
+	remoteAddr net.Addr
+
+	sent     uint64
+	acked    uint64
+	pending  []byte
+	received uint64
+	ackSent  uint64
+	leftover []byte
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func newResumeConn(token string, window time.Duration, localAddr, remoteAddr net.Addr) *resumeConn {
+	return &resumeConn{
+		token:      token,
+		window:     window,
+		changed:    make(chan struct{}),
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+	}
+}
+
+// notifyLocked wakes up everyone waiting for a state change. c.access must be held.
+func (c *resumeConn) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// waitChange blocks until changed is closed, or until deadline if it is set. It returns false if the deadline
+// has passed.
+func waitChange(changed chan struct{}, deadline time.Time) bool {
+	if deadline.IsZero() {
+		<-changed
+		return true
+	}
+	d := time.Until(deadline)
+	if d <= 0 {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-changed:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// attach makes ws the current connection of c and sends again everything peerReceived doesn't cover.
+func (c *resumeConn) attach(ws *websocket.Conn, peerReceived uint64) error {
+	c.writing.Lock()
+	defer c.writing.Unlock()
+
+	c.access.Lock()
+	if c.err != nil {
+		c.access.Unlock()
+		return c.err
+	}
+	if peerReceived < c.acked || peerReceived > c.sent {
+		c.access.Unlock()
+		return newError("invalid resume position ", peerReceived, ", expecting ", c.acked, " to ", c.sent)
+	}
+	c.pending = append(c.pending[:0], c.pending[peerReceived-c.acked:]...)
+	c.acked = peerReceived
+	retransmit := append([]byte(nil), c.pending...)
+	offset := c.acked
+	old := c.ws
+	c.ws = ws
+	c.gen++
+	c.ackSent = c.received
+	if !c.readDeadline.IsZero() {
+		ws.SetReadDeadline(c.readDeadline)
+	}
+	if !c.writeDeadline.IsZero() {
+		ws.SetWriteDeadline(c.writeDeadline)
+	}
+	c.notifyLocked()
+	c.access.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	for len(retransmit) > 0 {
+		n := len(retransmit)
+		if n > resumeFrameSize {
+			n = resumeFrameSize
+		}
+		if err := writeResumeFrame(ws, resumeFrameData, offset, retransmit[:n]); err != nil {
+			// the reader finds out and waits for another connection
+			return nil
+		}
+		offset += uint64(n)
+		retransmit = retransmit[n:]
+	}
+	return nil
+}
+
+// broken handles a failure of ws. A normal closure ends the session, other failures wait for it to be resumed.
+func (c *resumeConn) broken(ws *websocket.Conn, err error) {
+	c.access.Lock()
+	if c.ws != ws || c.err != nil {
+		c.access.Unlock()
+		return
+	}
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		c.access.Unlock()
+		c.fail(io.EOF)
+		return
+	}
+	c.ws = nil
+	gen := c.gen
+	c.notifyLocked()
+	c.access.Unlock()
+	ws.Close()
+
+	newError("WebSocket connection broken, waiting for resumption").Base(err).AtDebug().WriteToLog()
+	if c.redial != nil {
+		go c.reconnect()
+		return
+	}
+	time.AfterFunc(c.window, func() {
+		c.access.Lock()
+		expired := c.ws == nil && c.gen == gen
+		c.access.Unlock()
+		if expired {
+			c.fail(newError("session not resumed in ", c.window))
+		}
+	})
+}
+
+func (c *resumeConn) reconnect() {
+	deadline := time.Now().Add(c.window)
+	delay := 100 * time.Millisecond
+	for {
+		c.access.Lock()
+		received, err := c.received, c.err
+		c.access.Unlock()
+		if err != nil {
+			return
+		}
+
+		ws, peerReceived, err := c.redial(c.token, received)
+		if err == nil {
+			if err = c.attach(ws, peerReceived); err == nil {
+				newError("WebSocket session resumed").AtDebug().WriteToLog()
+				return
+			}
+			ws.Close()
+		}
+		if time.Now().After(deadline) {
+			c.fail(newError("failed to resume session").Base(err))
+			return
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > 5*time.Second {
+			delay = 5 * time.Second
+		}
+	}
+}
+
+// fail ends the session with err.
+func (c *resumeConn) fail(err error) {
+	c.access.Lock()
+	if c.err != nil {
+		c.access.Unlock()
+		return
+	}
+	c.err = err
+	ws := c.ws
+	c.ws = nil
+	c.pending = nil
+	c.notifyLocked()
+	c.access.Unlock()
+
+	if ws != nil {
+		ws.Close()
+	}
+	if c.onClose != nil {
+		c.onClose()
+	}
+}
+
+func (c *resumeConn) handleFrame(ws *websocket.Conn, frame []byte) error {
+	if len(frame) < resumeFrameHeaderSize {
+		return newError("invalid frame of size ", len(frame))
+	}
+	n := binary.BigEndian.Uint64(frame[1:resumeFrameHeaderSize])
+
+	c.access.Lock()
+	switch frame[0] {
+	case resumeFrameData:
+		payload := frame[resumeFrameHeaderSize:]
+		if n > c.received {
+			c.access.Unlock()
+			return newError("missing data at ", c.received, ", got ", n)
+		}
+		// data sent again after a resumption may overlap what is already received
+		if skip := c.received - n; skip < uint64(len(payload)) {
+			c.leftover = payload[skip:]
+			c.received += uint64(len(c.leftover))
+		}
+		var ack bool
+		if c.received-c.ackSent >= resumeBufferSize/4 {
+			c.ackSent = c.received
+			ack = true
+		}
+		received := c.received
+		c.access.Unlock()
+		if ack {
+			c.writing.Lock()
+			// a failure is reported by the next read
+			writeResumeFrame(ws, resumeFrameAck, received, nil)
+			c.writing.Unlock()
+		}
+		return nil
+	case resumeFrameAck:
+		if n > c.sent {
+			c.access.Unlock()
+			return newError("acknowledged ", n, " bytes of ", c.sent)
+		}
+		if n > c.acked {
+			c.pending = append(c.pending[:0], c.pending[n-c.acked:]...)
+			c.acked = n
+			c.notifyLocked()
+		}
+		c.access.Unlock()
+		return nil
+	default:
+		c.access.Unlock()
+		return newError("unknown frame type ", frame[0])
+	}
+}
+
+// Read implements net.Conn.Read().
+func (c *resumeConn) Read(b []byte) (int, error) {
+	for {
+		c.access.Lock()
+		if len(c.leftover) > 0 {
+			n := copy(b, c.leftover)
+			c.leftover = c.leftover[n:]
+			c.access.Unlock()
+			return n, nil
+		}
+		ws, changed, err, deadline := c.ws, c.changed, c.err, c.readDeadline
+		c.access.Unlock()
+
+		if err != nil {
+			return 0, err
+		}
+		if ws == nil {
+			// detached, the deadline is not on any connection
+			if !waitChange(changed, deadline) {
+				return 0, os.ErrDeadlineExceeded
+			}
+			continue
+		}
+
+		_, frame, err := ws.ReadMessage()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return 0, err
+			}
+			c.broken(ws, err)
+			continue
+		}
+		if err := c.handleFrame(ws, frame); err != nil {
+			c.fail(err)
+			return 0, err
+		}
+	}
+}
+
+// Write implements net.Conn.Write().
+func (c *resumeConn) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		c.access.Lock()
+		if c.err != nil {
+			c.access.Unlock()
+			if c.err == io.EOF {
+				return written, io.ErrClosedPipe
+			}
+			return written, c.err
+		}
+		room := resumeBufferSize - len(c.pending)
+		if room <= 0 {
+			changed, deadline := c.changed, c.writeDeadline
+			c.access.Unlock()
+			if !waitChange(changed, deadline) {
+				return written, os.ErrDeadlineExceeded
+			}
+			continue
+		}
+		if room > resumeFrameSize {
+			room = resumeFrameSize
+		}
+		if room > len(b) {
+			room = len(b)
+		}
+		chunk := b[:room]
+		offset := c.sent
+		c.pending = append(c.pending, chunk...)
+		c.sent += uint64(len(chunk))
+		ws := c.ws
+		c.access.Unlock()
+
+		if ws != nil {
+			c.writing.Lock()
+			err := writeResumeFrame(ws, resumeFrameData, offset, chunk)
+			c.writing.Unlock()
+			if err != nil {
+				// the chunk is kept and sent again after the session is resumed
+				c.broken(ws, err)
+			}
+		}
+		b = b[len(chunk):]
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// Close implements net.Conn.Close().
+func (c *resumeConn) Close() error {
+	c.access.Lock()
+	ws := c.ws
+	c.access.Unlock()
+	if ws != nil {
+		c.writing.Lock()
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second*5))
+		c.writing.Unlock()
+	}
+	c.fail(io.ErrClosedPipe)
+	return nil
+}
+
+func (c *resumeConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *resumeConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *resumeConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *resumeConn) SetReadDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.readDeadline = t
+	// waiters check the new deadline
+	c.notifyLocked()
+	if c.ws != nil {
+		return c.ws.SetReadDeadline(t)
+	}
+	return nil
+}
+
+func (c *resumeConn) SetWriteDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.writeDeadline = t
+	// waiters check the new deadline
+	c.notifyLocked()
+	if c.ws != nil {
+		return c.ws.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+func writeResumeFrame(ws *websocket.Conn, frameType byte, n uint64, payload []byte) error {
+	frame := make([]byte, resumeFrameHeaderSize+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint64(frame[1:resumeFrameHeaderSize], n)
+	copy(frame[resumeFrameHeaderSize:], payload)
+	return ws.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func formatResumeHeader(token string, received uint64) string {
+	return token + " " + strconv.FormatUint(received, 10)
+}
+
+func parseResumeHeader(value string) (string, uint64, error) {
+	token, received, ok := strings.Cut(value, " ")
+	if !ok {
+		return "", 0, newError("invalid resume header: ", value)
+	}
+	n, err := strconv.ParseUint(received, 10, 64)
+	if err != nil {
+		return "", 0, newError("invalid resume header: ", value).Base(err)
+	}
+	return token, n, nil
+}
+
+// resumeHub keeps the resumable sessions of a listener.
+type resumeHub struct {
+	sync.Mutex
+	secret   []byte
+	window   time.Duration
+	sessions map[string]*resumeConn
+}
+
+func newResumeHub(window time.Duration) *resumeHub {
+	secret := make([]byte, 32)
+	common.Must2(rand.Read(secret))
+	return &resumeHub{
+		secret:   secret,
+		window:   window,
+		sessions: make(map[string]*resumeConn),
+	}
+}
+
+// newToken returns a random session id followed by its HMAC, so that tokens can't be forged without the secret.
+func (h *resumeHub) newToken() string {
+	token := make([]byte, 32)
+	common.Must2(rand.Read(token[:16]))
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(token[:16])
+	copy(token[16:], mac.Sum(nil))
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func (h *resumeHub) verifyToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 32 {
+		return false
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(b[:16])
+	return hmac.Equal(b[16:], mac.Sum(nil)[:16])
+}
+
+// pendingLocked returns the number of sessions without a connection. h must be locked.
+func (h *resumeHub) pendingLocked() int {
+	pending := 0
+	for _, s := range h.sessions {
+		s.access.Lock()
+		if s.ws == nil {
+			pending++
+		}
+		s.access.Unlock()
+	}
+	return pending
+}
+
+// accept handles the resume header of an upgrade request. It returns the session, the number of bytes
+// the client has received, and the value of the response header. peerReceived is 0 for a new session.
+func (h *resumeHub) accept(value string) (session *resumeConn, isNew bool, peerReceived uint64, response string, err error) {
+	if value == "new" {
+		token := h.newToken()
+		session = newResumeConn(token, h.window, nil, nil)
+		session.onClose = func() {
+			h.Lock()
+			delete(h.sessions, token)
+			h.Unlock()
+		}
+		h.Lock()
+		if h.pendingLocked() >= resumeMaxPending {
+			h.Unlock()
+			return nil, false, 0, "", newError("too many pending sessions")
+		}
+		h.sessions[token] = session
+		h.Unlock()
+		return session, true, 0, formatResumeHeader(token, 0), nil
+	}
+
+	token, peerReceived, err := parseResumeHeader(value)
+	if err != nil {
+		return nil, false, 0, "", err
+	}
+	if !h.verifyToken(token) {
+		return nil, false, 0, "", newError("invalid resume token")
+	}
+	h.Lock()
+	session = h.sessions[token]
+	h.Unlock()
+	if session == nil {
+		return nil, false, 0, "", newError("session expired")
+	}
+	session.access.Lock()
+	received := session.received
+	session.access.Unlock()
+	return session, false, peerReceived, formatResumeHeader(token, received), nil
+}
@@ -0,0 +1,219 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	gonet "net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/testing/servers/tcp"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+func TestResumeAfterBrokenConnection(t *testing.T) {
+	config := &Config{
+		Path:         "ws",
+		Resume:       true,
+		ResumeWindow: 5,
+	}
+	listenPort := tcp.PickPort()
+	listen, err := ListenWS(context.Background(), net.LocalHostIP, listenPort, &internet.MemoryStreamConfig{
+		ProtocolName:     "websocket",
+		ProtocolSettings: config,
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	})
+	common.Must(err)
+	defer listen.Close()
+
+	conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, listenPort), &internet.MemoryStreamConfig{
+		ProtocolName:     "websocket",
+		ProtocolSettings: config,
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	c, ok := conn.(*resumeConn)
+	if !ok {
+		t.Fatalf("expected a resumable connection, but got %T", conn)
+	}
+
+	payload := make([]byte, 4*1024*1024)
+	common.Must2(rand.Read(payload))
+	go func() {
+		for b := payload; len(b) > 0; b = b[32*1024:] {
+			if _, err := conn.Write(b[:32*1024]); err != nil {
+				return
+			}
+		}
+	}()
+
+	received := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, received[:len(payload)/4]); err != nil {
+		t.Fatal(err)
+	}
+
+	// break the underlying connection in the middle of the transfer
+	c.access.Lock()
+	ws := c.ws
+	c.access.Unlock()
+	common.Must(ws.UnderlyingConn().Close())
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := io.ReadFull(conn, received[len(payload)/4:]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, payload) {
+		t.Error("stream corrupted after resumption")
+	}
+
+	c.access.Lock()
+	resumed := c.gen > 1
+	c.access.Unlock()
+	if !resumed {
+		t.Error("session was not resumed")
+	}
+}
+
+func TestResumeToken(t *testing.T) {
+	hub := newResumeHub(time.Second)
+
+	s, isNew, _, response, err := hub.accept("new")
+	common.Must(err)
+	if !isNew {
+		t.Error("expected a new session")
+	}
+	token, _, err := parseResumeHeader(response)
+	common.Must(err)
+	if !hub.verifyToken(token) {
+		t.Error("token of the hub is not valid")
+	}
+
+	if resumed, _, _, _, err := hub.accept(formatResumeHeader(token, 0)); err != nil || resumed != s {
+		t.Error("failed to find session: ", err)
+	}
+
+	forged := []byte(token)
+	if forged[0] == 'A' {
+		forged[0] = 'B'
+	} else {
+		forged[0] = 'A'
+	}
+	if _, _, _, _, err := hub.accept(formatResumeHeader(string(forged), 0)); err == nil {
+		t.Error("accepted a forged token")
+	}
+	if _, _, _, _, err := hub.accept(formatResumeHeader(newResumeHub(time.Second).newToken(), 0)); err == nil {
+		t.Error("accepted a token of another hub")
+	}
+	if _, _, _, _, err := hub.accept("invalid"); err == nil {
+		t.Error("accepted an invalid header")
+	}
+
+	s.Close()
+	if _, _, _, _, err := hub.accept(formatResumeHeader(token, 0)); err == nil {
+		t.Error("resumed a closed session")
+	}
+}
+
+func TestResumeDeadlineWhileDetached(t *testing.T) {
+	c := newResumeConn("token", time.Second, nil, nil)
+	defer c.Close()
+
+	isTimeout := func(err error) bool {
+		ne, ok := err.(gonet.Error)
+		return ok && ne.Timeout()
+	}
+
+	common.Must(c.SetReadDeadline(time.Now().Add(50 * time.Millisecond)))
+	start := time.Now()
+	if _, err := c.Read(make([]byte, 1)); !isTimeout(err) {
+		t.Error("expected a timeout, but got ", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Error("read returned after ", d)
+	}
+
+	// writes are kept while detached, until the buffer is full
+	common.Must2(c.Write(make([]byte, resumeBufferSize)))
+	common.Must(c.SetWriteDeadline(time.Now().Add(50 * time.Millisecond)))
+	if _, err := c.Write(make([]byte, 1)); !isTimeout(err) {
+		t.Error("expected a timeout, but got ", err)
+	}
+}
+
+func TestResumeMaxPending(t *testing.T) {
+	hub := newResumeHub(time.Second)
+	for i := 0; i < resumeMaxPending; i++ {
+		if _, _, _, _, err := hub.accept("new"); err != nil {
+			t.Fatal("session ", i, " refused: ", err)
+		}
+	}
+	if _, _, _, _, err := hub.accept("new"); err == nil {
+		t.Error("accepted more pending sessions than allowed")
+	}
+}
+
+func TestResumeRefusalLooksLikeFailedUpgrade(t *testing.T) {
+	listenPort := tcp.PickPort()
+	listen, err := ListenWS(context.Background(), net.LocalHostIP, listenPort, &internet.MemoryStreamConfig{
+		ProtocolName: "websocket",
+		ProtocolSettings: &Config{
+			Path:   "ws",
+			Resume: true,
+		},
+	}, func(conn stat.Connection) {
+		conn.Close()
+	})
+	common.Must(err)
+	defer listen.Close()
+
+	get := func(header http.Header) (*http.Response, []byte) {
+		request, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:"+strconv.Itoa(int(listenPort))+"/ws", nil)
+		common.Must(err)
+		request.Header = header
+		response, err := http.DefaultClient.Do(request)
+		common.Must(err)
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		common.Must(err)
+		return response, body
+	}
+	header := func(key, resume string) http.Header {
+		h := http.Header{}
+		h.Set("Connection", "Upgrade")
+		h.Set("Upgrade", "websocket")
+		h.Set("Sec-WebSocket-Version", "13")
+		if key != "" {
+			h.Set("Sec-WebSocket-Key", key)
+		}
+		if resume != "" {
+			h.Set(resumeHeader, resume)
+		}
+		return h
+	}
+
+	// refused by the upgrader for the missing key
+	failed, failedBody := get(header("", ""))
+	// a well-formed upgrade with a token that is not ours
+	refused, refusedBody := get(header("dGhlIHNhbXBsZSBub25jZQ==", formatResumeHeader(newResumeHub(time.Second).newToken(), 0)))
+
+	if refused.StatusCode != failed.StatusCode || !bytes.Equal(refusedBody, failedBody) {
+		t.Error("refused resumption answered ", refused.StatusCode, " ", string(refusedBody), ", failed upgrade ", failed.StatusCode, " ", string(failedBody))
+	}
+	for _, key := range []string{"Content-Type", "Sec-Websocket-Version"} {
+		if refused.Header.Get(key) != failed.Header.Get(key) {
+			t.Error("header ", key, " differs: ", refused.Header.Get(key), " and ", failed.Header.Get(key))
+		}
+	}
+}
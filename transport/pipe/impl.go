@@ -39,6 +39,7 @@ type pipe struct {
 	errChan     chan error
 	option      pipeOption
 	state       state
+	cause       error
 }
 
 var (
@@ -62,7 +63,7 @@ func (p *pipe) getState(forRead bool) error {
 		}
 		return io.EOF
 	case errord:
-		return io.ErrClosedPipe
+		return p.interruptedError()
 	default:
 		panic("impossible case")
 	}
@@ -169,7 +170,13 @@ func (p *pipe) WriteMultiBuffer(mb buf.MultiBuffer) error {
 		select {
 		case <-p.writeSignal.Wait():
 		case <-p.done.Wait():
-			return io.ErrClosedPipe
+			p.Lock()
+			err := io.ErrClosedPipe
+			if p.state == errord {
+				err = p.interruptedError()
+			}
+			p.Unlock()
+			return err
 		}
 	}
 }
@@ -189,6 +196,11 @@ func (p *pipe) Close() error {
 
 // Interrupt implements common.Interruptible.
 func (p *pipe) Interrupt() {
+	p.InterruptWithError(nil)
+}
+
+// InterruptWithError implements common.ErrorInterruptible.
+func (p *pipe) InterruptWithError(err error) {
 	p.Lock()
 	defer p.Unlock()
 
@@ -197,6 +209,7 @@ func (p *pipe) Interrupt() {
 	}
 
 	p.state = errord
+	p.cause = err
 
 	if !p.data.IsEmpty() {
 		buf.ReleaseMulti(p.data)
@@ -205,3 +218,11 @@ func (p *pipe) Interrupt() {
 
 	common.Must(p.done.Close())
 }
+
+// interruptedError returns the error of operations on an interrupted pipe, carrying the cause of the interruption if any.
+func (p *pipe) interruptedError() error {
+	if p.cause == nil {
+		return io.ErrClosedPipe
+	}
+	return newError("pipe interrupted").Base(p.cause)
+}
@@ -1,5 +1,7 @@
 package pipe
 
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
 import (
 	"context"
 
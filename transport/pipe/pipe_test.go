@@ -49,6 +49,47 @@ func TestPipeInterrupt(t *testing.T) {
 	}
 }
 
+func TestPipeInterruptWithError(t *testing.T) {
+	cause := errors.New("upstream failed")
+
+	pReader, pWriter := New(WithSizeLimit(1024))
+	pWriter.InterruptWithError(cause)
+	if _, err := pReader.ReadMultiBuffer(); !errors.Is(err, cause) {
+		t.Fatal("expect error caused by ", cause, ", but got ", err)
+	}
+
+	pReader, pWriter = New(WithSizeLimit(1024))
+	pReader.InterruptWithError(cause)
+	b := buf.New()
+	common.Must2(b.WriteString("abcd"))
+	if err := pWriter.WriteMultiBuffer(buf.MultiBuffer{b}); !errors.Is(err, cause) {
+		t.Fatal("expect error caused by ", cause, ", but got ", err)
+	}
+}
+
+func TestPipeInterruptBlockedWriter(t *testing.T) {
+	cause := errors.New("downstream failed")
+	pReader, pWriter := New(WithSizeLimit(0))
+
+	b := buf.New()
+	common.Must2(b.WriteString("abcd"))
+	common.Must(pWriter.WriteMultiBuffer(buf.MultiBuffer{b}))
+
+	var errg errgroup.Group
+	errg.Go(func() error {
+		b := buf.New()
+		common.Must2(b.WriteString("efgh"))
+		return pWriter.WriteMultiBuffer(buf.MultiBuffer{b})
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	pReader.InterruptWithError(cause)
+
+	if err := errg.Wait(); !errors.Is(err, cause) {
+		t.Fatal("expect error caused by ", cause, ", but got ", err)
+	}
+}
+
 func TestPipeClose(t *testing.T) {
 	pReader, pWriter := New(WithSizeLimit(1024))
 	payload := []byte{'a', 'b', 'c', 'd'}
@@ -26,6 +26,11 @@ func (r *Reader) Interrupt() {
 	r.pipe.Interrupt()
 }
 
+// InterruptWithError implements common.ErrorInterruptible.
+func (r *Reader) InterruptWithError(err error) {
+	r.pipe.InterruptWithError(err)
+}
+
 // ReturnAnError makes ReadMultiBuffer return an error, only once.
 func (r *Reader) ReturnAnError(err error) {
 	r.pipe.errChan <- err
@@ -23,3 +23,8 @@ func (w *Writer) Close() error {
 func (w *Writer) Interrupt() {
 	w.pipe.Interrupt()
 }
+
+// InterruptWithError implements common.ErrorInterruptible.
+func (w *Writer) InterruptWithError(err error) {
+	w.pipe.InterruptWithError(err)
+}